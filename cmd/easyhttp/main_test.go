@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// runCommand runs the command and returns its exit code, stdout and stderr
+func runCommand(stdin string, args ...string) (int, string, string) {
+	var stdout, stderr bytes.Buffer
+	code := run(args, strings.NewReader(stdin), &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func TestRun(t *testing.T) {
+	t.Run("sends the request of the flags", func(t *testing.T) {
+		var got *http.Request
+		var gotBody string
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+			got, gotBody = r, string(body)
+			w.Header().Set("X-Request-Id", "abc")
+			_, _ = w.Write([]byte("created"))
+		}))
+		defer server.Close()
+
+		code, stdout, stderr := runCommand(`{"id":1}`, "-json", "-d", "@-", "-H", "X-Tenant: acme", "-i", server.URL+"/orders?limit=10&sort=id")
+
+		require.Equal(t, exitOK, code, stderr)
+		assert.Equal(t, http.MethodPost, got.Method)
+		assert.Equal(t, "/orders", got.URL.Path)
+		assert.Equal(t, "limit=10&sort=id", got.URL.RawQuery)
+		assert.Equal(t, "acme", got.Header.Get("X-Tenant"))
+		assert.Equal(t, "application/json", got.Header.Get("Content-Type"))
+		assert.Equal(t, `{"id":1}`, gotBody)
+		assert.True(t, strings.HasPrefix(stdout, "200 OK\n"))
+		assert.Contains(t, stdout, "X-Request-Id: abc\n")
+		assert.True(t, strings.HasSuffix(stdout, "\n\ncreated"))
+	})
+
+	t.Run("writes the response as JSON", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"error":"missing"}`))
+		}))
+		defer server.Close()
+
+		code, stdout, _ := runCommand("", "-o", "json", "-fail", server.URL)
+
+		assert.Equal(t, exitRequestFail, code)
+		var doc map[string]any
+		require.NoError(t, json.Unmarshal([]byte(stdout), &doc))
+		assert.Equal(t, float64(http.StatusNotFound), doc["status_code"])
+		assert.Equal(t, map[string]any{"error": "missing"}, doc["body"])
+		assert.Equal(t, []any{"application/json"}, doc["headers"].(map[string]any)["Content-Type"])
+	})
+
+	t.Run("retries and reports events", func(t *testing.T) {
+		var requests atomic.Int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			if requests.Add(1) == 1 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			_, _ = w.Write([]byte("ok"))
+		}))
+		defer server.Close()
+
+		code, stdout, stderr := runCommand("", "-retries", "3", "-retry-delay", "1ms", "-retry-strategy", "fixed", "-events", "-o", "status", server.URL+"/orders")
+
+		require.Equal(t, exitOK, code, stderr)
+		assert.Equal(t, "200 OK\n", stdout)
+		assert.Equal(t, int32(2), requests.Load())
+		assert.Contains(t, stderr, "retry: GET /orders attempt 2/3 in 1ms after status 503")
+	})
+
+	t.Run("exports spans", func(t *testing.T) {
+		var traceparent string
+		server := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+			traceparent = r.Header.Get("Traceparent")
+		}))
+		defer server.Close()
+
+		code, _, stderr := runCommand("", "-trace", server.URL)
+
+		require.Equal(t, exitOK, code, stderr)
+		var span jsonSpan
+		require.NoError(t, json.Unmarshal([]byte(strings.TrimSpace(stderr)), &span))
+		assert.Equal(t, "GET", span.Attributes["http.method"])
+		assert.Contains(t, traceparent, span.TraceID)
+	})
+
+	t.Run("streams the response body", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.Header().Set("Content-Type", "text/event-stream")
+			_, _ = w.Write([]byte("data: one\n\n"))
+			w.(http.Flusher).Flush()
+			_, _ = w.Write([]byte("data: two\n\n"))
+		}))
+		defer server.Close()
+
+		code, stdout, stderr := runCommand("", "-N", server.URL)
+
+		require.Equal(t, exitOK, code, stderr)
+		assert.Equal(t, "data: one\n\ndata: two\n\n", stdout)
+	})
+
+	t.Run("rejects invalid usage", func(t *testing.T) {
+		for name, args := range map[string][]string{
+			"missing URL":    {},
+			"relative URL":   {"/orders"},
+			"unknown output": {"-o", "yaml", "http://localhost"},
+			"invalid header": {"-H", "X-Tenant", "http://localhost"},
+			"json stream":    {"-N", "-o", "json", "http://localhost"},
+		} {
+			code, _, stderr := runCommand("", args...)
+			assert.Equal(t, exitUsage, code, name)
+			assert.NotEmpty(t, stderr, name)
+		}
+	})
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/bdpiprava/easy-http/pkg/httpx"
+)
+
+// Output formats of the -o flag
+const (
+	outputBody    = "body"
+	outputHeaders = "headers"
+	outputStatus  = "status"
+	outputJSON    = "json"
+)
+
+// jsonResponse is the document written by -o json
+type jsonResponse struct {
+	Status     string      `json:"status"`
+	StatusCode int         `json:"status_code"`
+	Headers    http.Header `json:"headers"`
+	Body       any         `json:"body,omitempty"`
+	DurationMS float64     `json:"duration_ms"`
+}
+
+// writeResponse writes the response in the format selected by the flags
+func writeResponse(w io.Writer, opts options, resp *httpx.Response, duration time.Duration) error {
+	if resp.IsStreaming {
+		defer resp.Close()
+	}
+
+	switch opts.output {
+	case outputStatus:
+		_, err := fmt.Fprintln(w, resp.Status)
+		return err
+	case outputHeaders:
+		return writeHeaders(w, resp)
+	case outputJSON:
+		return writeJSON(w, resp, duration)
+	}
+
+	if opts.include {
+		if err := writeHeaders(w, resp); err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintln(w); err != nil {
+			return err
+		}
+	}
+	if resp.IsStreaming {
+		_, err := io.Copy(w, resp.StreamBody)
+		return errors.Wrap(err, "failed to read the response stream")
+	}
+	_, err := w.Write(resp.RawBody)
+	return err
+}
+
+// writeHeaders writes the status line and the response headers sorted by name
+func writeHeaders(w io.Writer, resp *httpx.Response) error {
+	if _, err := fmt.Fprintln(w, resp.Status); err != nil {
+		return err
+	}
+	header := resp.Header()
+	names := make([]string, 0, len(header))
+	for name := range header {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		for _, value := range header[name] {
+			if _, err := fmt.Fprintf(w, "%s: %s\n", name, value); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// writeJSON writes the response as an indented JSON document, embedding JSON bodies as-is
+func writeJSON(w io.Writer, resp *httpx.Response, duration time.Duration) error {
+	doc := jsonResponse{
+		Status:     resp.Status,
+		StatusCode: resp.StatusCode,
+		Headers:    resp.Header(),
+		DurationMS: float64(duration.Microseconds()) / 1000,
+	}
+	if len(resp.RawBody) > 0 {
+		doc.Body = string(resp.RawBody)
+		if json.Valid(resp.RawBody) {
+			doc.Body = json.RawMessage(resp.RawBody)
+		}
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return errors.Wrap(encoder.Encode(doc), "failed to encode the response")
+}
+
+// printEvents writes the client events to w until the returned function is called
+// Events are emitted before the request that caused them completes, so stopping after the last
+// request still prints all of them.
+func printEvents(client *httpx.Client, w io.Writer) (stop func()) {
+	events := client.Events()
+	done := make(chan struct{})
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		for {
+			select {
+			case event := <-events:
+				_, _ = fmt.Fprintln(w, formatEvent(event))
+			case <-done:
+				for {
+					select {
+					case event := <-events:
+						_, _ = fmt.Fprintln(w, formatEvent(event))
+					default:
+						return
+					}
+				}
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-finished
+	}
+}
+
+// formatEvent renders an event as a single line
+func formatEvent(event httpx.Event) string {
+	prefix := event.EventTime().Format(time.RFC3339Nano)
+	switch e := event.(type) {
+	case httpx.RetryEvent:
+		cause := fmt.Sprintf("status %d", e.StatusCode)
+		if e.Err != nil {
+			cause = e.Err.Error()
+		}
+		return fmt.Sprintf("%s retry: %s attempt %d/%d in %s after %s", prefix, e.Op, e.Attempt, e.MaxAttempts, e.Delay, cause)
+	case httpx.CircuitBreakerEvent:
+		if e.Rejected {
+			return fmt.Sprintf("%s circuit breaker %s: rejected %s while %s", prefix, e.Name, e.Op, e.To)
+		}
+		return fmt.Sprintf("%s circuit breaker %s: %s -> %s", prefix, e.Name, e.From, e.To)
+	case httpx.RateLimitEvent:
+		if e.Rejected {
+			return fmt.Sprintf("%s rate limit %s: rejected %s", prefix, e.Host, e.Op)
+		}
+		return fmt.Sprintf("%s rate limit %s: %s waits %s", prefix, e.Host, e.Op, e.Wait)
+	default:
+		return fmt.Sprintf("%s %T: %+v", prefix, event, event)
+	}
+}
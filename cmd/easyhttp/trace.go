@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+
+	"github.com/bdpiprava/easy-http/pkg/httpx"
+)
+
+// jsonSpan is the JSON line written for each finished span
+type jsonSpan struct {
+	Name       string         `json:"name"`
+	TraceID    string         `json:"trace_id"`
+	SpanID     string         `json:"span_id"`
+	ParentID   string         `json:"parent_id,omitempty"`
+	Start      time.Time      `json:"start"`
+	DurationMS float64        `json:"duration_ms"`
+	Status     string         `json:"status"`
+	Message    string         `json:"message,omitempty"`
+	Attributes map[string]any `json:"attributes,omitempty"`
+}
+
+// jsonSpanExporter writes spans as JSON lines
+type jsonSpanExporter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// ExportSpans implements sdktrace.SpanExporter
+func (e *jsonSpanExporter) ExportSpans(_ context.Context, spans []sdktrace.ReadOnlySpan) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	encoder := json.NewEncoder(e.w)
+	for _, span := range spans {
+		line := jsonSpan{
+			Name:       span.Name(),
+			TraceID:    span.SpanContext().TraceID().String(),
+			SpanID:     span.SpanContext().SpanID().String(),
+			Start:      span.StartTime(),
+			DurationMS: float64(span.EndTime().Sub(span.StartTime()).Microseconds()) / 1000,
+			Status:     span.Status().Code.String(),
+			Message:    span.Status().Description,
+		}
+		if span.Parent().IsValid() {
+			line.ParentID = span.Parent().SpanID().String()
+		}
+		if attrs := span.Attributes(); len(attrs) > 0 {
+			line.Attributes = make(map[string]any, len(attrs))
+			for _, attr := range attrs {
+				line.Attributes[string(attr.Key)] = attr.Value.AsInterface()
+			}
+		}
+		if err := encoder.Encode(line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Shutdown implements sdktrace.SpanExporter
+func (e *jsonSpanExporter) Shutdown(context.Context) error {
+	return nil
+}
+
+// newTracing returns the client option exporting request spans to w, and the function flushing them
+func newTracing(w io.Writer) (httpx.ClientConfigOption, func(context.Context) error) {
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(&jsonSpanExporter{w: w}))
+	option := httpx.WithClientTracing(httpx.TracingConfig{
+		TracerProvider: provider,
+		Propagator:     propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}),
+	})
+	return option, provider.Shutdown
+}
@@ -310,13 +310,11 @@ func productionReadyClientExample() {
 		)
 	}
 
-	// Production retry policy
-	retryPolicy := httpx.ConservativeRetryPolicy()
-
-	// Production-ready client
+	// Production-ready client: start from the profile matching the workload and
+	// only override what differs, instead of copying timeouts and retry numbers
 	client := httpx.NewClientWithConfig(
+		httpx.ProfileInteractive(),
 		httpx.WithClientDefaultBaseURL(server.URL),
-		httpx.WithClientTimeout(10*time.Second),
 		httpx.WithClientLogger(logger),
 		httpx.WithClientLogLevel(slog.LevelInfo),
 		httpx.WithClientDefaultHeaders(http.Header{
@@ -325,7 +323,6 @@ func productionReadyClientExample() {
 			"Content-Type": []string{"application/json"},
 		}),
 		httpx.WithClientCircuitBreaker(cbConfig),
-		httpx.WithClientRetryPolicy(retryPolicy),
 	)
 
 	fmt.Println("  Production client making API calls:")
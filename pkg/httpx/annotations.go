@@ -0,0 +1,127 @@
+package httpx
+
+import (
+	"context"
+	"log/slog"
+	"maps"
+	"net/http"
+	"path"
+	"slices"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// annotationsKey is the context key holding request annotations
+type annotationsKey struct{}
+
+// operationKey is the context key holding the operation name set with WithOperation
+type operationKey struct{}
+
+// ContextWithOperation returns a context carrying the operation name reported in HTTPError.Op
+func ContextWithOperation(ctx context.Context, op string) context.Context {
+	if op == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, operationKey{}, op)
+}
+
+// OperationFromContext returns the operation name carried by ctx, or "" if there is none
+func OperationFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	op, _ := ctx.Value(operationKey{}).(string)
+	return op
+}
+
+// requestOperation returns the operation name of the request, defaulting to "METHOD /path" with the path
+// template of requests built with path parameters
+func requestOperation(req *http.Request) string {
+	if req == nil {
+		return ""
+	}
+	if op := OperationFromContext(req.Context()); op != "" {
+		return op
+	}
+	if template := PathTemplateFromContext(req.Context()); template != "" {
+		return req.Method + " " + path.Join("/", template)
+	}
+	return req.Method + " " + CanonicalRequestOf(req).URL.Path
+}
+
+// ContextWithAnnotations returns a context carrying the given annotations merged over any already present
+// Annotations are business context (e.g. order_id) attached to HTTPErrors, logs and spans of the request
+func ContextWithAnnotations(ctx context.Context, annotations map[string]string) context.Context {
+	if len(annotations) == 0 {
+		return ctx
+	}
+	merged := maps.Clone(AnnotationsFromContext(ctx))
+	if merged == nil {
+		merged = make(map[string]string, len(annotations))
+	}
+	maps.Copy(merged, annotations)
+	return context.WithValue(ctx, annotationsKey{}, merged)
+}
+
+// AnnotationsFromContext returns the annotations carried by ctx, or nil if there are none
+// The returned map must not be modified
+func AnnotationsFromContext(ctx context.Context) map[string]string {
+	if ctx == nil {
+		return nil
+	}
+	annotations, _ := ctx.Value(annotationsKey{}).(map[string]string)
+	return annotations
+}
+
+// annotateError attaches the operation and annotations to an HTTPError created without the request context
+func annotateError(httpErr *HTTPError, req *http.Request, opts RequestOptions) *HTTPError {
+	if httpErr == nil {
+		return nil
+	}
+	if httpErr.Op == "" {
+		switch {
+		case opts.Operation != "":
+			httpErr.Op = opts.Operation
+		case req != nil:
+			httpErr.Op = requestOperation(req)
+		default:
+			httpErr.Op = strings.ToUpper(opts.Method) + " " + path.Join("/", opts.Path)
+		}
+	}
+	if httpErr.Annotations == nil && len(opts.Annotations) > 0 {
+		httpErr.Annotations = opts.Annotations
+	}
+	return httpErr
+}
+
+// formatAnnotations renders annotations as sorted key=value pairs
+func formatAnnotations(annotations map[string]string) string {
+	pairs := make([]string, 0, len(annotations))
+	for _, key := range slices.Sorted(maps.Keys(annotations)) {
+		pairs = append(pairs, key+"="+annotations[key])
+	}
+	return strings.Join(pairs, " ")
+}
+
+// annotationLogAttr returns the annotations as a log group, reporting false if there are none
+func annotationLogAttr(annotations map[string]string) (slog.Attr, bool) {
+	if len(annotations) == 0 {
+		return slog.Attr{}, false
+	}
+	args := make([]any, 0, len(annotations))
+	for _, key := range slices.Sorted(maps.Keys(annotations)) {
+		args = append(args, slog.String(key, annotations[key]))
+	}
+	return slog.Group(LogFieldAnnotations, args...), true
+}
+
+// annotationSpanAttributes returns the annotations of ctx as span attributes prefixed with "annotation."
+func annotationSpanAttributes(ctx context.Context) []attribute.KeyValue {
+	annotations := AnnotationsFromContext(ctx)
+	attrs := make([]attribute.KeyValue, 0, len(annotations))
+	for _, key := range slices.Sorted(maps.Keys(annotations)) {
+		attrs = append(attrs, attribute.String("annotation."+key, annotations[key]))
+	}
+	return attrs
+}
@@ -0,0 +1,120 @@
+package httpx_test
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/bdpiprava/easy-http/pkg/httpx"
+)
+
+func TestWithAnnotation(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	t.Run("attaches annotations to errors after retries", func(t *testing.T) {
+		closed := httptest.NewServer(http.NotFoundHandler())
+		closed.Close()
+
+		policy := httpx.DefaultRetryPolicy()
+		policy.BaseDelay = time.Millisecond
+		policy.MaxDelay = time.Millisecond
+		client := httpx.NewClientWithConfig(
+			httpx.WithClientDefaultBaseURL(closed.URL),
+			httpx.WithClientRetryPolicy(policy),
+		)
+
+		_, err := client.Execute(*httpx.NewRequest(http.MethodPost,
+			httpx.WithPath("/orders"),
+			httpx.WithAnnotation("order_id", "ord-42"),
+			httpx.WithAnnotation("tenant", "acme"),
+		), map[string]any{})
+
+		require.Error(t, err)
+		assert.Equal(t, map[string]string{"order_id": "ord-42", "tenant": "acme"}, httpx.GetAnnotations(err))
+		assert.Contains(t, err.Error(), "[order_id=ord-42 tenant=acme]")
+	})
+
+	t.Run("attaches annotations to build errors", func(t *testing.T) {
+		client := httpx.NewClientWithConfig()
+
+		_, err := client.Execute(*httpx.NewRequest("BREW",
+			httpx.WithBaseURL(server.URL),
+			httpx.WithAnnotation("order_id", "ord-42"),
+		), map[string]any{})
+
+		require.Error(t, err)
+		assert.Equal(t, map[string]string{"order_id": "ord-42"}, httpx.GetAnnotations(err))
+	})
+
+	t.Run("emits annotations in logs", func(t *testing.T) {
+		var buf bytes.Buffer
+		logger := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+		client := httpx.NewClientWithConfig(
+			httpx.WithClientDefaultBaseURL(server.URL),
+			httpx.WithClientLogger(logger),
+			httpx.WithClientLogLevel(slog.LevelDebug),
+		)
+
+		_, err := client.Execute(*httpx.NewRequest(http.MethodGet, httpx.WithAnnotation("order_id", "ord-42")), map[string]any{})
+		require.NoError(t, err)
+
+		record := findLogRecord(t, &buf, "HTTP response")
+		require.NotNil(t, record)
+		assert.Equal(t, map[string]any{"order_id": "ord-42"}, record["annotations"])
+	})
+
+	t.Run("emits annotations as span attributes", func(t *testing.T) {
+		exporter := tracetest.NewInMemoryExporter()
+		tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+		client := httpx.NewClientWithConfig(
+			httpx.WithClientDefaultBaseURL(server.URL),
+			httpx.WithClientTracing(httpx.TracingConfig{TracerProvider: tp}),
+		)
+
+		_, err := client.Execute(*httpx.NewRequest(http.MethodGet, httpx.WithAnnotation("order_id", "ord-42")), map[string]any{})
+		require.NoError(t, err)
+
+		spans := exporter.GetSpans()
+		require.Len(t, spans, 1)
+		assert.Contains(t, spans[0].Attributes, attribute.String("annotation.order_id", "ord-42"))
+	})
+
+	t.Run("merges annotations already in the context", func(t *testing.T) {
+		ctx := httpx.ContextWithAnnotations(context.Background(), map[string]string{"tenant": "acme", "order_id": "old"})
+		client := httpx.NewClientWithConfig(
+			httpx.WithClientDefaultBaseURL(server.URL),
+			httpx.WithClientMiddleware(rejectingMiddleware{}),
+		)
+
+		_, err := client.Execute(*httpx.NewRequest(http.MethodGet,
+			httpx.WithContext(ctx),
+			httpx.WithAnnotation("order_id", "ord-42"),
+		), map[string]any{})
+
+		require.Error(t, err)
+		assert.True(t, httpx.IsMiddlewareError(err))
+		assert.Equal(t, map[string]string{"order_id": "ord-42", "tenant": "acme"}, httpx.GetAnnotations(err))
+	})
+}
+
+// rejectingMiddleware fails every request without sending it
+type rejectingMiddleware struct{}
+
+func (rejectingMiddleware) Name() string { return "rejecting" }
+
+func (rejectingMiddleware) Execute(_ context.Context, req *http.Request, _ httpx.MiddlewareFunc) (*http.Response, error) {
+	return nil, httpx.MiddlewareError("request rejected", nil, req)
+}
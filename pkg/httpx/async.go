@@ -0,0 +1,322 @@
+package httpx
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	defaultAsyncPollInterval    = time.Second
+	defaultAsyncMaxPollInterval = time.Minute
+)
+
+// AsyncStatus is the state of a long-running operation
+type AsyncStatus string
+
+const (
+	// AsyncStatusRunning indicates the operation has not completed yet
+	AsyncStatusRunning AsyncStatus = "running"
+	// AsyncStatusSucceeded indicates the operation completed successfully
+	AsyncStatusSucceeded AsyncStatus = "succeeded"
+	// AsyncStatusFailed indicates the operation completed with an error
+	AsyncStatusFailed AsyncStatus = "failed"
+	// AsyncStatusCanceled indicates the operation was canceled before completing
+	AsyncStatusCanceled AsyncStatus = "canceled"
+)
+
+// AsyncStatusMapper maps a poll response, given by its status code and JSON object body (nil if the body is
+// not an object), to the status of the operation
+type AsyncStatusMapper func(statusCode int, body map[string]any) AsyncStatus
+
+// AsyncProgress describes a poll of a long-running operation
+type AsyncProgress struct {
+	Status   AsyncStatus // Status of the operation after this poll
+	Percent  float64     // Completion reported by the percentComplete or progress field, -1 when unknown
+	Polls    int         // Number of polls sent so far
+	Response *Response   // Poll response, its body is the raw body as a string
+}
+
+// AsyncConfig holds the configuration of AsyncOperation
+type AsyncConfig struct {
+	PollInterval    time.Duration       // Delay between polls when the server sends no Retry-After (default: 1s)
+	MaxPollInterval time.Duration       // Cap on Retry-After delays requested by the server (default: 1m)
+	Timeout         time.Duration       // Bound on the whole operation, 0 relies on the request context only
+	StatusMapper    AsyncStatusMapper   // Maps poll responses to a status (default: DefaultAsyncStatus)
+	OnProgress      func(AsyncProgress) // Optional callback invoked after every poll
+	PollOptions     []RequestOption     // Options of poll and result requests, e.g. per-request auth headers
+}
+
+// AsyncOption configures AsyncOperation
+type AsyncOption func(*AsyncConfig)
+
+// WithAsyncPollInterval sets the delay between polls used when the server sends no Retry-After header
+func WithAsyncPollInterval(interval time.Duration) AsyncOption {
+	return func(c *AsyncConfig) {
+		c.PollInterval = interval
+	}
+}
+
+// WithAsyncMaxPollInterval caps the delays requested by the server with Retry-After
+func WithAsyncMaxPollInterval(interval time.Duration) AsyncOption {
+	return func(c *AsyncConfig) {
+		c.MaxPollInterval = interval
+	}
+}
+
+// WithAsyncTimeout bounds the whole operation, from the initial request to the final result
+func WithAsyncTimeout(timeout time.Duration) AsyncOption {
+	return func(c *AsyncConfig) {
+		c.Timeout = timeout
+	}
+}
+
+// WithAsyncStatusMapper replaces DefaultAsyncStatus to support APIs reporting their status differently
+func WithAsyncStatusMapper(mapper AsyncStatusMapper) AsyncOption {
+	return func(c *AsyncConfig) {
+		c.StatusMapper = mapper
+	}
+}
+
+// WithAsyncProgress sets a callback invoked after every poll
+func WithAsyncProgress(callback func(AsyncProgress)) AsyncOption {
+	return func(c *AsyncConfig) {
+		c.OnProgress = callback
+	}
+}
+
+// WithAsyncPollOptions adds request options to poll and result requests, which otherwise only inherit the
+// client configuration and the context of the initial request
+func WithAsyncPollOptions(opts ...RequestOption) AsyncOption {
+	return func(c *AsyncConfig) {
+		c.PollOptions = append(c.PollOptions, opts...)
+	}
+}
+
+// DefaultAsyncStatus maps the status field used by Azure style operations (NotStarted, Running, Succeeded,
+// Failed, Canceled...) and the done and error fields of Google style operations. Bodies without either are
+// running while the server answers 202 Accepted, and succeeded otherwise, the body being the final resource.
+func DefaultAsyncStatus(statusCode int, body map[string]any) AsyncStatus {
+	if status, ok := body["status"].(string); ok {
+		switch strings.ToLower(status) {
+		case "succeeded", "success", "successful", "completed", "complete", "done":
+			return AsyncStatusSucceeded
+		case "failed", "failure", "error":
+			return AsyncStatusFailed
+		case "canceled", "cancelled":
+			return AsyncStatusCanceled
+		}
+		return AsyncStatusRunning
+	}
+	if done, ok := body["done"].(bool); ok {
+		switch {
+		case !done:
+			return AsyncStatusRunning
+		case body["error"] != nil:
+			return AsyncStatusFailed
+		}
+		return AsyncStatusSucceeded
+	}
+	if statusCode == http.StatusAccepted {
+		return AsyncStatusRunning
+	}
+	return AsyncStatusSucceeded
+}
+
+// AsyncOperation sends req and, when the server answers 202 Accepted, polls the operation monitor given by
+// the Operation-Location, Azure-AsyncOperation or Location header until the operation completes, waiting as
+// requested by Retry-After between polls. The returned response holds the final result decoded as T, taken
+// from the resourceLocation field, the response field of Google style operations, the resource itself for
+// PUT and PATCH requests, the Location header, or else the last poll body.
+// Error responses are returned like Execute does, without an error. Operations that fail or are canceled
+// return the last poll response with an error matching ErrOperationFailed.
+func AsyncOperation[T any](client *Client, req Request, opts ...AsyncOption) (*Response, error) {
+	config := AsyncConfig{
+		PollInterval:    defaultAsyncPollInterval,
+		MaxPollInterval: defaultAsyncMaxPollInterval,
+		StatusMapper:    DefaultAsyncStatus,
+	}
+	for _, opt := range opts {
+		opt(&config)
+	}
+
+	requestOpts := buildOptsFromConfig(client.config, &req)
+	ctx := requestOpts.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if config.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, config.Timeout)
+		defer cancel()
+	}
+	config.PollOptions = append(slices.Clip(config.PollOptions), WithContext(ctx))
+
+	resp, err := client.Execute(*req.Clone(WithContext(ctx)), "")
+	if err != nil || resp.StatusCode > 299 {
+		return resp, err
+	}
+	if resp.StatusCode != http.StatusAccepted {
+		return resp, decodeResponseBody(resp, resp.RawBody, *(new(T)), &client.config)
+	}
+
+	poller := &asyncPoller{client: client, config: config, method: requestOpts.Method, request: resp.httpResponse.Request.URL}
+	if poller.monitor, err = asyncLocation(resp, "Operation-Location", "Azure-AsyncOperation", "Location"); err != nil {
+		return resp, err
+	}
+	if poller.monitor == nil {
+		return resp, errors.New("async operation accepted without an Operation-Location, Azure-AsyncOperation or Location header")
+	}
+	poller.result, _ = asyncLocation(resp, "Location")
+
+	for polls := 1; ; polls++ {
+		if err = poller.wait(ctx, resp); err != nil {
+			return resp, err
+		}
+		if resp, err = client.Execute(*NewRequest(http.MethodGet, poller.requestOptions(poller.monitor)...), ""); err != nil || resp.StatusCode > 299 {
+			return resp, err
+		}
+
+		body := asyncBody(resp.RawBody)
+		status := config.StatusMapper(resp.StatusCode, body)
+		if config.OnProgress != nil {
+			config.OnProgress(AsyncProgress{Status: status, Percent: asyncPercent(body), Polls: polls, Response: resp})
+		}
+		switch status {
+		case AsyncStatusSucceeded:
+			return asyncResult[T](poller, resp, body)
+		case AsyncStatusFailed, AsyncStatusCanceled:
+			return resp, tagError(errors.Errorf("async operation %s%s", status, asyncErrorDetail(body)), ErrOperationFailed)
+		}
+		// Location style operations may move the monitor while running
+		if resp.StatusCode == http.StatusAccepted && body == nil {
+			if next, _ := asyncLocation(resp, "Location"); next != nil {
+				poller.monitor = next
+			}
+		}
+	}
+}
+
+// asyncPoller tracks the URLs of an operation started by AsyncOperation
+type asyncPoller struct {
+	client  *Client
+	config  AsyncConfig
+	method  string   // Method of the initial request
+	request *url.URL // URL of the initial request
+	monitor *url.URL // URL polled for the status of the operation
+	result  *url.URL // Location header of the initial response, if any
+}
+
+// asyncLocation returns the first of the headers of resp set, resolved against the URL of the request of resp
+func asyncLocation(resp *Response, headers ...string) (*url.URL, error) {
+	for _, header := range headers {
+		value := resp.Header().Get(header)
+		if value == "" {
+			continue
+		}
+		location, err := resp.httpResponse.Request.URL.Parse(value)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid %s header", header)
+		}
+		return location, nil
+	}
+	return nil, nil
+}
+
+// wait sleeps for the delay requested by the Retry-After header of resp, or else the poll interval
+func (p *asyncPoller) wait(ctx context.Context, resp *Response) error {
+	delay := p.config.PollInterval
+	if retryAfter, ok := ParseRetryAfter(resp.Header().Get("Retry-After"), time.Now()); ok {
+		delay = min(retryAfter, p.config.MaxPollInterval)
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return tagError(errors.Wrap(ctx.Err(), "async operation did not complete in time"), ErrTimeout)
+		}
+		return errors.Wrap(ctx.Err(), "async operation polling canceled")
+	}
+}
+
+// asyncResult returns the result of an operation which succeeded with the poll response resp
+func asyncResult[T any](p *asyncPoller, resp *Response, body map[string]any) (*Response, error) {
+	target := p.result
+	if target != nil && target.String() == p.monitor.String() {
+		target = nil
+	}
+	if location, ok := body["resourceLocation"].(string); ok {
+		resourceLocation, err := resp.httpResponse.Request.URL.Parse(location)
+		if err != nil {
+			return resp, errors.Wrap(err, "invalid resourceLocation field")
+		}
+		return p.client.Execute(*NewRequest(http.MethodGet, p.requestOptions(resourceLocation)...), *(new(T)))
+	}
+	raw := resp.RawBody
+	switch {
+	case body["response"] != nil:
+		raw, _ = json.Marshal(body["response"])
+	case body["status"] == nil && body["done"] == nil:
+		// The poll body is the resource itself
+	case p.method == http.MethodPut || p.method == http.MethodPatch:
+		return p.client.Execute(*NewRequest(http.MethodGet, p.requestOptions(p.request)...), *(new(T)))
+	case target != nil:
+		return p.client.Execute(*NewRequest(http.MethodGet, p.requestOptions(target)...), *(new(T)))
+	}
+	return resp, decodeResponseBody(resp, raw, *(new(T)), &p.client.config)
+}
+
+// requestOptions returns the options of a GET request to target
+func (p *asyncPoller) requestOptions(target *url.URL) []RequestOption {
+	base := *target
+	base.RawQuery, base.Fragment = "", ""
+	opts := []RequestOption{WithBaseURL(base.String())}
+	if target.RawQuery != "" {
+		query, _ := url.ParseQuery(target.RawQuery)
+		opts = append(opts, WithQueryParams(query))
+	}
+	return append(opts, p.config.PollOptions...)
+}
+
+// asyncBody returns the JSON object body of a poll response, or nil
+func asyncBody(raw []byte) map[string]any {
+	var body map[string]any
+	if json.Unmarshal(raw, &body) != nil {
+		return nil
+	}
+	return body
+}
+
+// asyncPercent returns the completion percentage reported in body, or -1
+func asyncPercent(body map[string]any) float64 {
+	for _, field := range []string{"percentComplete", "progress"} {
+		if percent, ok := body[field].(float64); ok {
+			return percent
+		}
+	}
+	return -1
+}
+
+// asyncErrorDetail returns the message of the error object of a failed operation body, if any
+func asyncErrorDetail(body map[string]any) string {
+	switch detail := body["error"].(type) {
+	case string:
+		return ": " + detail
+	case map[string]any:
+		if message, ok := detail["message"].(string); ok {
+			return ": " + message
+		}
+		data, _ := json.Marshal(detail)
+		return ": " + string(data)
+	}
+	return ""
+}
@@ -0,0 +1,204 @@
+package httpx_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bdpiprava/easy-http/pkg/httpx"
+)
+
+func TestAsyncOperation(t *testing.T) {
+	type widget struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	}
+	newClient := func(t *testing.T, handler http.HandlerFunc) *httpx.Client {
+		t.Helper()
+		server := httptest.NewServer(handler)
+		t.Cleanup(server.Close)
+		return httpx.NewClientWithConfig(httpx.WithClientDefaultBaseURL(server.URL))
+	}
+
+	t.Run("should poll the operation location and fetch the result from location", func(t *testing.T) {
+		var polls atomic.Int32
+		client := newClient(t, func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/widgets":
+				w.Header().Set("Operation-Location", "/operations/1?api-version=2024")
+				w.Header().Set("Location", "/widgets/1")
+				w.Header().Set("Retry-After", "0")
+				w.WriteHeader(http.StatusAccepted)
+			case "/operations/1":
+				assert.Equal(t, "2024", r.URL.Query().Get("api-version"))
+				assert.Equal(t, "secret", r.Header.Get("Authorization"))
+				if polls.Add(1) < 2 {
+					_, _ = w.Write([]byte(`{"status":"Running","percentComplete":50}`))
+					return
+				}
+				_, _ = w.Write([]byte(`{"status":"Succeeded"}`))
+			case "/widgets/1":
+				_, _ = w.Write([]byte(`{"id":"1","name":"gear"}`))
+			}
+		})
+		var progress []httpx.AsyncProgress
+
+		resp, err := httpx.AsyncOperation[widget](client, *httpx.NewRequest(http.MethodPost, httpx.WithPath("/widgets")),
+			httpx.WithAsyncPollInterval(time.Millisecond),
+			httpx.WithAsyncPollOptions(httpx.WithHeader("Authorization", "secret")),
+			httpx.WithAsyncProgress(func(p httpx.AsyncProgress) { progress = append(progress, p) }),
+		)
+
+		require.NoError(t, err)
+		assert.Equal(t, widget{ID: "1", Name: "gear"}, resp.Body)
+		require.Len(t, progress, 2)
+		assert.Equal(t, httpx.AsyncStatusRunning, progress[0].Status)
+		assert.InDelta(t, 50, progress[0].Percent, 0)
+		assert.Equal(t, httpx.AsyncStatusSucceeded, progress[1].Status)
+		assert.InDelta(t, -1, progress[1].Percent, 0)
+		assert.Equal(t, 2, progress[1].Polls)
+	})
+
+	t.Run("should poll location until it returns the resource", func(t *testing.T) {
+		var polls atomic.Int32
+		client := newClient(t, func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/jobs" {
+				w.Header().Set("Location", "/jobs/1")
+				w.WriteHeader(http.StatusAccepted)
+				return
+			}
+			if polls.Add(1) < 3 {
+				w.WriteHeader(http.StatusAccepted)
+				return
+			}
+			_, _ = w.Write([]byte(`{"id":"1","name":"done"}`))
+		})
+
+		resp, err := httpx.AsyncOperation[widget](client, *httpx.NewRequest(http.MethodPost, httpx.WithPath("/jobs")),
+			httpx.WithAsyncPollInterval(time.Millisecond))
+
+		require.NoError(t, err)
+		assert.Equal(t, widget{ID: "1", Name: "done"}, resp.Body)
+		assert.Equal(t, int32(3), polls.Load())
+	})
+
+	t.Run("should decode the response field of google style operations", func(t *testing.T) {
+		client := newClient(t, func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/widgets" {
+				w.Header().Set("Location", "/operations/1")
+				w.WriteHeader(http.StatusAccepted)
+				return
+			}
+			_, _ = w.Write([]byte(`{"name":"operations/1","done":true,"response":{"id":"2","name":"cog"}}`))
+		})
+
+		resp, err := httpx.AsyncOperation[widget](client, *httpx.NewRequest(http.MethodPost, httpx.WithPath("/widgets")),
+			httpx.WithAsyncPollInterval(time.Millisecond))
+
+		require.NoError(t, err)
+		assert.Equal(t, widget{ID: "2", Name: "cog"}, resp.Body)
+	})
+
+	t.Run("should get the resource after a PUT operation succeeds", func(t *testing.T) {
+		client := newClient(t, func(w http.ResponseWriter, r *http.Request) {
+			switch {
+			case r.Method == http.MethodPut:
+				w.Header().Set("Azure-AsyncOperation", "/operations/1")
+				w.WriteHeader(http.StatusAccepted)
+			case r.URL.Path == "/operations/1":
+				_, _ = w.Write([]byte(`{"status":"Succeeded"}`))
+			default:
+				_, _ = w.Write([]byte(`{"id":"3","name":"put"}`))
+			}
+		})
+
+		resp, err := httpx.AsyncOperation[widget](client, *httpx.NewRequest(http.MethodPut, httpx.WithPath("/widgets", "3")),
+			httpx.WithAsyncPollInterval(time.Millisecond))
+
+		require.NoError(t, err)
+		assert.Equal(t, widget{ID: "3", Name: "put"}, resp.Body)
+	})
+
+	t.Run("should decode responses of operations completing synchronously", func(t *testing.T) {
+		client := newClient(t, func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write([]byte(`{"id":"4","name":"sync"}`))
+		})
+
+		resp, err := httpx.AsyncOperation[widget](client, *httpx.NewRequest(http.MethodPost))
+
+		require.NoError(t, err)
+		assert.Equal(t, widget{ID: "4", Name: "sync"}, resp.Body)
+	})
+
+	t.Run("should return an operation failed error with the error message", func(t *testing.T) {
+		client := newClient(t, func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/widgets" {
+				w.Header().Set("Operation-Location", "/operations/1")
+				w.WriteHeader(http.StatusAccepted)
+				return
+			}
+			_, _ = w.Write([]byte(`{"status":"Failed","error":{"code":"Quota","message":"quota exceeded"}}`))
+		})
+
+		resp, err := httpx.AsyncOperation[widget](client, *httpx.NewRequest(http.MethodPost, httpx.WithPath("/widgets")),
+			httpx.WithAsyncPollInterval(time.Millisecond))
+
+		require.ErrorIs(t, err, httpx.ErrOperationFailed)
+		assert.ErrorContains(t, err, "async operation failed: quota exceeded")
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	})
+
+	t.Run("should use a custom status mapper", func(t *testing.T) {
+		client := newClient(t, func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/widgets" {
+				w.Header().Set("Operation-Location", "/operations/1")
+				w.WriteHeader(http.StatusAccepted)
+				return
+			}
+			_, _ = w.Write([]byte(`{"state":"ABORTED"}`))
+		})
+
+		_, err := httpx.AsyncOperation[widget](client, *httpx.NewRequest(http.MethodPost, httpx.WithPath("/widgets")),
+			httpx.WithAsyncPollInterval(time.Millisecond),
+			httpx.WithAsyncStatusMapper(func(_ int, body map[string]any) httpx.AsyncStatus {
+				if body["state"] == "ABORTED" {
+					return httpx.AsyncStatusCanceled
+				}
+				return httpx.AsyncStatusRunning
+			}))
+
+		require.ErrorIs(t, err, httpx.ErrOperationFailed)
+		assert.ErrorContains(t, err, "async operation canceled")
+	})
+
+	t.Run("should time out operations that never complete", func(t *testing.T) {
+		client := newClient(t, func(w http.ResponseWriter, _ *http.Request) {
+			w.Header().Set("Operation-Location", "/operations/1")
+			w.WriteHeader(http.StatusAccepted)
+			_, _ = w.Write([]byte(`{"status":"Running"}`))
+		})
+
+		_, err := httpx.AsyncOperation[widget](client, *httpx.NewRequest(http.MethodPost),
+			httpx.WithAsyncPollInterval(5*time.Millisecond), httpx.WithAsyncTimeout(50*time.Millisecond))
+
+		require.ErrorIs(t, err, httpx.ErrTimeout)
+	})
+
+	t.Run("should fail accepted responses without a monitor location", func(t *testing.T) {
+		client := newClient(t, func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusAccepted)
+		})
+
+		_, err := httpx.AsyncOperation[widget](client, *httpx.NewRequest(http.MethodPost))
+
+		require.Error(t, err)
+		assert.False(t, errors.Is(err, httpx.ErrOperationFailed))
+	})
+}
@@ -0,0 +1,38 @@
+package httpx
+
+import (
+	"net/http"
+	"slices"
+)
+
+// AWSHTTPClient adapts the client to the HTTPClient interface of the AWS SDK for Go v2, so AWS calls
+// share the metrics, tracing, logging and circuit breaking of other outbound requests:
+//
+//	cfg, err := config.LoadDefaultConfig(ctx, config.WithHTTPClient(client.AWSHTTPClient()))
+//
+// The SDK signs and retries requests itself, so the client retry middlewares are removed to avoid
+// multiplying attempts; configure retries with the SDK retryer instead.
+type AWSHTTPClient struct {
+	client *http.Client
+}
+
+// AWSHTTPClient returns an adapter implementing the aws.HTTPClient interface of the AWS SDK for Go v2
+func (c Client) AWSHTTPClient() *AWSHTTPClient {
+	c.config.Middlewares = slices.DeleteFunc(slices.Clone(c.config.Middlewares), isRetryMiddleware)
+	return &AWSHTTPClient{client: c.StandardClient()}
+}
+
+// Do sends the request through the client middleware chain
+func (a *AWSHTTPClient) Do(req *http.Request) (*http.Response, error) {
+	return a.client.Do(req)
+}
+
+// isRetryMiddleware reports whether the middleware retries requests
+func isRetryMiddleware(middleware Middleware) bool {
+	switch middleware.(type) {
+	case *AdvancedRetryMiddleware, *RetryMiddleware:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,63 @@
+package httpx_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bdpiprava/easy-http/pkg/httpx"
+)
+
+// awsHTTPClient mirrors the aws.HTTPClient interface of the AWS SDK for Go v2
+type awsHTTPClient interface {
+	Do(*http.Request) (*http.Response, error)
+}
+
+func TestAWSHTTPClient(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Header().Set("X-Seen", r.Header.Get("X-Injected"))
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	policy := httpx.DefaultRetryPolicy()
+	policy.BaseDelay = time.Millisecond
+	client := httpx.NewClientWithConfig(
+		httpx.WithClientRetryPolicy(policy),
+		httpx.WithClientMiddleware(headerMiddleware{key: "X-Injected", value: "yes"}),
+	)
+
+	var adapter awsHTTPClient = client.AWSHTTPClient()
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, server.URL, nil)
+	require.NoError(t, err)
+
+	resp, err := adapter.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	t.Run("leaves retries to the SDK", func(t *testing.T) {
+		assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+		assert.Equal(t, int32(1), calls.Load())
+	})
+
+	t.Run("runs the other middlewares", func(t *testing.T) {
+		assert.Equal(t, "yes", resp.Header.Get("X-Seen"))
+	})
+
+	t.Run("keeps retries for the client itself", func(t *testing.T) {
+		calls.Store(0)
+
+		_, err := client.Execute(*httpx.NewRequest(http.MethodGet, httpx.WithBaseURL(server.URL)), map[string]any{})
+
+		require.NoError(t, err)
+		assert.Equal(t, int32(policy.MaxAttempts), calls.Load())
+	})
+}
@@ -0,0 +1,115 @@
+package httpx
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// bandwidthLimiter paces the bytes sent and received on the connections of a client, one token bucket per
+// direction shared by every connection
+type bandwidthLimiter struct {
+	egress  *byteBucket
+	ingress *byteBucket
+}
+
+// newBandwidthLimiter returns a limiter of the client bandwidth, or nil if it is unlimited
+func newBandwidthLimiter(config ClientConfig) *bandwidthLimiter {
+	if config.BandwidthLimit <= 0 {
+		return nil
+	}
+	burst := config.BandwidthBurst
+	if burst <= 0 {
+		burst = config.BandwidthLimit
+	}
+	clock := clockOrSystem(config.Clock)
+	return &bandwidthLimiter{
+		egress:  newByteBucket(clock, config.BandwidthLimit, burst),
+		ingress: newByteBucket(clock, config.BandwidthLimit, burst),
+	}
+}
+
+// configureBandwidthLimit wraps the transport dialer so connections are paced by limiter
+func configureBandwidthLimit(transport *http.Transport, limiter *bandwidthLimiter) {
+	dial := transport.DialContext
+	if dial == nil {
+		dial = (&net.Dialer{}).DialContext
+	}
+	transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := dial(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		return &throttledConn{Conn: conn, limiter: limiter}, nil
+	}
+}
+
+// throttledConn is a connection whose reads and writes are paced by a bandwidth limiter
+type throttledConn struct {
+	net.Conn
+	limiter *bandwidthLimiter
+}
+
+// Read implements net.Conn, reading at most a burst at once and waiting until the bytes read are paid for
+func (c *throttledConn) Read(p []byte) (int, error) {
+	if int64(len(p)) > c.limiter.ingress.burst {
+		p = p[:c.limiter.ingress.burst]
+	}
+	n, err := c.Conn.Read(p)
+	c.limiter.ingress.wait(int64(n))
+	return n, err
+}
+
+// Write implements net.Conn, writing bursts once they are paid for
+func (c *throttledConn) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		chunk := p[:min(int64(len(p)), c.limiter.egress.burst)]
+		c.limiter.egress.wait(int64(len(chunk)))
+		n, err := c.Conn.Write(chunk)
+		written += n
+		if err != nil {
+			return written, err
+		}
+		p = p[n:]
+	}
+	return written, nil
+}
+
+// byteBucket is a token bucket of bytes, whose balance goes negative when bytes are taken on credit so
+// concurrent connections queue for their share instead of polling
+type byteBucket struct {
+	clock Clock
+	rate  float64 // Bytes per second
+	burst int64
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+// newByteBucket returns a full bucket refilled with rate bytes per second up to burst
+func newByteBucket(clock Clock, rate, burst int64) *byteBucket {
+	return &byteBucket{clock: clock, rate: float64(rate), burst: burst, tokens: float64(burst), last: clock.Now()}
+}
+
+// wait takes n bytes from the bucket, sleeping until they are available
+func (b *byteBucket) wait(n int64) {
+	if n <= 0 {
+		return
+	}
+	b.mu.Lock()
+	now := b.clock.Now()
+	b.tokens = min(b.tokens+now.Sub(b.last).Seconds()*b.rate, float64(b.burst))
+	b.last = now
+	b.tokens -= float64(n)
+	deficit := -b.tokens
+	b.mu.Unlock()
+
+	if deficit > 0 {
+		timer := b.clock.NewTimer(time.Duration(deficit / b.rate * float64(time.Second)))
+		<-timer.C()
+	}
+}
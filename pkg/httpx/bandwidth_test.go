@@ -0,0 +1,65 @@
+package httpx_test
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bdpiprava/easy-http/pkg/httpx"
+)
+
+func TestClientBandwidthLimit(t *testing.T) {
+	const size = 64 << 10
+	payload := bytes.Repeat([]byte("x"), size)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method == http.MethodGet {
+			_, _ = fmt.Fprintf(w, `{"data":%q}`, payload)
+			return
+		}
+		_, _ = fmt.Fprintf(w, `{"received":%d}`, len(received))
+	}))
+	defer server.Close()
+
+	t.Run("paces downloads", func(t *testing.T) {
+		client := httpx.NewClientWithConfig(httpx.WithClientDefaultBaseURL(server.URL), httpx.WithClientBandwidthLimit(128<<10, 16<<10))
+
+		start := time.Now()
+		resp, err := client.Execute(*httpx.NewRequest(http.MethodGet), nil)
+		require.NoError(t, err)
+
+		assert.Greater(t, len(resp.RawBody), size)
+		// 48KiB beyond the burst at 128KiB/s
+		assert.GreaterOrEqual(t, time.Since(start), 300*time.Millisecond)
+	})
+
+	t.Run("paces uploads", func(t *testing.T) {
+		client := httpx.NewClientWithConfig(httpx.WithClientDefaultBaseURL(server.URL), httpx.WithClientBandwidthLimit(128<<10, 16<<10))
+
+		start := time.Now()
+		resp, err := client.Execute(*httpx.NewRequest(http.MethodPost, httpx.WithBody(bytes.NewReader(payload))), nil)
+		require.NoError(t, err)
+
+		assert.JSONEq(t, fmt.Sprintf(`{"received":%d}`, size), string(resp.RawBody))
+		assert.GreaterOrEqual(t, time.Since(start), 300*time.Millisecond)
+	})
+
+	t.Run("does not pace without a limit", func(t *testing.T) {
+		client := httpx.NewClientWithConfig(httpx.WithClientDefaultBaseURL(server.URL))
+
+		start := time.Now()
+		resp, err := client.Execute(*httpx.NewRequest(http.MethodGet), nil)
+		require.NoError(t, err)
+
+		assert.Greater(t, len(resp.RawBody), size)
+		assert.Less(t, time.Since(start), 300*time.Millisecond)
+	})
+}
@@ -0,0 +1,152 @@
+package httpx
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// BulkheadConfig configures a bulkhead, see WithClientBulkhead
+type BulkheadConfig struct {
+	MaxConcurrent   int           // Requests in flight at once (default: 10)
+	PerHost         bool          // Apply the limit per host vs globally
+	MaxQueue        int           // Requests waiting for a slot, beyond which they fail fast (default: 0, none wait)
+	MaxWaitDuration time.Duration // Maximum time a request waits for a slot (default: 30s)
+}
+
+// BulkheadStats holds the state of a bulkhead, summed over hosts in per-host mode
+type BulkheadStats struct {
+	Inflight int   // Requests in flight
+	Queued   int   // Requests waiting for a slot
+	Rejected int64 // Requests rejected because the queue was full or the wait timed out since the client was created
+}
+
+// BulkheadMiddleware caps the requests in flight with a fixed limit, so a burst of concurrent calls cannot
+// exhaust a downstream, or the connections and goroutines of the client when it stalls
+//
+// Unlike rate limiting, it bounds how many requests run at once rather than how many start per second, and
+// unlike the adaptive concurrency limiter the limit does not follow latency. Requests over the limit wait in a
+// bounded queue, or fail at once with an error matching ErrConcurrencyLimited when the queue is full.
+type BulkheadMiddleware struct {
+	config BulkheadConfig
+
+	mu          sync.Mutex
+	compartment map[string]*bulkheadCompartment // By host in per-host mode, else under ""
+	rejected    int64
+}
+
+// bulkheadCompartment holds the slots of a host, or of all hosts
+type bulkheadCompartment struct {
+	slots  chan struct{} // Holds a value per request in flight
+	queued int           // Guarded by the lock of the middleware
+}
+
+// NewBulkheadMiddleware creates a bulkhead middleware
+func NewBulkheadMiddleware(config BulkheadConfig) *BulkheadMiddleware {
+	if config.MaxConcurrent <= 0 {
+		config.MaxConcurrent = 10
+	}
+	if config.MaxQueue < 0 {
+		config.MaxQueue = 0
+	}
+	if config.MaxWaitDuration <= 0 {
+		config.MaxWaitDuration = 30 * time.Second
+	}
+	return &BulkheadMiddleware{
+		config:      config,
+		compartment: make(map[string]*bulkheadCompartment),
+	}
+}
+
+// Name returns the middleware name
+func (m *BulkheadMiddleware) Name() string {
+	return "bulkhead"
+}
+
+// Execute implements the Middleware interface
+func (m *BulkheadMiddleware) Execute(ctx context.Context, req *http.Request, next MiddlewareFunc) (*http.Response, error) {
+	compartment := m.compartmentFor(req)
+	if err := m.acquire(ctx, compartment); err != nil {
+		if ctx.Err() != nil {
+			return nil, err
+		}
+		return nil, &HTTPError{
+			Op:      requestOperation(req),
+			Type:    ErrorTypeMiddleware,
+			Message: fmt.Sprintf("bulkhead of %d requests full", m.config.MaxConcurrent),
+			Cause:   tagError(err, ErrConcurrencyLimited),
+			Request: req,
+		}
+	}
+	defer func() { <-compartment.slots }()
+	return next(ctx, req)
+}
+
+// Stats returns the requests in flight, queued and rejected
+func (m *BulkheadMiddleware) Stats() BulkheadStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stats := BulkheadStats{Rejected: m.rejected}
+	for _, compartment := range m.compartment {
+		stats.Inflight += len(compartment.slots)
+		stats.Queued += compartment.queued
+	}
+	return stats
+}
+
+// compartmentFor returns the compartment the request takes a slot of
+func (m *BulkheadMiddleware) compartmentFor(req *http.Request) *bulkheadCompartment {
+	var key string
+	if m.config.PerHost {
+		key = req.URL.Host
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	compartment, ok := m.compartment[key]
+	if !ok {
+		compartment = &bulkheadCompartment{slots: make(chan struct{}, m.config.MaxConcurrent)}
+		m.compartment[key] = compartment
+	}
+	return compartment
+}
+
+// acquire takes a slot of the compartment, waiting in its queue if there is room
+func (m *BulkheadMiddleware) acquire(ctx context.Context, compartment *bulkheadCompartment) error {
+	select {
+	case compartment.slots <- struct{}{}:
+		return nil
+	default:
+	}
+
+	m.mu.Lock()
+	if compartment.queued >= m.config.MaxQueue {
+		m.rejected++
+		m.mu.Unlock()
+		return errors.New("no request slot available and the queue is full")
+	}
+	compartment.queued++
+	m.mu.Unlock()
+	defer func() {
+		m.mu.Lock()
+		compartment.queued--
+		m.mu.Unlock()
+	}()
+
+	timer := time.NewTimer(m.config.MaxWaitDuration)
+	defer timer.Stop()
+	select {
+	case compartment.slots <- struct{}{}:
+		return nil
+	case <-timer.C:
+		m.mu.Lock()
+		m.rejected++
+		m.mu.Unlock()
+		return errors.Errorf("bulkhead wait timeout exceeded: %v", m.config.MaxWaitDuration)
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,107 @@
+package httpx_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bdpiprava/easy-http/pkg/httpx"
+)
+
+func TestBulkhead(t *testing.T) {
+	// blockingServer answers once release is closed, reporting each request on started
+	blockingServer := func(t *testing.T) (*httptest.Server, chan struct{}, chan struct{}) {
+		started, release := make(chan struct{}, 10), make(chan struct{})
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			started <- struct{}{}
+			<-release
+			w.WriteHeader(http.StatusOK)
+		}))
+		t.Cleanup(server.Close)
+		return server, started, release
+	}
+	get := func(client *httpx.Client, baseURL string) error {
+		_, err := client.Execute(*httpx.NewRequest(http.MethodGet, httpx.WithBaseURL(baseURL)), nil)
+		return err
+	}
+	// fill starts n requests held by the server, returning a function waiting for them to complete
+	fill := func(t *testing.T, client *httpx.Client, server *httptest.Server, started chan struct{}, n int) func() {
+		var wg sync.WaitGroup
+		for range n {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				assert.NoError(t, get(client, server.URL))
+			}()
+			<-started
+		}
+		return wg.Wait
+	}
+
+	t.Run("fails fast over the limit", func(t *testing.T) {
+		server, started, release := blockingServer(t)
+		client := httpx.NewClientWithConfig(httpx.WithClientBulkhead(httpx.BulkheadConfig{MaxConcurrent: 2}))
+		wait := fill(t, client, server, started, 2)
+		assert.Equal(t, httpx.BulkheadStats{Inflight: 2}, client.Bulkhead().Stats())
+
+		err := get(client, server.URL)
+
+		assert.True(t, errors.Is(err, httpx.ErrConcurrencyLimited))
+		close(release)
+		wait()
+		assert.Equal(t, httpx.BulkheadStats{Rejected: 1}, client.Bulkhead().Stats())
+	})
+
+	t.Run("queues requests for a slot", func(t *testing.T) {
+		server, started, release := blockingServer(t)
+		client := httpx.NewClientWithConfig(httpx.WithClientBulkhead(httpx.BulkheadConfig{MaxConcurrent: 1, MaxQueue: 1}))
+		wait := fill(t, client, server, started, 1)
+
+		queued := make(chan error, 1)
+		go func() { queued <- get(client, server.URL) }()
+		require.Eventually(t, func() bool { return client.Bulkhead().Stats().Queued == 1 }, time.Second, time.Millisecond)
+		assert.True(t, errors.Is(get(client, server.URL), httpx.ErrConcurrencyLimited), "the queue is full")
+
+		close(release)
+		assert.NoError(t, <-queued)
+		wait()
+	})
+
+	t.Run("times out queued requests", func(t *testing.T) {
+		server, started, release := blockingServer(t)
+		client := httpx.NewClientWithConfig(httpx.WithClientBulkhead(httpx.BulkheadConfig{
+			MaxConcurrent: 1, MaxQueue: 1, MaxWaitDuration: 10 * time.Millisecond,
+		}))
+		wait := fill(t, client, server, started, 1)
+
+		err := get(client, server.URL)
+
+		assert.True(t, errors.Is(err, httpx.ErrConcurrencyLimited))
+		close(release)
+		wait()
+	})
+
+	t.Run("limits each host separately", func(t *testing.T) {
+		server, started, release := blockingServer(t)
+		client := httpx.NewClientWithConfig(httpx.WithClientBulkhead(httpx.BulkheadConfig{MaxConcurrent: 1, PerHost: true}))
+		wait := fill(t, client, server, started, 1)
+
+		// The same server under another host name has slots of its own
+		other := strings.Replace(server.URL, "127.0.0.1", "localhost", 1)
+		done := make(chan error, 1)
+		go func() { done <- get(client, other) }()
+		<-started
+		assert.True(t, errors.Is(get(client, server.URL), httpx.ErrConcurrencyLimited))
+
+		close(release)
+		assert.NoError(t, <-done)
+		wait()
+	})
+}
@@ -2,16 +2,28 @@ package httpx
 
 import (
 	"bytes"
+	"container/list"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
+	"path"
+	"slices"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
+
+	"github.com/pkg/errors"
 )
 
 // CacheBackend defines the interface for cache storage
+// NewInMemoryCache keeps entries in the process, NewDiskCache across restarts; the rediscache subpackage shares
+// them between instances.
 type CacheBackend interface {
 	Get(key string) (*CachedResponse, bool)
 	Set(key string, response *CachedResponse) error
@@ -20,6 +32,12 @@ type CacheBackend interface {
 	Stats() CacheStats
 }
 
+// CacheMatchDeleter is implemented by backends able to delete the entries whose keys match a predicate, as
+// CacheMiddleware.InvalidatePrefix requires
+type CacheMatchDeleter interface {
+	DeleteMatching(match func(key string) bool) (int, error)
+}
+
 // CachedResponse represents a cached HTTP response
 type CachedResponse struct {
 	StatusCode   int
@@ -29,91 +47,303 @@ type CachedResponse struct {
 	ExpiresAt    time.Time
 	ETag         string
 	LastModified string
+
+	// Windows after ExpiresAt during which the response may still be served, see RFC 5861
+	StaleWhileRevalidate time.Duration // Served while it is revalidated in the background, with RevalidateAsync
+	StaleIfError         time.Duration // Served when the origin fails, see MaxStaleOnError
+}
+
+// RetainUntil returns when the response can no longer be served, even stale
+// Backends keep entries until then rather than until ExpiresAt.
+func (r *CachedResponse) RetainUntil() time.Time {
+	return r.ExpiresAt.Add(max(r.StaleWhileRevalidate, r.StaleIfError))
 }
 
 // CacheConfig configures the caching middleware
+//
+// CacheableMethods defaults to GET and HEAD. HEAD requests are answered from a fresh stored GET
+// response of the same URL, as permitted by RFC 9111, so a HEAD issued before a download does not
+// reach the origin. Adding OPTIONS caches CORS preflight results per Origin and requested method and
+// headers, for Access-Control-Max-Age when set.
+//
+// Entries are partitioned by PartitionFunc so a response cached for one user is never served to, or
+// revalidated on behalf of, another user requesting the same URL. Responses with a Vary header are stored per
+// variant, keyed by the values of the request headers it names, so a response negotiated for one Accept or
+// Accept-Encoding header is never served to a request sending another; responses with "Vary: *" are not stored.
+//
+// The cache follows the RFC 9111 rules of shared caches unless PrivateCache is set: responses marked private
+// are not stored, s-maxage takes precedence over max-age, and responses to requests carrying an Authorization
+// header are only stored when the response explicitly permits it with public, s-maxage or must-revalidate.
 type CacheConfig struct {
-	Backend          CacheBackend
-	MaxEntries       int
-	MaxSizeBytes     int64
-	DefaultTTL       time.Duration
-	CacheableMethods []string
-	SkipCacheFor     func(*http.Request) bool
+	Backend           CacheBackend
+	MaxEntries        int   // Maximum number of entries of the default in-memory backend
+	MaxSizeBytes      int64 // Maximum estimated size of the default in-memory backend, unbounded when zero
+	MaxEntrySizeBytes int64 // Maximum size of a cached response, MaxSizeBytes when zero; larger responses are not cached
+	DefaultTTL        time.Duration
+	CacheableMethods  []string
+	SkipCacheFor      func(*http.Request) bool
+
+	// KeyFunc returns the key identifying the resource requested, e.g. to ignore query parameters that do not
+	// change the response, and defaults to DefaultCacheKey; WithCacheKey sets the key of a single request. Keys
+	// are still scoped by PartitionFunc and Vary.
+	// InvalidatePrefix only finds entries whose keys start with the method and URL like the default ones.
+	KeyFunc func(*http.Request) string
+
+	// TTLRules set the TTL of the responses to matching paths, the first matching rule applying, e.g. to cache
+	// the responses of legacy services sending no caching headers for longer than DefaultTTL
+	TTLRules []CacheTTLRule
+
+	// RefreshAhead serves fresh GET entries from the cache and refreshes an entry in the background once this
+	// fraction of its lifetime has elapsed, e.g. 0.8 refreshes a 5 minute entry after 4 minutes, so popular
+	// entries stay warm instead of expiring under load. Only one refresh per entry runs at a time.
+	// Zero disables refresh-ahead, and GET requests are then revalidated with the origin on every call.
+	RefreshAhead float64
+
+	// CoalesceRequests lets a single GET request per entry go to the origin at a time, so a popular entry that
+	// expires is fetched once rather than by every caller. Concurrent requests for the entry wait for it and are
+	// answered from the response it stored; they fetch the origin themselves when it was not cacheable, and
+	// give up with their context's error if it ends first.
+	CoalesceRequests bool
+
+	// PartitionFunc returns the cache partition of a request, e.g. a tenant or user ID
+	// Requests in different partitions never share entries; "" is the partition of anonymous requests.
+	// Defaults to AuthorizationPartition.
+	PartitionFunc func(*http.Request) string
+
+	// BaseContext scopes background refreshes, which are canceled once it is done, e.g. on application shutdown
+	// (default: the base context of the client sending the request). Refreshes otherwise outlive the request
+	// that triggered them until they complete.
+	BaseContext context.Context
+
+	// RevalidateAsync serves fresh GET entries from the cache, and expired ones during the stale-while-revalidate
+	// window of their response (RFC 5861) while they are revalidated in the background, so callers do not wait
+	// for the origin. Expired entries without the directive are revalidated before they are served.
+	RevalidateAsync bool
+
+	// MaxStaleOnError serves an expired entry for up to this long after it expired when revalidating it fails
+	// with a network error or a 5xx response, rather than failing the request; the stale-if-error directive of
+	// the response (RFC 5861) takes precedence. Zero serves stale entries only to responses carrying it.
+	MaxStaleOnError time.Duration
+
+	// StatusHeader names a response header set to the CacheStatus of every response, e.g. "X-Cache", to debug
+	// caching from logs or captured exchanges. Empty disables it.
+	StatusHeader string
+
+	// PrivateCache declares the cache private to a single user, e.g. a client acting for one account, so that
+	// responses marked private and responses to authenticated requests are stored like any other response
+	PrivateCache bool
+
+	// StatsGroup returns the statistics group of a request, e.g. CacheStatsByHost or CacheStatsByPathPrefix, so
+	// Stats breaks hits, misses and entry ages and lifetimes down by group to show which endpoints benefit from
+	// the cache. Groups must be few: there is one per distinct value, kept for the life of the middleware.
+	StatsGroup func(*http.Request) string
+
+	// Observer is notified of lookups and stores, grouped by StatsGroup, e.g. to export them to Prometheus
+	// (default: the client's with WithClientCache, set by WithClientPrometheusMetrics)
+	Observer CacheObserver
+
+	// WriteBehind stores responses in the backend in the background, so the latency of requests does not include
+	// serializing large bodies to a remote or disk backend. Responses waiting to be stored answer lookups as if
+	// stored, and a response replaces the one of the same variant still waiting, so concurrent writes of an entry
+	// reach the backend once. Responses are not cached while WriteQueueSize of them wait.
+	WriteBehind bool
+
+	// WriteQueueSize bounds the responses waiting to be stored with WriteBehind (default: 1000)
+	WriteQueueSize int
+}
+
+// CacheTTLRule sets the TTL of the responses to the paths matching PathPattern
+// Responses the origin forbids to store, e.g. with no-store, are never cached whatever the rules.
+type CacheTTLRule struct {
+	PathPattern string        // Pattern of the request path in the path.Match syntax, e.g. "/v1/catalog/*"
+	TTL         time.Duration // TTL of the responses, rules with a zero or negative TTL are ignored
+	Override    bool          // If true, TTL overrides the freshness sent by the origin, else it only replaces DefaultTTL
+}
+
+// DefaultCacheKey is the default CacheConfig.KeyFunc, the method and canonical URL of the request as returned by
+// CanonicalRequest.Key, e.g. "GET:https://api.example.com/users?a=1&b=2"
+func DefaultCacheKey(req *http.Request) string {
+	return CanonicalRequestOf(req).Key()
+}
+
+// RequestCacheOptions are the cache directives of a single request, set with WithNoCache, WithForceRevalidate,
+// WithCacheTTL and WithCacheKey
+type RequestCacheOptions struct {
+	NoCache         bool          // Neither answered from nor stored in the cache
+	ForceRevalidate bool          // Revalidated with the origin even when a fresh response is cached
+	TTL             time.Duration // TTL of the response stored, overriding the caching headers and TTL rules
+	Key             string        // Key identifying the response in place of the one returned by CacheConfig.KeyFunc
+}
+
+// merge returns the options with those set in other applied over them
+func (o RequestCacheOptions) merge(other RequestCacheOptions) RequestCacheOptions {
+	o.NoCache = o.NoCache || other.NoCache
+	o.ForceRevalidate = o.ForceRevalidate || other.ForceRevalidate
+	if other.TTL > 0 {
+		o.TTL = other.TTL
+	}
+	if other.Key != "" {
+		o.Key = other.Key
+	}
+	return o
+}
+
+// requestCacheOptionsKey is the context key of the RequestCacheOptions of a request
+type requestCacheOptionsKey struct{}
+
+// contextWithRequestCacheOptions returns a context carrying the cache directives of a request
+func contextWithRequestCacheOptions(ctx context.Context, options RequestCacheOptions) context.Context {
+	if options == (RequestCacheOptions{}) {
+		return ctx
+	}
+	return context.WithValue(ctx, requestCacheOptionsKey{}, options)
+}
+
+// requestCacheOptions returns the cache directives of req
+func requestCacheOptions(req *http.Request) RequestCacheOptions {
+	options, _ := req.Context().Value(requestCacheOptionsKey{}).(RequestCacheOptions)
+	return options
+}
+
+// AuthorizationPartition partitions the cache by a hash of the Authorization header
+// The whole credential is hashed rather than a claim such as the JWT subject, which the client cannot verify;
+// a caller presenting a forged token must not be able to select another user's partition.
+func AuthorizationPartition(req *http.Request) string {
+	authorization := req.Header.Get("Authorization")
+	if authorization == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(authorization))
+	return hex.EncodeToString(sum[:16])
 }
 
 // CacheStats tracks cache performance metrics
 type CacheStats struct {
-	Hits      int64
-	Misses    int64
-	Evictions int64
-	Size      int64
+	Hits          int64
+	Misses        int64
+	Evictions     int64
+	Size          int64 // Number of entries
+	Bytes         int64 // Estimated bytes held by the entries, see CachedResponse.Size
+	EvictedBytes  int64 // Bytes released by evictions, including expired entries
+	Rejected      int64 // Entries not stored because they exceed the per-entry size limit
+	DroppedWrites int64 // Responses not stored because the write-behind queue was full, see CacheConfig.WriteBehind
+
+	Groups map[string]CacheGroupStats // Lookups and stores of the middleware by CacheConfig.StatsGroup, if set
+}
+
+// cachedResponseOverhead approximates the memory held by a cache entry besides its body, headers and validators
+const cachedResponseOverhead = 256
+
+// Size returns the estimated memory footprint of the response in bytes, used to bound the in-memory cache
+func (r *CachedResponse) Size() int64 {
+	size := int64(cachedResponseOverhead + len(r.Body) + len(r.ETag) + len(r.LastModified))
+	for name, values := range r.Headers {
+		size += int64(len(name))
+		for _, value := range values {
+			size += int64(len(value))
+		}
+	}
+	return size
 }
 
-// InMemoryCache implements CacheBackend using an in-memory store
+// InMemoryCacheConfig bounds an InMemoryCache
+type InMemoryCacheConfig struct {
+	MaxEntries    int   // Maximum number of entries, 1000 when zero or negative
+	MaxBytes      int64 // Maximum total size of the entries, unbounded when zero or negative
+	MaxEntryBytes int64 // Maximum size of a single entry, MaxBytes when zero or negative
+}
+
+// InMemoryCache implements CacheBackend using an in-memory store with LRU eviction
 type InMemoryCache struct {
-	mu       sync.RWMutex
-	entries  map[string]*CachedResponse
-	stats    CacheStats
-	maxSize  int
-	lruOrder []string // Track access order for LRU eviction
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	lru     *list.List // Most recently used entries at the front
+	stats   CacheStats
+	config  InMemoryCacheConfig
 }
 
-// NewInMemoryCache creates a new in-memory cache
+// inMemoryEntry is an element of the LRU list
+type inMemoryEntry struct {
+	key      string
+	response *CachedResponse
+	size     int64
+}
+
+// NewInMemoryCache creates a new in-memory cache holding up to maxSize entries
 func NewInMemoryCache(maxSize int) *InMemoryCache {
-	if maxSize <= 0 {
-		maxSize = 1000 // Default size
+	return NewInMemoryCacheWithConfig(InMemoryCacheConfig{MaxEntries: maxSize})
+}
+
+// NewInMemoryCacheWithConfig creates a new in-memory cache bounded by entry count and estimated size in bytes
+// Least recently used entries are evicted until both bounds are met; entries larger than MaxEntryBytes are
+// rejected by Set with an error matching ErrTooLarge.
+func NewInMemoryCacheWithConfig(config InMemoryCacheConfig) *InMemoryCache {
+	if config.MaxEntries <= 0 {
+		config.MaxEntries = 1000 // Default size
+	}
+	if config.MaxEntryBytes <= 0 || (config.MaxBytes > 0 && config.MaxEntryBytes > config.MaxBytes) {
+		config.MaxEntryBytes = max(config.MaxBytes, 0)
 	}
 	return &InMemoryCache{
-		entries:  make(map[string]*CachedResponse),
-		maxSize:  maxSize,
-		lruOrder: make([]string, 0, maxSize),
+		entries: make(map[string]*list.Element),
+		lru:     list.New(),
+		config:  config,
 	}
 }
 
 // Get retrieves a cached response
 func (c *InMemoryCache) Get(key string) (*CachedResponse, bool) {
-	c.mu.RLock()
-	entry, exists := c.entries[key]
-	c.mu.RUnlock()
+	c.mu.Lock()
+	defer c.mu.Unlock()
 
+	element, exists := c.entries[key]
 	if !exists {
-		c.mu.Lock()
 		c.stats.Misses++
-		c.mu.Unlock()
 		return nil, false
 	}
 
-	// Check if expired
-	if time.Now().After(entry.ExpiresAt) {
-		c.mu.Lock()
-		delete(c.entries, key)
-		c.removeFromLRU(key)
+	// Check if expired, past the time it may be served stale
+	entry := element.Value.(*inMemoryEntry)
+	if time.Now().After(entry.response.RetainUntil()) {
+		c.evict(element)
 		c.stats.Misses++
-		c.stats.Evictions++
-		c.mu.Unlock()
 		return nil, false
 	}
 
-	c.mu.Lock()
 	c.stats.Hits++
-	c.updateLRU(key)
-	c.mu.Unlock()
-
-	return entry, true
+	c.lru.MoveToFront(element)
+	return entry.response, true
 }
 
-// Set stores a response in cache
+// Set stores a response in cache, evicting least recently used entries to stay within the configured bounds
 func (c *InMemoryCache) Set(key string, response *CachedResponse) error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	// Evict if at capacity
-	if len(c.entries) >= c.maxSize && c.entries[key] == nil {
-		c.evictOldest()
+	size := response.Size()
+	if c.config.MaxEntryBytes > 0 && size > c.config.MaxEntryBytes {
+		// The new response supersedes any stored one, which must not be served instead
+		if element, exists := c.entries[key]; exists {
+			c.remove(element)
+		}
+		c.stats.Rejected++
+		return tagError(errors.Errorf("cache entry of %d bytes exceeds the limit of %d bytes", size, c.config.MaxEntryBytes), ErrTooLarge)
+	}
+
+	if element, exists := c.entries[key]; exists {
+		entry := element.Value.(*inMemoryEntry)
+		c.stats.Bytes += size - entry.size
+		entry.response, entry.size = response, size
+		c.lru.MoveToFront(element)
+	} else {
+		c.entries[key] = c.lru.PushFront(&inMemoryEntry{key: key, response: response, size: size})
+		c.stats.Bytes += size
 	}
 
-	c.entries[key] = response
-	c.updateLRU(key)
+	// The new entry is at the front and fits on its own, so it is never evicted here
+	for len(c.entries) > c.config.MaxEntries || (c.config.MaxBytes > 0 && c.stats.Bytes > c.config.MaxBytes) {
+		c.evict(c.lru.Back())
+	}
 	c.stats.Size = int64(len(c.entries))
 	return nil
 }
@@ -123,9 +353,9 @@ func (c *InMemoryCache) Delete(key string) error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	delete(c.entries, key)
-	c.removeFromLRU(key)
-	c.stats.Size = int64(len(c.entries))
+	if element, exists := c.entries[key]; exists {
+		c.remove(element)
+	}
 	return nil
 }
 
@@ -134,58 +364,81 @@ func (c *InMemoryCache) Clear() error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	c.entries = make(map[string]*CachedResponse)
-	c.lruOrder = make([]string, 0, c.maxSize)
+	c.entries = make(map[string]*list.Element)
+	c.lru.Init()
 	c.stats.Size = 0
+	c.stats.Bytes = 0
 	return nil
 }
 
+// DeleteMatching removes the entries whose keys match, returning how many were removed
+func (c *InMemoryCache) DeleteMatching(match func(key string) bool) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	deleted := 0
+	for key, element := range c.entries {
+		if match(key) {
+			c.remove(element)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
 // Stats returns cache statistics
 func (c *InMemoryCache) Stats() CacheStats {
-	c.mu.RLock()
-	defer c.mu.RUnlock()
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	return c.stats
 }
 
-// evictOldest removes the least recently used entry
-func (c *InMemoryCache) evictOldest() {
-	if len(c.lruOrder) == 0 {
-		return
-	}
-
-	oldest := c.lruOrder[0]
-	delete(c.entries, oldest)
-	c.lruOrder = c.lruOrder[1:]
+// evict removes an entry to make room or because it expired, the caller holds c.mu
+func (c *InMemoryCache) evict(element *list.Element) {
 	c.stats.Evictions++
+	c.stats.EvictedBytes += element.Value.(*inMemoryEntry).size
+	c.remove(element)
 }
 
-// updateLRU moves a key to the end of the LRU list
-func (c *InMemoryCache) updateLRU(key string) {
-	// Remove from current position
-	c.removeFromLRU(key)
-	// Add to end
-	c.lruOrder = append(c.lruOrder, key)
-}
-
-// removeFromLRU removes a key from the LRU list
-func (c *InMemoryCache) removeFromLRU(key string) {
-	for i, k := range c.lruOrder {
-		if k == key {
-			c.lruOrder = append(c.lruOrder[:i], c.lruOrder[i+1:]...)
-			break
-		}
-	}
+// remove removes an entry, the caller holds c.mu
+func (c *InMemoryCache) remove(element *list.Element) {
+	entry := c.lru.Remove(element).(*inMemoryEntry)
+	delete(c.entries, entry.key)
+	c.stats.Bytes -= entry.size
+	c.stats.Size = int64(len(c.entries))
 }
 
 // CacheMiddleware implements HTTP caching
 type CacheMiddleware struct {
 	config CacheConfig
+
+	mu         sync.Mutex
+	refreshing map[string]struct{}     // Keys with a refresh-ahead in flight
+	flights    map[string]*cacheFlight // Keys being fetched with CoalesceRequests
+	groups     cacheStats              // Statistics by CacheConfig.StatsGroup
+	validators *validatorMap           // Validators of the responses received, see ValidatorStore
+	writer     cacheWriter             // Writes pending with CacheConfig.WriteBehind
+	hits       atomic.Int64            // Requests answered from the cache, counted once however many entries they read
+	misses     atomic.Int64            // Requests answered by the origin
+}
+
+// cacheFlight is a fetch from the origin that concurrent requests for the same key wait on
+type cacheFlight struct {
+	done   chan struct{}
+	stored bool // Whether the response was stored, set before done is closed
 }
 
 // NewCacheMiddleware creates a new cache middleware
 func NewCacheMiddleware(config CacheConfig) *CacheMiddleware {
 	if config.Backend == nil {
-		config.Backend = NewInMemoryCache(1000)
+		config.Backend = NewInMemoryCacheWithConfig(InMemoryCacheConfig{
+			MaxEntries:    config.MaxEntries,
+			MaxBytes:      config.MaxSizeBytes,
+			MaxEntryBytes: config.MaxEntrySizeBytes,
+		})
+	}
+	if config.MaxEntrySizeBytes <= 0 {
+		config.MaxEntrySizeBytes = max(config.MaxSizeBytes, 0)
 	}
 	if config.DefaultTTL == 0 {
 		config.DefaultTTL = 5 * time.Minute
@@ -193,7 +446,26 @@ func NewCacheMiddleware(config CacheConfig) *CacheMiddleware {
 	if len(config.CacheableMethods) == 0 {
 		config.CacheableMethods = []string{http.MethodGet, http.MethodHead}
 	}
-	return &CacheMiddleware{config: config}
+	if config.PartitionFunc == nil {
+		config.PartitionFunc = AuthorizationPartition
+	}
+	if config.KeyFunc == nil {
+		config.KeyFunc = DefaultCacheKey
+	}
+	if config.WriteQueueSize <= 0 {
+		config.WriteQueueSize = defaultCacheWriteQueue
+	}
+	return &CacheMiddleware{
+		config:     config,
+		refreshing: make(map[string]struct{}),
+		flights:    make(map[string]*cacheFlight),
+		groups:     make(cacheStats),
+		validators: newValidatorMap(config.MaxEntries),
+		writer: cacheWriter{
+			pending: make(map[string]*cacheWrite),
+			varying: make(map[string]*cacheWrite),
+		},
+	}
 }
 
 // Name returns the middleware name
@@ -201,17 +473,150 @@ func (m *CacheMiddleware) Name() string {
 	return "cache"
 }
 
+// Stats returns the statistics of the cache backend, with those of the middleware by CacheConfig.StatsGroup
+// Hits and misses are those of the requests of the middleware rather than of the backend, which counts every
+// entry read, e.g. both the index and the variant of a response with a Vary header.
+func (m *CacheMiddleware) Stats() CacheStats {
+	stats := m.config.Backend.Stats()
+	stats.Hits, stats.Misses = m.hits.Load(), m.misses.Load()
+	m.mu.Lock()
+	stats.Groups = m.groups.snapshot()
+	stats.DroppedWrites = m.writer.dropped
+	m.mu.Unlock()
+	return stats
+}
+
+// Validators returns the store of the validators of the responses received, seeding conditional requests for
+// URLs whose response is not cached
+func (m *CacheMiddleware) Validators() *ValidatorStore {
+	return &ValidatorStore{validators: m.validators}
+}
+
+// InvalidatePrefix removes the entries of every partition whose URL is prefix or lies under it, e.g. all pages
+// and items of a collection after it changed, returning how many were removed
+// Their validators are removed too, so the next requests fetch them in full.
+// The prefix is canonicalized with CanonicalURL and matched on path segment boundaries: "https://api.example.com/users"
+// covers /users?page=2 and /users/42, but not /users-archive. The backend must implement CacheMatchDeleter.
+func (m *CacheMiddleware) InvalidatePrefix(prefix string) (int, error) {
+	deleter, ok := m.config.Backend.(CacheMatchDeleter)
+	if !ok {
+		return 0, errors.Errorf("cache backend %T cannot delete entries by prefix", m.config.Backend)
+	}
+	u, err := url.Parse(prefix)
+	if err != nil {
+		return 0, ValidationError("invalid cache invalidation prefix", err)
+	}
+	canonical := CanonicalURL(u).String()
+	m.validators.deleteMatching(func(target string) bool {
+		return urlUnder(target, canonical)
+	})
+	m.discardWrites(func(key string) bool {
+		return cacheKeyUnder(key, canonical)
+	})
+	return deleter.DeleteMatching(func(key string) bool {
+		return cacheKeyUnder(key, canonical)
+	})
+}
+
+// cacheKeyUnder reports whether a cache key is for a URL equal to prefix or under it
+// Keys are a method and URL, e.g. "GET:https://api.example.com/users", optionally preceded by a partition and
+// followed by the preflight request details, all separated by "|".
+func cacheKeyUnder(key, prefix string) bool {
+	for _, part := range strings.Split(key, "|") {
+		method, target, ok := strings.Cut(part, ":")
+		if ok && method != "" && strings.ToUpper(method) == method && urlUnder(target, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// urlUnder reports whether target is the URL prefix or a URL under it, on path segment boundaries
+func urlUnder(target, prefix string) bool {
+	if !strings.HasPrefix(target, prefix) {
+		return false
+	}
+	rest := target[len(prefix):]
+	return rest == "" || strings.HasSuffix(prefix, "/") || rest[0] == '/' || rest[0] == '?'
+}
+
 // Execute implements the Middleware interface
 func (m *CacheMiddleware) Execute(ctx context.Context, req *http.Request, next MiddlewareFunc) (*http.Response, error) {
+	ctx, outcome := ensureCacheOutcome(ctx)
+	outcome.status = CacheMiss
+	resp, err := m.execute(ctx, req, next)
+	switch {
+	case outcome.status == CacheBypass:
+	case outcome.status.FromCache():
+		m.hits.Add(1)
+	default:
+		m.misses.Add(1)
+	}
+	if resp != nil && m.config.StatusHeader != "" {
+		if resp.Header == nil {
+			resp.Header = make(http.Header)
+		}
+		resp.Header.Set(m.config.StatusHeader, string(outcome.status))
+	}
+	return resp, err
+}
+
+// execute answers a request from the cache or the origin, recording the CacheStatus in the context
+func (m *CacheMiddleware) execute(ctx context.Context, req *http.Request, next MiddlewareFunc) (*http.Response, error) {
 	// Check if request is cacheable
 	if !m.isCacheable(req) {
+		setCacheStatus(ctx, CacheBypass)
 		return next(ctx, req)
 	}
+	ctx, recordLookup := m.trackLookup(ctx, req)
+	defer recordLookup()
+
+	switch req.Method {
+	case http.MethodHead:
+		return m.executeHead(ctx, req, next)
+	case http.MethodOptions:
+		return m.executePreflight(ctx, req, next)
+	}
 
 	cacheKey := m.generateCacheKey(req)
 
+	// Serve fresh entries directly when refreshing ahead, renewing those nearing expiry in the background
+	forceRevalidate := requestCacheOptions(req).ForceRevalidate
+	if m.config.RefreshAhead > 0 && !forceRevalidate {
+		if cached, found := m.lookupFresh(req, cacheKey); found {
+			if m.dueForRefresh(cached) {
+				m.refresh(ctx, req, cacheKey, cached, next)
+			}
+			return m.buildResponseFromCache(ctx, cached), nil
+		}
+	}
+
+	// Serve fresh entries directly, and stale ones while revalidating them in the background
+	if m.config.RevalidateAsync && !forceRevalidate {
+		if cached, found := m.get(req, cacheKey); found {
+			now := time.Now()
+			if now.Before(cached.ExpiresAt) {
+				return m.buildResponseFromCache(ctx, cached), nil
+			}
+			if now.Before(cached.ExpiresAt.Add(cached.StaleWhileRevalidate)) {
+				m.refresh(ctx, req, cacheKey, cached, next)
+				return m.buildStaleResponseFromCache(ctx, cached), nil
+			}
+		}
+	}
+
+	if m.config.CoalesceRequests {
+		return m.fetchCoalesced(ctx, req, cacheKey, next)
+	}
+	resp, _, err := m.fetch(ctx, req, cacheKey, next)
+	return resp, err
+}
+
+// fetch revalidates or fetches the response from the origin and caches it, reporting whether it was stored
+func (m *CacheMiddleware) fetch(ctx context.Context, req *http.Request, cacheKey string, next MiddlewareFunc) (*http.Response, bool, error) {
 	// Try to get from cache
-	if cached, found := m.config.Backend.Get(cacheKey); found {
+	cached, found := m.get(req, cacheKey)
+	if found {
 		// Add conditional request headers
 		if cached.ETag != "" {
 			req.Header.Set("If-None-Match", cached.ETag)
@@ -219,33 +624,264 @@ func (m *CacheMiddleware) Execute(ctx context.Context, req *http.Request, next M
 		if cached.LastModified != "" {
 			req.Header.Set("If-Modified-Since", cached.LastModified)
 		}
+	} else if req.Header.Get("If-None-Match") == "" && req.Header.Get("If-Modified-Since") == "" {
+		// Without a cached response a 304 Not Modified response is returned as is
+		if entry, ok := m.validators.lookup(m.validatorKey(req)); ok && entry.seeded {
+			if entry.validators.ETag != "" {
+				req.Header.Set("If-None-Match", entry.validators.ETag)
+			}
+			if entry.validators.LastModified != "" {
+				req.Header.Set("If-Modified-Since", entry.validators.LastModified)
+			}
+		}
 	}
 
 	// Execute request
 	resp, err := next(ctx, req)
+	if found && m.servableOnError(ctx, cached, resp, err) {
+		if resp != nil {
+			drainAndClose(resp.Body, defaultDrainLimit)
+		}
+		return m.buildStaleResponseFromCache(ctx, cached), false, nil
+	}
 	if err != nil {
-		return nil, err
+		return nil, false, err
 	}
+	m.recordValidators(req, resp)
 
 	// Handle 304 Not Modified
 	if resp.StatusCode == http.StatusNotModified {
-		if cached, found := m.config.Backend.Get(cacheKey); found {
-			return m.buildResponseFromCache(cached), nil
+		if cached, found := m.get(req, cacheKey); found {
+			_ = resp.Body.Close()
+			renewed := m.renew(req, cached, resp)
+			if m.set(req, cacheKey, renewed) == nil {
+				m.recordStore(req, renewed.ExpiresAt)
+			}
+			resp := m.buildResponseFromCache(ctx, renewed)
+			setCacheStatus(ctx, CacheRevalidated)
+			return resp, true, nil
 		}
 	}
 
 	// Cache successful responses
-	if m.shouldCache(resp) {
-		if err := m.cacheResponse(cacheKey, resp); err != nil {
-			// Log error but don't fail the request
-			// In production, you might want to log this
-			_ = err
+	if m.shouldCache(req, resp) {
+		// Don't fail the request when the response cannot be stored
+		return resp, m.cacheResponse(req, cacheKey, resp) == nil, nil
+	}
+
+	return resp, false, nil
+}
+
+// fetchCoalesced fetches the response unless a fetch of the key is in flight, in which case it waits for that
+// fetch and answers from the response it stored
+func (m *CacheMiddleware) fetchCoalesced(ctx context.Context, req *http.Request, cacheKey string, next MiddlewareFunc) (*http.Response, error) {
+	m.mu.Lock()
+	if flight, ok := m.flights[cacheKey]; ok {
+		m.mu.Unlock()
+		select {
+		case <-flight.done:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		if flight.stored {
+			if cached, found := m.lookupFresh(req, cacheKey); found {
+				return m.buildResponseFromCache(ctx, cached), nil
+			}
+		}
+		resp, _, err := m.fetch(ctx, req, cacheKey, next)
+		return resp, err
+	}
+	flight := &cacheFlight{done: make(chan struct{})}
+	m.flights[cacheKey] = flight
+	m.mu.Unlock()
+
+	// Release the waiters even if the fetch panics
+	defer func() {
+		m.mu.Lock()
+		delete(m.flights, cacheKey)
+		m.mu.Unlock()
+		close(flight.done)
+	}()
+	resp, stored, err := m.fetch(ctx, req, cacheKey, next)
+	flight.stored = stored
+	return resp, err
+}
+
+// executeHead answers a HEAD request from a fresh stored GET or HEAD response, going to the origin otherwise
+func (m *CacheMiddleware) executeHead(ctx context.Context, req *http.Request, next MiddlewareFunc) (*http.Response, error) {
+	cacheKey := m.generateCacheKey(req)
+	if !requestCacheOptions(req).ForceRevalidate {
+		for _, key := range []string{m.methodCacheKey(http.MethodGet, req), cacheKey} {
+			if cached, found := m.lookupFresh(req, key); found {
+				return m.buildHeadResponseFromCache(ctx, cached), nil
+			}
 		}
 	}
 
+	resp, err := next(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if m.shouldCache(req, resp) {
+		_ = m.cacheResponse(req, cacheKey, resp)
+	}
 	return resp, nil
 }
 
+// recordValidators stores the validators of a successful response to req, replacing those seeded by the caller
+func (m *CacheMiddleware) recordValidators(req *http.Request, resp *http.Response) {
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return
+	}
+	validators := Validators{ETag: resp.Header.Get("ETag"), LastModified: resp.Header.Get("Last-Modified")}
+	if validators != (Validators{}) {
+		m.validators.store(m.validatorKey(req), validators, false)
+	}
+}
+
+// validatorKey returns the key of the validators of the URL of req in its partition
+func (m *CacheMiddleware) validatorKey(req *http.Request) validatorKey {
+	return validatorKey{partition: m.config.PartitionFunc(req), url: CanonicalURL(req.URL).String()}
+}
+
+// servableOnError reports whether the cached response may answer a request the origin failed with a network
+// error or a 5xx response, during its stale-if-error window
+// Cancellation is not an origin failure, the caller gave up on the request.
+func (m *CacheMiddleware) servableOnError(ctx context.Context, cached *CachedResponse, resp *http.Response, err error) bool {
+	if err != nil {
+		if ctx.Err() != nil {
+			return false
+		}
+	} else if resp.StatusCode < http.StatusInternalServerError {
+		return false
+	}
+	return cached.StaleIfError > 0 && time.Now().Before(cached.ExpiresAt.Add(cached.StaleIfError))
+}
+
+// dueForRefresh reports whether the RefreshAhead fraction of the entry's lifetime has elapsed
+func (m *CacheMiddleware) dueForRefresh(cached *CachedResponse) bool {
+	lifetime := cached.ExpiresAt.Sub(cached.CachedAt)
+	return time.Since(cached.CachedAt) >= time.Duration(float64(lifetime)*m.config.RefreshAhead)
+}
+
+// refresh re-fetches a cached response in the background, unless a refresh of the entry is already running
+// The refresh outlives the request that triggered it, keeping its context values but not its cancellation; it
+// is canceled with CacheConfig.BaseContext, or the base context of the client, instead.
+func (m *CacheMiddleware) refresh(ctx context.Context, req *http.Request, key string, cached *CachedResponse, next MiddlewareFunc) {
+	base := m.config.BaseContext
+	if base == nil {
+		base = baseContextFromContext(ctx)
+	}
+	if base != nil && base.Err() != nil {
+		return
+	}
+
+	// Variants of a response are refreshed independently
+	refreshKey := key
+	if len(varyFields(cached.Headers)) > 0 {
+		refreshKey = variantCacheKey(key, cached.Headers, req)
+	}
+	m.mu.Lock()
+	if _, running := m.refreshing[refreshKey]; running {
+		m.mu.Unlock()
+		return
+	}
+	m.refreshing[refreshKey] = struct{}{}
+	m.mu.Unlock()
+
+	ctx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	stop := func() bool { return false }
+	if base != nil {
+		stop = context.AfterFunc(base, cancel)
+	}
+	refreshReq := req.Clone(ctx)
+	refreshReq.Header.Del("If-None-Match")
+	refreshReq.Header.Del("If-Modified-Since")
+	if cached.ETag != "" {
+		refreshReq.Header.Set("If-None-Match", cached.ETag)
+	}
+	if cached.LastModified != "" {
+		refreshReq.Header.Set("If-Modified-Since", cached.LastModified)
+	}
+
+	go func() {
+		defer func() {
+			stop()
+			cancel()
+			m.mu.Lock()
+			delete(m.refreshing, refreshKey)
+			m.mu.Unlock()
+		}()
+
+		resp, err := next(ctx, refreshReq)
+		if err != nil {
+			return
+		}
+		m.recordValidators(refreshReq, resp)
+		switch {
+		case resp.StatusCode == http.StatusNotModified:
+			renewed := m.renew(refreshReq, cached, resp)
+			if m.set(refreshReq, key, renewed) == nil {
+				m.recordStore(refreshReq, renewed.ExpiresAt)
+			}
+		case m.shouldCache(refreshReq, resp):
+			_ = m.cacheResponse(refreshReq, key, resp)
+		}
+		_ = resp.Body.Close()
+	}()
+}
+
+// renew returns the cached response revalidated by a 304 Not Modified response to req: its headers are updated
+// with those of the 304, except Content-Length, and its expiry and stale windows are computed again from them,
+// as RFC 9111 section 4.3.4 requires
+func (m *CacheMiddleware) renew(req *http.Request, cached *CachedResponse, notModified *http.Response) *CachedResponse {
+	renewed := *cached
+	renewed.Headers = cached.Headers.Clone()
+	if renewed.Headers == nil {
+		renewed.Headers = make(http.Header)
+	}
+	for name, values := range notModified.Header {
+		if name != "Content-Length" {
+			renewed.Headers[name] = slices.Clone(values)
+		}
+	}
+
+	merged := &http.Response{StatusCode: cached.StatusCode, Header: renewed.Headers, Request: req}
+	renewed.CachedAt = time.Now()
+	renewed.ExpiresAt = m.calculateExpiration(req, merged)
+	renewed.ETag = renewed.Headers.Get("ETag")
+	renewed.LastModified = renewed.Headers.Get("Last-Modified")
+	renewed.StaleWhileRevalidate, renewed.StaleIfError = m.staleWindows(merged)
+	return &renewed
+}
+
+// executePreflight answers an OPTIONS request from a fresh stored preflight result, going to the origin otherwise
+func (m *CacheMiddleware) executePreflight(ctx context.Context, req *http.Request, next MiddlewareFunc) (*http.Response, error) {
+	cacheKey := m.preflightCacheKey(req)
+	if cached, found := m.lookupFresh(req, cacheKey); found && !requestCacheOptions(req).ForceRevalidate {
+		return m.buildResponseFromCache(ctx, cached), nil
+	}
+
+	resp, err := next(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if expiresAt, ok := m.preflightExpiration(req, resp); ok && m.shouldCache(req, resp) {
+		_ = m.storeResponse(req, cacheKey, resp, expiresAt)
+	}
+	return resp, nil
+}
+
+// lookupFresh returns the response stored for req under key if it has not expired
+// Backends may return expired entries, the in-memory backend drops them on lookup
+func (m *CacheMiddleware) lookupFresh(req *http.Request, key string) (*CachedResponse, bool) {
+	cached, found := m.get(req, key)
+	if !found || !time.Now().Before(cached.ExpiresAt) {
+		return nil, false
+	}
+	return cached, true
+}
+
 // isCacheable checks if the request should use caching
 func (m *CacheMiddleware) isCacheable(req *http.Request) bool {
 	// Check if method is cacheable
@@ -261,29 +897,135 @@ func (m *CacheMiddleware) isCacheable(req *http.Request) bool {
 		return false
 	}
 
-	// Check custom skip function
+	// Check custom skip function and the directives of the request
 	if m.config.SkipCacheFor != nil && m.config.SkipCacheFor(req) {
 		return false
 	}
+	if requestCacheOptions(req).NoCache {
+		return false
+	}
 
 	return true
 }
 
 // generateCacheKey creates a unique cache key for the request
+// Responses varying on request headers are stored under keys of their own derived from it, see get and set.
 func (m *CacheMiddleware) generateCacheKey(req *http.Request) string {
-	// Basic key: method + URL
-	key := fmt.Sprintf("%s:%s", req.Method, req.URL.String())
+	return m.methodCacheKey(req.Method, req)
+}
 
-	// Add Vary header consideration if present in request context
-	// Note: Full Vary support would require storing response headers first
+// methodCacheKey creates the cache key of the request URL for the given method, scoped to the request partition
+func (m *CacheMiddleware) methodCacheKey(method string, req *http.Request) string {
+	if req.Method != method {
+		req = req.WithContext(req.Context())
+		req.Method = method
+	}
+	key := requestCacheOptions(req).Key
+	if key == "" {
+		key = m.config.KeyFunc(req)
+	}
+	if partition := m.config.PartitionFunc(req); partition != "" {
+		key = partition + "|" + key
+	}
 	return key
 }
 
-// shouldCache determines if response should be cached
-func (m *CacheMiddleware) shouldCache(resp *http.Response) bool {
+// get returns the entry stored under key for req, or the one waiting to be stored with CacheConfig.WriteBehind
+// The entry of a response with a Vary header is an index naming the headers it varies on, which leads to the
+// variant stored for the values req sends.
+func (m *CacheMiddleware) get(req *http.Request, key string) (*CachedResponse, bool) {
+	if cached, pending := m.pendingWrite(req, key); pending {
+		return cached, true
+	}
+	cached, found := m.config.Backend.Get(key)
+	if !found || !isVaryIndex(cached) {
+		return cached, found
+	}
+	return m.config.Backend.Get(variantCacheKey(key, cached.Headers, req))
+}
+
+// set stores the response to req under key, in the background with CacheConfig.WriteBehind
+func (m *CacheMiddleware) set(req *http.Request, key string, cached *CachedResponse) error {
+	if m.config.WriteBehind {
+		return m.queueWrite(req, key, cached)
+	}
+	return m.store(req, key, cached)
+}
+
+// store stores the response to req under key, or under the key of its variant when it has a Vary header
+// The index stored under key is retained as long as the longest lived variant stored through it.
+func (m *CacheMiddleware) store(req *http.Request, key string, cached *CachedResponse) error {
+	fields := varyFields(cached.Headers)
+	if len(fields) == 0 {
+		return m.config.Backend.Set(key, cached)
+	}
+	if err := m.config.Backend.Set(variantCacheKey(key, cached.Headers, req), cached); err != nil {
+		return err
+	}
+	index := &CachedResponse{
+		Headers:   http.Header{"Vary": {strings.Join(fields, ", ")}},
+		CachedAt:  cached.CachedAt,
+		ExpiresAt: cached.RetainUntil(),
+	}
+	if existing, found := m.config.Backend.Get(key); found && isVaryIndex(existing) && existing.ExpiresAt.After(index.ExpiresAt) {
+		index.ExpiresAt = existing.ExpiresAt
+	}
+	return m.config.Backend.Set(key, index)
+}
+
+// isVaryIndex reports whether a stored entry is the index of the variants of a response rather than a response
+func isVaryIndex(cached *CachedResponse) bool {
+	return cached.StatusCode == 0
+}
+
+// varyFields returns the canonical names of the request headers listed by the Vary header, sorted
+func varyFields(header http.Header) []string {
+	var fields []string
+	for _, value := range header.Values("Vary") {
+		for _, field := range strings.Split(value, ",") {
+			if field = strings.TrimSpace(field); field != "" {
+				fields = append(fields, http.CanonicalHeaderKey(field))
+			}
+		}
+	}
+	slices.Sort(fields)
+	return slices.Compact(fields)
+}
+
+// variantCacheKey returns the key of the variant of the response stored under key selected by req
+// The header values are hashed so that credentials named by Vary, e.g. Authorization, do not appear in keys.
+func variantCacheKey(key string, header http.Header, req *http.Request) string {
+	hash := sha256.New()
+	for _, field := range varyFields(header) {
+		_, _ = fmt.Fprintf(hash, "%s:%q\n", field, req.Header.Values(field))
+	}
+	return key + "|vary=" + hex.EncodeToString(hash.Sum(nil)[:16])
+}
+
+// preflightCacheKey creates the cache key of a CORS preflight request
+// Preflight results depend on the origin and on the method and headers the actual request will use
+func (m *CacheMiddleware) preflightCacheKey(req *http.Request) string {
+	return fmt.Sprintf("%s|%s|%s|%s",
+		m.methodCacheKey(req.Method, req),
+		req.Header.Get("Origin"),
+		req.Header.Get("Access-Control-Request-Method"),
+		strings.ToLower(req.Header.Get("Access-Control-Request-Headers")),
+	)
+}
+
+// shouldCache determines if the response to req should be cached
+func (m *CacheMiddleware) shouldCache(req *http.Request, resp *http.Response) bool {
 	// Check Cache-Control directives
-	cacheControl := resp.Header.Get("Cache-Control")
-	if strings.Contains(cacheControl, "no-store") || strings.Contains(cacheControl, "no-cache") {
+	cacheControl := responseCacheControl(resp.Header)
+	if cacheControl.Has("no-store") || cacheControl.Has("no-cache") {
+		return false
+	}
+	if !m.config.PrivateCache && !sharedCacheMayStore(req, cacheControl) {
+		return false
+	}
+
+	// A response varying on more than request headers cannot be selected for a later request, see RFC 9111 4.1
+	if slices.Contains(varyFields(resp.Header), "*") {
 		return false
 	}
 
@@ -291,21 +1033,49 @@ func (m *CacheMiddleware) shouldCache(resp *http.Response) bool {
 	return resp.StatusCode >= 200 && resp.StatusCode < 300
 }
 
-// cacheResponse stores a response in the cache
-func (m *CacheMiddleware) cacheResponse(key string, resp *http.Response) error {
-	// Read response body
-	bodyBytes, err := io.ReadAll(resp.Body)
+// sharedCacheMayStore reports whether a shared cache may store a response with the given directives to req,
+// as specified by RFC 9111 sections 3 and 3.5
+func sharedCacheMayStore(req *http.Request, cacheControl CacheControl) bool {
+	if cacheControl.Has("private") {
+		return false
+	}
+	if req.Header.Get("Authorization") == "" {
+		return true
+	}
+	return cacheControl.Has("public") || cacheControl.Has("s-maxage") || cacheControl.Has("must-revalidate")
+}
+
+// cacheResponse stores the response to req in the cache
+func (m *CacheMiddleware) cacheResponse(req *http.Request, key string, resp *http.Response) error {
+	return m.storeResponse(req, key, resp, m.calculateExpiration(req, resp))
+}
+
+// storeResponse stores the response to req in the cache until expiresAt
+func (m *CacheMiddleware) storeResponse(req *http.Request, key string, resp *http.Response, expiresAt time.Time) error {
+	limit := m.config.MaxEntrySizeBytes
+	if limit > 0 && resp.ContentLength > limit {
+		return tagError(errors.Errorf("response of %d bytes exceeds the cache entry limit of %d bytes", resp.ContentLength, limit), ErrTooLarge)
+	}
+
+	// Read response body, stopping past the entry limit so an oversized response is not buffered
+	var body io.Reader = resp.Body
+	if limit > 0 {
+		body = io.LimitReader(resp.Body, limit+1)
+	}
+	bodyBytes, err := io.ReadAll(body)
 	if err != nil {
 		return err
 	}
+	if limit > 0 && int64(len(bodyBytes)) > limit {
+		// Hand the downstream consumer the bytes read so far followed by the rest of the body
+		resp.Body = &replayBody{Reader: io.MultiReader(bytes.NewReader(bodyBytes), resp.Body), body: resp.Body}
+		return tagError(errors.Errorf("response exceeds the cache entry limit of %d bytes", limit), ErrTooLarge)
+	}
 	resp.Body.Close()
 
 	// Restore body for downstream consumers
 	resp.Body = io.NopCloser(bytes.NewReader(bodyBytes))
 
-	// Calculate expiration time
-	expiresAt := m.calculateExpiration(resp)
-
 	// Create cached response
 	cached := &CachedResponse{
 		StatusCode:   resp.StatusCode,
@@ -316,27 +1086,37 @@ func (m *CacheMiddleware) cacheResponse(key string, resp *http.Response) error {
 		ETag:         resp.Header.Get("ETag"),
 		LastModified: resp.Header.Get("Last-Modified"),
 	}
+	cached.StaleWhileRevalidate, cached.StaleIfError = m.staleWindows(resp)
 
-	return m.config.Backend.Set(key, cached)
+	if err := m.set(req, key, cached); err != nil {
+		return err
+	}
+	m.recordStore(req, expiresAt)
+	return nil
 }
 
 // calculateExpiration determines when a cached response expires
-func (m *CacheMiddleware) calculateExpiration(resp *http.Response) time.Time {
-	// Check Cache-Control max-age
-	cacheControl := resp.Header.Get("Cache-Control")
-	if cacheControl != "" {
-		directives := strings.Split(cacheControl, ",")
-		for _, directive := range directives {
-			directive = strings.TrimSpace(directive)
-			if strings.HasPrefix(directive, "max-age=") {
-				maxAgeStr := strings.TrimPrefix(directive, "max-age=")
-				var maxAge int
-				if _, err := fmt.Sscanf(maxAgeStr, "%d", &maxAge); err == nil && maxAge > 0 {
-					return time.Now().Add(time.Duration(maxAge) * time.Second)
-				}
-			}
+// The TTL set with WithCacheTTL, then a matching TTL rule forced with Override take precedence over the caching
+// headers; other rules replace DefaultTTL.
+func (m *CacheMiddleware) calculateExpiration(req *http.Request, resp *http.Response) time.Time {
+	if ttl := requestCacheOptions(req).TTL; ttl > 0 {
+		return time.Now().Add(ttl)
+	}
+	rule, matched := m.ttlRule(req)
+	if matched && rule.Override {
+		return time.Now().Add(rule.TTL)
+	}
+	cacheControl := responseCacheControl(resp.Header)
+
+	// Check Cache-Control s-maxage, which only applies to shared caches, then max-age
+	if !m.config.PrivateCache {
+		if sharedMaxAge, ok := cacheControl.SharedMaxAge(); ok && sharedMaxAge > 0 {
+			return time.Now().Add(sharedMaxAge)
 		}
 	}
+	if maxAge, ok := cacheControl.MaxAge(); ok && maxAge > 0 {
+		return time.Now().Add(maxAge)
+	}
 
 	// Check Expires header
 	if expiresStr := resp.Header.Get("Expires"); expiresStr != "" {
@@ -345,12 +1125,59 @@ func (m *CacheMiddleware) calculateExpiration(resp *http.Response) time.Time {
 		}
 	}
 
-	// Use default TTL
+	// Use the TTL of the matching rule, else the default TTL
+	if matched {
+		return time.Now().Add(rule.TTL)
+	}
 	return time.Now().Add(m.config.DefaultTTL)
 }
 
-// buildResponseFromCache reconstructs an HTTP response from cache
-func (m *CacheMiddleware) buildResponseFromCache(cached *CachedResponse) *http.Response {
+// ttlRule returns the first TTL rule matching the path of req
+func (m *CacheMiddleware) ttlRule(req *http.Request) (CacheTTLRule, bool) {
+	for _, rule := range m.config.TTLRules {
+		if matched, _ := path.Match(rule.PathPattern, req.URL.Path); matched && rule.TTL > 0 {
+			return rule, true
+		}
+	}
+	return CacheTTLRule{}, false
+}
+
+// staleWindows determines how long after it expired a response may be served stale, see RFC 5861
+// must-revalidate and proxy-revalidate forbid serving the response stale at all.
+func (m *CacheMiddleware) staleWindows(resp *http.Response) (whileRevalidate, ifError time.Duration) {
+	cacheControl := responseCacheControl(resp.Header)
+	if cacheControl.Has("must-revalidate") || (!m.config.PrivateCache && cacheControl.Has("proxy-revalidate")) {
+		return 0, 0
+	}
+	if m.config.RevalidateAsync {
+		whileRevalidate, _ = cacheControl.StaleWhileRevalidate()
+	}
+	ifError, ok := cacheControl.StaleIfError()
+	if !ok {
+		ifError = m.config.MaxStaleOnError
+	}
+	return whileRevalidate, ifError
+}
+
+// preflightExpiration determines when a cached preflight result expires
+// Access-Control-Max-Age takes precedence over the HTTP caching headers; zero or negative values disable caching
+func (m *CacheMiddleware) preflightExpiration(req *http.Request, resp *http.Response) (time.Time, bool) {
+	maxAgeStr := resp.Header.Get("Access-Control-Max-Age")
+	if maxAgeStr == "" {
+		return m.calculateExpiration(req, resp), true
+	}
+	maxAge, ok := parseDeltaSeconds(strings.TrimSpace(maxAgeStr))
+	if !ok || maxAge <= 0 {
+		return time.Time{}, false
+	}
+	return time.Now().Add(time.Duration(maxAge) * time.Second), true
+}
+
+// buildResponseFromCache reconstructs an HTTP response from cache, counting a cache hit for the client
+func (m *CacheMiddleware) buildResponseFromCache(ctx context.Context, cached *CachedResponse) *http.Response {
+	countCacheHit(ctx)
+	recordHit(ctx, cached)
+	setCacheStatus(ctx, CacheHit)
 	return &http.Response{
 		StatusCode:    cached.StatusCode,
 		Status:        http.StatusText(cached.StatusCode),
@@ -362,3 +1189,32 @@ func (m *CacheMiddleware) buildResponseFromCache(cached *CachedResponse) *http.R
 		ProtoMinor:    1,
 	}
 }
+
+// buildStaleResponseFromCache reconstructs an HTTP response from an expired cache entry
+func (m *CacheMiddleware) buildStaleResponseFromCache(ctx context.Context, cached *CachedResponse) *http.Response {
+	resp := m.buildResponseFromCache(ctx, cached)
+	setCacheStatus(ctx, CacheStale)
+	return resp
+}
+
+// buildHeadResponseFromCache reconstructs the response to a HEAD request from a cached GET or HEAD response
+func (m *CacheMiddleware) buildHeadResponseFromCache(ctx context.Context, cached *CachedResponse) *http.Response {
+	resp := m.buildResponseFromCache(ctx, cached)
+	resp.Body = http.NoBody
+	resp.ContentLength = int64(len(cached.Body))
+	if contentLength, err := strconv.ParseInt(cached.Headers.Get("Content-Length"), 10, 64); err == nil {
+		resp.ContentLength = contentLength
+	}
+	return resp
+}
+
+// replayBody replays the part of a response body read while deciding whether to cache it before the rest
+type replayBody struct {
+	io.Reader
+	body io.ReadCloser
+}
+
+// Close closes the underlying response body
+func (b *replayBody) Close() error {
+	return b.body.Close()
+}
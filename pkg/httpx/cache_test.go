@@ -1,12 +1,17 @@
 package httpx_test
 
 import (
+	"context"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
@@ -408,6 +413,186 @@ func TestInMemoryCache_Stats(t *testing.T) {
 	})
 }
 
+func TestInMemoryCache_ByteLimits(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	newResponse := func(bodySize int) *httpx.CachedResponse {
+		return &httpx.CachedResponse{
+			StatusCode: 200,
+			Headers:    http.Header{"Content-Type": {"application/json"}},
+			Body:       make([]byte, bodySize),
+			ExpiresAt:  now.Add(time.Hour),
+		}
+	}
+	entrySize := newResponse(1000).Size()
+
+	t.Run("estimates the size of a response", func(t *testing.T) {
+		t.Parallel()
+
+		small, large := newResponse(0), newResponse(1000)
+		large.ETag = `"v1"`
+
+		assert.Equal(t, int64(1004), large.Size()-small.Size())
+		assert.Greater(t, small.Size(), int64(len("Content-Type")+len("application/json")))
+	})
+
+	t.Run("evicts least recently used entries beyond MaxBytes", func(t *testing.T) {
+		t.Parallel()
+
+		subject := httpx.NewInMemoryCacheWithConfig(httpx.InMemoryCacheConfig{MaxBytes: 3 * entrySize})
+		require.NoError(t, subject.Set("a", newResponse(1000)))
+		require.NoError(t, subject.Set("b", newResponse(1000)))
+		require.NoError(t, subject.Set("c", newResponse(1000)))
+		_, found := subject.Get("a")
+		require.True(t, found)
+
+		require.NoError(t, subject.Set("d", newResponse(1000)))
+
+		_, found = subject.Get("b")
+		assert.False(t, found, "least recently used entry should be evicted")
+		for _, key := range []string{"a", "c", "d"} {
+			_, found = subject.Get(key)
+			assert.True(t, found, key)
+		}
+		stats := subject.Stats()
+		assert.Equal(t, int64(3), stats.Size)
+		assert.Equal(t, 3*entrySize, stats.Bytes)
+		assert.Equal(t, int64(1), stats.Evictions)
+		assert.Equal(t, entrySize, stats.EvictedBytes)
+	})
+
+	t.Run("evicts several entries to fit a large one", func(t *testing.T) {
+		t.Parallel()
+
+		subject := httpx.NewInMemoryCacheWithConfig(httpx.InMemoryCacheConfig{MaxBytes: 3 * entrySize})
+		for _, key := range []string{"a", "b", "c"} {
+			require.NoError(t, subject.Set(key, newResponse(1000)))
+		}
+
+		large := newResponse(2000)
+		require.NoError(t, subject.Set("large", large))
+
+		stats := subject.Stats()
+		assert.Equal(t, int64(2), stats.Size)
+		assert.Equal(t, entrySize+large.Size(), stats.Bytes)
+		assert.Equal(t, int64(2), stats.Evictions)
+	})
+
+	t.Run("accounts for replaced and deleted entries", func(t *testing.T) {
+		t.Parallel()
+
+		subject := httpx.NewInMemoryCacheWithConfig(httpx.InMemoryCacheConfig{MaxBytes: 10 * entrySize})
+		require.NoError(t, subject.Set("a", newResponse(1000)))
+		require.NoError(t, subject.Set("a", newResponse(0)))
+		assert.Equal(t, newResponse(0).Size(), subject.Stats().Bytes)
+
+		require.NoError(t, subject.Delete("a"))
+		assert.Zero(t, subject.Stats().Bytes)
+
+		require.NoError(t, subject.Set("b", newResponse(1000)))
+		require.NoError(t, subject.Clear())
+		assert.Equal(t, httpx.CacheStats{}, subject.Stats())
+	})
+
+	t.Run("rejects entries larger than MaxEntryBytes", func(t *testing.T) {
+		t.Parallel()
+
+		subject := httpx.NewInMemoryCacheWithConfig(httpx.InMemoryCacheConfig{MaxBytes: 10 * entrySize, MaxEntryBytes: entrySize})
+		require.NoError(t, subject.Set("a", newResponse(1000)))
+
+		err := subject.Set("a", newResponse(1001))
+
+		require.ErrorIs(t, err, httpx.ErrTooLarge)
+		_, found := subject.Get("a")
+		assert.False(t, found, "a rejected response must not leave the previous one in place")
+		stats := subject.Stats()
+		assert.Equal(t, int64(1), stats.Rejected)
+		assert.Zero(t, stats.Bytes)
+	})
+
+	t.Run("counts expired entries as evicted bytes", func(t *testing.T) {
+		t.Parallel()
+
+		subject := httpx.NewInMemoryCacheWithConfig(httpx.InMemoryCacheConfig{})
+		expired := newResponse(10)
+		expired.ExpiresAt = now.Add(-time.Second)
+		require.NoError(t, subject.Set("a", expired))
+
+		_, found := subject.Get("a")
+
+		assert.False(t, found)
+		assert.Equal(t, httpx.CacheStats{Misses: 1, Evictions: 1, EvictedBytes: expired.Size()}, subject.Stats())
+	})
+}
+
+func TestCacheMiddleware_SizeLimits(t *testing.T) {
+	t.Parallel()
+
+	body := strings.Repeat("x", 4096)
+	newServer := func(chunked bool) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Cache-Control", "max-age=60")
+			size := len(body)
+			if r.URL.Path == "/small" {
+				size = 10
+			}
+			if chunked {
+				w.(http.Flusher).Flush() // No Content-Length
+			}
+			_, _ = w.Write([]byte(body[:size]))
+		}))
+	}
+
+	for _, chunked := range []bool{false, true} {
+		t.Run(fmt.Sprintf("does not cache responses over the entry limit, chunked=%t", chunked), func(t *testing.T) {
+			t.Parallel()
+
+			server := newServer(chunked)
+			defer server.Close()
+
+			cache := httpx.NewCacheMiddleware(httpx.CacheConfig{MaxSizeBytes: 64 * 1024, MaxEntrySizeBytes: 1024})
+			client := httpx.NewClientWithConfig(
+				httpx.WithClientDefaultBaseURL(server.URL),
+				httpx.WithClientMiddleware(cache),
+			)
+
+			for range 2 {
+				resp, err := client.Execute(*httpx.NewRequest(http.MethodGet, httpx.WithPath("/large")), "")
+				require.NoError(t, err)
+				assert.Equal(t, body, resp.Body, "the full body is delivered even when it is not cached")
+			}
+			_, err := client.Execute(*httpx.NewRequest(http.MethodGet, httpx.WithPath("/small")), "")
+			require.NoError(t, err)
+
+			assert.Equal(t, int64(1), cache.Stats().Size, "only the small response is cached")
+		})
+	}
+
+	t.Run("bounds the default backend by MaxSizeBytes", func(t *testing.T) {
+		t.Parallel()
+
+		server := newServer(false)
+		defer server.Close()
+
+		cache := httpx.NewCacheMiddleware(httpx.CacheConfig{MaxSizeBytes: 3 * 4096})
+		client := httpx.NewClientWithConfig(
+			httpx.WithClientDefaultBaseURL(server.URL),
+			httpx.WithClientMiddleware(cache),
+		)
+
+		for i := range 5 {
+			_, err := client.Execute(*httpx.NewRequest(http.MethodGet, httpx.WithPath(fmt.Sprintf("/large/%d", i))), "")
+			require.NoError(t, err)
+		}
+
+		stats := cache.Stats()
+		assert.Equal(t, int64(2), stats.Size)
+		assert.LessOrEqual(t, stats.Bytes, int64(3*4096))
+		assert.Equal(t, int64(3), stats.Evictions)
+	})
+}
+
 func TestInMemoryCache_Concurrency(t *testing.T) {
 	t.Parallel()
 
@@ -840,6 +1025,41 @@ func TestCacheMiddleware_ConditionalRequests(t *testing.T) {
 
 		assert.NotEmpty(t, ifModifiedSince, "Expected If-Modified-Since header to be set on second request")
 	})
+
+	t.Run("renews the entry with the headers of a 304 Not Modified", func(t *testing.T) {
+		t.Parallel()
+
+		var requests atomic.Int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests.Add(1)
+			w.Header().Set("ETag", `"v1"`)
+			if r.Header.Get("If-None-Match") == `"v1"` {
+				w.Header().Set("Cache-Control", "max-age=60")
+				w.Header().Set("X-Version", "2")
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			w.Header().Set("Expires", time.Now().Add(-time.Minute).UTC().Format(http.TimeFormat))
+			w.Header().Set("X-Version", "1")
+			_, _ = w.Write([]byte(`{}`))
+		}))
+		defer server.Close()
+
+		client := httpx.NewClientWithConfig(
+			httpx.WithClientDefaultBaseURL(server.URL),
+			httpx.WithClientCache(httpx.CacheConfig{RevalidateAsync: true, MaxStaleOnError: time.Hour}),
+		)
+		var responses []*httpx.Response
+		for range 3 {
+			resp, err := client.Execute(*httpx.NewRequest(http.MethodGet), map[string]any{})
+			require.NoError(t, err)
+			responses = append(responses, resp)
+		}
+
+		assert.Equal(t, int32(2), requests.Load(), "the renewed entry is fresh")
+		assert.Equal(t, "2", responses[1].Header().Get("X-Version"))
+		assert.Equal(t, "2", responses[2].Header().Get("X-Version"))
+	})
 }
 
 func TestCacheMiddleware_SkipCache(t *testing.T) {
@@ -987,3 +1207,1304 @@ func TestCacheMiddleware_ErrorHandling(t *testing.T) {
 		assert.Error(t, err)
 	})
 }
+
+func TestCacheMiddleware_HeadAndPreflight(t *testing.T) {
+	t.Parallel()
+
+	t.Run("answers HEAD from a fresh GET response", func(t *testing.T) {
+		t.Parallel()
+
+		var serverCalls atomic.Int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			serverCalls.Add(1)
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("Cache-Control", "max-age=3600")
+			_, _ = w.Write([]byte(`{"size":10}`))
+		}))
+		defer server.Close()
+
+		client := httpx.NewClientWithConfig(
+			httpx.WithClientDefaultBaseURL(server.URL),
+			httpx.WithClientDefaultCache(),
+		)
+
+		_, err := client.Execute(*httpx.NewRequest(http.MethodGet, httpx.WithPath("/file")), nil)
+		require.NoError(t, err)
+
+		resp, err := client.Execute(*httpx.NewRequest(http.MethodHead, httpx.WithPath("/file")), nil)
+
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "application/json", resp.Header().Get("Content-Type"))
+		assert.Equal(t, "11", resp.Header().Get("Content-Length"))
+		assert.Equal(t, int32(1), serverCalls.Load())
+	})
+
+	t.Run("caches HEAD responses", func(t *testing.T) {
+		t.Parallel()
+
+		var serverCalls atomic.Int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			serverCalls.Add(1)
+			assert.Equal(t, http.MethodHead, r.Method)
+			w.Header().Set("Cache-Control", "max-age=3600")
+			w.Header().Set("Content-Length", "42")
+		}))
+		defer server.Close()
+
+		client := httpx.NewClientWithConfig(
+			httpx.WithClientDefaultBaseURL(server.URL),
+			httpx.WithClientDefaultCache(),
+		)
+
+		for range 2 {
+			resp, err := client.Execute(*httpx.NewRequest(http.MethodHead, httpx.WithPath("/file")), nil)
+			require.NoError(t, err)
+			assert.Equal(t, "42", resp.Header().Get("Content-Length"))
+		}
+		assert.Equal(t, int32(1), serverCalls.Load())
+	})
+
+	t.Run("does not cache OPTIONS by default", func(t *testing.T) {
+		t.Parallel()
+
+		var serverCalls atomic.Int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			serverCalls.Add(1)
+			w.Header().Set("Access-Control-Max-Age", "600")
+			w.WriteHeader(http.StatusNoContent)
+		}))
+		defer server.Close()
+
+		client := httpx.NewClientWithConfig(
+			httpx.WithClientDefaultBaseURL(server.URL),
+			httpx.WithClientDefaultCache(),
+		)
+
+		for range 2 {
+			_, err := client.Execute(*httpx.NewRequest(http.MethodOptions, httpx.WithPath("/api")), nil)
+			require.NoError(t, err)
+		}
+		assert.Equal(t, int32(2), serverCalls.Load())
+	})
+
+	t.Run("caches preflight results per origin", func(t *testing.T) {
+		t.Parallel()
+
+		var serverCalls atomic.Int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			serverCalls.Add(1)
+			w.Header().Set("Access-Control-Allow-Origin", r.Header.Get("Origin"))
+			w.Header().Set("Access-Control-Allow-Methods", "PUT")
+			w.Header().Set("Access-Control-Max-Age", "600")
+			w.WriteHeader(http.StatusNoContent)
+		}))
+		defer server.Close()
+
+		client := httpx.NewClientWithConfig(
+			httpx.WithClientDefaultBaseURL(server.URL),
+			httpx.WithClientCache(httpx.CacheConfig{
+				CacheableMethods: []string{http.MethodGet, http.MethodHead, http.MethodOptions},
+			}),
+		)
+		preflight := func(origin string) *httpx.Response {
+			resp, err := client.Execute(*httpx.NewRequest(http.MethodOptions,
+				httpx.WithPath("/api"),
+				httpx.WithHeader("Origin", origin),
+				httpx.WithHeader("Access-Control-Request-Method", "PUT"),
+			), nil)
+			require.NoError(t, err)
+			return resp
+		}
+
+		preflight("https://a.example.com")
+		resp := preflight("https://a.example.com")
+		assert.Equal(t, "https://a.example.com", resp.Header().Get("Access-Control-Allow-Origin"))
+		assert.Equal(t, int32(1), serverCalls.Load())
+
+		preflight("https://b.example.com")
+		assert.Equal(t, int32(2), serverCalls.Load())
+	})
+
+	t.Run("does not cache preflight results with a zero max age", func(t *testing.T) {
+		t.Parallel()
+
+		var serverCalls atomic.Int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			serverCalls.Add(1)
+			w.Header().Set("Access-Control-Max-Age", "0")
+			w.WriteHeader(http.StatusNoContent)
+		}))
+		defer server.Close()
+
+		client := httpx.NewClientWithConfig(
+			httpx.WithClientDefaultBaseURL(server.URL),
+			httpx.WithClientCache(httpx.CacheConfig{CacheableMethods: []string{http.MethodOptions}}),
+		)
+
+		for range 2 {
+			_, err := client.Execute(*httpx.NewRequest(http.MethodOptions, httpx.WithPath("/api")), nil)
+			require.NoError(t, err)
+		}
+		assert.Equal(t, int32(2), serverCalls.Load())
+	})
+}
+
+func TestCacheMiddleware_Authorization(t *testing.T) {
+	t.Parallel()
+
+	// newServer answers with cacheControl and counts the requests reaching it
+	// Responses also carry an Expires date in the past, so they are only fresh through their directives.
+	newServer := func(t *testing.T, cacheControl string) (*httptest.Server, *atomic.Int32) {
+		t.Helper()
+		var requests atomic.Int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.Header().Set("Cache-Control", cacheControl)
+			w.Header().Set("Expires", "Thu, 01 Jan 1970 00:00:00 GMT")
+			_, _ = w.Write([]byte(fmt.Sprintf(`{"count":%d}`, requests.Add(1))))
+		}))
+		t.Cleanup(server.Close)
+		return server, &requests
+	}
+	get := func(t *testing.T, client *httpx.Client, authorization string) {
+		t.Helper()
+		opts := []httpx.RequestOption{httpx.WithPath("/data")}
+		if authorization != "" {
+			opts = append(opts, httpx.WithHeader("Authorization", authorization))
+		}
+		_, err := client.Execute(*httpx.NewRequest(http.MethodGet, opts...), map[string]any{})
+		require.NoError(t, err)
+	}
+
+	testCases := []struct {
+		name          string
+		cacheControl  string
+		authorization string
+		private       bool
+		wantRequests  int32
+	}{
+		{name: "does not store authenticated responses by default", cacheControl: "max-age=60", authorization: "Bearer alice", wantRequests: 2},
+		{name: "stores authenticated responses marked public", cacheControl: "public, max-age=60", authorization: "Bearer alice", wantRequests: 1},
+		{name: "stores authenticated responses with s-maxage", cacheControl: "s-maxage=60", authorization: "Bearer alice", wantRequests: 1},
+		{name: "stores authenticated responses with must-revalidate", cacheControl: "must-revalidate, max-age=60", authorization: "Bearer alice", wantRequests: 1},
+		{name: "does not store private responses", cacheControl: "private, max-age=60", wantRequests: 2},
+		{name: "stores anonymous responses", cacheControl: "max-age=60", wantRequests: 1},
+		{name: "private cache stores authenticated responses", cacheControl: "max-age=60", authorization: "Bearer alice", private: true, wantRequests: 1},
+		{name: "private cache stores private responses", cacheControl: "private, max-age=60", authorization: "Bearer alice", private: true, wantRequests: 1},
+		{name: "private cache ignores s-maxage", cacheControl: "s-maxage=60", authorization: "Bearer alice", private: true, wantRequests: 2},
+		{name: "private cache honors no-store", cacheControl: "no-store", authorization: "Bearer alice", private: true, wantRequests: 2},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			server, requests := newServer(t, tc.cacheControl)
+			client := httpx.NewClientWithConfig(
+				httpx.WithClientDefaultBaseURL(server.URL),
+				httpx.WithClientCache(httpx.CacheConfig{PrivateCache: tc.private, RefreshAhead: 0.9}),
+			)
+
+			get(t, client, tc.authorization)
+			get(t, client, tc.authorization)
+
+			assert.Equal(t, tc.wantRequests, requests.Load())
+		})
+	}
+
+	t.Run("prefers s-maxage over max-age", func(t *testing.T) {
+		t.Parallel()
+
+		server, requests := newServer(t, "public, max-age=0, s-maxage=60")
+		client := httpx.NewClientWithConfig(
+			httpx.WithClientDefaultBaseURL(server.URL),
+			httpx.WithClientCache(httpx.CacheConfig{RefreshAhead: 0.9}),
+		)
+
+		get(t, client, "Bearer alice")
+		get(t, client, "Bearer alice")
+
+		assert.Equal(t, int32(1), requests.Load())
+	})
+
+	t.Run("does not share authenticated responses between credentials", func(t *testing.T) {
+		t.Parallel()
+
+		server, requests := newServer(t, "public, max-age=60")
+		client := httpx.NewClientWithConfig(
+			httpx.WithClientDefaultBaseURL(server.URL),
+			httpx.WithClientCache(httpx.CacheConfig{RefreshAhead: 0.9}),
+		)
+
+		get(t, client, "Bearer alice")
+		get(t, client, "Bearer bob")
+
+		assert.Equal(t, int32(2), requests.Load())
+	})
+}
+
+func TestCacheMiddleware_Partitioning(t *testing.T) {
+	t.Parallel()
+
+	// newUserServer returns the caller's own data and revalidates any ETag, as a server with a shared ETag scheme would
+	newUserServer := func(t *testing.T) *httptest.Server {
+		t.Helper()
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("ETag", `"v1"`)
+			w.Header().Set("Cache-Control", "max-age=3600")
+			if r.Header.Get("If-None-Match") == `"v1"` {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			_, _ = w.Write([]byte(`{"user":"` + r.Header.Get("Authorization") + r.Header.Get("X-Tenant") + `"}`))
+		}))
+		t.Cleanup(server.Close)
+		return server
+	}
+
+	t.Run("does not share entries between credentials", func(t *testing.T) {
+		t.Parallel()
+
+		server := newUserServer(t)
+		client := httpx.NewClientWithConfig(
+			httpx.WithClientDefaultBaseURL(server.URL),
+			httpx.WithClientDefaultCache(),
+		)
+		get := func(authorization string) map[string]any {
+			resp, err := client.Execute(*httpx.NewRequest(http.MethodGet,
+				httpx.WithPath("/me"),
+				httpx.WithHeader("Authorization", authorization),
+			), map[string]any{})
+			require.NoError(t, err)
+			return resp.Body.(map[string]any)
+		}
+
+		assert.Equal(t, "alice", get("alice")["user"])
+		assert.Equal(t, "bob", get("bob")["user"])
+		assert.Equal(t, "alice", get("alice")["user"])
+	})
+
+	t.Run("uses a custom partition function", func(t *testing.T) {
+		t.Parallel()
+
+		server := newUserServer(t)
+		client := httpx.NewClientWithConfig(
+			httpx.WithClientDefaultBaseURL(server.URL),
+			httpx.WithClientCache(httpx.CacheConfig{
+				PartitionFunc: func(r *http.Request) string { return r.Header.Get("X-Tenant") },
+			}),
+		)
+		get := func(tenant string) map[string]any {
+			resp, err := client.Execute(*httpx.NewRequest(http.MethodGet,
+				httpx.WithPath("/me"),
+				httpx.WithHeader("X-Tenant", tenant),
+			), map[string]any{})
+			require.NoError(t, err)
+			return resp.Body.(map[string]any)
+		}
+
+		assert.Equal(t, "acme", get("acme")["user"])
+		assert.Equal(t, "globex", get("globex")["user"])
+	})
+
+	t.Run("hashes the Authorization header", func(t *testing.T) {
+		t.Parallel()
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		assert.Empty(t, httpx.AuthorizationPartition(req))
+
+		req.Header.Set("Authorization", "Bearer secret")
+		partition := httpx.AuthorizationPartition(req)
+		assert.Len(t, partition, 32)
+		assert.NotContains(t, partition, "secret")
+	})
+}
+
+func TestCacheMiddleware_RefreshAhead(t *testing.T) {
+	t.Parallel()
+
+	t.Run("serves fresh entries and refreshes them before they expire", func(t *testing.T) {
+		t.Parallel()
+
+		var requests atomic.Int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			version := requests.Add(1)
+			w.Header().Set("Cache-Control", "max-age=1")
+			_, _ = fmt.Fprintf(w, "v%d", version)
+		}))
+		defer server.Close()
+
+		client := httpx.NewClientWithConfig(
+			httpx.WithClientDefaultBaseURL(server.URL),
+			httpx.WithClientCache(httpx.CacheConfig{RefreshAhead: 0.5}),
+		)
+		get := func() string {
+			resp, err := client.Execute(*httpx.NewRequest(http.MethodGet, httpx.WithPath("/hot")), "")
+			require.NoError(t, err)
+			return resp.Body.(string)
+		}
+
+		assert.Equal(t, "v1", get())
+		assert.Equal(t, "v1", get(), "fresh entries are served without contacting the origin")
+		assert.Equal(t, int32(1), requests.Load())
+
+		time.Sleep(600 * time.Millisecond)
+		assert.Equal(t, "v1", get(), "the entry is still served while it is refreshed")
+		assert.Eventually(t, func() bool { return requests.Load() == 2 }, time.Second, 10*time.Millisecond)
+		assert.Eventually(t, func() bool { return get() == "v2" }, time.Second, 10*time.Millisecond)
+		assert.Equal(t, int32(2), requests.Load())
+	})
+
+	t.Run("renews entries revalidated with 304", func(t *testing.T) {
+		t.Parallel()
+
+		var requests, revalidations atomic.Int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests.Add(1)
+			w.Header().Set("Cache-Control", "max-age=1")
+			w.Header().Set("ETag", `"v1"`)
+			if r.Header.Get("If-None-Match") == `"v1"` {
+				revalidations.Add(1)
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			_, _ = w.Write([]byte("v1"))
+		}))
+		defer server.Close()
+
+		client := httpx.NewClientWithConfig(
+			httpx.WithClientDefaultBaseURL(server.URL),
+			httpx.WithClientCache(httpx.CacheConfig{RefreshAhead: 0.5}),
+		)
+		get := func() {
+			resp, err := client.Execute(*httpx.NewRequest(http.MethodGet), "")
+			require.NoError(t, err)
+			assert.Equal(t, "v1", resp.Body)
+		}
+
+		get()
+		time.Sleep(600 * time.Millisecond)
+		get()
+		require.Eventually(t, func() bool { return revalidations.Load() == 1 }, time.Second, 10*time.Millisecond)
+
+		// The renewed entry outlives the original one second lifetime
+		time.Sleep(500 * time.Millisecond)
+		get()
+		assert.Equal(t, int32(2), requests.Load())
+	})
+
+	t.Run("runs one refresh per entry at a time", func(t *testing.T) {
+		t.Parallel()
+
+		var requests atomic.Int32
+		release := make(chan struct{})
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			if requests.Add(1) > 1 {
+				<-release
+			}
+			w.Header().Set("Cache-Control", "max-age=1")
+			_, _ = w.Write([]byte("ok"))
+		}))
+		defer server.Close()
+
+		client := httpx.NewClientWithConfig(
+			httpx.WithClientDefaultBaseURL(server.URL),
+			httpx.WithClientCache(httpx.CacheConfig{RefreshAhead: 0.1}),
+		)
+		_, err := client.Execute(*httpx.NewRequest(http.MethodGet), "")
+		require.NoError(t, err)
+		time.Sleep(150 * time.Millisecond)
+
+		var wg sync.WaitGroup
+		for range 10 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, err := client.Execute(*httpx.NewRequest(http.MethodGet), "")
+				assert.NoError(t, err)
+			}()
+		}
+		wg.Wait()
+		assert.Eventually(t, func() bool { return requests.Load() == 2 }, time.Second, 10*time.Millisecond)
+		time.Sleep(50 * time.Millisecond)
+		close(release)
+
+		assert.Equal(t, int32(2), requests.Load())
+	})
+
+	t.Run("cancels refreshes when the base context is done", func(t *testing.T) {
+		t.Parallel()
+
+		var requests atomic.Int32
+		canceled := make(chan struct{})
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if requests.Add(1) > 1 {
+				<-r.Context().Done()
+				close(canceled)
+				return
+			}
+			w.Header().Set("Cache-Control", "max-age=1")
+			_, _ = w.Write([]byte("ok"))
+		}))
+		defer server.Close()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		client := httpx.NewClientWithConfig(
+			httpx.WithClientDefaultBaseURL(server.URL),
+			httpx.WithClientBaseContext(ctx),
+			httpx.WithClientCache(httpx.CacheConfig{RefreshAhead: 0.1}),
+		)
+		_, err := client.Execute(*httpx.NewRequest(http.MethodGet), "")
+		require.NoError(t, err)
+		time.Sleep(150 * time.Millisecond)
+		_, err = client.Execute(*httpx.NewRequest(http.MethodGet), "")
+		require.NoError(t, err)
+		require.Eventually(t, func() bool { return requests.Load() == 2 }, time.Second, 10*time.Millisecond)
+
+		cancel()
+
+		select {
+		case <-canceled:
+		case <-time.After(time.Second):
+			t.Fatal("the refresh was not canceled")
+		}
+	})
+
+	t.Run("does not refresh once the base context is done", func(t *testing.T) {
+		t.Parallel()
+
+		var requests atomic.Int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			requests.Add(1)
+			w.Header().Set("Cache-Control", "max-age=1")
+			_, _ = w.Write([]byte("ok"))
+		}))
+		defer server.Close()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		client := httpx.NewClientWithConfig(
+			httpx.WithClientDefaultBaseURL(server.URL),
+			httpx.WithClientBaseContext(ctx),
+			httpx.WithClientCache(httpx.CacheConfig{RefreshAhead: 0.1}),
+		)
+		_, err := client.Execute(*httpx.NewRequest(http.MethodGet), "")
+		require.NoError(t, err)
+		cancel()
+		time.Sleep(150 * time.Millisecond)
+
+		_, err = client.Execute(*httpx.NewRequest(http.MethodGet), "")
+		require.NoError(t, err)
+		time.Sleep(50 * time.Millisecond)
+
+		assert.Equal(t, int32(1), requests.Load())
+	})
+
+	t.Run("scopes refreshes of a shared middleware to the client sending the request", func(t *testing.T) {
+		t.Parallel()
+
+		var requests atomic.Int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			requests.Add(1)
+			w.Header().Set("Cache-Control", "max-age=1")
+			_, _ = w.Write([]byte("ok"))
+		}))
+		defer server.Close()
+
+		cache := httpx.NewCacheMiddleware(httpx.CacheConfig{RefreshAhead: 0.1})
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_ = httpx.NewClientWithConfig(httpx.WithClientBaseContext(ctx), httpx.WithClientMiddleware(cache))
+		client := httpx.NewClientWithConfig(
+			httpx.WithClientDefaultBaseURL(server.URL),
+			httpx.WithClientMiddleware(cache),
+		)
+		_, err := client.Execute(*httpx.NewRequest(http.MethodGet), "")
+		require.NoError(t, err)
+		time.Sleep(150 * time.Millisecond)
+
+		_, err = client.Execute(*httpx.NewRequest(http.MethodGet), "")
+		require.NoError(t, err)
+
+		require.Eventually(t, func() bool { return requests.Load() == 2 }, time.Second, 10*time.Millisecond)
+	})
+}
+
+func TestCacheMiddleware_CoalesceRequests(t *testing.T) {
+	t.Parallel()
+
+	// newSlowServer returns a server holding responses until release is closed
+	newSlowServer := func(cacheControl string) (*httptest.Server, *atomic.Int32, chan struct{}) {
+		var requests atomic.Int32
+		release := make(chan struct{})
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			requests.Add(1)
+			<-release
+			w.Header().Set("Cache-Control", cacheControl)
+			_, _ = w.Write([]byte("payload"))
+		}))
+		return server, &requests, release
+	}
+
+	t.Run("fetches an entry once for concurrent requests", func(t *testing.T) {
+		t.Parallel()
+
+		server, requests, release := newSlowServer("max-age=60")
+		defer server.Close()
+
+		client := httpx.NewClientWithConfig(
+			httpx.WithClientDefaultBaseURL(server.URL),
+			httpx.WithClientCache(httpx.CacheConfig{CoalesceRequests: true}),
+		)
+
+		var wg sync.WaitGroup
+		for range 20 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				resp, err := client.Execute(*httpx.NewRequest(http.MethodGet, httpx.WithPath("/popular")), "")
+				assert.NoError(t, err)
+				assert.Equal(t, "payload", resp.Body)
+			}()
+		}
+		require.Eventually(t, func() bool { return requests.Load() == 1 }, time.Second, 5*time.Millisecond)
+		time.Sleep(50 * time.Millisecond) // Let the other requests queue up behind the first
+		close(release)
+		wg.Wait()
+
+		assert.Equal(t, int32(1), requests.Load())
+	})
+
+	t.Run("waiters fetch themselves when the response is not cacheable", func(t *testing.T) {
+		t.Parallel()
+
+		server, requests, release := newSlowServer("no-store")
+		defer server.Close()
+
+		client := httpx.NewClientWithConfig(
+			httpx.WithClientDefaultBaseURL(server.URL),
+			httpx.WithClientCache(httpx.CacheConfig{CoalesceRequests: true}),
+		)
+
+		var wg sync.WaitGroup
+		for range 3 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				resp, err := client.Execute(*httpx.NewRequest(http.MethodGet), "")
+				assert.NoError(t, err)
+				assert.Equal(t, "payload", resp.Body)
+			}()
+		}
+		require.Eventually(t, func() bool { return requests.Load() == 1 }, time.Second, 5*time.Millisecond)
+		close(release)
+		wg.Wait()
+
+		assert.Equal(t, int32(3), requests.Load())
+	})
+
+	t.Run("waiters give up when their context ends", func(t *testing.T) {
+		t.Parallel()
+
+		server, requests, release := newSlowServer("max-age=60")
+		defer server.Close()
+		defer close(release)
+
+		client := httpx.NewClientWithConfig(
+			httpx.WithClientDefaultBaseURL(server.URL),
+			httpx.WithClientCache(httpx.CacheConfig{CoalesceRequests: true}),
+		)
+
+		go func() {
+			_, _ = client.Execute(*httpx.NewRequest(http.MethodGet), "")
+		}()
+		require.Eventually(t, func() bool { return requests.Load() == 1 }, time.Second, 5*time.Millisecond)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		_, err := client.Execute(*httpx.NewRequest(http.MethodGet, httpx.WithContext(ctx)), "")
+
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.Equal(t, int32(1), requests.Load())
+	})
+
+	t.Run("does not coalesce requests of different partitions", func(t *testing.T) {
+		t.Parallel()
+
+		server, requests, release := newSlowServer("max-age=60")
+		defer server.Close()
+
+		client := httpx.NewClientWithConfig(
+			httpx.WithClientDefaultBaseURL(server.URL),
+			httpx.WithClientCache(httpx.CacheConfig{CoalesceRequests: true}),
+		)
+
+		var wg sync.WaitGroup
+		for _, token := range []string{"alice", "bob"} {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, err := client.Execute(*httpx.NewRequest(http.MethodGet, httpx.WithHeader("Authorization", "Bearer "+token)), "")
+				assert.NoError(t, err)
+			}()
+		}
+		require.Eventually(t, func() bool { return requests.Load() == 2 }, time.Second, 5*time.Millisecond)
+		close(release)
+		wg.Wait()
+	})
+}
+
+func TestCacheMiddleware_StatsGroups(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=60")
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+	host := strings.TrimPrefix(server.URL, "http://")
+
+	registry := prometheus.NewRegistry()
+	config := httpx.DefaultPrometheusConfig()
+	config.Registry = registry
+	client := httpx.NewClientWithConfig(
+		httpx.WithClientDefaultBaseURL(server.URL),
+		httpx.WithClientPrometheusMetrics(config),
+		httpx.WithClientCache(httpx.CacheConfig{RefreshAhead: 0.9, StatsGroup: httpx.CacheStatsByPathPrefix(1)}),
+	)
+	cache := client.Cache()
+
+	for _, p := range []string{"/users/1", "/users/1", "/users/2", "/orders", "/orders", "/orders"} {
+		_, err := client.Execute(*httpx.NewRequest(http.MethodGet, httpx.WithPath(p)), nil)
+		require.NoError(t, err)
+	}
+
+	t.Run("breaks stats down by group", func(t *testing.T) {
+		groups := cache.Stats().Groups
+		require.Len(t, groups, 2)
+
+		users := groups[host+"/users"]
+		assert.Equal(t, int64(1), users.Hits)
+		assert.Equal(t, int64(2), users.Misses)
+		assert.Equal(t, int64(2), users.Stores)
+		orders := groups[host+"/orders"]
+		assert.Equal(t, int64(2), orders.Hits)
+		assert.Equal(t, int64(1), orders.Misses)
+		assert.Equal(t, int64(1), orders.Stores)
+	})
+
+	t.Run("records entry ages and lifetimes", func(t *testing.T) {
+		orders := cache.Stats().Groups[host+"/orders"]
+
+		assert.Equal(t, int64(2), orders.Age.Count)
+		assert.Equal(t, int64(2), orders.Age.Counts[0], "entries served right after being stored are under a second old")
+		assert.Less(t, orders.Age.Mean(), time.Second)
+		assert.Equal(t, int64(1), orders.TTL.Count)
+		assert.Equal(t, int64(1), orders.TTL.Counts[2], "max-age=60 falls in the (10s, 1m] bucket")
+	})
+
+	t.Run("exports lookups to Prometheus", func(t *testing.T) {
+		families, err := registry.Gather()
+		require.NoError(t, err)
+		lookups := map[string]float64{}
+		for _, family := range families {
+			if family.GetName() != "http_client_cache_lookups_total" {
+				continue
+			}
+			for _, metric := range family.GetMetric() {
+				labels := map[string]string{}
+				for _, label := range metric.GetLabel() {
+					labels[label.GetName()] = label.GetValue()
+				}
+				lookups[labels["group"]+","+labels["result"]] = metric.GetCounter().GetValue()
+			}
+		}
+
+		assert.Equal(t, map[string]float64{
+			host + "/users,hit":   1,
+			host + "/users,miss":  2,
+			host + "/orders,hit":  2,
+			host + "/orders,miss": 1,
+		}, lookups)
+	})
+
+	t.Run("reports to the observer of its own config", func(t *testing.T) {
+		observer := &cacheLookupRecorder{}
+		cache := httpx.NewCacheMiddleware(httpx.CacheConfig{RefreshAhead: 0.9, Observer: observer})
+		client := httpx.NewClientWithConfig(
+			httpx.WithClientDefaultBaseURL(server.URL),
+			httpx.WithClientMiddleware(cache),
+		)
+
+		for range 2 {
+			_, err := client.Execute(*httpx.NewRequest(http.MethodGet), nil)
+			require.NoError(t, err)
+		}
+
+		assert.Equal(t, []bool{false, true}, observer.list())
+	})
+}
+
+// cacheLookupRecorder records whether the lookups it is notified of hit
+type cacheLookupRecorder struct {
+	mu   sync.Mutex
+	hits []bool
+}
+
+func (r *cacheLookupRecorder) ObserveCacheLookup(_ string, hit bool, _ time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.hits = append(r.hits, hit)
+}
+
+func (r *cacheLookupRecorder) ObserveCacheStore(string, time.Duration) {}
+
+func (r *cacheLookupRecorder) list() []bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]bool(nil), r.hits...)
+}
+
+func TestCacheMiddleware_StaleContent(t *testing.T) {
+	t.Parallel()
+
+	t.Run("serves stale entries while revalidating them in the background", func(t *testing.T) {
+		t.Parallel()
+
+		var requests atomic.Int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			version := requests.Add(1)
+			w.Header().Set("Cache-Control", "max-age=1, stale-while-revalidate=30")
+			_, _ = fmt.Fprintf(w, "v%d", version)
+		}))
+		defer server.Close()
+
+		client := httpx.NewClientWithConfig(
+			httpx.WithClientDefaultBaseURL(server.URL),
+			httpx.WithClientCache(httpx.CacheConfig{RevalidateAsync: true}),
+		)
+		get := func() *httpx.Response {
+			resp, err := client.Execute(*httpx.NewRequest(http.MethodGet), "")
+			require.NoError(t, err)
+			return resp
+		}
+
+		first := get()
+		assert.False(t, first.FromCache())
+		fresh := get()
+		assert.True(t, fresh.FromCache())
+		assert.False(t, fresh.Stale())
+		assert.Equal(t, int32(1), requests.Load())
+
+		time.Sleep(1100 * time.Millisecond)
+		stale := get()
+		assert.Equal(t, "v1", stale.Body)
+		assert.True(t, stale.Stale())
+		assert.Eventually(t, func() bool { return get().Body == "v2" }, time.Second, 10*time.Millisecond)
+		assert.Equal(t, int32(2), requests.Load())
+	})
+
+	t.Run("serves stale entries when the origin fails", func(t *testing.T) {
+		t.Parallel()
+
+		var failing atomic.Bool
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			if failing.Load() {
+				w.WriteHeader(http.StatusBadGateway)
+				return
+			}
+			w.Header().Set("Cache-Control", "max-age=1")
+			_, _ = w.Write([]byte("ok"))
+		}))
+		defer server.Close()
+
+		client := httpx.NewClientWithConfig(
+			httpx.WithClientDefaultBaseURL(server.URL),
+			httpx.WithClientCache(httpx.CacheConfig{MaxStaleOnError: time.Minute}),
+		)
+		_, err := client.Execute(*httpx.NewRequest(http.MethodGet), "")
+		require.NoError(t, err)
+		failing.Store(true)
+		time.Sleep(1100 * time.Millisecond)
+
+		resp, err := client.Execute(*httpx.NewRequest(http.MethodGet), "")
+
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "ok", resp.Body)
+		assert.True(t, resp.FromCache())
+		assert.True(t, resp.Stale())
+	})
+
+	t.Run("serves stale entries on network errors within stale-if-error", func(t *testing.T) {
+		t.Parallel()
+
+		var calls atomic.Int32
+		cache := httpx.NewCacheMiddleware(httpx.CacheConfig{})
+		next := func(_ context.Context, req *http.Request) (*http.Response, error) {
+			if calls.Add(1) > 1 {
+				return nil, fmt.Errorf("connection refused")
+			}
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Header:     http.Header{"Cache-Control": {"max-age=0, stale-if-error=60"}},
+				Body:       http.NoBody,
+				Request:    req,
+			}, nil
+		}
+		req := httptest.NewRequest(http.MethodGet, "http://example.com/resource", nil)
+
+		_, err := cache.Execute(context.Background(), req, next)
+		require.NoError(t, err)
+		resp, err := cache.Execute(context.Background(), req.Clone(context.Background()), next)
+
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, err = cache.Execute(ctx, req.Clone(ctx), next)
+		assert.Error(t, err, "canceled requests are not answered with stale content")
+	})
+
+	t.Run("does not serve stale entries that must be revalidated", func(t *testing.T) {
+		t.Parallel()
+
+		var failing atomic.Bool
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			if failing.Load() {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			w.Header().Set("Cache-Control", "max-age=1, must-revalidate, stale-if-error=60")
+			_, _ = w.Write([]byte("ok"))
+		}))
+		defer server.Close()
+
+		client := httpx.NewClientWithConfig(
+			httpx.WithClientDefaultBaseURL(server.URL),
+			httpx.WithClientCache(httpx.CacheConfig{MaxStaleOnError: time.Minute}),
+		)
+		_, err := client.Execute(*httpx.NewRequest(http.MethodGet), "")
+		require.NoError(t, err)
+		failing.Store(true)
+		time.Sleep(1100 * time.Millisecond)
+
+		resp, err := client.Execute(*httpx.NewRequest(http.MethodGet), "")
+
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+		assert.False(t, resp.FromCache())
+	})
+}
+
+func TestCacheMiddleware_CacheStatus(t *testing.T) {
+	t.Parallel()
+
+	t.Run("reports how each response was served", func(t *testing.T) {
+		t.Parallel()
+
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("ETag", `"v1"`)
+			w.Header().Set("Cache-Control", "max-age=60")
+			if r.Header.Get("If-None-Match") == `"v1"` {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			_, _ = w.Write([]byte("ok"))
+		}))
+		defer server.Close()
+
+		client := httpx.NewClientWithConfig(
+			httpx.WithClientDefaultBaseURL(server.URL),
+			httpx.WithClientCache(httpx.CacheConfig{StatusHeader: "X-Cache"}),
+		)
+		send := func(method, path string) *httpx.Response {
+			resp, err := client.Execute(*httpx.NewRequest(method, httpx.WithPath(path)), "")
+			require.NoError(t, err)
+			return resp
+		}
+
+		testCases := []struct {
+			name     string
+			method   string
+			path     string
+			expected httpx.CacheStatus
+		}{
+			{name: "first request", method: http.MethodGet, path: "/items", expected: httpx.CacheMiss},
+			{name: "revalidated entry", method: http.MethodGet, path: "/items", expected: httpx.CacheRevalidated},
+			{name: "HEAD answered from a fresh entry", method: http.MethodHead, path: "/items", expected: httpx.CacheHit},
+			{name: "uncacheable method", method: http.MethodPost, path: "/items", expected: httpx.CacheBypass},
+		}
+		for _, tc := range testCases {
+			resp := send(tc.method, tc.path)
+
+			assert.Equal(t, tc.expected, resp.CacheStatus(), tc.name)
+			assert.Equal(t, string(tc.expected), resp.GetHeader("X-Cache"), tc.name)
+			assert.Equal(t, tc.expected.FromCache(), resp.FromCache(), tc.name)
+		}
+	})
+
+	t.Run("is empty without caching", func(t *testing.T) {
+		t.Parallel()
+
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			_, _ = w.Write([]byte("ok"))
+		}))
+		defer server.Close()
+		client := httpx.NewClientWithConfig(httpx.WithClientDefaultBaseURL(server.URL))
+
+		resp, err := client.Execute(*httpx.NewRequest(http.MethodGet), "")
+
+		require.NoError(t, err)
+		assert.Empty(t, resp.CacheStatus())
+		assert.False(t, resp.FromCache())
+	})
+}
+
+func TestCacheMiddleware_TTLRules(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/headers/item" {
+			w.Header().Set("Cache-Control", "max-age=60")
+		}
+		if r.URL.Path == "/private/item" {
+			w.Header().Set("Cache-Control", "no-store")
+		}
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	cache := httpx.NewInMemoryCache(10)
+	client := httpx.NewClientWithConfig(
+		httpx.WithClientDefaultBaseURL(server.URL),
+		httpx.WithClientCache(httpx.CacheConfig{
+			Backend:    cache,
+			DefaultTTL: time.Minute,
+			TTLRules: []httpx.CacheTTLRule{
+				{PathPattern: "/legacy/*", TTL: time.Hour},
+				{PathPattern: "/headers/*", TTL: 10 * time.Second, Override: true},
+				{PathPattern: "/private/*", TTL: time.Hour, Override: true},
+			},
+		}),
+	)
+
+	testCases := []struct {
+		name     string
+		path     string
+		expected time.Duration
+	}{
+		{name: "rule replaces the default TTL", path: "/legacy/catalog", expected: time.Hour},
+		{name: "overriding rule replaces the server freshness", path: "/headers/item", expected: 10 * time.Second},
+		{name: "paths without rule keep the default TTL", path: "/other", expected: time.Minute},
+		{name: "nested paths do not match a single segment", path: "/legacy/catalog/items", expected: time.Minute},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := client.Execute(*httpx.NewRequest(http.MethodGet, httpx.WithPath(tc.path)), "")
+			require.NoError(t, err)
+
+			cached, ok := cache.Get("GET:" + server.URL + tc.path)
+			require.True(t, ok)
+			assert.InDelta(t, tc.expected, cached.ExpiresAt.Sub(cached.CachedAt), float64(time.Second))
+		})
+	}
+
+	t.Run("rules do not store responses forbidden to cache", func(t *testing.T) {
+		_, err := client.Execute(*httpx.NewRequest(http.MethodGet, httpx.WithPath("/private/item")), "")
+		require.NoError(t, err)
+
+		_, ok := cache.Get("GET:" + server.URL + "/private/item")
+		assert.False(t, ok)
+	})
+}
+
+func TestCacheMiddleware_Vary(t *testing.T) {
+	t.Parallel()
+
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Header().Set("Cache-Control", "max-age=60")
+		if r.URL.Path == "/any" {
+			w.Header().Set("Vary", "*")
+		} else {
+			w.Header().Add("Vary", "Accept")
+			w.Header().Add("Vary", "X-Locale")
+		}
+		w.Header().Set("Content-Type", "text/plain")
+		_, _ = fmt.Fprintf(w, "%s %s", r.Header.Get("Accept"), r.Header.Get("X-Locale"))
+	}))
+	defer server.Close()
+
+	newClient := func(keyFunc func(*http.Request) string) *httpx.Client {
+		return httpx.NewClientWithConfig(
+			httpx.WithClientDefaultBaseURL(server.URL),
+			httpx.WithClientCache(httpx.CacheConfig{RefreshAhead: 0.9, KeyFunc: keyFunc}),
+		)
+	}
+	get := func(t *testing.T, client *httpx.Client, path string, options ...httpx.RequestOption) (string, httpx.CacheStatus) {
+		resp, err := client.Execute(*httpx.NewRequest(http.MethodGet, append(options, httpx.WithPath(path))...), "")
+		require.NoError(t, err)
+		return resp.Body.(string), resp.CacheStatus()
+	}
+
+	t.Run("stores a response per variant", func(t *testing.T) {
+		client := newClient(nil)
+		requests.Store(0)
+
+		testCases := []struct {
+			headers  http.Header
+			expected string
+			status   httpx.CacheStatus
+		}{
+			{headers: http.Header{"Accept": {"application/json"}}, expected: "application/json ", status: httpx.CacheMiss},
+			{headers: http.Header{"Accept": {"text/csv"}}, expected: "text/csv ", status: httpx.CacheMiss},
+			{headers: http.Header{"Accept": {"application/json"}, "X-Locale": {"fr"}}, expected: "application/json fr", status: httpx.CacheMiss},
+			{headers: http.Header{"Accept": {"application/json"}}, expected: "application/json ", status: httpx.CacheHit},
+			{headers: http.Header{"Accept": {"text/csv"}}, expected: "text/csv ", status: httpx.CacheHit},
+		}
+		for _, tc := range testCases {
+			body, status := get(t, client, "/report", httpx.WithHeaders(tc.headers))
+
+			assert.Equal(t, tc.expected, body)
+			assert.Equal(t, tc.status, status, tc.headers)
+		}
+		assert.Equal(t, int32(3), requests.Load())
+	})
+
+	t.Run("counts a request for a variant once", func(t *testing.T) {
+		client := newClient(nil)
+
+		_, _ = get(t, client, "/report", httpx.WithHeader("Accept", "application/json"))
+		_, _ = get(t, client, "/report", httpx.WithHeader("Accept", "application/json"))
+		_, _ = get(t, client, "/report", httpx.WithHeader("Accept", "text/csv"))
+
+		stats := client.Cache().Stats()
+		assert.Equal(t, int64(1), stats.Hits)
+		assert.Equal(t, int64(2), stats.Misses)
+	})
+
+	t.Run("does not store responses varying on everything", func(t *testing.T) {
+		client := newClient(nil)
+
+		_, _ = get(t, client, "/any")
+		_, status := get(t, client, "/any")
+
+		assert.Equal(t, httpx.CacheMiss, status)
+	})
+
+	t.Run("builds keys with KeyFunc", func(t *testing.T) {
+		client := newClient(func(req *http.Request) string {
+			u := *req.URL
+			query := u.Query()
+			query.Del("utm_source")
+			u.RawQuery = query.Encode()
+			return httpx.DefaultCacheKey(&http.Request{Method: req.Method, URL: &u})
+		})
+
+		_, _ = get(t, client, "/report", httpx.WithQueryParam("utm_source", "mail"), httpx.WithHeader("Accept", "text/csv"))
+		body, status := get(t, client, "/report", httpx.WithQueryParam("utm_source", "web"), httpx.WithHeader("Accept", "text/csv"))
+
+		assert.Equal(t, "text/csv ", body)
+		assert.Equal(t, httpx.CacheHit, status)
+	})
+}
+
+func TestCacheMiddleware_RequestOptions(t *testing.T) {
+	t.Parallel()
+
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := requests.Add(1)
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Header().Set("ETag", `"v1"`)
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		_, _ = fmt.Fprintf(w, "response %d", n)
+	}))
+	defer server.Close()
+
+	cache := httpx.NewInMemoryCache(10)
+	client := httpx.NewClientWithConfig(
+		httpx.WithClientDefaultBaseURL(server.URL),
+		httpx.WithClientCache(httpx.CacheConfig{Backend: cache, RefreshAhead: 0.9}),
+	)
+	get := func(t *testing.T, path string, options ...httpx.RequestOption) (string, httpx.CacheStatus) {
+		resp, err := client.Execute(*httpx.NewRequest(http.MethodGet, append(options, httpx.WithPath(path))...), "")
+		require.NoError(t, err)
+		return resp.Body.(string), resp.CacheStatus()
+	}
+
+	t.Run("bypasses the cache", func(t *testing.T) {
+		cached, _ := get(t, "/no-cache")
+
+		body, status := get(t, "/no-cache", httpx.WithNoCache())
+
+		assert.NotEqual(t, cached, body)
+		assert.Equal(t, httpx.CacheBypass, status)
+		_, _ = get(t, "/uncached", httpx.WithNoCache())
+		_, ok := cache.Get("GET:" + server.URL + "/uncached")
+		assert.False(t, ok)
+	})
+
+	t.Run("revalidates fresh responses", func(t *testing.T) {
+		cached, _ := get(t, "/revalidate")
+		requests.Store(0)
+
+		body, status := get(t, "/revalidate", httpx.WithForceRevalidate())
+
+		assert.Equal(t, cached, body)
+		assert.Equal(t, httpx.CacheRevalidated, status)
+		assert.Equal(t, int32(1), requests.Load())
+	})
+
+	t.Run("overrides the TTL", func(t *testing.T) {
+		_, _ = get(t, "/ttl", httpx.WithCacheTTL(time.Hour))
+
+		cached, ok := cache.Get("GET:" + server.URL + "/ttl")
+		require.True(t, ok)
+		assert.InDelta(t, time.Hour, cached.ExpiresAt.Sub(cached.CachedAt), float64(time.Second))
+	})
+
+	t.Run("uses a custom key", func(t *testing.T) {
+		first, _ := get(t, "/reports/2026", httpx.WithCacheKey("latest-report"))
+
+		body, status := get(t, "/reports/latest", httpx.WithCacheKey("latest-report"))
+
+		assert.Equal(t, first, body)
+		assert.Equal(t, httpx.CacheHit, status)
+	})
+}
+
+// blockingCacheBackend is an in-memory backend whose writes wait until release is closed
+type blockingCacheBackend struct {
+	*httpx.InMemoryCache
+	release chan struct{}
+
+	mu     sync.Mutex
+	writes map[string]int // Writes started, by path of the key
+}
+
+func newBlockingCacheBackend() *blockingCacheBackend {
+	return &blockingCacheBackend{
+		InMemoryCache: httpx.NewInMemoryCache(100),
+		release:       make(chan struct{}),
+		writes:        make(map[string]int),
+	}
+}
+
+func (b *blockingCacheBackend) Set(key string, response *httpx.CachedResponse) error {
+	b.mu.Lock()
+	b.writes[key[strings.LastIndex(key, "/"):]]++
+	b.mu.Unlock()
+	<-b.release
+	return b.InMemoryCache.Set(key, response)
+}
+
+func (b *blockingCacheBackend) started(path string) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.writes[path]
+}
+
+func TestCacheMiddleware_WriteBehind(t *testing.T) {
+	t.Parallel()
+
+	newServer := func() (*httptest.Server, *atomic.Int32) {
+		var requests atomic.Int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests.Add(1)
+			w.Header().Set("Cache-Control", "max-age=60")
+			_, _ = w.Write([]byte("payload of " + r.URL.Path))
+		}))
+		return server, &requests
+	}
+	get := func(t *testing.T, client *httpx.Client, path string, opts ...httpx.RequestOption) {
+		resp, err := client.Execute(*httpx.NewRequest(http.MethodGet, append(opts, httpx.WithPath(path))...), "")
+		require.NoError(t, err)
+		assert.Equal(t, "payload of "+path, resp.Body)
+	}
+
+	t.Run("responses do not wait for the backend and answer lookups until stored", func(t *testing.T) {
+		t.Parallel()
+
+		server, requests := newServer()
+		defer server.Close()
+		backend := newBlockingCacheBackend()
+		client := httpx.NewClientWithConfig(
+			httpx.WithClientDefaultBaseURL(server.URL),
+			httpx.WithClientCache(httpx.CacheConfig{Backend: backend, RevalidateAsync: true, WriteBehind: true}),
+		)
+
+		get(t, client, "/users")
+		get(t, client, "/users")
+		assert.Equal(t, int32(1), requests.Load())
+
+		close(backend.release)
+		require.NoError(t, client.Cache().Flush(context.Background()))
+		assert.Equal(t, 1, backend.started("/users"))
+		assert.Equal(t, int64(1), backend.Stats().Size)
+		get(t, client, "/users")
+		assert.Equal(t, int32(1), requests.Load())
+	})
+
+	t.Run("writes of an entry pending at once reach the backend once", func(t *testing.T) {
+		t.Parallel()
+
+		server, requests := newServer()
+		defer server.Close()
+		backend := newBlockingCacheBackend()
+		client := httpx.NewClientWithConfig(
+			httpx.WithClientDefaultBaseURL(server.URL),
+			httpx.WithClientCache(httpx.CacheConfig{Backend: backend, WriteBehind: true}),
+		)
+
+		get(t, client, "/warmup")
+		require.Eventually(t, func() bool { return backend.started("/warmup") == 1 }, time.Second, 5*time.Millisecond)
+		for range 5 {
+			get(t, client, "/popular", httpx.WithForceRevalidate())
+		}
+		assert.Equal(t, int32(6), requests.Load())
+
+		close(backend.release)
+		require.NoError(t, client.Cache().Flush(context.Background()))
+		assert.Equal(t, 1, backend.started("/popular"))
+	})
+
+	t.Run("responses are not cached while the queue is full", func(t *testing.T) {
+		t.Parallel()
+
+		server, requests := newServer()
+		defer server.Close()
+		backend := newBlockingCacheBackend()
+		client := httpx.NewClientWithConfig(
+			httpx.WithClientDefaultBaseURL(server.URL),
+			httpx.WithClientCache(httpx.CacheConfig{
+				Backend:         backend,
+				RevalidateAsync: true,
+				WriteBehind:     true,
+				WriteQueueSize:  1,
+			}),
+		)
+
+		get(t, client, "/a")
+		require.Eventually(t, func() bool { return backend.started("/a") == 1 }, time.Second, 5*time.Millisecond)
+		get(t, client, "/b")
+		get(t, client, "/c")
+		assert.Equal(t, int64(1), client.Cache().Stats().DroppedWrites)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		assert.ErrorIs(t, client.Cache().Flush(ctx), context.DeadlineExceeded)
+
+		close(backend.release)
+		require.NoError(t, client.Cache().Flush(context.Background()))
+		assert.Equal(t, 0, backend.started("/c"))
+		get(t, client, "/b")
+		get(t, client, "/c")
+		assert.Equal(t, int32(4), requests.Load())
+	})
+}
@@ -0,0 +1,188 @@
+package httpx
+
+import (
+	"context"
+	"net/http"
+	"path"
+	"slices"
+	"strings"
+	"time"
+)
+
+// cacheAgeBuckets are the upper bounds of the age and TTL histograms of cache statistics
+var cacheAgeBuckets = []time.Duration{
+	time.Second, 10 * time.Second, time.Minute, 5 * time.Minute, 15 * time.Minute, time.Hour, 6 * time.Hour, 24 * time.Hour,
+}
+
+// CacheObserver is notified of the lookups and stores of a CacheMiddleware, grouped by CacheConfig.StatsGroup
+// Observers are called synchronously on the request path and must not block.
+type CacheObserver interface {
+	// ObserveCacheLookup reports a cacheable request, answered from an entry of the given age on a hit
+	ObserveCacheLookup(group string, hit bool, age time.Duration)
+	// ObserveCacheStore reports a response stored, or renewed by revalidation, fresh for ttl
+	ObserveCacheStore(group string, ttl time.Duration)
+}
+
+// CacheGroupStats holds the cache statistics of a group of requests, see CacheConfig.StatsGroup
+type CacheGroupStats struct {
+	Hits   int64             // Requests answered from the cache, after revalidation included
+	Misses int64             // Requests answered by the origin
+	Stores int64             // Responses stored or renewed by revalidation
+	Age    DurationHistogram // Age of the entries answering hits
+	TTL    DurationHistogram // Freshness lifetime of the entries stored
+}
+
+// DurationHistogram counts durations in buckets bounded by Bounds
+type DurationHistogram struct {
+	Bounds []time.Duration // Upper bounds of the buckets, inclusive
+	Counts []int64         // Durations per bucket, the last one counting those above every bound
+	Sum    time.Duration
+	Count  int64
+}
+
+// newDurationHistogram returns an empty histogram with the given bucket bounds
+func newDurationHistogram(bounds []time.Duration) DurationHistogram {
+	return DurationHistogram{Bounds: bounds, Counts: make([]int64, len(bounds)+1)}
+}
+
+// Mean returns the mean duration observed, 0 if there is none
+func (h DurationHistogram) Mean() time.Duration {
+	if h.Count == 0 {
+		return 0
+	}
+	return h.Sum / time.Duration(h.Count)
+}
+
+// observe counts a duration
+func (h *DurationHistogram) observe(d time.Duration) {
+	i, _ := slices.BinarySearch(h.Bounds, d)
+	h.Counts[i]++
+	h.Sum += d
+	h.Count++
+}
+
+// clone returns a copy of the histogram sharing nothing mutable with it
+func (h DurationHistogram) clone() DurationHistogram {
+	h.Counts = slices.Clone(h.Counts)
+	return h
+}
+
+// CacheStatsByHost groups cache statistics by host, see CacheConfig.StatsGroup
+func CacheStatsByHost(req *http.Request) string {
+	return CanonicalRequestOf(req).URL.Host
+}
+
+// CacheStatsByPathPrefix groups cache statistics by host and the first segments of the path, or of the path
+// template of requests built with path parameters, e.g. "api.example.com/v1/users" for 2 segments
+func CacheStatsByPathPrefix(segments int) func(*http.Request) string {
+	return func(req *http.Request) string {
+		u := CanonicalRequestOf(req).URL
+		p := u.Path
+		if template := PathTemplateFromContext(req.Context()); template != "" {
+			p = path.Join("/", template)
+		}
+		parts := strings.Split(strings.Trim(p, "/"), "/")
+		return u.Host + "/" + strings.Join(parts[:min(segments, len(parts))], "/")
+	}
+}
+
+// cacheLookupKey is the context key of the cacheLookup of a request going through a CacheMiddleware
+type cacheLookupKey struct{}
+
+// cacheLookup records whether a request was answered from the cache
+type cacheLookup struct {
+	hit bool
+	age time.Duration
+}
+
+// cacheStats holds the per-group statistics of a CacheMiddleware, guarded by its mutex
+type cacheStats map[string]*CacheGroupStats
+
+// tracksStats reports whether lookups and stores are recorded
+func (m *CacheMiddleware) tracksStats() bool {
+	return m.config.StatsGroup != nil || m.config.Observer != nil
+}
+
+// statsGroup returns the statistics group of req
+func (m *CacheMiddleware) statsGroup(req *http.Request) string {
+	if m.config.StatsGroup == nil {
+		return ""
+	}
+	return m.config.StatsGroup(req)
+}
+
+// trackLookup returns a context recording whether the request is answered from the cache, and a function
+// recording the lookup once the request is answered
+func (m *CacheMiddleware) trackLookup(ctx context.Context, req *http.Request) (context.Context, func()) {
+	if !m.tracksStats() {
+		return ctx, func() {}
+	}
+	lookup := &cacheLookup{}
+	return context.WithValue(ctx, cacheLookupKey{}, lookup), func() {
+		group := m.statsGroup(req)
+		if m.config.StatsGroup != nil {
+			m.mu.Lock()
+			stats := m.groupStats(group)
+			if lookup.hit {
+				stats.Hits++
+				stats.Age.observe(lookup.age)
+			} else {
+				stats.Misses++
+			}
+			m.mu.Unlock()
+		}
+		if m.config.Observer != nil {
+			m.config.Observer.ObserveCacheLookup(group, lookup.hit, lookup.age)
+		}
+	}
+}
+
+// recordHit marks the request of ctx as answered from a cached entry
+func recordHit(ctx context.Context, cached *CachedResponse) {
+	if lookup, ok := ctx.Value(cacheLookupKey{}).(*cacheLookup); ok {
+		lookup.hit, lookup.age = true, max(time.Since(cached.CachedAt), 0)
+	}
+}
+
+// recordStore records a response to req stored until expiresAt
+func (m *CacheMiddleware) recordStore(req *http.Request, expiresAt time.Time) {
+	if !m.tracksStats() {
+		return
+	}
+	group := m.statsGroup(req)
+	ttl := max(time.Until(expiresAt), 0)
+	if m.config.StatsGroup != nil {
+		m.mu.Lock()
+		stats := m.groupStats(group)
+		stats.Stores++
+		stats.TTL.observe(ttl)
+		m.mu.Unlock()
+	}
+	if m.config.Observer != nil {
+		m.config.Observer.ObserveCacheStore(group, ttl)
+	}
+}
+
+// groupStats returns the statistics of a group, creating them if needed; the caller holds m.mu
+func (m *CacheMiddleware) groupStats(group string) *CacheGroupStats {
+	stats, ok := m.groups[group]
+	if !ok {
+		stats = &CacheGroupStats{Age: newDurationHistogram(cacheAgeBuckets), TTL: newDurationHistogram(cacheAgeBuckets)}
+		m.groups[group] = stats
+	}
+	return stats
+}
+
+// snapshot returns a copy of the per-group statistics, nil if there are none; the caller holds m.mu
+func (s cacheStats) snapshot() map[string]CacheGroupStats {
+	if len(s) == 0 {
+		return nil
+	}
+	groups := make(map[string]CacheGroupStats, len(s))
+	for group, stats := range s {
+		stats := *stats
+		stats.Age, stats.TTL = stats.Age.clone(), stats.TTL.clone()
+		groups[group] = stats
+	}
+	return groups
+}
@@ -0,0 +1,49 @@
+package httpx
+
+import "context"
+
+// CacheStatus is how the cache middleware answered a request, see Response.CacheStatus
+type CacheStatus string
+
+// Cache statuses
+const (
+	CacheHit         CacheStatus = "HIT"         // Served from a fresh cache entry
+	CacheMiss        CacheStatus = "MISS"        // Fetched from the origin, and stored if cacheable
+	CacheRevalidated CacheStatus = "REVALIDATED" // Served from a cache entry the origin confirmed with 304 Not Modified
+	CacheStale       CacheStatus = "STALE"       // Served from an expired cache entry, see CacheConfig.RevalidateAsync
+	CacheBypass      CacheStatus = "BYPASS"      // Sent to the origin without looking up the cache, e.g. a POST request
+)
+
+// FromCache reports whether the response was served from a cache entry rather than the origin
+func (s CacheStatus) FromCache() bool {
+	return s == CacheHit || s == CacheRevalidated || s == CacheStale
+}
+
+// cacheOutcomeKey is the context key of the cacheOutcome of a request
+type cacheOutcomeKey struct{}
+
+// cacheOutcome records how the cache middleware answered a request, for the Response handed to the caller
+type cacheOutcome struct {
+	status CacheStatus
+}
+
+// contextWithCacheOutcome returns a context recording how the cache middleware answers the request into outcome
+func contextWithCacheOutcome(ctx context.Context, outcome *cacheOutcome) context.Context {
+	return context.WithValue(ctx, cacheOutcomeKey{}, outcome)
+}
+
+// ensureCacheOutcome returns the cacheOutcome of the context, adding one when the middleware is used on its own
+func ensureCacheOutcome(ctx context.Context) (context.Context, *cacheOutcome) {
+	if outcome, ok := ctx.Value(cacheOutcomeKey{}).(*cacheOutcome); ok {
+		return ctx, outcome
+	}
+	outcome := &cacheOutcome{}
+	return contextWithCacheOutcome(ctx, outcome), outcome
+}
+
+// setCacheStatus records the CacheStatus of the request of ctx, if it is tracked
+func setCacheStatus(ctx context.Context, status CacheStatus) {
+	if outcome, ok := ctx.Value(cacheOutcomeKey{}).(*cacheOutcome); ok {
+		outcome.status = status
+	}
+}
@@ -0,0 +1,135 @@
+package httpx
+
+import (
+	"context"
+	"maps"
+	"net/http"
+	"slices"
+
+	"github.com/pkg/errors"
+)
+
+// defaultCacheWriteQueue is the number of writes pending with CacheConfig.WriteBehind unless WriteQueueSize is set
+const defaultCacheWriteQueue = 1000
+
+// cacheWrite is a response waiting to be stored by the write-behind writer
+type cacheWrite struct {
+	key    string // Key the response is stored under, before its variant is selected
+	req    *http.Request
+	cached *CachedResponse
+}
+
+// cacheWriter holds the writes pending with CacheConfig.WriteBehind, guarded by the lock of the middleware
+type cacheWriter struct {
+	pending map[string]*cacheWrite // By key of the variant, the latest write replacing the previous one
+	varying map[string]*cacheWrite // By key, the latest pending write of a response with a Vary header
+	order   []string               // Keys of the pending writes, oldest first
+	dropped int64
+	idle    chan struct{} // Closed once no write is pending, nil while the writer is not running
+}
+
+// queueWrite queues the response to req for the write-behind writer, replacing a pending write of the same
+// variant, and fails when the queue is full
+func (m *CacheMiddleware) queueWrite(req *http.Request, key string, cached *CachedResponse) error {
+	write := &cacheWrite{key: key, req: req, cached: cached}
+	writeKey := key
+	if len(varyFields(cached.Headers)) > 0 {
+		writeKey = variantCacheKey(key, cached.Headers, req)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	writer := &m.writer
+	if _, queued := writer.pending[writeKey]; !queued {
+		if len(writer.order) >= m.config.WriteQueueSize {
+			writer.dropped++
+			return errors.Errorf("cache write queue of %d entries is full", m.config.WriteQueueSize)
+		}
+		writer.order = append(writer.order, writeKey)
+	}
+	writer.pending[writeKey] = write
+	if writeKey != key {
+		writer.varying[key] = write
+	}
+	if writer.idle == nil {
+		writer.idle = make(chan struct{})
+		go m.writeBehind()
+	}
+	return nil
+}
+
+// writeBehind stores the pending writes in the backend until none is left
+// A write stays visible to lookups until it is stored; one replaced while it was stored is queued again.
+func (m *CacheMiddleware) writeBehind() {
+	writer := &m.writer
+	for {
+		m.mu.Lock()
+		if len(writer.order) == 0 {
+			close(writer.idle)
+			writer.idle = nil
+			m.mu.Unlock()
+			return
+		}
+		writeKey := writer.order[0]
+		writer.order = writer.order[1:]
+		write, ok := writer.pending[writeKey]
+		m.mu.Unlock()
+		if !ok {
+			continue // Discarded by an invalidation
+		}
+
+		_ = m.store(write.req, write.key, write.cached)
+
+		m.mu.Lock()
+		switch {
+		case writer.pending[writeKey] == write:
+			delete(writer.pending, writeKey)
+		case writer.pending[writeKey] != nil:
+			writer.order = append(writer.order, writeKey)
+		}
+		if writer.varying[write.key] == write {
+			delete(writer.varying, write.key)
+		}
+		m.mu.Unlock()
+	}
+}
+
+// pendingWrite returns the response waiting to be stored under key that answers req
+func (m *CacheMiddleware) pendingWrite(req *http.Request, key string) (*CachedResponse, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if write, ok := m.writer.varying[key]; ok {
+		key = variantCacheKey(key, write.cached.Headers, req)
+	}
+	write, ok := m.writer.pending[key]
+	if !ok {
+		return nil, false
+	}
+	return write.cached, true
+}
+
+// discardWrites drops the pending writes of the keys that match, so invalidated entries are not stored again
+func (m *CacheMiddleware) discardWrites(match func(key string) bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	maps.DeleteFunc(m.writer.pending, func(key string, _ *cacheWrite) bool { return match(key) })
+	maps.DeleteFunc(m.writer.varying, func(key string, _ *cacheWrite) bool { return match(key) })
+	m.writer.order = slices.DeleteFunc(m.writer.order, match)
+}
+
+// Flush waits until the responses queued with CacheConfig.WriteBehind are stored in the backend, e.g. before
+// the process exits, returning the error of ctx if it is done first
+func (m *CacheMiddleware) Flush(ctx context.Context) error {
+	m.mu.Lock()
+	idle := m.writer.idle
+	m.mu.Unlock()
+	if idle == nil {
+		return nil
+	}
+	select {
+	case <-idle:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,86 @@
+package httpx
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// URLCanonicalizer returns the canonical form of a request URL, it must not modify u
+type URLCanonicalizer func(u *url.URL) *url.URL
+
+// CanonicalRequest is the canonical form of a request shared by the subsystems identifying requests: cache keys
+// and request coalescing, metrics labels, operation names, HTTP message signatures and DPoP proofs all derive
+// from it, so a request is identified the same way by each of them
+type CanonicalRequest struct {
+	Method string
+	URL    *url.URL
+}
+
+// Key returns the canonical request as a string, e.g. "GET:https://api.example.com/users?a=1&b=2"
+func (c CanonicalRequest) Key() string {
+	return c.Method + ":" + c.URL.String()
+}
+
+// CanonicalURL is the default URLCanonicalizer, applying the RFC 3986 normalizations that keep the meaning of a
+// URL: the scheme and host are lower-cased, default ports are removed, the path is made absolute, dot segments are
+// resolved, query parameters are sorted by name keeping the order of repeated values, and the fragment is dropped
+func CanonicalURL(u *url.URL) *url.URL {
+	canonical := *u
+	canonical.Scheme = strings.ToLower(u.Scheme)
+	canonical.Host = strings.ToLower(u.Host)
+	if (canonical.Scheme == schemeHTTP && strings.HasSuffix(canonical.Host, ":80")) ||
+		(canonical.Scheme == schemeHTTPS && strings.HasSuffix(canonical.Host, ":443")) {
+		canonical.Host = canonical.Host[:strings.LastIndex(canonical.Host, ":")]
+	}
+	if canonical.Host != "" && !strings.HasPrefix(canonical.Path, "/") {
+		canonical.Path, canonical.RawPath = "/"+canonical.Path, ""
+	}
+	if strings.Contains(canonical.Path, "/.") {
+		cleaned := path.Clean(canonical.Path)
+		if strings.HasSuffix(canonical.Path, "/") && cleaned != "/" {
+			cleaned += "/"
+		}
+		canonical.Path, canonical.RawPath = cleaned, ""
+	}
+	if canonical.RawQuery != "" {
+		if query, err := url.ParseQuery(canonical.RawQuery); err == nil {
+			canonical.RawQuery = query.Encode()
+		}
+	}
+	canonical.Fragment, canonical.RawFragment = "", ""
+	return &canonical
+}
+
+// CanonicalRequestOf returns the canonical form of req, using the URLCanonicalizer of the client which built it
+func CanonicalRequestOf(req *http.Request) CanonicalRequest {
+	return CanonicalRequest{Method: req.Method, URL: urlCanonicalizerFromContext(req.Context())(req.URL)}
+}
+
+// urlCanonicalizerKey is the context key of the URLCanonicalizer of the client
+type urlCanonicalizerKey struct{}
+
+// contextWithURLCanonicalizer returns a context carrying the canonicalizer requests are identified with
+func contextWithURLCanonicalizer(ctx context.Context, canonicalizer URLCanonicalizer) context.Context {
+	if canonicalizer == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, urlCanonicalizerKey{}, canonicalizer)
+}
+
+// urlCanonicalizerFromContext returns the canonicalizer carried by ctx, CanonicalURL if there is none
+func urlCanonicalizerFromContext(ctx context.Context) URLCanonicalizer {
+	if canonicalizer, ok := ctx.Value(urlCanonicalizerKey{}).(URLCanonicalizer); ok {
+		return canonicalizer
+	}
+	return CanonicalURL
+}
+
+// canonicalizeRequest replaces the URL of req by its canonical form, so the request sent is the one the
+// subsystems identify it by
+func canonicalizeRequest(req *http.Request) {
+	req.URL = urlCanonicalizerFromContext(req.Context())(req.URL)
+	req.Host = req.URL.Host
+}
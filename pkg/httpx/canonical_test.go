@@ -0,0 +1,121 @@
+package httpx_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bdpiprava/easy-http/pkg/httpx"
+)
+
+func TestCanonicalURL(t *testing.T) {
+	testCases := []struct {
+		name string
+		url  string
+		want string
+	}{
+		{name: "lower-cases scheme and host", url: "HTTPS://API.Example.COM/Users", want: "https://api.example.com/Users"},
+		{name: "removes default http port", url: "http://example.com:80/a", want: "http://example.com/a"},
+		{name: "removes default https port", url: "https://example.com:443/a", want: "https://example.com/a"},
+		{name: "keeps other ports", url: "https://example.com:8443/a", want: "https://example.com:8443/a"},
+		{name: "adds the root path", url: "https://example.com", want: "https://example.com/"},
+		{name: "resolves dot segments", url: "https://example.com/a/./b/../c/", want: "https://example.com/a/c/"},
+		{name: "sorts query parameters", url: "https://example.com/?b=2&a=1&b=1", want: "https://example.com/?a=1&b=2&b=1"},
+		{name: "drops the fragment", url: "https://example.com/a#section", want: "https://example.com/a"},
+		{name: "keeps malformed queries", url: "https://example.com/?a=%zz", want: "https://example.com/?a=%zz"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			u, err := url.Parse(tc.url)
+			require.NoError(t, err)
+			original := *u
+
+			got := httpx.CanonicalURL(u)
+
+			assert.Equal(t, tc.want, got.String())
+			assert.Equal(t, original, *u, "the URL must not be modified")
+		})
+	}
+}
+
+func TestWithClientURLCanonicalizer(t *testing.T) {
+	// dropTracking removes utm_* parameters on top of the default normalization
+	dropTracking := func(u *url.URL) *url.URL {
+		canonical := httpx.CanonicalURL(u)
+		query := canonical.Query()
+		for name := range query {
+			if strings.HasPrefix(name, "utm_") {
+				query.Del(name)
+			}
+		}
+		canonical.RawQuery = query.Encode()
+		return canonical
+	}
+
+	t.Run("should send and cache requests by their canonical URL", func(t *testing.T) {
+		var requests atomic.Int32
+		var received atomic.Value
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests.Add(1)
+			received.Store(r.URL.RequestURI())
+			w.Header().Set("Cache-Control", "max-age=60")
+			_, _ = w.Write([]byte("ok"))
+		}))
+		defer server.Close()
+		client := httpx.NewClientWithConfig(
+			httpx.WithClientDefaultBaseURL(server.URL),
+			httpx.WithClientURLCanonicalizer(dropTracking),
+			httpx.WithClientCache(httpx.CacheConfig{RefreshAhead: 0.9}),
+		)
+
+		for _, campaign := range []string{"spring", "summer"} {
+			_, err := client.Execute(*httpx.NewRequest(http.MethodGet,
+				httpx.WithPath("/products"),
+				httpx.WithQueryParam("utm_campaign", campaign),
+				httpx.WithQueryParam("page", "2"),
+			), "")
+			require.NoError(t, err)
+		}
+
+		assert.Equal(t, int32(1), requests.Load())
+		assert.Equal(t, "/products?page=2", received.Load())
+	})
+
+	t.Run("should report canonical URLs to metrics", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}))
+		defer server.Close()
+		collector := &urlRecordingCollector{}
+		client := httpx.NewClientWithConfig(
+			httpx.WithClientDefaultBaseURL(server.URL),
+			httpx.WithClientURLCanonicalizer(dropTracking),
+			httpx.WithClientMiddleware(httpx.NewMetricsMiddleware(collector)),
+		)
+
+		_, err := client.Execute(*httpx.NewRequest(http.MethodGet,
+			httpx.WithPath("/products"),
+			httpx.WithQueryParam("utm_source", "mail"),
+		), "")
+
+		require.NoError(t, err)
+		assert.Equal(t, []string{server.URL + "/products"}, collector.urls)
+	})
+}
+
+// urlRecordingCollector records the URLs of the requests counted by the metrics middleware
+type urlRecordingCollector struct {
+	httpx.NoOpMetricsCollector
+	urls []string
+}
+
+func (c *urlRecordingCollector) IncrementRequests(_, url string) {
+	c.urls = append(c.urls, url)
+}
@@ -0,0 +1,240 @@
+package httpx
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// defaultCaptureSize is the number of exchanges kept by the capture buffer when CaptureConfig.Size is not set
+const defaultCaptureSize = 100
+
+// errCaptureDisabled is returned by Client.DebugDump when capture is not enabled
+var errCaptureDisabled = errors.New("request capture is not enabled, see WithClientCapture")
+
+// captureRedacted replaces the values of redacted headers and query parameters in captured exchanges
+const captureRedacted = "[REDACTED]"
+
+var (
+	// defaultCaptureRedactedHeaders carry credentials and are always redacted in captured exchanges
+	defaultCaptureRedactedHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie", "X-API-Key", "X-Auth-Token", "DPoP"}
+	// defaultCaptureRedactedQueryParams carry credentials and are always redacted in captured URLs
+	defaultCaptureRedactedQueryParams = []string{"access_token", "api_key", "apikey", "token", "key", "signature", "X-Amz-Signature", "X-Amz-Credential"}
+)
+
+// CaptureConfig configures the in-memory capture of the last exchanges of a client, for crash diagnostics
+// Only metadata is kept, never bodies, and credentials are redacted.
+type CaptureConfig struct {
+	Size                int       // Number of exchanges kept, the oldest being overwritten (default: 100)
+	RedactedHeaders     []string  // Headers redacted in addition to Authorization, cookies and API keys
+	RedactedQueryParams []string  // Query parameters redacted in addition to access tokens, API keys and signatures
+	DumpOnSignal        bool      // If true, SIGQUIT dumps the buffer to DumpOutput before the default goroutine dump and exit
+	DumpOutput          io.Writer // Destination of signal dumps (default: os.Stderr)
+}
+
+// CapturedExchange is the metadata of a request sent by the client and of its response
+// Every attempt is captured, so a retried request appears once per attempt.
+type CapturedExchange struct {
+	Start           time.Time
+	Duration        time.Duration // Time until the response headers or the failure, 0 while in flight
+	InFlight        bool          // Whether the request was still waiting for a response when captured
+	Op              string        // Operation of the request, see WithOperation
+	Method          string
+	URL             string            // Request URL with credentials redacted
+	RequestHeaders  http.Header       // Request headers with credentials redacted
+	StatusCode      int               // 0 when in flight or failed without a response
+	ResponseHeaders http.Header       // Response headers with credentials redacted, nil without a response
+	Err             string            // Error of the attempt, empty on success
+	Annotations     map[string]string // Annotations of the request, see WithAnnotation
+}
+
+// captureBuffer is a ring buffer of the last exchanges of a client
+type captureBuffer struct {
+	redactedHeaders     []string
+	redactedQueryParams []string
+
+	mu      sync.Mutex
+	entries []*CapturedExchange
+	next    int // Index the next exchange is written at
+}
+
+// newCaptureBuffer creates a capture buffer, returning nil when capture is disabled
+func newCaptureBuffer(config ClientConfig) *captureBuffer {
+	if config.Capture == nil {
+		return nil
+	}
+	size := config.Capture.Size
+	if size <= 0 {
+		size = defaultCaptureSize
+	}
+	buffer := &captureBuffer{
+		redactedHeaders:     slices.Concat(defaultCaptureRedactedHeaders, config.Capture.RedactedHeaders),
+		redactedQueryParams: slices.Concat(defaultCaptureRedactedQueryParams, config.Capture.RedactedQueryParams),
+		entries:             make([]*CapturedExchange, 0, size),
+	}
+	if config.Capture.DumpOnSignal {
+		buffer.dumpOnSignal(config.BaseContext, cmp.Or(config.Capture.DumpOutput, io.Writer(os.Stderr)))
+	}
+	return buffer
+}
+
+// wrap captures the exchanges sent with do
+func (b *captureBuffer) wrap(do func(*http.Request) (*http.Response, error)) func(*http.Request) (*http.Response, error) {
+	if b == nil {
+		return do
+	}
+	return func(req *http.Request) (*http.Response, error) {
+		entry := b.start(req)
+		resp, err := do(req)
+		b.finish(entry, resp, err)
+		return resp, err
+	}
+}
+
+// start captures req as in flight
+func (b *captureBuffer) start(req *http.Request) *CapturedExchange {
+	entry := &CapturedExchange{
+		Start:          time.Now(),
+		InFlight:       true,
+		Op:             requestOperation(req),
+		Method:         req.Method,
+		URL:            b.redactURL(req.URL),
+		RequestHeaders: b.redactHeader(req.Header),
+		Annotations:    maps.Clone(AnnotationsFromContext(req.Context())),
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if len(b.entries) < cap(b.entries) {
+		b.entries = append(b.entries, entry)
+	} else {
+		b.entries[b.next] = entry
+	}
+	b.next = (b.next + 1) % cap(b.entries)
+	return entry
+}
+
+// finish records the outcome of a captured exchange
+func (b *captureBuffer) finish(entry *CapturedExchange, resp *http.Response, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	entry.InFlight = false
+	entry.Duration = time.Since(entry.Start)
+	if resp != nil {
+		entry.StatusCode = resp.StatusCode
+		entry.ResponseHeaders = b.redactHeader(resp.Header)
+	}
+	if err != nil {
+		entry.Err = err.Error()
+	}
+}
+
+// snapshot returns copies of the captured exchanges, oldest first
+func (b *captureBuffer) snapshot() []CapturedExchange {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	exchanges := make([]CapturedExchange, 0, len(b.entries))
+	start := 0
+	if len(b.entries) == cap(b.entries) {
+		start = b.next
+	}
+	for i := range b.entries {
+		exchanges = append(exchanges, *b.entries[(start+i)%len(b.entries)])
+	}
+	return exchanges
+}
+
+// dump writes the captured exchanges to w, oldest first
+func (b *captureBuffer) dump(w io.Writer) error {
+	exchanges := b.snapshot()
+	var buf strings.Builder
+	fmt.Fprintf(&buf, "httpx: %d captured exchanges at %s\n", len(exchanges), time.Now().Format(time.RFC3339Nano))
+	for _, e := range exchanges {
+		outcome := fmt.Sprintf("%d in %s", e.StatusCode, e.Duration)
+		switch {
+		case e.InFlight:
+			outcome = fmt.Sprintf("in flight for %s", time.Since(e.Start))
+		case e.Err != "":
+			outcome = fmt.Sprintf("failed in %s: %s", e.Duration, e.Err)
+		}
+		fmt.Fprintf(&buf, "\n%s %s %s (%s) %s\n", e.Start.Format(time.RFC3339Nano), e.Method, e.URL, e.Op, outcome)
+		for _, name := range slices.Sorted(maps.Keys(e.Annotations)) {
+			fmt.Fprintf(&buf, "  @%s: %s\n", name, e.Annotations[name])
+		}
+		writeCapturedHeader(&buf, "> ", e.RequestHeaders)
+		writeCapturedHeader(&buf, "< ", e.ResponseHeaders)
+	}
+	_, err := io.WriteString(w, buf.String())
+	return err
+}
+
+// dumpOnSignal dumps the buffer to w on SIGQUIT, then restores the default handling and raises the signal again
+// so the runtime still prints its goroutine dump and exits; it stops listening once ctx is done
+func (b *captureBuffer) dumpOnSignal(ctx context.Context, w io.Writer) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGQUIT)
+	go func() {
+		defer signal.Stop(signals)
+		select {
+		case sig := <-signals:
+			_ = b.dump(w)
+			signal.Stop(signals)
+			if process, err := os.FindProcess(os.Getpid()); err == nil {
+				_ = process.Signal(sig)
+			}
+		case <-ctx.Done():
+		}
+	}()
+}
+
+// redactHeader returns a copy of header with credentials redacted
+func (b *captureBuffer) redactHeader(header http.Header) http.Header {
+	redacted := header.Clone()
+	for _, name := range b.redactedHeaders {
+		if values := redacted.Values(name); len(values) > 0 {
+			redacted[http.CanonicalHeaderKey(name)] = slices.Repeat([]string{captureRedacted}, len(values))
+		}
+	}
+	return redacted
+}
+
+// redactURL returns u with its password and credential query parameters redacted
+func (b *captureBuffer) redactURL(u *url.URL) string {
+	if u.RawQuery == "" {
+		return u.Redacted()
+	}
+	redacted := *u
+	query := u.Query()
+	for name := range query {
+		if slices.ContainsFunc(b.redactedQueryParams, func(param string) bool { return strings.EqualFold(param, name) }) {
+			query[name] = slices.Repeat([]string{captureRedacted}, len(query[name]))
+		}
+	}
+	redacted.RawQuery = query.Encode()
+	return redacted.Redacted()
+}
+
+// writeCapturedHeader writes header sorted by name, each line prefixed
+func writeCapturedHeader(buf *strings.Builder, prefix string, header http.Header) {
+	for _, name := range slices.Sorted(maps.Keys(header)) {
+		for _, value := range header[name] {
+			fmt.Fprintf(buf, "%s%s: %s\n", prefix, name, value)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package httpx_test
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bdpiprava/easy-http/pkg/httpx"
+)
+
+func TestClientCapture(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Set-Cookie", "session=secret")
+		w.Header().Set("X-Request-Id", "req-"+r.URL.Query().Get("n"))
+		if r.URL.Query().Get("n") == "3" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	t.Run("keeps the last exchanges with credentials redacted", func(t *testing.T) {
+		client := httpx.NewClientWithConfig(
+			httpx.WithClientDefaultBaseURL(server.URL),
+			httpx.WithClientCapture(httpx.CaptureConfig{Size: 2, RedactedHeaders: []string{"X-Tenant"}}),
+		)
+
+		for _, n := range []string{"1", "2", "3"} {
+			_, _ = client.Execute(*httpx.NewRequest(http.MethodGet,
+				httpx.WithPath("/orders"),
+				httpx.WithQueryParam("n", n),
+				httpx.WithQueryParam("access_token", "t0k3n"),
+				httpx.WithHeader("Authorization", "Bearer t0k3n"),
+				httpx.WithHeader("X-Tenant", "acme"),
+				httpx.WithAnnotation("order_id", "o-"+n),
+			), nil)
+		}
+
+		exchanges := client.CapturedExchanges()
+		require.Len(t, exchanges, 2)
+		assert.Equal(t, "o-2", exchanges[0].Annotations["order_id"])
+		assert.Equal(t, "o-3", exchanges[1].Annotations["order_id"])
+
+		last := exchanges[1]
+		assert.False(t, last.InFlight)
+		assert.Equal(t, http.MethodGet, last.Method)
+		assert.Equal(t, "GET /orders", last.Op)
+		assert.Equal(t, http.StatusServiceUnavailable, last.StatusCode)
+		assert.Contains(t, last.URL, "access_token=%5BREDACTED%5D")
+		assert.Contains(t, last.URL, "n=3")
+		assert.NotContains(t, last.URL, "t0k3n")
+		assert.Equal(t, "[REDACTED]", last.RequestHeaders.Get("Authorization"))
+		assert.Equal(t, "[REDACTED]", last.RequestHeaders.Get("X-Tenant"))
+		assert.Equal(t, "[REDACTED]", last.ResponseHeaders.Get("Set-Cookie"))
+		assert.Equal(t, "req-3", last.ResponseHeaders.Get("X-Request-Id"))
+		assert.Positive(t, last.Duration)
+
+		var dump bytes.Buffer
+		require.NoError(t, client.DebugDump(&dump))
+		assert.Contains(t, dump.String(), "2 captured exchanges")
+		assert.Contains(t, dump.String(), "(GET /orders) 503 in ")
+		assert.Contains(t, dump.String(), "@order_id: o-3")
+		assert.Contains(t, dump.String(), "> Authorization: [REDACTED]")
+		assert.Contains(t, dump.String(), "< X-Request-Id: req-3")
+		assert.NotContains(t, dump.String(), "t0k3n")
+		assert.NotContains(t, dump.String(), "o-1")
+	})
+
+	t.Run("captures requests in flight", func(t *testing.T) {
+		release := make(chan struct{})
+		slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			<-release
+			_, _ = w.Write([]byte(`{}`))
+		}))
+		defer slow.Close()
+		client := httpx.NewClientWithConfig(
+			httpx.WithClientDefaultBaseURL(slow.URL),
+			httpx.WithClientCapture(httpx.CaptureConfig{}),
+		)
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			_, _ = client.Execute(*httpx.NewRequest(http.MethodPost, httpx.WithPath("/jobs")), nil)
+		}()
+		require.Eventually(t, func() bool { return len(client.CapturedExchanges()) == 1 }, time.Second, 5*time.Millisecond)
+
+		var dump bytes.Buffer
+		require.NoError(t, client.DebugDump(&dump))
+		assert.True(t, client.CapturedExchanges()[0].InFlight)
+		assert.Contains(t, dump.String(), "(POST /jobs) in flight for ")
+
+		close(release)
+		<-done
+		assert.False(t, client.CapturedExchanges()[0].InFlight)
+	})
+
+	t.Run("fails without capture", func(t *testing.T) {
+		client := httpx.NewClientWithConfig(httpx.WithClientDefaultBaseURL(server.URL))
+
+		assert.Nil(t, client.CapturedExchanges())
+		assert.Error(t, client.DebugDump(&bytes.Buffer{}))
+	})
+}
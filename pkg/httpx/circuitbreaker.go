@@ -43,6 +43,9 @@ type CircuitBreakerConfig struct {
 
 	// IsSuccessful determines whether a request is successful or not
 	IsSuccessful func(err error, statusCode int) bool
+
+	// Clock is the clock the open state timeout and the closed state interval are measured on (default: the system clock)
+	Clock Clock
 }
 
 // DefaultCircuitBreakerConfig returns a circuit breaker configuration with sensible defaults
@@ -148,6 +151,9 @@ type CircuitBreaker struct {
 	counts     Counts
 	expiry     time.Time
 	mutex      sync.RWMutex
+
+	// State changes not yet reported as events, emitted by the next Execute
+	transitions []CircuitBreakerEvent
 }
 
 // NewCircuitBreaker creates a new circuit breaker with the given configuration
@@ -174,12 +180,13 @@ func NewCircuitBreaker(config CircuitBreakerConfig) *CircuitBreaker {
 	if config.Name == "" {
 		config.Name = "circuit_breaker"
 	}
+	config.Clock = clockOrSystem(config.Clock)
 
 	cb := &CircuitBreaker{
 		config:     config,
 		state:      StateClosed,
 		generation: 0,
-		expiry:     time.Now().Add(config.Interval),
+		expiry:     config.Clock.Now().Add(config.Interval),
 	}
 
 	return cb
@@ -192,10 +199,11 @@ func (cb *CircuitBreaker) Name() string {
 
 // State returns the current state of the circuit breaker
 func (cb *CircuitBreaker) State() CircuitBreakerState {
-	cb.mutex.RLock()
-	defer cb.mutex.RUnlock()
+	// The state may move from open to half-open, so the write lock is required
+	cb.mutex.Lock()
+	defer cb.mutex.Unlock()
 
-	now := time.Now()
+	now := cb.config.Clock.Now()
 	state, _ := cb.currentState(now)
 	return state
 }
@@ -211,17 +219,42 @@ func (cb *CircuitBreaker) Counts() Counts {
 // Execute implements the Middleware interface
 func (cb *CircuitBreaker) Execute(ctx context.Context, req *http.Request, next MiddlewareFunc) (*http.Response, error) {
 	generation, err := cb.beforeRequest()
+	cb.emitTransitions(ctx, req)
 	if err != nil {
+		state := cb.State()
+		emitEvent(ctx, CircuitBreakerEvent{
+			Time:     cb.config.Clock.Now(),
+			Op:       requestOperation(req),
+			Name:     cb.config.Name,
+			From:     state,
+			To:       state,
+			Rejected: true,
+		})
 		return nil, err
 	}
 
-	resp, err := next(ctx, req)
+	ctx = context.WithValue(ctx, breakerStateKey{}, cb.State())
+	resp, err := next(ctx, req.WithContext(ctx))
 
 	cb.afterRequest(generation, cb.config.IsSuccessful(err, cb.getStatusCode(resp)))
+	cb.emitTransitions(ctx, req)
 
 	return resp, err
 }
 
+// emitTransitions reports the pending state changes as events of the request
+func (cb *CircuitBreaker) emitTransitions(ctx context.Context, req *http.Request) {
+	cb.mutex.Lock()
+	transitions := cb.transitions
+	cb.transitions = nil
+	cb.mutex.Unlock()
+
+	for _, event := range transitions {
+		event.Op = requestOperation(req)
+		emitEvent(ctx, event)
+	}
+}
+
 // getStatusCode safely extracts status code from response
 func (cb *CircuitBreaker) getStatusCode(resp *http.Response) int {
 	if resp == nil {
@@ -235,18 +268,20 @@ func (cb *CircuitBreaker) beforeRequest() (uint64, error) {
 	cb.mutex.Lock()
 	defer cb.mutex.Unlock()
 
-	now := time.Now()
+	now := cb.config.Clock.Now()
 	state, generation := cb.currentState(now)
 
 	if state == StateOpen {
 		return generation, &HTTPError{
 			Type:    ErrorTypeMiddleware,
 			Message: fmt.Sprintf("circuit breaker '%s' is open", cb.config.Name),
+			Cause:   ErrCircuitOpen,
 		}
 	} else if state == StateHalfOpen && cb.counts.Requests >= cb.config.MaxRequests {
 		return generation, &HTTPError{
 			Type:    ErrorTypeMiddleware,
 			Message: fmt.Sprintf("circuit breaker '%s' is half-open and max requests exceeded", cb.config.Name),
+			Cause:   ErrCircuitOpen,
 		}
 	}
 
@@ -259,7 +294,7 @@ func (cb *CircuitBreaker) afterRequest(before uint64, success bool) {
 	cb.mutex.Lock()
 	defer cb.mutex.Unlock()
 
-	now := time.Now()
+	now := cb.config.Clock.Now()
 	state, generation := cb.currentState(now)
 	if generation != before {
 		return // Circuit breaker state changed during request, ignore this result
@@ -320,6 +355,7 @@ func (cb *CircuitBreaker) setState(state CircuitBreakerState, now time.Time) {
 	cb.state = state
 
 	cb.toNewGeneration(now)
+	cb.transitions = append(cb.transitions, CircuitBreakerEvent{Time: now, Name: cb.config.Name, From: prev, To: state})
 
 	if cb.config.OnStateChange != nil {
 		cb.config.OnStateChange(cb.config.Name, prev, state)
@@ -381,36 +417,15 @@ func (cbm *CircuitBreakerMiddleware) Counts() Counts {
 // CircuitBreakerError creates a circuit breaker specific error
 func CircuitBreakerError(message string, req *http.Request) *HTTPError {
 	return &HTTPError{
+		Op:      requestOperation(req),
 		Type:    ErrorTypeMiddleware,
 		Message: message,
+		Cause:   ErrCircuitOpen,
 		Request: req,
 	}
 }
 
 // IsCircuitBreakerError checks if an error is a circuit breaker error
 func IsCircuitBreakerError(err error) bool {
-	httpErr := &HTTPError{}
-	if errors.As(err, &httpErr) {
-		return httpErr.Type == ErrorTypeMiddleware &&
-			(contains(httpErr.Message, "circuit breaker") || contains(httpErr.Message, "open") || contains(httpErr.Message, "half-open"))
-	}
-	return false
-}
-
-// contains checks if a string contains a substring (case-insensitive helper)
-func contains(s, substr string) bool {
-	return len(s) >= len(substr) && (s == substr ||
-		(len(s) > len(substr) &&
-			(s[:len(substr)] == substr || s[len(s)-len(substr):] == substr ||
-				indexOf(s, substr) >= 0)))
-}
-
-// indexOf finds the index of substring in string
-func indexOf(s, substr string) int {
-	for i := 0; i <= len(s)-len(substr); i++ {
-		if s[i:i+len(substr)] == substr {
-			return i
-		}
-	}
-	return -1
+	return errors.Is(err, ErrCircuitOpen)
 }
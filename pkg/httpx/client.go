@@ -2,9 +2,12 @@ package httpx
 
 import (
 	"context"
+	"crypto/tls"
+	"io"
 	"log/slog"
-	"net"
+	"math/rand/v2"
 	"net/http"
+	"slices"
 	"time"
 )
 
@@ -18,6 +21,13 @@ type Client struct {
 	config        ClientConfig  // New structured configuration
 	clientOptions ClientOptions // Deprecated: kept for backward compatibility
 	client        *http.Client
+	drainer       *bodyDrainer
+	slowRequests  *slowRequestReporter
+	connPool      *connPool
+	events        *eventSink
+	capture       *captureBuffer
+	metrics       *clientMetrics
+	deliveries    *deliveryTracker
 }
 
 // NewClientWithConfig creates a new client with the improved configuration architecture
@@ -33,6 +43,33 @@ func NewClientWithConfig(opts ...ClientConfigOption) *Client {
 	for _, opt := range opts {
 		opt(&config)
 	}
+	config.Logger = mapLogFields(config.Logger, config.LogFieldMapper)
+
+	// Share one lock-guarded random source between the client's jitter users
+	config.RandSource = newLockedSource(config.RandSource)
+	if config.RetryPolicy != nil && config.RetryPolicy.RandSource == nil && config.RetryPolicy.JitterSeed == "" {
+		policy := *config.RetryPolicy
+		policy.RandSource = config.RandSource
+		config.RetryPolicy = &policy
+	}
+
+	if config.RetryBudget != nil && config.RetryPolicy != nil && config.RetryPolicy.Budget == nil {
+		policy := *config.RetryPolicy
+		policy.Budget = config.RetryBudget
+		config.RetryPolicy = &policy
+	}
+
+	// Share the clock with the retry policy and circuit breaker, unless they set their own
+	if config.Clock != nil && config.RetryPolicy != nil && config.RetryPolicy.Clock == nil {
+		policy := *config.RetryPolicy
+		policy.Clock = config.Clock
+		config.RetryPolicy = &policy
+	}
+	if config.Clock != nil && config.CircuitBreakerConfig != nil && config.CircuitBreakerConfig.Clock == nil {
+		breaker := *config.CircuitBreakerConfig
+		breaker.Clock = config.Clock
+		config.CircuitBreakerConfig = &breaker
+	}
 
 	// Auto-configure middlewares based on configuration
 	if len(config.Middlewares) == 0 {
@@ -52,9 +89,8 @@ func NewClientWithConfig(opts ...ClientConfigOption) *Client {
 		}
 
 		// Add logging middleware if logger is provided
-		if config.Logger != nil {
-			loggingMiddleware := NewLoggingMiddleware(config.Logger, config.LogLevel)
-			middlewares = append(middlewares, loggingMiddleware)
+		if config.Logger != nil || config.ContextLogger != nil {
+			middlewares = append(middlewares, newClientLoggingMiddleware(config))
 		}
 
 		config.Middlewares = middlewares
@@ -78,6 +114,15 @@ func NewClientWithConfig(opts ...ClientConfigOption) *Client {
 		}
 	}
 
+	// Stop service discovery with the application lifecycle; other middlewares get the base context per request
+	if config.BaseContext != nil {
+		for _, middleware := range config.Middlewares {
+			if service, ok := middleware.(*ServiceMiddleware); ok {
+				context.AfterFunc(config.BaseContext, service.Close)
+			}
+		}
+	}
+
 	// Create HTTP client with timeout
 	httpClient := &http.Client{
 		Timeout: config.Timeout,
@@ -88,6 +133,41 @@ func NewClientWithConfig(opts ...ClientConfigOption) *Client {
 		httpClient.Transport = configureProxyTransport(&config)
 	}
 
+	// Apply TLS settings, client certificates and revocation checking if configured
+	if config.TLSConfig != nil || config.CertificateProvider != nil ||
+		(config.RevocationCheck != "" && config.RevocationCheck != RevocationCheckDisabled) {
+		configureTLS(transportFor(httpClient), &config)
+	}
+
+	// Apply granular timeouts if configured
+	if config.DialTimeout > 0 || config.TLSHandshakeTimeout > 0 || config.ResponseHeaderTimeout > 0 {
+		configureTimeouts(transportFor(httpClient), &config)
+	}
+
+	// Race connections across the addresses of a host, every attempt bounded by the dial timeout
+	if config.ConnectRace != nil && !dialsThroughSOCKS(config.ProxyConfig) {
+		configureConnectRace(transportFor(httpClient), *config.ConnectRace, clockOrSystem(config.Clock))
+	}
+
+	// Pace connections beneath the pool tracking, whose wrapper must stay outermost
+	if limiter := newBandwidthLimiter(config); limiter != nil {
+		configureBandwidthLimit(transportFor(httpClient), limiter)
+	}
+
+	// Track the connection pool if observed or if connections expire
+	pool := newConnPool(config)
+	if pool != nil {
+		configureConnPool(transportFor(httpClient), pool)
+	}
+	if config.ConnMaxIdleTime > 0 {
+		transportFor(httpClient).IdleConnTimeout = config.ConnMaxIdleTime
+	}
+
+	// Replace the TLS handshake last, over the final dialer and TLS configuration
+	if config.TLSHandshaker != nil {
+		configureTLSHandshaker(transportFor(httpClient), config.TLSHandshaker)
+	}
+
 	// Wire up cookie jar if configured
 	if config.CookieJar != nil {
 		httpClient.Jar = config.CookieJar
@@ -97,6 +177,13 @@ func NewClientWithConfig(opts ...ClientConfigOption) *Client {
 		config:        config,
 		clientOptions: config.ToClientOptions(), // For backward compatibility
 		client:        httpClient,
+		drainer:       newBodyDrainer(config),
+		slowRequests:  newSlowRequestReporter(config),
+		connPool:      pool,
+		events:        newEventSink(config),
+		capture:       newCaptureBuffer(config),
+		metrics:       &clientMetrics{},
+		deliveries:    newDeliveryTracker(config),
 	}
 }
 
@@ -126,6 +213,13 @@ func configureProxyTransport(config *ClientConfig) *http.Transport {
 			NoProxy:   config.NoProxy,
 			ProxyAuth: proxyAuth,
 		}
+		if config.ProxyVia != "" {
+			via, err := ParseProxyURL(config.ProxyVia)
+			if err != nil {
+				return transport
+			}
+			config.ProxyConfig.Via = via
+		}
 	}
 
 	// If no proxy config at this point, use system proxy
@@ -137,25 +231,27 @@ func configureProxyTransport(config *ClientConfig) *http.Transport {
 	proxyConfig := config.ProxyConfig
 
 	// Check if this is a SOCKS proxy (requires special handling)
-	if proxyConfig.ProxyURL != nil {
-		scheme := proxyConfig.ProxyURL.Scheme
-		if scheme == schemeSOCKS4 || scheme == schemeSOCKS5 {
-			// SOCKS proxies need a custom dialer
-			socksDialer, err := CreateSOCKSDialer(proxyConfig.ProxyURL, proxyConfig.ProxyAuth)
-			if err != nil {
-				// If SOCKS dialer creation fails, fall back to no proxy
-				return transport
-			}
+	if proxyConfig.ProxyURL != nil && isSOCKSScheme(proxyConfig.ProxyURL.Scheme) {
+		// SOCKS proxies need a custom dialer, hosts bypassing the proxy keep the default one
+		dialContext, err := socksDialContext(proxyConfig, transport.DialContext)
+		if err != nil {
+			// If SOCKS dialer creation fails, fall back to no proxy
+			return transport
+		}
+		transport.DialContext = dialContext
 
-			// Create a custom DialContext that uses the SOCKS dialer
-			transport.DialContext = func(_ context.Context, network, addr string) (net.Conn, error) {
-				return socksDialer.Dial(network, addr)
-			}
+		// Clear the Proxy field since we're using custom dialer
+		transport.Proxy = nil
+		return transport
+	}
 
-			// Clear the Proxy field since we're using custom dialer
-			transport.Proxy = nil
+	// Chain the HTTP proxy behind a SOCKS proxy, every connection then goes through the SOCKS proxy
+	if proxyConfig.Via != nil {
+		dialContext, err := viaDialContext(proxyConfig.Via)
+		if err != nil {
 			return transport
 		}
+		transport.DialContext = dialContext
 	}
 
 	// For HTTP/HTTPS proxies, use the standard Proxy function
@@ -197,6 +293,9 @@ func NewClient(opts ...ClientOption) *Client {
 		config:        config,
 		clientOptions: cOpts,
 		client:        &http.Client{Timeout: cOpts.Timeout},
+		drainer:       newBodyDrainer(config),
+		metrics:       &clientMetrics{},
+		deliveries:    newDeliveryTracker(config),
 	}
 }
 
@@ -205,6 +304,186 @@ func (c Client) Execute(req Request, respType any) (*Response, error) {
 	return execute(&c, &req, respType)
 }
 
+// Do executes the request through the full middleware chain like Execute, but returns the standard response
+// with its body unread, for code expecting net/http semantics such as decoders reading from resp.Body
+// The caller must close the body. Errors are *HTTPError as with Execute, while 4xx and 5xx responses are
+// returned without an error, as by http.Client.Do.
+func (c Client) Do(req Request) (*http.Response, error) {
+	return do(&c, &req)
+}
+
+// Metrics returns a snapshot of the requests, attempts, retries and cache hits counted since the client
+// was created
+func (c Client) Metrics() ClientMetrics {
+	return c.metrics.snapshot()
+}
+
+// CloseIdleConnections closes any idle keep-alive connections held by the underlying transport
+// Use it as an escape hatch to recover a connection pool, e.g. after a burst of abandoned responses
+func (c Client) CloseIdleConnections() {
+	c.client.CloseIdleConnections()
+}
+
+// BodyDrainStats returns counters of response bodies drained, discarded or leaked by the client
+func (c Client) BodyDrainStats() BodyDrainStats {
+	return c.drainer.stats()
+}
+
+// SlowRequests returns the number of calls that exceeded the slow request threshold
+func (c Client) SlowRequests() uint64 {
+	return c.slowRequests.slowRequests()
+}
+
+// RateLimiter returns the client's rate limit middleware for introspection and runtime adjustment,
+// or nil if rate limiting is not configured
+func (c Client) RateLimiter() *RateLimitMiddleware {
+	for _, middleware := range c.config.Middlewares {
+		if limiter, ok := middleware.(*RateLimitMiddleware); ok {
+			return limiter
+		}
+	}
+	return nil
+}
+
+// Quota returns the quota added by WithClientQuota, or nil if there is none
+func (c Client) Quota() *QuotaMiddleware {
+	for _, middleware := range c.config.Middlewares {
+		if quota, ok := middleware.(*QuotaMiddleware); ok {
+			return quota
+		}
+	}
+	return nil
+}
+
+// Cache returns the cache middleware added by WithClientCache, or nil if there is none
+func (c Client) Cache() *CacheMiddleware {
+	for _, middleware := range c.config.Middlewares {
+		if cache, ok := middleware.(*CacheMiddleware); ok {
+			return cache
+		}
+	}
+	return nil
+}
+
+// Validators returns the validator store of the cache middleware added by WithClientCache, or nil if there is none
+func (c Client) Validators() *ValidatorStore {
+	if cache := c.Cache(); cache != nil {
+		return cache.Validators()
+	}
+	return nil
+}
+
+// OAuth2 returns the OAuth2 middleware added by WithClientOAuth2, or nil if there is none
+func (c Client) OAuth2() *OAuth2Middleware {
+	for _, middleware := range c.config.Middlewares {
+		if oauth2, ok := middleware.(*OAuth2Middleware); ok {
+			return oauth2
+		}
+	}
+	return nil
+}
+
+// Redaction returns the redaction middleware added by WithClientRedaction, or nil if there is none
+func (c Client) Redaction() *RedactionMiddleware {
+	for _, middleware := range c.config.Middlewares {
+		if redaction, ok := middleware.(*RedactionMiddleware); ok {
+			return redaction
+		}
+	}
+	return nil
+}
+
+// ConcurrencyLimiter returns the adaptive concurrency limiter added by WithClientAdaptiveConcurrency, or nil if there is none
+func (c Client) ConcurrencyLimiter() *ConcurrencyLimitMiddleware {
+	for _, middleware := range c.config.Middlewares {
+		if limiter, ok := middleware.(*ConcurrencyLimitMiddleware); ok {
+			return limiter
+		}
+	}
+	return nil
+}
+
+// Bulkhead returns the bulkhead added by WithClientBulkhead, or nil if there is none
+func (c Client) Bulkhead() *BulkheadMiddleware {
+	for _, middleware := range c.config.Middlewares {
+		if bulkhead, ok := middleware.(*BulkheadMiddleware); ok {
+			return bulkhead
+		}
+	}
+	return nil
+}
+
+// newClientLoggingMiddleware creates the logging middleware of a client, sampled if configured
+func newClientLoggingMiddleware(config ClientConfig) *LoggingMiddleware {
+	logger := config.Logger
+	if logger == nil {
+		logger = mapLogFields(slog.Default(), config.LogFieldMapper)
+	}
+	if config.LogSampling == nil {
+		return NewLoggingMiddleware(logger, config.LogLevel)
+	}
+	sampling := *config.LogSampling
+	if sampling.RandSource == nil {
+		sampling.RandSource = config.RandSource
+	}
+	return NewSampledLoggingMiddleware(logger, config.LogLevel, sampling)
+}
+
+// ServiceDiscovery returns the client's service discovery middleware for introspection and to stop it with Close,
+// or nil if service discovery is not configured
+func (c Client) ServiceDiscovery() *ServiceMiddleware {
+	for _, middleware := range c.config.Middlewares {
+		if service, ok := middleware.(*ServiceMiddleware); ok {
+			return service
+		}
+	}
+	return nil
+}
+
+// LogSamplingStats returns the counters of requests logged or suppressed by WithClientLogSampling
+func (c Client) LogSamplingStats() LogSamplingStats {
+	for _, middleware := range c.config.Middlewares {
+		if logging, ok := middleware.(*LoggingMiddleware); ok {
+			return logging.SamplingStats()
+		}
+	}
+	return LogSamplingStats{}
+}
+
+// Events returns the channel of retry, circuit breaker and rate limit events enabled by WithClientEvents
+// It is nil, and so blocks forever, when events are disabled. The channel is never closed.
+func (c Client) Events() <-chan Event {
+	return c.events.events()
+}
+
+// DroppedEvents returns the number of events dropped because the Events channel was full
+func (c Client) DroppedEvents() uint64 {
+	return c.events.droppedEvents()
+}
+
+// CapturedExchanges returns the last exchanges captured by WithClientCapture, oldest first, or nil if capture is disabled
+func (c Client) CapturedExchanges() []CapturedExchange {
+	if c.capture == nil {
+		return nil
+	}
+	return c.capture.snapshot()
+}
+
+// DebugDump writes the last exchanges captured by WithClientCapture to w, oldest first, e.g. from a debug
+// endpoint or a panic handler to see what the client was doing before an incident
+func (c Client) DebugDump(w io.Writer) error {
+	if c.capture == nil {
+		return errCaptureDisabled
+	}
+	return c.capture.dump(w)
+}
+
+// ConnPoolStats returns a snapshot of the connection pool per dialed host
+// It is nil unless a ConnPoolObserver, e.g. by WithClientPrometheusMetrics, or WithClientConnMaxLifetime is configured
+func (c Client) ConnPoolStats() map[string]ConnPoolStats {
+	return c.connPool.stats()
+}
+
 // WithDefaultTimeout is a function that sets the timeout for the client
 func WithDefaultTimeout(timeout time.Duration) ClientOption {
 	return func(c *ClientOptions) {
@@ -281,6 +560,58 @@ func WithClientTimeout(timeout time.Duration) ClientConfigOption {
 	}
 }
 
+// WithClientDialTimeout sets the maximum time to establish a TCP connection
+// Exceeding it produces a timeout error with TimeoutPhaseDial
+func WithClientDialTimeout(timeout time.Duration) ClientConfigOption {
+	return func(c *ClientConfig) {
+		c.DialTimeout = timeout
+	}
+}
+
+// WithClientConnectRace races connections across the addresses a host name resolves to, using the first to
+// succeed, so a dead address behind DNS round-robin costs the race delay instead of a full connect timeout.
+// An attempt still pending after config.Delay is raced with the next address, and a failed attempt is replaced
+// at once, with at most config.Parallel attempts in flight; DialTimeout bounds every attempt.
+// Connections through a SOCKS proxy or Via chain are not raced, as the proxy resolves host names itself.
+func WithClientConnectRace(config ConnectRaceConfig) ClientConfigOption {
+	return func(c *ClientConfig) {
+		c.ConnectRace = &config
+	}
+}
+
+// WithClientTLSHandshakeTimeout sets the maximum time to complete the TLS handshake
+// Exceeding it produces a timeout error with TimeoutPhaseTLSHandshake
+func WithClientTLSHandshakeTimeout(timeout time.Duration) ClientConfigOption {
+	return func(c *ClientConfig) {
+		c.TLSHandshakeTimeout = timeout
+	}
+}
+
+// WithClientResponseHeaderTimeout sets the maximum time to wait for response headers once the request is written
+// Exceeding it produces a timeout error with TimeoutPhaseResponseHeader
+func WithClientResponseHeaderTimeout(timeout time.Duration) ClientConfigOption {
+	return func(c *ClientConfig) {
+		c.ResponseHeaderTimeout = timeout
+	}
+}
+
+// WithClientBodyReadTimeout sets the maximum time to read the response body once headers are received
+// Exceeding it produces a timeout error with TimeoutPhaseBodyRead
+func WithClientBodyReadTimeout(timeout time.Duration) ClientConfigOption {
+	return func(c *ClientConfig) {
+		c.BodyReadTimeout = timeout
+	}
+}
+
+// WithClientSlowRequestThreshold logs every call taking at least threshold at Warn level with its
+// timing breakdown (DNS, connect, TLS, time to first byte, body read) and counts it in SlowRequests
+// Slow calls are logged regardless of the client log level; the client logger is used if set, slog.Default otherwise
+func WithClientSlowRequestThreshold(threshold time.Duration) ClientConfigOption {
+	return func(c *ClientConfig) {
+		c.SlowRequestThreshold = threshold
+	}
+}
+
 // WithClientLogger sets the structured logger for the client
 func WithClientLogger(logger *slog.Logger) ClientConfigOption {
 	return func(c *ClientConfig) {
@@ -288,6 +619,31 @@ func WithClientLogger(logger *slog.Logger) ClientConfigOption {
 	}
 }
 
+// WithClientContextLogger logs each request with the logger extractor returns for the request context, such as
+// one carrying user or session fields, falling back to the client logger when it returns nil
+func WithClientContextLogger(extractor func(ctx context.Context) *slog.Logger) ClientConfigOption {
+	return func(c *ClientConfig) {
+		c.ContextLogger = extractor
+	}
+}
+
+// WithClientLogFieldMapper renames the attribute keys of all client logs, e.g. to keep an existing log schema
+// The built-in keys are the LogField constants; see LogFieldMapper.
+func WithClientLogFieldMapper(mapper LogFieldMapper) ClientConfigOption {
+	return func(c *ClientConfig) {
+		c.LogFieldMapper = mapper
+	}
+}
+
+// WithClientLogSampling logs only a fraction of requests, e.g. WithClientLogSampling(0.01, 1) logs 1% of
+// successful requests and every failed one (transport errors, 4xx and 5xx). Rates are clamped to [0, 1].
+// Suppressed requests are counted in Client.LogSamplingStats.
+func WithClientLogSampling(successRate, errorRate float64) ClientConfigOption {
+	return func(c *ClientConfig) {
+		c.LogSampling = &LogSamplingConfig{SuccessRate: successRate, ErrorRate: errorRate}
+	}
+}
+
 // WithClientLogLevel sets the minimum log level for HTTP operations
 func WithClientLogLevel(level slog.Level) ClientConfigOption {
 	return func(c *ClientConfig) {
@@ -358,6 +714,14 @@ func WithClientRetryPolicy(policy RetryPolicy) ClientConfigOption {
 	}
 }
 
+// WithClientRetryBudget caps the retries of the retry policy of the client in aggregate, so a burst of failures
+// across many requests does not turn into a retry storm, see RetryBudget
+func WithClientRetryBudget(budget RetryBudget) ClientConfigOption {
+	return func(c *ClientConfig) {
+		c.RetryBudget = &budget
+	}
+}
+
 // WithClientDefaultRetryPolicy enables default retry behavior for all requests
 func WithClientDefaultRetryPolicy() ClientConfigOption {
 	policy := DefaultRetryPolicy()
@@ -382,6 +746,31 @@ func WithClientConservativeRetryPolicy() ClientConfigOption {
 	}
 }
 
+// WithoutStaleConnectionRetry disables the single transparent replay of requests that fail because a
+// reused keep-alive connection was closed by the server
+func WithoutStaleConnectionRetry() ClientConfigOption {
+	return func(c *ClientConfig) {
+		c.DisableStaleConnectionRetry = true
+	}
+}
+
+// WithClientClock sets the clock retry backoffs and circuit breaker timeouts are measured on, e.g. a fake
+// clock advanced manually by resilience tests
+func WithClientClock(clock Clock) ClientConfigOption {
+	return func(c *ClientConfig) {
+		c.Clock = clock
+	}
+}
+
+// WithClientRandSource sets the random source used for retry jitter
+// Pass a seeded source, e.g. rand.NewPCG(1, 2), to make delays deterministic in tests and simulations;
+// the client guards it with a lock, so it need not be safe for concurrent use
+func WithClientRandSource(src rand.Source) ClientConfigOption {
+	return func(c *ClientConfig) {
+		c.RandSource = src
+	}
+}
+
 // WithClientCircuitBreaker sets the circuit breaker configuration for all requests made by this client
 func WithClientCircuitBreaker(config CircuitBreakerConfig) ClientConfigOption {
 	return func(c *ClientConfig) {
@@ -414,8 +803,12 @@ func WithClientConservativeCircuitBreaker() ClientConfigOption {
 }
 
 // WithClientCache enables HTTP caching with the specified configuration
+// Lookups and stores are reported to the client's CacheObserver unless config sets its own Observer.
 func WithClientCache(config CacheConfig) ClientConfigOption {
 	return func(c *ClientConfig) {
+		if config.Observer == nil {
+			config.Observer = c.CacheObserver
+		}
 		cacheMiddleware := NewCacheMiddleware(config)
 		c.Middlewares = append(c.Middlewares, cacheMiddleware)
 	}
@@ -424,12 +817,36 @@ func WithClientCache(config CacheConfig) ClientConfigOption {
 // WithClientDefaultCache enables HTTP caching with default settings
 func WithClientDefaultCache() ClientConfigOption {
 	return WithClientCache(CacheConfig{
-		Backend:      NewInMemoryCache(1000),
+		MaxEntries:   1000,
 		DefaultTTL:   5 * time.Minute,
 		MaxSizeBytes: 10 * 1024 * 1024, // 10MB
 	})
 }
 
+// WithClientService routes requests for the named service to its endpoints found with DNS SRV records
+// The default base URL becomes "http://<service>" unless one is set; see WithClientServiceDiscovery.
+func WithClientService(service string) ClientConfigOption {
+	return WithClientServiceDiscovery(ServiceConfig{Name: service})
+}
+
+// WithClientServiceDiscovery routes requests whose URL host is config.Name to the endpoints of the service,
+// found by config.Resolver and picked by config.Balancer. The default base URL becomes "http://<service>"
+// unless one is set. Use an https base URL with TLSConfig.ServerName set when endpoints are IP addresses.
+// Outlier detection reports to the client's OutlierObserver unless config.OutlierDetection sets its own.
+func WithClientServiceDiscovery(config ServiceConfig) ClientConfigOption {
+	return func(c *ClientConfig) {
+		if c.DefaultBaseURL == "" {
+			c.DefaultBaseURL = "http://" + config.Name
+		}
+		if config.OutlierDetection != nil && config.OutlierDetection.Observer == nil {
+			detection := *config.OutlierDetection
+			detection.Observer = c.OutlierObserver
+			config.OutlierDetection = &detection
+		}
+		c.Middlewares = append(c.Middlewares, NewServiceMiddleware(config))
+	}
+}
+
 // WithClientRateLimit adds rate limiting to all requests
 func WithClientRateLimit(config RateLimitConfig) ClientConfigOption {
 	return func(c *ClientConfig) {
@@ -438,6 +855,47 @@ func WithClientRateLimit(config RateLimitConfig) ClientConfigOption {
 	}
 }
 
+// WithClientQuota rejects requests with ErrRateLimited once config.Limit requests were sent in the current
+// period, persisting the count to config.File so restarts do not reset it. If the file cannot be read,
+// every request fails with the error rather than risk exceeding the quota.
+func WithClientQuota(config QuotaConfig) ClientConfigOption {
+	return func(c *ClientConfig) {
+		c.Middlewares = append(c.Middlewares, newQuotaMiddleware(config))
+	}
+}
+
+// WithClientAdaptiveConcurrency caps the requests in flight with a limit adapting to the upstream latency
+// Requests over the limit fail with ErrConcurrencyLimited unless config.WaitOnLimit is set. For a fixed limit,
+// or one per host, use WithClientBulkhead instead; combining both stacks two limiters on every request.
+// The limit is reported to the client's ConcurrencyObserver unless config sets its own.
+func WithClientAdaptiveConcurrency(config ConcurrencyLimitConfig) ClientConfigOption {
+	return func(c *ClientConfig) {
+		if config.Observer == nil {
+			config.Observer = c.ConcurrencyObserver
+		}
+		c.Middlewares = append(c.Middlewares, NewConcurrencyLimitMiddleware(config))
+	}
+}
+
+// WithClientBulkhead caps the requests in flight at config.MaxConcurrent, for the client or per host
+// Requests over the limit wait in a queue of config.MaxQueue requests, and fail with ErrConcurrencyLimited when
+// it is full or their wait times out. Unlike WithClientAdaptiveConcurrency, the limit does not adapt to latency;
+// use one or the other, as combining both stacks two limiters on every request.
+func WithClientBulkhead(config BulkheadConfig) ClientConfigOption {
+	return func(c *ClientConfig) {
+		c.Middlewares = append(c.Middlewares, NewBulkheadMiddleware(config))
+	}
+}
+
+// WithClientConcurrencyObserver sets an observer notified of the adaptive concurrency limit and requests in flight
+// It applies to the limiter added after it with WithClientAdaptiveConcurrency; limiters created with
+// NewConcurrencyLimitMiddleware report to their ConcurrencyLimitConfig.Observer.
+func WithClientConcurrencyObserver(observer ConcurrencyObserver) ClientConfigOption {
+	return func(c *ClientConfig) {
+		c.ConcurrencyObserver = observer
+	}
+}
+
 // WithClientDefaultRateLimit adds default rate limiting (10 req/sec with burst of 20)
 func WithClientDefaultRateLimit() ClientConfigOption {
 	return WithClientRateLimit(RateLimitConfig{
@@ -472,6 +930,21 @@ func WithClientPrometheusMetrics(config PrometheusConfig) ClientConfigOption {
 		}
 		metricsMiddleware := NewMetricsMiddleware(collector)
 		c.Middlewares = append(c.Middlewares, metricsMiddleware)
+		if c.BodyDrainObserver == nil {
+			c.BodyDrainObserver = collector
+		}
+		if c.ConnPoolObserver == nil {
+			c.ConnPoolObserver = collector
+		}
+		if c.OutlierObserver == nil {
+			c.OutlierObserver = collector
+		}
+		if c.ConcurrencyObserver == nil {
+			c.ConcurrencyObserver = collector
+		}
+		if c.CacheObserver == nil {
+			c.CacheObserver = collector
+		}
 	}
 }
 
@@ -494,6 +967,271 @@ func WithClientDefaultTracing() ClientConfigOption {
 	return WithClientTracing(TracingConfig{})
 }
 
+// WithClientResponseSignatureVerification verifies detached JWS signatures on all responses
+func WithClientResponseSignatureVerification(config JWSVerificationConfig) ClientConfigOption {
+	return func(c *ClientConfig) {
+		verificationMiddleware := NewJWSVerificationMiddleware(config)
+		c.Middlewares = append(c.Middlewares, verificationMiddleware)
+	}
+}
+
+// WithClientMessageSignatures signs all requests using RFC 9421 HTTP Message Signatures
+func WithClientMessageSignatures(config MessageSignatureConfig) ClientConfigOption {
+	return func(c *ClientConfig) {
+		signatureMiddleware := NewMessageSignatureMiddleware(config)
+		c.Middlewares = append(c.Middlewares, signatureMiddleware)
+	}
+}
+
+// WithClientRequestSigner signs all requests with signer, e.g. NewHMACSigner or NewSigV4Signer, once per attempt
+func WithClientRequestSigner(signer RequestSigner) ClientConfigOption {
+	return func(c *ClientConfig) {
+		signingMiddleware := NewRequestSigningMiddleware(signer)
+		c.Middlewares = append(c.Middlewares, signingMiddleware)
+	}
+}
+
+// WithClientDPoP attaches DPoP (RFC 9449) proofs to all requests
+func WithClientDPoP(config DPoPConfig) ClientConfigOption {
+	return func(c *ClientConfig) {
+		dpopMiddleware := NewDPoPMiddleware(config)
+		c.Middlewares = append(c.Middlewares, dpopMiddleware)
+	}
+}
+
+// WithClientOAuth2 authorizes all requests with access tokens of the OAuth2 client credentials grant, fetched
+// from config.TokenURL and refreshed before they expire
+// The middleware is placed ahead of any DPoP, message signature and request signing middleware regardless of
+// option order, so proofs and signatures cover the Authorization header
+func WithClientOAuth2(config OAuth2Config) ClientConfigOption {
+	return func(c *ClientConfig) {
+		oauth2Middleware := NewOAuth2Middleware(config)
+		for i, middleware := range c.Middlewares {
+			switch middleware.(type) {
+			case *DPoPMiddleware, *MessageSignatureMiddleware, *RequestSigningMiddleware:
+				c.Middlewares = slices.Insert(c.Middlewares, i, Middleware(oauth2Middleware))
+				return
+			}
+		}
+		c.Middlewares = append(c.Middlewares, oauth2Middleware)
+	}
+}
+
+// WithClientPayloadEncryption encrypts request bodies and decrypts encrypted response bodies
+// The middleware is placed ahead of any compression, message signature and request signing middleware regardless
+// of option order
+func WithClientPayloadEncryption(config PayloadEncryptionConfig) ClientConfigOption {
+	return func(c *ClientConfig) {
+		encryptionMiddleware := NewPayloadEncryptionMiddleware(config)
+		for i, middleware := range c.Middlewares {
+			switch middleware.(type) {
+			case *CompressionMiddleware, *MessageSignatureMiddleware, *RequestSigningMiddleware:
+				c.Middlewares = slices.Insert(c.Middlewares, i, Middleware(encryptionMiddleware))
+				return
+			}
+		}
+		c.Middlewares = append(c.Middlewares, encryptionMiddleware)
+	}
+}
+
+// WithClientRedaction masks PII in request bodies, e.g. to third-party analytics APIs, according to config.Rules
+// The middleware is placed ahead of any payload encryption, compression, message signature and request signing
+// middleware regardless of option order. If a rule is invalid, every request fails with the error.
+func WithClientRedaction(config RedactionConfig) ClientConfigOption {
+	return func(c *ClientConfig) {
+		redactionMiddleware := newRedactionMiddleware(config)
+		for i, middleware := range c.Middlewares {
+			switch middleware.(type) {
+			case *PayloadEncryptionMiddleware, *CompressionMiddleware, *MessageSignatureMiddleware, *RequestSigningMiddleware:
+				c.Middlewares = slices.Insert(c.Middlewares, i, Middleware(redactionMiddleware))
+				return
+			}
+		}
+		c.Middlewares = append(c.Middlewares, redactionMiddleware)
+	}
+}
+
+// WithClientDrainLimit sets the maximum number of bytes read from an abandoned response body so its
+// connection can be returned to the pool; bodies larger than the limit are closed without draining
+// A negative limit disables draining
+func WithClientDrainLimit(limit int64) ClientConfigOption {
+	return func(c *ClientConfig) {
+		c.DrainLimit = limit
+	}
+}
+
+// WithClientJSONOptions sets how response bodies are decoded, e.g. rejecting unknown fields in contract tests
+// while production clients stay lenient
+func WithClientJSONOptions(options JSONOptions) ClientConfigOption {
+	return func(c *ClientConfig) {
+		c.JSONOptions = options
+	}
+}
+
+// WithClientJSONCodec replaces encoding/json with codec for JSON request and response bodies, e.g.
+// jsoniter.ConfigCompatibleWithStandardLibrary for faster decoding of large responses
+func WithClientJSONCodec(codec JSONCodec) ClientConfigOption {
+	return func(c *ClientConfig) {
+		c.JSONCodec = codec
+	}
+}
+
+// WithClientSerializationFallback resends request payloads set with WithJSONBody or WithEncodedBody in
+// mediaTypes, in order, when the server rejects their serialization with 415 Unsupported Media Type or
+// 406 Not Acceptable, e.g. for partner APIs accepting only XML or forms. Each media type is tried once, marshaled
+// by its registered codec, see RegisterCodec, and sent as the Content-Type and Accept of the request; the
+// response to the last attempt is returned.
+//
+//	httpx.WithClientSerializationFallback("application/xml", "application/x-www-form-urlencoded")
+func WithClientSerializationFallback(mediaTypes ...string) ClientConfigOption {
+	return func(c *ClientConfig) {
+		c.FallbackMediaTypes = mediaTypes
+	}
+}
+
+// WithClientBaseContext sets the application lifecycle context background work derives from, such as cache
+// refresh-ahead and service discovery refreshes, so it stops on shutdown rather than running forever
+func WithClientBaseContext(ctx context.Context) ClientConfigOption {
+	return func(c *ClientConfig) {
+		c.BaseContext = ctx
+	}
+}
+
+// WithClientDeliveryHistory sets the number of completed deliveries of Client.Send whose status is kept for
+// Client.DeliveryStatus, the oldest being forgotten first (default: 1000)
+func WithClientDeliveryHistory(size int) ClientConfigOption {
+	return func(c *ClientConfig) {
+		c.DeliveryHistory = size
+	}
+}
+
+// WithClientFlagProvider sets the provider evaluating the feature flags of toggled middlewares, such as those added
+// with WithClientToggledMiddleware, whose ToggleConfig sets no Provider
+func WithClientFlagProvider(provider FlagProvider) ClientConfigOption {
+	return func(c *ClientConfig) {
+		c.FlagProvider = provider
+	}
+}
+
+// WithClientToggledMiddleware adds a middleware that runs only while its feature flag is enabled for the request
+// host, e.g. verbose logging switched on for one upstream during an incident; see ToggledMiddleware
+func WithClientToggledMiddleware(middleware Middleware, config ToggleConfig) ClientConfigOption {
+	return func(c *ClientConfig) {
+		c.Middlewares = append(c.Middlewares, NewToggledMiddleware(middleware, config))
+	}
+}
+
+// WithClientAPIVersioning sets how the client sends API versions and reads the version served, e.g.
+// WithClientAPIVersioning(APIVersioning{Default: "2024-06-01", Scheme: APIVersionQuery}); see WithAPIVersion
+func WithClientAPIVersioning(versioning APIVersioning) ClientConfigOption {
+	return func(c *ClientConfig) {
+		c.APIVersioning = &versioning
+	}
+}
+
+// WithClientURLCanonicalizer sets how request URLs are canonicalized before the middleware chain. The canonical
+// URL is the one sent, and cache keys, metrics labels and request signatures are all derived from it, see
+// CanonicalRequest. Wrap CanonicalURL to extend the default normalization, e.g. to drop tracking parameters.
+func WithClientURLCanonicalizer(canonicalizer URLCanonicalizer) ClientConfigOption {
+	return func(c *ClientConfig) {
+		c.URLCanonicalizer = canonicalizer
+	}
+}
+
+// WithClientRequestTransformers appends transformers rewriting every request body after marshaling. They run
+// before the middleware chain, so compression and signing always apply to the transformed body
+func WithClientRequestTransformers(transformers ...RequestTransformer) ClientConfigOption {
+	return func(c *ClientConfig) {
+		c.RequestTransformers = append(c.RequestTransformers, transformers...)
+	}
+}
+
+// WithClientResponseTransformers appends transformers rewriting every response body before it reaches the
+// caller, such as RedactJSONFields, RenameJSONFields or TransformBody. They run in order.
+func WithClientResponseTransformers(transformers ...ResponseTransformer) ClientConfigOption {
+	return func(c *ClientConfig) {
+		c.ResponseTransformers = append(c.ResponseTransformers, transformers...)
+	}
+}
+
+// WithClientInterceptors appends interceptors observing and modifying every request payload before it is
+// marshaled and every response once decoded, see Interceptor. They run in order.
+func WithClientInterceptors(interceptors ...Interceptor) ClientConfigOption {
+	return func(c *ClientConfig) {
+		c.Interceptors = append(c.Interceptors, interceptors...)
+	}
+}
+
+// WithClientBodyDrainObserver sets an observer notified when response bodies are drained, discarded or leaked
+func WithClientBodyDrainObserver(observer BodyDrainObserver) ClientConfigOption {
+	return func(c *ClientConfig) {
+		c.BodyDrainObserver = observer
+	}
+}
+
+// WithClientEvents enables the Client.Events channel with room for buffer events (default: 64)
+// Events are dropped rather than delaying requests when the channel is full, see Client.DroppedEvents
+func WithClientEvents(buffer int) ClientConfigOption {
+	return func(c *ClientConfig) {
+		if buffer <= 0 {
+			buffer = defaultEventBuffer
+		}
+		c.EventBuffer = buffer
+	}
+}
+
+// WithClientCapture keeps the metadata of the last exchanges of the client in memory, with credentials redacted,
+// for Client.DebugDump and, with config.DumpOnSignal, SIGQUIT
+func WithClientCapture(config CaptureConfig) ClientConfigOption {
+	return func(c *ClientConfig) {
+		c.Capture = &config
+	}
+}
+
+// WithClientConnPoolObserver sets an observer notified of per-host connection pool changes, dials and TLS handshakes
+func WithClientConnPoolObserver(observer ConnPoolObserver) ClientConfigOption {
+	return func(c *ClientConfig) {
+		c.ConnPoolObserver = observer
+	}
+}
+
+// WithClientOutlierObserver sets an observer notified when outlier detection ejects a service endpoint or returns it
+// It applies to the service discovery added after it with WithClientServiceDiscovery; service middlewares created
+// with NewServiceMiddleware report to their OutlierConfig.Observer.
+func WithClientOutlierObserver(observer OutlierObserver) ClientConfigOption {
+	return func(c *ClientConfig) {
+		c.OutlierObserver = observer
+	}
+}
+
+// WithClientConnMaxLifetime retires connections once they are older than lifetime: an idle connection is closed
+// and a busy one is closed after its last request, so it is never reused. Retired connections are counted in
+// ConnPoolStats.Expired. Use it below the idle timeout of load balancers that drop connections silently.
+func WithClientConnMaxLifetime(lifetime time.Duration) ClientConfigOption {
+	return func(c *ClientConfig) {
+		c.ConnMaxLifetime = lifetime
+	}
+}
+
+// WithClientConnMaxIdleTime closes connections that stayed idle in the pool for longer than idle
+// Keep it below the idle timeout of load balancers between the client and the server, e.g. 350s for an AWS NLB.
+func WithClientConnMaxIdleTime(idle time.Duration) ClientConfigOption {
+	return func(c *ClientConfig) {
+		c.ConnMaxIdleTime = idle
+	}
+}
+
+// WithClientBandwidthLimit paces the bytes sent and received by the client to bytesPerSec in each direction,
+// over all its connections, so bulk transfers leave room on shared links to latency-sensitive traffic.
+// Up to burst bytes, or bytesPerSec if burst is 0, go through at once before pacing starts. Bytes are counted
+// on the wire, so TLS records and HTTP framing count as well as bodies.
+func WithClientBandwidthLimit(bytesPerSec, burst int64) ClientConfigOption {
+	return func(c *ClientConfig) {
+		c.BandwidthLimit = bytesPerSec
+		c.BandwidthBurst = burst
+	}
+}
+
 // WithClientCookieJar enables automatic cookie management with a standard cookie jar
 func WithClientCookieJar() ClientConfigOption {
 	return func(c *ClientConfig) {
@@ -544,6 +1282,14 @@ func WithClientNoProxy(domains []string) ClientConfigOption {
 	}
 }
 
+// WithClientProxyVia chains the proxy set with WithClientProxy behind the SOCKS proxy socksURL,
+// e.g. WithClientProxy("http://proxy.internal:3128") with WithClientProxyVia("socks5h://localhost:9050")
+func WithClientProxyVia(socksURL string) ClientConfigOption {
+	return func(c *ClientConfig) {
+		c.ProxyVia = socksURL
+	}
+}
+
 // WithClientProxyConfig sets a complete proxy configuration
 func WithClientProxyConfig(proxyConfig ProxyConfig) ClientConfigOption {
 	return func(c *ClientConfig) {
@@ -551,6 +1297,38 @@ func WithClientProxyConfig(proxyConfig ProxyConfig) ClientConfigOption {
 	}
 }
 
+// WithClientCertificateProvider presents the certificate of provider to servers requesting mutual TLS, replacing
+// the certificates of the TLS configuration. It is asked for on every handshake, so rotated certificates are used
+// by new connections without restarting the client or closing pooled connections, see FileCertificateProvider.
+func WithClientCertificateProvider(provider CertificateProvider) ClientConfigOption {
+	return func(c *ClientConfig) {
+		c.CertificateProvider = provider
+	}
+}
+
+// WithClientTLSConfig sets the TLS configuration used for outbound connections
+func WithClientTLSConfig(tlsConfig *tls.Config) ClientConfigOption {
+	return func(c *ClientConfig) {
+		c.TLSConfig = tlsConfig
+	}
+}
+
+// WithClientRevocationCheck enables certificate revocation checking using stapled OCSP responses
+// RevocationCheckSoftFail allows connections whose revocation status cannot be confirmed,
+// RevocationCheckHardFail rejects them
+func WithClientRevocationCheck(mode RevocationCheckMode) ClientConfigOption {
+	return func(c *ClientConfig) {
+		c.RevocationCheck = mode
+	}
+}
+
+// WithClientRevocationSoftFailHandler sets a callback invoked when a revocation check soft-fails
+func WithClientRevocationSoftFailHandler(handler RevocationSoftFailHandler) ClientConfigOption {
+	return func(c *ClientConfig) {
+		c.RevocationSoftFailHandler = handler
+	}
+}
+
 // WithClientSystemProxy enables proxy configuration from environment variables
 // Reads HTTP_PROXY, HTTPS_PROXY, and NO_PROXY environment variables
 func WithClientSystemProxy() ClientConfigOption {
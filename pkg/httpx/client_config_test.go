@@ -2,6 +2,7 @@ package httpx_test
 
 import (
 	"bytes"
+	"context"
 	"log/slog"
 	"net/http"
 	"net/http/httptest"
@@ -280,3 +281,133 @@ func TestBasicAuthSupport(t *testing.T) {
 		})
 	}
 }
+
+func TestWithoutHeader(t *testing.T) {
+	var received http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.Header.Clone()
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client := httpx.NewClientWithConfig(
+		httpx.WithClientDefaultBaseURL(server.URL),
+		httpx.WithClientDefaultHeader("X-Tenant", "default"),
+		httpx.WithClientDefaultHeader("X-Trace-Id", "abc"),
+	)
+
+	t.Run("removes a client default header", func(t *testing.T) {
+		_, err := client.Execute(*httpx.NewRequest(http.MethodGet, httpx.WithoutHeader("x-trace-id")), "")
+
+		require.NoError(t, err)
+		assert.Empty(t, received.Values("X-Trace-Id"))
+		assert.Equal(t, "default", received.Get("X-Tenant"))
+	})
+
+	t.Run("request header replaces default whatever its case", func(t *testing.T) {
+		_, err := client.Execute(*httpx.NewRequest(http.MethodGet, httpx.WithHeader("x-tenant", "acme")), "")
+
+		require.NoError(t, err)
+		assert.Equal(t, []string{"acme"}, received.Values("X-Tenant"))
+	})
+
+	t.Run("removal wins over a later WithHeader", func(t *testing.T) {
+		req := httpx.NewRequest(http.MethodGet, httpx.WithoutHeader("X-Tenant"), httpx.WithHeader("X-Tenant", "acme"))
+		_, err := client.Execute(*req, "")
+
+		require.NoError(t, err)
+		assert.Empty(t, received.Values("X-Tenant"))
+	})
+
+	t.Run("removes headers added by middlewares", func(t *testing.T) {
+		client := httpx.NewClientWithConfig(
+			httpx.WithClientDefaultBaseURL(server.URL),
+			httpx.WithClientMiddleware(httpx.NewUserAgentMiddleware("partner-sdk/1.0", false)),
+		)
+
+		_, err := client.Execute(*httpx.NewRequest(http.MethodGet, httpx.WithoutHeader("User-Agent")), "")
+
+		require.NoError(t, err)
+		assert.Empty(t, received.Get("User-Agent"), "neither the middleware nor the Go default User-Agent is sent")
+	})
+
+	t.Run("works with the legacy client", func(t *testing.T) {
+		client := httpx.NewClient(
+			httpx.WithDefaultBaseURL(server.URL),
+			httpx.WithDefaultHeader("X-Trace-Id", "abc"),
+		)
+
+		_, err := client.Execute(*httpx.NewRequest(http.MethodGet, httpx.WithoutHeader("X-Trace-Id")), "")
+
+		require.NoError(t, err)
+		assert.Empty(t, received.Values("X-Trace-Id"))
+	})
+
+	t.Run("invalid header name", func(t *testing.T) {
+		_, err := client.Execute(*httpx.NewRequest(http.MethodGet, httpx.WithoutHeader("Bad Header")), "")
+
+		assert.Error(t, err)
+	})
+}
+
+func TestRequestLogger(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	type userKey struct{}
+	newLogger := func(buf *bytes.Buffer) *slog.Logger {
+		return slog.New(slog.NewTextHandler(buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	}
+
+	var clientLogs, contextLogs, requestLogs bytes.Buffer
+	client := httpx.NewClientWithConfig(
+		httpx.WithClientDefaultBaseURL(server.URL),
+		httpx.WithClientLogger(newLogger(&clientLogs)),
+		httpx.WithClientLogLevel(slog.LevelDebug),
+		httpx.WithClientContextLogger(func(ctx context.Context) *slog.Logger {
+			user, ok := ctx.Value(userKey{}).(string)
+			if !ok {
+				return nil
+			}
+			return newLogger(&contextLogs).With("user", user)
+		}),
+	)
+
+	t.Run("falls back to the client logger", func(t *testing.T) {
+		_, err := client.Execute(*httpx.NewRequest(http.MethodGet), "")
+
+		require.NoError(t, err)
+		assert.Contains(t, clientLogs.String(), "HTTP response")
+		assert.Empty(t, contextLogs.String())
+	})
+
+	t.Run("uses the logger extracted from the context", func(t *testing.T) {
+		clientLogs.Reset()
+		ctx := context.WithValue(context.Background(), userKey{}, "alice")
+
+		_, err := client.Execute(*httpx.NewRequest(http.MethodGet, httpx.WithContext(ctx)), "")
+
+		require.NoError(t, err)
+		assert.Contains(t, contextLogs.String(), "HTTP response")
+		assert.Contains(t, contextLogs.String(), "user=alice")
+		assert.Empty(t, clientLogs.String())
+	})
+
+	t.Run("request logger takes precedence", func(t *testing.T) {
+		contextLogs.Reset()
+		ctx := context.WithValue(context.Background(), userKey{}, "alice")
+		req := httpx.NewRequest(http.MethodGet,
+			httpx.WithContext(ctx),
+			httpx.WithRequestLogger(newLogger(&requestLogs).With("session", "s1")),
+		)
+
+		_, err := client.Execute(*req, "")
+
+		require.NoError(t, err)
+		assert.Contains(t, requestLogs.String(), "session=s1")
+		assert.Empty(t, contextLogs.String())
+		assert.Empty(t, clientLogs.String())
+	})
+}
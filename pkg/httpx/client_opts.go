@@ -2,10 +2,13 @@ package httpx
 
 import (
 	"context"
+	"crypto/tls"
 	"io"
 	"log/slog"
+	"math/rand/v2"
 	"net/http"
 	"net/url"
+	"slices"
 	"time"
 )
 
@@ -22,6 +25,28 @@ type ClientConfig struct {
 	Logger   *slog.Logger  // Optional structured logger for all requests
 	LogLevel slog.Level    // Minimum log level for HTTP operations
 
+	// ContextLogger extracts a request-scoped logger, e.g. one carrying user or session fields, from the request
+	// context. A nil result falls back to Logger.
+	ContextLogger func(ctx context.Context) *slog.Logger
+
+	// LogFieldMapper renames the attribute keys of all client logs, see the LogField constants (nil keeps them)
+	LogFieldMapper LogFieldMapper
+
+	// Log sampling of request and response logs, keeping a different fraction of failures (nil logs every request)
+	LogSampling *LogSamplingConfig
+
+	// Slow request logging, independent of LogLevel
+	SlowRequestThreshold time.Duration // Calls taking at least this long are logged at Warn with a timing breakdown (0 disables)
+
+	// Granular timeouts, each reported as a distinct TimeoutPhase
+	DialTimeout           time.Duration // Max time to establish a TCP connection
+	TLSHandshakeTimeout   time.Duration // Max time to complete the TLS handshake
+	ResponseHeaderTimeout time.Duration // Max time to wait for response headers after the request is written
+	BodyReadTimeout       time.Duration // Max time to read the response body after headers are received
+
+	// Connection racing across the addresses of a host, each attempt bounded by DialTimeout (nil dials them in turn)
+	ConnectRace *ConnectRaceConfig
+
 	// Default values that can be overridden per request
 	DefaultBaseURL   string      // Default base URL for requests
 	DefaultHeaders   http.Header // Default headers applied to all requests
@@ -31,10 +56,22 @@ type ClientConfig struct {
 	ProxyURL    string       // HTTP/HTTPS/SOCKS proxy URL (e.g., "http://proxy.company.com:8080", "socks5://localhost:1080")
 	ProxyAuth   BasicAuth    // Proxy authentication credentials
 	NoProxy     []string     // Domains to bypass proxy (e.g., "localhost", "*.internal.com", "192.168.0.0/16")
+	ProxyVia    string       // SOCKS proxy the HTTP/HTTPS proxy is reached through (e.g., "socks5h://localhost:9050")
 	ProxyConfig *ProxyConfig // Internal proxy configuration (automatically populated from ProxyURL/ProxyAuth/NoProxy)
 
+	// TLS configuration
+	TLSConfig                 *tls.Config               // Optional TLS configuration for outbound connections
+	CertificateProvider       CertificateProvider       // Optional client certificate for mutual TLS, asked for on every handshake so it can rotate
+	RevocationCheck           RevocationCheckMode       // Certificate revocation checking mode (default: disabled)
+	TLSHandshaker             TLSHandshaker             // Optional replacement of the crypto/tls handshake, to control the TLS fingerprint
+	RevocationSoftFailHandler RevocationSoftFailHandler // Called when revocation status cannot be confirmed in soft-fail mode
+
 	// Retry configuration
-	RetryPolicy *RetryPolicy // Optional retry policy for all requests
+	RetryPolicy                 *RetryPolicy // Optional retry policy for all requests
+	RetryBudget                 *RetryBudget // Optional cap of the aggregate retries of the retry policy, unless it sets its own
+	DisableStaleConnectionRetry bool         // If true, requests failing on a stale keep-alive connection are not replayed once
+	RandSource                  rand.Source  // Random source for retry jitter (default: a per-client source seeded randomly)
+	Clock                       Clock        // Clock of retry backoffs and circuit breakers (default: the system clock)
 
 	// Circuit breaker configuration
 	CircuitBreakerConfig *CircuitBreakerConfig // Optional circuit breaker for fault tolerance
@@ -43,6 +80,59 @@ type ClientConfig struct {
 	CookieJar        http.CookieJar    // Automatic cookie jar for managing cookies across requests
 	CookieJarManager *CookieJarManager // Optional cookie jar manager with persistence utilities
 
+	// Response decoding
+	JSONOptions JSONOptions // Strictness of decoding response bodies into the response type
+	JSONCodec   JSONCodec   // JSON implementation of request and response bodies (nil uses encoding/json)
+
+	// Media types request payloads are resent in, in order, when the server rejects theirs with 415 or 406
+	FallbackMediaTypes []string
+
+	// Lifecycle
+	BaseContext     context.Context // Parent of background work such as cache refreshes and service discovery, stopped once it is done
+	DeliveryHistory int             // Number of completed deliveries of Client.Send whose status is kept (default: 1000)
+
+	// Feature flags
+	FlagProvider FlagProvider // Evaluates the flags of toggled middlewares without a provider of their own
+
+	// API versioning
+	APIVersioning *APIVersioning // How the API version of requests is sent and the served one read (nil sends WithAPIVersion in an API-Version header)
+
+	// Request identification
+	URLCanonicalizer URLCanonicalizer // Canonical form of request URLs, sent and used by cache keys, metrics and signatures (nil uses CanonicalURL)
+
+	// Body pre- and post-processing, applied in order
+	RequestTransformers  []RequestTransformer  // Rewrite request bodies after marshaling, before any middleware
+	ResponseTransformers []ResponseTransformer // Rewrite response bodies before they reach the caller
+	Interceptors         []Interceptor         // Observe and modify request payloads before marshaling and responses once decoded
+
+	// Response body cleanup
+	DrainLimit        int64             // Max bytes read from an abandoned response body so its connection can be reused (default: 256KiB, negative disables draining)
+	BodyDrainObserver BodyDrainObserver // Optional observer notified when bodies are drained, discarded or leaked
+
+	// Structured events
+	EventBuffer int // Capacity of the Client.Events channel, events are disabled when 0
+
+	// Capture of the last exchanges for crash diagnostics, see Client.DebugDump (nil disables)
+	Capture *CaptureConfig
+
+	// Connection pool observability
+	ConnPoolObserver ConnPoolObserver // Optional observer notified of per-host connection pool changes, dials and TLS handshakes
+	ConnMaxLifetime  time.Duration    // Connections are retired once this old, closed when idle and never reused (0 disables)
+	ConnMaxIdleTime  time.Duration    // Idle connections are closed after this long (0 keeps the transport default of 90s)
+
+	// Bandwidth throttling
+	BandwidthLimit int64 // Bytes per second sent, and received, over all connections of the client (0 = unlimited)
+	BandwidthBurst int64 // Bytes sent or received at once before pacing starts (default: BandwidthLimit)
+
+	// Service discovery observability
+	OutlierObserver OutlierObserver // Optional observer of ejections of service discovery added by the client
+
+	// Concurrency limit observability
+	ConcurrencyObserver ConcurrencyObserver // Optional observer of the adaptive concurrency limiter added by the client
+
+	// Cache observability
+	CacheObserver CacheObserver // Optional observer of the cache added by the client, see CacheConfig.Observer
+
 	// Middleware configuration
 	Middlewares []Middleware // Ordered list of middlewares to apply to all requests
 }
@@ -68,21 +158,38 @@ type RequestConfig struct {
 	Method string // HTTP method (GET, POST, etc.)
 
 	// URL components
-	BaseURL string // Base URL for this request (overrides client default)
-	Path    string // Path to append to base URL
+	BaseURL    string            // Base URL for this request (overrides client default)
+	Path       string            // Path to append to base URL
+	PathParams map[string]string // Values of the {name} placeholders of Path
 
 	// Request modifiers
-	Headers     http.Header // Headers for this request (merged with client defaults)
+	Headers     http.Header // Headers for this request, replacing client default values of the same name
 	QueryParams url.Values  // Query parameters for this request
 	Body        io.Reader   // Request body
 	BasicAuth   BasicAuth   // Basic auth for this request (overrides client default)
 
 	// Request behavior
-	Context        context.Context // Request context for cancellation/timeout
-	Timeout        time.Duration   // Request timeout (overrides client default)
-	Streaming      bool            // If true, response body will not be read into memory
-	Cookies        []*http.Cookie  // Cookies to add to this specific request
-	DisableCookies bool            // If true, disables cookie jar for this specific request
+	Context              context.Context        // Request context for cancellation/timeout
+	Timeout              time.Duration          // Request timeout (overrides client default)
+	Streaming            bool                   // If true, response body will not be read into memory
+	StreamTee            io.Writer              // If set, receives a copy of the response body as it is read
+	StreamIdleTimeout    time.Duration          // If set, the response body is aborted when no data arrives for this long
+	StreamIdleReconnects int                    // Number of times a stalled response is re-requested before failing
+	StreamReconnect      *StreamReconnectPolicy // If set, the response is re-requested after transient drops and stalls
+	Annotations          map[string]string      // Business context attached to errors, logs and spans of this request
+	Operation            string                 // Operation name reported in HTTPError.Op (default: method and path)
+	Cookies              []*http.Cookie         // Cookies to add to this specific request
+	DisableCookies       bool                   // If true, disables cookie jar for this specific request
+	RemovedHeaders       []string               // Headers never sent with this request, including client defaults
+	Logger               *slog.Logger           // Logger for this request (overrides client logger)
+	AffinityKey          string                 // Key hashed to an endpoint by NewConsistentHashBalancer
+	APIVersion           string                 // API version of this request (overrides APIVersioning.Default)
+	Middlewares          []Middleware           // Middlewares run for this request only, after the client middlewares
+	MultipartParts       []MultipartPart        // Parts of a multipart/form-data body streamed in place of Body
+	ResponseDecoder      Codec                  // Codec decoding the response body (default: by Content-Type)
+	Cache                RequestCacheOptions    // Cache directives for this request
+	InformationalHandler InformationalHandler   // Called with the interim 1xx responses of this request
+	FallbackMediaTypes   []string               // Media types the payload is resent in when rejected (overrides the client ones)
 
 	// Proxy configuration (overrides client proxy for this specific request)
 	ProxyURL     string    // Proxy URL for this request (overrides client proxy)
@@ -97,22 +204,39 @@ type RequestConfig struct {
 //
 // Deprecated: Use RequestConfig for new code. Maintained for backward compatibility.
 type RequestOptions struct {
-	Method         string
-	BaseURL        string
-	Headers        http.Header
-	QueryParams    url.Values
-	Body           io.Reader
-	BasicAuth      BasicAuth
-	Path           string
-	Timeout        time.Duration
-	Context        context.Context
-	Error          error          // Stores errors from RequestOptions that can't return errors directly
-	Streaming      bool           // If true, response body will not be read into memory
-	Cookies        []*http.Cookie // Cookies to add to this specific request
-	DisableCookies bool           // If true, disables cookie jar for this specific request
-	ProxyURL       string         // Proxy URL for this request (overrides client proxy)
-	ProxyAuth      BasicAuth      // Proxy auth for this request
-	DisableProxy   bool           // If true, disables proxy for this specific request
+	Method               string
+	BaseURL              string
+	Headers              http.Header
+	QueryParams          url.Values
+	Body                 io.Reader
+	BasicAuth            BasicAuth
+	Path                 string
+	PathParams           map[string]string // Values of the {name} placeholders of Path
+	Timeout              time.Duration
+	Context              context.Context
+	Error                error                  // Stores errors from RequestOptions that can't return errors directly
+	Streaming            bool                   // If true, response body will not be read into memory
+	StreamTee            io.Writer              // If set, receives a copy of the response body as it is read
+	StreamIdleTimeout    time.Duration          // If set, the response body is aborted when no data arrives for this long
+	StreamIdleReconnects int                    // Number of times a stalled response is re-requested before failing
+	StreamReconnect      *StreamReconnectPolicy // If set, the response is re-requested after transient drops and stalls
+	Annotations          map[string]string      // Business context attached to errors, logs and spans of this request
+	Operation            string                 // Operation name reported in HTTPError.Op (default: method and path)
+	Cookies              []*http.Cookie         // Cookies to add to this specific request
+	DisableCookies       bool                   // If true, disables cookie jar for this specific request
+	RemovedHeaders       []string               // Headers never sent with this request, including client defaults
+	Logger               *slog.Logger           // Logger for this request (overrides client logger)
+	AffinityKey          string                 // Key hashed to an endpoint by NewConsistentHashBalancer
+	APIVersion           string                 // API version of this request (overrides APIVersioning.Default)
+	Middlewares          []Middleware           // Middlewares run for this request only, after the client middlewares
+	MultipartParts       []MultipartPart        // Parts of a multipart/form-data body streamed in place of Body
+	ResponseDecoder      Codec                  // Codec decoding the response body (default: by Content-Type)
+	Cache                RequestCacheOptions    // Cache directives for this request
+	InformationalHandler InformationalHandler   // Called with the interim 1xx responses of this request
+	FallbackMediaTypes   []string               // Media types the payload is resent in when rejected (overrides the client ones)
+	ProxyURL             string                 // Proxy URL for this request (overrides client proxy)
+	ProxyAuth            BasicAuth              // Proxy auth for this request
+	DisableProxy         bool                   // If true, disables proxy for this specific request
 }
 
 // ClientConfigOption is a function that modifies ClientConfig
@@ -139,22 +263,39 @@ func (c ClientConfig) ToClientOptions() ClientOptions {
 // ToRequestOptions converts RequestConfig to RequestOptions for backward compatibility
 func (r RequestConfig) ToRequestOptions() RequestOptions {
 	return RequestOptions{
-		Method:         r.Method,
-		BaseURL:        r.BaseURL,
-		Headers:        r.Headers,
-		QueryParams:    r.QueryParams,
-		Body:           r.Body,
-		BasicAuth:      r.BasicAuth,
-		Path:           r.Path,
-		Timeout:        r.Timeout,
-		Context:        r.Context,
-		Error:          r.Error,
-		Streaming:      r.Streaming,
-		Cookies:        r.Cookies,
-		DisableCookies: r.DisableCookies,
-		ProxyURL:       r.ProxyURL,
-		ProxyAuth:      r.ProxyAuth,
-		DisableProxy:   r.DisableProxy,
+		Method:               r.Method,
+		BaseURL:              r.BaseURL,
+		Headers:              r.Headers,
+		QueryParams:          r.QueryParams,
+		Body:                 r.Body,
+		BasicAuth:            r.BasicAuth,
+		Path:                 r.Path,
+		PathParams:           r.PathParams,
+		Timeout:              r.Timeout,
+		Context:              r.Context,
+		Error:                r.Error,
+		Streaming:            r.Streaming,
+		StreamTee:            r.StreamTee,
+		StreamIdleTimeout:    r.StreamIdleTimeout,
+		StreamIdleReconnects: r.StreamIdleReconnects,
+		StreamReconnect:      r.StreamReconnect,
+		Annotations:          r.Annotations,
+		Operation:            r.Operation,
+		Cookies:              r.Cookies,
+		DisableCookies:       r.DisableCookies,
+		RemovedHeaders:       r.RemovedHeaders,
+		Logger:               r.Logger,
+		AffinityKey:          r.AffinityKey,
+		APIVersion:           r.APIVersion,
+		Middlewares:          r.Middlewares,
+		MultipartParts:       r.MultipartParts,
+		ResponseDecoder:      r.ResponseDecoder,
+		Cache:                r.Cache,
+		InformationalHandler: r.InformationalHandler,
+		FallbackMediaTypes:   r.FallbackMediaTypes,
+		ProxyURL:             r.ProxyURL,
+		ProxyAuth:            r.ProxyAuth,
+		DisableProxy:         r.DisableProxy,
 	}
 }
 
@@ -174,10 +315,10 @@ func (r *RequestConfig) MergeWithDefaults(clientConfig ClientConfig) {
 		r.Headers = make(http.Header)
 	}
 
-	// Copy client default headers first
+	// Copy client default headers not set or removed by the request; header names are case-insensitive
 	for key, values := range clientConfig.DefaultHeaders {
-		if _, exists := r.Headers[key]; !exists {
-			r.Headers[key] = values
+		if !hasHeader(r.Headers, key) && !containsHeaderName(r.RemovedHeaders, key) {
+			r.Headers[key] = slices.Clone(values)
 		}
 	}
 
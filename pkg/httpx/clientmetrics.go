@@ -0,0 +1,94 @@
+package httpx
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// ClientMetrics counts the activity of a client since it was created, see Client.Metrics
+// Snapshots are cheap and always available, unlike Prometheus metrics; subtract two snapshots with Sub to
+// get the activity in between.
+type ClientMetrics struct {
+	Requests  int64 // Requests executed, however many attempts they took
+	Errors    int64 // Requests that failed with an error, error responses are not counted
+	Attempts  int64 // Requests sent to the server, retries included and responses served from the cache excluded
+	Retries   int64 // Attempts retried by the retry middlewares
+	CacheHits int64 // Responses served from the cache, revalidated ones included
+}
+
+// Sub returns the activity counted by m since the earlier snapshot before
+func (m ClientMetrics) Sub(before ClientMetrics) ClientMetrics {
+	return ClientMetrics{
+		Requests:  m.Requests - before.Requests,
+		Errors:    m.Errors - before.Errors,
+		Attempts:  m.Attempts - before.Attempts,
+		Retries:   m.Retries - before.Retries,
+		CacheHits: m.CacheHits - before.CacheHits,
+	}
+}
+
+// clientMetrics holds the counters of a client, nil counts nothing
+type clientMetrics struct {
+	requests  atomic.Int64
+	errors    atomic.Int64
+	attempts  atomic.Int64
+	retries   atomic.Int64
+	cacheHits atomic.Int64
+}
+
+// completed counts a request which returned err
+func (m *clientMetrics) completed(err error) {
+	if m == nil {
+		return
+	}
+	m.requests.Add(1)
+	if err != nil {
+		m.errors.Add(1)
+	}
+}
+
+// attempted counts a request sent to the server
+func (m *clientMetrics) attempted() {
+	if m != nil {
+		m.attempts.Add(1)
+	}
+}
+
+// snapshot returns the current value of the counters
+func (m *clientMetrics) snapshot() ClientMetrics {
+	if m == nil {
+		return ClientMetrics{}
+	}
+	return ClientMetrics{
+		Requests:  m.requests.Load(),
+		Errors:    m.errors.Load(),
+		Attempts:  m.attempts.Load(),
+		Retries:   m.retries.Load(),
+		CacheHits: m.cacheHits.Load(),
+	}
+}
+
+// clientMetricsKey is the context key of the client counters
+type clientMetricsKey struct{}
+
+// contextWithClientMetrics returns a context carrying the counters middlewares report to
+func contextWithClientMetrics(ctx context.Context, metrics *clientMetrics) context.Context {
+	if metrics == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, clientMetricsKey{}, metrics)
+}
+
+// countRetry counts a retry in the client counters of the request context, if any
+func countRetry(ctx context.Context) {
+	if metrics, ok := ctx.Value(clientMetricsKey{}).(*clientMetrics); ok {
+		metrics.retries.Add(1)
+	}
+}
+
+// countCacheHit counts a response served from the cache in the client counters of the request context, if any
+func countCacheHit(ctx context.Context) {
+	if metrics, ok := ctx.Value(clientMetricsKey{}).(*clientMetrics); ok {
+		metrics.cacheHits.Add(1)
+	}
+}
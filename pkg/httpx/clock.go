@@ -0,0 +1,61 @@
+package httpx
+
+import "time"
+
+// Clock is the source of time of retry backoffs and circuit breakers
+// Replace it with a fake clock, see httpxtesting.NewFakeClock, to test resilience behavior without sleeping.
+type Clock interface {
+	// Now returns the current time
+	Now() time.Time
+	// NewTimer returns a timer sending the current time on its channel once d elapsed
+	NewTimer(d time.Duration) Timer
+}
+
+// Timer is a single-use timer created by a Clock
+type Timer interface {
+	// C returns the channel the time is sent on when the timer fires
+	C() <-chan time.Time
+	// Stop prevents the timer from firing, reporting whether it was stopped before firing
+	Stop() bool
+}
+
+// SystemClock returns the clock of the operating system, used when no clock is configured
+func SystemClock() Clock {
+	return systemClock{}
+}
+
+// systemClock implements Clock with the time package
+type systemClock struct{}
+
+// Now implements Clock
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// NewTimer implements Clock
+func (systemClock) NewTimer(d time.Duration) Timer {
+	return systemTimer{time.NewTimer(d)}
+}
+
+// systemTimer implements Timer with a time.Timer
+type systemTimer struct {
+	timer *time.Timer
+}
+
+// C implements Timer
+func (t systemTimer) C() <-chan time.Time {
+	return t.timer.C
+}
+
+// Stop implements Timer
+func (t systemTimer) Stop() bool {
+	return t.timer.Stop()
+}
+
+// clockOrSystem returns clock, or the system clock if nil
+func clockOrSystem(clock Clock) Clock {
+	if clock == nil {
+		return systemClock{}
+	}
+	return clock
+}
@@ -0,0 +1,155 @@
+package httpx
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"mime"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// Codec marshals request bodies and unmarshals response bodies of a media type, see RegisterCodec
+// JSONCodec implementations satisfy it, and JSONCodecFuncs adapts other libraries, e.g. for YAML or msgpack:
+//
+//	httpx.RegisterCodec("application/yaml", httpx.JSONCodecFuncs{MarshalFunc: yaml.Marshal, UnmarshalFunc: yaml.Unmarshal})
+//	httpx.RegisterCodec("application/msgpack", httpx.JSONCodecFuncs{MarshalFunc: msgpack.Marshal, UnmarshalFunc: msgpack.Unmarshal})
+type Codec interface {
+	Marshal(v any) ([]byte, error)
+	Unmarshal(data []byte, v any) error
+}
+
+// XMLCodec is the Codec of XML bodies with encoding/xml, registered for application/xml and text/xml
+type XMLCodec struct{}
+
+// Marshal implements Codec
+func (XMLCodec) Marshal(v any) ([]byte, error) {
+	return xml.Marshal(v)
+}
+
+// Unmarshal implements Codec
+func (XMLCodec) Unmarshal(data []byte, v any) error {
+	return xml.Unmarshal(data, v)
+}
+
+// codecs is the registry of codecs by media type
+var codecs = struct {
+	mu     sync.RWMutex
+	byType map[string]Codec
+}{byType: map[string]Codec{
+	"application/xml": XMLCodec{},
+	"text/xml":        XMLCodec{},
+}}
+
+// RegisterCodec registers the codec decoding response bodies of a media type, e.g. "application/yaml", and
+// encoding request bodies set with WithEncodedBody; a nil codec unregisters it. Media types are matched without
+// their parameters, and those with a structured syntax suffix fall back to the codec of the suffix, e.g.
+// "application/problem+xml" to that of "application/xml". JSON and responses of media types without a codec
+// are decoded with the client JSONCodec, as before, unless a codec is registered for application/json.
+func RegisterCodec(mediaType string, codec Codec) {
+	mediaType = normalizeMediaType(mediaType)
+	codecs.mu.Lock()
+	defer codecs.mu.Unlock()
+	if codec == nil {
+		delete(codecs.byType, mediaType)
+		return
+	}
+	codecs.byType[mediaType] = codec
+}
+
+// codecFor returns the codec registered for the media type of contentType
+func codecFor(contentType string) (Codec, bool) {
+	mediaType := normalizeMediaType(contentType)
+	codecs.mu.RLock()
+	defer codecs.mu.RUnlock()
+	if codec, ok := codecs.byType[mediaType]; ok {
+		return codec, true
+	}
+	if i := strings.LastIndex(mediaType, "+"); i >= 0 {
+		codec, ok := codecs.byType["application/"+mediaType[i+1:]]
+		return codec, ok
+	}
+	return nil, false
+}
+
+// normalizeMediaType returns the media type of a Content-Type without parameters, in lower case
+func normalizeMediaType(contentType string) string {
+	if mediaType, _, err := mime.ParseMediaType(contentType); err == nil {
+		return mediaType
+	}
+	return strings.ToLower(strings.TrimSpace(contentType))
+}
+
+// isJSONMediaType reports whether the media type is JSON or has the +json suffix
+func isJSONMediaType(mediaType string) bool {
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+// encodedBody is the body set by WithEncodedBody, marshaled with the codec of its media type when the request
+// is built. It marshals itself if read before.
+type encodedBody struct {
+	mediaType string
+	value     any
+	reader    *bytes.Reader
+}
+
+// Read implements io.Reader
+func (b *encodedBody) Read(p []byte) (int, error) {
+	if b.reader == nil {
+		content, err := b.marshal(nil)
+		if err != nil {
+			return 0, err
+		}
+		b.reader = bytes.NewReader(content)
+	}
+	return b.reader.Read(p)
+}
+
+// marshal encodes the value with the codec of the media type, JSON media types without a codec with jsonCodec
+// or encoding/json if nil
+func (b *encodedBody) marshal(jsonCodec JSONCodec) ([]byte, error) {
+	codec, ok := codecFor(b.mediaType)
+	switch {
+	case ok:
+	case isJSONMediaType(normalizeMediaType(b.mediaType)):
+		codec = jsonCodec
+		if codec == nil {
+			codec = JSONCodecFuncs{MarshalFunc: json.Marshal}
+		}
+	default:
+		return nil, errors.Errorf("no codec registered for media type %q, see RegisterCodec", b.mediaType)
+	}
+	content, err := codec.Marshal(b.value)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to marshal %s body", b.mediaType)
+	}
+	return content, nil
+}
+
+// marshalEncodedBody replaces a body set by WithEncodedBody with its encoding by the codec of its media type
+func marshalEncodedBody(opts *RequestOptions, jsonCodec JSONCodec) error {
+	body, ok := opts.Body.(*encodedBody)
+	if !ok || body.reader != nil {
+		return nil
+	}
+	content, err := body.marshal(jsonCodec)
+	if err != nil {
+		return err
+	}
+	opts.Body = bytes.NewReader(content)
+	return nil
+}
+
+// responseCodec returns the codec decoding a response body: the decoder of the request, else the codec
+// registered for the Content-Type, or nil to decode it as JSON
+func responseCodec(response *Response) Codec {
+	if response.decoder != nil {
+		return response.decoder
+	}
+	if codec, ok := codecFor(response.header.Get("Content-Type")); ok {
+		return codec
+	}
+	return nil
+}
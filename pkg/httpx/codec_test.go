@@ -0,0 +1,134 @@
+package httpx_test
+
+import (
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bdpiprava/easy-http/pkg/httpx"
+)
+
+type xmlUser struct {
+	XMLName xml.Name `xml:"user"`
+	ID      int      `xml:"id,attr"`
+	Name    string   `xml:"name"`
+}
+
+// keyValueCodec encodes a map[string]string as key=value lines
+type keyValueCodec struct{}
+
+func (keyValueCodec) Marshal(v any) ([]byte, error) {
+	var lines []string
+	for key, value := range v.(map[string]string) {
+		lines = append(lines, key+"="+value)
+	}
+	return []byte(strings.Join(lines, "\n")), nil
+}
+
+func (keyValueCodec) Unmarshal(data []byte, v any) error {
+	values := map[string]string{}
+	for _, line := range strings.Split(string(data), "\n") {
+		key, value, _ := strings.Cut(line, "=")
+		values[key] = value
+	}
+	switch target := v.(type) {
+	case *map[string]string:
+		*target = values
+	case *any:
+		*target = values
+	}
+	return nil
+}
+
+func TestResponseCodecs(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", r.URL.Query().Get("type"))
+		if r.Method == http.MethodPost {
+			body, _ := io.ReadAll(r.Body)
+			w.Header().Set("X-Request-Type", r.Header.Get("Content-Type"))
+			_, _ = w.Write(body)
+			return
+		}
+		_, _ = w.Write([]byte(r.URL.Query().Get("body")))
+	}))
+	defer server.Close()
+	client := httpx.NewClientWithConfig(httpx.WithClientDefaultBaseURL(server.URL))
+	get := func(contentType, body string, opts ...httpx.RequestOption) httpx.Request {
+		opts = append(opts, httpx.WithQueryParam("type", contentType), httpx.WithQueryParam("body", body))
+		return *httpx.NewRequest(http.MethodGet, opts...)
+	}
+
+	t.Run("decodes XML responses by Content-Type", func(t *testing.T) {
+		for _, contentType := range []string{"application/xml", "text/xml; charset=utf-8", "application/atom+xml"} {
+			resp, err := client.Execute(get(contentType, `<user id="7"><name>Ada</name></user>`), xmlUser{})
+			require.NoError(t, err, contentType)
+
+			assert.Equal(t, 7, resp.Body.(xmlUser).ID)
+			assert.Equal(t, "Ada", resp.Body.(xmlUser).Name)
+		}
+	})
+
+	t.Run("keeps decoding other responses as JSON", func(t *testing.T) {
+		resp, err := client.Execute(get("application/octet-stream", `{"name":"Ada"}`), map[string]any{})
+		require.NoError(t, err)
+
+		assert.Equal(t, map[string]any{"name": "Ada"}, resp.Body)
+	})
+
+	t.Run("decodes with registered codecs", func(t *testing.T) {
+		httpx.RegisterCodec("application/x-key-value", keyValueCodec{})
+		defer httpx.RegisterCodec("application/x-key-value", nil)
+
+		resp, err := client.Execute(get("application/x-key-value", "name=Ada"), nil)
+		require.NoError(t, err)
+
+		assert.Equal(t, map[string]string{"name": "Ada"}, resp.Body)
+	})
+
+	t.Run("forces the response decoder", func(t *testing.T) {
+		resp, err := client.Execute(get("text/plain", `<user id="7"><name>Ada</name></user>`, httpx.WithResponseDecoder(httpx.XMLCodec{})), xmlUser{})
+		require.NoError(t, err)
+
+		assert.Equal(t, "Ada", resp.Body.(xmlUser).Name)
+	})
+
+	t.Run("encodes XML request bodies", func(t *testing.T) {
+		req := httpx.NewRequest(http.MethodPost,
+			httpx.WithQueryParam("type", "application/xml"),
+			httpx.WithXMLBody(xmlUser{ID: 7, Name: "Ada"}),
+		)
+
+		resp, err := client.Execute(*req, xmlUser{})
+		require.NoError(t, err)
+
+		assert.Equal(t, "application/xml", resp.Header().Get("X-Request-Type"))
+		assert.Equal(t, `<user id="7"><name>Ada</name></user>`, string(resp.RawBody))
+		assert.Equal(t, "Ada", resp.Body.(xmlUser).Name)
+	})
+
+	t.Run("encodes JSON request bodies without a registered codec", func(t *testing.T) {
+		req := httpx.NewRequest(http.MethodPost,
+			httpx.WithQueryParam("type", "application/vnd.api+json"),
+			httpx.WithEncodedBody("application/vnd.api+json", map[string]string{"name": "Ada"}),
+		)
+
+		resp, err := client.Execute(*req, map[string]string{})
+		require.NoError(t, err)
+
+		assert.Equal(t, map[string]string{"name": "Ada"}, resp.Body)
+	})
+
+	t.Run("fails on bodies without a codec", func(t *testing.T) {
+		req := httpx.NewRequest(http.MethodPost, httpx.WithEncodedBody("application/x-unknown", "value"))
+
+		_, err := client.Execute(*req, nil)
+
+		assert.ErrorContains(t, err, `no codec registered for media type "application/x-unknown"`)
+	})
+}
@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"log/slog"
 	"net/http"
+	"net/url"
+	"path"
 	"strconv"
 	"time"
 )
@@ -13,6 +15,7 @@ import (
 type LoggingMiddleware struct {
 	logger   *slog.Logger
 	logLevel slog.Level
+	sampler  *logSampler
 }
 
 // NewLoggingMiddleware creates a new logging middleware
@@ -26,36 +29,69 @@ func NewLoggingMiddleware(logger *slog.Logger, level slog.Level) *LoggingMiddlew
 	}
 }
 
+// NewSampledLoggingMiddleware creates a logging middleware that logs only a sample of the requests
+// The request record is held back until the outcome is known, so sampled requests are logged in full.
+func NewSampledLoggingMiddleware(logger *slog.Logger, level slog.Level, sampling LogSamplingConfig) *LoggingMiddleware {
+	m := NewLoggingMiddleware(logger, level)
+	m.sampler = newLogSampler(sampling)
+	return m
+}
+
 // Name returns the middleware name
 func (m *LoggingMiddleware) Name() string {
 	return "logging"
 }
 
+// SamplingStats returns the counters of requests logged or suppressed by sampling
+// They are zero for a middleware created without sampling.
+func (m *LoggingMiddleware) SamplingStats() LogSamplingStats {
+	return m.sampler.stats()
+}
+
 // Execute implements the Middleware interface
 func (m *LoggingMiddleware) Execute(ctx context.Context, req *http.Request, next MiddlewareFunc) (*http.Response, error) {
-	if !m.logger.Enabled(ctx, m.logLevel) {
+	logger := loggerFromContext(ctx, m.logger)
+	if !logger.Enabled(ctx, m.logLevel) {
 		return next(ctx, req)
 	}
 
-	// Log the outgoing request
-	m.logger.LogAttrs(ctx, slog.LevelDebug, "HTTP request",
-		slog.String("method", req.Method),
-		slog.String("url", req.URL.String()),
-		slog.String("host", req.Host),
-		slog.Any("headers", req.Header),
+	contextAttrs := requestLogAttrs(ctx)
+	if annotations, ok := annotationLogAttr(AnnotationsFromContext(ctx)); ok {
+		contextAttrs = append(contextAttrs, annotations)
+	}
+	withContext := func(attrs ...slog.Attr) []slog.Attr {
+		return append(attrs, contextAttrs...)
+	}
+
+	// Log the outgoing request, or hold it back until the sampling decision
+	requestAttrs := withContext(
+		slog.String(LogFieldMethod, req.Method),
+		slog.String(LogFieldURL, req.URL.String()),
+		slog.String(LogFieldHost, req.Host),
+		slog.Any(LogFieldRequestHeaders, req.Header),
 	)
+	if m.sampler == nil {
+		logger.LogAttrs(ctx, slog.LevelDebug, "HTTP request", requestAttrs...)
+	}
 
 	start := time.Now()
 	resp, err := next(ctx, req)
 	duration := time.Since(start)
 
+	if m.sampler != nil {
+		if !m.sampler.sample(err != nil || resp.StatusCode >= 400) {
+			return resp, err
+		}
+		logger.LogAttrs(ctx, slog.LevelDebug, "HTTP request", requestAttrs...)
+	}
+
 	if err != nil {
-		m.logger.LogAttrs(ctx, slog.LevelError, "Failed to execute HTTP request",
-			slog.String("method", req.Method),
-			slog.String("url", req.URL.String()),
-			slog.Duration("duration", duration),
-			slog.String("error", err.Error()),
-		)
+		logger.LogAttrs(ctx, slog.LevelError, "Failed to execute HTTP request", withContext(
+			slog.String(LogFieldMethod, req.Method),
+			slog.String(LogFieldURL, req.URL.String()),
+			durationAttr(LogFieldDuration, duration),
+			slog.String(LogFieldError, err.Error()),
+		)...)
 		return nil, err
 	}
 
@@ -68,17 +104,38 @@ func (m *LoggingMiddleware) Execute(ctx context.Context, req *http.Request, next
 		level = slog.LevelError
 	}
 
-	m.logger.LogAttrs(ctx, level, "HTTP response",
-		slog.Int("status_code", resp.StatusCode),
-		slog.String("status", resp.Status),
-		slog.Duration("duration", duration),
-		slog.String("content_length", resp.Header.Get("Content-Length")),
-		slog.String("content_type", resp.Header.Get("Content-Type")),
-	)
+	logger.LogAttrs(ctx, level, "HTTP response", withContext(
+		slog.String(LogFieldMethod, req.Method),
+		slog.String(LogFieldURL, req.URL.String()),
+		slog.Int(LogFieldStatus, resp.StatusCode),
+		slog.String(LogFieldStatusText, resp.Status),
+		durationAttr(LogFieldDuration, duration),
+		slog.String(LogFieldContentLength, resp.Header.Get("Content-Length")),
+		slog.String(LogFieldContentType, resp.Header.Get("Content-Type")),
+	)...)
 
 	return resp, nil
 }
 
+// loggerKey is the context key holding the logger of a single request
+type loggerKey struct{}
+
+// contextWithLogger returns a context carrying the logger used for a single request in place of the client logger
+func contextWithLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	if logger == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// loggerFromContext returns the request logger carried by ctx, or fallback if there is none
+func loggerFromContext(ctx context.Context, fallback *slog.Logger) *slog.Logger {
+	if logger, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok {
+		return logger
+	}
+	return fallback
+}
+
 // RetryMiddleware implements automatic retry logic with exponential backoff
 type RetryMiddleware struct {
 	maxRetries int
@@ -181,6 +238,19 @@ func (m *RetryMiddleware) Execute(ctx context.Context, req *http.Request, next M
 		if delay > m.maxDelay {
 			delay = m.maxDelay
 		}
+		if deadlineErr := retryDeadlineError(ctx, req, systemClock{}, attempt+1, delay, err, resp); deadlineErr != nil {
+			return nil, deadlineErr
+		}
+		countRetry(ctx)
+		emitEvent(ctx, RetryEvent{
+			Time:        time.Now(),
+			Op:          requestOperation(req),
+			Attempt:     attempt + 2,
+			MaxAttempts: m.maxRetries + 1,
+			Delay:       delay,
+			StatusCode:  statusCodeOf(resp),
+			Err:         err,
+		})
 
 		// Wait before retrying
 		select {
@@ -234,8 +304,9 @@ func (m *MetricsMiddleware) Name() string {
 
 // Execute implements the Middleware interface
 func (m *MetricsMiddleware) Execute(ctx context.Context, req *http.Request, next MiddlewareFunc) (*http.Response, error) {
-	method := req.Method
-	url := req.URL.String()
+	canonical := CanonicalRequestOf(req)
+	method := canonical.Method
+	url := metricsURL(req.Context(), canonical.URL)
 
 	m.collector.IncrementRequests(method, url)
 
@@ -273,6 +344,19 @@ func (m *MetricsMiddleware) Execute(ctx context.Context, req *http.Request, next
 	return resp, nil
 }
 
+// metricsURL returns the URL reported to metrics collectors, with the path template in place of the path and
+// no query for requests built with path parameters, so labels derived from it stay low-cardinality
+func metricsURL(ctx context.Context, u *url.URL) string {
+	template := PathTemplateFromContext(ctx)
+	if template == "" {
+		return u.String()
+	}
+	reported := *u
+	reported.Path, reported.RawPath = path.Join("/", template), ""
+	reported.RawQuery, reported.ForceQuery = "", false
+	return reported.String()
+}
+
 // UserAgentMiddleware adds or modifies the User-Agent header
 type UserAgentMiddleware struct {
 	userAgent string
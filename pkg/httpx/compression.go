@@ -27,8 +27,16 @@ type CompressionConfig struct {
 	EnableRequest      bool     // Compress request bodies
 	EnableResponse     bool     // Decompress response bodies (add Accept-Encoding)
 	PreferredEncodings []string // Preferred encodings in order (gzip, deflate, br)
+
+	// Decompression bomb protection, a response exceeding a limit fails with an error matching ErrTooLarge
+	MaxDecompressedBytes  int64 // Maximum size of a decompressed response body (0 = unlimited)
+	MaxDecompressionRatio int64 // Maximum ratio of decompressed to compressed size, enforced above 1MiB (0 = unlimited)
 }
 
+// minRatioCheckedBytes is the decompressed size below which MaxDecompressionRatio is not enforced,
+// as small repetitive bodies legitimately compress far beyond any sensible ratio
+const minRatioCheckedBytes = 1 << 20
+
 // DefaultCompressionConfig returns sensible compression defaults
 func DefaultCompressionConfig() CompressionConfig {
 	return CompressionConfig{
@@ -38,6 +46,9 @@ func DefaultCompressionConfig() CompressionConfig {
 		EnableRequest:      true,
 		EnableResponse:     true,
 		PreferredEncodings: []string{"gzip", "deflate"},
+
+		MaxDecompressedBytes:  100 << 20, // 100MiB
+		MaxDecompressionRatio: 0,
 	}
 }
 
@@ -87,7 +98,7 @@ func (c *GzipCompressor) Compress(data []byte) ([]byte, error) {
 
 // Decompress decompresses gzip data
 func (c *GzipCompressor) Decompress(data []byte) ([]byte, error) {
-	reader, err := gzip.NewReader(bytes.NewReader(data))
+	reader, err := c.newReader(bytes.NewReader(data))
 	if err != nil {
 		return nil, err
 	}
@@ -96,6 +107,11 @@ func (c *GzipCompressor) Decompress(data []byte) ([]byte, error) {
 	return io.ReadAll(reader)
 }
 
+// newReader returns a reader decompressing r
+func (c *GzipCompressor) newReader(r io.Reader) (io.ReadCloser, error) {
+	return gzip.NewReader(r)
+}
+
 // ContentEncoding returns the encoding name
 func (c *GzipCompressor) ContentEncoding() string {
 	return "gzip"
@@ -147,7 +163,7 @@ func (c *DeflateCompressor) Compress(data []byte) ([]byte, error) {
 
 // Decompress decompresses deflate data
 func (c *DeflateCompressor) Decompress(data []byte) ([]byte, error) {
-	reader, err := zlib.NewReader(bytes.NewReader(data))
+	reader, err := c.newReader(bytes.NewReader(data))
 	if err != nil {
 		return nil, err
 	}
@@ -156,11 +172,22 @@ func (c *DeflateCompressor) Decompress(data []byte) ([]byte, error) {
 	return io.ReadAll(reader)
 }
 
+// newReader returns a reader decompressing r
+func (c *DeflateCompressor) newReader(r io.Reader) (io.ReadCloser, error) {
+	return zlib.NewReader(r)
+}
+
 // ContentEncoding returns the encoding name
 func (c *DeflateCompressor) ContentEncoding() string {
 	return "deflate"
 }
 
+// streamDecompressor is implemented by compressors able to decompress a stream, which bounds the memory
+// used to decompress a response body
+type streamDecompressor interface {
+	newReader(r io.Reader) (io.ReadCloser, error)
+}
+
 // CompressionMiddleware handles automatic compression/decompression
 type CompressionMiddleware struct {
 	config      CompressionConfig
@@ -316,8 +343,9 @@ func (m *CompressionMiddleware) decompressResponse(resp *http.Response) error {
 	resp.Body.Close()
 
 	// Decompress
-	decompressed, err := compressor.Decompress(compressed)
+	decompressed, err := m.decompress(compressor, compressed)
 	if err != nil {
+		resp.Body = http.NoBody
 		return err
 	}
 
@@ -329,6 +357,40 @@ func (m *CompressionMiddleware) decompressResponse(resp *http.Response) error {
 	return nil
 }
 
+// decompress decompresses a response body, failing with ErrTooLarge once the output exceeds the size
+// or ratio limits rather than holding all of it in memory
+func (m *CompressionMiddleware) decompress(compressor Compressor, compressed []byte) ([]byte, error) {
+	limit := m.config.MaxDecompressedBytes
+	if ratio := m.config.MaxDecompressionRatio; ratio > 0 {
+		ratioLimit := max(int64(len(compressed))*ratio, minRatioCheckedBytes)
+		if limit <= 0 || ratioLimit < limit {
+			limit = ratioLimit
+		}
+	}
+	stream, ok := compressor.(streamDecompressor)
+	if limit <= 0 || !ok {
+		return compressor.Decompress(compressed)
+	}
+
+	reader, err := stream.newReader(bytes.NewReader(compressed))
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	decompressed, err := io.ReadAll(io.LimitReader(reader, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(decompressed)) > limit {
+		if maxBytes := m.config.MaxDecompressedBytes; maxBytes > 0 && limit == maxBytes {
+			return nil, tagError(fmt.Errorf("decompressed response exceeds the limit of %d bytes", maxBytes), ErrTooLarge)
+		}
+		return nil, tagError(fmt.Errorf("decompressed response of %d compressed bytes exceeds the compression ratio limit of %d",
+			len(compressed), m.config.MaxDecompressionRatio), ErrTooLarge)
+	}
+	return decompressed, nil
+}
+
 // shouldCompress checks if content type should be compressed
 func (m *CompressionMiddleware) shouldCompress(contentType string) bool {
 	if contentType == "" {
@@ -31,6 +31,7 @@ func TestDefaultCompressionConfig(t *testing.T) {
 		assert.Equal(t, []string{"gzip", "deflate"}, got.PreferredEncodings)
 		assert.Contains(t, got.CompressibleTypes, "application/json")
 		assert.Contains(t, got.CompressibleTypes, "application/xml")
+		assert.Equal(t, int64(100<<20), got.MaxDecompressedBytes)
 	})
 }
 
@@ -734,6 +735,69 @@ func TestCompressionMiddleware_Execute_ResponseDecompression(t *testing.T) {
 	}
 }
 
+func TestCompressionMiddleware_DecompressionLimits(t *testing.T) {
+	t.Parallel()
+
+	// 4MiB of zeros compress to about 4KiB, a ratio of about 1000
+	bomb := bytes.NewBuffer(nil)
+	gw := gzip.NewWriter(bomb)
+	_, _ = gw.Write(make([]byte, 4<<20))
+	_ = gw.Close()
+
+	tests := []struct {
+		name    string
+		config  httpx.CompressionConfig
+		wantErr string
+	}{
+		{
+			name:   "decompresses within the limits",
+			config: httpx.CompressionConfig{EnableResponse: true, MaxDecompressedBytes: 8 << 20, MaxDecompressionRatio: 2000},
+		},
+		{
+			name:    "rejects a body exceeding the decompressed size limit",
+			config:  httpx.CompressionConfig{EnableResponse: true, MaxDecompressedBytes: 1 << 20},
+			wantErr: "decompressed response exceeds the limit of 1048576 bytes",
+		},
+		{
+			name:    "rejects a body exceeding the compression ratio limit",
+			config:  httpx.CompressionConfig{EnableResponse: true, MaxDecompressionRatio: 100},
+			wantErr: "exceeds the compression ratio limit of 100",
+		},
+		{
+			name:    "applies the lower of both limits",
+			config:  httpx.CompressionConfig{EnableResponse: true, MaxDecompressedBytes: 2 << 20, MaxDecompressionRatio: 100},
+			wantErr: "exceeds the compression ratio limit of 100",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				w.Header().Set("Content-Encoding", "gzip")
+				_, _ = w.Write(bomb.Bytes())
+			}))
+			defer server.Close()
+
+			client := httpx.NewClientWithConfig(
+				httpx.WithClientDefaultBaseURL(server.URL),
+				httpx.WithClientCompression(tc.config),
+			)
+
+			resp, err := client.Execute(*httpx.NewRequest(http.MethodGet), "")
+			if tc.wantErr == "" {
+				require.NoError(t, err)
+				assert.Len(t, resp.Body.(string), 4<<20)
+				return
+			}
+			require.Error(t, err)
+			assert.ErrorIs(t, err, httpx.ErrTooLarge)
+			assert.ErrorContains(t, err, tc.wantErr)
+		})
+	}
+}
+
 func TestCompressionMiddleware_Integration(t *testing.T) {
 	t.Parallel()
 
@@ -0,0 +1,281 @@
+package httpx
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// defaultInitialConcurrency is the concurrency limit before any latency was observed
+	defaultInitialConcurrency = 20
+	// defaultMaxConcurrency bounds the adaptive concurrency limit
+	defaultMaxConcurrency = 200
+	// defaultGradientSmoothing is the weight of each new limit of the gradient algorithm
+	defaultGradientSmoothing = 0.2
+	// gradientTolerance is the latency increase the gradient algorithm tolerates before lowering the limit
+	gradientTolerance = 1.5
+	// shortRTTWindow and longRTTWindow are the samples averaged into the short and long term latencies
+	shortRTTWindow = 10
+	longRTTWindow  = 600
+)
+
+// ConcurrencyAlgorithm selects how the adaptive concurrency limit follows latency
+type ConcurrencyAlgorithm string
+
+const (
+	// ConcurrencyGradient compares short and long term latency averages, lowering the limit as latency rises
+	ConcurrencyGradient ConcurrencyAlgorithm = "gradient"
+	// ConcurrencyVegas estimates the upstream queue from the minimum latency, as TCP Vegas does
+	ConcurrencyVegas ConcurrencyAlgorithm = "vegas"
+)
+
+// ConcurrencyLimitConfig configures adaptive concurrency limiting
+type ConcurrencyLimitConfig struct {
+	Algorithm       ConcurrencyAlgorithm // Limit algorithm (default: gradient)
+	InitialLimit    int                  // Limit before latency is observed (default: 20)
+	MinLimit        int                  // Lowest limit (default: 1)
+	MaxLimit        int                  // Highest limit (default: 200)
+	Smoothing       float64              // Weight of each new limit between 0 and 1 (default: 0.2 for gradient, 1 for vegas)
+	WaitOnLimit     bool                 // Wait for a request to complete when at the limit vs return error
+	MaxWaitDuration time.Duration        // Maximum time to wait for a request to complete (default: 30s)
+
+	// Observer is notified of the limit and requests in flight (default: the client's with
+	// WithClientAdaptiveConcurrency, see WithClientConcurrencyObserver)
+	Observer ConcurrencyObserver
+}
+
+// ConcurrencyObserver is notified whenever the concurrency limit or the number of requests in flight changes
+// Observers are called synchronously on the request path and must not block.
+type ConcurrencyObserver interface {
+	ObserveConcurrency(limit, inflight int)
+}
+
+// ConcurrencyStats holds the state of an adaptive concurrency limiter
+type ConcurrencyStats struct {
+	Limit    int   // Current limit of requests in flight
+	Inflight int   // Requests in flight
+	Rejected int64 // Requests rejected at the limit since the client was created
+}
+
+// ConcurrencyLimitMiddleware caps the requests in flight with a limit adapted to the observed latency, as
+// Netflix's concurrency-limits does, so a slowing upstream gets fewer requests instead of a growing queue
+//
+// Each completed request is a latency sample. The limit grows while latency holds and shrinks as it rises,
+// which signals requests queuing upstream. 429, 503 and 504 responses and errors, timeouts included, are
+// treated as overload and lower the limit. Samples taken while under half the limit was used do not raise it.
+type ConcurrencyLimitMiddleware struct {
+	config ConcurrencyLimitConfig
+
+	mu       sync.Mutex
+	limit    float64
+	inflight int
+	rejected int64
+	released chan struct{} // Closed and replaced whenever a request completes
+	shortRTT float64       // Short term average latency in seconds, gradient only
+	longRTT  float64       // Long term average latency in seconds, gradient only
+	minRTT   float64       // Lowest latency in seconds, vegas only
+}
+
+// NewConcurrencyLimitMiddleware creates an adaptive concurrency limit middleware
+func NewConcurrencyLimitMiddleware(config ConcurrencyLimitConfig) *ConcurrencyLimitMiddleware {
+	if config.Algorithm == "" {
+		config.Algorithm = ConcurrencyGradient
+	}
+	if config.MinLimit <= 0 {
+		config.MinLimit = 1
+	}
+	if config.MaxLimit <= 0 {
+		config.MaxLimit = max(defaultMaxConcurrency, config.MinLimit)
+	}
+	if config.InitialLimit <= 0 {
+		config.InitialLimit = defaultInitialConcurrency
+	}
+	config.InitialLimit = min(max(config.InitialLimit, config.MinLimit), config.MaxLimit)
+	if config.Smoothing <= 0 || config.Smoothing > 1 {
+		config.Smoothing = defaultGradientSmoothing
+		if config.Algorithm == ConcurrencyVegas {
+			config.Smoothing = 1
+		}
+	}
+	if config.MaxWaitDuration == 0 {
+		config.MaxWaitDuration = 30 * time.Second
+	}
+
+	return &ConcurrencyLimitMiddleware{
+		config:   config,
+		limit:    float64(config.InitialLimit),
+		released: make(chan struct{}),
+	}
+}
+
+// Name returns the middleware name
+func (m *ConcurrencyLimitMiddleware) Name() string {
+	return "concurrency-limit"
+}
+
+// Execute implements the Middleware interface
+func (m *ConcurrencyLimitMiddleware) Execute(ctx context.Context, req *http.Request, next MiddlewareFunc) (*http.Response, error) {
+	inflight, err := m.acquire(ctx)
+	if err != nil {
+		return nil, &HTTPError{
+			Op:      requestOperation(req),
+			Type:    ErrorTypeMiddleware,
+			Message: fmt.Sprintf("concurrency limit of %d requests reached", m.Stats().Limit),
+			Cause:   tagError(err, ErrConcurrencyLimited),
+			Request: req,
+		}
+	}
+
+	start := time.Now()
+	resp, err := next(ctx, req)
+	if err != nil && ctx.Err() != nil && errors.Is(err, context.Canceled) {
+		// A request cancelled by the caller says nothing about the upstream
+		m.release(0, inflight, false, false)
+		return resp, err
+	}
+	m.release(time.Since(start), inflight, isOverloaded(resp, err), true)
+	return resp, err
+}
+
+// Stats returns the current limit, requests in flight and rejections
+func (m *ConcurrencyLimitMiddleware) Stats() ConcurrencyStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return ConcurrencyStats{Limit: m.currentLimit(), Inflight: m.inflight, Rejected: m.rejected}
+}
+
+// acquire takes a slot below the limit, waiting for one if configured, and returns the requests then in flight
+func (m *ConcurrencyLimitMiddleware) acquire(ctx context.Context) (int, error) {
+	var timeout <-chan time.Time
+	if m.config.WaitOnLimit && m.config.MaxWaitDuration > 0 {
+		timer := time.NewTimer(m.config.MaxWaitDuration)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	for {
+		m.mu.Lock()
+		if m.inflight < m.currentLimit() {
+			m.inflight++
+			inflight := m.inflight
+			m.observe()
+			m.mu.Unlock()
+			return inflight, nil
+		}
+		if !m.config.WaitOnLimit {
+			m.rejected++
+			m.mu.Unlock()
+			return 0, errors.New("no request slot available")
+		}
+		released := m.released
+		m.mu.Unlock()
+
+		select {
+		case <-released:
+		case <-timeout:
+			m.mu.Lock()
+			m.rejected++
+			m.mu.Unlock()
+			return 0, errors.Errorf("concurrency limit wait timeout exceeded: %v", m.config.MaxWaitDuration)
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		}
+	}
+}
+
+// release frees the slot of a completed request and, if sampled, adapts the limit to its latency
+func (m *ConcurrencyLimitMiddleware) release(rtt time.Duration, inflight int, overloaded, sampled bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.inflight--
+	if sampled {
+		var limit float64
+		if m.config.Algorithm == ConcurrencyVegas {
+			limit = m.vegas(rtt.Seconds(), inflight, overloaded)
+		} else {
+			limit = m.gradient(rtt.Seconds(), inflight, overloaded)
+		}
+		limit = m.limit*(1-m.config.Smoothing) + limit*m.config.Smoothing
+		m.limit = min(max(limit, float64(m.config.MinLimit)), float64(m.config.MaxLimit))
+	}
+	close(m.released)
+	m.released = make(chan struct{})
+	m.observe()
+}
+
+// gradient returns the limit following the ratio of the long to the short term latency, the caller holding the lock
+func (m *ConcurrencyLimitMiddleware) gradient(rtt float64, inflight int, overloaded bool) float64 {
+	if m.longRTT == 0 {
+		m.shortRTT, m.longRTT = rtt, rtt
+	}
+	m.shortRTT += (rtt - m.shortRTT) * 2 / (shortRTTWindow + 1)
+	m.longRTT += (rtt - m.longRTT) * 2 / (longRTTWindow + 1)
+	// Let the long term average catch up after a lasting latency drop
+	if m.longRTT > 2*m.shortRTT {
+		m.longRTT *= 0.95
+	}
+
+	if overloaded {
+		return m.limit / 2
+	}
+	if float64(inflight) < m.limit/2 || m.shortRTT == 0 {
+		return m.limit
+	}
+	gradient := max(0.5, min(1, gradientTolerance*m.longRTT/m.shortRTT))
+	return m.limit*gradient + math.Sqrt(m.limit)
+}
+
+// vegas returns the limit following the estimated upstream queue, the caller holding the lock
+func (m *ConcurrencyLimitMiddleware) vegas(rtt float64, inflight int, overloaded bool) float64 {
+	if m.minRTT == 0 || rtt < m.minRTT {
+		m.minRTT = rtt
+	}
+	threshold := max(1, math.Log10(m.limit))
+	if overloaded {
+		return m.limit - threshold
+	}
+	if float64(inflight) < m.limit/2 || rtt == 0 {
+		return m.limit
+	}
+
+	queue := math.Ceil(m.limit * (1 - m.minRTT/rtt))
+	switch {
+	case queue <= threshold:
+		return m.limit + 6*threshold
+	case queue < 3*threshold:
+		return m.limit + threshold
+	case queue > 6*threshold:
+		return m.limit - threshold
+	}
+	return m.limit
+}
+
+// currentLimit returns the limit of requests in flight, the caller holding the lock
+func (m *ConcurrencyLimitMiddleware) currentLimit() int {
+	return int(m.limit)
+}
+
+// observe reports the limit and requests in flight to the observer, the caller holding the lock
+func (m *ConcurrencyLimitMiddleware) observe() {
+	if m.config.Observer != nil {
+		m.config.Observer.ObserveConcurrency(m.currentLimit(), m.inflight)
+	}
+}
+
+// isOverloaded reports whether the outcome of a request signals an overloaded upstream
+func isOverloaded(resp *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,199 @@
+package httpx_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bdpiprava/easy-http/pkg/httpx"
+)
+
+// concurrencyRecorder records the requests in flight it is notified of
+type concurrencyRecorder struct {
+	mu       sync.Mutex
+	inflight []int
+}
+
+func (r *concurrencyRecorder) ObserveConcurrency(_, inflight int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.inflight = append(r.inflight, inflight)
+}
+
+func (r *concurrencyRecorder) list() []int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]int(nil), r.inflight...)
+}
+
+func TestConcurrencyLimit(t *testing.T) {
+	// blockingServer answers once release is closed, reporting each request on started
+	blockingServer := func(t *testing.T) (*httptest.Server, chan struct{}, chan struct{}) {
+		started, release := make(chan struct{}, 10), make(chan struct{})
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			started <- struct{}{}
+			<-release
+			w.WriteHeader(http.StatusOK)
+		}))
+		t.Cleanup(server.Close)
+		return server, started, release
+	}
+	fixed := httpx.ConcurrencyLimitConfig{InitialLimit: 2, MinLimit: 2, MaxLimit: 2}
+
+	t.Run("should reject requests over the limit", func(t *testing.T) {
+		server, started, release := blockingServer(t)
+		client := httpx.NewClientWithConfig(httpx.WithClientDefaultBaseURL(server.URL), httpx.WithClientAdaptiveConcurrency(fixed))
+
+		var wg sync.WaitGroup
+		for range 2 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, err := client.Execute(*httpx.NewRequest(http.MethodGet), nil)
+				assert.NoError(t, err)
+			}()
+			<-started
+		}
+		assert.Equal(t, httpx.ConcurrencyStats{Limit: 2, Inflight: 2}, client.ConcurrencyLimiter().Stats())
+
+		_, err := client.Execute(*httpx.NewRequest(http.MethodGet), nil)
+		assert.True(t, errors.Is(err, httpx.ErrConcurrencyLimited))
+
+		close(release)
+		wg.Wait()
+		assert.Equal(t, httpx.ConcurrencyStats{Limit: 2, Rejected: 1}, client.ConcurrencyLimiter().Stats())
+	})
+
+	t.Run("should wait for a slot when configured", func(t *testing.T) {
+		server, started, release := blockingServer(t)
+		config := fixed
+		config.InitialLimit, config.MinLimit, config.MaxLimit = 1, 1, 1
+		config.WaitOnLimit = true
+		client := httpx.NewClientWithConfig(httpx.WithClientDefaultBaseURL(server.URL), httpx.WithClientAdaptiveConcurrency(config))
+
+		done := make(chan error, 2)
+		for range 2 {
+			go func() {
+				_, err := client.Execute(*httpx.NewRequest(http.MethodGet), nil)
+				done <- err
+			}()
+		}
+		<-started
+		select {
+		case <-started:
+			t.Fatal("second request sent over the limit")
+		case <-time.After(50 * time.Millisecond):
+		}
+
+		close(release)
+		require.NoError(t, <-done)
+		require.NoError(t, <-done)
+	})
+
+	t.Run("should lower the limit on overload", func(t *testing.T) {
+		for _, algorithm := range []httpx.ConcurrencyAlgorithm{httpx.ConcurrencyGradient, httpx.ConcurrencyVegas} {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(http.StatusServiceUnavailable)
+			}))
+			client := httpx.NewClientWithConfig(
+				httpx.WithClientDefaultBaseURL(server.URL),
+				httpx.WithClientAdaptiveConcurrency(httpx.ConcurrencyLimitConfig{Algorithm: algorithm, InitialLimit: 20, MinLimit: 2}),
+			)
+
+			for range 50 {
+				_, _ = client.Execute(*httpx.NewRequest(http.MethodGet), nil)
+			}
+
+			assert.Equal(t, 2, client.ConcurrencyLimiter().Stats().Limit, algorithm)
+			server.Close()
+		}
+	})
+
+	t.Run("should raise the limit while latency holds under load", func(t *testing.T) {
+		for _, algorithm := range []httpx.ConcurrencyAlgorithm{httpx.ConcurrencyGradient, httpx.ConcurrencyVegas} {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				time.Sleep(2 * time.Millisecond)
+				w.WriteHeader(http.StatusOK)
+			}))
+			client := httpx.NewClientWithConfig(
+				httpx.WithClientDefaultBaseURL(server.URL),
+				httpx.WithClientAdaptiveConcurrency(httpx.ConcurrencyLimitConfig{Algorithm: algorithm, InitialLimit: 4, WaitOnLimit: true}),
+			)
+
+			var wg sync.WaitGroup
+			for range 4 {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for range 20 {
+						_, err := client.Execute(*httpx.NewRequest(http.MethodGet), nil)
+						assert.NoError(t, err)
+					}
+				}()
+			}
+			wg.Wait()
+
+			assert.Greater(t, client.ConcurrencyLimiter().Stats().Limit, 4, algorithm)
+			server.Close()
+		}
+	})
+
+	t.Run("should report the limit and requests in flight as Prometheus metrics", func(t *testing.T) {
+		server, started, release := blockingServer(t)
+		registry := prometheus.NewRegistry()
+		config := httpx.DefaultPrometheusConfig()
+		config.Registry = registry
+		client := httpx.NewClientWithConfig(
+			httpx.WithClientDefaultBaseURL(server.URL),
+			httpx.WithClientPrometheusMetrics(config),
+			httpx.WithClientAdaptiveConcurrency(fixed),
+		)
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			_, _ = client.Execute(*httpx.NewRequest(http.MethodGet), nil)
+		}()
+		<-started
+
+		families, err := registry.Gather()
+		require.NoError(t, err)
+		values := map[string]float64{}
+		for _, family := range families {
+			for _, metric := range family.GetMetric() {
+				values[family.GetName()] += metric.GetGauge().GetValue()
+			}
+		}
+		assert.Equal(t, float64(2), values["http_client_concurrency_limit"])
+		assert.Equal(t, float64(1), values["http_client_concurrency_inflight"])
+
+		close(release)
+		<-done
+	})
+	t.Run("should report to the observer of its own config", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}))
+		defer server.Close()
+		recorder := &concurrencyRecorder{}
+		config := fixed
+		config.Observer = recorder
+		client := httpx.NewClientWithConfig(
+			httpx.WithClientDefaultBaseURL(server.URL),
+			httpx.WithClientConcurrencyObserver(&concurrencyRecorder{}),
+			httpx.WithClientMiddleware(httpx.NewConcurrencyLimitMiddleware(config)),
+		)
+
+		_, err := client.Execute(*httpx.NewRequest(http.MethodGet), nil)
+		require.NoError(t, err)
+
+		assert.Equal(t, []int{1, 0}, recorder.list())
+	})
+}
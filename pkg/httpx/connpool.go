@@ -0,0 +1,319 @@
+package httpx
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ConnPoolObserver is notified of changes to the client's connection pool, per host
+// Hosts are the dialed "host:port" addresses, so connections through an HTTP proxy are reported under the proxy.
+// Observers are called synchronously on the request path and must not block.
+type ConnPoolObserver interface {
+	// ObserveConnPool reports the number of idle and active connections to host after a change
+	ObserveConnPool(host string, idle, active int)
+	// ObserveDial reports a connection attempt to host, err is nil when the connection was established
+	ObserveDial(host string, err error)
+	// ObserveTLSHandshake reports a TLS handshake with host, err is nil when the handshake succeeded
+	ObserveTLSHandshake(host string, err error)
+}
+
+// ConnPoolStats is a snapshot of the connections the client holds to a host
+type ConnPoolStats struct {
+	Idle                 int   // Open connections not serving a request
+	Active               int   // Connections serving at least one request
+	Dials                int64 // Connection attempts
+	DialFailures         int64 // Connection attempts that failed
+	TLSHandshakeFailures int64 // TLS handshakes that failed
+	Expired              int64 // Connections closed on reaching their maximum lifetime
+}
+
+// connPool tracks the connections of a transport from its dialer and per-request traces
+type connPool struct {
+	observer    ConnPoolObserver
+	maxLifetime time.Duration
+
+	mu    sync.Mutex
+	hosts map[string]*ConnPoolStats
+}
+
+// newConnPool creates a connection pool tracker, returning nil when neither an observer nor a maximum
+// connection lifetime is configured
+func newConnPool(config ClientConfig) *connPool {
+	if config.ConnPoolObserver == nil && config.ConnMaxLifetime <= 0 {
+		return nil
+	}
+	observer := config.ConnPoolObserver
+	if observer == nil {
+		observer = noopConnPoolObserver{}
+	}
+	return &connPool{observer: observer, maxLifetime: config.ConnMaxLifetime, hosts: make(map[string]*ConnPoolStats)}
+}
+
+// noopConnPoolObserver ignores connection pool changes, for pools tracked only to expire connections
+type noopConnPoolObserver struct{}
+
+// ObserveConnPool implements ConnPoolObserver
+func (noopConnPoolObserver) ObserveConnPool(string, int, int) {}
+
+// ObserveDial implements ConnPoolObserver
+func (noopConnPoolObserver) ObserveDial(string, error) {}
+
+// ObserveTLSHandshake implements ConnPoolObserver
+func (noopConnPoolObserver) ObserveTLSHandshake(string, error) {}
+
+// configureConnPool wraps the transport dialer so established connections are tracked
+func configureConnPool(transport *http.Transport, pool *connPool) {
+	dial := transport.DialContext
+	if dial == nil {
+		dial = (&net.Dialer{}).DialContext
+	}
+	transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := dial(ctx, network, addr)
+		pool.dialed(addr, err)
+		if err != nil {
+			return nil, err
+		}
+		pooled := &pooledConn{Conn: conn, pool: pool, host: addr}
+		if pool.maxLifetime > 0 {
+			pooled.expiry = time.AfterFunc(pool.maxLifetime, func() { pool.expire(pooled) })
+		}
+		return pooled, nil
+	}
+}
+
+// stats returns a snapshot of the tracked hosts
+func (p *connPool) stats() map[string]ConnPoolStats {
+	if p == nil {
+		return nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	stats := make(map[string]ConnPoolStats, len(p.hosts))
+	for host, hostStats := range p.hosts {
+		stats[host] = *hostStats
+	}
+	return stats
+}
+
+// wrap tracks which connection serves each request sent through do
+// A nil pool returns do unchanged
+func (p *connPool) wrap(do func(*http.Request) (*http.Response, error)) func(*http.Request) (*http.Response, error) {
+	if p == nil {
+		return do
+	}
+	return func(req *http.Request) (*http.Response, error) {
+		var current atomic.Pointer[pooledConn]
+		host := canonicalAddr(req)
+		trace := &httptrace.ClientTrace{
+			GotConn: func(info httptrace.GotConnInfo) {
+				conn := asPooledConn(info.Conn)
+				if conn == nil {
+					return
+				}
+				p.acquire(conn)
+				// net/http may retry the round trip on another connection
+				if previous := current.Swap(conn); previous != nil {
+					p.release(previous)
+				}
+			},
+			TLSHandshakeDone: func(_ tls.ConnectionState, err error) {
+				p.handshake(host, err)
+			},
+		}
+
+		resp, err := do(req.WithContext(httptrace.WithClientTrace(req.Context(), trace)))
+		if err != nil {
+			if conn := current.Swap(nil); conn != nil {
+				p.release(conn)
+			}
+			return resp, err
+		}
+		resp.Body = &pooledBody{ReadCloser: resp.Body, release: func() {
+			if conn := current.Swap(nil); conn != nil {
+				p.release(conn)
+			}
+		}}
+		return resp, nil
+	}
+}
+
+// dialed records a connection attempt
+func (p *connPool) dialed(host string, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	stats := p.host(host)
+	stats.Dials++
+	if err != nil {
+		stats.DialFailures++
+	} else {
+		stats.Idle++
+		p.observer.ObserveConnPool(host, stats.Idle, stats.Active)
+	}
+	p.observer.ObserveDial(host, err)
+}
+
+// handshake records a TLS handshake
+func (p *connPool) handshake(host string, err error) {
+	if err != nil {
+		p.mu.Lock()
+		p.host(host).TLSHandshakeFailures++
+		p.mu.Unlock()
+	}
+	p.observer.ObserveTLSHandshake(host, err)
+}
+
+// acquire marks the connection as serving one more request
+func (p *connPool) acquire(conn *pooledConn) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if conn.closed {
+		return
+	}
+	conn.requests++
+	if conn.requests == 1 {
+		p.move(conn.host, -1, 1)
+	}
+}
+
+// release marks the connection as serving one request less, closing it once idle if it has expired
+func (p *connPool) release(conn *pooledConn) {
+	p.mu.Lock()
+	if conn.closed || conn.requests == 0 {
+		p.mu.Unlock()
+		return
+	}
+	conn.requests--
+	if conn.requests == 0 {
+		p.move(conn.host, 1, -1)
+	}
+	retire := conn.expired && conn.requests == 0
+	p.mu.Unlock()
+
+	if retire {
+		_ = conn.Close()
+	}
+}
+
+// expire marks the connection as past its maximum lifetime, closing it now if idle or else after its last request
+// Closing an idle connection makes the transport drop it from the pool, so it is never reused.
+func (p *connPool) expire(conn *pooledConn) {
+	p.mu.Lock()
+	if conn.closed {
+		p.mu.Unlock()
+		return
+	}
+	conn.expired = true
+	p.host(conn.host).Expired++
+	idle := conn.requests == 0
+	p.mu.Unlock()
+
+	if idle {
+		_ = conn.Close()
+	}
+}
+
+// closed removes the connection from the pool
+func (p *connPool) closed(conn *pooledConn) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if conn.closed {
+		return
+	}
+	conn.closed = true
+	if conn.requests > 0 {
+		p.move(conn.host, 0, -1)
+	} else {
+		p.move(conn.host, -1, 0)
+	}
+}
+
+// move adjusts the idle and active connections of host and notifies the observer, the caller holds p.mu
+func (p *connPool) move(host string, idle, active int) {
+	stats := p.host(host)
+	stats.Idle += idle
+	stats.Active += active
+	p.observer.ObserveConnPool(host, stats.Idle, stats.Active)
+}
+
+// host returns the stats of host, the caller holds p.mu
+func (p *connPool) host(host string) *ConnPoolStats {
+	stats, ok := p.hosts[host]
+	if !ok {
+		stats = &ConnPoolStats{}
+		p.hosts[host] = stats
+	}
+	return stats
+}
+
+// pooledConn is a connection tracked by connPool
+type pooledConn struct {
+	net.Conn
+	pool   *connPool
+	host   string
+	expiry *time.Timer // Fires at the maximum lifetime, nil if connections do not expire
+
+	// Guarded by pool.mu
+	requests int
+	closed   bool
+	expired  bool
+}
+
+// Close implements net.Conn
+func (c *pooledConn) Close() error {
+	if c.expiry != nil {
+		c.expiry.Stop()
+	}
+	c.pool.closed(c)
+	return c.Conn.Close()
+}
+
+// asPooledConn returns the tracked connection underlying conn, or nil if it is not tracked
+func asPooledConn(conn net.Conn) *pooledConn {
+	if tlsConn, ok := conn.(*tls.Conn); ok {
+		conn = tlsConn.NetConn()
+	}
+	pooled, _ := conn.(*pooledConn)
+	return pooled
+}
+
+// canonicalAddr returns the "host:port" address of the request URL
+func canonicalAddr(req *http.Request) string {
+	port := req.URL.Port()
+	if port == "" {
+		port = "80"
+		if req.URL.Scheme == "https" {
+			port = "443"
+		}
+	}
+	return net.JoinHostPort(req.URL.Hostname(), port)
+}
+
+// pooledBody releases the connection of a response once its body is read or closed
+type pooledBody struct {
+	io.ReadCloser
+	release func()
+	once    sync.Once
+}
+
+// Read implements io.Reader
+func (b *pooledBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err == io.EOF {
+		b.once.Do(b.release)
+	}
+	return n, err
+}
+
+// Close implements io.Closer
+func (b *pooledBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.release)
+	return err
+}
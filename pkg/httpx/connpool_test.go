@@ -0,0 +1,266 @@
+package httpx_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bdpiprava/easy-http/pkg/httpx"
+)
+
+// recordingPoolObserver records the connection pool notifications
+type recordingPoolObserver struct {
+	mu             sync.Mutex
+	pools          []string
+	dials          []error
+	handshakeFails int
+}
+
+func (o *recordingPoolObserver) ObserveConnPool(_ string, idle, active int) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.pools = append(o.pools, poolState(idle, active))
+}
+
+func (o *recordingPoolObserver) ObserveDial(_ string, err error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.dials = append(o.dials, err)
+}
+
+func (o *recordingPoolObserver) ObserveTLSHandshake(_ string, err error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if err != nil {
+		o.handshakeFails++
+	}
+}
+
+func poolState(idle, active int) string {
+	return strings.Repeat("i", idle) + strings.Repeat("a", active)
+}
+
+func TestConnPoolObserver(t *testing.T) {
+	t.Run("tracks idle and active connections per host", func(t *testing.T) {
+		release := make(chan struct{})
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.(http.Flusher).Flush()
+			<-release
+			_, _ = w.Write([]byte(`{}`))
+		}))
+		defer server.Close()
+
+		observer := &recordingPoolObserver{}
+		client := httpx.NewClientWithConfig(
+			httpx.WithClientDefaultBaseURL(server.URL),
+			httpx.WithClientConnPoolObserver(observer),
+		)
+
+		resp, err := client.Execute(*httpx.NewRequest(http.MethodGet, httpx.WithStreaming()), nil)
+		require.NoError(t, err)
+
+		host := strings.TrimPrefix(server.URL, "http://")
+		assert.Equal(t, httpx.ConnPoolStats{Active: 1, Dials: 1}, client.ConnPoolStats()[host])
+
+		close(release)
+		_, err = io.ReadAll(resp.StreamBody)
+		require.NoError(t, err)
+		require.NoError(t, resp.Close())
+
+		assert.Equal(t, httpx.ConnPoolStats{Idle: 1, Dials: 1}, client.ConnPoolStats()[host])
+		observer.mu.Lock()
+		defer observer.mu.Unlock()
+		assert.Equal(t, []string{"i", "a", "i"}, observer.pools)
+		assert.Equal(t, []error{nil}, observer.dials)
+	})
+
+	t.Run("reuses idle connections", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			_, _ = w.Write([]byte(`{}`))
+		}))
+		defer server.Close()
+
+		client := httpx.NewClientWithConfig(
+			httpx.WithClientDefaultBaseURL(server.URL),
+			httpx.WithClientConnPoolObserver(&recordingPoolObserver{}),
+		)
+
+		for range 3 {
+			_, err := client.Execute(*httpx.NewRequest(http.MethodGet), nil)
+			require.NoError(t, err)
+		}
+
+		host := strings.TrimPrefix(server.URL, "http://")
+		assert.Equal(t, httpx.ConnPoolStats{Idle: 1, Dials: 1}, client.ConnPoolStats()[host])
+
+		client.CloseIdleConnections()
+		assert.Equal(t, httpx.ConnPoolStats{Dials: 1}, client.ConnPoolStats()[host])
+	})
+
+	t.Run("counts dial failures", func(t *testing.T) {
+		server := httptest.NewServer(http.NotFoundHandler())
+		server.Close()
+
+		observer := &recordingPoolObserver{}
+		client := httpx.NewClientWithConfig(
+			httpx.WithClientDefaultBaseURL(server.URL),
+			httpx.WithClientConnPoolObserver(observer),
+		)
+
+		_, err := client.Execute(*httpx.NewRequest(http.MethodGet), nil)
+		require.Error(t, err)
+
+		host := strings.TrimPrefix(server.URL, "http://")
+		assert.Equal(t, httpx.ConnPoolStats{Dials: 1, DialFailures: 1}, client.ConnPoolStats()[host])
+		assert.Len(t, observer.dials, 1)
+		assert.Error(t, observer.dials[0])
+	})
+
+	t.Run("counts TLS handshake failures", func(t *testing.T) {
+		server := httptest.NewTLSServer(http.NotFoundHandler())
+		defer server.Close()
+
+		observer := &recordingPoolObserver{}
+		client := httpx.NewClientWithConfig(
+			httpx.WithClientDefaultBaseURL(server.URL),
+			httpx.WithClientConnPoolObserver(observer),
+		)
+
+		_, err := client.Execute(*httpx.NewRequest(http.MethodGet), nil)
+		require.Error(t, err)
+
+		host := strings.TrimPrefix(server.URL, "https://")
+		stats := client.ConnPoolStats()[host]
+		assert.Equal(t, int64(1), stats.TLSHandshakeFailures)
+		assert.Equal(t, 0, stats.Idle+stats.Active)
+		assert.Equal(t, 1, observer.handshakeFails)
+	})
+
+	t.Run("is disabled without an observer", func(t *testing.T) {
+		client := httpx.NewClientWithConfig()
+		assert.Nil(t, client.ConnPoolStats())
+	})
+}
+
+func TestConnMaxLifetime(t *testing.T) {
+	var mu sync.Mutex
+	var remotes []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		remotes = append(remotes, r.RemoteAddr)
+		mu.Unlock()
+		if r.URL.Path == "/slow" {
+			time.Sleep(80 * time.Millisecond)
+		}
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+	host := strings.TrimPrefix(server.URL, "http://")
+
+	connections := func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		seen := map[string]bool{}
+		for _, remote := range remotes {
+			seen[remote] = true
+		}
+		remotes = nil
+		return len(seen)
+	}
+	get := func(t *testing.T, client *httpx.Client, path string) {
+		_, err := client.Execute(*httpx.NewRequest(http.MethodGet, httpx.WithPath(path)), nil)
+		require.NoError(t, err)
+	}
+
+	t.Run("closes idle connections past their lifetime", func(t *testing.T) {
+		client := httpx.NewClientWithConfig(
+			httpx.WithClientDefaultBaseURL(server.URL),
+			httpx.WithClientConnMaxLifetime(40*time.Millisecond),
+		)
+
+		get(t, client, "/")
+		get(t, client, "/")
+		assert.Equal(t, 1, connections(), "the connection is reused before it expires")
+
+		time.Sleep(80 * time.Millisecond)
+		assert.Equal(t, httpx.ConnPoolStats{Dials: 1, Expired: 1}, client.ConnPoolStats()[host])
+
+		get(t, client, "/")
+		assert.Equal(t, 1, connections())
+		assert.Equal(t, int64(2), client.ConnPoolStats()[host].Dials)
+	})
+
+	t.Run("retires busy connections after their request", func(t *testing.T) {
+		client := httpx.NewClientWithConfig(
+			httpx.WithClientDefaultBaseURL(server.URL),
+			httpx.WithClientConnMaxLifetime(20*time.Millisecond),
+		)
+
+		get(t, client, "/slow")
+		get(t, client, "/")
+
+		assert.Equal(t, 2, connections())
+		stats := client.ConnPoolStats()[host]
+		assert.Equal(t, int64(2), stats.Dials)
+		assert.Equal(t, int64(1), stats.Expired)
+	})
+
+	t.Run("closes connections idle for too long", func(t *testing.T) {
+		client := httpx.NewClientWithConfig(
+			httpx.WithClientDefaultBaseURL(server.URL),
+			httpx.WithClientConnMaxIdleTime(20*time.Millisecond),
+		)
+
+		get(t, client, "/")
+		time.Sleep(60 * time.Millisecond)
+		get(t, client, "/")
+
+		assert.Equal(t, 2, connections())
+	})
+}
+
+func TestPrometheusCollector_ConnPool(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	registry := prometheus.NewRegistry()
+	config := httpx.DefaultPrometheusConfig()
+	config.Registry = registry
+	client := httpx.NewClientWithConfig(
+		httpx.WithClientDefaultBaseURL(server.URL),
+		httpx.WithClientPrometheusMetrics(config),
+	)
+
+	_, err := client.Execute(*httpx.NewRequest(http.MethodGet), nil)
+	require.NoError(t, err)
+
+	families, err := registry.Gather()
+	require.NoError(t, err)
+	values := map[string]float64{}
+	for _, family := range families {
+		for _, metric := range family.GetMetric() {
+			name := family.GetName()
+			for _, label := range metric.GetLabel() {
+				if label.GetName() != "host" {
+					name += "," + label.GetValue()
+				}
+			}
+			values[name] += metric.GetGauge().GetValue() + metric.GetCounter().GetValue()
+		}
+	}
+
+	assert.Equal(t, float64(1), values["http_client_connections,idle"])
+	assert.Equal(t, float64(0), values["http_client_connections,active"])
+	assert.Equal(t, float64(1), values["http_client_dials_total,success"])
+}
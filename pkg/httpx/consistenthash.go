@@ -0,0 +1,171 @@
+package httpx
+
+import (
+	"cmp"
+	"context"
+	"hash/fnv"
+	"math"
+	"math/rand/v2"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	// defaultHashReplicas is the number of points of each endpoint on a consistent hash ring
+	defaultHashReplicas = 100
+	// defaultHashLoadFactor bounds the requests in flight to an endpoint relative to the average
+	defaultHashLoadFactor = 1.25
+)
+
+// affinityKeyKey is the context key holding the affinity key set with WithAffinityKey
+type affinityKeyKey struct{}
+
+// ContextWithAffinityKey returns a context carrying the key hashed to an endpoint by NewConsistentHashBalancer
+func ContextWithAffinityKey(ctx context.Context, key string) context.Context {
+	if key == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, affinityKeyKey{}, key)
+}
+
+// AffinityKeyFromContext returns the affinity key carried by ctx, or "" if there is none
+func AffinityKeyFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	key, _ := ctx.Value(affinityKeyKey{}).(string)
+	return key
+}
+
+// ConsistentHashConfig configures the balancer of NewConsistentHashBalancer
+type ConsistentHashConfig struct {
+	Replicas   int         // Points of each endpoint on the hash ring, more spread keys more evenly (default: 100)
+	LoadFactor float64     // Maximum requests in flight to an endpoint relative to the average, at least 1 (default: 1.25)
+	RandSource rand.Source // Places requests without an affinity key on the ring (default: seeded randomly)
+}
+
+// consistentHashBalancer picks endpoints on a hash ring with bounded loads
+type consistentHashBalancer struct {
+	config ConsistentHashConfig
+	random *rand.Rand
+
+	mu        sync.Mutex
+	signature string          // Addresses the ring was built for
+	ring      []hashRingPoint // Sorted by hash
+	inflight  map[string]int  // Requests in flight by endpoint address
+	total     int             // Requests in flight to all endpoints
+}
+
+// hashRingPoint is a point of an endpoint on the hash ring
+type hashRingPoint struct {
+	hash     uint64
+	endpoint Endpoint
+}
+
+// NewConsistentHashBalancer returns a LoadTrackingBalancer sending requests with the same affinity key, set with
+// WithAffinityKey, to the same endpoint, so sharded backends keep their caches warm
+//
+// Endpoints are placed on a hash ring, so a change of endpoints only moves the keys of the endpoints that joined
+// or left. Loads are bounded as in "Consistent Hashing with Bounded Loads": when an endpoint already has
+// LoadFactor times the average requests in flight, the request goes to the next endpoint on the ring, so a hot
+// key or a changing endpoint set cannot overload an endpoint. Requests without an affinity key are placed on the
+// ring at random. Priorities and weights of the endpoints are ignored.
+func NewConsistentHashBalancer(config ConsistentHashConfig) LoadTrackingBalancer {
+	if config.Replicas <= 0 {
+		config.Replicas = defaultHashReplicas
+	}
+	if config.LoadFactor < 1 {
+		config.LoadFactor = defaultHashLoadFactor
+	}
+	return &consistentHashBalancer{
+		config:   config,
+		random:   rand.New(newLockedSource(config.RandSource)),
+		inflight: make(map[string]int),
+	}
+}
+
+// Pick implements Balancer
+func (b *consistentHashBalancer) Pick(req *http.Request, endpoints []Endpoint) (Endpoint, bool) {
+	if len(endpoints) == 0 {
+		return Endpoint{}, false
+	}
+	hash := b.random.Uint64()
+	if key := AffinityKeyFromContext(req.Context()); key != "" {
+		hash = hashKey(key)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.build(endpoints)
+
+	// Walk the ring from the key to the first endpoint below the load bound; one always is, as the bound is
+	// above the average load
+	capacity := int(math.Ceil(b.config.LoadFactor * float64(b.total+1) / float64(len(endpoints))))
+	start, _ := slices.BinarySearchFunc(b.ring, hash, func(p hashRingPoint, hash uint64) int { return cmp.Compare(p.hash, hash) })
+	for i := range b.ring {
+		endpoint := b.ring[(start+i)%len(b.ring)].endpoint
+		if b.inflight[endpoint.Address()] < capacity {
+			b.inflight[endpoint.Address()]++
+			b.total++
+			return endpoint, true
+		}
+	}
+	return Endpoint{}, false
+}
+
+// Done implements LoadTrackingBalancer
+func (b *consistentHashBalancer) Done(endpoint Endpoint) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	address := endpoint.Address()
+	if b.inflight[address] > 0 {
+		b.inflight[address]--
+		b.total--
+	}
+	if b.inflight[address] == 0 {
+		delete(b.inflight, address)
+	}
+}
+
+// build rebuilds the ring when the endpoints changed, the caller holding the lock
+func (b *consistentHashBalancer) build(endpoints []Endpoint) {
+	addresses := make([]string, len(endpoints))
+	for i, endpoint := range endpoints {
+		addresses[i] = endpoint.Address()
+	}
+	slices.Sort(addresses)
+	signature := strings.Join(addresses, ",")
+	if signature == b.signature {
+		return
+	}
+
+	b.signature = signature
+	b.ring = b.ring[:0]
+	for _, endpoint := range endpoints {
+		for replica := range b.config.Replicas {
+			b.ring = append(b.ring, hashRingPoint{
+				hash:     hashKey(endpoint.Address() + "#" + strconv.Itoa(replica)),
+				endpoint: endpoint,
+			})
+		}
+	}
+	slices.SortFunc(b.ring, func(a, b hashRingPoint) int {
+		return cmp.Or(cmp.Compare(a.hash, b.hash), strings.Compare(a.endpoint.Address(), b.endpoint.Address()))
+	})
+}
+
+// hashKey hashes key to a point of the ring, mixing FNV-1a so similar keys land far apart
+func hashKey(key string) uint64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(key))
+	x := h.Sum64()
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
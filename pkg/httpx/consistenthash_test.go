@@ -0,0 +1,109 @@
+package httpx_test
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bdpiprava/easy-http/pkg/httpx"
+)
+
+// keyedRequest returns a request carrying the affinity key
+func keyedRequest(key string) *http.Request {
+	req, _ := http.NewRequestWithContext(httpx.ContextWithAffinityKey(context.Background(), key), http.MethodGet, "http://payments/", nil)
+	return req
+}
+
+func TestConsistentHashBalancer(t *testing.T) {
+	endpoints := []httpx.Endpoint{
+		{Host: "10.0.0.1", Port: 80},
+		{Host: "10.0.0.2", Port: 80},
+		{Host: "10.0.0.3", Port: 80},
+	}
+	// pick picks the endpoint of key and completes the request at once
+	pick := func(balancer httpx.LoadTrackingBalancer, key string, endpoints []httpx.Endpoint) httpx.Endpoint {
+		endpoint, ok := balancer.Pick(keyedRequest(key), endpoints)
+		require.True(t, ok)
+		balancer.Done(endpoint)
+		return endpoint
+	}
+
+	t.Run("should send a key to the same endpoint and spread keys over endpoints", func(t *testing.T) {
+		balancer := httpx.NewConsistentHashBalancer(httpx.ConsistentHashConfig{})
+
+		counts := map[httpx.Endpoint]int{}
+		for i := range 300 {
+			key := "user-" + strconv.Itoa(i)
+			endpoint := pick(balancer, key, endpoints)
+			assert.Equal(t, endpoint, pick(balancer, key, endpoints))
+			counts[endpoint]++
+		}
+		for _, endpoint := range endpoints {
+			assert.Greater(t, counts[endpoint], 50, endpoint.Address())
+		}
+	})
+
+	t.Run("should only move the keys of a removed endpoint", func(t *testing.T) {
+		balancer := httpx.NewConsistentHashBalancer(httpx.ConsistentHashConfig{})
+
+		for i := range 100 {
+			key := "user-" + strconv.Itoa(i)
+			before := pick(balancer, key, endpoints)
+			after := pick(balancer, key, endpoints[:2])
+			if before != endpoints[2] {
+				assert.Equal(t, before, after, key)
+			}
+		}
+	})
+
+	t.Run("should move requests of a hot key to the next endpoint past the load bound", func(t *testing.T) {
+		balancer := httpx.NewConsistentHashBalancer(httpx.ConsistentHashConfig{LoadFactor: 1.25})
+
+		picked := map[httpx.Endpoint]int{}
+		for range 6 {
+			endpoint, ok := balancer.Pick(keyedRequest("hot"), endpoints)
+			require.True(t, ok)
+			picked[endpoint]++
+		}
+		// The bound of the last pick is ceil(1.25 * 6 / 3) = 3 requests in flight
+		assert.Greater(t, len(picked), 1)
+		for _, count := range picked {
+			assert.LessOrEqual(t, count, 3)
+		}
+		for endpoint, count := range picked {
+			for range count {
+				balancer.Done(endpoint)
+			}
+		}
+		assert.Equal(t, pick(balancer, "hot", endpoints), pick(balancer, "hot", endpoints))
+	})
+
+	t.Run("should route requests with an affinity key to the same server", func(t *testing.T) {
+		_, first := namedServer(t, "first")
+		_, second := namedServer(t, "second")
+		_, third := namedServer(t, "third")
+		client := httpx.NewClientWithConfig(httpx.WithClientServiceDiscovery(httpx.ServiceConfig{
+			Name:     "payments",
+			Resolver: &staticResolver{endpoints: []httpx.Endpoint{first, second, third}},
+			Balancer: httpx.NewConsistentHashBalancer(httpx.ConsistentHashConfig{}),
+		}))
+		defer client.ServiceDiscovery().Close()
+		get := func(key string) string {
+			resp, err := client.Execute(*httpx.NewRequest(http.MethodGet, httpx.WithAffinityKey(key)), "")
+			require.NoError(t, err)
+			return resp.Body.(string)
+		}
+
+		for i := range 10 {
+			key := "user-" + strconv.Itoa(i)
+			body := get(key)
+			for range 3 {
+				assert.Equal(t, body, get(key))
+			}
+		}
+	})
+}
@@ -0,0 +1,178 @@
+package httpx
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"time"
+)
+
+// Connection racing defaults
+const (
+	defaultConnectRaceParallel = 2
+	defaultConnectRaceDelay    = 250 * time.Millisecond
+)
+
+// ConnectRaceConfig configures racing connection attempts across the addresses a host name resolves to
+type ConnectRaceConfig struct {
+	Parallel int           // Connection attempts in flight at once (default: 2)
+	Delay    time.Duration // Wait for a pending attempt before racing it with the next address (default: 250ms)
+
+	// LookupIP resolves host names (default: net.DefaultResolver.LookupIPAddr)
+	LookupIP func(ctx context.Context, host string) ([]net.IPAddr, error)
+}
+
+// configureConnectRace makes the transport race connections to the addresses of host names
+func configureConnectRace(transport *http.Transport, config ConnectRaceConfig, clock Clock) {
+	if config.Parallel <= 0 {
+		config.Parallel = defaultConnectRaceParallel
+	}
+	if config.Delay <= 0 {
+		config.Delay = defaultConnectRaceDelay
+	}
+	if config.LookupIP == nil {
+		config.LookupIP = net.DefaultResolver.LookupIPAddr
+	}
+	dial := transport.DialContext
+	if dial == nil {
+		dial = (&net.Dialer{}).DialContext
+	}
+
+	transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil || net.ParseIP(host) != nil {
+			return dial(ctx, network, addr)
+		}
+		ips, err := config.LookupIP(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		addrs := raceAddresses(network, ips, port)
+		if len(addrs) == 0 {
+			return nil, &net.DNSError{Err: "no suitable address found", Name: host, IsNotFound: true}
+		}
+		return raceDial(ctx, dial, network, addrs, config, clock)
+	}
+}
+
+// dialsThroughSOCKS reports whether connections go through a SOCKS proxy or Via chain, where resolving host names
+// locally to race them would leak lookups and break names only the proxy can resolve
+func dialsThroughSOCKS(proxy *ProxyConfig) bool {
+	if proxy == nil {
+		return false
+	}
+	return proxy.Via != nil || (proxy.ProxyURL != nil && isSOCKSScheme(proxy.ProxyURL.Scheme))
+}
+
+// raceAddresses returns the addresses of ips suitable for network, alternating address families starting with
+// the family of the first address, as recommended by RFC 8305
+func raceAddresses(network string, ips []net.IPAddr, port string) []string {
+	var first, second []string
+	for _, ip := range ips {
+		isIPv4 := ip.IP.To4() != nil
+		if (network == "tcp4" && !isIPv4) || (network == "tcp6" && isIPv4) {
+			continue
+		}
+		addr := net.JoinHostPort(ip.String(), port)
+		if len(first) == 0 || (ips[0].IP.To4() != nil) == isIPv4 {
+			first = append(first, addr)
+		} else {
+			second = append(second, addr)
+		}
+	}
+
+	addrs := make([]string, 0, len(first)+len(second))
+	for i := range max(len(first), len(second)) {
+		if i < len(first) {
+			addrs = append(addrs, first[i])
+		}
+		if i < len(second) {
+			addrs = append(addrs, second[i])
+		}
+	}
+	return addrs
+}
+
+// dialResult is the outcome of a connection attempt
+type dialResult struct {
+	conn net.Conn
+	err  error
+}
+
+// raceDial connects to the first of addrs to accept a connection, starting the next attempt when one fails or
+// stays pending for the race delay, with at most config.Parallel attempts in flight. The attempts still pending
+// when one succeeds are canceled and the connections they may still establish are closed.
+func raceDial(ctx context.Context, dial func(context.Context, string, string) (net.Conn, error), network string, addrs []string, config ConnectRaceConfig, clock Clock) (net.Conn, error) {
+	if len(addrs) == 1 {
+		return dial(ctx, network, addrs[0])
+	}
+
+	raceCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	results := make(chan dialResult, len(addrs))
+	next, pending := 0, 0
+	start := func() {
+		addr := addrs[next]
+		next++
+		pending++
+		go func() {
+			conn, err := dial(raceCtx, network, addr)
+			results <- dialResult{conn: conn, err: err}
+		}()
+	}
+	// abandon closes the connections of the attempts still pending once they complete
+	abandon := func() {
+		go func(pending int) {
+			for range pending {
+				if result := <-results; result.conn != nil {
+					_ = result.conn.Close()
+				}
+			}
+		}(pending)
+	}
+
+	var firstErr error
+	start()
+	for {
+		var timer Timer
+		var delay <-chan time.Time
+		if next < len(addrs) && pending < config.Parallel {
+			timer = clock.NewTimer(config.Delay)
+			delay = timer.C()
+		}
+
+		select {
+		case result := <-results:
+			pending--
+			if result.err == nil {
+				stopTimer(timer)
+				abandon()
+				return result.conn, nil
+			}
+			if firstErr == nil {
+				firstErr = result.err
+			}
+			switch {
+			case next < len(addrs):
+				start()
+			case pending == 0:
+				stopTimer(timer)
+				return nil, firstErr
+			}
+		case <-delay:
+			start()
+		case <-ctx.Done():
+			stopTimer(timer)
+			abandon()
+			return nil, ctx.Err()
+		}
+		stopTimer(timer)
+	}
+}
+
+// stopTimer stops timer if it is set
+func stopTimer(timer Timer) {
+	if timer != nil {
+		timer.Stop()
+	}
+}
@@ -0,0 +1,90 @@
+package httpx_test
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bdpiprava/easy-http/pkg/httpx"
+)
+
+// staticLookup resolves every host name to ips, counting the lookups
+func staticLookup(lookups *atomic.Int32, ips ...string) func(context.Context, string) ([]net.IPAddr, error) {
+	return func(context.Context, string) ([]net.IPAddr, error) {
+		lookups.Add(1)
+		addrs := make([]net.IPAddr, len(ips))
+		for i, ip := range ips {
+			addrs[i] = net.IPAddr{IP: net.ParseIP(ip)}
+		}
+		return addrs, nil
+	}
+}
+
+func TestClientConnectRace(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+	serverURL, err := url.Parse(server.URL)
+	require.NoError(t, err)
+	port := serverURL.Port()
+
+	t.Run("connects past a dead address", func(t *testing.T) {
+		var lookups atomic.Int32
+		client := httpx.NewClientWithConfig(
+			httpx.WithClientDefaultBaseURL("http://racing.test:"+port),
+			httpx.WithClientDialTimeout(10*time.Second),
+			httpx.WithClientConnectRace(httpx.ConnectRaceConfig{
+				Delay:    50 * time.Millisecond,
+				LookupIP: staticLookup(&lookups, "192.0.2.1", "127.0.0.1"), // 192.0.2.1 is reserved for documentation
+			}),
+		)
+
+		start := time.Now()
+		resp, err := client.Execute(*httpx.NewRequest(http.MethodGet), nil)
+		require.NoError(t, err)
+
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Less(t, time.Since(start), 5*time.Second, "the dead address must not cost the dial timeout")
+		assert.Equal(t, int32(1), lookups.Load())
+	})
+
+	t.Run("fails when every address fails", func(t *testing.T) {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		_, closedPort, _ := net.SplitHostPort(listener.Addr().String())
+		require.NoError(t, listener.Close())
+
+		var lookups atomic.Int32
+		client := httpx.NewClientWithConfig(
+			httpx.WithClientDefaultBaseURL("http://racing.test:"+closedPort),
+			httpx.WithClientConnectRace(httpx.ConnectRaceConfig{LookupIP: staticLookup(&lookups, "127.0.0.1", "127.0.0.2")}),
+		)
+
+		_, err = client.Execute(*httpx.NewRequest(http.MethodGet), nil)
+
+		assert.Error(t, err)
+		assert.Equal(t, int32(1), lookups.Load())
+	})
+
+	t.Run("dials IP addresses directly", func(t *testing.T) {
+		var lookups atomic.Int32
+		client := httpx.NewClientWithConfig(
+			httpx.WithClientDefaultBaseURL(server.URL),
+			httpx.WithClientConnectRace(httpx.ConnectRaceConfig{LookupIP: staticLookup(&lookups, "192.0.2.1")}),
+		)
+
+		_, err := client.Execute(*httpx.NewRequest(http.MethodGet), nil)
+		require.NoError(t, err)
+
+		assert.Zero(t, lookups.Load())
+	})
+}
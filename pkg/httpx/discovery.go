@@ -0,0 +1,447 @@
+package httpx
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// defaultRefreshInterval is how often a ServiceMiddleware resolves a polled service again
+	defaultRefreshInterval = 30 * time.Second
+	// defaultResolveTimeout bounds each Resolve call of a ServiceMiddleware
+	defaultResolveTimeout = 5 * time.Second
+	// watchRetryDelay is the pause before watching a service again after a watch failed
+	watchRetryDelay = time.Second
+)
+
+// Endpoint is the network address of an instance of a service
+type Endpoint struct {
+	Host     string // Host name or IP address
+	Port     int    // TCP port
+	Priority int    // Lower values are preferred, see NewWeightedRandomBalancer
+	Weight   int    // Relative share of requests among endpoints of the same priority
+}
+
+// Address returns the "host:port" address of the endpoint
+func (e Endpoint) Address() string {
+	return net.JoinHostPort(e.Host, strconv.Itoa(e.Port))
+}
+
+// Resolver discovers the healthy endpoints of a service
+type Resolver interface {
+	// Resolve returns the current endpoints of service
+	Resolve(ctx context.Context, service string) ([]Endpoint, error)
+}
+
+// ResolverWatcher is implemented by resolvers able to push changes, such as ConsulResolver
+// A ServiceMiddleware watches such resolvers in the background instead of polling Resolve.
+type ResolverWatcher interface {
+	// Watch calls update with the endpoints of service on every change until ctx is done or watching fails
+	Watch(ctx context.Context, service string, update func([]Endpoint)) error
+}
+
+// Balancer picks the endpoint serving a request
+type Balancer interface {
+	// Pick returns the endpoint for req among endpoints, reporting false if none can serve it
+	Pick(req *http.Request, endpoints []Endpoint) (Endpoint, bool)
+}
+
+// LoadTrackingBalancer is implemented by balancers accounting for the requests in flight to each endpoint,
+// such as the one of NewConsistentHashBalancer. ServiceMiddleware calls Done when a request sent to an endpoint
+// the balancer picked completed.
+type LoadTrackingBalancer interface {
+	Balancer
+	// Done reports that a request sent to endpoint completed
+	Done(endpoint Endpoint)
+}
+
+// BalancerFunc adapts a function to the Balancer interface
+type BalancerFunc func(req *http.Request, endpoints []Endpoint) (Endpoint, bool)
+
+// Pick implements Balancer
+func (f BalancerFunc) Pick(req *http.Request, endpoints []Endpoint) (Endpoint, bool) {
+	return f(req, endpoints)
+}
+
+// NewWeightedRandomBalancer returns a Balancer choosing at random among the endpoints of the lowest priority,
+// in proportion to their weight, as for DNS SRV records. Endpoints are chosen uniformly when all weights are zero.
+// A nil src seeds a random source.
+func NewWeightedRandomBalancer(src rand.Source) Balancer {
+	random := rand.New(newLockedSource(src))
+	return BalancerFunc(func(_ *http.Request, endpoints []Endpoint) (Endpoint, bool) {
+		if len(endpoints) == 0 {
+			return Endpoint{}, false
+		}
+		lowest := slices.MinFunc(endpoints, func(a, b Endpoint) int { return a.Priority - b.Priority }).Priority
+		candidates := slices.DeleteFunc(slices.Clone(endpoints), func(e Endpoint) bool { return e.Priority != lowest })
+
+		total := 0
+		for _, e := range candidates {
+			total += max(e.Weight, 0)
+		}
+		if total == 0 {
+			return candidates[random.IntN(len(candidates))], true
+		}
+		n := random.IntN(total)
+		for _, e := range candidates {
+			if n -= max(e.Weight, 0); n < 0 {
+				return e, true
+			}
+		}
+		return candidates[len(candidates)-1], true
+	})
+}
+
+// ServiceConfig configures client-side service discovery and load balancing
+type ServiceConfig struct {
+	Name            string        // Service name, which is also the host of the request URLs routed to the service
+	Resolver        Resolver      // Discovers the endpoints (default: DNS SRV lookup of Name, see SRVResolver)
+	Balancer        Balancer      // Picks the endpoint of each request (default: NewWeightedRandomBalancer)
+	RefreshInterval time.Duration // How often endpoints are resolved again in the background (default: 30s), unused by a ResolverWatcher
+	ResolveTimeout  time.Duration // Timeout of each Resolve call (default: 5s)
+
+	OutlierDetection *OutlierConfig // Ejects failing endpoints for a cooldown (default: disabled)
+
+	// BaseContext scopes the background resolutions and watches, which stop once it is done as they do on Close
+	// (default: context.Background())
+	BaseContext context.Context
+}
+
+// ServiceMiddleware routes requests for a service to one of its endpoints
+//
+// Requests whose URL host is the service name are sent to an endpoint picked by the balancer; the Host header
+// keeps the service name. Other requests pass through unchanged. Endpoints are resolved on the first request,
+// then refreshed in the background, so requests never wait on a resolver once it answered. A failed or empty
+// resolution keeps the previous endpoints. With ServiceConfig.OutlierDetection, failing endpoints are left out
+// of balancing for a while.
+type ServiceMiddleware struct {
+	config   ServiceConfig
+	outliers *outlierDetector // Nil unless outlier detection is enabled
+	ctx      context.Context  // Cancelled by Close, scopes background refreshes and watches
+	cancel   context.CancelFunc
+
+	mu         sync.Mutex
+	endpoints  []Endpoint
+	resolvedAt time.Time
+	refreshing bool
+	watching   bool
+}
+
+// NewServiceMiddleware creates a service discovery middleware
+func NewServiceMiddleware(config ServiceConfig) *ServiceMiddleware {
+	if config.Resolver == nil {
+		config.Resolver = &SRVResolver{}
+	}
+	if config.Balancer == nil {
+		config.Balancer = NewWeightedRandomBalancer(nil)
+	}
+	if config.RefreshInterval <= 0 {
+		config.RefreshInterval = defaultRefreshInterval
+	}
+	if config.ResolveTimeout <= 0 {
+		config.ResolveTimeout = defaultResolveTimeout
+	}
+	if config.BaseContext == nil {
+		config.BaseContext = context.Background()
+	}
+	ctx, cancel := context.WithCancel(config.BaseContext)
+	return &ServiceMiddleware{
+		config:   config,
+		outliers: newOutlierDetector(config.Name, config.OutlierDetection),
+		ctx:      ctx,
+		cancel:   cancel,
+	}
+}
+
+// Name returns the middleware name
+func (m *ServiceMiddleware) Name() string {
+	return "service-discovery"
+}
+
+// Execute implements the Middleware interface
+func (m *ServiceMiddleware) Execute(ctx context.Context, req *http.Request, next MiddlewareFunc) (*http.Response, error) {
+	if !strings.EqualFold(req.URL.Hostname(), m.config.Name) {
+		return next(ctx, req)
+	}
+
+	endpoints, err := m.current(ctx)
+	if err != nil {
+		return nil, err
+	}
+	total := len(endpoints)
+	if m.outliers != nil {
+		endpoints = m.outliers.available(endpoints)
+	}
+	endpoint, ok := m.config.Balancer.Pick(req, endpoints)
+	if !ok {
+		return nil, tagError(errors.Errorf("no endpoint of service %q can serve the request", m.config.Name), ErrNoEndpoints)
+	}
+
+	target := req.WithContext(ctx)
+	targetURL := *req.URL
+	targetURL.Host = endpoint.Address()
+	target.URL = &targetURL
+	if target.Host == "" {
+		target.Host = req.URL.Host
+	}
+	resp, err := next(ctx, target)
+	if tracking, ok := m.config.Balancer.(LoadTrackingBalancer); ok {
+		tracking.Done(endpoint)
+	}
+	if m.outliers != nil {
+		m.outliers.report(endpoint, total, isOutlierFailure(ctx, resp, err))
+	}
+	return resp, err
+}
+
+// Endpoints returns the endpoints currently known for the service
+func (m *ServiceMiddleware) Endpoints() []Endpoint {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return slices.Clone(m.endpoints)
+}
+
+// OutlierStats returns the ejections of outlier detection, empty unless ServiceConfig.OutlierDetection is set
+func (m *ServiceMiddleware) OutlierStats() OutlierStats {
+	return m.outliers.stats()
+}
+
+// Close stops refreshing and watching the service in the background
+func (m *ServiceMiddleware) Close() {
+	m.cancel()
+}
+
+// current returns the endpoints of the service, resolving them on first use and refreshing them when due
+func (m *ServiceMiddleware) current(ctx context.Context) ([]Endpoint, error) {
+	m.mu.Lock()
+	endpoints := m.endpoints
+	m.mu.Unlock()
+
+	if endpoints == nil {
+		resolved, err := m.resolve(ctx)
+		if err != nil {
+			return nil, tagError(errors.Wrapf(err, "failed to resolve service %q", m.config.Name), ErrNoEndpoints)
+		}
+		m.update(resolved)
+		endpoints = resolved
+	}
+	m.refresh()
+	return endpoints, nil
+}
+
+// resolve asks the resolver for the endpoints, treating an empty answer as an error
+func (m *ServiceMiddleware) resolve(ctx context.Context) ([]Endpoint, error) {
+	ctx, cancel := context.WithTimeout(ctx, m.config.ResolveTimeout)
+	defer cancel()
+
+	endpoints, err := m.config.Resolver.Resolve(ctx, m.config.Name)
+	if err == nil && len(endpoints) == 0 {
+		err = errors.New("resolver returned no endpoints")
+	}
+	return endpoints, err
+}
+
+// update replaces the endpoints, keeping the previous ones if there are no new ones
+func (m *ServiceMiddleware) update(endpoints []Endpoint) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.resolvedAt = time.Now()
+	if len(endpoints) > 0 {
+		m.endpoints = slices.Clone(endpoints)
+		if m.outliers != nil {
+			m.outliers.prune(endpoints)
+		}
+	}
+}
+
+// refresh starts watching the service, or resolves it again in the background when the refresh interval elapsed
+func (m *ServiceMiddleware) refresh() {
+	watcher, watches := m.config.Resolver.(ResolverWatcher)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.ctx.Err() != nil || m.refreshing || m.watching {
+		return
+	}
+	if watches {
+		m.watching = true
+		go m.watch(watcher)
+		return
+	}
+	if time.Since(m.resolvedAt) < m.config.RefreshInterval {
+		return
+	}
+	m.refreshing = true
+	go func() {
+		endpoints, _ := m.resolve(m.ctx)
+		m.update(endpoints)
+		m.mu.Lock()
+		m.refreshing = false
+		m.mu.Unlock()
+	}()
+}
+
+// watch follows the changes pushed by the resolver until Close, watching again after a failure
+func (m *ServiceMiddleware) watch(watcher ResolverWatcher) {
+	for {
+		_ = watcher.Watch(m.ctx, m.config.Name, m.update)
+		select {
+		case <-m.ctx.Done():
+			return
+		case <-time.After(watchRetryDelay):
+		}
+	}
+}
+
+// SRVResolver resolves services with DNS SRV records, e.g. "_payments._tcp.example.com"
+type SRVResolver struct {
+	Service  string        // SRV service, e.g. "payments"; empty to look the service name up as is
+	Proto    string        // SRV protocol, e.g. "tcp"; empty to look the service name up as is
+	Resolver *net.Resolver // DNS resolver (default: net.DefaultResolver)
+}
+
+// Resolve implements Resolver
+func (r *SRVResolver) Resolve(ctx context.Context, service string) ([]Endpoint, error) {
+	resolver := r.Resolver
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+	_, records, err := resolver.LookupSRV(ctx, r.Service, r.Proto, service)
+	if err != nil {
+		return nil, err
+	}
+	endpoints := make([]Endpoint, len(records))
+	for i, record := range records {
+		endpoints[i] = Endpoint{
+			Host:     strings.TrimSuffix(record.Target, "."),
+			Port:     int(record.Port),
+			Priority: int(record.Priority),
+			Weight:   int(record.Weight),
+		}
+	}
+	return endpoints, nil
+}
+
+// ConsulResolver resolves the instances of a service passing their Consul health checks
+// It implements ResolverWatcher with Consul blocking queries, so changes apply as soon as Consul sees them.
+type ConsulResolver struct {
+	Address    string        // Consul HTTP API address (default: "http://127.0.0.1:8500")
+	Datacenter string        // Datacenter to query (default: the agent's)
+	Tag        string        // Only resolve instances with this tag
+	Token      string        // ACL token
+	WaitTime   time.Duration // Maximum duration of a blocking query (default: 5m)
+	HTTPClient *http.Client  // Client for the Consul API (default: http.DefaultClient)
+}
+
+// consulServiceEntry is the part of a Consul health API entry describing an instance
+type consulServiceEntry struct {
+	Node struct {
+		Address string
+	}
+	Service struct {
+		Address string
+		Port    int
+		Weights struct {
+			Passing int
+		}
+	}
+}
+
+// Resolve implements Resolver
+func (r *ConsulResolver) Resolve(ctx context.Context, service string) ([]Endpoint, error) {
+	endpoints, _, err := r.query(ctx, service, 0)
+	return endpoints, err
+}
+
+// Watch implements ResolverWatcher
+func (r *ConsulResolver) Watch(ctx context.Context, service string, update func([]Endpoint)) error {
+	var index uint64
+	for {
+		endpoints, next, err := r.query(ctx, service, index)
+		if err != nil {
+			return err
+		}
+		if next == 0 {
+			return errors.New("consul response has no X-Consul-Index header")
+		}
+		if next != index {
+			update(endpoints)
+		}
+		// Consul resets the index when its state is restored, start over as documented for blocking queries
+		if next < index {
+			next = 0
+		}
+		index = next
+	}
+}
+
+// query reads the passing instances of service, blocking until the Consul index moves past index when it is set
+func (r *ConsulResolver) query(ctx context.Context, service string, index uint64) ([]Endpoint, uint64, error) {
+	address := r.Address
+	if address == "" {
+		address = "http://127.0.0.1:8500"
+	}
+	params := url.Values{"passing": {"true"}}
+	if r.Datacenter != "" {
+		params.Set("dc", r.Datacenter)
+	}
+	if r.Tag != "" {
+		params.Set("tag", r.Tag)
+	}
+	if index > 0 {
+		wait := r.WaitTime
+		if wait <= 0 {
+			wait = 5 * time.Minute
+		}
+		params.Set("index", strconv.FormatUint(index, 10))
+		params.Set("wait", fmt.Sprintf("%ds", int(wait.Seconds())))
+	}
+	endpoint := strings.TrimSuffix(address, "/") + "/v1/health/service/" + url.PathEscape(service) + "?" + params.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "failed to create Consul request")
+	}
+	if r.Token != "" {
+		req.Header.Set("X-Consul-Token", r.Token)
+	}
+	client := r.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "failed to query Consul")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, 0, errors.Errorf("consul returned status %d", resp.StatusCode)
+	}
+
+	var entries []consulServiceEntry
+	if err := json.NewDecoder(resp.Body).Decode(&entries); err != nil {
+		return nil, 0, errors.Wrap(err, "failed to decode Consul response")
+	}
+	endpoints := make([]Endpoint, len(entries))
+	for i, entry := range entries {
+		host := entry.Service.Address
+		if host == "" {
+			host = entry.Node.Address
+		}
+		endpoints[i] = Endpoint{Host: host, Port: entry.Service.Port, Weight: entry.Service.Weights.Passing}
+	}
+	next, _ := strconv.ParseUint(resp.Header.Get("X-Consul-Index"), 10, 64)
+	return endpoints, next, nil
+}
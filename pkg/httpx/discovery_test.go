@@ -0,0 +1,334 @@
+package httpx_test
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bdpiprava/easy-http/pkg/httpx"
+)
+
+// staticResolver resolves every service to its current endpoints
+type staticResolver struct {
+	mu        sync.Mutex
+	endpoints []httpx.Endpoint
+	err       error
+	calls     atomic.Int32
+}
+
+func (r *staticResolver) Resolve(context.Context, string) ([]httpx.Endpoint, error) {
+	r.calls.Add(1)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.endpoints, r.err
+}
+
+func (r *staticResolver) set(endpoints []httpx.Endpoint, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.endpoints, r.err = endpoints, err
+}
+
+// namedServer starts a server answering with its name and the Host header it received
+func namedServer(t *testing.T, name string) (*httptest.Server, httpx.Endpoint) {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(name + " " + r.Host))
+	}))
+	t.Cleanup(server.Close)
+	host, port, err := net.SplitHostPort(strings.TrimPrefix(server.URL, "http://"))
+	require.NoError(t, err)
+	portNumber, err := strconv.Atoi(port)
+	require.NoError(t, err)
+	return server, httpx.Endpoint{Host: host, Port: portNumber}
+}
+
+func TestWeightedRandomBalancer(t *testing.T) {
+	balancer := httpx.NewWeightedRandomBalancer(rand.NewPCG(1, 2))
+	endpoints := []httpx.Endpoint{
+		{Host: "a", Priority: 1, Weight: 3},
+		{Host: "b", Priority: 1, Weight: 1},
+		{Host: "backup", Priority: 2, Weight: 100},
+	}
+
+	picks := map[string]int{}
+	for range 1000 {
+		endpoint, ok := balancer.Pick(nil, endpoints)
+		require.True(t, ok)
+		picks[endpoint.Host]++
+	}
+
+	assert.Zero(t, picks["backup"], "higher priorities only serve when no lower one exists")
+	assert.InDelta(t, 750, picks["a"], 60)
+	assert.InDelta(t, 250, picks["b"], 60)
+
+	endpoint, ok := balancer.Pick(nil, endpoints[2:])
+	assert.True(t, ok)
+	assert.Equal(t, "backup", endpoint.Host)
+	_, ok = balancer.Pick(nil, nil)
+	assert.False(t, ok)
+}
+
+func TestServiceDiscovery(t *testing.T) {
+	_, first := namedServer(t, "first")
+	_, second := namedServer(t, "second")
+
+	get := func(t *testing.T, client *httpx.Client) string {
+		resp, err := client.Execute(*httpx.NewRequest(http.MethodGet), "")
+		require.NoError(t, err)
+		return resp.Body.(string)
+	}
+
+	t.Run("balances requests across endpoints", func(t *testing.T) {
+		resolver := &staticResolver{endpoints: []httpx.Endpoint{first, second}}
+		client := httpx.NewClientWithConfig(httpx.WithClientServiceDiscovery(httpx.ServiceConfig{
+			Name:     "payments",
+			Resolver: resolver,
+		}))
+		defer client.ServiceDiscovery().Close()
+
+		seen := map[string]bool{}
+		for range 20 {
+			seen[get(t, client)] = true
+		}
+
+		assert.Equal(t, map[string]bool{"first payments": true, "second payments": true}, seen)
+		assert.Equal(t, int32(1), resolver.calls.Load())
+		assert.ElementsMatch(t, []httpx.Endpoint{first, second}, client.ServiceDiscovery().Endpoints())
+	})
+
+	t.Run("refreshes endpoints in the background", func(t *testing.T) {
+		resolver := &staticResolver{endpoints: []httpx.Endpoint{first}}
+		client := httpx.NewClientWithConfig(httpx.WithClientServiceDiscovery(httpx.ServiceConfig{
+			Name:            "payments",
+			Resolver:        resolver,
+			RefreshInterval: 10 * time.Millisecond,
+		}))
+		defer client.ServiceDiscovery().Close()
+
+		assert.Equal(t, "first payments", get(t, client))
+		resolver.set([]httpx.Endpoint{second}, nil)
+		time.Sleep(20 * time.Millisecond)
+
+		assert.Eventually(t, func() bool { return get(t, client) == "second payments" }, time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("keeps endpoints when resolving fails", func(t *testing.T) {
+		resolver := &staticResolver{endpoints: []httpx.Endpoint{first}}
+		client := httpx.NewClientWithConfig(httpx.WithClientServiceDiscovery(httpx.ServiceConfig{
+			Name:            "payments",
+			Resolver:        resolver,
+			RefreshInterval: time.Millisecond,
+		}))
+		defer client.ServiceDiscovery().Close()
+
+		assert.Equal(t, "first payments", get(t, client))
+		resolver.set(nil, errors.New("dns unavailable"))
+		time.Sleep(10 * time.Millisecond)
+
+		for range 5 {
+			assert.Equal(t, "first payments", get(t, client))
+		}
+	})
+
+	t.Run("stops refreshing once the base context is done", func(t *testing.T) {
+		resolver := &staticResolver{endpoints: []httpx.Endpoint{first}}
+		ctx, cancel := context.WithCancel(context.Background())
+		client := httpx.NewClientWithConfig(
+			httpx.WithClientBaseContext(ctx),
+			httpx.WithClientServiceDiscovery(httpx.ServiceConfig{
+				Name:            "payments",
+				Resolver:        resolver,
+				RefreshInterval: time.Millisecond,
+			}),
+		)
+		assert.Equal(t, "first payments", get(t, client))
+
+		cancel()
+		time.Sleep(10 * time.Millisecond)
+		calls := resolver.calls.Load()
+		for range 5 {
+			time.Sleep(2 * time.Millisecond)
+			assert.Equal(t, "first payments", get(t, client))
+		}
+
+		assert.Equal(t, calls, resolver.calls.Load())
+	})
+
+	t.Run("fails without endpoints", func(t *testing.T) {
+		resolver := &staticResolver{err: errors.New("dns unavailable")}
+		client := httpx.NewClientWithConfig(httpx.WithClientServiceDiscovery(httpx.ServiceConfig{
+			Name:     "payments",
+			Resolver: resolver,
+		}))
+
+		_, err := client.Execute(*httpx.NewRequest(http.MethodGet), "")
+
+		assert.ErrorIs(t, err, httpx.ErrNoEndpoints)
+	})
+
+	t.Run("leaves other hosts alone", func(t *testing.T) {
+		server, _ := namedServer(t, "other")
+		resolver := &staticResolver{endpoints: []httpx.Endpoint{first}}
+		client := httpx.NewClientWithConfig(
+			httpx.WithClientServiceDiscovery(httpx.ServiceConfig{Name: "payments", Resolver: resolver}),
+			httpx.WithClientDefaultBaseURL(server.URL),
+		)
+
+		assert.Equal(t, "other "+strings.TrimPrefix(server.URL, "http://"), get(t, client))
+		assert.Zero(t, resolver.calls.Load())
+	})
+}
+
+func TestSRVResolver(t *testing.T) {
+	resolver := &httpx.SRVResolver{Resolver: &net.Resolver{
+		PreferGo: true,
+		Dial: func(context.Context, string, string) (net.Conn, error) {
+			client, server := net.Pipe()
+			go serveSRV(server, []srvRecord{
+				{priority: 10, weight: 5, port: 8080, target: "a.example.com."},
+				{priority: 20, weight: 0, port: 8081, target: "b.example.com."},
+			})
+			return client, nil
+		},
+	}}
+
+	endpoints, err := resolver.Resolve(context.Background(), "_payments._tcp.example.com.")
+
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []httpx.Endpoint{
+		{Host: "a.example.com", Port: 8080, Priority: 10, Weight: 5},
+		{Host: "b.example.com", Port: 8081, Priority: 20},
+	}, endpoints)
+}
+
+// srvRecord is an SRV answer served by serveSRV
+type srvRecord struct {
+	priority, weight, port uint16
+	target                 string
+}
+
+// serveSRV answers a single DNS query received over a stream connection with the SRV records
+func serveSRV(conn net.Conn, records []srvRecord) {
+	defer conn.Close()
+	var length uint16
+	if err := binary.Read(conn, binary.BigEndian, &length); err != nil {
+		return
+	}
+	query := make([]byte, length)
+	if _, err := io.ReadFull(conn, query); err != nil {
+		return
+	}
+
+	// The question ends after the name labels and the type and class
+	end := 12
+	for query[end] != 0 {
+		end += int(query[end]) + 1
+	}
+	end += 5
+
+	msg := binary.BigEndian.AppendUint16(nil, binary.BigEndian.Uint16(query))
+	msg = binary.BigEndian.AppendUint16(msg, 0x8180)
+	msg = binary.BigEndian.AppendUint16(msg, 1)
+	msg = binary.BigEndian.AppendUint16(msg, uint16(len(records)))
+	msg = append(msg, 0, 0, 0, 0)
+	msg = append(msg, query[12:end]...)
+	for _, record := range records {
+		var target []byte
+		for _, label := range strings.Split(strings.TrimSuffix(record.target, "."), ".") {
+			target = append(append(target, byte(len(label))), label...)
+		}
+		target = append(target, 0)
+
+		msg = append(msg, 0xc0, 12) // Name pointing at the question
+		msg = binary.BigEndian.AppendUint16(msg, 33)
+		msg = binary.BigEndian.AppendUint16(msg, 1)
+		msg = binary.BigEndian.AppendUint32(msg, 60)
+		msg = binary.BigEndian.AppendUint16(msg, uint16(6+len(target)))
+		msg = binary.BigEndian.AppendUint16(msg, record.priority)
+		msg = binary.BigEndian.AppendUint16(msg, record.weight)
+		msg = binary.BigEndian.AppendUint16(msg, record.port)
+		msg = append(msg, target...)
+	}
+	_ = binary.Write(conn, binary.BigEndian, uint16(len(msg)))
+	_, _ = conn.Write(msg)
+}
+
+func TestConsulResolver(t *testing.T) {
+	_, first := namedServer(t, "first")
+	_, second := namedServer(t, "second")
+
+	entries := func(endpoints ...httpx.Endpoint) []map[string]any {
+		var result []map[string]any
+		for _, e := range endpoints {
+			result = append(result, map[string]any{
+				"Node":    map[string]any{"Address": e.Host},
+				"Service": map[string]any{"Port": e.Port, "Weights": map[string]any{"Passing": 1}},
+			})
+		}
+		return result
+	}
+
+	changed := make(chan struct{})
+	var queries []string
+	var mu sync.Mutex
+	consul := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		queries = append(queries, r.URL.RequestURI())
+		mu.Unlock()
+		assert.Equal(t, "secret", r.Header.Get("X-Consul-Token"))
+
+		switch r.URL.Query().Get("index") {
+		case "":
+			w.Header().Set("X-Consul-Index", "1")
+			_ = json.NewEncoder(w).Encode(entries(first))
+		case "1":
+			select {
+			case <-changed:
+			case <-r.Context().Done():
+				return
+			}
+			w.Header().Set("X-Consul-Index", "2")
+			_ = json.NewEncoder(w).Encode(entries(second))
+		default:
+			<-r.Context().Done()
+		}
+	}))
+	defer consul.Close()
+
+	resolver := &httpx.ConsulResolver{Address: consul.URL, Token: "secret", Tag: "v2", WaitTime: time.Minute}
+	client := httpx.NewClientWithConfig(httpx.WithClientServiceDiscovery(httpx.ServiceConfig{
+		Name:     "payments",
+		Resolver: resolver,
+	}))
+	defer client.ServiceDiscovery().Close()
+	get := func() string {
+		resp, err := client.Execute(*httpx.NewRequest(http.MethodGet), "")
+		require.NoError(t, err)
+		return resp.Body.(string)
+	}
+
+	assert.Equal(t, "first payments", get())
+	close(changed)
+	assert.Eventually(t, func() bool { return get() == "second payments" }, time.Second, 10*time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, "/v1/health/service/payments?passing=true&tag=v2", queries[0])
+	assert.Contains(t, queries, "/v1/health/service/payments?index=1&passing=true&tag=v2&wait=60s")
+}
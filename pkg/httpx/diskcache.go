@@ -0,0 +1,350 @@
+package httpx
+
+import (
+	"container/list"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Files of a DiskCache directory
+const (
+	diskCacheIndexFile  = "index.json"
+	diskCacheBodySuffix = ".body"
+)
+
+// DiskCache implements CacheBackend storing responses in a directory, so they survive process restarts
+// Every body is stored in a file of its own, while headers and validators are kept in an index file that is
+// rewritten atomically on every change; the body files a stale index does not reference are removed on startup.
+// Least recently used entries are evicted once the total estimated size, see CachedResponse.Size, exceeds the
+// limit; recency is persisted with the next change of the index or by Close.
+//
+// A directory must be used by a single DiskCache at a time.
+type DiskCache struct {
+	dir      string
+	maxBytes int64
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	lru     *list.List // Most recently used entries at the front
+	stats   CacheStats
+	dirty   bool // Whether recency changed since the index was written
+}
+
+// diskCacheEntry is an element of the LRU list, and of the index file in LRU order
+type diskCacheEntry struct {
+	Key          string      `json:"key"`
+	File         string      `json:"file"` // Name of the body file in the cache directory
+	Size         int64       `json:"size"`
+	StatusCode   int         `json:"status_code"`
+	Headers      http.Header `json:"headers,omitempty"`
+	CachedAt     time.Time   `json:"cached_at"`
+	ExpiresAt    time.Time   `json:"expires_at"`
+	ETag         string      `json:"etag,omitempty"`
+	LastModified string      `json:"last_modified,omitempty"`
+
+	StaleWhileRevalidate time.Duration `json:"stale_while_revalidate,omitempty"`
+	StaleIfError         time.Duration `json:"stale_if_error,omitempty"`
+}
+
+// NewDiskCache opens the disk cache stored in dir, creating the directory if needed
+// maxBytes bounds the total size of the entries and of each entry; zero or negative leaves it unbounded.
+func NewDiskCache(dir string, maxBytes int64) (*DiskCache, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, errors.Wrap(err, "failed to create cache directory")
+	}
+	c := &DiskCache{
+		dir:      dir,
+		maxBytes: max(maxBytes, 0),
+		entries:  make(map[string]*list.Element),
+		lru:      list.New(),
+	}
+	if err := c.load(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Get retrieves a cached response, reading its body from disk
+func (c *DiskCache) Get(key string) (*CachedResponse, bool) {
+	c.mu.Lock()
+	element, exists := c.entries[key]
+	if !exists {
+		c.stats.Misses++
+		c.mu.Unlock()
+		return nil, false
+	}
+	entry := element.Value.(*diskCacheEntry)
+	if time.Now().After(entry.retainUntil()) {
+		c.evict(element)
+		_ = c.save()
+		c.stats.Misses++
+		c.mu.Unlock()
+		return nil, false
+	}
+	c.lru.MoveToFront(element)
+	c.dirty = true
+	c.mu.Unlock()
+
+	// Bodies are never rewritten in place, a body file removed meanwhile by an eviction is a miss
+	body, err := os.ReadFile(filepath.Join(c.dir, entry.File))
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err != nil {
+		if c.entries[key] == element {
+			c.remove(element)
+			_ = c.save()
+		}
+		c.stats.Misses++
+		return nil, false
+	}
+	c.stats.Hits++
+	return &CachedResponse{
+		StatusCode:   entry.StatusCode,
+		Headers:      entry.Headers,
+		Body:         body,
+		CachedAt:     entry.CachedAt,
+		ExpiresAt:    entry.ExpiresAt,
+		ETag:         entry.ETag,
+		LastModified: entry.LastModified,
+
+		StaleWhileRevalidate: entry.StaleWhileRevalidate,
+		StaleIfError:         entry.StaleIfError,
+	}, true
+}
+
+// Set stores a response on disk, evicting least recently used entries to stay within the size limit
+func (c *DiskCache) Set(key string, response *CachedResponse) error {
+	size := response.Size()
+	if c.maxBytes > 0 && size > c.maxBytes {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		// The new response supersedes any stored one, which must not be served instead
+		if element, exists := c.entries[key]; exists {
+			c.remove(element)
+			_ = c.save()
+		}
+		c.stats.Rejected++
+		return tagError(errors.Errorf("cache entry of %d bytes exceeds the limit of %d bytes", size, c.maxBytes), ErrTooLarge)
+	}
+
+	// Every write goes to a new file, so readers of the previous body are not affected
+	file, err := c.writeBody(response.Body)
+	if err != nil {
+		return err
+	}
+	entry := &diskCacheEntry{
+		Key:          key,
+		File:         file,
+		Size:         size,
+		StatusCode:   response.StatusCode,
+		Headers:      response.Headers,
+		CachedAt:     response.CachedAt,
+		ExpiresAt:    response.ExpiresAt,
+		ETag:         response.ETag,
+		LastModified: response.LastModified,
+
+		StaleWhileRevalidate: response.StaleWhileRevalidate,
+		StaleIfError:         response.StaleIfError,
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if element, exists := c.entries[key]; exists {
+		c.remove(element)
+	}
+	c.entries[key] = c.lru.PushFront(entry)
+	c.stats.Bytes += size
+
+	// The new entry is at the front and fits on its own, so it is never evicted here
+	for c.maxBytes > 0 && c.stats.Bytes > c.maxBytes {
+		c.evict(c.lru.Back())
+	}
+	c.stats.Size = int64(len(c.entries))
+	return c.save()
+}
+
+// Delete removes a cache entry
+func (c *DiskCache) Delete(key string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, exists := c.entries[key]
+	if !exists {
+		return nil
+	}
+	c.remove(element)
+	return c.save()
+}
+
+// Clear removes all cache entries
+func (c *DiskCache) Clear() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for element := c.lru.Front(); element != nil; element = element.Next() {
+		_ = os.Remove(filepath.Join(c.dir, element.Value.(*diskCacheEntry).File))
+	}
+	c.entries = make(map[string]*list.Element)
+	c.lru.Init()
+	c.stats.Size = 0
+	c.stats.Bytes = 0
+	return c.save()
+}
+
+// DeleteMatching removes the entries whose keys match, returning how many were removed
+func (c *DiskCache) DeleteMatching(match func(key string) bool) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	deleted := 0
+	for key, element := range c.entries {
+		if match(key) {
+			c.remove(element)
+			deleted++
+		}
+	}
+	if deleted == 0 {
+		return 0, nil
+	}
+	return deleted, c.save()
+}
+
+// Stats returns cache statistics
+func (c *DiskCache) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.stats
+}
+
+// Close writes the recency of the entries to the index, so the LRU order survives the restart
+func (c *DiskCache) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.dirty {
+		return nil
+	}
+	return c.save()
+}
+
+// load reads the index and removes the body files it does not reference, e.g. after a crash
+func (c *DiskCache) load() error {
+	data, err := os.ReadFile(filepath.Join(c.dir, diskCacheIndexFile))
+	if err != nil && !os.IsNotExist(err) {
+		return errors.Wrap(err, "failed to read cache index")
+	}
+	var index []*diskCacheEntry
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &index); err != nil {
+			// A corrupt index loses the entries rather than the cache
+			index = nil
+		}
+	}
+
+	now := time.Now()
+	referenced := make(map[string]bool, len(index))
+	for _, entry := range index {
+		if entry.File != filepath.Base(entry.File) || !strings.HasSuffix(entry.File, diskCacheBodySuffix) {
+			continue // Not a body file of the cache directory
+		}
+		info, err := os.Stat(filepath.Join(c.dir, entry.File))
+		if err != nil || !info.Mode().IsRegular() || now.After(entry.retainUntil()) || c.entries[entry.Key] != nil {
+			continue
+		}
+		referenced[entry.File] = true
+		c.entries[entry.Key] = c.lru.PushBack(entry)
+		c.stats.Bytes += entry.Size
+	}
+	for c.maxBytes > 0 && c.stats.Bytes > c.maxBytes {
+		element := c.lru.Back()
+		delete(referenced, element.Value.(*diskCacheEntry).File)
+		c.remove(element)
+	}
+	c.stats.Size = int64(len(c.entries))
+
+	files, err := os.ReadDir(c.dir)
+	if err != nil {
+		return errors.Wrap(err, "failed to read cache directory")
+	}
+	for _, file := range files {
+		name := file.Name()
+		if (strings.HasSuffix(name, diskCacheBodySuffix) || strings.HasPrefix(name, diskCacheIndexFile+".")) && !referenced[name] {
+			_ = os.Remove(filepath.Join(c.dir, name))
+		}
+	}
+	return c.save()
+}
+
+// writeBody writes a body to a new file of the cache directory, returning its name
+func (c *DiskCache) writeBody(body []byte) (string, error) {
+	file, err := os.CreateTemp(c.dir, "*"+diskCacheBodySuffix)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to write cached response")
+	}
+	if _, err := file.Write(body); err != nil {
+		file.Close()
+		_ = os.Remove(file.Name())
+		return "", errors.Wrap(err, "failed to write cached response")
+	}
+	if err := file.Close(); err != nil {
+		_ = os.Remove(file.Name())
+		return "", errors.Wrap(err, "failed to write cached response")
+	}
+	return filepath.Base(file.Name()), nil
+}
+
+// save writes the index in LRU order, replacing it atomically, the caller holding c.mu
+func (c *DiskCache) save() error {
+	index := make([]*diskCacheEntry, 0, c.lru.Len())
+	for element := c.lru.Front(); element != nil; element = element.Next() {
+		index = append(index, element.Value.(*diskCacheEntry))
+	}
+	data, err := json.Marshal(index)
+	if err != nil {
+		return errors.Wrap(err, "failed to encode cache index")
+	}
+	tmp, err := os.CreateTemp(c.dir, diskCacheIndexFile+".*")
+	if err != nil {
+		return errors.Wrap(err, "failed to write cache index")
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return errors.Wrap(err, "failed to write cache index")
+	}
+	if err := tmp.Close(); err != nil {
+		return errors.Wrap(err, "failed to write cache index")
+	}
+	if err := os.Rename(tmp.Name(), filepath.Join(c.dir, diskCacheIndexFile)); err != nil {
+		return errors.Wrap(err, "failed to write cache index")
+	}
+	c.dirty = false
+	return nil
+}
+
+// retainUntil returns when the entry can no longer be served, even stale, see CachedResponse.RetainUntil
+func (e *diskCacheEntry) retainUntil() time.Time {
+	return e.ExpiresAt.Add(max(e.StaleWhileRevalidate, e.StaleIfError))
+}
+
+// evict removes an entry to make room or because it expired, the caller holds c.mu
+func (c *DiskCache) evict(element *list.Element) {
+	c.stats.Evictions++
+	c.stats.EvictedBytes += element.Value.(*diskCacheEntry).Size
+	c.remove(element)
+}
+
+// remove removes an entry and its body file, the caller holds c.mu
+func (c *DiskCache) remove(element *list.Element) {
+	entry := c.lru.Remove(element).(*diskCacheEntry)
+	delete(c.entries, entry.Key)
+	_ = os.Remove(filepath.Join(c.dir, entry.File))
+	c.stats.Bytes -= entry.Size
+	c.stats.Size = int64(len(c.entries))
+}
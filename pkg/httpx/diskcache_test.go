@@ -0,0 +1,155 @@
+package httpx_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bdpiprava/easy-http/pkg/httpx"
+)
+
+func TestDiskCache(t *testing.T) {
+	response := func(body string) *httpx.CachedResponse {
+		return &httpx.CachedResponse{
+			StatusCode: http.StatusOK,
+			Headers:    http.Header{"Content-Type": {"application/json"}},
+			Body:       []byte(body),
+			CachedAt:   time.Now(),
+			ExpiresAt:  time.Now().Add(time.Hour),
+			ETag:       `"v1"`,
+		}
+	}
+
+	t.Run("survives reopening the directory", func(t *testing.T) {
+		dir := t.TempDir()
+		cache, err := httpx.NewDiskCache(dir, 0)
+		require.NoError(t, err)
+		require.NoError(t, cache.Set("a", response(`{"id":1}`)))
+		require.NoError(t, cache.Close())
+
+		reopened, err := httpx.NewDiskCache(dir, 0)
+		require.NoError(t, err)
+		cached, ok := reopened.Get("a")
+
+		require.True(t, ok)
+		assert.Equal(t, `{"id":1}`, string(cached.Body))
+		assert.Equal(t, "application/json", cached.Headers.Get("Content-Type"))
+		assert.Equal(t, `"v1"`, cached.ETag)
+		assert.Equal(t, int64(1), reopened.Stats().Size)
+	})
+
+	t.Run("evicts least recently used entries by size", func(t *testing.T) {
+		dir := t.TempDir()
+		entrySize := response(string(make([]byte, 1000))).Size()
+		cache, err := httpx.NewDiskCache(dir, 2*entrySize)
+		require.NoError(t, err)
+
+		require.NoError(t, cache.Set("a", response(string(make([]byte, 1000)))))
+		require.NoError(t, cache.Set("b", response(string(make([]byte, 1000)))))
+		_, _ = cache.Get("a")
+		require.NoError(t, cache.Set("c", response(string(make([]byte, 1000)))))
+
+		_, hasA := cache.Get("a")
+		_, hasB := cache.Get("b")
+		_, hasC := cache.Get("c")
+		assert.True(t, hasA)
+		assert.False(t, hasB)
+		assert.True(t, hasC)
+		stats := cache.Stats()
+		assert.Equal(t, int64(1), stats.Evictions)
+		assert.Equal(t, 2*entrySize, stats.Bytes)
+		bodies, _ := filepath.Glob(filepath.Join(dir, "*.body"))
+		assert.Len(t, bodies, 2)
+	})
+
+	t.Run("rejects entries larger than the limit", func(t *testing.T) {
+		cache, err := httpx.NewDiskCache(t.TempDir(), 512)
+		require.NoError(t, err)
+		require.NoError(t, cache.Set("a", response("small")))
+
+		err = cache.Set("a", response(string(make([]byte, 1024))))
+
+		require.ErrorIs(t, err, httpx.ErrTooLarge)
+		_, ok := cache.Get("a")
+		assert.False(t, ok)
+	})
+
+	t.Run("recovers from a corrupt index and removes unreferenced bodies", func(t *testing.T) {
+		dir := t.TempDir()
+		cache, err := httpx.NewDiskCache(dir, 0)
+		require.NoError(t, err)
+		require.NoError(t, cache.Set("a", response("body")))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "index.json"), []byte("{"), 0o600))
+
+		reopened, err := httpx.NewDiskCache(dir, 0)
+		require.NoError(t, err)
+
+		_, ok := reopened.Get("a")
+		assert.False(t, ok)
+		bodies, _ := filepath.Glob(filepath.Join(dir, "*.body"))
+		assert.Empty(t, bodies)
+	})
+
+	t.Run("is safe for concurrent use", func(t *testing.T) {
+		cache, err := httpx.NewDiskCache(t.TempDir(), 20*response("body-00").Size())
+		require.NoError(t, err)
+
+		var wg sync.WaitGroup
+		for i := range 8 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for j := range 50 {
+					key := fmt.Sprintf("key-%d", (i*50+j)%30)
+					_ = cache.Set(key, response(fmt.Sprintf("body-%02d", j%100)))
+					if cached, ok := cache.Get(key); ok {
+						assert.Len(t, cached.Body, len("body-00"))
+					}
+					if j%10 == 0 {
+						_ = cache.Delete(key)
+					}
+				}
+			}()
+		}
+		wg.Wait()
+
+		assert.LessOrEqual(t, cache.Stats().Size, int64(20))
+	})
+
+	t.Run("backs the cache middleware", func(t *testing.T) {
+		var requests atomic.Int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			requests.Add(1)
+			w.Header().Set("Cache-Control", "max-age=60")
+			_, _ = w.Write([]byte(`{"openapi":"3.1.0"}`))
+		}))
+		defer server.Close()
+		dir := t.TempDir()
+		newClient := func() *httpx.Client {
+			cache, err := httpx.NewDiskCache(dir, 1<<20)
+			require.NoError(t, err)
+			return httpx.NewClientWithConfig(
+				httpx.WithClientDefaultBaseURL(server.URL),
+				httpx.WithClientCache(httpx.CacheConfig{Backend: cache, RefreshAhead: 0.8}),
+			)
+		}
+
+		// A second client on the same directory stands for the process after a restart
+		for range 2 {
+			resp, err := newClient().Execute(*httpx.NewRequest(http.MethodGet, httpx.WithPath("/openapi.json")), map[string]any{})
+			require.NoError(t, err)
+			assert.Equal(t, map[string]any{"openapi": "3.1.0"}, resp.Body)
+		}
+
+		assert.Equal(t, int32(1), requests.Load())
+	})
+}
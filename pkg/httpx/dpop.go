@@ -0,0 +1,200 @@
+package httpx
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+// DPoPConfig configures DPoP (RFC 9449) proof-of-possession for outbound requests
+type DPoPConfig struct {
+	KeySource        SigningKeySource // Source of the DPoP key pair (required)
+	Algorithm        string           // JWS algorithm (default: derived from the key type)
+	KeepBearerScheme bool             // If true, "Authorization: Bearer" is not rewritten to the DPoP scheme
+	Now              func() time.Time // Clock used for the iat claim (default: time.Now)
+}
+
+// DPoPMiddleware attaches DPoP proofs to outbound requests and handles server-provided nonces
+type DPoPMiddleware struct {
+	config DPoPConfig
+	mu     sync.Mutex
+	nonces map[string]string // Latest DPoP-Nonce per host
+}
+
+// NewDPoPMiddleware creates a new DPoP middleware
+func NewDPoPMiddleware(config DPoPConfig) *DPoPMiddleware {
+	if config.Now == nil {
+		config.Now = time.Now
+	}
+	return &DPoPMiddleware{
+		config: config,
+		nonces: make(map[string]string),
+	}
+}
+
+// Name returns the middleware name
+func (m *DPoPMiddleware) Name() string {
+	return "dpop"
+}
+
+// Execute implements the Middleware interface
+func (m *DPoPMiddleware) Execute(ctx context.Context, req *http.Request, next MiddlewareFunc) (*http.Response, error) {
+	nonce := m.nonce(req.URL.Host)
+	if err := m.attachProof(ctx, req, nonce); err != nil {
+		return nil, MiddlewareError("failed to create DPoP proof", err, req)
+	}
+
+	resp, err := next(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	newNonce := resp.Header.Get("DPoP-Nonce")
+	if newNonce == "" {
+		return resp, nil
+	}
+	m.setNonce(req.URL.Host, newNonce)
+
+	// Retry once with the fresh nonce when the server demands one
+	if newNonce == nonce || !requiresDPoPNonce(resp) {
+		return resp, nil
+	}
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return resp, nil // Body can't be replayed
+	}
+
+	retry := req.Clone(ctx)
+	if req.GetBody != nil {
+		body, bodyErr := req.GetBody()
+		if bodyErr != nil {
+			return resp, nil
+		}
+		retry.Body = body
+	}
+	if err := m.attachProof(ctx, retry, newNonce); err != nil {
+		return resp, nil
+	}
+	resp.Body.Close()
+
+	return next(ctx, retry)
+}
+
+// attachProof creates a DPoP proof for the request and binds the access token if present
+func (m *DPoPMiddleware) attachProof(ctx context.Context, req *http.Request, nonce string) error {
+	if m.config.KeySource == nil {
+		return errors.New("DPoP key source is not configured")
+	}
+	key, err := m.config.KeySource.SigningKey(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to load DPoP key")
+	}
+
+	accessToken := ""
+	if authorization := req.Header.Get("Authorization"); authorization != "" {
+		scheme, token, found := strings.Cut(authorization, " ")
+		if found && (strings.EqualFold(scheme, "DPoP") || strings.EqualFold(scheme, "Bearer")) {
+			accessToken = token
+			if !m.config.KeepBearerScheme {
+				req.Header.Set("Authorization", "DPoP "+token)
+			}
+		}
+	}
+
+	proof, err := m.proof(key, req.Method, CanonicalRequestOf(req).URL, accessToken, nonce)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("DPoP", proof)
+	return nil
+}
+
+// proof builds and signs a DPoP proof JWT
+func (m *DPoPMiddleware) proof(key SigningKey, method string, target *url.URL, accessToken, nonce string) (string, error) {
+	alg := m.config.Algorithm
+	if alg == "" {
+		var err error
+		if alg, err = jwsAlgorithmFor(key.Signer); err != nil {
+			return "", err
+		}
+	}
+
+	jwk, err := NewJSONWebKey(key.Signer.Public(), "")
+	if err != nil {
+		return "", err
+	}
+
+	header := map[string]any{
+		"typ": "dpop+jwt",
+		"alg": alg,
+		"jwk": jwk,
+	}
+
+	// htu is the target URI without query and fragment
+	htu := url.URL{Scheme: target.Scheme, Host: target.Host, Path: target.Path}
+	claims := map[string]any{
+		"jti": uuid.NewString(),
+		"htm": method,
+		"htu": htu.String(),
+		"iat": m.config.Now().Unix(),
+	}
+	if accessToken != "" {
+		hash := sha256.Sum256([]byte(accessToken))
+		claims["ath"] = base64.RawURLEncoding.EncodeToString(hash[:])
+	}
+	if nonce != "" {
+		claims["nonce"] = nonce
+	}
+
+	headerJSON, err := json.Marshal(header)
+	if err != nil {
+		return "", err
+	}
+	claimsJSON, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	signingInput := base64.RawURLEncoding.EncodeToString(headerJSON) + "." + base64.RawURLEncoding.EncodeToString(claimsJSON)
+	signature, err := signJWS(alg, key.Signer, []byte(signingInput))
+	if err != nil {
+		return "", errors.Wrap(err, "failed to sign DPoP proof")
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// nonce returns the latest nonce received from the host
+func (m *DPoPMiddleware) nonce(host string) string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.nonces[host]
+}
+
+// setNonce stores the latest nonce received from the host
+func (m *DPoPMiddleware) setNonce(host, nonce string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.nonces[host] = nonce
+}
+
+// requiresDPoPNonce checks if the server rejected the request because of a missing or stale nonce
+func requiresDPoPNonce(resp *http.Response) bool {
+	switch resp.StatusCode {
+	case http.StatusBadRequest:
+		// Authorization servers report use_dpop_nonce in the error response body
+		return true
+	case http.StatusUnauthorized:
+		// Resource servers report use_dpop_nonce in the WWW-Authenticate challenge
+		return strings.Contains(resp.Header.Get("WWW-Authenticate"), "use_dpop_nonce")
+	default:
+		return false
+	}
+}
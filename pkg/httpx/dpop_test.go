@@ -0,0 +1,179 @@
+package httpx_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bdpiprava/easy-http/pkg/httpx"
+)
+
+// dpopProof is a decoded and verified DPoP proof
+type dpopProof struct {
+	Header map[string]any
+	Claims map[string]any
+}
+
+// verifyDPoPProof verifies the proof signature with the embedded jwk and returns its contents
+func verifyDPoPProof(t *testing.T, proof string) dpopProof {
+	t.Helper()
+
+	parts := strings.Split(proof, ".")
+	require.Len(t, parts, 3)
+
+	headerJSON, err := base64.RawURLEncoding.DecodeString(parts[0])
+	require.NoError(t, err)
+	claimsJSON, err := base64.RawURLEncoding.DecodeString(parts[1])
+	require.NoError(t, err)
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	require.NoError(t, err)
+
+	var header struct {
+		Type      string           `json:"typ"`
+		Algorithm string           `json:"alg"`
+		Key       httpx.JSONWebKey `json:"jwk"`
+	}
+	require.NoError(t, json.Unmarshal(headerJSON, &header))
+	assert.Equal(t, "dpop+jwt", header.Type)
+	assert.Equal(t, "ES256", header.Algorithm)
+
+	pub, err := header.Key.PublicKey()
+	require.NoError(t, err)
+	ecPub, ok := pub.(*ecdsa.PublicKey)
+	require.True(t, ok)
+
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	require.Len(t, signature, 64)
+	require.True(t, ecdsa.Verify(ecPub, digest[:],
+		new(big.Int).SetBytes(signature[:32]), new(big.Int).SetBytes(signature[32:])))
+
+	result := dpopProof{Header: map[string]any{}, Claims: map[string]any{}}
+	require.NoError(t, json.Unmarshal(headerJSON, &result.Header))
+	require.NoError(t, json.Unmarshal(claimsJSON, &result.Claims))
+	return result
+}
+
+func TestDPoPMiddleware(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	t.Run("attaches proof bound to method, uri and access token", func(t *testing.T) {
+		var received *http.Request
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			received = r
+			w.WriteHeader(http.StatusOK)
+		}))
+		defer server.Close()
+
+		client := httpx.NewClientWithConfig(
+			httpx.WithClientDefaultBaseURL(server.URL),
+			httpx.WithClientDPoP(httpx.DPoPConfig{KeySource: httpx.StaticSigningKey{Signer: key}}),
+		)
+
+		_, err := client.Execute(*httpx.NewRequest(http.MethodPost,
+			httpx.WithPath("/resource"),
+			httpx.WithQueryParam("page", "2"),
+			httpx.WithHeader("Authorization", "Bearer access-token"),
+		), "")
+
+		require.NoError(t, err)
+		assert.Equal(t, "DPoP access-token", received.Header.Get("Authorization"))
+
+		proof := verifyDPoPProof(t, received.Header.Get("DPoP"))
+		tokenHash := sha256.Sum256([]byte("access-token"))
+		assert.Equal(t, http.MethodPost, proof.Claims["htm"])
+		assert.Equal(t, server.URL+"/resource", proof.Claims["htu"])
+		assert.Equal(t, base64.RawURLEncoding.EncodeToString(tokenHash[:]), proof.Claims["ath"])
+		assert.NotEmpty(t, proof.Claims["jti"])
+		assert.NotContains(t, proof.Claims, "nonce")
+	})
+
+	t.Run("keeps bearer scheme when configured", func(t *testing.T) {
+		var received *http.Request
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			received = r
+			w.WriteHeader(http.StatusOK)
+		}))
+		defer server.Close()
+
+		client := httpx.NewClientWithConfig(
+			httpx.WithClientDefaultBaseURL(server.URL),
+			httpx.WithClientDPoP(httpx.DPoPConfig{
+				KeySource:        httpx.StaticSigningKey{Signer: key},
+				KeepBearerScheme: true,
+			}),
+		)
+
+		_, err := client.Execute(*httpx.NewRequest(http.MethodGet, httpx.WithHeader("Authorization", "Bearer token")), "")
+
+		require.NoError(t, err)
+		assert.Equal(t, "Bearer token", received.Header.Get("Authorization"))
+		assert.NotEmpty(t, received.Header.Get("DPoP"))
+	})
+
+	t.Run("retries once with server provided nonce", func(t *testing.T) {
+		var calls atomic.Int32
+		var lastProof dpopProof
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls.Add(1)
+			lastProof = verifyDPoPProof(t, r.Header.Get("DPoP"))
+			if lastProof.Claims["nonce"] != "server-nonce" {
+				w.Header().Set("DPoP-Nonce", "server-nonce")
+				w.Header().Set("WWW-Authenticate", `DPoP error="use_dpop_nonce"`)
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+		}))
+		defer server.Close()
+
+		client := httpx.NewClientWithConfig(
+			httpx.WithClientDefaultBaseURL(server.URL),
+			httpx.WithClientDPoP(httpx.DPoPConfig{KeySource: httpx.StaticSigningKey{Signer: key}}),
+		)
+
+		resp, err := client.Execute(*httpx.NewRequest(http.MethodPost,
+			httpx.WithJSONBody(map[string]string{"name": "test"}),
+			httpx.WithHeader("Authorization", "DPoP token"),
+		), "")
+
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, int32(2), calls.Load())
+
+		// Subsequent requests reuse the cached nonce
+		_, err = client.Execute(*httpx.NewRequest(http.MethodGet), "")
+		require.NoError(t, err)
+		assert.Equal(t, int32(3), calls.Load())
+		assert.Equal(t, "server-nonce", lastProof.Claims["nonce"])
+	})
+
+	t.Run("fails without key source", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}))
+		defer server.Close()
+
+		client := httpx.NewClientWithConfig(
+			httpx.WithClientDefaultBaseURL(server.URL),
+			httpx.WithClientDPoP(httpx.DPoPConfig{}),
+		)
+
+		_, err := client.Execute(*httpx.NewRequest(http.MethodGet), "")
+
+		require.Error(t, err)
+		assert.True(t, httpx.IsMiddlewareError(err))
+	})
+}
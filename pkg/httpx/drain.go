@@ -0,0 +1,164 @@
+package httpx
+
+import (
+	"io"
+	"net/http"
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// defaultDrainLimit is the maximum number of bytes read from an abandoned body so its connection can be reused
+const defaultDrainLimit int64 = 256 << 10
+
+// BodyDrainOutcome describes what happened to a response body that was not fully consumed by the caller
+type BodyDrainOutcome string
+
+const (
+	// BodyDrainOutcomeDrained means the body was read to EOF and closed, returning the connection to the pool
+	BodyDrainOutcomeDrained BodyDrainOutcome = "drained"
+	// BodyDrainOutcomeDiscarded means the body exceeded the drain limit and was closed without reusing the connection
+	BodyDrainOutcomeDiscarded BodyDrainOutcome = "discarded"
+	// BodyDrainOutcomeLeaked means a streaming body was garbage collected without being closed
+	BodyDrainOutcomeLeaked BodyDrainOutcome = "leaked"
+)
+
+// BodyDrainObserver is notified whenever the client cleans up a response body on the caller's behalf
+type BodyDrainObserver interface {
+	ObserveBodyDrain(method, url string, outcome BodyDrainOutcome)
+}
+
+// BodyDrainStats holds counters of response bodies cleaned up by the client
+type BodyDrainStats struct {
+	Drained   int64 // Bodies read to EOF and returned to the pool
+	Discarded int64 // Bodies closed without draining because they exceeded the drain limit
+	Leaked    int64 // Streaming bodies garbage collected without being closed
+}
+
+// bodyDrainer drains abandoned response bodies and tracks streaming bodies for leaks
+type bodyDrainer struct {
+	limit     int64
+	observer  BodyDrainObserver
+	drained   atomic.Int64
+	discarded atomic.Int64
+	leaked    atomic.Int64
+}
+
+// newBodyDrainer creates a drainer for the client configuration
+func newBodyDrainer(config ClientConfig) *bodyDrainer {
+	limit := config.DrainLimit
+	if limit == 0 {
+		limit = defaultDrainLimit
+	}
+	return &bodyDrainer{limit: limit, observer: config.BodyDrainObserver}
+}
+
+// drainLimit returns the configured limit, falling back to the default for clients without a drainer
+func (d *bodyDrainer) drainLimit() int64 {
+	if d == nil {
+		return defaultDrainLimit
+	}
+	return d.limit
+}
+
+// release drains and closes a response body that will not be handed to the caller
+func (d *bodyDrainer) release(resp *http.Response) {
+	if resp == nil || resp.Body == nil {
+		return
+	}
+	outcome := BodyDrainOutcomeDiscarded
+	if drainAndClose(resp.Body, d.drainLimit()) {
+		outcome = BodyDrainOutcomeDrained
+	}
+	d.record(resp.Request, outcome)
+}
+
+// track wraps the streaming body of the response and reports it as leaked if the body becomes unreachable
+// before it is closed
+// The cleanup is attached to the body rather than the response, as callers often keep only StreamBody.
+func (d *bodyDrainer) track(response *Response, req *http.Request) {
+	if d == nil || response.StreamBody == nil {
+		return
+	}
+	body := &trackedBody{ReadCloser: response.StreamBody, limit: d.limit, closed: new(atomic.Bool)}
+	response.StreamBody = body
+
+	// The argument must not reference the tracked body, or it would never become unreachable
+	runtime.AddCleanup(body, func(leaked leakedBody) {
+		if leaked.closed.Load() {
+			return
+		}
+		d.record(leaked.req, BodyDrainOutcomeLeaked)
+		_ = drainAndClose(leaked.body, d.limit)
+	}, leakedBody{body: body.ReadCloser, req: req, closed: body.closed})
+}
+
+// leakedBody holds what the cleanup of a tracked body needs to release it
+type leakedBody struct {
+	body   io.ReadCloser
+	req    *http.Request
+	closed *atomic.Bool
+}
+
+// record updates counters and notifies the observer
+func (d *bodyDrainer) record(req *http.Request, outcome BodyDrainOutcome) {
+	if d == nil {
+		return
+	}
+	switch outcome {
+	case BodyDrainOutcomeDrained:
+		d.drained.Add(1)
+	case BodyDrainOutcomeDiscarded:
+		d.discarded.Add(1)
+	case BodyDrainOutcomeLeaked:
+		d.leaked.Add(1)
+	}
+	if d.observer != nil && req != nil {
+		d.observer.ObserveBodyDrain(req.Method, req.URL.String(), outcome)
+	}
+}
+
+// stats returns a snapshot of the counters
+func (d *bodyDrainer) stats() BodyDrainStats {
+	if d == nil {
+		return BodyDrainStats{}
+	}
+	return BodyDrainStats{
+		Drained:   d.drained.Load(),
+		Discarded: d.discarded.Load(),
+		Leaked:    d.leaked.Load(),
+	}
+}
+
+// trackedBody is a streaming body that drains itself on Close so the connection can be reused
+type trackedBody struct {
+	io.ReadCloser
+	limit  int64
+	once   sync.Once
+	closed *atomic.Bool // Shared with the cleanup reporting leaks
+	err    error
+}
+
+// Close drains up to the limit and closes the underlying body
+func (b *trackedBody) Close() error {
+	b.once.Do(func() {
+		b.closed.Store(true)
+		if b.limit > 0 {
+			_, _ = io.CopyN(io.Discard, b.ReadCloser, b.limit)
+		}
+		b.err = b.ReadCloser.Close()
+	})
+	return b.err
+}
+
+// drainAndClose reads up to limit bytes from the body and closes it, reporting whether EOF was reached
+// A negative limit closes the body without draining
+func drainAndClose(body io.ReadCloser, limit int64) bool {
+	drained := false
+	if limit >= 0 {
+		n, err := io.CopyN(io.Discard, body, limit+1)
+		drained = n <= limit && err == io.EOF
+	}
+	_ = body.Close()
+	return drained
+}
@@ -0,0 +1,219 @@
+package httpx_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bdpiprava/easy-http/pkg/httpx"
+)
+
+// newConnCountingServer starts a server that counts new TCP connections
+func newConnCountingServer(t *testing.T, handler http.HandlerFunc) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+
+	var conns atomic.Int32
+	server := httptest.NewUnstartedServer(handler)
+	server.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			conns.Add(1)
+		}
+	}
+	server.Start()
+	t.Cleanup(server.Close)
+	return server, &conns
+}
+
+// failingMiddleware returns the response together with an error, abandoning the body
+type failingMiddleware struct{}
+
+func (failingMiddleware) Name() string { return "failing" }
+
+func (failingMiddleware) Execute(ctx context.Context, req *http.Request, next httpx.MiddlewareFunc) (*http.Response, error) {
+	resp, err := next(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return resp, errors.New("rejected by middleware")
+}
+
+// recordingDrainObserver records observed drain outcomes
+type recordingDrainObserver struct {
+	mu       sync.Mutex
+	outcomes []httpx.BodyDrainOutcome
+}
+
+func (o *recordingDrainObserver) ObserveBodyDrain(_, _ string, outcome httpx.BodyDrainOutcome) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.outcomes = append(o.outcomes, outcome)
+}
+
+func TestBodyDraining(t *testing.T) {
+	t.Run("retry drains intermediate responses and reuses the connection", func(t *testing.T) {
+		var attempts atomic.Int32
+		server, conns := newConnCountingServer(t, func(w http.ResponseWriter, _ *http.Request) {
+			if attempts.Add(1) < 3 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				_, _ = w.Write([]byte(strings.Repeat("e", 4096)))
+				return
+			}
+			_, _ = w.Write([]byte(`{"ok":true}`))
+		})
+
+		client := httpx.NewClientWithConfig(
+			httpx.WithClientDefaultBaseURL(server.URL),
+			httpx.WithClientRetryPolicy(httpx.RetryPolicy{
+				MaxAttempts:          3,
+				BaseDelay:            time.Millisecond,
+				Strategy:             httpx.RetryStrategyFixed,
+				RetryableStatusCodes: []int{http.StatusServiceUnavailable},
+			}),
+		)
+
+		resp, err := client.Execute(*httpx.NewRequest(http.MethodGet), map[string]any{})
+
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, int32(3), attempts.Load())
+		assert.Equal(t, int32(1), conns.Load())
+	})
+
+	t.Run("drains body when middleware fails", func(t *testing.T) {
+		server, conns := newConnCountingServer(t, func(w http.ResponseWriter, _ *http.Request) {
+			_, _ = w.Write([]byte(`{"ok":true}`))
+		})
+		observer := &recordingDrainObserver{}
+
+		client := httpx.NewClientWithConfig(
+			httpx.WithClientDefaultBaseURL(server.URL),
+			httpx.WithClientMiddleware(failingMiddleware{}),
+			httpx.WithClientBodyDrainObserver(observer),
+		)
+
+		for range 3 {
+			_, err := client.Execute(*httpx.NewRequest(http.MethodGet), map[string]any{})
+			require.Error(t, err)
+		}
+
+		assert.Equal(t, httpx.BodyDrainStats{Drained: 3}, client.BodyDrainStats())
+		assert.Equal(t, []httpx.BodyDrainOutcome{"drained", "drained", "drained"}, observer.outcomes)
+		assert.Equal(t, int32(1), conns.Load())
+	})
+
+	t.Run("discards bodies larger than the drain limit", func(t *testing.T) {
+		server, _ := newConnCountingServer(t, func(w http.ResponseWriter, _ *http.Request) {
+			_, _ = w.Write([]byte(strings.Repeat("x", 2048)))
+		})
+
+		client := httpx.NewClientWithConfig(
+			httpx.WithClientDefaultBaseURL(server.URL),
+			httpx.WithClientMiddleware(failingMiddleware{}),
+			httpx.WithClientDrainLimit(1024),
+		)
+
+		_, err := client.Execute(*httpx.NewRequest(http.MethodGet), "")
+
+		require.Error(t, err)
+		assert.Equal(t, httpx.BodyDrainStats{Discarded: 1}, client.BodyDrainStats())
+	})
+
+	t.Run("closing a partially read stream returns the connection", func(t *testing.T) {
+		server, conns := newConnCountingServer(t, func(w http.ResponseWriter, _ *http.Request) {
+			_, _ = w.Write([]byte(strings.Repeat("s", 8192)))
+		})
+
+		client := httpx.NewClientWithConfig(httpx.WithClientDefaultBaseURL(server.URL))
+
+		for range 3 {
+			resp, err := client.Execute(*httpx.NewRequest(http.MethodGet, httpx.WithStreaming()), "")
+			require.NoError(t, err)
+
+			buf := make([]byte, 16)
+			_, err = resp.StreamBody.Read(buf)
+			require.NoError(t, err)
+			require.NoError(t, resp.Close())
+		}
+
+		assert.Equal(t, int32(1), conns.Load())
+		assert.Equal(t, httpx.BodyDrainStats{}, client.BodyDrainStats())
+	})
+
+	t.Run("reports leaked streaming bodies", func(t *testing.T) {
+		server, _ := newConnCountingServer(t, func(w http.ResponseWriter, _ *http.Request) {
+			_, _ = w.Write([]byte("stream"))
+		})
+		observer := &recordingDrainObserver{}
+
+		client := httpx.NewClientWithConfig(
+			httpx.WithClientDefaultBaseURL(server.URL),
+			httpx.WithClientBodyDrainObserver(observer),
+		)
+
+		func() {
+			resp, err := client.Execute(*httpx.NewRequest(http.MethodGet, httpx.WithStreaming()), "")
+			require.NoError(t, err)
+			require.True(t, resp.IsStreaming)
+		}()
+
+		require.Eventually(t, func() bool {
+			runtime.GC()
+			return client.BodyDrainStats().Leaked == 1
+		}, 5*time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("does not release a stream still read after its response is dropped", func(t *testing.T) {
+		server, _ := newConnCountingServer(t, func(w http.ResponseWriter, _ *http.Request) {
+			for range 5 {
+				_, _ = w.Write([]byte("chunk;"))
+				w.(http.Flusher).Flush()
+				time.Sleep(10 * time.Millisecond)
+			}
+		})
+
+		client := httpx.NewClientWithConfig(httpx.WithClientDefaultBaseURL(server.URL))
+
+		stream := func() io.ReadCloser {
+			resp, err := client.Execute(*httpx.NewRequest(http.MethodGet, httpx.WithStreaming()), "")
+			require.NoError(t, err)
+			return resp.StreamBody
+		}()
+		runtime.GC()
+		runtime.GC()
+
+		body, err := io.ReadAll(stream)
+		require.NoError(t, err)
+		require.NoError(t, stream.Close())
+
+		assert.Equal(t, strings.Repeat("chunk;", 5), string(body))
+		assert.Equal(t, httpx.BodyDrainStats{}, client.BodyDrainStats())
+	})
+
+	t.Run("close idle connections forces a new connection", func(t *testing.T) {
+		server, conns := newConnCountingServer(t, func(w http.ResponseWriter, _ *http.Request) {
+			_, _ = w.Write([]byte(`{}`))
+		})
+
+		client := httpx.NewClientWithConfig(httpx.WithClientDefaultBaseURL(server.URL))
+
+		_, err := client.Execute(*httpx.NewRequest(http.MethodGet), map[string]any{})
+		require.NoError(t, err)
+		client.CloseIdleConnections()
+		_, err = client.Execute(*httpx.NewRequest(http.MethodGet), map[string]any{})
+		require.NoError(t, err)
+
+		assert.Equal(t, int32(2), conns.Load())
+	})
+}
@@ -0,0 +1,431 @@
+package httpx
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// PayloadEncryptionFormat defines how encrypted payloads are serialized
+type PayloadEncryptionFormat string
+
+const (
+	// PayloadEncryptionJWE serializes payloads as compact JWE (RFC 7516) with Content-Type application/jose
+	PayloadEncryptionJWE PayloadEncryptionFormat = "jwe"
+	// PayloadEncryptionAESGCM serializes payloads as raw nonce || ciphertext || tag with Content-Type application/octet-stream
+	PayloadEncryptionAESGCM PayloadEncryptionFormat = "aes-gcm"
+)
+
+const (
+	contentTypeJOSE        = "application/jose"
+	contentTypeOctetStream = "application/octet-stream"
+	defaultKeyIDHeader     = "X-Encryption-Key-Id"
+)
+
+// EncryptionKey holds the key material used to encrypt or decrypt payloads
+type EncryptionKey struct {
+	KeyID      string          // Key identifier (JWE "kid" or the key id header for AES-GCM)
+	Secret     []byte          // Shared AES key (16, 24 or 32 bytes) used for JWE "dir" and AES-GCM
+	PublicKey  *rsa.PublicKey  // Recipient key used to wrap the content key with RSA-OAEP-256
+	PrivateKey *rsa.PrivateKey // Own key used to unwrap RSA-OAEP-256 content keys on responses
+}
+
+// EncryptionKeyProvider supplies keys for payload encryption, allowing keys to be rotated or loaded from a KMS
+type EncryptionKeyProvider interface {
+	// EncryptionKey returns the key used to encrypt outbound request bodies
+	EncryptionKey(ctx context.Context) (EncryptionKey, error)
+	// DecryptionKey returns the key identified by keyID used to decrypt response bodies
+	DecryptionKey(ctx context.Context, keyID string) (EncryptionKey, error)
+}
+
+// StaticEncryptionKey is an EncryptionKeyProvider that always returns the same key
+type StaticEncryptionKey EncryptionKey
+
+// EncryptionKey implements EncryptionKeyProvider
+func (k StaticEncryptionKey) EncryptionKey(_ context.Context) (EncryptionKey, error) {
+	return EncryptionKey(k), nil
+}
+
+// DecryptionKey implements EncryptionKeyProvider
+func (k StaticEncryptionKey) DecryptionKey(_ context.Context, _ string) (EncryptionKey, error) {
+	return EncryptionKey(k), nil
+}
+
+// PayloadEncryptionConfig configures end-to-end encryption of request and response bodies
+type PayloadEncryptionConfig struct {
+	KeyProvider               EncryptionKeyProvider   // Source of encryption keys (required)
+	Format                    PayloadEncryptionFormat // Payload format (default: jwe)
+	ContentEncryption         string                  // JWE "enc" algorithm: A128GCM, A192GCM or A256GCM (default: derived from the key size)
+	KeyIDHeader               string                  // Header advertising the key id in aes-gcm format (default: X-Encryption-Key-Id)
+	DisableRequestEncryption  bool                    // If true, request bodies are sent as-is
+	DisableResponseDecryption bool                    // If true, encrypted response bodies are returned as-is
+}
+
+// PayloadEncryptionMiddleware encrypts request bodies after marshaling and decrypts encrypted response bodies
+//
+// The middleware re-reads the plaintext body through GetBody on every attempt, so each retry is encrypted
+// with a fresh IV. WithClientPayloadEncryption places it ahead of compression and message signing so the
+// ciphertext is what gets signed, and compression is not wasted on incompressible data.
+type PayloadEncryptionMiddleware struct {
+	config PayloadEncryptionConfig
+}
+
+// NewPayloadEncryptionMiddleware creates a new payload encryption middleware
+func NewPayloadEncryptionMiddleware(config PayloadEncryptionConfig) *PayloadEncryptionMiddleware {
+	if config.Format == "" {
+		config.Format = PayloadEncryptionJWE
+	}
+	if config.KeyIDHeader == "" {
+		config.KeyIDHeader = defaultKeyIDHeader
+	}
+	return &PayloadEncryptionMiddleware{config: config}
+}
+
+// Name returns the middleware name
+func (m *PayloadEncryptionMiddleware) Name() string {
+	return "payload-encryption"
+}
+
+// Execute implements the Middleware interface
+func (m *PayloadEncryptionMiddleware) Execute(ctx context.Context, req *http.Request, next MiddlewareFunc) (*http.Response, error) {
+	if m.config.KeyProvider == nil {
+		return nil, MiddlewareError("payload encryption key provider is not configured", nil, req)
+	}
+
+	if !m.config.DisableRequestEncryption && req.Body != nil && req.Body != http.NoBody {
+		if err := m.encryptRequest(ctx, req); err != nil {
+			return nil, MiddlewareError("failed to encrypt request body", err, req)
+		}
+	}
+
+	resp, err := next(ctx, req)
+	if err != nil {
+		return resp, err
+	}
+
+	if !m.config.DisableResponseDecryption && m.isEncrypted(resp) {
+		if err := m.decryptResponse(ctx, resp); err != nil {
+			resp.Body.Close()
+			return nil, MiddlewareError("failed to decrypt response body", err, req)
+		}
+	}
+	return resp, nil
+}
+
+// encryptRequest replaces the request body with its encrypted form
+func (m *PayloadEncryptionMiddleware) encryptRequest(ctx context.Context, req *http.Request) error {
+	plaintext, err := readRequestBody(req)
+	if err != nil {
+		return err
+	}
+
+	key, err := m.config.KeyProvider.EncryptionKey(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to load encryption key")
+	}
+
+	var encrypted []byte
+	contentType := req.Header.Get("Content-Type")
+	switch m.config.Format {
+	case PayloadEncryptionJWE:
+		token, jweErr := encryptJWE(key, m.config.ContentEncryption, contentType, plaintext)
+		if jweErr != nil {
+			return jweErr
+		}
+		encrypted = []byte(token)
+		req.Header.Set("Content-Type", contentTypeJOSE)
+	case PayloadEncryptionAESGCM:
+		if encrypted, err = sealAESGCM(key.Secret, plaintext, nil); err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", contentTypeOctetStream)
+		if key.KeyID != "" {
+			req.Header.Set(m.config.KeyIDHeader, key.KeyID)
+		}
+	default:
+		return errors.Errorf("unsupported payload encryption format: %s", m.config.Format)
+	}
+
+	req.Body = io.NopCloser(bytes.NewReader(encrypted))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(encrypted)), nil
+	}
+	req.ContentLength = int64(len(encrypted))
+	return nil
+}
+
+// isEncrypted checks if the response carries an encrypted payload in the configured format
+func (m *PayloadEncryptionMiddleware) isEncrypted(resp *http.Response) bool {
+	if resp == nil || resp.Body == nil {
+		return false
+	}
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	switch m.config.Format {
+	case PayloadEncryptionJWE:
+		return mediaType == contentTypeJOSE
+	case PayloadEncryptionAESGCM:
+		return mediaType == contentTypeOctetStream && resp.Header.Get(m.config.KeyIDHeader) != ""
+	default:
+		return false
+	}
+}
+
+// decryptResponse replaces the response body with its decrypted form
+func (m *PayloadEncryptionMiddleware) decryptResponse(ctx context.Context, resp *http.Response) error {
+	encrypted, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return errors.Wrap(err, "failed to read response body")
+	}
+	resp.Body.Close()
+
+	var plaintext []byte
+	contentType := "application/json"
+	switch m.config.Format {
+	case PayloadEncryptionJWE:
+		var cty string
+		plaintext, cty, err = decryptJWE(ctx, m.config.KeyProvider, strings.TrimSpace(string(encrypted)))
+		if cty != "" {
+			contentType = cty
+		}
+	case PayloadEncryptionAESGCM:
+		var key EncryptionKey
+		if key, err = m.config.KeyProvider.DecryptionKey(ctx, resp.Header.Get(m.config.KeyIDHeader)); err != nil {
+			return errors.Wrap(err, "failed to load decryption key")
+		}
+		plaintext, err = openAESGCM(key.Secret, encrypted, nil)
+	}
+	if err != nil {
+		return err
+	}
+
+	resp.Body = io.NopCloser(bytes.NewReader(plaintext))
+	resp.ContentLength = int64(len(plaintext))
+	resp.Header.Set("Content-Type", contentType)
+	resp.Header.Set("Content-Length", strconv.Itoa(len(plaintext)))
+	resp.Header.Del(m.config.KeyIDHeader)
+	return nil
+}
+
+// readRequestBody reads the request body, preferring GetBody so a retried request sees the original payload
+func readRequestBody(req *http.Request) ([]byte, error) {
+	body := req.Body
+	if req.GetBody != nil {
+		fresh, err := req.GetBody()
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to get request body")
+		}
+		body = fresh
+	}
+	defer body.Close()
+
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read request body")
+	}
+	return data, nil
+}
+
+// jweHeader is the protected header of a compact JWE
+type jweHeader struct {
+	Algorithm         string `json:"alg"`
+	ContentEncryption string `json:"enc"`
+	KeyID             string `json:"kid,omitempty"`
+	ContentType       string `json:"cty,omitempty"`
+}
+
+// encryptJWE encrypts the plaintext as a compact JWE using "dir" or "RSA-OAEP-256" key management
+func encryptJWE(key EncryptionKey, enc, contentType string, plaintext []byte) (string, error) {
+	header := jweHeader{KeyID: key.KeyID, ContentType: contentType}
+
+	var cek, encryptedKey []byte
+	switch {
+	case len(key.Secret) > 0:
+		header.Algorithm = "dir"
+		cek = key.Secret
+		if enc == "" {
+			enc = "A" + strconv.Itoa(len(cek)*8) + "GCM"
+		}
+	case key.PublicKey != nil:
+		header.Algorithm = "RSA-OAEP-256"
+		if enc == "" {
+			enc = "A256GCM"
+		}
+		size, err := jweKeySize(enc)
+		if err != nil {
+			return "", err
+		}
+		cek = make([]byte, size)
+		if _, err := rand.Read(cek); err != nil {
+			return "", err
+		}
+		if encryptedKey, err = rsa.EncryptOAEP(sha256.New(), rand.Reader, key.PublicKey, cek, nil); err != nil {
+			return "", errors.Wrap(err, "failed to wrap content encryption key")
+		}
+	default:
+		return "", errors.New("encryption key has no secret or public key")
+	}
+
+	size, err := jweKeySize(enc)
+	if err != nil {
+		return "", err
+	}
+	if len(cek) != size {
+		return "", errors.Errorf("key size %d does not match content encryption %s", len(cek), enc)
+	}
+	header.ContentEncryption = enc
+
+	headerJSON, err := json.Marshal(header)
+	if err != nil {
+		return "", err
+	}
+	protected := base64.RawURLEncoding.EncodeToString(headerJSON)
+
+	// The ASCII protected header is the additional authenticated data
+	sealed, err := sealAESGCM(cek, plaintext, []byte(protected))
+	if err != nil {
+		return "", err
+	}
+	iv, ciphertext, tag := sealed[:gcmNonceSize], sealed[gcmNonceSize:len(sealed)-gcmTagSize], sealed[len(sealed)-gcmTagSize:]
+
+	return strings.Join([]string{
+		protected,
+		base64.RawURLEncoding.EncodeToString(encryptedKey),
+		base64.RawURLEncoding.EncodeToString(iv),
+		base64.RawURLEncoding.EncodeToString(ciphertext),
+		base64.RawURLEncoding.EncodeToString(tag),
+	}, "."), nil
+}
+
+// decryptJWE decrypts a compact JWE, returning the plaintext and its "cty" header
+func decryptJWE(ctx context.Context, provider EncryptionKeyProvider, token string) ([]byte, string, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 5 {
+		return nil, "", errors.New("malformed JWE: expected 5 parts")
+	}
+
+	decoded := make([][]byte, len(parts))
+	for i, part := range parts {
+		data, err := base64.RawURLEncoding.DecodeString(part)
+		if err != nil {
+			return nil, "", errors.Wrap(err, "malformed JWE encoding")
+		}
+		decoded[i] = data
+	}
+
+	var header jweHeader
+	if err := json.Unmarshal(decoded[0], &header); err != nil {
+		return nil, "", errors.Wrap(err, "malformed JWE header")
+	}
+
+	key, err := provider.DecryptionKey(ctx, header.KeyID)
+	if err != nil {
+		return nil, "", errors.Wrap(err, "failed to load decryption key")
+	}
+
+	var cek []byte
+	switch header.Algorithm {
+	case "dir":
+		cek = key.Secret
+	case "RSA-OAEP-256":
+		if key.PrivateKey == nil {
+			return nil, "", errors.New("decryption key has no private key")
+		}
+		if cek, err = rsa.DecryptOAEP(sha256.New(), rand.Reader, key.PrivateKey, decoded[1], nil); err != nil {
+			return nil, "", errors.Wrap(err, "failed to unwrap content encryption key")
+		}
+	default:
+		return nil, "", errors.Errorf("unsupported JWE algorithm: %s", header.Algorithm)
+	}
+
+	size, err := jweKeySize(header.ContentEncryption)
+	if err != nil {
+		return nil, "", err
+	}
+	if len(cek) != size {
+		return nil, "", errors.Errorf("key size %d does not match content encryption %s", len(cek), header.ContentEncryption)
+	}
+	if len(decoded[2]) != gcmNonceSize || len(decoded[4]) != gcmTagSize {
+		return nil, "", errors.New("malformed JWE: invalid IV or tag length")
+	}
+
+	sealed := make([]byte, 0, len(decoded[2])+len(decoded[3])+len(decoded[4]))
+	sealed = append(sealed, decoded[2]...)
+	sealed = append(sealed, decoded[3]...)
+	sealed = append(sealed, decoded[4]...)
+
+	plaintext, err := openAESGCM(cek, sealed, []byte(parts[0]))
+	if err != nil {
+		return nil, "", err
+	}
+	return plaintext, header.ContentType, nil
+}
+
+// jweKeySize returns the content encryption key size for a JWE "enc" algorithm
+func jweKeySize(enc string) (int, error) {
+	switch enc {
+	case "A128GCM":
+		return 16, nil
+	case "A192GCM":
+		return 24, nil
+	case "A256GCM":
+		return 32, nil
+	default:
+		return 0, errors.Errorf("unsupported JWE content encryption: %s", enc)
+	}
+}
+
+const (
+	gcmNonceSize = 12
+	gcmTagSize   = 16
+)
+
+// sealAESGCM encrypts the plaintext with a random nonce, returning nonce || ciphertext || tag
+func sealAESGCM(key, plaintext, additionalData []byte) ([]byte, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcmNonceSize, gcmNonceSize+len(plaintext)+gcmTagSize)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+// openAESGCM decrypts data produced by sealAESGCM
+func openAESGCM(key, sealed, additionalData []byte) ([]byte, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < gcmNonceSize+gcmTagSize {
+		return nil, errors.New("encrypted payload is too short")
+	}
+	plaintext, err := aead.Open(nil, sealed[:gcmNonceSize], sealed[gcmNonceSize:], additionalData)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to decrypt payload")
+	}
+	return plaintext, nil
+}
+
+// newGCM creates an AES-GCM cipher for the key
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid AES key")
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,260 @@
+package httpx_test
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bdpiprava/easy-http/pkg/httpx"
+)
+
+// decryptTestJWE decrypts a compact JWE using either the shared secret or the RSA private key
+func decryptTestJWE(t *testing.T, token string, secret []byte, privateKey *rsa.PrivateKey) (map[string]any, []byte) {
+	t.Helper()
+
+	parts := strings.Split(token, ".")
+	require.Len(t, parts, 5)
+
+	decode := func(s string) []byte {
+		data, err := base64.RawURLEncoding.DecodeString(s)
+		require.NoError(t, err)
+		return data
+	}
+
+	header := map[string]any{}
+	require.NoError(t, json.Unmarshal(decode(parts[0]), &header))
+
+	cek := secret
+	if privateKey != nil {
+		var err error
+		cek, err = rsa.DecryptOAEP(sha256.New(), rand.Reader, privateKey, decode(parts[1]), nil)
+		require.NoError(t, err)
+	}
+
+	block, err := aes.NewCipher(cek)
+	require.NoError(t, err)
+	aead, err := cipher.NewGCM(block)
+	require.NoError(t, err)
+
+	plaintext, err := aead.Open(nil, decode(parts[2]), append(decode(parts[3]), decode(parts[4])...), []byte(parts[0]))
+	require.NoError(t, err)
+	return header, plaintext
+}
+
+// encryptTestJWE encrypts the plaintext as a compact "dir" A256GCM JWE
+func encryptTestJWE(t *testing.T, secret, plaintext []byte, contentType string) string {
+	t.Helper()
+
+	headerJSON, err := json.Marshal(map[string]string{"alg": "dir", "enc": "A256GCM", "cty": contentType})
+	require.NoError(t, err)
+	protected := base64.RawURLEncoding.EncodeToString(headerJSON)
+
+	block, err := aes.NewCipher(secret)
+	require.NoError(t, err)
+	aead, err := cipher.NewGCM(block)
+	require.NoError(t, err)
+
+	iv := make([]byte, 12)
+	_, err = rand.Read(iv)
+	require.NoError(t, err)
+	sealed := aead.Seal(nil, iv, plaintext, []byte(protected))
+
+	enc := base64.RawURLEncoding
+	return protected + ".." + enc.EncodeToString(iv) + "." + enc.EncodeToString(sealed[:len(sealed)-16]) + "." + enc.EncodeToString(sealed[len(sealed)-16:])
+}
+
+func TestPayloadEncryptionMiddleware(t *testing.T) {
+	secret := make([]byte, 32)
+	_, err := rand.Read(secret)
+	require.NoError(t, err)
+
+	t.Run("encrypts request and decrypts response with direct JWE", func(t *testing.T) {
+		var header map[string]any
+		var plaintext []byte
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "application/jose", r.Header.Get("Content-Type"))
+			body, _ := io.ReadAll(r.Body)
+			header, plaintext = decryptTestJWE(t, string(body), secret, nil)
+
+			w.Header().Set("Content-Type", "application/jose")
+			_, _ = w.Write([]byte(encryptTestJWE(t, secret, []byte(`{"status":"accepted"}`), "application/json")))
+		}))
+		defer server.Close()
+
+		client := httpx.NewClientWithConfig(
+			httpx.WithClientDefaultBaseURL(server.URL),
+			httpx.WithClientPayloadEncryption(httpx.PayloadEncryptionConfig{
+				KeyProvider: httpx.StaticEncryptionKey{KeyID: "key-1", Secret: secret},
+			}),
+		)
+
+		resp, err := client.Execute(*httpx.NewRequest(http.MethodPost,
+			httpx.WithJSONBody(map[string]string{"card": "4111111111111111"}),
+		), map[string]any{})
+
+		require.NoError(t, err)
+		assert.Equal(t, "dir", header["alg"])
+		assert.Equal(t, "A256GCM", header["enc"])
+		assert.Equal(t, "key-1", header["kid"])
+		assert.Equal(t, "application/json", header["cty"])
+		assert.JSONEq(t, `{"card":"4111111111111111"}`, string(plaintext))
+		assert.Equal(t, map[string]any{"status": "accepted"}, resp.Body)
+		assert.Equal(t, "application/json", resp.Header().Get("Content-Type"))
+	})
+
+	t.Run("wraps content key with RSA-OAEP-256", func(t *testing.T) {
+		serverKey, err := rsa.GenerateKey(rand.Reader, 2048)
+		require.NoError(t, err)
+
+		var header map[string]any
+		var plaintext []byte
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+			header, plaintext = decryptTestJWE(t, string(body), nil, serverKey)
+			w.WriteHeader(http.StatusNoContent)
+		}))
+		defer server.Close()
+
+		client := httpx.NewClientWithConfig(
+			httpx.WithClientDefaultBaseURL(server.URL),
+			httpx.WithClientPayloadEncryption(httpx.PayloadEncryptionConfig{
+				KeyProvider: httpx.StaticEncryptionKey{KeyID: "server-key", PublicKey: &serverKey.PublicKey},
+			}),
+		)
+
+		_, err = client.Execute(*httpx.NewRequest(http.MethodPut, httpx.WithJSONBody(map[string]int{"id": 1})), nil)
+
+		require.NoError(t, err)
+		assert.Equal(t, "RSA-OAEP-256", header["alg"])
+		assert.Equal(t, "server-key", header["kid"])
+		assert.JSONEq(t, `{"id":1}`, string(plaintext))
+	})
+
+	t.Run("round trips raw AES-GCM payloads", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "application/octet-stream", r.Header.Get("Content-Type"))
+			assert.Equal(t, "key-2", r.Header.Get("X-Encryption-Key-Id"))
+
+			// Echo the encrypted body back
+			body, _ := io.ReadAll(r.Body)
+			w.Header().Set("Content-Type", "application/octet-stream")
+			w.Header().Set("X-Encryption-Key-Id", "key-2")
+			_, _ = w.Write(body)
+		}))
+		defer server.Close()
+
+		client := httpx.NewClientWithConfig(
+			httpx.WithClientDefaultBaseURL(server.URL),
+			httpx.WithClientPayloadEncryption(httpx.PayloadEncryptionConfig{
+				KeyProvider: httpx.StaticEncryptionKey{KeyID: "key-2", Secret: secret},
+				Format:      httpx.PayloadEncryptionAESGCM,
+			}),
+		)
+
+		resp, err := client.Execute(*httpx.NewRequest(http.MethodPost,
+			httpx.WithJSONBody(map[string]string{"hello": "world"}),
+		), map[string]any{})
+
+		require.NoError(t, err)
+		assert.Equal(t, map[string]any{"hello": "world"}, resp.Body)
+	})
+
+	t.Run("re-encrypts the original payload on retry", func(t *testing.T) {
+		var attempts atomic.Int32
+		var bodies []string
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+			bodies = append(bodies, string(body))
+			_, plaintext := decryptTestJWE(t, string(body), secret, nil)
+			assert.JSONEq(t, `{"attempt":"same"}`, string(plaintext))
+
+			if attempts.Add(1) == 1 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+		}))
+		defer server.Close()
+
+		client := httpx.NewClientWithConfig(
+			httpx.WithClientDefaultBaseURL(server.URL),
+			httpx.WithClientRetryPolicy(httpx.RetryPolicy{
+				MaxAttempts:          2,
+				BaseDelay:            time.Millisecond,
+				Strategy:             httpx.RetryStrategyFixed,
+				RetryableStatusCodes: []int{http.StatusServiceUnavailable},
+			}),
+			httpx.WithClientPayloadEncryption(httpx.PayloadEncryptionConfig{
+				KeyProvider: httpx.StaticEncryptionKey{Secret: secret},
+			}),
+		)
+
+		_, err := client.Execute(*httpx.NewRequest(http.MethodPost,
+			httpx.WithJSONBody(map[string]string{"attempt": "same"}),
+		), nil)
+
+		require.NoError(t, err)
+		require.Len(t, bodies, 2)
+		assert.NotEqual(t, bodies[0], bodies[1], "each attempt must use a fresh IV")
+	})
+
+	t.Run("encrypts before compression regardless of option order", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Empty(t, r.Header.Get("Content-Encoding"))
+			body, _ := io.ReadAll(r.Body)
+			_, plaintext := decryptTestJWE(t, string(body), secret, nil)
+			assert.Contains(t, string(plaintext), strings.Repeat("a", 100))
+			w.WriteHeader(http.StatusOK)
+		}))
+		defer server.Close()
+
+		client := httpx.NewClientWithConfig(
+			httpx.WithClientDefaultBaseURL(server.URL),
+			httpx.WithClientDefaultCompression(),
+			httpx.WithClientPayloadEncryption(httpx.PayloadEncryptionConfig{
+				KeyProvider: httpx.StaticEncryptionKey{Secret: secret},
+			}),
+		)
+
+		_, err := client.Execute(*httpx.NewRequest(http.MethodPost,
+			httpx.WithJSONBody(map[string]string{"data": strings.Repeat("a", 4096)}),
+		), nil)
+
+		require.NoError(t, err)
+	})
+
+	t.Run("fails on tampered response", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			token := encryptTestJWE(t, secret, []byte(`{"ok":true}`), "application/json")
+			w.Header().Set("Content-Type", "application/jose")
+			_, _ = w.Write([]byte(token[:len(token)-4] + "AAAA"))
+		}))
+		defer server.Close()
+
+		client := httpx.NewClientWithConfig(
+			httpx.WithClientDefaultBaseURL(server.URL),
+			httpx.WithClientPayloadEncryption(httpx.PayloadEncryptionConfig{
+				KeyProvider: httpx.StaticEncryptionKey{Secret: secret},
+			}),
+		)
+
+		_, err := client.Execute(*httpx.NewRequest(http.MethodGet), map[string]any{})
+
+		require.Error(t, err)
+		assert.True(t, httpx.IsMiddlewareError(err))
+	})
+}
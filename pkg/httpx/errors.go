@@ -31,8 +31,57 @@ const (
 	ErrorTypeUnknown ErrorType = "unknown"
 )
 
+// Sentinel errors matched with errors.Is, regardless of how many times the error was wrapped
+// by retries or middlewares. Prefer them over inspecting error messages.
+var (
+	// ErrTimeout matches any timeout, whatever the TimeoutPhase
+	ErrTimeout = errors.New("httpx: timeout")
+	// ErrCircuitOpen matches requests rejected by an open or saturated half-open circuit breaker
+	ErrCircuitOpen = errors.New("httpx: circuit breaker open")
+	// ErrRateLimited matches requests rejected by the client rate limiter and 429 responses
+	ErrRateLimited = errors.New("httpx: rate limited")
+	// ErrConcurrencyLimited matches requests rejected by the adaptive concurrency limiter or a bulkhead
+	ErrConcurrencyLimited = errors.New("httpx: concurrency limited")
+	// ErrTooLarge matches bodies exceeding a size limit
+	ErrTooLarge = errors.New("httpx: body too large")
+	// ErrDecode matches response bodies that could not be decoded into the response type
+	ErrDecode = errors.New("httpx: failed to decode response")
+	// ErrNoEndpoints matches requests for a service without a resolved endpoint able to serve them
+	ErrNoEndpoints = errors.New("httpx: no endpoints available")
+	// ErrOperationFailed matches long-running operations polled by AsyncOperation which failed or were canceled
+	ErrOperationFailed = errors.New("httpx: async operation failed")
+	// ErrDeadlineWouldExceed matches retries given up because their backoff would outlast the context deadline,
+	// see RetryDeadlineError for the attempts made
+	ErrDeadlineWouldExceed = errors.New("httpx: retry backoff would exceed the deadline")
+)
+
+// taggedError marks an error with a sentinel for errors.Is while keeping its message
+type taggedError struct {
+	sentinel error
+	cause    error
+}
+
+// tagError returns err tagged with sentinel, or sentinel itself when err is nil
+func tagError(err, sentinel error) error {
+	if err == nil {
+		return sentinel
+	}
+	return &taggedError{sentinel: sentinel, cause: err}
+}
+
+// Error implements the error interface
+func (e *taggedError) Error() string {
+	return e.cause.Error()
+}
+
+// Unwrap exposes both the cause and the sentinel to errors.Is and errors.As
+func (e *taggedError) Unwrap() []error {
+	return []error{e.cause, e.sentinel}
+}
+
 // HTTPError is the base error type for all HTTP-related errors
 type HTTPError struct {
+	Op         string          // Operation that failed, e.g. "GET /users/{id}" (see WithOperation)
 	Type       ErrorType       // The category of error
 	Message    string          // Human-readable error message
 	Cause      error           // The underlying error that caused this error
@@ -40,15 +89,23 @@ type HTTPError struct {
 	Response   *http.Response  // The HTTP response if available (may be nil)
 	StatusCode int             // HTTP status code if available (0 if not applicable)
 	Context    context.Context // Request context for additional metadata
+	// Business context attached to the request with WithAnnotation (nil if none)
+	Annotations map[string]string
 }
 
 // Error implements the error interface
 func (e *HTTPError) Error() string {
+	var msg string
 	if e.Request != nil {
-		return fmt.Sprintf("%s error for %s %s: %s",
+		msg = fmt.Sprintf("%s error for %s %s: %s",
 			e.Type, e.Request.Method, e.Request.URL.String(), e.Message)
+	} else {
+		msg = fmt.Sprintf("%s error: %s", e.Type, e.Message)
+	}
+	if len(e.Annotations) > 0 {
+		msg += " [" + formatAnnotations(e.Annotations) + "]"
 	}
-	return fmt.Sprintf("%s error: %s", e.Type, e.Message)
+	return msg
 }
 
 // Unwrap implements the unwrapper interface for error chains
@@ -57,7 +114,18 @@ func (e *HTTPError) Unwrap() error {
 }
 
 // Is implements the error equality interface
+// Besides matching HTTPErrors of the same type and status, it matches the ErrTimeout, ErrRateLimited
+// and ErrTooLarge sentinels from the error classification; other sentinels are found in the Cause chain
 func (e *HTTPError) Is(target error) bool {
+	switch target {
+	case ErrTimeout:
+		return e.Type == ErrorTypeTimeout
+	case ErrRateLimited:
+		return e.StatusCode == http.StatusTooManyRequests
+	case ErrTooLarge:
+		maxBytesErr := &http.MaxBytesError{}
+		return e.StatusCode == http.StatusRequestEntityTooLarge || errors.As(e.Cause, &maxBytesErr)
+	}
 	if httpErr, ok := target.(*HTTPError); ok {
 		return e.Type == httpErr.Type && e.StatusCode == httpErr.StatusCode
 	}
@@ -77,13 +145,15 @@ func NewHTTPError(errorType ErrorType, message string, cause error, req *http.Re
 	}
 
 	return &HTTPError{
-		Type:       errorType,
-		Message:    message,
-		Cause:      cause,
-		Request:    req,
-		Response:   resp,
-		StatusCode: statusCode,
-		Context:    ctx,
+		Op:          requestOperation(req),
+		Type:        errorType,
+		Message:     message,
+		Cause:       cause,
+		Request:     req,
+		Response:    resp,
+		StatusCode:  statusCode,
+		Context:     ctx,
+		Annotations: AnnotationsFromContext(ctx),
 	}
 }
 
@@ -143,6 +213,16 @@ func ClassifyError(err error, req *http.Request, resp *http.Response) *HTTPError
 	// Analyze the error to determine its type
 	errorType, message := classifyErrorType(err)
 
+	// Record which phase timed out so slow dials can be told apart from slow origins
+	if errorType == ErrorTypeTimeout {
+		phase := classifyTimeoutPhase(err)
+		message = timeoutMessage(phase)
+		phaseErr := &TimeoutPhaseError{}
+		if !errors.As(err, &phaseErr) {
+			err = &TimeoutPhaseError{Phase: phase, Cause: err}
+		}
+	}
+
 	return NewHTTPError(errorType, message, err, req, resp)
 }
 
@@ -341,3 +421,21 @@ func GetRequestContext(err error) context.Context {
 	}
 	return nil
 }
+
+// GetOperation extracts the operation name from an error if available
+func GetOperation(err error) string {
+	httpErr := &HTTPError{}
+	if errors.As(err, &httpErr) {
+		return httpErr.Op
+	}
+	return ""
+}
+
+// GetAnnotations extracts the request annotations from an error if available
+func GetAnnotations(err error) map[string]string {
+	httpErr := &HTTPError{}
+	if errors.As(err, &httpErr) {
+		return httpErr.Annotations
+	}
+	return nil
+}
@@ -2,12 +2,16 @@ package httpx_test
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"log/slog"
 	"net"
 	"net/http"
 	"net/http/httptest"
 	"syscall"
 	"testing"
+	"time"
 
 	"github.com/bdpiprava/easy-http/pkg/httpx"
 	"github.com/stretchr/testify/assert"
@@ -331,3 +335,166 @@ func TestErrorHelperFunctions(t *testing.T) {
 		assert.Nil(t, httpx.GetRequestContext(regularErr))
 	})
 }
+
+func TestSentinelErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/slow":
+			time.Sleep(100 * time.Millisecond)
+		case "/fail":
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		case "/invalid":
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"id":`))
+			return
+		}
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	retryPolicy := httpx.DefaultRetryPolicy()
+	retryPolicy.MaxAttempts = 2
+	retryPolicy.BaseDelay = time.Millisecond
+	newClient := func(opts ...httpx.ClientConfigOption) *httpx.Client {
+		return httpx.NewClientWithConfig(append([]httpx.ClientConfigOption{
+			httpx.WithClientDefaultBaseURL(server.URL),
+			httpx.WithClientRetryPolicy(retryPolicy),
+			httpx.WithClientLogger(slog.New(slog.DiscardHandler)),
+		}, opts...)...)
+	}
+	get := func(client *httpx.Client, path string) error {
+		_, err := client.Execute(*httpx.NewRequest(http.MethodGet, httpx.WithPath(path)), struct{ ID int }{})
+		return err
+	}
+	req := httptest.NewRequest(http.MethodGet, "http://example.com/upload", nil)
+
+	sentinels := []error{httpx.ErrTimeout, httpx.ErrCircuitOpen, httpx.ErrRateLimited, httpx.ErrTooLarge, httpx.ErrDecode}
+	tests := []struct {
+		name      string
+		err       func(t *testing.T) error
+		want      error
+		httpError bool
+	}{
+		{
+			name: "timeout through retries",
+			err: func(_ *testing.T) error {
+				return get(newClient(httpx.WithClientTimeout(20*time.Millisecond)), "/slow")
+			},
+			want:      httpx.ErrTimeout,
+			httpError: true,
+		},
+		{
+			name: "open circuit breaker",
+			err: func(_ *testing.T) error {
+				config := httpx.DefaultCircuitBreakerConfig()
+				config.ReadyToTrip = func(counts httpx.Counts) bool { return counts.ConsecutiveFailures >= 1 }
+				client := newClient(httpx.WithClientCircuitBreaker(config))
+				_, _ = client.Execute(*httpx.NewRequest(http.MethodGet, httpx.WithPath("/fail")), map[string]any{})
+				return get(client, "/ok")
+			},
+			want:      httpx.ErrCircuitOpen,
+			httpError: true,
+		},
+		{
+			name: "client rate limiter",
+			err: func(t *testing.T) error {
+				client := newClient(httpx.WithClientRateLimit(httpx.RateLimitConfig{
+					RequestsPerSec:  1,
+					BurstSize:       1,
+					WaitOnLimit:     true,
+					MaxWaitDuration: 10 * time.Millisecond,
+				}))
+				require.NoError(t, get(client, "/ok"))
+				return get(client, "/ok")
+			},
+			want:      httpx.ErrRateLimited,
+			httpError: true,
+		},
+		{
+			name: "429 response",
+			err: func(_ *testing.T) error {
+				return httpx.ClassifyError(nil, req, &http.Response{StatusCode: http.StatusTooManyRequests, Request: req})
+			},
+			want:      httpx.ErrRateLimited,
+			httpError: true,
+		},
+		{
+			name: "body exceeding size limit",
+			err: func(_ *testing.T) error {
+				return httpx.ClassifyError(&http.MaxBytesError{Limit: 1024}, req, nil)
+			},
+			want:      httpx.ErrTooLarge,
+			httpError: true,
+		},
+		{
+			name: "undecodable response body",
+			err: func(_ *testing.T) error {
+				return get(newClient(), "/invalid")
+			},
+			want: httpx.ErrDecode,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := fmt.Errorf("load user: %w", tt.err(t))
+
+			require.Error(t, err)
+			for _, sentinel := range sentinels {
+				assert.Equal(t, sentinel == tt.want, errors.Is(err, sentinel), "errors.Is(err, %v)", sentinel)
+			}
+			httpErr := &httpx.HTTPError{}
+			assert.Equal(t, tt.httpError, errors.As(err, &httpErr))
+		})
+	}
+
+	t.Run("decode errors keep the JSON cause", func(t *testing.T) {
+		err := get(newClient(), "/invalid")
+
+		syntaxErr := &json.SyntaxError{}
+		assert.ErrorAs(t, err, &syntaxErr)
+		assert.Contains(t, err.Error(), "failed to unmarshal response")
+	})
+
+	t.Run("timeouts expose the phase", func(t *testing.T) {
+		err := get(newClient(httpx.WithClientTimeout(20*time.Millisecond)), "/slow")
+
+		phaseErr := &httpx.TimeoutPhaseError{}
+		require.ErrorAs(t, err, &phaseErr)
+		assert.ErrorIs(t, phaseErr, httpx.ErrTimeout)
+	})
+}
+
+func TestHTTPErrorOperation(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+	client := httpx.NewClientWithConfig(httpx.WithClientDefaultBaseURL(server.URL))
+
+	t.Run("defaults to method and path", func(t *testing.T) {
+		_, err := client.Execute(*httpx.NewRequest(http.MethodGet, httpx.WithPath("/users", "42")), map[string]any{})
+
+		require.Error(t, err)
+		assert.Equal(t, "GET /users/42", httpx.GetOperation(err))
+	})
+
+	t.Run("uses the operation name", func(t *testing.T) {
+		_, err := client.Execute(*httpx.NewRequest(http.MethodGet,
+			httpx.WithPath("/users", "42"),
+			httpx.WithOperation("GET /users/{id}"),
+		), map[string]any{})
+
+		require.Error(t, err)
+		assert.Equal(t, "GET /users/{id}", httpx.GetOperation(err))
+	})
+
+	t.Run("is set on errors created before the request is sent", func(t *testing.T) {
+		_, err := client.Execute(*httpx.NewRequest(http.MethodGet,
+			httpx.WithPath("/users", "42"),
+			httpx.WithHeader("", "value"),
+		), map[string]any{})
+
+		require.Error(t, err)
+		assert.Equal(t, "GET /users/42", httpx.GetOperation(err))
+	})
+}
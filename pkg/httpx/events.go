@@ -0,0 +1,147 @@
+package httpx
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// defaultEventBuffer is the capacity of the event channel when WithClientEvents is given no buffer size
+const defaultEventBuffer = 64
+
+// Event is a structured notification of retry, circuit breaker or rate limit activity, received from Client.Events
+// It is one of RetryEvent, CircuitBreakerEvent or RateLimitEvent:
+//
+//	for event := range client.Events() {
+//		switch e := event.(type) {
+//		case httpx.RetryEvent:
+//			fmt.Printf("%s: attempt %d/%d, backing off %s\n", e.Op, e.Attempt, e.MaxAttempts, e.Delay)
+//		case httpx.CircuitBreakerEvent:
+//			fmt.Printf("circuit %s: %s -> %s\n", e.Name, e.From, e.To)
+//		}
+//	}
+type Event interface {
+	// EventTime returns when the event occurred
+	EventTime() time.Time
+}
+
+// RetryEvent is emitted before a request is retried
+type RetryEvent struct {
+	Time        time.Time
+	Op          string        // Operation of the request, see WithOperation
+	Attempt     int           // Attempt about to be made, starting at 2 for the first retry
+	MaxAttempts int           // Maximum number of attempts, including the initial request
+	Delay       time.Duration // Backoff before the attempt
+	StatusCode  int           // Status code of the failed attempt, 0 if it failed without a response
+	Err         error         // Error of the failed attempt, nil if it failed with a retryable status code
+}
+
+// EventTime implements Event
+func (e RetryEvent) EventTime() time.Time {
+	return e.Time
+}
+
+// CircuitBreakerEvent is emitted when a circuit breaker changes state or rejects a request
+type CircuitBreakerEvent struct {
+	Time     time.Time
+	Op       string // Operation of the request that observed the event
+	Name     string // Circuit breaker name
+	From     CircuitBreakerState
+	To       CircuitBreakerState
+	Rejected bool // Whether the request was rejected, From and To are then the current state
+}
+
+// EventTime implements Event
+func (e CircuitBreakerEvent) EventTime() time.Time {
+	return e.Time
+}
+
+// RateLimitEvent is emitted when a request waits for or is rejected by the rate limiter
+type RateLimitEvent struct {
+	Time       time.Time
+	Op         string        // Operation of the request
+	Host       string        // Host the limit applies to
+	Wait       time.Duration // Expected wait before the request is sent
+	StatusCode int           // 429 when the wait was requested by the server with Retry-After, 0 for client-side limits
+	Rejected   bool          // Whether the request was rejected instead of waiting
+}
+
+// EventTime implements Event
+func (e RateLimitEvent) EventTime() time.Time {
+	return e.Time
+}
+
+// eventSink delivers events to the client's channel without ever blocking the request
+type eventSink struct {
+	ch      chan Event
+	dropped atomic.Uint64
+}
+
+// newEventSink creates the event sink of a client, returning nil when events are disabled
+func newEventSink(config ClientConfig) *eventSink {
+	if config.EventBuffer <= 0 {
+		return nil
+	}
+	return &eventSink{ch: make(chan Event, config.EventBuffer)}
+}
+
+// emit delivers the event, dropping it when the channel is full
+func (s *eventSink) emit(event Event) {
+	if s == nil {
+		return
+	}
+	select {
+	case s.ch <- event:
+	default:
+		s.dropped.Add(1)
+	}
+}
+
+// events returns the event channel, nil when events are disabled
+func (s *eventSink) events() <-chan Event {
+	if s == nil {
+		return nil
+	}
+	return s.ch
+}
+
+// droppedEvents returns the number of events dropped because the channel was full
+func (s *eventSink) droppedEvents() uint64 {
+	if s == nil {
+		return 0
+	}
+	return s.dropped.Load()
+}
+
+// eventSinkKey is the context key of the event sink
+type eventSinkKey struct{}
+
+// contextWithEventSink returns a context carrying the sink middlewares emit events to
+func contextWithEventSink(ctx context.Context, sink *eventSink) context.Context {
+	if sink == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, eventSinkKey{}, sink)
+}
+
+// emitEvent sends the event to the sink of the request context, if any
+// Middlewares used outside a client with events enabled emit nothing
+func emitEvent(ctx context.Context, event Event) {
+	sink, _ := ctx.Value(eventSinkKey{}).(*eventSink)
+	sink.emit(event)
+}
+
+// eventsEnabled reports whether events emitted with ctx are delivered, so callers can skip building them
+func eventsEnabled(ctx context.Context) bool {
+	_, ok := ctx.Value(eventSinkKey{}).(*eventSink)
+	return ok
+}
+
+// statusCodeOf returns the status code of resp, 0 if there is no response
+func statusCodeOf(resp *http.Response) int {
+	if resp == nil {
+		return 0
+	}
+	return resp.StatusCode
+}
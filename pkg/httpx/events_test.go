@@ -0,0 +1,149 @@
+package httpx_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bdpiprava/easy-http/pkg/httpx"
+)
+
+// drainEvents returns the events buffered on the channel
+func drainEvents(events <-chan httpx.Event) []httpx.Event {
+	var drained []httpx.Event
+	for {
+		select {
+		case event := <-events:
+			drained = append(drained, event)
+		default:
+			return drained
+		}
+	}
+}
+
+func TestClientEvents(t *testing.T) {
+	t.Run("emits retry events", func(t *testing.T) {
+		var requests atomic.Int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			if requests.Add(1) < 3 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			_, _ = w.Write([]byte(`{}`))
+		}))
+		defer server.Close()
+
+		client := httpx.NewClientWithConfig(
+			httpx.WithClientDefaultBaseURL(server.URL),
+			httpx.WithClientRetryPolicy(httpx.RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, Strategy: httpx.RetryStrategyFixed}),
+			httpx.WithClientEvents(0),
+		)
+
+		_, err := client.Execute(*httpx.NewRequest(http.MethodGet, httpx.WithPath("/orders")), nil)
+		require.NoError(t, err)
+
+		events := drainEvents(client.Events())
+		require.Len(t, events, 2)
+		for i, event := range events {
+			retry, ok := event.(httpx.RetryEvent)
+			require.True(t, ok, "unexpected event %T", event)
+			assert.Equal(t, i+2, retry.Attempt)
+			assert.Equal(t, 3, retry.MaxAttempts)
+			assert.Equal(t, time.Millisecond, retry.Delay)
+			assert.Equal(t, http.StatusServiceUnavailable, retry.StatusCode)
+			assert.Equal(t, "GET /orders", retry.Op)
+			assert.False(t, retry.EventTime().IsZero())
+		}
+	})
+
+	t.Run("emits circuit breaker events", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusInternalServerError)
+		}))
+		defer server.Close()
+
+		client := httpx.NewClientWithConfig(
+			httpx.WithClientDefaultBaseURL(server.URL),
+			httpx.WithClientCircuitBreaker(httpx.CircuitBreakerConfig{
+				Name:        "orders",
+				Timeout:     time.Minute,
+				ReadyToTrip: func(counts httpx.Counts) bool { return counts.ConsecutiveFailures >= 1 },
+			}),
+			httpx.WithClientEvents(0),
+		)
+
+		_, _ = client.Execute(*httpx.NewRequest(http.MethodGet, httpx.WithPath("/orders")), nil)
+		_, err := client.Execute(*httpx.NewRequest(http.MethodGet, httpx.WithPath("/orders")), nil)
+		require.ErrorIs(t, err, httpx.ErrCircuitOpen)
+
+		events := drainEvents(client.Events())
+		require.Len(t, events, 2)
+		assert.Equal(t, httpx.CircuitBreakerEvent{
+			Time: events[0].EventTime(),
+			Op:   "GET /orders",
+			Name: "orders",
+			From: httpx.StateClosed,
+			To:   httpx.StateOpen,
+		}, events[0])
+		rejected, ok := events[1].(httpx.CircuitBreakerEvent)
+		require.True(t, ok)
+		assert.True(t, rejected.Rejected)
+		assert.Equal(t, httpx.StateOpen, rejected.To)
+	})
+
+	t.Run("emits rate limit events", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			_, _ = w.Write([]byte(`{}`))
+		}))
+		defer server.Close()
+
+		client := httpx.NewClientWithConfig(
+			httpx.WithClientDefaultBaseURL(server.URL),
+			httpx.WithClientRateLimit(httpx.RateLimitConfig{RequestsPerSec: 20, BurstSize: 1, WaitOnLimit: true, MaxWaitDuration: time.Second}),
+			httpx.WithClientEvents(0),
+		)
+
+		for range 2 {
+			_, err := client.Execute(*httpx.NewRequest(http.MethodGet), nil)
+			require.NoError(t, err)
+		}
+
+		events := drainEvents(client.Events())
+		require.Len(t, events, 1)
+		limited, ok := events[0].(httpx.RateLimitEvent)
+		require.True(t, ok)
+		assert.Positive(t, limited.Wait)
+		assert.LessOrEqual(t, limited.Wait, 50*time.Millisecond)
+		assert.False(t, limited.Rejected)
+	})
+
+	t.Run("drops events when the channel is full", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}))
+		defer server.Close()
+
+		client := httpx.NewClientWithConfig(
+			httpx.WithClientDefaultBaseURL(server.URL),
+			httpx.WithClientRetryPolicy(httpx.RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond}),
+			httpx.WithClientEvents(1),
+		)
+
+		_, _ = client.Execute(*httpx.NewRequest(http.MethodGet), nil)
+
+		assert.Len(t, drainEvents(client.Events()), 1)
+		assert.Equal(t, uint64(1), client.DroppedEvents())
+	})
+
+	t.Run("is disabled by default", func(t *testing.T) {
+		client := httpx.NewClientWithConfig()
+
+		assert.Nil(t, client.Events())
+		assert.Zero(t, client.DroppedEvents())
+	})
+}
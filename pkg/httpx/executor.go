@@ -1,10 +1,10 @@
 package httpx
 
 import (
+	"cmp"
 	"context"
 	"log/slog"
 	"net/http"
-	"path"
 	"strings"
 
 	"github.com/pkg/errors"
@@ -15,75 +15,216 @@ func execute(client *Client, request *Request, respType any) (*Response, error)
 	// Note: respType can be nil for requests that don't expect response bodies (e.g., HEAD)
 	// The validation is handled downstream in newResponse() where we have body content
 
-	// Build request options to check for streaming mode
+	// Always use middleware execution for clients with new config architecture
+	// This includes both new clients and old clients converted to new architecture
+	return executeWithMiddleware(client, request, client.requestOptions(request), respType)
+}
+
+// requestOptions builds the options of the request, merged with the client defaults
+func (c *Client) requestOptions(request *Request) RequestOptions {
 	// Use new config architecture if available, fall back to old for compatibility
-	var requestOpts RequestOptions
-	if client.config.Timeout != 0 || client.config.Logger != nil {
+	if c.config.Timeout != 0 || c.config.Logger != nil {
 		// Client was created with new architecture
-		requestOpts = buildOptsFromConfig(client.config, request)
-	} else {
-		// Client was created with old architecture
-		requestOpts = buildOpts(client.clientOptions, request)
+		return buildOptsFromConfig(c.config, request)
 	}
-
-	// Always use middleware execution for clients with new config architecture
-	// This includes both new clients and old clients converted to new architecture
-	return executeWithMiddleware(client, request, requestOpts, respType)
+	// Client was created with old architecture
+	return buildOpts(c.clientOptions, request)
 }
 
 // executeWithMiddleware executes the request using the new architecture with middleware support
 func executeWithMiddleware(client *Client, _ *Request, requestOpts RequestOptions, respType any) (*Response, error) {
+	exchange, err := sendWithMiddleware(client, requestOpts)
+	if err != nil {
+		return nil, err
+	}
+	req, resp := exchange.req, exchange.resp
+	if resp.Request == nil {
+		// Responses served by the cache middleware are not tied to a request
+		resp.Request = req
+	}
+	if !requestOpts.Streaming {
+		exchange.stopwatch.headersReceived()
+	}
+
+	response, err := newResponse(resp, respType, requestOpts, &client.config)
+	if err == nil && !response.IsStreaming {
+		err = interceptResponse(req.Context(), client.config.Interceptors, response)
+	}
+	if response != nil {
+		response.cacheStatus = exchange.cache.status
+	}
+	if response != nil && response.IsStreaming {
+		client.drainer.track(response, req)
+	}
+	if err != nil && GetTimeoutPhase(err) == TimeoutPhaseBodyRead {
+		err = annotateError(ClassifyError(err, req, nil), req, requestOpts)
+	}
+	client.slowRequests.report(exchange.ctx, req, exchange.stopwatch, resp.StatusCode, err)
+	client.metrics.completed(err)
+	return response, err
+}
+
+// do sends the request through the middleware chain and returns the response with its body unread
+func do(client *Client, request *Request) (*http.Response, error) {
+	exchange, err := sendWithMiddleware(client, client.requestOptions(request))
+	if err != nil {
+		return nil, err
+	}
+	client.slowRequests.report(exchange.ctx, exchange.req, exchange.stopwatch, exchange.resp.StatusCode, nil)
+	client.metrics.completed(nil)
+	return exchange.resp, nil
+}
+
+// exchange is a request sent through the middleware chain and its response, whose body is not read yet
+type exchange struct {
+	ctx       context.Context
+	req       *http.Request
+	resp      *http.Response
+	stopwatch *requestStopwatch
+	cache     *cacheOutcome // How the cache middleware answered the request
+}
+
+// sendWithMiddleware builds the request and sends it through the middleware chain, resending it in the
+// fallback serializations of the client when the server rejects the one of the request
+// Failures are classified, logged and counted, so callers return the error as is.
+func sendWithMiddleware(client *Client, requestOpts RequestOptions) (exchange, error) {
+	sent, err := sendOnce(client, requestOpts)
+	if err != nil {
+		return exchange{}, err
+	}
+	return sendWithSerializationFallback(client, requestOpts, sent)
+}
+
+// sendOnce builds the request and sends it through the middleware chain
+func sendOnce(client *Client, requestOpts RequestOptions) (exchange, error) {
+	requestOpts.Logger = client.requestLogger(requestOpts)
+
 	// Build the HTTP request
-	req, err := buildRequestFromConfig(requestOpts)
+	req, err := buildRequestFromConfig(requestOpts, &client.config)
 	if err != nil {
 		// Classify the error for better context
-		httpErr := ClassifyError(err, req, nil)
-		if client.config.Logger != nil {
-			logError(client.config.Logger, "Failed to build HTTP request", httpErr, req)
+		httpErr := annotateError(ClassifyError(err, req, nil), req, requestOpts)
+		if logger := cmp.Or(requestOpts.Logger, client.config.Logger); logger != nil {
+			logError(logger, "Failed to build HTTP request", httpErr, req)
 		}
-		return nil, httpErr
+		client.metrics.completed(httpErr)
+		return exchange{}, httpErr
 	}
 
-	// Create the final handler that performs the actual HTTP call
 	// Handle DisableCookies by using a temporary client without cookie jar
+	httpClient := client.client
+	if requestOpts.DisableCookies && client.client.Jar != nil {
+		// Create temporary client without cookie jar for this request
+		httpClient = &http.Client{
+			Timeout: client.client.Timeout,
+			// Copy other settings but omit Jar
+			CheckRedirect: client.client.CheckRedirect,
+			Transport:     client.client.Transport,
+		}
+	}
+
+	// Execute the middleware chain
+	req, stopwatch := client.slowRequests.start(req)
+	outcome := &cacheOutcome{}
+	req = req.WithContext(contextWithCacheOutcome(req.Context(), outcome))
+	req, stream := client.newResumableStream(req, httpClient.Do, requestOpts)
+	ctx := req.Context()
+	resp, err := client.send(req, httpClient.Do)
+	if err != nil {
+		// Classify and enhance the error with context
+		httpErr := annotateError(ClassifyError(err, req, resp), req, requestOpts)
+		// The response is not handed to the caller, so return its connection to the pool
+		client.drainer.release(resp)
+		stream.abort(httpErr)
+		client.slowRequests.report(ctx, req, stopwatch, 0, httpErr)
+		client.metrics.completed(httpErr)
+		return exchange{}, httpErr
+	}
+
+	stream.watch(resp)
+	if requestOpts.StreamTee != nil {
+		resp.Body = newTeeBody(resp.Body, requestOpts.StreamTee)
+	}
+	return exchange{ctx: ctx, req: req, resp: resp, stopwatch: stopwatch, cache: outcome}, nil
+}
+
+// send runs the request through the client and request middleware chain, performing the actual HTTP call with do
+func (c *Client) send(req *http.Request, do func(*http.Request) (*http.Response, error)) (*http.Response, error) {
+	do = c.capture.wrap(c.connPool.wrap(do))
+	if c.events != nil {
+		req = req.WithContext(contextWithEventSink(req.Context(), c.events))
+	}
+	if c.metrics != nil {
+		req = req.WithContext(contextWithClientMetrics(req.Context(), c.metrics))
+	}
+	if c.config.BaseContext != nil {
+		req = req.WithContext(contextWithBaseContext(req.Context(), c.config.BaseContext))
+	}
+	if c.config.FlagProvider != nil {
+		req = req.WithContext(contextWithFlagProvider(req.Context(), c.config.FlagProvider))
+	}
 	finalHandler := func(_ context.Context, httpReq *http.Request) (*http.Response, error) {
-		if requestOpts.DisableCookies && client.client.Jar != nil {
-			// Create temporary client without cookie jar for this request
-			tempClient := &http.Client{
-				Timeout: client.client.Timeout,
-				// Copy other settings but omit Jar
-				CheckRedirect: client.client.CheckRedirect,
-				Transport:     client.client.Transport,
+		// Middlewares may have added headers the request asked not to send
+		removeHeaders(httpReq.Header, removedHeadersFromContext(httpReq.Context()))
+		if err := checkNetworkGuard(httpReq); err != nil {
+			return nil, err
+		}
+		c.metrics.attempted()
+
+		send := do
+		if c.config.BodyReadTimeout > 0 {
+			send = func(r *http.Request) (*http.Response, error) {
+				return doWithBodyReadTimeout(do, r, c.config.BodyReadTimeout)
 			}
-			return tempClient.Do(httpReq)
 		}
-		return client.client.Do(httpReq)
+		if c.config.DisableStaleConnectionRetry {
+			return send(httpReq)
+		}
+		return doWithStaleConnectionRetry(send, httpReq)
 	}
 
 	// Create middleware chain
 	chain := NewMiddlewareChain(finalHandler)
-	for _, middleware := range client.config.Middlewares {
+	for _, middleware := range c.config.Middlewares {
 		chain.Add(middleware)
 	}
-
-	// Execute the middleware chain
-	ctx := req.Context()
-	resp, err := chain.Execute(ctx, req)
-	if err != nil {
-		// Classify and enhance the error with context
-		httpErr := ClassifyError(err, req, resp)
-		return nil, httpErr
+	for _, middleware := range requestMiddlewaresFromContext(req.Context()) {
+		chain.Add(middleware)
 	}
+	return chain.Execute(req.Context(), req)
+}
+
+// baseContextKey is the context key of the base context of the client
+type baseContextKey struct{}
 
-	return newResponse(resp, respType, requestOpts.Streaming)
+// contextWithBaseContext returns a context carrying the base context background work of middlewares derives from
+func contextWithBaseContext(ctx, base context.Context) context.Context {
+	return context.WithValue(ctx, baseContextKey{}, base)
 }
 
-// buildRequestFromConfig builds an HTTP request using the new configuration architecture
-func buildRequestFromConfig(opts RequestOptions) (*http.Request, error) {
+// baseContextFromContext returns the base context of the client carried by ctx, nil if there is none
+func baseContextFromContext(ctx context.Context) context.Context {
+	base, _ := ctx.Value(baseContextKey{}).(context.Context)
+	return base
+}
+
+// buildRequestFromConfig builds an HTTP request using the new configuration architecture, marshaling JSON bodies
+// with the client codec and applying its request transformers
+func buildRequestFromConfig(opts RequestOptions, config *ClientConfig) (*http.Request, error) {
 	// Check for errors that occurred during option processing
 	if opts.Error != nil {
 		return nil, opts.Error
 	}
+	if err := interceptRequest(config.Interceptors, &opts); err != nil {
+		return nil, err
+	}
+	if err := marshalJSONBody(&opts, config.JSONCodec); err != nil {
+		return nil, err
+	}
+	if err := marshalEncodedBody(&opts, config.JSONCodec); err != nil {
+		return nil, err
+	}
+	encodeMultipartBody(&opts)
 
 	if _, ok := supportedMethods[strings.ToUpper(opts.Method)]; !ok {
 		return nil, errors.Errorf("unsupported method: %s", opts.Method)
@@ -93,14 +234,32 @@ func buildRequestFromConfig(opts RequestOptions) (*http.Request, error) {
 	if ctx == nil {
 		ctx = context.Background()
 	}
+	ctx = ContextWithAnnotations(ctx, opts.Annotations)
+	ctx = ContextWithOperation(ctx, opts.Operation)
+	ctx = contextWithRemovedHeaders(ctx, opts.RemovedHeaders)
+	ctx = contextWithLogger(ctx, opts.Logger)
+	ctx = ContextWithAffinityKey(ctx, opts.AffinityKey)
+	ctx = contextWithRequestCacheOptions(ctx, opts.Cache)
+	ctx = contextWithInformationalHandler(ctx, opts.InformationalHandler)
+	ctx = contextWithURLCanonicalizer(ctx, config.URLCanonicalizer)
+	ctx = contextWithRequestMiddlewares(ctx, opts.Middlewares)
 	req, err := http.NewRequestWithContext(ctx, opts.Method, opts.BaseURL, opts.Body)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to create request")
 	}
 
-	req.URL.Path = path.Join(req.URL.Path, opts.Path)
+	template, err := applyPath(req.URL, opts.Path, opts.PathParams)
+	if err != nil {
+		return nil, err
+	}
+	if template != "" {
+		req = req.WithContext(ContextWithPathTemplate(req.Context(), template))
+	}
 	req.Header = opts.Headers
+	removeHeaders(req.Header, opts.RemovedHeaders)
 	req.URL.RawQuery = opts.QueryParams.Encode()
+	applyAPIVersion(req, opts.APIVersion, config.APIVersioning)
+	canonicalizeRequest(req)
 
 	// Apply basic auth if specified
 	if opts.BasicAuth.Username != "" || opts.BasicAuth.Password != "" {
@@ -112,9 +271,25 @@ func buildRequestFromConfig(opts RequestOptions) (*http.Request, error) {
 		req.AddCookie(cookie)
 	}
 
+	if err := transformRequest(config.RequestTransformers, req); err != nil {
+		return nil, err
+	}
 	return req, nil
 }
 
+// requestLogger returns the logger set with WithRequestLogger, else the one extracted from the request context,
+// or nil to use the client logger
+func (c *Client) requestLogger(opts RequestOptions) *slog.Logger {
+	if opts.Logger != nil || c.config.ContextLogger == nil {
+		return mapLogFields(opts.Logger, c.config.LogFieldMapper)
+	}
+	ctx := opts.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return mapLogFields(c.config.ContextLogger(ctx), c.config.LogFieldMapper)
+}
+
 // logError logs errors with structured logging and request context
 func logError(logger *slog.Logger, message string, err error, req *http.Request) {
 	if logger == nil {
@@ -122,15 +297,21 @@ func logError(logger *slog.Logger, message string, err error, req *http.Request)
 	}
 
 	attrs := []slog.Attr{
-		slog.String("error", err.Error()),
+		slog.String(LogFieldError, err.Error()),
 	}
 
 	if req != nil {
 		attrs = append(attrs,
-			slog.String("method", req.Method),
-			slog.String("url", req.URL.String()),
+			slog.String(LogFieldMethod, req.Method),
+			slog.String(LogFieldURL, req.URL.String()),
 		)
 	}
+	if op := GetOperation(err); op != "" {
+		attrs = append(attrs, slog.String(LogFieldOperation, op))
+	}
+	if attr, ok := annotationLogAttr(GetAnnotations(err)); ok {
+		attrs = append(attrs, attr)
+	}
 
 	logger.LogAttrs(context.Background(), slog.LevelError, message, attrs...)
 }
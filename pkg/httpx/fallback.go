@@ -0,0 +1,70 @@
+package httpx
+
+import (
+	"net/http"
+	"slices"
+)
+
+// serializationFallbacks returns the media types the request is resent with when the server rejects its
+// serialization, those of the request overriding those of the client
+func serializationFallbacks(opts RequestOptions, config *ClientConfig) []string {
+	if opts.FallbackMediaTypes != nil {
+		return opts.FallbackMediaTypes
+	}
+	return config.FallbackMediaTypes
+}
+
+// rejectsSerialization reports whether resp rejects the media type of the request body or of the response the
+// request accepts
+func rejectsSerialization(resp *http.Response) bool {
+	return resp.StatusCode == http.StatusUnsupportedMediaType || resp.StatusCode == http.StatusNotAcceptable
+}
+
+// withSerialization returns the options of the request with its payload encoded as mediaType, also accepted as
+// the media type of the response, or false if the body of the request is not a payload set by WithJSONBody or
+// WithEncodedBody, or is already encoded as mediaType
+func withSerialization(opts RequestOptions, mediaType string) (RequestOptions, bool) {
+	var payload any
+	var current string
+	switch body := opts.Body.(type) {
+	case *jsonBody:
+		payload, current = body.value, "application/json"
+	case *encodedBody:
+		payload, current = body.value, body.mediaType
+	default:
+		return opts, false
+	}
+	if normalizeMediaType(current) == normalizeMediaType(mediaType) {
+		return opts, false
+	}
+
+	// Options are shared with the previous attempt, so the headers are replaced in a copy
+	opts.Body = &encodedBody{mediaType: mediaType, value: payload}
+	opts.Headers = opts.Headers.Clone()
+	if opts.Headers == nil {
+		opts.Headers = make(http.Header)
+	}
+	opts.Headers.Set("Content-Type", mediaType)
+	opts.Headers.Set("Accept", mediaType)
+	return opts, true
+}
+
+// sendWithSerializationFallback resends a request whose serialization the server rejects with 415 Unsupported
+// Media Type or 406 Not Acceptable once per fallback media type, in order, until one is not rejected
+func sendWithSerializationFallback(client *Client, requestOpts RequestOptions, sent exchange) (exchange, error) {
+	fallbacks := slices.Clone(serializationFallbacks(requestOpts, &client.config))
+	for len(fallbacks) > 0 && rejectsSerialization(sent.resp) {
+		opts, ok := withSerialization(requestOpts, fallbacks[0])
+		fallbacks = fallbacks[1:]
+		if !ok {
+			continue
+		}
+		drainAndClose(sent.resp.Body, defaultDrainLimit)
+		next, err := sendOnce(client, opts)
+		if err != nil {
+			return exchange{}, err
+		}
+		sent = next
+	}
+	return sent, nil
+}
@@ -0,0 +1,89 @@
+package httpx_test
+
+import (
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bdpiprava/easy-http/pkg/httpx"
+)
+
+type shipment struct {
+	XMLName xml.Name `xml:"shipment" json:"-"`
+	ID      string   `xml:"id" json:"id"`
+}
+
+func TestSerializationFallback(t *testing.T) {
+	t.Parallel()
+
+	// The partner accepts and produces XML only
+	var contentTypes []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		contentTypes = append(contentTypes, r.Header.Get("Content-Type"))
+		if r.URL.Path == "/accept" && r.Header.Get("Accept") != "application/xml" {
+			w.WriteHeader(http.StatusNotAcceptable)
+			return
+		}
+		var received shipment
+		if r.Header.Get("Content-Type") != "application/xml" || xml.NewDecoder(r.Body).Decode(&received) != nil {
+			w.WriteHeader(http.StatusUnsupportedMediaType)
+			return
+		}
+		w.Header().Set("Content-Type", "application/xml")
+		_ = xml.NewEncoder(w).Encode(shipment{ID: received.ID + "-accepted"})
+	}))
+	defer server.Close()
+	client := httpx.NewClientWithConfig(
+		httpx.WithClientDefaultBaseURL(server.URL),
+		httpx.WithClientSerializationFallback("application/json", "application/xml"),
+	)
+	post := func(path string, opts ...httpx.RequestOption) (*httpx.Response, error) {
+		contentTypes = nil
+		return client.Execute(*httpx.NewRequest(http.MethodPost, append([]httpx.RequestOption{httpx.WithPath(path)}, opts...)...), shipment{})
+	}
+
+	t.Run("resends rejected payloads in the fallback media types", func(t *testing.T) {
+		resp, err := post("/shipments", httpx.WithJSONBody(shipment{ID: "s1"}))
+
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "s1-accepted", resp.Body.(shipment).ID)
+		assert.Equal(t, []string{"application/json", "application/xml"}, contentTypes, "the media type of the payload is not retried")
+	})
+
+	t.Run("accepts the fallback media type", func(t *testing.T) {
+		resp, err := post("/accept", httpx.WithJSONBody(shipment{ID: "s2"}))
+
+		require.NoError(t, err)
+		assert.Equal(t, "s2-accepted", resp.Body.(shipment).ID)
+	})
+
+	t.Run("returns the rejection once fallbacks are exhausted", func(t *testing.T) {
+		resp, err := post("/shipments", httpx.WithJSONBody(shipment{ID: "s3"}), httpx.WithSerializationFallback("text/xml"))
+
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusUnsupportedMediaType, resp.StatusCode)
+		assert.Equal(t, []string{"application/json", "text/xml"}, contentTypes)
+	})
+
+	t.Run("is disabled per request", func(t *testing.T) {
+		resp, err := post("/shipments", httpx.WithJSONBody(shipment{ID: "s4"}), httpx.WithSerializationFallback())
+
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusUnsupportedMediaType, resp.StatusCode)
+		assert.Len(t, contentTypes, 1)
+	})
+
+	t.Run("does not resend raw bodies", func(t *testing.T) {
+		resp, err := post("/shipments", httpx.WithBody(strings.NewReader(`{"id":"s5"}`)), httpx.WithHeader("Content-Type", "application/json"))
+
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusUnsupportedMediaType, resp.StatusCode)
+		assert.Len(t, contentTypes, 1)
+	})
+}
@@ -0,0 +1,66 @@
+package httpx
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+)
+
+// TLSHandshaker performs the TLS handshake over a dialed connection in place of crypto/tls, giving control over
+// the ClientHello (cipher suite and extension order, ALPN...) for endpoints that block the default Go TLS
+// fingerprint. config holds the client TLS configuration with ServerName set.
+// HTTP/2 is only negotiated over *tls.Conn connections, handshakers returning other connections must offer
+// http/1.1 alone in ALPN. Build with the utls tag for UTLSHandshaker, which mimics common browsers.
+type TLSHandshaker func(ctx context.Context, conn net.Conn, config *tls.Config) (net.Conn, error)
+
+// WithClientTLSHandshaker replaces the TLS handshake of the client's connections, except those tunneled through
+// an HTTP proxy, whose TLS handshake is performed by the transport
+func WithClientTLSHandshaker(handshaker TLSHandshaker) ClientConfigOption {
+	return func(c *ClientConfig) {
+		c.TLSHandshaker = handshaker
+	}
+}
+
+// configureTLSHandshaker makes the transport dial TLS connections with handshaker
+// It must be called once the transport dialer and TLS configuration are final.
+func configureTLSHandshaker(transport *http.Transport, handshaker TLSHandshaker) {
+	dial := transport.DialContext
+	if dial == nil {
+		dial = (&net.Dialer{}).DialContext
+	}
+	transport.DialTLSContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := dial(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		config := &tls.Config{MinVersion: tls.VersionTLS12}
+		if transport.TLSClientConfig != nil {
+			config = transport.TLSClientConfig.Clone()
+		}
+		if config.ServerName == "" {
+			host, _, splitErr := net.SplitHostPort(addr)
+			if splitErr != nil {
+				host = addr
+			}
+			config.ServerName = host
+		}
+
+		// The transport applies its handshake timeout to its own handshakes only
+		handshakeCtx := ctx
+		if transport.TLSHandshakeTimeout > 0 {
+			var cancel context.CancelFunc
+			handshakeCtx, cancel = context.WithTimeout(ctx, transport.TLSHandshakeTimeout)
+			defer cancel()
+		}
+		tlsConn, err := handshaker(handshakeCtx, conn, config)
+		if err != nil {
+			_ = conn.Close()
+			if ctx.Err() == nil && handshakeCtx.Err() != nil {
+				return nil, &TimeoutPhaseError{Phase: TimeoutPhaseTLSHandshake, Limit: transport.TLSHandshakeTimeout, Cause: err}
+			}
+			return nil, err
+		}
+		return tlsConn, nil
+	}
+}
@@ -0,0 +1,81 @@
+package httpx_test
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bdpiprava/easy-http/pkg/httpx"
+)
+
+func TestTLSHandshaker(t *testing.T) {
+	var protocol string
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		protocol = r.Proto
+	}))
+	server.EnableHTTP2 = true
+	server.StartTLS()
+	defer server.Close()
+	roots := x509.NewCertPool()
+	roots.AddCert(server.Certificate())
+
+	t.Run("should perform the handshake with the configured handshaker", func(t *testing.T) {
+		var serverName string
+		client := httpx.NewClientWithConfig(
+			httpx.WithClientDefaultBaseURL(server.URL),
+			httpx.WithClientTLSConfig(&tls.Config{RootCAs: roots, MinVersion: tls.VersionTLS12}),
+			httpx.WithClientTLSHandshaker(func(ctx context.Context, conn net.Conn, config *tls.Config) (net.Conn, error) {
+				serverName = config.ServerName
+				config.NextProtos = []string{"http/1.1"}
+				tlsConn := tls.Client(conn, config)
+				return tlsConn, tlsConn.HandshakeContext(ctx)
+			}),
+		)
+
+		resp, err := client.Execute(*httpx.NewRequest(http.MethodGet), "")
+
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "127.0.0.1", serverName)
+		assert.Equal(t, "HTTP/1.1", protocol)
+	})
+
+	t.Run("should fail requests when the handshake fails", func(t *testing.T) {
+		errRejected := errors.New("fingerprint rejected")
+		client := httpx.NewClientWithConfig(
+			httpx.WithClientDefaultBaseURL(server.URL),
+			httpx.WithClientTLSHandshaker(func(context.Context, net.Conn, *tls.Config) (net.Conn, error) {
+				return nil, errRejected
+			}),
+		)
+
+		_, err := client.Execute(*httpx.NewRequest(http.MethodGet), "")
+
+		assert.ErrorIs(t, err, errRejected)
+	})
+
+	t.Run("should apply the TLS handshake timeout", func(t *testing.T) {
+		client := httpx.NewClientWithConfig(
+			httpx.WithClientDefaultBaseURL(server.URL),
+			httpx.WithClientTLSHandshakeTimeout(20*time.Millisecond),
+			httpx.WithClientTLSHandshaker(func(ctx context.Context, _ net.Conn, _ *tls.Config) (net.Conn, error) {
+				<-ctx.Done()
+				return nil, ctx.Err()
+			}),
+		)
+
+		_, err := client.Execute(*httpx.NewRequest(http.MethodGet), "")
+
+		require.ErrorIs(t, err, httpx.ErrTimeout)
+		assert.Equal(t, httpx.TimeoutPhaseTLSHandshake, httpx.GetTimeoutPhase(err))
+	})
+}
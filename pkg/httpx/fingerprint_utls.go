@@ -0,0 +1,60 @@
+//go:build utls
+
+package httpx
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+
+	"github.com/pkg/errors"
+	utls "github.com/refraction-networking/utls"
+)
+
+// UTLSHandshaker returns a TLS handshaker sending the ClientHello of hello, e.g. utls.HelloChrome_Auto or
+// utls.HelloFirefox_Auto, with github.com/refraction-networking/utls. ALPN is restricted to http/1.1 since the
+// transport cannot serve HTTP/2 over utls connections.
+func UTLSHandshaker(hello utls.ClientHelloID) TLSHandshaker {
+	return func(ctx context.Context, conn net.Conn, config *tls.Config) (net.Conn, error) {
+		spec, err := utls.UTLSIdToSpec(hello)
+		if err != nil {
+			return nil, errors.Wrapf(err, "unsupported ClientHello %s", hello.Str())
+		}
+		for _, extension := range spec.Extensions {
+			if alpn, ok := extension.(*utls.ALPNExtension); ok {
+				alpn.AlpnProtocols = []string{"http/1.1"}
+			}
+		}
+
+		uconn := utls.UClient(conn, utlsConfig(config), utls.HelloCustom)
+		if err := uconn.ApplyPreset(&spec); err != nil {
+			return nil, errors.Wrapf(err, "failed to apply ClientHello %s", hello.Str())
+		}
+		if err := uconn.HandshakeContext(ctx); err != nil {
+			return nil, err
+		}
+		return uconn, nil
+	}
+}
+
+// utlsConfig converts the settings of config supported by utls
+func utlsConfig(config *tls.Config) *utls.Config {
+	converted := &utls.Config{
+		ServerName:            config.ServerName,
+		RootCAs:               config.RootCAs,
+		InsecureSkipVerify:    config.InsecureSkipVerify,
+		MinVersion:            config.MinVersion,
+		MaxVersion:            config.MaxVersion,
+		VerifyPeerCertificate: config.VerifyPeerCertificate,
+	}
+	for _, certificate := range config.Certificates {
+		converted.Certificates = append(converted.Certificates, utls.Certificate{
+			Certificate:                 certificate.Certificate,
+			PrivateKey:                  certificate.PrivateKey,
+			OCSPStaple:                  certificate.OCSPStaple,
+			SignedCertificateTimestamps: certificate.SignedCertificateTimestamps,
+			Leaf:                        certificate.Leaf,
+		})
+	}
+	return converted
+}
@@ -0,0 +1,41 @@
+//go:build utls
+
+package httpx_test
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	utls "github.com/refraction-networking/utls"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bdpiprava/easy-http/pkg/httpx"
+)
+
+func TestUTLSHandshaker(t *testing.T) {
+	var protocol string
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		protocol = r.Proto
+	}))
+	server.EnableHTTP2 = true
+	server.StartTLS()
+	defer server.Close()
+	roots := x509.NewCertPool()
+	roots.AddCert(server.Certificate())
+
+	client := httpx.NewClientWithConfig(
+		httpx.WithClientDefaultBaseURL(server.URL),
+		httpx.WithClientTLSConfig(&tls.Config{RootCAs: roots, MinVersion: tls.VersionTLS12}),
+		httpx.WithClientTLSHandshaker(httpx.UTLSHandshaker(utls.HelloChrome_Auto)),
+	)
+
+	resp, err := client.Execute(*httpx.NewRequest(http.MethodGet), "")
+
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "HTTP/1.1", protocol)
+}
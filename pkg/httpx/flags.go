@@ -0,0 +1,131 @@
+package httpx
+
+import (
+	"cmp"
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// defaultFlagTimeout bounds how long a request waits on the flag provider
+const defaultFlagTimeout = 50 * time.Millisecond
+
+// FlagProvider evaluates feature flags, e.g. backed by LaunchDarkly, Unleash or a config service
+type FlagProvider interface {
+	// Enabled reports whether flag is on for requests to host, or an error when the flag cannot be evaluated
+	Enabled(ctx context.Context, flag, host string) (bool, error)
+}
+
+// FlagProviderFunc adapts a function to the FlagProvider interface
+type FlagProviderFunc func(ctx context.Context, flag, host string) (bool, error)
+
+// Enabled implements FlagProvider
+func (f FlagProviderFunc) Enabled(ctx context.Context, flag, host string) (bool, error) {
+	return f(ctx, flag, host)
+}
+
+// ToggleConfig configures a middleware switched on and off at runtime by a feature flag
+type ToggleConfig struct {
+	Flag     string        // Flag name (default: the middleware name)
+	Provider FlagProvider  // Evaluates the flag (default: the FlagProvider of the client sending the request)
+	Default  bool          // Whether the middleware runs when the flag was never evaluated successfully for the host
+	Timeout  time.Duration // Maximum time a request waits on the provider (default: 50ms)
+}
+
+// ToggledMiddleware runs a middleware only while its feature flag is enabled for the request host
+//
+// The flag is evaluated on every request, so toggling it takes effect without a redeploy. When the provider
+// fails or exceeds the timeout, the value it last returned for the host is used, or ToggleConfig.Default if it
+// never answered, so an unavailable provider never flips the middleware to an unexpected state.
+type ToggledMiddleware struct {
+	middleware Middleware
+	config     ToggleConfig
+
+	mu   sync.Mutex
+	last map[string]bool // Last value returned by the provider per host
+}
+
+// NewToggledMiddleware wraps middleware so it only runs while config.Flag is enabled
+func NewToggledMiddleware(middleware Middleware, config ToggleConfig) *ToggledMiddleware {
+	config.Flag = cmp.Or(config.Flag, middleware.Name())
+	if config.Timeout <= 0 {
+		config.Timeout = defaultFlagTimeout
+	}
+	return &ToggledMiddleware{
+		middleware: middleware,
+		config:     config,
+		last:       make(map[string]bool),
+	}
+}
+
+// Name returns the name of the wrapped middleware
+func (m *ToggledMiddleware) Name() string {
+	return m.middleware.Name()
+}
+
+// Middleware returns the wrapped middleware
+func (m *ToggledMiddleware) Middleware() Middleware {
+	return m.middleware
+}
+
+// Execute implements the Middleware interface
+func (m *ToggledMiddleware) Execute(ctx context.Context, req *http.Request, next MiddlewareFunc) (*http.Response, error) {
+	if !m.enabled(ctx, req.URL.Hostname()) {
+		return next(ctx, req)
+	}
+	return m.middleware.Execute(ctx, req, next)
+}
+
+// enabled evaluates the flag for host, falling back to its last known value or the default
+func (m *ToggledMiddleware) enabled(ctx context.Context, host string) bool {
+	provider := m.config.Provider
+	if provider == nil {
+		provider, _ = ctx.Value(flagProviderKey{}).(FlagProvider)
+	}
+	if provider != nil {
+		flagCtx, cancel := context.WithTimeout(ctx, m.config.Timeout)
+		enabled, err := m.evaluate(flagCtx, provider, host)
+		cancel()
+		if err == nil {
+			m.mu.Lock()
+			m.last[host] = enabled
+			m.mu.Unlock()
+			return enabled
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if enabled, ok := m.last[host]; ok {
+		return enabled
+	}
+	return m.config.Default
+}
+
+// evaluate asks provider for the flag, giving up when ctx is done even if the provider ignores it
+func (m *ToggledMiddleware) evaluate(ctx context.Context, provider FlagProvider, host string) (bool, error) {
+	type result struct {
+		enabled bool
+		err     error
+	}
+	done := make(chan result, 1)
+	go func() {
+		enabled, err := provider.Enabled(ctx, m.config.Flag, host)
+		done <- result{enabled: enabled, err: err}
+	}()
+	select {
+	case r := <-done:
+		return r.enabled, r.err
+	case <-ctx.Done():
+		return false, ctx.Err()
+	}
+}
+
+// flagProviderKey is the context key of the flag provider of the client
+type flagProviderKey struct{}
+
+// contextWithFlagProvider returns a context carrying the provider evaluating the flags of toggled middlewares
+func contextWithFlagProvider(ctx context.Context, provider FlagProvider) context.Context {
+	return context.WithValue(ctx, flagProviderKey{}, provider)
+}
@@ -0,0 +1,103 @@
+package httpx_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bdpiprava/easy-http/pkg/httpx"
+)
+
+func TestToggledMiddleware(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.Header.Get("X-Verbose")))
+	}))
+	defer server.Close()
+	localhostURL := strings.Replace(server.URL, "127.0.0.1", "localhost", 1)
+
+	get := func(t *testing.T, client *httpx.Client, baseURL string) string {
+		t.Helper()
+		resp, err := client.Execute(*httpx.NewRequest(http.MethodGet, httpx.WithBaseURL(baseURL)), "")
+		require.NoError(t, err)
+		return resp.Body.(string)
+	}
+	verbose := &addHeaderMiddleware{key: "X-Verbose", value: "on"}
+
+	t.Run("should run the middleware per host while its flag is enabled", func(t *testing.T) {
+		var enabled atomic.Bool
+		provider := httpx.FlagProviderFunc(func(_ context.Context, flag, host string) (bool, error) {
+			assert.Equal(t, "verbose-logging", flag)
+			return host == "localhost" && enabled.Load(), nil
+		})
+		client := httpx.NewClientWithConfig(
+			httpx.WithClientFlagProvider(provider),
+			httpx.WithClientToggledMiddleware(verbose, httpx.ToggleConfig{Flag: "verbose-logging"}),
+		)
+
+		assert.Empty(t, get(t, client, localhostURL))
+		enabled.Store(true)
+		assert.Equal(t, "on", get(t, client, localhostURL))
+		assert.Empty(t, get(t, client, server.URL))
+	})
+
+	t.Run("should evaluate a shared middleware with the provider of the client sending the request", func(t *testing.T) {
+		toggled := httpx.NewToggledMiddleware(verbose, httpx.ToggleConfig{})
+		on := httpx.FlagProviderFunc(func(context.Context, string, string) (bool, error) { return true, nil })
+		off := httpx.FlagProviderFunc(func(context.Context, string, string) (bool, error) { return false, nil })
+		enabled := httpx.NewClientWithConfig(httpx.WithClientFlagProvider(on), httpx.WithClientMiddleware(toggled))
+		disabled := httpx.NewClientWithConfig(httpx.WithClientFlagProvider(off), httpx.WithClientMiddleware(toggled))
+
+		assert.Equal(t, "on", get(t, enabled, server.URL))
+		assert.Empty(t, get(t, disabled, server.URL))
+	})
+
+	t.Run("should keep the last value when the provider fails", func(t *testing.T) {
+		var failing atomic.Bool
+		provider := httpx.FlagProviderFunc(func(context.Context, string, string) (bool, error) {
+			if failing.Load() {
+				return false, errors.New("flag service unavailable")
+			}
+			return true, nil
+		})
+		client := httpx.NewClientWithConfig(
+			httpx.WithClientToggledMiddleware(verbose, httpx.ToggleConfig{Provider: provider}),
+		)
+
+		assert.Equal(t, "on", get(t, client, server.URL))
+		failing.Store(true)
+		assert.Equal(t, "on", get(t, client, server.URL))
+		assert.Empty(t, get(t, client, localhostURL), "hosts never evaluated use the default")
+	})
+
+	t.Run("should use the default when the provider does not answer in time", func(t *testing.T) {
+		release := make(chan struct{})
+		defer close(release)
+		provider := httpx.FlagProviderFunc(func(context.Context, string, string) (bool, error) {
+			<-release
+			return false, nil
+		})
+		client := httpx.NewClientWithConfig(
+			httpx.WithClientToggledMiddleware(verbose, httpx.ToggleConfig{Provider: provider, Default: true, Timeout: 10 * time.Millisecond}),
+		)
+
+		start := time.Now()
+		assert.Equal(t, "on", get(t, client, server.URL))
+		assert.Less(t, time.Since(start), time.Second)
+	})
+
+	t.Run("should use the default without a provider", func(t *testing.T) {
+		client := httpx.NewClientWithConfig(
+			httpx.WithClientToggledMiddleware(verbose, httpx.ToggleConfig{Default: true}),
+		)
+
+		assert.Equal(t, "on", get(t, client, server.URL))
+	})
+}
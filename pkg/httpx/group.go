@@ -0,0 +1,209 @@
+package httpx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// GroupOption configures a RequestGroup
+type GroupOption func(*RequestGroup)
+
+// WithGroupLimit bounds the number of requests running concurrently (default: unbounded)
+func WithGroupLimit(limit int) GroupOption {
+	return func(g *RequestGroup) {
+		if limit > 0 {
+			g.sem = make(chan struct{}, limit)
+		}
+	}
+}
+
+// WithGroupContinueOnError keeps running the remaining requests after a failure instead of cancelling them
+func WithGroupContinueOnError() GroupOption {
+	return func(g *RequestGroup) {
+		g.continueOnError = true
+	}
+}
+
+// RequestGroup runs client calls concurrently with shared cancellation and aggregated errors
+//
+// By default the first failure cancels the group context, which aborts the in-flight and pending
+// requests; their cancellation errors are not reported. Non-2xx responses count as failures.
+type RequestGroup struct {
+	client          *Client
+	ctx             context.Context
+	cancel          context.CancelCauseFunc
+	sem             chan struct{}
+	continueOnError bool
+
+	wg     sync.WaitGroup
+	mu     sync.Mutex
+	next   int
+	errors []*GroupCallError
+}
+
+// Group creates a RequestGroup whose requests use client and are cancelled when ctx is done
+// A nil client uses the package default client
+func Group(ctx context.Context, client *Client, opts ...GroupOption) *RequestGroup {
+	if client == nil {
+		client = defaultClient
+	}
+	groupCtx, cancel := context.WithCancelCause(ctx)
+	g := &RequestGroup{
+		client: client,
+		ctx:    groupCtx,
+		cancel: cancel,
+	}
+	for _, opt := range opts {
+		opt(g)
+	}
+	return g
+}
+
+// Context returns the group context, which is cancelled on the first failure or when Wait returns
+func (g *RequestGroup) Context() context.Context {
+	return g.ctx
+}
+
+// Go executes the request in a new goroutine and decodes the response body into target
+// target must be a non-nil pointer; its element type is used as the response type
+func (g *RequestGroup) Go(req Request, target any) {
+	index := g.reserve()
+
+	targetValue := reflect.ValueOf(target)
+	if target == nil || targetValue.Kind() != reflect.Pointer || targetValue.IsNil() {
+		g.record(index, ValidationError(fmt.Sprintf("target must be a non-nil pointer, got %T", target), nil))
+		return
+	}
+
+	g.start(index, func(ctx context.Context) error {
+		resp, err := g.client.Execute(*req.Clone(WithContext(ctx)), targetValue.Elem().Interface())
+		if err != nil {
+			return err
+		}
+		if resp.StatusCode >= 400 {
+			return ClassifyError(nil, resp.httpResponse.Request, resp.httpResponse)
+		}
+		if resp.Body != nil {
+			targetValue.Elem().Set(reflect.ValueOf(resp.Body))
+		}
+		return nil
+	})
+}
+
+// GoFunc runs fn in a new goroutine with the group context, for calls that need custom handling
+func (g *RequestGroup) GoFunc(fn func(ctx context.Context) error) {
+	g.start(g.reserve(), fn)
+}
+
+// Wait blocks until all calls complete and returns a *GroupError if any of them failed
+func (g *RequestGroup) Wait() error {
+	g.wg.Wait()
+	g.cancel(nil)
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if len(g.errors) == 0 {
+		return nil
+	}
+	errs := slices.Clone(g.errors)
+	slices.SortFunc(errs, func(a, b *GroupCallError) int { return a.Index - b.Index })
+	return &GroupError{Errors: errs}
+}
+
+// reserve allocates the index of the next call
+func (g *RequestGroup) reserve() int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	index := g.next
+	g.next++
+	return index
+}
+
+// start runs fn once a concurrency slot is available
+func (g *RequestGroup) start(index int, fn func(ctx context.Context) error) {
+	g.wg.Add(1)
+	go func() {
+		defer g.wg.Done()
+
+		if g.sem != nil {
+			select {
+			case g.sem <- struct{}{}:
+				defer func() { <-g.sem }()
+			case <-g.ctx.Done():
+				g.record(index, context.Cause(g.ctx))
+				return
+			}
+		}
+		if g.ctx.Err() != nil {
+			g.record(index, context.Cause(g.ctx))
+			return
+		}
+
+		if err := fn(g.ctx); err != nil {
+			g.record(index, err)
+		}
+	}()
+}
+
+// record stores a failure, cancelling the group unless configured to continue
+func (g *RequestGroup) record(index int, err error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	// Failures caused by the group cancelling itself are noise, the original failure is already recorded
+	if errors.Is(context.Cause(g.ctx), errGroupCancelled) && (errors.Is(err, context.Canceled) || errors.Is(err, errGroupCancelled)) {
+		return
+	}
+
+	g.errors = append(g.errors, &GroupCallError{Index: index, Err: err})
+	if !g.continueOnError {
+		g.cancel(errGroupCancelled)
+	}
+}
+
+// errGroupCancelled is the cancellation cause set when a call in the group fails
+var errGroupCancelled = errors.New("request group cancelled after a failed call")
+
+// GroupCallError is the failure of a single call in a RequestGroup
+type GroupCallError struct {
+	Index int   // Position of the call in the order Go/GoFunc were invoked
+	Err   error // The error returned by the call, usually an *HTTPError
+}
+
+// Error implements the error interface
+func (e *GroupCallError) Error() string {
+	return fmt.Sprintf("call %d: %v", e.Index, e.Err)
+}
+
+// Unwrap implements the unwrapper interface for error chains
+func (e *GroupCallError) Unwrap() error {
+	return e.Err
+}
+
+// GroupError aggregates the failures of a RequestGroup
+type GroupError struct {
+	Errors []*GroupCallError // Failed calls ordered by index
+}
+
+// Error implements the error interface
+func (e *GroupError) Error() string {
+	messages := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		messages[i] = err.Error()
+	}
+	return fmt.Sprintf("%d of the group calls failed: %s", len(e.Errors), strings.Join(messages, "; "))
+}
+
+// Unwrap returns the individual failures so errors.Is and errors.As inspect each of them
+func (e *GroupError) Unwrap() []error {
+	errs := make([]error, len(e.Errors))
+	for i, err := range e.Errors {
+		errs[i] = err
+	}
+	return errs
+}
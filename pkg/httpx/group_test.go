@@ -0,0 +1,136 @@
+package httpx_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bdpiprava/easy-http/pkg/httpx"
+)
+
+type groupUser struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+func TestRequestGroup(t *testing.T) {
+	var inFlight, maxInFlight atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		current := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			seen := maxInFlight.Load()
+			if current <= seen || maxInFlight.CompareAndSwap(seen, current) {
+				break
+			}
+		}
+
+		switch r.URL.Path {
+		case "/slow":
+			select {
+			case <-time.After(2 * time.Second):
+			case <-r.Context().Done():
+				return
+			}
+		case "/missing":
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"error":"not found"}`))
+			return
+		case "/broken":
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		time.Sleep(20 * time.Millisecond)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id":` + r.URL.Query().Get("id") + `,"name":"user"}`))
+	}))
+	defer server.Close()
+
+	client := httpx.NewClientWithConfig(httpx.WithClientDefaultBaseURL(server.URL))
+
+	t.Run("decodes results into targets with bounded concurrency", func(t *testing.T) {
+		maxInFlight.Store(0)
+		users := make([]groupUser, 6)
+
+		g := httpx.Group(context.Background(), client, httpx.WithGroupLimit(2))
+		for i := range users {
+			g.Go(*httpx.NewRequest(http.MethodGet, httpx.WithPath("/users"), httpx.WithQueryParam("id", strconv.Itoa(i))), &users[i])
+		}
+
+		require.NoError(t, g.Wait())
+		for i, user := range users {
+			assert.Equal(t, groupUser{ID: i, Name: "user"}, user)
+		}
+		assert.LessOrEqual(t, maxInFlight.Load(), int32(2))
+	})
+
+	t.Run("first failure cancels in-flight calls", func(t *testing.T) {
+		var slow, missing groupUser
+		start := time.Now()
+
+		g := httpx.Group(context.Background(), client)
+		g.Go(*httpx.NewRequest(http.MethodGet, httpx.WithPath("/slow")), &slow)
+		g.Go(*httpx.NewRequest(http.MethodGet, httpx.WithPath("/missing")), &missing)
+		err := g.Wait()
+
+		assert.Less(t, time.Since(start), time.Second)
+		groupErr := &httpx.GroupError{}
+		require.ErrorAs(t, err, &groupErr)
+		require.Len(t, groupErr.Errors, 1)
+		assert.Equal(t, 1, groupErr.Errors[0].Index)
+		assert.True(t, httpx.IsClientError(err))
+		assert.Equal(t, http.StatusNotFound, httpx.GetStatusCode(groupErr.Errors[0]))
+	})
+
+	t.Run("aggregates all failures when continuing on error", func(t *testing.T) {
+		var ok, missing, broken groupUser
+
+		g := httpx.Group(context.Background(), client, httpx.WithGroupContinueOnError())
+		g.Go(*httpx.NewRequest(http.MethodGet, httpx.WithPath("/missing")), &missing)
+		g.Go(*httpx.NewRequest(http.MethodGet, httpx.WithPath("/users"), httpx.WithQueryParam("id", "7")), &ok)
+		g.Go(*httpx.NewRequest(http.MethodGet, httpx.WithPath("/broken")), &broken)
+		err := g.Wait()
+
+		groupErr := &httpx.GroupError{}
+		require.ErrorAs(t, err, &groupErr)
+		require.Len(t, groupErr.Errors, 2)
+		assert.Equal(t, 0, groupErr.Errors[0].Index)
+		assert.Equal(t, 2, groupErr.Errors[1].Index)
+		assert.True(t, httpx.IsClientError(groupErr.Errors[0]))
+		assert.True(t, httpx.IsServerError(groupErr.Errors[1]))
+		assert.Equal(t, groupUser{ID: 7, Name: "user"}, ok)
+	})
+
+	t.Run("parent cancellation is reported", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		var slow groupUser
+
+		g := httpx.Group(ctx, client)
+		g.Go(*httpx.NewRequest(http.MethodGet, httpx.WithPath("/slow")), &slow)
+		time.AfterFunc(20*time.Millisecond, cancel)
+
+		err := g.Wait()
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+
+	t.Run("runs custom functions and rejects invalid targets", func(t *testing.T) {
+		sentinel := errors.New("custom failure")
+
+		g := httpx.Group(context.Background(), client, httpx.WithGroupContinueOnError())
+		g.GoFunc(func(context.Context) error { return sentinel })
+		g.Go(*httpx.NewRequest(http.MethodGet, httpx.WithPath("/users")), groupUser{})
+		err := g.Wait()
+
+		assert.ErrorIs(t, err, sentinel)
+		assert.True(t, httpx.IsValidationError(err))
+	})
+}
@@ -0,0 +1,36 @@
+package httpx
+
+import (
+	"net/http"
+	"sync/atomic"
+)
+
+// NetworkGuard decides whether a request may be sent over the network, returning an error to fail it
+type NetworkGuard func(req *http.Request) error
+
+// networkGuard is the guard checked by every client before sending a request, if any
+var networkGuard atomic.Pointer[NetworkGuard]
+
+// SetNetworkGuard installs a guard checked by every client of the process before sending a request over the
+// network, responses served by middlewares such as the cache are not checked. It returns a function restoring
+// the previous guard. It is meant for tests, see httpxtesting.DenyExternalNetwork.
+func SetNetworkGuard(guard NetworkGuard) (restore func()) {
+	var previous *NetworkGuard
+	if guard == nil {
+		previous = networkGuard.Swap(nil)
+	} else {
+		previous = networkGuard.Swap(&guard)
+	}
+	return func() {
+		networkGuard.Store(previous)
+	}
+}
+
+// checkNetworkGuard returns the error of the installed guard for req, if any
+func checkNetworkGuard(req *http.Request) error {
+	guard := networkGuard.Load()
+	if guard == nil {
+		return nil
+	}
+	return (*guard)(req)
+}
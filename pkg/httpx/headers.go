@@ -0,0 +1,291 @@
+package httpx
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxDeltaSeconds caps delta-seconds values such as max-age and Retry-After, as recommended by RFC 9111 section 1.2.2
+const maxDeltaSeconds = 2147483648
+
+// CacheControl holds the directives of a Cache-Control header keyed by lower-case name
+// Directives without an argument map to an empty string; quoted arguments are unquoted.
+type CacheControl map[string]string
+
+// ParseCacheControl parses a Cache-Control header value
+// Malformed directives are skipped and only the first occurrence of a directive is kept, so it never fails.
+// Join multiple header lines with a comma before parsing them.
+func ParseCacheControl(header string) CacheControl {
+	directives := CacheControl{}
+	for _, part := range splitQuoted(header, ',', false) {
+		name, value, _ := strings.Cut(part, "=")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if !isToken(name) {
+			continue
+		}
+		if _, exists := directives[name]; exists {
+			continue
+		}
+		directives[name] = unquote(strings.TrimSpace(value))
+	}
+	return directives
+}
+
+// Has reports whether the directive is present
+func (c CacheControl) Has(directive string) bool {
+	_, ok := c[strings.ToLower(directive)]
+	return ok
+}
+
+// MaxAge returns the max-age directive, reporting false when it is absent or malformed
+// Values beyond 2^31 seconds are capped.
+func (c CacheControl) MaxAge() (time.Duration, bool) {
+	return c.deltaSeconds("max-age")
+}
+
+// SharedMaxAge returns the s-maxage directive, which overrides max-age in shared caches, reporting false when
+// it is absent or malformed
+func (c CacheControl) SharedMaxAge() (time.Duration, bool) {
+	return c.deltaSeconds("s-maxage")
+}
+
+// StaleWhileRevalidate returns the RFC 5861 stale-while-revalidate directive, the time a response may be served
+// stale after it expired while it is revalidated in the background, reporting false when it is absent or malformed
+func (c CacheControl) StaleWhileRevalidate() (time.Duration, bool) {
+	return c.deltaSeconds("stale-while-revalidate")
+}
+
+// StaleIfError returns the RFC 5861 stale-if-error directive, the time a response may be served stale after it
+// expired when revalidating it fails, reporting false when it is absent or malformed
+func (c CacheControl) StaleIfError() (time.Duration, bool) {
+	return c.deltaSeconds("stale-if-error")
+}
+
+// deltaSeconds returns the delta-seconds argument of a directive, reporting false when it is absent or malformed
+func (c CacheControl) deltaSeconds(directive string) (time.Duration, bool) {
+	value, ok := c[directive]
+	if !ok {
+		return 0, false
+	}
+	seconds, ok := parseDeltaSeconds(value)
+	if !ok {
+		return 0, false
+	}
+	return time.Duration(seconds) * time.Second, true
+}
+
+// responseCacheControl parses all Cache-Control lines of a response header
+func responseCacheControl(header http.Header) CacheControl {
+	return ParseCacheControl(strings.Join(header.Values("Cache-Control"), ","))
+}
+
+// ParseRetryAfter returns the delay requested by a Retry-After header value, given either as delay-seconds
+// or as an HTTP-date relative to now
+// It reports false for empty, negative or malformed values. Dates in the past yield zero and delays beyond
+// 2^31 seconds are capped, so the result is always safe to sleep on.
+func ParseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, false
+	}
+	if seconds, ok := parseDeltaSeconds(value); ok {
+		return time.Duration(seconds) * time.Second, true
+	}
+	date, err := http.ParseTime(value)
+	if err != nil {
+		return 0, false
+	}
+	delay := date.Sub(now)
+	if delay < 0 {
+		return 0, true
+	}
+	return min(delay, maxDeltaSeconds*time.Second), true
+}
+
+// removedHeadersKey is the context key for the headers a request asked not to send
+type removedHeadersKey struct{}
+
+// contextWithRemovedHeaders returns a copy of ctx carrying the names of headers that must not be sent
+func contextWithRemovedHeaders(ctx context.Context, names []string) context.Context {
+	if len(names) == 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, removedHeadersKey{}, names)
+}
+
+// removedHeadersFromContext returns the names of headers that must not be sent, or nil if there are none
+func removedHeadersFromContext(ctx context.Context) []string {
+	names, _ := ctx.Value(removedHeadersKey{}).([]string)
+	return names
+}
+
+// removeHeaders deletes the named headers from header whatever the case of their keys
+// An empty User-Agent is kept when it is removed, as net/http omits it instead of sending its default.
+func removeHeaders(header http.Header, names []string) {
+	if len(names) == 0 || header == nil {
+		return
+	}
+	for key := range header {
+		if containsHeaderName(names, key) {
+			delete(header, key)
+		}
+	}
+	if containsHeaderName(names, "User-Agent") {
+		header["User-Agent"] = []string{""}
+	}
+}
+
+// hasHeader reports whether header has a key matching name case-insensitively
+func hasHeader(header http.Header, name string) bool {
+	for key := range header {
+		if strings.EqualFold(key, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// containsHeaderName reports whether names contains name case-insensitively
+func containsHeaderName(names []string, name string) bool {
+	for _, n := range names {
+		if strings.EqualFold(n, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// Link is a single link of a Link header, see RFC 8288
+type Link struct {
+	URL    string            // Target URI as given, possibly relative to the request URL
+	Rel    string            // Space separated, lower-case relation types
+	Params map[string]string // Other parameters keyed by lower-case name
+}
+
+// HasRel reports whether the link has the relation type rel, e.g. "next"
+func (l Link) HasRel(rel string) bool {
+	for _, r := range strings.Fields(l.Rel) {
+		if r == strings.ToLower(rel) {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseLinkHeader parses a Link header value, such as `<https://api.example.com/items?page=2>; rel="next"`
+// Malformed links are skipped and the remaining ones returned, so it never fails.
+// Join multiple header lines with a comma before parsing them.
+func ParseLinkHeader(header string) []Link {
+	var links []Link
+	for _, part := range splitQuoted(header, ',', true) {
+		part = strings.TrimSpace(part)
+		if !strings.HasPrefix(part, "<") {
+			continue
+		}
+		end := strings.IndexByte(part, '>')
+		if end < 0 || strings.ContainsAny(part[1:end], "< \t") {
+			continue
+		}
+
+		link := Link{URL: part[1:end]}
+		for _, param := range splitQuoted(part[end+1:], ';', false) {
+			name, value, _ := strings.Cut(param, "=")
+			name = strings.ToLower(strings.TrimSpace(name))
+			if !isToken(name) {
+				continue
+			}
+			value = unquote(strings.TrimSpace(value))
+			if name == "rel" {
+				if link.Rel == "" {
+					link.Rel = strings.Join(strings.Fields(strings.ToLower(value)), " ")
+				}
+				continue
+			}
+			if link.Params == nil {
+				link.Params = map[string]string{}
+			}
+			if _, exists := link.Params[name]; !exists {
+				link.Params[name] = value
+			}
+		}
+		links = append(links, link)
+	}
+	return links
+}
+
+// parseDeltaSeconds parses a non-negative integer number of seconds, capping it at maxDeltaSeconds
+func parseDeltaSeconds(value string) (int64, bool) {
+	if value == "" {
+		return 0, false
+	}
+	for i := 0; i < len(value); i++ {
+		if value[i] < '0' || value[i] > '9' {
+			return 0, false
+		}
+	}
+	seconds, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || seconds > maxDeltaSeconds {
+		// Only overflow is possible for a string of digits
+		return maxDeltaSeconds, true
+	}
+	return seconds, true
+}
+
+// splitQuoted splits s on sep outside of quoted strings and, if uris is set, angle-bracketed URIs
+func splitQuoted(s string, sep byte, uris bool) []string {
+	var parts []string
+	start := 0
+	quoted, bracketed := false, false
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case quoted && c == '\\':
+			i++ // Skip the escaped character
+		case c == '"' && !bracketed:
+			quoted = !quoted
+		case c == '<' && uris && !quoted:
+			bracketed = true
+		case c == '>' && uris && !quoted:
+			bracketed = false
+		case c == sep && !quoted && !bracketed:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// unquote returns the content of a quoted-string, resolving escapes, or value unchanged if it is not quoted
+func unquote(value string) string {
+	if len(value) < 2 || value[0] != '"' || value[len(value)-1] != '"' {
+		return value
+	}
+	value = value[1 : len(value)-1]
+	if !strings.Contains(value, `\`) {
+		return value
+	}
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		if value[i] == '\\' && i+1 < len(value) {
+			i++
+		}
+		b.WriteByte(value[i])
+	}
+	return b.String()
+}
+
+// isToken reports whether s is a non-empty HTTP token, see RFC 9110 section 5.6.2
+func isToken(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c <= ' ' || c >= 0x7f || strings.IndexByte(`"(),/:;<=>?@[\]{}`, c) >= 0 {
+			return false
+		}
+	}
+	return true
+}
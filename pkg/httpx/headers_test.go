@@ -0,0 +1,192 @@
+package httpx_test
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/bdpiprava/easy-http/pkg/httpx"
+)
+
+// maxDelta is the cap applied to delta-seconds values
+const maxDelta = 2147483648 * time.Second
+
+func TestParseCacheControl(t *testing.T) {
+	testCases := []struct {
+		name   string
+		header string
+		want   httpx.CacheControl
+		maxAge time.Duration
+		hasAge bool
+	}{
+		{name: "empty", header: "", want: httpx.CacheControl{}},
+		{name: "directives", header: "public, max-age=60", want: httpx.CacheControl{"public": "", "max-age": "60"}, maxAge: time.Minute, hasAge: true},
+		{name: "case and whitespace", header: " No-Cache ,MAX-AGE = 5 ", want: httpx.CacheControl{"no-cache": "", "max-age": "5"}, maxAge: 5 * time.Second, hasAge: true},
+		{name: "quoted argument with comma", header: `no-cache="Set-Cookie, X-Id", max-age=1`, want: httpx.CacheControl{"no-cache": "Set-Cookie, X-Id", "max-age": "1"}, maxAge: time.Second, hasAge: true},
+		{name: "first occurrence wins", header: "max-age=1, max-age=2", want: httpx.CacheControl{"max-age": "1"}, maxAge: time.Second, hasAge: true},
+		{name: "negative max-age", header: "max-age=-1", want: httpx.CacheControl{"max-age": "-1"}},
+		{name: "overflowing max-age is capped", header: "max-age=99999999999999999999999", want: httpx.CacheControl{"max-age": "99999999999999999999999"}, maxAge: maxDelta, hasAge: true},
+		{name: "malformed directives are skipped", header: `, =1, "quoted", no-store`, want: httpx.CacheControl{"no-store": ""}},
+		{name: "unterminated quote", header: `private="a, no-store`, want: httpx.CacheControl{"private": `"a, no-store`}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := httpx.ParseCacheControl(tc.header)
+
+			assert.Equal(t, tc.want, got)
+			maxAge, ok := got.MaxAge()
+			assert.Equal(t, tc.hasAge, ok)
+			assert.Equal(t, tc.maxAge, maxAge)
+		})
+	}
+
+	t.Run("s-maxage", func(t *testing.T) {
+		got := httpx.ParseCacheControl("max-age=60, s-maxage=600")
+
+		sharedMaxAge, ok := got.SharedMaxAge()
+		assert.True(t, ok)
+		assert.Equal(t, 10*time.Minute, sharedMaxAge)
+		_, ok = httpx.ParseCacheControl("max-age=60").SharedMaxAge()
+		assert.False(t, ok)
+	})
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2025, time.January, 1, 12, 0, 0, 0, time.UTC)
+	testCases := []struct {
+		name  string
+		value string
+		want  time.Duration
+		ok    bool
+	}{
+		{name: "seconds", value: "120", want: 2 * time.Minute, ok: true},
+		{name: "zero", value: "0", want: 0, ok: true},
+		{name: "http date", value: now.Add(90 * time.Second).Format(http.TimeFormat), want: 90 * time.Second, ok: true},
+		{name: "date in the past", value: now.Add(-time.Hour).Format(http.TimeFormat), want: 0, ok: true},
+		{name: "overflowing seconds are capped", value: "9223372036854775808", want: maxDelta, ok: true},
+		{name: "empty", value: " "},
+		{name: "negative", value: "-5"},
+		{name: "fraction", value: "1.5"},
+		{name: "garbage", value: "soon"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, ok := httpx.ParseRetryAfter(tc.value, now)
+
+			assert.Equal(t, tc.ok, ok)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestParseLinkHeader(t *testing.T) {
+	testCases := []struct {
+		name   string
+		header string
+		want   []httpx.Link
+	}{
+		{name: "empty", header: ""},
+		{
+			name:   "pagination",
+			header: `<https://api.example.com/items?page=2>; rel="next", <https://api.example.com/items?page=9>; rel=last`,
+			want: []httpx.Link{
+				{URL: "https://api.example.com/items?page=2", Rel: "next"},
+				{URL: "https://api.example.com/items?page=9", Rel: "last"},
+			},
+		},
+		{
+			name:   "commas and semicolons inside URI and quotes",
+			header: `</items?ids=1,2;x>; rel="next prev"; title="a, b; c"`,
+			want:   []httpx.Link{{URL: "/items?ids=1,2;x", Rel: "next prev", Params: map[string]string{"title": "a, b; c"}}},
+		},
+		{
+			name:   "case and escapes",
+			header: `</a>; REL=" Next  "; Title="say \"hi\""`,
+			want:   []httpx.Link{{URL: "/a", Rel: "next", Params: map[string]string{"title": `say "hi"`}}},
+		},
+		{
+			name:   "malformed links are skipped",
+			header: `https://no-brackets; rel=next, </ok>; rel=prev, </unterminated; rel=next, </with space>; rel=next`,
+			want:   []httpx.Link{{URL: "/ok", Rel: "prev"}},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, httpx.ParseLinkHeader(tc.header))
+		})
+	}
+
+	t.Run("HasRel", func(t *testing.T) {
+		link := httpx.ParseLinkHeader(`</a>; rel="next last"`)[0]
+
+		assert.True(t, link.HasRel("next"))
+		assert.True(t, link.HasRel("Last"))
+		assert.False(t, link.HasRel("prev"))
+	})
+}
+
+func FuzzParseCacheControl(f *testing.F) {
+	for _, seed := range []string{
+		"", "no-store", "public, max-age=60", `no-cache="Set-Cookie, X-Id"`, "max-age=99999999999999999999",
+		`private="unterminated, max-age=1`, `a="\`, ",,,=,", "max-age=-1, s-maxage=10",
+	} {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, header string) {
+		directives := httpx.ParseCacheControl(header)
+		for name := range directives {
+			if name == "" || name != strings.ToLower(name) || strings.ContainsAny(name, " \t,=\"") {
+				t.Fatalf("invalid directive name %q parsed from %q", name, header)
+			}
+		}
+		if maxAge, ok := directives.MaxAge(); ok && (maxAge < 0 || maxAge > maxDelta) {
+			t.Fatalf("max-age %s out of range for %q", maxAge, header)
+		}
+	})
+}
+
+func FuzzParseRetryAfter(f *testing.F) {
+	for _, seed := range []string{
+		"", "0", "120", "-1", "9223372036854775808", "Wed, 21 Oct 2015 07:28:00 GMT", "Mon, 01 Jan 10000 00:00:00 GMT", "1e9",
+	} {
+		f.Add(seed)
+	}
+
+	now := time.Now()
+	f.Fuzz(func(t *testing.T, value string) {
+		delay, ok := httpx.ParseRetryAfter(value, now)
+		if !ok && delay != 0 {
+			t.Fatalf("rejected %q with delay %s", value, delay)
+		}
+		if delay < 0 || delay > maxDelta {
+			t.Fatalf("delay %s out of range for %q", delay, value)
+		}
+	})
+}
+
+func FuzzParseLinkHeader(f *testing.F) {
+	for _, seed := range []string{
+		"", `<https://api.example.com/items?page=2>; rel="next"`, `</a,b>; rel="next prev"; title="x, y", </c>; rel=last`,
+		`<`, `<>`, `</a>; rel="\`, `</a>;;;=;rel`, `"<", </a>`,
+	} {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, header string) {
+		for _, link := range httpx.ParseLinkHeader(header) {
+			if strings.ContainsAny(link.URL, "<> \t") {
+				t.Fatalf("invalid link URL %q parsed from %q", link.URL, header)
+			}
+			if link.Rel != strings.ToLower(link.Rel) {
+				t.Fatalf("rel %q parsed from %q is not lower-case", link.Rel, header)
+			}
+		}
+	})
+}
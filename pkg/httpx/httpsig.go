@@ -0,0 +1,320 @@
+package httpx
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// HTTPSignatureAlgorithm identifies an RFC 9421 HTTP Message Signature algorithm
+type HTTPSignatureAlgorithm string
+
+const (
+	// HTTPSignatureRSAPSSSHA512 is RSASSA-PSS using SHA-512
+	HTTPSignatureRSAPSSSHA512 HTTPSignatureAlgorithm = "rsa-pss-sha512"
+	// HTTPSignatureRSAV15SHA256 is RSASSA-PKCS1-v1_5 using SHA-256
+	HTTPSignatureRSAV15SHA256 HTTPSignatureAlgorithm = "rsa-v1_5-sha256"
+	// HTTPSignatureECDSAP256SHA256 is ECDSA using curve P-256 and SHA-256
+	HTTPSignatureECDSAP256SHA256 HTTPSignatureAlgorithm = "ecdsa-p256-sha256"
+	// HTTPSignatureECDSAP384SHA384 is ECDSA using curve P-384 and SHA-384
+	HTTPSignatureECDSAP384SHA384 HTTPSignatureAlgorithm = "ecdsa-p384-sha384"
+	// HTTPSignatureEd25519 is EdDSA using curve Ed25519
+	HTTPSignatureEd25519 HTTPSignatureAlgorithm = "ed25519"
+)
+
+// MessageSignatureConfig configures RFC 9421 HTTP Message Signatures for outbound requests
+type MessageSignatureConfig struct {
+	KeySource            SigningKeySource       // Source of the signing key (required)
+	Algorithm            HTTPSignatureAlgorithm // Signature algorithm (default: derived from the key type)
+	Label                string                 // Signature label used in Signature-Input and Signature (default: sig1)
+	Components           []string               // Covered components (default: @method, @target-uri, plus content-digest when a body is present)
+	IncludeAlgorithm     bool                   // If true, adds the alg parameter to the signature parameters
+	Expires              time.Duration          // If set, adds an expires parameter relative to created
+	Nonce                func() string          // Optional nonce generator
+	Tag                  string                 // Optional application specific tag parameter
+	DisableContentDigest bool                   // If true, the Content-Digest header is not added to requests with a body
+	Now                  func() time.Time       // Clock used for the created parameter (default: time.Now)
+}
+
+// MessageSignatureMiddleware signs outbound requests using RFC 9421 HTTP Message Signatures
+type MessageSignatureMiddleware struct {
+	config MessageSignatureConfig
+}
+
+// NewMessageSignatureMiddleware creates a new HTTP Message Signatures middleware
+func NewMessageSignatureMiddleware(config MessageSignatureConfig) *MessageSignatureMiddleware {
+	if config.Label == "" {
+		config.Label = "sig1"
+	}
+	if config.Now == nil {
+		config.Now = time.Now
+	}
+	return &MessageSignatureMiddleware{config: config}
+}
+
+// Name returns the middleware name
+func (m *MessageSignatureMiddleware) Name() string {
+	return "http-message-signature"
+}
+
+// Execute implements the Middleware interface
+func (m *MessageSignatureMiddleware) Execute(ctx context.Context, req *http.Request, next MiddlewareFunc) (*http.Response, error) {
+	// Sign and send the canonical URL, which middlewares rewriting the URL may have departed from
+	canonicalizeRequest(req)
+	if err := m.sign(ctx, req); err != nil {
+		return nil, MiddlewareError("failed to sign request", err, req)
+	}
+	return next(ctx, req)
+}
+
+// sign adds the Signature-Input and Signature headers to the request
+func (m *MessageSignatureMiddleware) sign(ctx context.Context, req *http.Request) error {
+	if m.config.KeySource == nil {
+		return errors.New("signing key source is not configured")
+	}
+	key, err := m.config.KeySource.SigningKey(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to load signing key")
+	}
+
+	algorithm := m.config.Algorithm
+	if algorithm == "" {
+		if algorithm, err = httpSignatureAlgorithmFor(key.Signer); err != nil {
+			return err
+		}
+	}
+
+	hasBody := req.Body != nil && req.Body != http.NoBody
+	if hasBody && !m.config.DisableContentDigest && req.Header.Get("Content-Digest") == "" {
+		if err := setContentDigest(req); err != nil {
+			return err
+		}
+	}
+
+	components := m.config.Components
+	if len(components) == 0 {
+		components = []string{"@method", "@target-uri"}
+		if req.Header.Get("Content-Digest") != "" {
+			components = append(components, "content-digest")
+		}
+	}
+
+	params := m.signatureParams(components, key.KeyID, algorithm)
+	base, err := signatureBase(req, components, params)
+	if err != nil {
+		return err
+	}
+
+	signature, err := signHTTPMessage(algorithm, key.Signer, []byte(base))
+	if err != nil {
+		return errors.Wrap(err, "failed to compute signature")
+	}
+
+	req.Header.Set("Signature-Input", m.config.Label+"="+params)
+	req.Header.Set("Signature", m.config.Label+"=:"+base64.StdEncoding.EncodeToString(signature)+":")
+	return nil
+}
+
+// signatureParams serializes the @signature-params inner list with its parameters
+func (m *MessageSignatureMiddleware) signatureParams(components []string, keyID string, algorithm HTTPSignatureAlgorithm) string {
+	var b strings.Builder
+	b.WriteString("(")
+	for i, component := range components {
+		if i > 0 {
+			b.WriteString(" ")
+		}
+		b.WriteString(serializeComponentID(component))
+	}
+	b.WriteString(")")
+
+	created := m.config.Now()
+	fmt.Fprintf(&b, ";created=%d", created.Unix())
+	if m.config.Expires > 0 {
+		fmt.Fprintf(&b, ";expires=%d", created.Add(m.config.Expires).Unix())
+	}
+	if m.config.Nonce != nil {
+		fmt.Fprintf(&b, ";nonce=%q", m.config.Nonce())
+	}
+	if m.config.IncludeAlgorithm {
+		fmt.Fprintf(&b, ";alg=%q", string(algorithm))
+	}
+	if keyID != "" {
+		fmt.Fprintf(&b, ";keyid=%q", keyID)
+	}
+	if m.config.Tag != "" {
+		fmt.Fprintf(&b, ";tag=%q", m.config.Tag)
+	}
+	return b.String()
+}
+
+// serializeComponentID serializes a component identifier, e.g. `@query-param;name=id` becomes `"@query-param";name="id"`
+func serializeComponentID(component string) string {
+	name, rawParams, _ := strings.Cut(component, ";")
+	id := strconv.Quote(strings.ToLower(strings.TrimSpace(name)))
+	if rawParams == "" {
+		return id
+	}
+	for _, param := range strings.Split(rawParams, ";") {
+		key, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+		id += ";" + key + "=" + strconv.Quote(strings.Trim(value, `"`))
+	}
+	return id
+}
+
+// signatureBase builds the RFC 9421 signature base for the request
+func signatureBase(req *http.Request, components []string, params string) (string, error) {
+	var b strings.Builder
+	for _, component := range components {
+		value, err := componentValue(req, component)
+		if err != nil {
+			return "", err
+		}
+		b.WriteString(serializeComponentID(component))
+		b.WriteString(": ")
+		b.WriteString(value)
+		b.WriteString("\n")
+	}
+	b.WriteString(`"@signature-params": `)
+	b.WriteString(params)
+	return b.String(), nil
+}
+
+// componentValue resolves the value of a derived component or header field
+func componentValue(req *http.Request, component string) (string, error) {
+	name, rawParams, _ := strings.Cut(component, ";")
+	name = strings.ToLower(strings.TrimSpace(name))
+
+	switch name {
+	case "@method":
+		return req.Method, nil
+	case "@target-uri":
+		return req.URL.String(), nil
+	case "@authority":
+		return requestAuthority(req), nil
+	case "@scheme":
+		return strings.ToLower(req.URL.Scheme), nil
+	case "@request-target":
+		return req.URL.RequestURI(), nil
+	case "@path":
+		if req.URL.EscapedPath() == "" {
+			return "/", nil
+		}
+		return req.URL.EscapedPath(), nil
+	case "@query":
+		return "?" + req.URL.RawQuery, nil
+	case "@query-param":
+		_, paramName, _ := strings.Cut(rawParams, "=")
+		paramName = strings.Trim(strings.TrimSpace(paramName), `"`)
+		values, ok := req.URL.Query()[paramName]
+		if !ok || len(values) == 0 {
+			return "", errors.Errorf("query parameter %q not present in request", paramName)
+		}
+		return values[0], nil
+	}
+
+	if strings.HasPrefix(name, "@") {
+		return "", errors.Errorf("unsupported derived component: %s", name)
+	}
+
+	values := req.Header.Values(name)
+	if len(values) == 0 && name == "content-length" && req.ContentLength > 0 {
+		values = []string{strconv.FormatInt(req.ContentLength, 10)}
+	}
+	if len(values) == 0 && name == "host" {
+		values = []string{requestAuthority(req)}
+	}
+	if len(values) == 0 {
+		return "", errors.Errorf("header %q not present in request", name)
+	}
+	trimmed := make([]string, len(values))
+	for i, value := range values {
+		trimmed[i] = strings.TrimSpace(value)
+	}
+	return strings.Join(trimmed, ", "), nil
+}
+
+// requestAuthority returns the lowercased host of the request without default ports
+func requestAuthority(req *http.Request) string {
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	host = strings.ToLower(host)
+	if (req.URL.Scheme == schemeHTTP && strings.HasSuffix(host, ":80")) ||
+		(req.URL.Scheme == schemeHTTPS && strings.HasSuffix(host, ":443")) {
+		host = host[:strings.LastIndex(host, ":")]
+	}
+	return host
+}
+
+// setContentDigest adds an RFC 9530 Content-Digest header computed over the request body
+func setContentDigest(req *http.Request) error {
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		return errors.Wrap(err, "failed to read request body")
+	}
+	req.Body.Close()
+
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(body)), nil
+	}
+
+	digest := sha256.Sum256(body)
+	req.Header.Set("Content-Digest", "sha-256=:"+base64.StdEncoding.EncodeToString(digest[:])+":")
+	return nil
+}
+
+// httpSignatureAlgorithmFor returns the default RFC 9421 algorithm for a signing key
+func httpSignatureAlgorithmFor(signer crypto.Signer) (HTTPSignatureAlgorithm, error) {
+	switch key := signer.Public().(type) {
+	case *rsa.PublicKey:
+		return HTTPSignatureRSAPSSSHA512, nil
+	case *ecdsa.PublicKey:
+		switch key.Curve.Params().BitSize {
+		case 256:
+			return HTTPSignatureECDSAP256SHA256, nil
+		case 384:
+			return HTTPSignatureECDSAP384SHA384, nil
+		}
+		return "", errors.Errorf("unsupported EC curve: %s", key.Curve.Params().Name)
+	case ed25519.PublicKey:
+		return HTTPSignatureEd25519, nil
+	default:
+		return "", errors.Errorf("unsupported signing key type: %T", key)
+	}
+}
+
+// signHTTPMessage signs the signature base with the given algorithm
+func signHTTPMessage(algorithm HTTPSignatureAlgorithm, signer crypto.Signer, base []byte) ([]byte, error) {
+	switch algorithm {
+	case HTTPSignatureEd25519:
+		return signer.Sign(rand.Reader, base, crypto.Hash(0))
+	case HTTPSignatureRSAPSSSHA512:
+		digest := crypto.SHA512.New()
+		digest.Write(base)
+		return signer.Sign(rand.Reader, digest.Sum(nil), &rsa.PSSOptions{SaltLength: 64, Hash: crypto.SHA512})
+	case HTTPSignatureRSAV15SHA256:
+		return signJWS("RS256", signer, base)
+	case HTTPSignatureECDSAP256SHA256:
+		return signJWS("ES256", signer, base)
+	case HTTPSignatureECDSAP384SHA384:
+		return signJWS("ES384", signer, base)
+	default:
+		return nil, errors.Errorf("unsupported signature algorithm: %s", algorithm)
+	}
+}
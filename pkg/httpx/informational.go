@@ -0,0 +1,37 @@
+package httpx
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptrace"
+	"net/textproto"
+)
+
+// InformationalResponse is an interim 1xx response received before the final response of a request, such as
+// 103 Early Hints announcing resources the final response will need
+type InformationalResponse struct {
+	StatusCode int
+	Header     http.Header
+}
+
+// Links returns the values of the Link headers of the response, e.g. the resources to preload announced by
+// 103 Early Hints
+func (r InformationalResponse) Links() []string {
+	return r.Header.Values("Link")
+}
+
+// InformationalHandler is called with each interim 1xx response of a request, other than 101 Switching
+// Protocols, before the final response is received; returning an error aborts the request
+type InformationalHandler func(ctx context.Context, resp InformationalResponse) error
+
+// contextWithInformationalHandler returns a context tracing the interim responses of requests to handler
+func contextWithInformationalHandler(ctx context.Context, handler InformationalHandler) context.Context {
+	if handler == nil {
+		return ctx
+	}
+	return httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		Got1xxResponse: func(code int, header textproto.MIMEHeader) error {
+			return handler(ctx, InformationalResponse{StatusCode: code, Header: http.Header(header).Clone()})
+		},
+	})
+}
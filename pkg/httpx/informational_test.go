@@ -0,0 +1,64 @@
+package httpx_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bdpiprava/easy-http/pkg/httpx"
+	httpxtesting "github.com/bdpiprava/easy-http/pkg/httpx/testing"
+)
+
+func TestInformationalHandler(t *testing.T) {
+	t.Parallel()
+
+	server := httpxtesting.NewMockServer()
+	defer server.Close()
+	server.OnGet("/page").
+		WithInformational(http.StatusProcessing, nil).
+		WithEarlyHints("</style.css>; rel=preload; as=style", "</app.js>; rel=preload; as=script").
+		WithJSON(map[string]string{"title": "home"})
+	client := httpx.NewClientWithConfig(httpx.WithClientDefaultBaseURL(server.URL()))
+
+	t.Run("reports interim responses and decodes the final one", func(t *testing.T) {
+		var interim []httpx.InformationalResponse
+
+		resp, err := client.Execute(*httpx.NewRequest(http.MethodGet,
+			httpx.WithPath("/page"),
+			httpx.WithInformationalHandler(func(_ context.Context, resp httpx.InformationalResponse) error {
+				interim = append(interim, resp)
+				return nil
+			}),
+		), map[string]string{})
+
+		require.NoError(t, err)
+		assert.Equal(t, map[string]string{"title": "home"}, resp.Body)
+		assert.Empty(t, resp.Header().Values("Link"), "interim headers are not part of the final response")
+		require.Len(t, interim, 2)
+		assert.Equal(t, http.StatusProcessing, interim[0].StatusCode)
+		assert.Equal(t, http.StatusEarlyHints, interim[1].StatusCode)
+		assert.Equal(t, []string{"</style.css>; rel=preload; as=style", "</app.js>; rel=preload; as=script"}, interim[1].Links())
+	})
+
+	t.Run("skips interim responses without a handler", func(t *testing.T) {
+		resp, err := client.Execute(*httpx.NewRequest(http.MethodGet, httpx.WithPath("/page")), map[string]string{})
+
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, map[string]string{"title": "home"}, resp.Body)
+	})
+
+	t.Run("aborts the request when the handler fails", func(t *testing.T) {
+		_, err := client.Execute(*httpx.NewRequest(http.MethodGet,
+			httpx.WithPath("/page"),
+			httpx.WithInformationalHandler(func(context.Context, httpx.InformationalResponse) error {
+				return assert.AnError
+			}),
+		), map[string]string{})
+
+		require.Error(t, err)
+	})
+}
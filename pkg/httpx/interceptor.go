@@ -0,0 +1,131 @@
+package httpx
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/pkg/errors"
+)
+
+// Interceptor observes and modifies the payloads of the requests a client executes and their decoded responses,
+// for business-level concerns such as stamping audit fields on every command or enriching responses, without
+// re-encoding or re-decoding bodies as middlewares and transformers would have to
+//
+// InterceptRequest runs on the value given to WithJSONBody or WithEncodedBody before it is marshaled, and only
+// for requests with such a body. InterceptResponse runs once the body of a buffered response is decoded, after
+// the response transformers; streaming responses are not intercepted. Interceptors run in the order they were
+// added, and an error fails the request.
+type Interceptor interface {
+	InterceptRequest(ctx context.Context, req *InterceptedRequest) error
+	InterceptResponse(ctx context.Context, resp *Response) error
+}
+
+// InterceptedRequest is a request whose payload is about to be marshaled
+// Replacing Payload sends the new value; the value given to the request option is left unchanged, unless the
+// interceptor modifies what it points to.
+type InterceptedRequest struct {
+	Method  string
+	Path    string      // Path of the request, with its {name} placeholders unexpanded
+	Header  http.Header // Headers of the request, which may be modified
+	Payload any
+}
+
+// TypedInterceptor returns an Interceptor applying onRequest to request payloads of type Req and onResponse to
+// decoded response bodies of type Resp, both modified in place; payloads and bodies of other types are left
+// unchanged. Either function may be nil.
+//
+//	httpx.TypedInterceptor(
+//		func(ctx context.Context, order *CreateOrder) error {
+//			order.RequestedBy = auth.UserFrom(ctx)
+//			return nil
+//		},
+//		func(ctx context.Context, _ *httpx.Response, order *Order) error {
+//			order.Total = order.Total.Round(2)
+//			return nil
+//		},
+//	)
+func TypedInterceptor[Req, Resp any](
+	onRequest func(ctx context.Context, payload *Req) error,
+	onResponse func(ctx context.Context, resp *Response, body *Resp) error,
+) Interceptor {
+	return &typedInterceptor[Req, Resp]{onRequest: onRequest, onResponse: onResponse}
+}
+
+// typedInterceptor is the Interceptor returned by TypedInterceptor
+type typedInterceptor[Req, Resp any] struct {
+	onRequest  func(ctx context.Context, payload *Req) error
+	onResponse func(ctx context.Context, resp *Response, body *Resp) error
+}
+
+// InterceptRequest implements Interceptor
+func (i *typedInterceptor[Req, Resp]) InterceptRequest(ctx context.Context, req *InterceptedRequest) error {
+	payload, ok := req.Payload.(Req)
+	if !ok || i.onRequest == nil {
+		return nil
+	}
+	if err := i.onRequest(ctx, &payload); err != nil {
+		return err
+	}
+	req.Payload = payload
+	return nil
+}
+
+// InterceptResponse implements Interceptor
+func (i *typedInterceptor[Req, Resp]) InterceptResponse(ctx context.Context, resp *Response) error {
+	body, ok := resp.Body.(Resp)
+	if !ok || i.onResponse == nil {
+		return nil
+	}
+	if err := i.onResponse(ctx, resp, &body); err != nil {
+		return err
+	}
+	resp.Body = body
+	return nil
+}
+
+// interceptRequest runs the interceptors on the payload of the request, before it is marshaled
+func interceptRequest(interceptors []Interceptor, opts *RequestOptions) error {
+	if len(interceptors) == 0 {
+		return nil
+	}
+	var payload any
+	switch body := opts.Body.(type) {
+	case *jsonBody:
+		payload = body.value
+	case *encodedBody:
+		payload = body.value
+	default:
+		return nil
+	}
+
+	ctx := opts.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	req := &InterceptedRequest{Method: opts.Method, Path: opts.Path, Header: opts.Headers.Clone(), Payload: payload}
+	for _, interceptor := range interceptors {
+		if err := interceptor.InterceptRequest(ctx, req); err != nil {
+			return errors.Wrap(err, "request interceptor failed")
+		}
+	}
+
+	// The body of the Request is shared by its executions, so the payload is replaced in a copy
+	switch body := opts.Body.(type) {
+	case *jsonBody:
+		opts.Body = &jsonBody{value: req.Payload}
+	case *encodedBody:
+		opts.Body = &encodedBody{mediaType: body.mediaType, value: req.Payload}
+	}
+	opts.Headers = req.Header
+	return nil
+}
+
+// interceptResponse runs the interceptors on a decoded response
+func interceptResponse(ctx context.Context, interceptors []Interceptor, resp *Response) error {
+	for _, interceptor := range interceptors {
+		if err := interceptor.InterceptResponse(ctx, resp); err != nil {
+			return errors.Wrap(err, "response interceptor failed")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package httpx_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bdpiprava/easy-http/pkg/httpx"
+)
+
+type requestedByKey struct{}
+
+type createOrder struct {
+	Item        string `json:"item"`
+	RequestedBy string `json:"requested_by"`
+}
+
+type order struct {
+	ID     int    `json:"id"`
+	Item   string `json:"item"`
+	Status string `json:"status"`
+}
+
+func TestInterceptors(t *testing.T) {
+	t.Parallel()
+
+	var received createOrder
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = createOrder{}
+		_ = json.NewDecoder(r.Body).Decode(&received)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id":7,"item":"book","status":"N"}`))
+	}))
+	defer server.Close()
+
+	statuses := map[string]string{"N": "new"}
+	stamp := httpx.TypedInterceptor(
+		func(ctx context.Context, payload *createOrder) error {
+			payload.RequestedBy, _ = ctx.Value(requestedByKey{}).(string)
+			return nil
+		},
+		func(_ context.Context, _ *httpx.Response, body *order) error {
+			body.Status = statuses[body.Status]
+			return nil
+		},
+	)
+
+	t.Run("modifies typed payloads and decoded responses", func(t *testing.T) {
+		client := httpx.NewClientWithConfig(
+			httpx.WithClientDefaultBaseURL(server.URL),
+			httpx.WithClientInterceptors(stamp),
+		)
+		payload := createOrder{Item: "book"}
+		ctx := context.WithValue(context.Background(), requestedByKey{}, "alice")
+
+		resp, err := client.Execute(*httpx.NewRequest(http.MethodPost,
+			httpx.WithPath("/orders"),
+			httpx.WithContext(ctx),
+			httpx.WithJSONBody(payload),
+		), order{})
+
+		require.NoError(t, err)
+		assert.Equal(t, createOrder{Item: "book", RequestedBy: "alice"}, received)
+		assert.Equal(t, order{ID: 7, Item: "book", Status: "new"}, resp.Body)
+		assert.Empty(t, payload.RequestedBy, "the caller's payload is left unchanged")
+	})
+
+	t.Run("ignores payloads and bodies of other types", func(t *testing.T) {
+		client := httpx.NewClientWithConfig(
+			httpx.WithClientDefaultBaseURL(server.URL),
+			httpx.WithClientInterceptors(stamp),
+		)
+
+		resp, err := client.Execute(*httpx.NewRequest(http.MethodPost,
+			httpx.WithPath("/orders"),
+			httpx.WithJSONBody(map[string]any{"item": "pen"}),
+		), map[string]any{})
+
+		require.NoError(t, err)
+		assert.Equal(t, createOrder{Item: "pen"}, received)
+		assert.Equal(t, "N", resp.Body.(map[string]any)["status"])
+	})
+
+	t.Run("fails the request when an interceptor fails", func(t *testing.T) {
+		errRejected := errors.New("rejected")
+		client := httpx.NewClientWithConfig(
+			httpx.WithClientDefaultBaseURL(server.URL),
+			httpx.WithClientInterceptors(httpx.TypedInterceptor[createOrder, order](nil,
+				func(context.Context, *httpx.Response, *order) error { return errRejected },
+			)),
+		)
+
+		_, err := client.Execute(*httpx.NewRequest(http.MethodGet, httpx.WithPath("/orders/7")), order{})
+
+		require.ErrorIs(t, err, errRejected)
+	})
+}
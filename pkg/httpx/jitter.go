@@ -0,0 +1,48 @@
+package httpx
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"math/rand/v2"
+	"sync"
+	"time"
+)
+
+// lockedSource serializes access to a rand.Source so it can be shared by concurrent requests
+// Each client owns its source, so jitter does not contend on the global math/rand state at high QPS
+type lockedSource struct {
+	mu  sync.Mutex
+	src rand.Source
+}
+
+// newLockedSource wraps src for concurrent use, seeding a new source when src is nil
+func newLockedSource(src rand.Source) *lockedSource {
+	if locked, ok := src.(*lockedSource); ok {
+		return locked
+	}
+	if src == nil {
+		src = rand.NewPCG(rand.Uint64(), rand.Uint64())
+	}
+	return &lockedSource{src: src}
+}
+
+// Uint64 implements rand.Source
+func (s *lockedSource) Uint64() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.src.Uint64()
+}
+
+// jitter returns a random duration in [0, maxJitter)
+func (s *lockedSource) jitter(maxJitter time.Duration) time.Duration {
+	if maxJitter <= 0 {
+		return 0
+	}
+	return time.Duration(rand.New(s).Int64N(int64(maxJitter)))
+}
+
+// seededSource returns a source seeded deterministically from seed
+func seededSource(seed string) rand.Source {
+	sum := sha256.Sum256([]byte(seed))
+	return rand.NewPCG(binary.BigEndian.Uint64(sum[:8]), binary.BigEndian.Uint64(sum[8:16]))
+}
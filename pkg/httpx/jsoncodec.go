@@ -0,0 +1,81 @@
+package httpx
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/pkg/errors"
+)
+
+// JSONCodec marshals JSON request bodies and unmarshals JSON response bodies, so a faster implementation can
+// replace encoding/json where decoding large responses dominates CPU time. The standard-compatible APIs of
+// jsoniter (jsoniter.ConfigCompatibleWithStandardLibrary) and sonic (sonic.ConfigStd) satisfy it as is;
+// JSONCodecFuncs adapts package-level functions such as those of go-json.
+//
+// JSONOptions only apply to encoding/json, configure the equivalent settings of the codec instead.
+type JSONCodec interface {
+	Marshal(v any) ([]byte, error)
+	Unmarshal(data []byte, v any) error
+}
+
+// JSONCodecFuncs adapts a pair of functions to the JSONCodec interface, e.g.
+// JSONCodecFuncs{MarshalFunc: gojson.Marshal, UnmarshalFunc: gojson.Unmarshal}
+type JSONCodecFuncs struct {
+	MarshalFunc   func(v any) ([]byte, error)
+	UnmarshalFunc func(data []byte, v any) error
+}
+
+// Marshal implements JSONCodec
+func (f JSONCodecFuncs) Marshal(v any) ([]byte, error) {
+	return f.MarshalFunc(v)
+}
+
+// Unmarshal implements JSONCodec
+func (f JSONCodecFuncs) Unmarshal(data []byte, v any) error {
+	return f.UnmarshalFunc(data, v)
+}
+
+// jsonBody is the body set by WithJSONBody, marshaled with the client's JSONCodec when the request is built
+// It marshals itself with encoding/json if read before.
+type jsonBody struct {
+	value  any
+	reader *bytes.Reader
+}
+
+// Read implements io.Reader
+func (b *jsonBody) Read(p []byte) (int, error) {
+	if b.reader == nil {
+		content, err := json.Marshal(b.value)
+		if err != nil {
+			return 0, err
+		}
+		b.reader = bytes.NewReader(content)
+	}
+	return b.reader.Read(p)
+}
+
+// marshalJSONBody replaces a body set by WithJSONBody with its JSON encoding by codec, or encoding/json if nil
+func marshalJSONBody(opts *RequestOptions, codec JSONCodec) error {
+	body, ok := opts.Body.(*jsonBody)
+	if !ok || body.reader != nil {
+		return nil
+	}
+	marshal := json.Marshal
+	if codec != nil {
+		marshal = codec.Marshal
+	}
+	content, err := marshal(body.value)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal JSON body")
+	}
+	opts.Body = bytes.NewReader(content)
+	return nil
+}
+
+// unmarshalJSON decodes a response body with the client's JSONCodec, or encoding/json and its JSONOptions
+func (c ClientConfig) unmarshalJSON(data []byte, v any) error {
+	if c.JSONCodec != nil {
+		return c.JSONCodec.Unmarshal(data, v)
+	}
+	return c.JSONOptions.unmarshal(data, v)
+}
@@ -0,0 +1,131 @@
+package httpx_test
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bdpiprava/easy-http/pkg/httpx"
+)
+
+// countingCodec is encoding/json counting its calls
+type countingCodec struct {
+	marshals, unmarshals atomic.Int32
+}
+
+func (c *countingCodec) Marshal(v any) ([]byte, error) {
+	c.marshals.Add(1)
+	return json.Marshal(v)
+}
+
+func (c *countingCodec) Unmarshal(data []byte, v any) error {
+	c.unmarshals.Add(1)
+	return json.Unmarshal(data, v)
+}
+
+func TestJSONCodec(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(body)
+	}))
+	defer server.Close()
+	type payload struct {
+		Name string `json:"name"`
+	}
+
+	t.Run("should marshal requests and unmarshal responses with the codec", func(t *testing.T) {
+		codec := &countingCodec{}
+		client := httpx.NewClientWithConfig(httpx.WithClientDefaultBaseURL(server.URL), httpx.WithClientJSONCodec(codec))
+
+		resp, err := client.Execute(*httpx.NewRequest(http.MethodPost, httpx.WithJSONBody(payload{Name: "ada"})), payload{})
+
+		require.NoError(t, err)
+		assert.Equal(t, payload{Name: "ada"}, resp.Body)
+		assert.Equal(t, int32(1), codec.marshals.Load())
+		assert.Equal(t, int32(1), codec.unmarshals.Load())
+	})
+
+	t.Run("should report marshal errors of the codec", func(t *testing.T) {
+		client := httpx.NewClientWithConfig(
+			httpx.WithClientDefaultBaseURL(server.URL),
+			httpx.WithClientJSONCodec(httpx.JSONCodecFuncs{
+				MarshalFunc:   func(any) ([]byte, error) { return nil, errors.New("boom") },
+				UnmarshalFunc: json.Unmarshal,
+			}),
+		)
+
+		_, err := client.Execute(*httpx.NewRequest(http.MethodPost, httpx.WithJSONBody(payload{})), payload{})
+
+		assert.ErrorContains(t, err, "failed to marshal JSON body")
+	})
+
+	t.Run("should send the same JSON body on every execution", func(t *testing.T) {
+		client := httpx.NewClientWithConfig(httpx.WithClientDefaultBaseURL(server.URL))
+		req := httpx.NewRequest(http.MethodPost, httpx.WithJSONBody(payload{Name: "ada"}))
+
+		for range 2 {
+			resp, err := client.Execute(*req, "")
+			require.NoError(t, err)
+			assert.JSONEq(t, `{"name":"ada"}`, resp.Body.(string))
+		}
+	})
+}
+
+// BenchmarkJSONCodec measures decoding a large response through the client. Compare engines by adding one, e.g.
+// {"jsoniter", jsoniter.ConfigCompatibleWithStandardLibrary}, and running with -benchmem.
+func BenchmarkJSONCodec(b *testing.B) {
+	type item struct {
+		ID     int               `json:"id"`
+		Name   string            `json:"name"`
+		Tags   []string          `json:"tags"`
+		Attrs  map[string]string `json:"attrs"`
+		Active bool              `json:"active"`
+	}
+	items := make([]item, 5000)
+	for i := range items {
+		items[i] = item{
+			ID:     i,
+			Name:   "item-" + strconv.Itoa(i),
+			Tags:   []string{"a", "b", "c"},
+			Attrs:  map[string]string{"color": "blue", "size": strings.Repeat("x", 16)},
+			Active: i%2 == 0,
+		}
+	}
+	body, err := json.Marshal(items)
+	require.NoError(b, err)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write(body)
+	}))
+	defer server.Close()
+
+	codecs := []struct {
+		name  string
+		codec httpx.JSONCodec
+	}{
+		{"encoding/json", nil},
+		{"codec", httpx.JSONCodecFuncs{MarshalFunc: json.Marshal, UnmarshalFunc: json.Unmarshal}},
+	}
+	for _, tc := range codecs {
+		b.Run(tc.name, func(b *testing.B) {
+			client := httpx.NewClientWithConfig(httpx.WithClientDefaultBaseURL(server.URL), httpx.WithClientJSONCodec(tc.codec))
+			b.SetBytes(int64(len(body)))
+			b.ReportAllocs()
+			for b.Loop() {
+				_, err := client.Execute(*httpx.NewRequest(http.MethodGet), []item{})
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
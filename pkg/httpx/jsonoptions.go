@@ -0,0 +1,175 @@
+package httpx
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"io"
+	"reflect"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// JSONOptions configures how response bodies are decoded into the response type
+// The zero value decodes as json.Unmarshal does: unknown fields are ignored, numbers decode into any as
+// float64 and object keys match struct fields case-insensitively.
+type JSONOptions struct {
+	DisallowUnknownFields bool // Fail on object keys matching no field of the target struct
+	UseNumber             bool // Decode numbers into any as json.Number, keeping large integers exact
+	CaseSensitive         bool // Match object keys to struct fields exactly, ignoring keys matching in another case
+}
+
+var (
+	jsonUnmarshalerType = reflect.TypeFor[json.Unmarshaler]()
+	textUnmarshalerType = reflect.TypeFor[encoding.TextUnmarshaler]()
+)
+
+// unmarshal decodes data into target, a pointer, with the options
+func (o JSONOptions) unmarshal(data []byte, target any) error {
+	if o == (JSONOptions{}) {
+		return json.Unmarshal(data, target)
+	}
+
+	if o.CaseSensitive {
+		var err error
+		if data, err = o.matchCase(data, reflect.TypeOf(target)); err != nil {
+			return err
+		}
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	if o.DisallowUnknownFields {
+		decoder.DisallowUnknownFields()
+	}
+	if o.UseNumber {
+		decoder.UseNumber()
+	}
+	if err := decoder.Decode(target); err != nil {
+		return err
+	}
+	// Reject trailing data as json.Unmarshal does
+	if _, err := decoder.Token(); !errors.Is(err, io.EOF) {
+		return errors.New("invalid data after top-level value")
+	}
+	return nil
+}
+
+// matchCase removes the object keys of data matching a field of typ only in another case, or rejects them
+// when unknown fields are disallowed, so the decoder only matches keys in their exact case
+func (o JSONOptions) matchCase(data []byte, typ reflect.Type) ([]byte, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	changed, err := o.matchValueCase(value, typ)
+	if err != nil || !changed {
+		return data, err
+	}
+	return json.Marshal(value)
+}
+
+// matchValueCase applies matchCase to a decoded value, reporting whether it removed keys
+func (o JSONOptions) matchValueCase(value any, typ reflect.Type) (bool, error) {
+	for typ.Kind() == reflect.Pointer {
+		if typ.Implements(jsonUnmarshalerType) || typ.Implements(textUnmarshalerType) {
+			return false, nil
+		}
+		typ = typ.Elem()
+	}
+	if reflect.PointerTo(typ).Implements(jsonUnmarshalerType) || reflect.PointerTo(typ).Implements(textUnmarshalerType) {
+		return false, nil
+	}
+
+	changed := false
+	switch typ.Kind() {
+	case reflect.Struct:
+		object, ok := value.(map[string]any)
+		if !ok {
+			return false, nil
+		}
+		fields := jsonFields(typ)
+		for key, item := range object {
+			if field, ok := fields[key]; ok {
+				itemChanged, err := o.matchValueCase(item, field)
+				if err != nil {
+					return false, err
+				}
+				changed = changed || itemChanged
+				continue
+			}
+			for name := range fields {
+				if strings.EqualFold(name, key) {
+					if o.DisallowUnknownFields {
+						return false, errors.Errorf("json: unknown field %q, did you mean %q", key, name)
+					}
+					delete(object, key)
+					changed = true
+					break
+				}
+			}
+		}
+	case reflect.Map:
+		object, ok := value.(map[string]any)
+		if !ok {
+			return false, nil
+		}
+		for _, item := range object {
+			itemChanged, err := o.matchValueCase(item, typ.Elem())
+			if err != nil {
+				return false, err
+			}
+			changed = changed || itemChanged
+		}
+	case reflect.Slice, reflect.Array:
+		items, ok := value.([]any)
+		if !ok {
+			return false, nil
+		}
+		for _, item := range items {
+			itemChanged, err := o.matchValueCase(item, typ.Elem())
+			if err != nil {
+				return false, err
+			}
+			changed = changed || itemChanged
+		}
+	}
+	return changed, nil
+}
+
+// jsonFields returns the types of the fields of a struct by JSON name, including promoted fields of
+// embedded structs
+func jsonFields(typ reflect.Type) map[string]reflect.Type {
+	fields := make(map[string]reflect.Type)
+	for i := range typ.NumField() {
+		field := typ.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+		fieldType := field.Type
+		if field.Anonymous && name == "" {
+			for fieldType.Kind() == reflect.Pointer {
+				fieldType = fieldType.Elem()
+			}
+			if fieldType.Kind() == reflect.Struct {
+				for promoted, promotedType := range jsonFields(fieldType) {
+					if _, ok := fields[promoted]; !ok {
+						fields[promoted] = promotedType
+					}
+				}
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		fields[name] = fieldType
+	}
+	return fields
+}
@@ -0,0 +1,121 @@
+package httpx_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bdpiprava/easy-http/pkg/httpx"
+)
+
+type jsonAudit struct {
+	By string `json:"by"`
+}
+
+type jsonUser struct {
+	jsonAudit
+	ID    int64          `json:"id"`
+	Name  string         `json:"name"`
+	Tags  []jsonUserTag  `json:"tags"`
+	Extra map[string]any `json:"extra"`
+}
+
+type jsonUserTag struct {
+	Label string `json:"label"`
+}
+
+func TestJSONOptions(t *testing.T) {
+	var body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(body))
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name    string
+		options httpx.JSONOptions
+		body    string
+		want    jsonUser
+		wantErr string
+	}{
+		{
+			name: "should decode leniently by default",
+			body: `{"ID":1,"NAME":"ada","unknown":true}`,
+			want: jsonUser{ID: 1, Name: "ada"},
+		},
+		{
+			name:    "should reject unknown fields",
+			options: httpx.JSONOptions{DisallowUnknownFields: true},
+			body:    `{"id":1,"unknown":true}`,
+			wantErr: `unknown field "unknown"`,
+		},
+		{
+			name:    "should accept known fields of embedded structs when unknown fields are rejected",
+			options: httpx.JSONOptions{DisallowUnknownFields: true},
+			body:    `{"id":1,"by":"admin"}`,
+			want:    jsonUser{jsonAudit: jsonAudit{By: "admin"}, ID: 1},
+		},
+		{
+			name:    "should ignore keys in another case when case-sensitive",
+			options: httpx.JSONOptions{CaseSensitive: true},
+			body:    `{"id":1,"Name":"ada","tags":[{"Label":"x"},{"label":"y"}],"BY":"root"}`,
+			want:    jsonUser{ID: 1, Tags: []jsonUserTag{{}, {Label: "y"}}},
+		},
+		{
+			name:    "should reject keys in another case when case-sensitive and strict",
+			options: httpx.JSONOptions{CaseSensitive: true, DisallowUnknownFields: true},
+			body:    `{"id":1,"Name":"ada"}`,
+			wantErr: `unknown field "Name", did you mean "name"`,
+		},
+		{
+			name:    "should keep large numbers exact with UseNumber",
+			options: httpx.JSONOptions{UseNumber: true, CaseSensitive: true},
+			body:    `{"id":9007199254740993,"extra":{"big":9007199254740993}}`,
+			want:    jsonUser{ID: 9007199254740993, Extra: map[string]any{"big": json.Number("9007199254740993")}},
+		},
+		{
+			name:    "should reject trailing data",
+			options: httpx.JSONOptions{UseNumber: true},
+			body:    `{"id":1} {}`,
+			wantErr: "invalid data after top-level value",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			body = tc.body
+			client := httpx.NewClientWithConfig(
+				httpx.WithClientDefaultBaseURL(server.URL),
+				httpx.WithClientJSONOptions(tc.options),
+			)
+
+			resp, err := client.Execute(*httpx.NewRequest(http.MethodGet), jsonUser{})
+
+			if tc.wantErr != "" {
+				assert.ErrorIs(t, err, httpx.ErrDecode)
+				assert.ErrorContains(t, err, tc.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, resp.Body)
+		})
+	}
+
+	t.Run("should decode numbers of untyped responses as json.Number", func(t *testing.T) {
+		body = `{"count":12345678901234567890}`
+		client := httpx.NewClientWithConfig(
+			httpx.WithClientDefaultBaseURL(server.URL),
+			httpx.WithClientJSONOptions(httpx.JSONOptions{UseNumber: true}),
+		)
+
+		resp, err := client.Execute(*httpx.NewRequest(http.MethodGet), nil)
+
+		require.NoError(t, err)
+		assert.Equal(t, map[string]any{"count": json.Number("12345678901234567890")}, resp.Body)
+	})
+}
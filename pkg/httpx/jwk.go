@@ -0,0 +1,265 @@
+package httpx
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// JSONWebKey represents a single public key in JWK format (RFC 7517)
+type JSONWebKey struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid,omitempty"`
+	Algorithm string `json:"alg,omitempty"`
+	Use       string `json:"use,omitempty"`
+	Curve     string `json:"crv,omitempty"`
+	N         string `json:"n,omitempty"`
+	E         string `json:"e,omitempty"`
+	X         string `json:"x,omitempty"`
+	Y         string `json:"y,omitempty"`
+}
+
+// JSONWebKeySet represents a set of JWKs as served by a JWKS endpoint
+type JSONWebKeySet struct {
+	Keys []JSONWebKey `json:"keys"`
+}
+
+// Key returns the key with the given key ID
+func (s JSONWebKeySet) Key(kid string) (JSONWebKey, bool) {
+	for _, key := range s.Keys {
+		if key.KeyID == kid {
+			return key, true
+		}
+	}
+	return JSONWebKey{}, false
+}
+
+// PublicKey converts the JWK into a crypto.PublicKey (*rsa.PublicKey, *ecdsa.PublicKey or ed25519.PublicKey)
+func (k JSONWebKey) PublicKey() (crypto.PublicKey, error) {
+	switch k.KeyType {
+	case "RSA":
+		n, err := decodeBase64URLInt(k.N)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid RSA modulus")
+		}
+		e, err := decodeBase64URLInt(k.E)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid RSA exponent")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+
+	case "EC":
+		curve, err := ellipticCurve(k.Curve)
+		if err != nil {
+			return nil, err
+		}
+		x, err := decodeBase64URLInt(k.X)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid EC x coordinate")
+		}
+		y, err := decodeBase64URLInt(k.Y)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid EC y coordinate")
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, errors.New("EC point is not on curve")
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+
+	case "OKP":
+		if k.Curve != "Ed25519" {
+			return nil, errors.Errorf("unsupported OKP curve: %s", k.Curve)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid Ed25519 public key")
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid Ed25519 public key size")
+		}
+		return ed25519.PublicKey(x), nil
+
+	default:
+		return nil, errors.Errorf("unsupported key type: %s", k.KeyType)
+	}
+}
+
+// NewJSONWebKey creates a JWK from a public key
+func NewJSONWebKey(publicKey crypto.PublicKey, kid string) (JSONWebKey, error) {
+	switch key := publicKey.(type) {
+	case *rsa.PublicKey:
+		return JSONWebKey{
+			KeyType: "RSA",
+			KeyID:   kid,
+			N:       base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			E:       base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}, nil
+
+	case *ecdsa.PublicKey:
+		size := (key.Curve.Params().BitSize + 7) / 8
+		return JSONWebKey{
+			KeyType: "EC",
+			KeyID:   kid,
+			Curve:   key.Curve.Params().Name,
+			X:       base64.RawURLEncoding.EncodeToString(key.X.FillBytes(make([]byte, size))),
+			Y:       base64.RawURLEncoding.EncodeToString(key.Y.FillBytes(make([]byte, size))),
+		}, nil
+
+	case ed25519.PublicKey:
+		return JSONWebKey{
+			KeyType: "OKP",
+			KeyID:   kid,
+			Curve:   "Ed25519",
+			X:       base64.RawURLEncoding.EncodeToString(key),
+		}, nil
+
+	default:
+		return JSONWebKey{}, errors.Errorf("unsupported public key type: %T", publicKey)
+	}
+}
+
+// ellipticCurve maps a JWK curve name to an elliptic curve
+func ellipticCurve(name string) (elliptic.Curve, error) {
+	switch name {
+	case "P-256":
+		return elliptic.P256(), nil
+	case "P-384":
+		return elliptic.P384(), nil
+	case "P-521":
+		return elliptic.P521(), nil
+	default:
+		return nil, errors.Errorf("unsupported EC curve: %s", name)
+	}
+}
+
+// decodeBase64URLInt decodes an unpadded base64url big-endian integer
+func decodeBase64URLInt(value string) (*big.Int, error) {
+	if value == "" {
+		return nil, errors.New("value cannot be empty")
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(raw), nil
+}
+
+// JWKSProvider supplies public keys used to verify signatures
+type JWKSProvider interface {
+	// Key returns the public key for the given key ID
+	Key(ctx context.Context, kid string) (JSONWebKey, error)
+}
+
+// StaticJWKS is a JWKSProvider backed by a fixed key set
+type StaticJWKS JSONWebKeySet
+
+// Key implements JWKSProvider
+func (s StaticJWKS) Key(_ context.Context, kid string) (JSONWebKey, error) {
+	if key, ok := JSONWebKeySet(s).Key(kid); ok {
+		return key, nil
+	}
+	return JSONWebKey{}, errors.Errorf("key not found: %s", kid)
+}
+
+// RemoteJWKS is a JWKSProvider that fetches and caches keys from a JWKS URL
+// Unknown key IDs trigger a refresh (at most once per minute) to pick up rotated keys
+type RemoteJWKS struct {
+	url                string
+	client             *http.Client
+	ttl                time.Duration
+	minRefreshInterval time.Duration
+
+	mu          sync.Mutex
+	keys        JSONWebKeySet
+	fetchedAt   time.Time
+	lastAttempt time.Time
+}
+
+// NewRemoteJWKS creates a JWKS provider for the given URL
+// A nil client uses http.DefaultClient; a zero ttl defaults to one hour
+func NewRemoteJWKS(jwksURL string, client *http.Client, ttl time.Duration) *RemoteJWKS {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	if ttl == 0 {
+		ttl = time.Hour
+	}
+	return &RemoteJWKS{
+		url:                jwksURL,
+		client:             client,
+		ttl:                ttl,
+		minRefreshInterval: time.Minute,
+	}
+}
+
+// Key implements JWKSProvider
+func (r *RemoteJWKS) Key(ctx context.Context, kid string) (JSONWebKey, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	stale := r.fetchedAt.IsZero() || now.Sub(r.fetchedAt) > r.ttl
+	if !stale {
+		if key, ok := r.keys.Key(kid); ok {
+			return key, nil
+		}
+	}
+
+	// Refresh on expiry or on unknown kid, but don't hammer the JWKS endpoint
+	if stale || now.Sub(r.lastAttempt) >= r.minRefreshInterval {
+		r.lastAttempt = now
+		keys, err := r.fetch(ctx)
+		if err != nil {
+			return JSONWebKey{}, err
+		}
+		r.keys = keys
+		r.fetchedAt = now
+	}
+
+	if key, ok := r.keys.Key(kid); ok {
+		return key, nil
+	}
+	return JSONWebKey{}, errors.Errorf("key not found: %s", kid)
+}
+
+// fetch downloads the key set from the JWKS URL
+func (r *RemoteJWKS) fetch(ctx context.Context) (JSONWebKeySet, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, r.url, nil)
+	if err != nil {
+		return JSONWebKeySet{}, errors.Wrap(err, "failed to create JWKS request")
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return JSONWebKeySet{}, errors.Wrap(err, "failed to fetch JWKS")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return JSONWebKeySet{}, errors.Errorf("unexpected JWKS response status: %d", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return JSONWebKeySet{}, errors.Wrap(err, "failed to read JWKS")
+	}
+
+	var keys JSONWebKeySet
+	if err := json.Unmarshal(body, &keys); err != nil {
+		return JSONWebKeySet{}, errors.Wrap(err, "failed to parse JWKS")
+	}
+	return keys, nil
+}
@@ -0,0 +1,316 @@
+package httpx
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	// defaultJWSSignatureHeader is the response header carrying the detached JWS
+	defaultJWSSignatureHeader = "X-JWS-Signature"
+)
+
+// jwsContextKey is the context key used to attach verified signatures to responses
+type jwsContextKey struct{}
+
+// JWSVerificationConfig configures response signature verification
+type JWSVerificationConfig struct {
+	JWKSURL           string        // URL of the partner's JWKS endpoint
+	KeyProvider       JWKSProvider  // Optional key provider (overrides JWKSURL)
+	HTTPClient        *http.Client  // HTTP client used to fetch the JWKS (default: http.DefaultClient)
+	CacheTTL          time.Duration // How long fetched keys are cached (default: 1 hour)
+	Header            string        // Response header containing the detached JWS (default: X-JWS-Signature)
+	AllowedAlgorithms []string      // Accepted JWS algorithms (default: RS256, RS384, RS512, PS256, PS384, PS512, ES256, ES384, ES512, EdDSA)
+	AllowUnsigned     bool          // If true, responses without a signature header pass through unverified
+	SkipVerifyFor     func(*http.Response) bool
+}
+
+// VerifiedSignature holds the details of a successfully verified response signature
+type VerifiedSignature struct {
+	KeyID     string         // Key ID used to verify the signature
+	Algorithm string         // JWS algorithm of the signature
+	Header    map[string]any // Decoded JWS protected header
+	Claims    map[string]any // Claims from the signed payload (nil if the payload is not a JSON object)
+}
+
+// SignatureVerificationError is returned when a response signature is missing or invalid
+type SignatureVerificationError struct {
+	Reason string // Why verification failed
+	KeyID  string // Key ID from the JWS header (may be empty)
+	Cause  error  // Underlying error (may be nil)
+}
+
+// Error implements the error interface
+func (e *SignatureVerificationError) Error() string {
+	if e.Cause != nil {
+		return fmt.Sprintf("response signature verification failed: %s: %v", e.Reason, e.Cause)
+	}
+	return fmt.Sprintf("response signature verification failed: %s", e.Reason)
+}
+
+// Unwrap implements the unwrapper interface for error chains
+func (e *SignatureVerificationError) Unwrap() error {
+	return e.Cause
+}
+
+// IsSignatureVerificationError checks if an error was caused by a failed response signature verification
+func IsSignatureVerificationError(err error) bool {
+	verificationErr := &SignatureVerificationError{}
+	return errors.As(err, &verificationErr)
+}
+
+// JWSVerificationMiddleware verifies detached JWS signatures on responses
+type JWSVerificationMiddleware struct {
+	config JWSVerificationConfig
+}
+
+// NewJWSVerificationMiddleware creates a new response signature verification middleware
+func NewJWSVerificationMiddleware(config JWSVerificationConfig) *JWSVerificationMiddleware {
+	if config.Header == "" {
+		config.Header = defaultJWSSignatureHeader
+	}
+	if len(config.AllowedAlgorithms) == 0 {
+		config.AllowedAlgorithms = []string{
+			"RS256", "RS384", "RS512",
+			"PS256", "PS384", "PS512",
+			"ES256", "ES384", "ES512",
+			"EdDSA",
+		}
+	}
+	if config.KeyProvider == nil {
+		config.KeyProvider = NewRemoteJWKS(config.JWKSURL, config.HTTPClient, config.CacheTTL)
+	}
+	return &JWSVerificationMiddleware{config: config}
+}
+
+// Name returns the middleware name
+func (m *JWSVerificationMiddleware) Name() string {
+	return "jws-verification"
+}
+
+// Execute implements the Middleware interface
+func (m *JWSVerificationMiddleware) Execute(ctx context.Context, req *http.Request, next MiddlewareFunc) (*http.Response, error) {
+	resp, err := next(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	if m.config.SkipVerifyFor != nil && m.config.SkipVerifyFor(resp) {
+		return resp, nil
+	}
+
+	signature := resp.Header.Get(m.config.Header)
+	if signature == "" {
+		if m.config.AllowUnsigned {
+			return resp, nil
+		}
+		return m.fail(resp, req, &SignatureVerificationError{Reason: "missing signature header " + m.config.Header})
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, MiddlewareError("failed to read response body for signature verification", err, req)
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	verified, verifyErr := m.verify(ctx, signature, body)
+	if verifyErr != nil {
+		return m.fail(resp, req, verifyErr)
+	}
+
+	// Attach the verified signature so callers can access claims via Response.VerifiedSignature
+	if resp.Request == nil {
+		resp.Request = req
+	}
+	resp.Request = resp.Request.WithContext(context.WithValue(resp.Request.Context(), jwsContextKey{}, verified))
+
+	return resp, nil
+}
+
+// fail closes the response body and wraps the verification error
+func (m *JWSVerificationMiddleware) fail(resp *http.Response, req *http.Request, verifyErr *SignatureVerificationError) (*http.Response, error) {
+	resp.Body.Close()
+	httpErr := MiddlewareError(verifyErr.Error(), verifyErr, req)
+	httpErr.Response = resp
+	httpErr.StatusCode = resp.StatusCode
+	return nil, httpErr
+}
+
+// verify checks a detached compact JWS (header..signature) against the response body
+func (m *JWSVerificationMiddleware) verify(ctx context.Context, signature string, body []byte) (*VerifiedSignature, *SignatureVerificationError) {
+	parts := strings.Split(signature, ".")
+	if len(parts) != 3 {
+		return nil, &SignatureVerificationError{Reason: "malformed JWS"}
+	}
+	if parts[1] != "" && parts[1] != base64.RawURLEncoding.EncodeToString(body) {
+		return nil, &SignatureVerificationError{Reason: "embedded payload does not match response body"}
+	}
+
+	headerJSON, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, &SignatureVerificationError{Reason: "malformed JWS header", Cause: err}
+	}
+	var header map[string]any
+	if err := json.Unmarshal(headerJSON, &header); err != nil {
+		return nil, &SignatureVerificationError{Reason: "malformed JWS header", Cause: err}
+	}
+
+	alg, _ := header["alg"].(string)
+	kid, _ := header["kid"].(string)
+	if !slices.Contains(m.config.AllowedAlgorithms, alg) {
+		return nil, &SignatureVerificationError{Reason: fmt.Sprintf("algorithm %q not allowed", alg), KeyID: kid}
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, &SignatureVerificationError{Reason: "malformed JWS signature", KeyID: kid, Cause: err}
+	}
+
+	jwk, err := m.config.KeyProvider.Key(ctx, kid)
+	if err != nil {
+		return nil, &SignatureVerificationError{Reason: "signing key not available", KeyID: kid, Cause: err}
+	}
+	publicKey, err := jwk.PublicKey()
+	if err != nil {
+		return nil, &SignatureVerificationError{Reason: "invalid signing key", KeyID: kid, Cause: err}
+	}
+
+	// RFC 7797: an unencoded payload is signed as-is instead of base64url encoded
+	payload := base64.RawURLEncoding.EncodeToString(body)
+	if b64, ok := header["b64"].(bool); ok && !b64 {
+		payload = string(body)
+	}
+	signingInput := []byte(parts[0] + "." + payload)
+
+	if err := verifyJWS(alg, publicKey, signingInput, sig); err != nil {
+		return nil, &SignatureVerificationError{Reason: "signature mismatch", KeyID: kid, Cause: err}
+	}
+
+	verified := &VerifiedSignature{
+		KeyID:     kid,
+		Algorithm: alg,
+		Header:    header,
+	}
+	var claims map[string]any
+	if json.Unmarshal(body, &claims) == nil {
+		verified.Claims = claims
+	}
+	return verified, nil
+}
+
+// VerifiedSignature returns the verified response signature, if the response was verified
+func (r *Response) VerifiedSignature() (*VerifiedSignature, bool) {
+	if r.httpResponse == nil || r.httpResponse.Request == nil {
+		return nil, false
+	}
+	verified, ok := r.httpResponse.Request.Context().Value(jwsContextKey{}).(*VerifiedSignature)
+	return verified, ok
+}
+
+// jwsHash returns the hash function used by a JWS algorithm
+func jwsHash(alg string) (crypto.Hash, error) {
+	switch alg {
+	case "RS256", "PS256", "ES256":
+		return crypto.SHA256, nil
+	case "RS384", "PS384", "ES384":
+		return crypto.SHA384, nil
+	case "RS512", "PS512", "ES512":
+		return crypto.SHA512, nil
+	default:
+		return 0, fmt.Errorf("unsupported algorithm: %s", alg)
+	}
+}
+
+// jwsCurve returns the curve of the keys of an ECDSA JWS algorithm, nil for other algorithms
+func jwsCurve(alg string) elliptic.Curve {
+	switch alg {
+	case "ES256":
+		return elliptic.P256()
+	case "ES384":
+		return elliptic.P384()
+	case "ES512":
+		return elliptic.P521()
+	default:
+		return nil
+	}
+}
+
+// verifyJWS verifies a JWS signature over the signing input
+func verifyJWS(alg string, publicKey crypto.PublicKey, signingInput, sig []byte) error {
+	if alg == "EdDSA" {
+		key, ok := publicKey.(ed25519.PublicKey)
+		if !ok {
+			return errors.New("key type does not match algorithm")
+		}
+		if !ed25519.Verify(key, signingInput, sig) {
+			return errors.New("invalid signature")
+		}
+		return nil
+	}
+
+	if len(alg) != 5 {
+		return fmt.Errorf("unsupported algorithm: %s", alg)
+	}
+	hash, err := jwsHash(alg)
+	if err != nil {
+		return err
+	}
+	hasher := hash.New()
+	hasher.Write(signingInput)
+	digest := hasher.Sum(nil)
+
+	switch alg[:2] {
+	case "RS":
+		key, ok := publicKey.(*rsa.PublicKey)
+		if !ok {
+			return errors.New("key type does not match algorithm")
+		}
+		return rsa.VerifyPKCS1v15(key, hash, digest, sig)
+
+	case "PS":
+		key, ok := publicKey.(*rsa.PublicKey)
+		if !ok {
+			return errors.New("key type does not match algorithm")
+		}
+		return rsa.VerifyPSS(key, hash, digest, sig, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash})
+
+	case "ES":
+		key, ok := publicKey.(*ecdsa.PublicKey)
+		if !ok {
+			return errors.New("key type does not match algorithm")
+		}
+		if key.Curve != jwsCurve(alg) {
+			return errors.New("key curve does not match algorithm")
+		}
+		// JWS encodes ECDSA signatures as the fixed-size concatenation R || S
+		size := (key.Curve.Params().BitSize + 7) / 8
+		if len(sig) != 2*size {
+			return errors.New("invalid signature length")
+		}
+		r := new(big.Int).SetBytes(sig[:size])
+		s := new(big.Int).SetBytes(sig[size:])
+		if !ecdsa.Verify(key, digest, r, s) {
+			return errors.New("invalid signature")
+		}
+		return nil
+
+	default:
+		return fmt.Errorf("unsupported algorithm: %s", alg)
+	}
+}
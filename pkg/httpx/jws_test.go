@@ -0,0 +1,282 @@
+package httpx_test
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bdpiprava/easy-http/pkg/httpx"
+)
+
+// signDetachedJWS produces a detached compact JWS (header..signature) over the payload
+func signDetachedJWS(t *testing.T, alg, kid string, key crypto.Signer, payload []byte) string {
+	t.Helper()
+
+	header, err := json.Marshal(map[string]any{"alg": alg, "kid": kid})
+	require.NoError(t, err)
+	protected := base64.RawURLEncoding.EncodeToString(header)
+	signingInput := protected + "." + base64.RawURLEncoding.EncodeToString(payload)
+
+	var sig []byte
+	switch k := key.(type) {
+	case ed25519.PrivateKey:
+		sig = ed25519.Sign(k, []byte(signingInput))
+	case *rsa.PrivateKey:
+		digest := sha256.Sum256([]byte(signingInput))
+		sig, err = rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, digest[:])
+		require.NoError(t, err)
+	case *ecdsa.PrivateKey:
+		digest := sha256.Sum256([]byte(signingInput))
+		r, s, signErr := ecdsa.Sign(rand.Reader, k, digest[:])
+		require.NoError(t, signErr)
+		size := (k.Curve.Params().BitSize + 7) / 8
+		sig = append(r.FillBytes(make([]byte, size)), s.FillBytes(make([]byte, size))...)
+	}
+
+	return protected + ".." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func newJWKSServer(t *testing.T, keys ...httpx.JSONWebKey) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+
+	var hits atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		hits.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(httpx.JSONWebKeySet{Keys: keys})
+	}))
+	t.Cleanup(server.Close)
+	return server, &hits
+}
+
+func TestJSONWebKey_PublicKeyRoundTrip(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	require.NoError(t, err)
+	edPub, _, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	for name, pub := range map[string]crypto.PublicKey{
+		"rsa":     &rsaKey.PublicKey,
+		"ecdsa":   &ecKey.PublicKey,
+		"ed25519": edPub,
+	} {
+		t.Run(name, func(t *testing.T) {
+			jwk, err := httpx.NewJSONWebKey(pub, "kid-1")
+			require.NoError(t, err)
+			assert.Equal(t, "kid-1", jwk.KeyID)
+
+			decoded, err := jwk.PublicKey()
+			require.NoError(t, err)
+			assert.True(t, decoded.(interface{ Equal(crypto.PublicKey) bool }).Equal(pub))
+		})
+	}
+
+	t.Run("unsupported key type", func(t *testing.T) {
+		_, err := httpx.JSONWebKey{KeyType: "oct"}.PublicKey()
+		assert.Error(t, err)
+	})
+}
+
+func TestJWSVerificationMiddleware(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	edPub, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	ecJWK, err := httpx.NewJSONWebKey(&ecKey.PublicKey, "ec-key")
+	require.NoError(t, err)
+	rsaJWK, err := httpx.NewJSONWebKey(&rsaKey.PublicKey, "rsa-key")
+	require.NoError(t, err)
+	edJWK, err := httpx.NewJSONWebKey(edPub, "ed-key")
+	require.NoError(t, err)
+
+	jwksServer, jwksHits := newJWKSServer(t, ecJWK, rsaJWK, edJWK)
+	payload := []byte(`{"account":"acc-1","balance":100}`)
+
+	newAPIServer := func(signature string, body []byte) *httptest.Server {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			if signature != "" {
+				w.Header().Set("X-JWS-Signature", signature)
+			}
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write(body)
+		}))
+		t.Cleanup(server.Close)
+		return server
+	}
+
+	tests := []struct {
+		name string
+		alg  string
+		kid  string
+		key  crypto.Signer
+	}{
+		{name: "ES256", alg: "ES256", kid: "ec-key", key: ecKey},
+		{name: "RS256", alg: "RS256", kid: "rsa-key", key: rsaKey},
+		{name: "EdDSA", alg: "EdDSA", kid: "ed-key", key: edKey},
+	}
+
+	for _, tt := range tests {
+		t.Run("verifies "+tt.name+" signature and exposes claims", func(t *testing.T) {
+			server := newAPIServer(signDetachedJWS(t, tt.alg, tt.kid, tt.key, payload), payload)
+			client := httpx.NewClientWithConfig(
+				httpx.WithClientDefaultBaseURL(server.URL),
+				httpx.WithClientResponseSignatureVerification(httpx.JWSVerificationConfig{JWKSURL: jwksServer.URL}),
+			)
+
+			resp, err := client.Execute(*httpx.NewRequest(http.MethodGet), map[string]any{})
+
+			require.NoError(t, err)
+			verified, ok := resp.VerifiedSignature()
+			require.True(t, ok)
+			assert.Equal(t, tt.kid, verified.KeyID)
+			assert.Equal(t, tt.alg, verified.Algorithm)
+			assert.Equal(t, "acc-1", verified.Claims["account"])
+			assert.Equal(t, map[string]any{"account": "acc-1", "balance": float64(100)}, resp.Body)
+		})
+	}
+
+	t.Run("fails with typed error on tampered body", func(t *testing.T) {
+		server := newAPIServer(signDetachedJWS(t, "ES256", "ec-key", ecKey, payload), []byte(`{"account":"acc-1","balance":999}`))
+		client := httpx.NewClientWithConfig(
+			httpx.WithClientDefaultBaseURL(server.URL),
+			httpx.WithClientResponseSignatureVerification(httpx.JWSVerificationConfig{JWKSURL: jwksServer.URL}),
+		)
+
+		_, err := client.Execute(*httpx.NewRequest(http.MethodGet), map[string]any{})
+
+		require.Error(t, err)
+		assert.True(t, httpx.IsSignatureVerificationError(err))
+		assert.True(t, httpx.IsMiddlewareError(err))
+	})
+
+	t.Run("fails when signature header is missing", func(t *testing.T) {
+		server := newAPIServer("", payload)
+		client := httpx.NewClientWithConfig(
+			httpx.WithClientDefaultBaseURL(server.URL),
+			httpx.WithClientResponseSignatureVerification(httpx.JWSVerificationConfig{JWKSURL: jwksServer.URL}),
+		)
+
+		_, err := client.Execute(*httpx.NewRequest(http.MethodGet), map[string]any{})
+
+		require.Error(t, err)
+		assert.True(t, httpx.IsSignatureVerificationError(err))
+	})
+
+	t.Run("allows unsigned responses when configured", func(t *testing.T) {
+		server := newAPIServer("", payload)
+		client := httpx.NewClientWithConfig(
+			httpx.WithClientDefaultBaseURL(server.URL),
+			httpx.WithClientResponseSignatureVerification(httpx.JWSVerificationConfig{
+				JWKSURL:       jwksServer.URL,
+				AllowUnsigned: true,
+			}),
+		)
+
+		resp, err := client.Execute(*httpx.NewRequest(http.MethodGet), map[string]any{})
+
+		require.NoError(t, err)
+		_, ok := resp.VerifiedSignature()
+		assert.False(t, ok)
+	})
+
+	t.Run("rejects disallowed algorithm", func(t *testing.T) {
+		server := newAPIServer(signDetachedJWS(t, "RS256", "rsa-key", rsaKey, payload), payload)
+		client := httpx.NewClientWithConfig(
+			httpx.WithClientDefaultBaseURL(server.URL),
+			httpx.WithClientResponseSignatureVerification(httpx.JWSVerificationConfig{
+				JWKSURL:           jwksServer.URL,
+				AllowedAlgorithms: []string{"ES256"},
+			}),
+		)
+
+		_, err := client.Execute(*httpx.NewRequest(http.MethodGet), map[string]any{})
+
+		verificationErr := &httpx.SignatureVerificationError{}
+		require.ErrorAs(t, err, &verificationErr)
+		assert.Equal(t, "rsa-key", verificationErr.KeyID)
+	})
+
+	t.Run("rejects a key on another curve than the algorithm", func(t *testing.T) {
+		p384Key, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+		require.NoError(t, err)
+		p384JWK, err := httpx.NewJSONWebKey(&p384Key.PublicKey, "p384-key")
+		require.NoError(t, err)
+		server := newAPIServer(signDetachedJWS(t, "ES256", "p384-key", p384Key, payload), payload)
+		client := httpx.NewClientWithConfig(
+			httpx.WithClientDefaultBaseURL(server.URL),
+			httpx.WithClientResponseSignatureVerification(httpx.JWSVerificationConfig{
+				KeyProvider: httpx.StaticJWKS{Keys: []httpx.JSONWebKey{p384JWK}},
+			}),
+		)
+
+		_, err = client.Execute(*httpx.NewRequest(http.MethodGet), map[string]any{})
+
+		verificationErr := &httpx.SignatureVerificationError{}
+		require.ErrorAs(t, err, &verificationErr)
+		assert.Equal(t, "p384-key", verificationErr.KeyID)
+	})
+
+	t.Run("rejects unknown algorithms", func(t *testing.T) {
+		for _, alg := range []string{"E", "ES", "ES999", "HS256"} {
+			server := newAPIServer(signDetachedJWS(t, alg, "ec-key", ecKey, payload), payload)
+			client := httpx.NewClientWithConfig(
+				httpx.WithClientDefaultBaseURL(server.URL),
+				httpx.WithClientResponseSignatureVerification(httpx.JWSVerificationConfig{
+					KeyProvider:       httpx.StaticJWKS{Keys: []httpx.JSONWebKey{ecJWK}},
+					AllowedAlgorithms: []string{alg},
+				}),
+			)
+
+			_, err := client.Execute(*httpx.NewRequest(http.MethodGet), map[string]any{})
+
+			assert.True(t, httpx.IsSignatureVerificationError(err), alg)
+		}
+	})
+
+	t.Run("caches fetched keys", func(t *testing.T) {
+		server := newAPIServer(signDetachedJWS(t, "ES256", "ec-key", ecKey, payload), payload)
+		client := httpx.NewClientWithConfig(
+			httpx.WithClientDefaultBaseURL(server.URL),
+			httpx.WithClientResponseSignatureVerification(httpx.JWSVerificationConfig{JWKSURL: jwksServer.URL}),
+		)
+
+		before := jwksHits.Load()
+		for range 3 {
+			_, err := client.Execute(*httpx.NewRequest(http.MethodGet), map[string]any{})
+			require.NoError(t, err)
+		}
+		assert.Equal(t, before+1, jwksHits.Load())
+	})
+
+	t.Run("uses static key provider", func(t *testing.T) {
+		server := newAPIServer(signDetachedJWS(t, "ES256", "ec-key", ecKey, payload), payload)
+		client := httpx.NewClientWithConfig(
+			httpx.WithClientDefaultBaseURL(server.URL),
+			httpx.WithClientResponseSignatureVerification(httpx.JWSVerificationConfig{
+				KeyProvider: httpx.StaticJWKS{Keys: []httpx.JSONWebKey{ecJWK}},
+			}),
+		)
+
+		_, err := client.Execute(*httpx.NewRequest(http.MethodGet), map[string]any{})
+
+		require.NoError(t, err)
+	})
+}
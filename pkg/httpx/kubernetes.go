@@ -0,0 +1,267 @@
+package httpx
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// Paths of the service account credentials mounted in Kubernetes pods
+const (
+	kubernetesTokenFile     = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+	kubernetesCAFile        = "/var/run/secrets/kubernetes.io/serviceaccount/ca.crt"
+	kubernetesNamespaceFile = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
+)
+
+// KubernetesResolver resolves a Kubernetes Service to the addresses of its ready pods from its EndpointSlices,
+// so requests go to pods directly instead of through kube-proxy
+//
+// It implements ResolverWatcher with the Kubernetes watch API, so pods joining or leaving apply immediately.
+// Pods that are not ready or terminating are skipped. Service names may carry the namespace as in cluster DNS,
+// e.g. "payments.prod" or "payments.prod.svc.cluster.local". The service account needs to list and watch
+// endpointslices in the namespace.
+type KubernetesResolver struct {
+	Namespace  string       // Namespace of services named without one (default: the namespace of the pod)
+	PortName   string       // Name of the EndpointSlice port to use (default: the first port)
+	APIServer  string       // API server URL (default: from KUBERNETES_SERVICE_HOST and KUBERNETES_SERVICE_PORT)
+	Token      string       // Bearer token (default: the pod service account token, read on each request as it rotates)
+	HTTPClient *http.Client // Client for the API server (default: one trusting the service account CA)
+
+	once   sync.Once
+	client *http.Client
+}
+
+// endpointSlice is the part of a discovery.k8s.io/v1 EndpointSlice used to resolve endpoints
+type endpointSlice struct {
+	Metadata struct {
+		Name            string `json:"name"`
+		ResourceVersion string `json:"resourceVersion"`
+	} `json:"metadata"`
+	Endpoints []struct {
+		Addresses  []string `json:"addresses"`
+		Conditions struct {
+			Ready       *bool `json:"ready"`
+			Terminating *bool `json:"terminating"`
+		} `json:"conditions"`
+	} `json:"endpoints"`
+	Ports []struct {
+		Name string `json:"name"`
+		Port *int   `json:"port"`
+	} `json:"ports"`
+}
+
+// endpointSliceList is a list of EndpointSlices
+type endpointSliceList struct {
+	Metadata struct {
+		ResourceVersion string `json:"resourceVersion"`
+	} `json:"metadata"`
+	Items []endpointSlice `json:"items"`
+}
+
+// endpointSliceEvent is an event of an EndpointSlice watch
+type endpointSliceEvent struct {
+	Type   string          `json:"type"`
+	Object json.RawMessage `json:"object"`
+}
+
+// Resolve implements Resolver
+func (r *KubernetesResolver) Resolve(ctx context.Context, service string) ([]Endpoint, error) {
+	list, err := r.list(ctx, service)
+	if err != nil {
+		return nil, err
+	}
+	slices := make(map[string]endpointSlice, len(list.Items))
+	for _, slice := range list.Items {
+		slices[slice.Metadata.Name] = slice
+	}
+	return r.endpoints(slices), nil
+}
+
+// Watch implements ResolverWatcher
+// It lists the EndpointSlices of the service, then follows their changes; it returns when the watch ends,
+// e.g. on the API server timeout, to be listed and watched again.
+func (r *KubernetesResolver) Watch(ctx context.Context, service string, update func([]Endpoint)) error {
+	list, err := r.list(ctx, service)
+	if err != nil {
+		return err
+	}
+	slices := make(map[string]endpointSlice, len(list.Items))
+	for _, slice := range list.Items {
+		slices[slice.Metadata.Name] = slice
+	}
+	update(r.endpoints(slices))
+
+	query := r.query(service)
+	query.Set("watch", "true")
+	query.Set("resourceVersion", list.Metadata.ResourceVersion)
+	resp, err := r.get(ctx, service, query)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	decoder := json.NewDecoder(bufio.NewReader(resp.Body))
+	for {
+		var event endpointSliceEvent
+		if err := decoder.Decode(&event); err != nil {
+			return errors.Wrap(err, "endpointslice watch ended")
+		}
+		var slice endpointSlice
+		if err := json.Unmarshal(event.Object, &slice); err != nil {
+			return errors.Wrap(err, "failed to decode endpointslice event")
+		}
+		switch event.Type {
+		case "ADDED", "MODIFIED":
+			slices[slice.Metadata.Name] = slice
+		case "DELETED":
+			delete(slices, slice.Metadata.Name)
+		case "ERROR":
+			// Typically 410 Gone once the resource version is too old, so list again
+			return errors.Errorf("endpointslice watch failed: %s", event.Object)
+		default:
+			continue
+		}
+		update(r.endpoints(slices))
+	}
+}
+
+// list lists the EndpointSlices of the service
+func (r *KubernetesResolver) list(ctx context.Context, service string) (endpointSliceList, error) {
+	var list endpointSliceList
+	resp, err := r.get(ctx, service, r.query(service))
+	if err != nil {
+		return list, err
+	}
+	defer resp.Body.Close()
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		return list, errors.Wrap(err, "failed to decode endpointslices")
+	}
+	return list, nil
+}
+
+// endpoints returns the ready, non-terminating pod addresses of the slices
+func (r *KubernetesResolver) endpoints(slices map[string]endpointSlice) []Endpoint {
+	var endpoints []Endpoint
+	for _, slice := range slices {
+		port, ok := r.port(slice)
+		if !ok {
+			continue
+		}
+		for _, endpoint := range slice.Endpoints {
+			conditions := endpoint.Conditions
+			// A nil ready condition means ready, as documented for EndpointSlices
+			if (conditions.Ready != nil && !*conditions.Ready) || (conditions.Terminating != nil && *conditions.Terminating) {
+				continue
+			}
+			for _, address := range endpoint.Addresses {
+				endpoints = append(endpoints, Endpoint{Host: address, Port: port})
+			}
+		}
+	}
+	return endpoints
+}
+
+// port returns the port of the slice named PortName, or its first port
+func (r *KubernetesResolver) port(slice endpointSlice) (int, bool) {
+	for _, port := range slice.Ports {
+		if port.Port != nil && (r.PortName == "" || port.Name == r.PortName) {
+			return *port.Port, true
+		}
+	}
+	return 0, false
+}
+
+// query returns the query selecting the EndpointSlices of the service
+func (r *KubernetesResolver) query(service string) url.Values {
+	name, _ := r.split(service)
+	return url.Values{"labelSelector": {"kubernetes.io/service-name=" + name}}
+}
+
+// split returns the service name and namespace of service
+func (r *KubernetesResolver) split(service string) (string, string) {
+	labels := strings.Split(service, ".")
+	if len(labels) > 1 {
+		return labels[0], labels[1]
+	}
+	namespace := r.Namespace
+	if namespace == "" {
+		if data, err := os.ReadFile(kubernetesNamespaceFile); err == nil {
+			namespace = strings.TrimSpace(string(data))
+		}
+	}
+	return service, namespace
+}
+
+// get sends an authenticated GET for the EndpointSlices of the service's namespace
+func (r *KubernetesResolver) get(ctx context.Context, service string, query url.Values) (*http.Response, error) {
+	apiServer := r.APIServer
+	if apiServer == "" {
+		host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+		if host == "" || port == "" {
+			return nil, errors.New("not running in Kubernetes: KUBERNETES_SERVICE_HOST is not set")
+		}
+		apiServer = "https://" + net.JoinHostPort(host, port)
+	}
+	_, namespace := r.split(service)
+	if namespace == "" {
+		return nil, errors.Errorf("no namespace for service %q", service)
+	}
+	endpoint := strings.TrimSuffix(apiServer, "/") + "/apis/discovery.k8s.io/v1/namespaces/" + url.PathEscape(namespace) +
+		"/endpointslices?" + query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create Kubernetes request")
+	}
+	token := r.Token
+	if token == "" {
+		if data, err := os.ReadFile(kubernetesTokenFile); err == nil {
+			token = strings.TrimSpace(string(data))
+		}
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := r.httpClient().Do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to query Kubernetes API")
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, errors.Errorf("kubernetes API returned status %d", resp.StatusCode)
+	}
+	return resp, nil
+}
+
+// httpClient returns HTTPClient, or a client trusting the service account CA when it is mounted
+func (r *KubernetesResolver) httpClient() *http.Client {
+	if r.HTTPClient != nil {
+		return r.HTTPClient
+	}
+	r.once.Do(func() {
+		r.client = http.DefaultClient
+		ca, err := os.ReadFile(kubernetesCAFile)
+		if err != nil {
+			return
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return
+		}
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}
+		r.client = &http.Client{Transport: transport}
+	})
+	return r.client
+}
@@ -0,0 +1,121 @@
+package httpx_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bdpiprava/easy-http/pkg/httpx"
+)
+
+// endpointSlice builds an EndpointSlice object with one pod per endpoint, ready unless listed as not ready;
+// the endpoints of a slice share their port
+func endpointSlice(name string, ready []httpx.Endpoint, notReady ...httpx.Endpoint) map[string]any {
+	var endpoints []map[string]any
+	port := 0
+	for _, e := range ready {
+		endpoints = append(endpoints, map[string]any{"addresses": []string{e.Host}})
+		port = e.Port
+	}
+	for _, e := range notReady {
+		endpoints = append(endpoints, map[string]any{
+			"addresses":  []string{e.Host},
+			"conditions": map[string]any{"ready": false, "terminating": true},
+		})
+		port = e.Port
+	}
+	return map[string]any{
+		"metadata":  map[string]any{"name": name},
+		"endpoints": endpoints,
+		"ports": []map[string]any{
+			{"name": "metrics", "port": 9090},
+			{"name": "http", "port": port},
+		},
+	}
+}
+
+func TestKubernetesResolver(t *testing.T) {
+	_, first := namedServer(t, "first")
+	_, second := namedServer(t, "second")
+
+	t.Run("should resolve ready pods of the named port", func(t *testing.T) {
+		api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "/apis/discovery.k8s.io/v1/namespaces/prod/endpointslices", r.URL.Path)
+			assert.Equal(t, "kubernetes.io/service-name=payments", r.URL.Query().Get("labelSelector"))
+			assert.Equal(t, "Bearer secret", r.Header.Get("Authorization"))
+			_ = json.NewEncoder(w).Encode(map[string]any{
+				"metadata": map[string]any{"resourceVersion": "1"},
+				"items": []any{
+					endpointSlice("payments-a", []httpx.Endpoint{first}),
+					endpointSlice("payments-b", nil, second),
+				},
+			})
+		}))
+		defer api.Close()
+
+		resolver := &httpx.KubernetesResolver{APIServer: api.URL, Token: "secret", Namespace: "default", PortName: "http"}
+		endpoints, err := resolver.Resolve(t.Context(), "payments.prod.svc.cluster.local")
+		require.NoError(t, err)
+		assert.Equal(t, []httpx.Endpoint{first}, endpoints)
+	})
+
+	t.Run("should fail when the API rejects the request", func(t *testing.T) {
+		api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusForbidden)
+		}))
+		defer api.Close()
+
+		resolver := &httpx.KubernetesResolver{APIServer: api.URL, Token: "secret", Namespace: "prod"}
+		_, err := resolver.Resolve(t.Context(), "payments")
+		assert.ErrorContains(t, err, "status 403")
+	})
+
+	t.Run("should follow pod churn with a watch", func(t *testing.T) {
+		rollout := make(chan struct{})
+		api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Query().Get("watch") != "true" {
+				_ = json.NewEncoder(w).Encode(map[string]any{
+					"metadata": map[string]any{"resourceVersion": "7"},
+					"items":    []any{endpointSlice("payments-a", []httpx.Endpoint{first})},
+				})
+				return
+			}
+			assert.Equal(t, "7", r.URL.Query().Get("resourceVersion"))
+			w.(http.Flusher).Flush()
+			select {
+			case <-rollout:
+			case <-r.Context().Done():
+				return
+			}
+			encoder := json.NewEncoder(w)
+			_ = encoder.Encode(map[string]any{"type": "ADDED", "object": endpointSlice("payments-b", []httpx.Endpoint{second})})
+			_ = encoder.Encode(map[string]any{"type": "MODIFIED", "object": endpointSlice("payments-a", nil, first)})
+			w.(http.Flusher).Flush()
+			<-r.Context().Done()
+		}))
+		defer api.Close()
+
+		client := httpx.NewClientWithConfig(httpx.WithClientServiceDiscovery(httpx.ServiceConfig{
+			Name:     "payments",
+			Resolver: &httpx.KubernetesResolver{APIServer: api.URL, Token: "secret", Namespace: "prod", PortName: "http"},
+		}))
+		defer client.ServiceDiscovery().Close()
+		get := func() string {
+			resp, err := client.Execute(*httpx.NewRequest(http.MethodGet), "")
+			require.NoError(t, err)
+			return resp.Body.(string)
+		}
+
+		assert.Equal(t, "first payments", get())
+		close(rollout)
+		assert.Eventually(t, func() bool {
+			return assert.ObjectsAreEqual([]httpx.Endpoint{second}, client.ServiceDiscovery().Endpoints())
+		}, time.Second, 10*time.Millisecond)
+		assert.Equal(t, "second payments", get())
+	})
+}
@@ -0,0 +1,200 @@
+package loadgen
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/bdpiprava/easy-http/pkg/httpx"
+)
+
+const defaultConcurrency = 10
+
+// Config describes the load to generate
+type Config struct {
+	Rate        float64                   // Requests started per second, 0 sends as fast as Concurrency allows
+	Duration    time.Duration             // How long to generate load, 0 runs until Requests are sent
+	Requests    int                       // Maximum number of requests, 0 is unlimited within Duration
+	Concurrency int                       // Maximum number of requests in flight (default: 10)
+	Request     func(i int) httpx.Request // Builds the i-th request, starting at 0
+}
+
+// Latency summarizes the latencies of the requests
+type Latency struct {
+	Min  time.Duration
+	Mean time.Duration
+	P50  time.Duration
+	P90  time.Duration
+	P95  time.Duration
+	P99  time.Duration
+	Max  time.Duration
+}
+
+// Report is the outcome of a load run
+type Report struct {
+	Requests    int            // Requests sent
+	Succeeded   int            // Requests answered with a status code below 400
+	Failed      int            // Requests failed with an error or answered with a status code of 400 or more
+	Duration    time.Duration  // Time from the first request to the last response
+	Throughput  float64        // Requests completed per second
+	Latency     Latency        // Latencies of all requests, failed ones included
+	StatusCodes map[int]int    // Number of responses per status code
+	Errors      map[string]int // Number of errors per kind, the httpx.ErrorType of the error or "unknown"
+}
+
+// String formats the report as a human-readable summary
+func (r Report) String() string {
+	return fmt.Sprintf("%d requests in %s (%.1f req/s), %d succeeded, %d failed, latency p50=%s p90=%s p99=%s max=%s",
+		r.Requests, r.Duration.Round(time.Millisecond), r.Throughput, r.Succeeded, r.Failed,
+		r.Latency.P50, r.Latency.P90, r.Latency.P99, r.Latency.Max)
+}
+
+// Run sends the requests built by config.Request with client until config.Duration elapsed or config.Requests
+// were sent, whichever comes first, and reports their latencies and outcomes. Requests go through the client
+// middleware chain, so the load exercises the same retries, rate limits and circuit breakers as production.
+// Run stops early when ctx is canceled, reporting the requests completed so far.
+func Run(ctx context.Context, client *httpx.Client, config Config) (Report, error) {
+	if config.Request == nil {
+		return Report{}, errors.New("loadgen: Request is required")
+	}
+	if config.Duration <= 0 && config.Requests <= 0 {
+		return Report{}, errors.New("loadgen: Duration or Requests is required")
+	}
+	if config.Concurrency <= 0 {
+		config.Concurrency = defaultConcurrency
+	}
+	if config.Duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, config.Duration)
+		defer cancel()
+	}
+
+	recorder := &recorder{statusCodes: make(map[int]int), errors: make(map[string]int)}
+	slots := make(chan struct{}, config.Concurrency)
+	var wg sync.WaitGroup
+	start := time.Now()
+	for i := 0; config.Requests <= 0 || i < config.Requests; i++ {
+		if config.Rate > 0 && !sleepUntil(ctx, start.Add(time.Duration(float64(i)/config.Rate*float64(time.Second)))) {
+			break
+		}
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+
+		wg.Add(1)
+		go func(req httpx.Request) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			sent := time.Now()
+			resp, err := client.Execute(req, "")
+			recorder.record(time.Since(sent), resp, err)
+		}(config.Request(i))
+	}
+	wg.Wait()
+	return recorder.report(time.Since(start)), nil
+}
+
+// sleepUntil waits until deadline, reporting false if ctx is done first
+func sleepUntil(ctx context.Context, deadline time.Time) bool {
+	delay := time.Until(deadline)
+	if delay <= 0 {
+		return ctx.Err() == nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// recorder accumulates the outcomes of concurrent requests
+type recorder struct {
+	mu          sync.Mutex
+	latencies   []time.Duration
+	succeeded   int
+	failed      int
+	statusCodes map[int]int
+	errors      map[string]int
+}
+
+// record adds the outcome of a request
+func (r *recorder) record(latency time.Duration, resp *httpx.Response, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.latencies = append(r.latencies, latency)
+	if resp != nil {
+		r.statusCodes[resp.StatusCode]++
+	}
+	switch {
+	case err != nil:
+		r.failed++
+		r.errors[errorKind(err)]++
+	case resp.StatusCode >= 400:
+		r.failed++
+	default:
+		r.succeeded++
+	}
+}
+
+// report summarizes the recorded outcomes of a run which lasted elapsed
+func (r *recorder) report(elapsed time.Duration) Report {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	report := Report{
+		Requests:    len(r.latencies),
+		Succeeded:   r.succeeded,
+		Failed:      r.failed,
+		Duration:    elapsed,
+		StatusCodes: r.statusCodes,
+		Errors:      r.errors,
+	}
+	if elapsed > 0 {
+		report.Throughput = float64(report.Requests) / elapsed.Seconds()
+	}
+	if len(r.latencies) == 0 {
+		return report
+	}
+
+	latencies := slices.Clone(r.latencies)
+	slices.Sort(latencies)
+	var total time.Duration
+	for _, latency := range latencies {
+		total += latency
+	}
+	report.Latency = Latency{
+		Min:  latencies[0],
+		Mean: total / time.Duration(len(latencies)),
+		P50:  percentile(latencies, 50),
+		P90:  percentile(latencies, 90),
+		P95:  percentile(latencies, 95),
+		P99:  percentile(latencies, 99),
+		Max:  latencies[len(latencies)-1],
+	}
+	return report
+}
+
+// percentile returns the p-th percentile of sorted latencies with the nearest-rank method
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	return sorted[max(rank-1, 0)]
+}
+
+// errorKind returns the httpx.ErrorType of err, or "unknown"
+func errorKind(err error) string {
+	var httpErr *httpx.HTTPError
+	if errors.As(err, &httpErr) && httpErr.Type != "" {
+		return string(httpErr.Type)
+	}
+	return string(httpx.ErrorTypeUnknown)
+}
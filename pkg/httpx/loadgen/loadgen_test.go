@@ -0,0 +1,96 @@
+package loadgen_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bdpiprava/easy-http/pkg/httpx"
+	"github.com/bdpiprava/easy-http/pkg/httpx/loadgen"
+)
+
+func TestRun(t *testing.T) {
+	var inflight, peak atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		current := inflight.Add(1)
+		defer inflight.Add(-1)
+		for {
+			seen := peak.Load()
+			if current <= seen || peak.CompareAndSwap(seen, current) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		if r.URL.Query().Get("fail") == "true" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+	client := httpx.NewClientWithConfig(httpx.WithClientDefaultBaseURL(server.URL), httpx.WithClientTimeout(time.Second))
+
+	t.Run("should send the requests with bounded concurrency and report outcomes", func(t *testing.T) {
+		peak.Store(0)
+
+		report, err := loadgen.Run(context.Background(), client, loadgen.Config{
+			Requests:    20,
+			Concurrency: 4,
+			Request: func(i int) httpx.Request {
+				if i%5 == 0 {
+					return *httpx.NewRequest(http.MethodGet, httpx.WithQueryParam("fail", "true"))
+				}
+				return *httpx.NewRequest(http.MethodGet)
+			},
+		})
+
+		require.NoError(t, err)
+		assert.Equal(t, 20, report.Requests)
+		assert.Equal(t, 16, report.Succeeded)
+		assert.Equal(t, 4, report.Failed)
+		assert.Equal(t, map[int]int{200: 16, 500: 4}, report.StatusCodes)
+		assert.LessOrEqual(t, peak.Load(), int32(4))
+		assert.GreaterOrEqual(t, report.Latency.P50, 10*time.Millisecond)
+		assert.LessOrEqual(t, report.Latency.Min, report.Latency.P50)
+		assert.LessOrEqual(t, report.Latency.P99, report.Latency.Max)
+		assert.Positive(t, report.Throughput)
+	})
+
+	t.Run("should pace requests at the rate for the duration", func(t *testing.T) {
+		report, err := loadgen.Run(context.Background(), client, loadgen.Config{
+			Rate:     100,
+			Duration: 200 * time.Millisecond,
+			Request:  func(int) httpx.Request { return *httpx.NewRequest(http.MethodGet) },
+		})
+
+		require.NoError(t, err)
+		assert.InDelta(t, 20, report.Requests, 3)
+		assert.Equal(t, report.Requests, report.Succeeded)
+	})
+
+	t.Run("should break errors down by kind", func(t *testing.T) {
+		report, err := loadgen.Run(context.Background(), client, loadgen.Config{
+			Requests: 3,
+			Request: func(int) httpx.Request {
+				return *httpx.NewRequest(http.MethodGet, httpx.WithBaseURL("http://127.0.0.1:1"))
+			},
+		})
+
+		require.NoError(t, err)
+		assert.Equal(t, 3, report.Failed)
+		assert.Equal(t, map[string]int{string(httpx.ErrorTypeNetwork): 3}, report.Errors)
+		assert.Contains(t, report.String(), "3 requests")
+	})
+
+	t.Run("should require a request template and a bound", func(t *testing.T) {
+		_, err := loadgen.Run(context.Background(), client, loadgen.Config{Requests: 1})
+		require.Error(t, err)
+
+		_, err = loadgen.Run(context.Background(), client, loadgen.Config{Request: func(int) httpx.Request { return *httpx.NewRequest(http.MethodGet) }})
+		require.Error(t, err)
+	})
+}
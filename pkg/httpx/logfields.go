@@ -0,0 +1,113 @@
+package httpx
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// Log attribute keys emitted by the built-in middlewares
+// They form a stable schema: keys are only added, never renamed. Use WithClientLogFieldMapper to map them to another one.
+const (
+	LogFieldMethod         = "http.method"                  // Request method
+	LogFieldURL            = "http.url"                     // Request URL, with any password redacted in slow request logs
+	LogFieldHost           = "http.host"                    // Request host
+	LogFieldRequestHeaders = "http.request.headers"         // Request headers
+	LogFieldStatus         = "http.status"                  // Response status code
+	LogFieldStatusText     = "http.status_text"             // Response status line, e.g. "200 OK"
+	LogFieldContentLength  = "http.response.content_length" // Response Content-Length header
+	LogFieldContentType    = "http.response.content_type"   // Response Content-Type header
+	LogFieldDuration       = "duration_ms"                  // Call duration in milliseconds
+	LogFieldError          = "error"                        // Error message
+	LogFieldOperation      = "op"                           // Operation name, see WithOperation
+	LogFieldAnnotations    = "annotations"                  // Group of request annotations, see WithAnnotation
+	LogFieldRetryAttempt   = "retry.attempt"                // Attempt number, starting at 1, of a request sent by the retry middleware
+	LogFieldRetryAttempts  = "retry.attempts"               // Number of attempts made for a call
+	LogFieldBreakerState   = "breaker.state"                // Circuit breaker state when the request was admitted
+	LogFieldSlowThreshold  = "slow.threshold_ms"            // Slow request threshold in milliseconds
+	LogFieldDNS            = "timing.dns_ms"                // DNS lookup time in milliseconds
+	LogFieldConnect        = "timing.connect_ms"            // Connection time in milliseconds
+	LogFieldTLSHandshake   = "timing.tls_handshake_ms"      // TLS handshake time in milliseconds
+	LogFieldFirstByte      = "timing.first_byte_ms"         // Time to the first response byte in milliseconds
+	LogFieldBodyRead       = "timing.body_read_ms"          // Response body read time in milliseconds
+	LogFieldConnReused     = "conn.reused"                  // Whether the connection was reused
+	LogFieldServerName     = "tls.server_name"              // TLS server name
+	LogFieldRevocation     = "tls.revocation_status"        // Certificate revocation status
+)
+
+// LogFieldMapper renames the attribute keys of client logs, e.g. LogFieldMethod to "method"
+// It receives every top-level key of the records emitted by the client and returns the key to emit; return the
+// key unchanged to keep it and an empty key to drop the attribute. Attributes already attached to a logger with
+// Logger.With, such as the fields of a request-scoped logger, keep their keys.
+type LogFieldMapper func(key string) string
+
+// durationAttr returns a duration attribute in fractional milliseconds
+func durationAttr(key string, d time.Duration) slog.Attr {
+	return slog.Float64(key, float64(d)/float64(time.Millisecond))
+}
+
+// mapLogFields returns a logger whose top-level attribute keys are renamed by mapper
+func mapLogFields(logger *slog.Logger, mapper LogFieldMapper) *slog.Logger {
+	if logger == nil || mapper == nil {
+		return logger
+	}
+	return slog.New(&fieldMappingHandler{next: logger.Handler(), mapper: mapper})
+}
+
+// fieldMappingHandler is a slog.Handler renaming top-level attribute keys before passing records on
+type fieldMappingHandler struct {
+	next   slog.Handler
+	mapper LogFieldMapper
+}
+
+// Enabled implements slog.Handler
+func (h *fieldMappingHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+// Handle implements slog.Handler
+func (h *fieldMappingHandler) Handle(ctx context.Context, record slog.Record) error {
+	mapped := slog.NewRecord(record.Time, record.Level, record.Message, record.PC)
+	record.Attrs(func(attr slog.Attr) bool {
+		if attr.Key = h.mapper(attr.Key); attr.Key != "" {
+			mapped.AddAttrs(attr)
+		}
+		return true
+	})
+	return h.next.Handle(ctx, mapped)
+}
+
+// WithAttrs implements slog.Handler
+func (h *fieldMappingHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	mapped := make([]slog.Attr, 0, len(attrs))
+	for _, attr := range attrs {
+		if attr.Key = h.mapper(attr.Key); attr.Key != "" {
+			mapped = append(mapped, attr)
+		}
+	}
+	return &fieldMappingHandler{next: h.next.WithAttrs(mapped), mapper: h.mapper}
+}
+
+// WithGroup implements slog.Handler
+// Attributes added after a group are no longer top-level, so they are passed on unchanged.
+func (h *fieldMappingHandler) WithGroup(name string) slog.Handler {
+	return h.next.WithGroup(name)
+}
+
+// retryAttemptKey is the context key holding the attempt number of a request sent by the retry middleware
+type retryAttemptKey struct{}
+
+// breakerStateKey is the context key holding the circuit breaker state when a request was admitted
+type breakerStateKey struct{}
+
+// requestLogAttrs returns the retry attempt and circuit breaker state of ctx as log attributes
+func requestLogAttrs(ctx context.Context) []slog.Attr {
+	var attrs []slog.Attr
+	if attempt, ok := ctx.Value(retryAttemptKey{}).(int); ok {
+		attrs = append(attrs, slog.Int(LogFieldRetryAttempt, attempt))
+	}
+	if state, ok := ctx.Value(breakerStateKey{}).(CircuitBreakerState); ok {
+		attrs = append(attrs, slog.String(LogFieldBreakerState, string(state)))
+	}
+	return attrs
+}
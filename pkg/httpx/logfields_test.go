@@ -0,0 +1,119 @@
+package httpx_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bdpiprava/easy-http/pkg/httpx"
+)
+
+// logRecords decodes the JSON log lines written to buf
+func logRecords(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	t.Helper()
+	var records []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var record map[string]any
+		require.NoError(t, json.Unmarshal([]byte(line), &record))
+		records = append(records, record)
+	}
+	return records
+}
+
+func TestLogFields(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	newClient := func(buf *bytes.Buffer, opts ...httpx.ClientConfigOption) *httpx.Client {
+		policy := httpx.DefaultRetryPolicy()
+		policy.BaseDelay = time.Millisecond
+		return httpx.NewClientWithConfig(append([]httpx.ClientConfigOption{
+			httpx.WithClientDefaultBaseURL(server.URL),
+			httpx.WithClientLogger(slog.New(slog.NewJSONHandler(buf, &slog.HandlerOptions{Level: slog.LevelDebug}))),
+			httpx.WithClientLogLevel(slog.LevelDebug),
+			httpx.WithClientRetryPolicy(policy),
+			httpx.WithClientCircuitBreaker(httpx.DefaultCircuitBreakerConfig()),
+		}, opts...)...)
+	}
+
+	t.Run("emits the stable schema", func(t *testing.T) {
+		calls.Store(0)
+		var buf bytes.Buffer
+
+		_, err := newClient(&buf).Execute(*httpx.NewRequest(http.MethodGet), "")
+		require.NoError(t, err)
+
+		var responses []map[string]any
+		for _, record := range logRecords(t, &buf) {
+			if record["msg"] == "HTTP response" {
+				responses = append(responses, record)
+			}
+		}
+		require.Len(t, responses, 2)
+		for i, record := range responses {
+			assert.Equal(t, http.MethodGet, record[httpx.LogFieldMethod])
+			assert.InDelta(t, i+1, record[httpx.LogFieldRetryAttempt], 0)
+			assert.Equal(t, "closed", record[httpx.LogFieldBreakerState])
+			assert.IsType(t, float64(0), record[httpx.LogFieldDuration])
+		}
+		assert.InDelta(t, http.StatusServiceUnavailable, responses[0][httpx.LogFieldStatus], 0)
+		assert.InDelta(t, http.StatusOK, responses[1][httpx.LogFieldStatus], 0)
+	})
+
+	t.Run("mapper renames and drops fields", func(t *testing.T) {
+		calls.Store(1)
+		var buf bytes.Buffer
+		client := newClient(&buf, httpx.WithClientLogFieldMapper(func(key string) string {
+			switch key {
+			case httpx.LogFieldMethod:
+				return "method"
+			case httpx.LogFieldRequestHeaders:
+				return ""
+			}
+			return key
+		}))
+
+		_, err := client.Execute(*httpx.NewRequest(http.MethodGet), "")
+		require.NoError(t, err)
+
+		for _, record := range logRecords(t, &buf) {
+			assert.Equal(t, http.MethodGet, record["method"])
+			assert.NotContains(t, record, httpx.LogFieldMethod)
+			assert.NotContains(t, record, httpx.LogFieldRequestHeaders)
+		}
+	})
+
+	t.Run("mapper applies to request loggers", func(t *testing.T) {
+		calls.Store(1)
+		var clientLogs, requestLogs bytes.Buffer
+		client := newClient(&clientLogs, httpx.WithClientLogFieldMapper(func(key string) string {
+			return strings.ReplaceAll(key, ".", "_")
+		}))
+		logger := slog.New(slog.NewJSONHandler(&requestLogs, &slog.HandlerOptions{Level: slog.LevelDebug})).With("user.id", "u1")
+
+		_, err := client.Execute(*httpx.NewRequest(http.MethodGet, httpx.WithRequestLogger(logger)), "")
+		require.NoError(t, err)
+
+		records := logRecords(t, &requestLogs)
+		require.NotEmpty(t, records)
+		assert.Equal(t, "u1", records[0]["user.id"], "fields attached to the logger keep their keys")
+		assert.Equal(t, http.MethodGet, records[0]["http_method"])
+		assert.Empty(t, clientLogs.String())
+	})
+}
@@ -0,0 +1,65 @@
+package httpx
+
+import (
+	"math/rand/v2"
+	"sync/atomic"
+)
+
+// LogSamplingConfig samples the request and response logs of the LoggingMiddleware
+// Each request is sampled once, so its request and response records are emitted or suppressed together.
+type LogSamplingConfig struct {
+	SuccessRate float64     // Fraction in [0, 1] of successful requests logged
+	ErrorRate   float64     // Fraction in [0, 1] of failed requests (transport errors, 4xx and 5xx) logged
+	RandSource  rand.Source // Random source for sampling decisions (default: a source seeded randomly)
+}
+
+// LogSamplingStats holds counters of requests logged or suppressed by log sampling
+type LogSamplingStats struct {
+	Logged             int64 // Requests whose records were emitted
+	SuppressedSuccess  int64 // Successful requests whose records were suppressed
+	SuppressedFailures int64 // Failed requests whose records were suppressed
+}
+
+// logSampler decides which requests are logged and counts the suppressed ones
+type logSampler struct {
+	config             LogSamplingConfig
+	rand               *rand.Rand
+	logged             atomic.Int64
+	suppressedSuccess  atomic.Int64
+	suppressedFailures atomic.Int64
+}
+
+// newLogSampler returns a sampler for config
+func newLogSampler(config LogSamplingConfig) *logSampler {
+	return &logSampler{config: config, rand: rand.New(newLockedSource(config.RandSource))}
+}
+
+// sample reports whether to log a request, counting it as logged or suppressed
+func (s *logSampler) sample(failed bool) bool {
+	rate := s.config.SuccessRate
+	if failed {
+		rate = s.config.ErrorRate
+	}
+	if rate >= 1 || (rate > 0 && s.rand.Float64() < rate) {
+		s.logged.Add(1)
+		return true
+	}
+	if failed {
+		s.suppressedFailures.Add(1)
+	} else {
+		s.suppressedSuccess.Add(1)
+	}
+	return false
+}
+
+// stats returns the sampling counters, which are zero for a nil sampler
+func (s *logSampler) stats() LogSamplingStats {
+	if s == nil {
+		return LogSamplingStats{}
+	}
+	return LogSamplingStats{
+		Logged:             s.logged.Load(),
+		SuppressedSuccess:  s.suppressedSuccess.Load(),
+		SuppressedFailures: s.suppressedFailures.Load(),
+	}
+}
@@ -0,0 +1,77 @@
+package httpx_test
+
+import (
+	"bytes"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bdpiprava/easy-http/pkg/httpx"
+)
+
+func TestWithClientLogSampling(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	newClient := func(logs *bytes.Buffer, successRate, errorRate float64) *httpx.Client {
+		return httpx.NewClientWithConfig(
+			httpx.WithClientDefaultBaseURL(server.URL),
+			httpx.WithClientLogger(slog.New(slog.NewTextHandler(logs, &slog.HandlerOptions{Level: slog.LevelDebug}))),
+			httpx.WithClientLogLevel(slog.LevelDebug),
+			httpx.WithClientLogSampling(successRate, errorRate),
+			httpx.WithClientRandSource(rand.NewPCG(1, 2)),
+		)
+	}
+	execute := func(t *testing.T, client *httpx.Client, path string, times int) {
+		for range times {
+			_, err := client.Execute(*httpx.NewRequest(http.MethodGet, httpx.WithPath(path)), "")
+			require.NoError(t, err)
+		}
+	}
+
+	t.Run("keeps every failure", func(t *testing.T) {
+		var logs bytes.Buffer
+		client := newClient(&logs, 0, 1)
+
+		execute(t, client, "/ok", 5)
+		execute(t, client, "/fail", 2)
+
+		assert.Equal(t, httpx.LogSamplingStats{Logged: 2, SuppressedSuccess: 5}, client.LogSamplingStats())
+		assert.Equal(t, 2, strings.Count(logs.String(), "HTTP request"))
+		assert.Equal(t, 2, strings.Count(logs.String(), "http.status=500"))
+		assert.NotContains(t, logs.String(), "http.status=200")
+	})
+
+	t.Run("samples successes", func(t *testing.T) {
+		var logs bytes.Buffer
+		client := newClient(&logs, 0.5, 1)
+
+		execute(t, client, "/ok", 100)
+
+		stats := client.LogSamplingStats()
+		assert.Equal(t, int64(100), stats.Logged+stats.SuppressedSuccess)
+		assert.InDelta(t, 50, stats.Logged, 20)
+		assert.Equal(t, int(stats.Logged), strings.Count(logs.String(), "HTTP request"))
+		assert.Equal(t, int(stats.Logged), strings.Count(logs.String(), "HTTP response"))
+	})
+
+	t.Run("disabled by default", func(t *testing.T) {
+		client := httpx.NewClientWithConfig(httpx.WithClientDefaultBaseURL(server.URL), httpx.WithClientLogger(slog.Default()))
+
+		execute(t, client, "/ok", 1)
+
+		assert.Equal(t, httpx.LogSamplingStats{}, client.LogSamplingStats())
+	})
+}
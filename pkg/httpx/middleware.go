@@ -58,6 +58,24 @@ func (c *MiddlewareChain) Execute(ctx context.Context, req *http.Request) (*http
 	return current(ctx, req)
 }
 
+// requestMiddlewaresKey is the context key of the middlewares added with WithMiddleware
+type requestMiddlewaresKey struct{}
+
+// contextWithRequestMiddlewares returns a context carrying the middlewares of a request, replacing any carried
+// by ctx so requests sent from within a middleware do not inherit them
+func contextWithRequestMiddlewares(ctx context.Context, middlewares []Middleware) context.Context {
+	if len(middlewares) == 0 && requestMiddlewaresFromContext(ctx) == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, requestMiddlewaresKey{}, middlewares)
+}
+
+// requestMiddlewaresFromContext returns the middlewares of the request carried by ctx
+func requestMiddlewaresFromContext(ctx context.Context) []Middleware {
+	middlewares, _ := ctx.Value(requestMiddlewaresKey{}).([]Middleware)
+	return middlewares
+}
+
 // RequestInterceptor provides hooks for request modification
 type RequestInterceptor interface {
 	// BeforeRequest is called before the request is sent
@@ -400,3 +400,85 @@ func TestMiddlewareWithLegacyClient(t *testing.T) {
 		assert.True(t, result.Legacy)
 	})
 }
+
+// orderMiddleware records the order in which middlewares run
+type orderMiddleware struct {
+	name  string
+	order *[]string
+}
+
+func (m orderMiddleware) Name() string {
+	return m.name
+}
+
+func (m orderMiddleware) Execute(ctx context.Context, req *http.Request, next httpx.MiddlewareFunc) (*http.Response, error) {
+	*m.order = append(*m.order, m.name)
+	return next(ctx, req)
+}
+
+// middlewareFunc adapts a function to the Middleware interface
+type middlewareFunc func(ctx context.Context, req *http.Request, next httpx.MiddlewareFunc) (*http.Response, error)
+
+func (f middlewareFunc) Name() string {
+	return "func"
+}
+
+func (f middlewareFunc) Execute(ctx context.Context, req *http.Request, next httpx.MiddlewareFunc) (*http.Response, error) {
+	return f(ctx, req, next)
+}
+
+func TestRequestMiddleware(t *testing.T) {
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		_, _ = w.Write([]byte(r.Header.Get("Authorization")))
+	}))
+	defer server.Close()
+
+	var order []string
+	client := httpx.NewClientWithConfig(
+		httpx.WithClientDefaultBaseURL(server.URL),
+		httpx.WithClientDefaultBasicAuth("client", "secret"),
+		httpx.WithClientMiddlewares(orderMiddleware{name: "client-1", order: &order}, orderMiddleware{name: "client-2", order: &order}),
+	)
+
+	t.Run("should run request middlewares after the client middlewares, in order", func(t *testing.T) {
+		order = nil
+
+		_, err := client.Execute(*httpx.NewRequest(http.MethodGet,
+			httpx.WithMiddleware(orderMiddleware{name: "request-1", order: &order}),
+			httpx.WithMiddlewares(orderMiddleware{name: "request-2", order: &order}),
+		), "")
+
+		require.NoError(t, err)
+		assert.Equal(t, []string{"client-1", "client-2", "request-1", "request-2"}, order)
+	})
+
+	t.Run("should only apply to the request it was added to", func(t *testing.T) {
+		override := middlewareFunc(func(ctx context.Context, req *http.Request, next httpx.MiddlewareFunc) (*http.Response, error) {
+			req.Header.Set("Authorization", "Bearer one-off")
+			return next(ctx, req)
+		})
+
+		resp, err := client.Execute(*httpx.NewRequest(http.MethodGet, httpx.WithMiddleware(override)), "")
+		require.NoError(t, err)
+		assert.Equal(t, "Bearer one-off", resp.Body)
+
+		resp, err = client.Execute(*httpx.NewRequest(http.MethodGet), "")
+		require.NoError(t, err)
+		assert.True(t, strings.HasPrefix(resp.Body.(string), "Basic "))
+	})
+
+	t.Run("should intercept the request before it is sent", func(t *testing.T) {
+		requests = 0
+		dryRun := middlewareFunc(func(_ context.Context, req *http.Request, _ httpx.MiddlewareFunc) (*http.Response, error) {
+			return &http.Response{StatusCode: http.StatusNoContent, Status: "204 No Content", Header: http.Header{}, Body: http.NoBody, Request: req}, nil
+		})
+
+		resp, err := client.Execute(*httpx.NewRequest(http.MethodPost, httpx.WithMiddleware(dryRun)), nil)
+
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+		assert.Zero(t, requests)
+	})
+}
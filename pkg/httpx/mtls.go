@@ -0,0 +1,104 @@
+package httpx
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// CertificateProvider supplies the client certificate presented to servers requesting mutual TLS
+// It is asked for the certificate on every TLS handshake, so short-lived certificates, e.g. SPIFFE SVIDs, rotate
+// without restarting the client: new connections present the current certificate while pooled connections,
+// authenticated with the previous one, keep being reused. It must be safe for concurrent use and should answer
+// from memory, as handshakes wait on it.
+type CertificateProvider interface {
+	GetCertificate(ctx context.Context) (*tls.Certificate, error)
+}
+
+// CertificateProviderFunc adapts a function to the CertificateProvider interface
+type CertificateProviderFunc func(ctx context.Context) (*tls.Certificate, error)
+
+// GetCertificate implements CertificateProvider
+func (f CertificateProviderFunc) GetCertificate(ctx context.Context) (*tls.Certificate, error) {
+	return f(ctx)
+}
+
+// configureClientCertificate presents the certificate of provider on the handshakes of transport
+// A nil certificate presents none, leaving the server to reject the connection if it requires one.
+func configureClientCertificate(transport *http.Transport, provider CertificateProvider) {
+	if transport.TLSClientConfig == nil {
+		transport.TLSClientConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+	}
+	transport.TLSClientConfig.GetClientCertificate = func(info *tls.CertificateRequestInfo) (*tls.Certificate, error) {
+		cert, err := provider.GetCertificate(info.Context())
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to get client certificate")
+		}
+		if cert == nil {
+			return &tls.Certificate{}, nil
+		}
+		return cert, nil
+	}
+}
+
+// FileCertificateProvider presents a certificate and key loaded from PEM files, reloaded once either file changes,
+// e.g. when a SPIFFE helper or cert-manager writes the rotated certificate
+// A failed reload, e.g. while the files are half written, keeps the previous certificate until it expires.
+type FileCertificateProvider struct {
+	certFile string
+	keyFile  string
+
+	mu       sync.Mutex
+	cert     *tls.Certificate
+	modified [2]time.Time // Modification times of the files the certificate was loaded from
+}
+
+// NewFileCertificateProvider creates a provider of the certificate in certFile and the key in keyFile, failing
+// if they cannot be loaded
+func NewFileCertificateProvider(certFile, keyFile string) (*FileCertificateProvider, error) {
+	provider := &FileCertificateProvider{certFile: certFile, keyFile: keyFile}
+	if _, err := provider.GetCertificate(context.Background()); err != nil {
+		return nil, err
+	}
+	return provider, nil
+}
+
+// GetCertificate implements CertificateProvider
+func (p *FileCertificateProvider) GetCertificate(context.Context) (*tls.Certificate, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	modified, err := p.modTimes()
+	if err == nil && p.cert != nil && modified == p.modified {
+		return p.cert, nil
+	}
+	if err == nil {
+		var cert tls.Certificate
+		if cert, err = tls.LoadX509KeyPair(p.certFile, p.keyFile); err == nil {
+			p.cert, p.modified = &cert, modified
+			return p.cert, nil
+		}
+	}
+	if p.cert != nil && p.cert.Leaf != nil && time.Now().Before(p.cert.Leaf.NotAfter) {
+		return p.cert, nil
+	}
+	return nil, errors.Wrap(err, "failed to load client certificate")
+}
+
+// modTimes returns the modification times of the certificate and key files
+func (p *FileCertificateProvider) modTimes() ([2]time.Time, error) {
+	var modified [2]time.Time
+	for i, file := range []string{p.certFile, p.keyFile} {
+		info, err := os.Stat(file)
+		if err != nil {
+			return modified, err
+		}
+		modified[i] = info.ModTime()
+	}
+	return modified, nil
+}
@@ -0,0 +1,105 @@
+package httpx_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bdpiprava/easy-http/pkg/httpx"
+)
+
+// writeClientCertificate writes a self-signed client certificate for commonName and its key as PEM files
+func writeClientCertificate(t *testing.T, certFile, keyFile, commonName string, modified time.Time) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600))
+	require.NoError(t, os.Chtimes(certFile, modified, modified))
+	require.NoError(t, os.Chtimes(keyFile, modified, modified))
+}
+
+func TestCertificateProvider(t *testing.T) {
+	pki := newTestPKI(t)
+	server := pki.server(t, nil)
+	server.TLS.ClientAuth = tls.RequireAnyClientCert
+	server.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// A connection per request, so every request performs a handshake
+		w.Header().Set("Connection", "close")
+		_, _ = w.Write([]byte(r.TLS.PeerCertificates[0].Subject.CommonName))
+	})
+
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	writeClientCertificate(t, certFile, keyFile, "svid-1", time.Now().Add(-time.Minute))
+	provider, err := httpx.NewFileCertificateProvider(certFile, keyFile)
+	require.NoError(t, err)
+	client := httpx.NewClientWithConfig(
+		httpx.WithClientDefaultBaseURL(server.URL),
+		httpx.WithClientTLSConfig(pki.tlsConfig()),
+		httpx.WithClientCertificateProvider(provider),
+	)
+	identity := func(t *testing.T) string {
+		resp, err := client.Execute(*httpx.NewRequest(http.MethodGet), "")
+		require.NoError(t, err)
+		return resp.Body.(string)
+	}
+
+	t.Run("presents the certificate of the provider", func(t *testing.T) {
+		assert.Equal(t, "svid-1", identity(t))
+	})
+
+	t.Run("presents the rotated certificate on new connections", func(t *testing.T) {
+		writeClientCertificate(t, certFile, keyFile, "svid-2", time.Now())
+
+		assert.Equal(t, "svid-2", identity(t))
+	})
+
+	t.Run("keeps the previous certificate while the files are invalid", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(keyFile, []byte("partial"), 0o600))
+
+		assert.Equal(t, "svid-2", identity(t))
+	})
+
+	t.Run("fails the handshake when the provider fails", func(t *testing.T) {
+		failing := httpx.NewClientWithConfig(
+			httpx.WithClientDefaultBaseURL(server.URL),
+			httpx.WithClientTLSConfig(pki.tlsConfig()),
+			httpx.WithClientCertificateProvider(httpx.CertificateProviderFunc(func(context.Context) (*tls.Certificate, error) {
+				return nil, assert.AnError
+			})),
+		)
+
+		_, err := failing.Execute(*httpx.NewRequest(http.MethodGet), "")
+
+		require.Error(t, err)
+	})
+}
@@ -2,10 +2,15 @@ package httpx
 
 import (
 	"bytes"
+	"fmt"
 	"io"
 	"mime/multipart"
+	"net/http"
+	"net/textproto"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
 
 	"github.com/pkg/errors"
 )
@@ -100,3 +105,95 @@ func (b *MultipartFormBuilder) Build() (io.Reader, string, error) {
 	contentType := b.writer.FormDataContentType()
 	return b.buffer, contentType, nil
 }
+
+// MultipartPart is a part of a multipart/form-data body set with WithMultipartBody, WithFormField or WithFormFile
+type MultipartPart struct {
+	FieldName   string    // Name of the form field
+	FileName    string    // File name of file parts, empty for value parts
+	ContentType string    // Content type of file parts (default: application/octet-stream)
+	Value       string    // Value of value parts
+	Reader      io.Reader // Content of file parts, streamed when the request is sent
+}
+
+// encodeMultipartBody replaces the body of the options with the multipart encoding of their parts, if any
+func encodeMultipartBody(opts *RequestOptions) {
+	if len(opts.MultipartParts) == 0 {
+		return
+	}
+	body := newMultipartBody(opts.MultipartParts)
+	if opts.Headers == nil {
+		opts.Headers = make(http.Header)
+	}
+	opts.Headers.Set("Content-Type", body.writer.FormDataContentType())
+	opts.Body = body
+}
+
+// multipartBody streams a multipart/form-data body through a pipe, writing parts as the request reads them
+// Writing starts on the first read, so a body that is never sent holds no goroutine.
+type multipartBody struct {
+	parts  []MultipartPart
+	reader *io.PipeReader
+	pipe   *io.PipeWriter
+	writer *multipart.Writer
+	start  sync.Once
+}
+
+// newMultipartBody returns a body streaming parts
+func newMultipartBody(parts []MultipartPart) *multipartBody {
+	reader, pipe := io.Pipe()
+	return &multipartBody{parts: parts, reader: reader, pipe: pipe, writer: multipart.NewWriter(pipe)}
+}
+
+// Read implements io.Reader
+func (b *multipartBody) Read(p []byte) (int, error) {
+	b.start.Do(func() {
+		go func() {
+			b.pipe.CloseWithError(b.write())
+		}()
+	})
+	return b.reader.Read(p)
+}
+
+// Close implements io.Closer, stopping the writing of parts
+func (b *multipartBody) Close() error {
+	return b.reader.Close()
+}
+
+// write writes every part and the closing boundary
+func (b *multipartBody) write() error {
+	for _, part := range b.parts {
+		if part.FileName == "" && part.Reader == nil {
+			if err := b.writer.WriteField(part.FieldName, part.Value); err != nil {
+				return errors.Wrapf(err, "failed to write field: %s", part.FieldName)
+			}
+			continue
+		}
+
+		contentType := part.ContentType
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		header := make(textproto.MIMEHeader)
+		header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"; filename="%s"`,
+			escapeQuotes(part.FieldName), escapeQuotes(part.FileName)))
+		header.Set("Content-Type", contentType)
+		writer, err := b.writer.CreatePart(header)
+		if err != nil {
+			return errors.Wrapf(err, "failed to create form file: %s", part.FileName)
+		}
+		if part.Reader != nil {
+			if _, err := io.Copy(writer, part.Reader); err != nil {
+				return errors.Wrapf(err, "failed to copy file content: %s", part.FileName)
+			}
+		}
+	}
+	return errors.Wrap(b.writer.Close(), "failed to close multipart writer")
+}
+
+// quoteEscaper escapes the characters mime/multipart escapes in Content-Disposition parameters
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+// escapeQuotes escapes a Content-Disposition parameter value
+func escapeQuotes(s string) string {
+	return quoteEscaper.Replace(s)
+}
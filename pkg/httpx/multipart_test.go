@@ -2,11 +2,17 @@ package httpx_test
 
 import (
 	"bytes"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
+	"mime"
 	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"testing"
 
@@ -350,3 +356,79 @@ func TestParseMultipartContent(t *testing.T) {
 
 	assert.Equal(t, 2, fieldCount, "should have parsed both field and file")
 }
+
+func (s *MultipartTestSuite) TestWithMultipartBody_StreamsParts() {
+	type uploaded struct {
+		Parts   []string `json:"parts"`
+		Chunked bool     `json:"chunked"`
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reader, err := r.MultipartReader()
+		if !s.NoError(err) {
+			return
+		}
+		var result uploaded
+		for {
+			part, err := reader.NextPart()
+			if err != nil {
+				break
+			}
+			content, _ := io.ReadAll(part)
+			result.Parts = append(result.Parts, fmt.Sprintf("%s|%s|%s|%s", part.FormName(), part.FileName(), part.Header.Get("Content-Type"), content))
+		}
+		result.Chunked = slices.Contains(r.TransferEncoding, "chunked")
+		_ = json.NewEncoder(w).Encode(result)
+	}))
+	defer server.Close()
+	testFile := filepath.Join(s.tempDir, "report.csv")
+	s.Require().NoError(os.WriteFile(testFile, []byte("a,b\n1,2\n"), 0600))
+	file, err := os.Open(testFile)
+	s.Require().NoError(err)
+	defer file.Close()
+
+	resp, err := httpx.POST[uploaded](
+		httpx.WithBaseURL(server.URL),
+		httpx.WithFormField("user_id", "42"),
+		httpx.WithFormFile("report", "report.csv", file),
+		httpx.WithMultipartBody(httpx.MultipartPart{FieldName: "avatar", FileName: `me "1".png`, ContentType: "image/png", Reader: strings.NewReader("png")}),
+		httpx.WithFormField("note", "last"),
+	)
+
+	s.Require().NoError(err)
+	s.Equal(uploaded{
+		Parts: []string{
+			"user_id|||42",
+			"report|report.csv|application/octet-stream|a,b\n1,2\n",
+			`avatar|me "1".png|image/png|png`,
+			"note|||last",
+		},
+		Chunked: true,
+	}, resp.Body)
+}
+
+// readTracker records whether its reader was read
+type readTracker struct {
+	io.Reader
+	read bool
+}
+
+func (r *readTracker) Read(p []byte) (int, error) {
+	r.read = true
+	return r.Reader.Read(p)
+}
+
+func (s *MultipartTestSuite) TestWithMultipartBody_ReadsFilesWhenSent() {
+	file := &readTracker{Reader: strings.NewReader("content")}
+	req := httpx.NewRequest("POST", httpx.WithFormFile("file", "blob.bin", file))
+	httpReq, err := req.ToHTTPReq(httpx.ClientOptions{})
+	s.Require().NoError(err)
+	s.False(file.read, "files are not buffered when the request is built")
+
+	_, params, err := mime.ParseMediaType(httpReq.Header.Get("Content-Type"))
+	s.Require().NoError(err)
+	form, err := multipart.NewReader(httpReq.Body, params["boundary"]).ReadForm(1 << 10)
+	s.Require().NoError(err)
+	s.True(file.read)
+	s.Require().Len(form.File["file"], 1)
+	s.Equal("blob.bin", form.File["file"][0].Filename)
+}
@@ -0,0 +1,309 @@
+package httpx
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// OAuth2 defaults
+const (
+	defaultOAuth2RefreshBefore = 30 * time.Second
+	defaultOAuth2FetchTimeout  = 30 * time.Second
+)
+
+// OAuth2AuthStyle is how the client credentials are sent to the token endpoint
+type OAuth2AuthStyle int
+
+const (
+	OAuth2AuthHeader OAuth2AuthStyle = iota // HTTP Basic authentication, as recommended by RFC 6749
+	OAuth2AuthParams                        // client_id and client_secret form parameters
+)
+
+// OAuth2Token is an access token issued by the token endpoint
+type OAuth2Token struct {
+	AccessToken string    `json:"access_token"`
+	TokenType   string    `json:"token_type"`       // Authorization scheme (default: Bearer)
+	Expiry      time.Time `json:"expiry,omitempty"` // Zero when the token does not expire
+}
+
+// OAuth2TokenStore stores tokens outside the client, e.g. to share them between processes or survive restarts
+// Tokens loaded from the store are used until they are due for refresh, before the token endpoint is called.
+type OAuth2TokenStore interface {
+	LoadToken(ctx context.Context) (*OAuth2Token, error) // Returns nil without error when there is no token
+	SaveToken(ctx context.Context, token *OAuth2Token) error
+}
+
+// OAuth2Config configures the OAuth2 client credentials grant (RFC 6749 section 4.4)
+type OAuth2Config struct {
+	TokenURL       string          // Token endpoint (required)
+	ClientID       string          // Client identifier
+	ClientSecret   string          // Client secret
+	Scopes         []string        // Requested scopes
+	EndpointParams url.Values      // Additional token request parameters, e.g. audience
+	AuthStyle      OAuth2AuthStyle // How the client credentials are sent (default: OAuth2AuthHeader)
+	RefreshBefore  time.Duration   // Tokens are refreshed in the background this long before they expire (default: 30s)
+	Store          OAuth2TokenStore
+	HTTPClient     *http.Client // Client of token requests (default: a client with a 30s timeout)
+	Clock          Clock        // Clock of token expiry (default: the system clock)
+
+	// BaseContext scopes token requests, which are shared by the requests waiting for a token and so outlive
+	// each of them; they are canceled once it is done, e.g. on application shutdown (default: the client base context)
+	BaseContext context.Context
+}
+
+// OAuth2Middleware authorizes requests with access tokens of the client credentials grant
+// A single token request runs at a time, shared by every request waiting for a token. Tokens are refreshed in
+// the background before they expire, and a request answered 401 is retried once with a new token.
+type OAuth2Middleware struct {
+	config OAuth2Config
+
+	mu       sync.Mutex
+	token    *OAuth2Token
+	rejected string       // Access token answered 401, never reused even if the store returns it
+	flight   *oauth2Fetch // Token request in flight
+}
+
+// oauth2Fetch is a token request that concurrent requests wait on
+type oauth2Fetch struct {
+	done  chan struct{}
+	token *OAuth2Token
+	err   error
+}
+
+// NewOAuth2Middleware creates a new OAuth2 client credentials middleware
+func NewOAuth2Middleware(config OAuth2Config) *OAuth2Middleware {
+	if config.RefreshBefore <= 0 {
+		config.RefreshBefore = defaultOAuth2RefreshBefore
+	}
+	if config.HTTPClient == nil {
+		config.HTTPClient = &http.Client{Timeout: defaultOAuth2FetchTimeout}
+	}
+	return &OAuth2Middleware{config: config}
+}
+
+// Name returns the middleware name
+func (m *OAuth2Middleware) Name() string {
+	return "oauth2"
+}
+
+// Execute implements the Middleware interface
+func (m *OAuth2Middleware) Execute(ctx context.Context, req *http.Request, next MiddlewareFunc) (*http.Response, error) {
+	token, err := m.Token(ctx)
+	if err != nil {
+		return nil, MiddlewareError("failed to obtain OAuth2 token", err, req)
+	}
+	setAuthorization(req, token)
+
+	resp, err := next(ctx, req)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+
+	// The token may have been revoked: retry once with a new one
+	m.reject(token)
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return resp, nil // Body can't be replayed
+	}
+	fresh, err := m.Token(ctx)
+	if err != nil || fresh.AccessToken == token.AccessToken {
+		return resp, nil
+	}
+	retry := req.Clone(ctx)
+	if req.GetBody != nil {
+		body, bodyErr := req.GetBody()
+		if bodyErr != nil {
+			return resp, nil
+		}
+		retry.Body = body
+	}
+	setAuthorization(retry, fresh)
+	drainAndClose(resp.Body, defaultDrainLimit)
+
+	return next(ctx, retry)
+}
+
+// Token returns a valid access token, requesting one from the token endpoint if needed
+func (m *OAuth2Middleware) Token(ctx context.Context) (*OAuth2Token, error) {
+	m.mu.Lock()
+	token, flight := m.token, m.flight
+	now := m.clock().Now()
+	if token != nil && !token.expiredAt(now) {
+		// Refresh ahead of expiry while the current token is still served
+		if flight == nil && token.expiredAt(now.Add(m.config.RefreshBefore)) {
+			m.startFetch(ctx)
+		}
+		m.mu.Unlock()
+		return token, nil
+	}
+	if flight == nil {
+		flight = m.startFetch(ctx)
+	}
+	m.mu.Unlock()
+
+	select {
+	case <-flight.done:
+		return flight.token, flight.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// reject discards token after the server refused it
+func (m *OAuth2Middleware) reject(token *OAuth2Token) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.rejected = token.AccessToken
+	if m.token != nil && m.token.AccessToken == token.AccessToken {
+		m.token = nil
+	}
+}
+
+// startFetch starts a token request scoped to the base context, that of the client sending the request
+// carried by ctx if none is configured; the caller holds m.mu
+func (m *OAuth2Middleware) startFetch(ctx context.Context) *oauth2Fetch {
+	base := m.config.BaseContext
+	if base == nil {
+		base = baseContextFromContext(ctx)
+	}
+	flight := &oauth2Fetch{done: make(chan struct{})}
+	m.flight = flight
+	rejected := m.rejected
+
+	go func() {
+		defer close(flight.done)
+		ctx := base
+		if ctx == nil {
+			ctx = context.Background()
+		}
+		ctx, cancel := context.WithTimeout(ctx, defaultOAuth2FetchTimeout)
+		defer cancel()
+
+		flight.token, flight.err = m.fetch(ctx, rejected)
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		m.flight = nil
+		if flight.err == nil {
+			m.token = flight.token
+		}
+	}()
+	return flight
+}
+
+// fetch loads a token from the store, or requests one from the token endpoint and saves it
+func (m *OAuth2Middleware) fetch(ctx context.Context, rejected string) (*OAuth2Token, error) {
+	refreshAt := m.clock().Now().Add(m.config.RefreshBefore)
+	if m.config.Store != nil {
+		token, err := m.config.Store.LoadToken(ctx)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to load OAuth2 token")
+		}
+		if token != nil && token.AccessToken != rejected && !token.expiredAt(refreshAt) {
+			return token, nil
+		}
+	}
+
+	token, err := m.requestToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if m.config.Store != nil {
+		if err := m.config.Store.SaveToken(ctx, token); err != nil {
+			return nil, errors.Wrap(err, "failed to save OAuth2 token")
+		}
+	}
+	return token, nil
+}
+
+// oauth2TokenResponse is the body of token endpoint responses, successful or not
+type oauth2TokenResponse struct {
+	AccessToken      string          `json:"access_token"`
+	TokenType        string          `json:"token_type"`
+	ExpiresIn        json.RawMessage `json:"expires_in"`
+	Error            string          `json:"error"`
+	ErrorDescription string          `json:"error_description"`
+}
+
+// requestToken requests a token from the token endpoint
+func (m *OAuth2Middleware) requestToken(ctx context.Context) (*OAuth2Token, error) {
+	if m.config.TokenURL == "" {
+		return nil, errors.New("OAuth2 token URL is not configured")
+	}
+	form := url.Values{"grant_type": {"client_credentials"}}
+	for name, values := range m.config.EndpointParams {
+		form[name] = values
+	}
+	if len(m.config.Scopes) > 0 {
+		form.Set("scope", strings.Join(m.config.Scopes, " "))
+	}
+	if m.config.AuthStyle == OAuth2AuthParams {
+		form.Set("client_id", m.config.ClientID)
+		form.Set("client_secret", m.config.ClientSecret)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, m.config.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create OAuth2 token request")
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if m.config.AuthStyle == OAuth2AuthHeader {
+		// RFC 6749 section 2.3.1 form-encodes the credentials before Basic authentication
+		req.SetBasicAuth(url.QueryEscape(m.config.ClientID), url.QueryEscape(m.config.ClientSecret))
+	}
+
+	issuedAt := m.clock().Now()
+	resp, err := m.config.HTTPClient.Do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to request OAuth2 token")
+	}
+	defer resp.Body.Close()
+
+	var body oauth2TokenResponse
+	decodeErr := json.NewDecoder(resp.Body).Decode(&body)
+	if body.Error != "" {
+		return nil, errors.Errorf("OAuth2 token endpoint returned %s: %s", body.Error, body.ErrorDescription)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("OAuth2 token endpoint returned status %d", resp.StatusCode)
+	}
+	if decodeErr != nil {
+		return nil, errors.Wrap(decodeErr, "failed to decode OAuth2 token response")
+	}
+	if body.AccessToken == "" {
+		return nil, errors.New("OAuth2 token response has no access_token")
+	}
+
+	token := &OAuth2Token{AccessToken: body.AccessToken, TokenType: body.TokenType}
+	// expires_in is a number, some servers send it as a string
+	if seconds, err := strconv.ParseInt(strings.Trim(string(body.ExpiresIn), `"`), 10, 64); err == nil && seconds > 0 {
+		token.Expiry = issuedAt.Add(time.Duration(seconds) * time.Second)
+	}
+	return token, nil
+}
+
+// clock returns the clock of token expiry
+func (m *OAuth2Middleware) clock() Clock {
+	return clockOrSystem(m.config.Clock)
+}
+
+// expiredAt reports whether the token is expired at t
+func (t *OAuth2Token) expiredAt(at time.Time) bool {
+	return !t.Expiry.IsZero() && !at.Before(t.Expiry)
+}
+
+// setAuthorization sets the Authorization header of req to token
+func setAuthorization(req *http.Request, token *OAuth2Token) {
+	scheme := token.TokenType
+	if scheme == "" || strings.EqualFold(scheme, "bearer") {
+		scheme = "Bearer"
+	}
+	req.Header.Set("Authorization", scheme+" "+token.AccessToken)
+}
@@ -0,0 +1,256 @@
+package httpx_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bdpiprava/easy-http/pkg/httpx"
+	httpxtesting "github.com/bdpiprava/easy-http/pkg/httpx/testing"
+)
+
+// tokenServer issues numbered tokens, token-1, token-2, ..., expiring after expiresIn seconds
+type tokenServer struct {
+	*httptest.Server
+	requests  atomic.Int32
+	expiresIn int
+	delay     time.Duration
+	lastForm  atomic.Value
+}
+
+func newTokenServer(t *testing.T, expiresIn int) *tokenServer {
+	ts := &tokenServer{expiresIn: expiresIn}
+	ts.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := ts.requests.Add(1)
+		require.NoError(t, r.ParseForm())
+		ts.lastForm.Store(r.PostForm)
+		// Credentials are form-encoded before Basic authentication
+		id, secret, _ := r.BasicAuth()
+		secret, _ = url.QueryUnescape(secret)
+		if id != "client" || secret != "s3cr%t" {
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte(`{"error":"invalid_client","error_description":"bad credentials"}`))
+			return
+		}
+		time.Sleep(ts.delay)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprintf(w, `{"access_token":"token-%d","token_type":"bearer","expires_in":%d}`, n, ts.expiresIn)
+	}))
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+// memoryTokenStore is an OAuth2TokenStore keeping a single token in memory
+type memoryTokenStore struct {
+	mu    sync.Mutex
+	token *httpx.OAuth2Token
+	saves int
+}
+
+func (s *memoryTokenStore) LoadToken(context.Context) (*httpx.OAuth2Token, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.token, nil
+}
+
+func (s *memoryTokenStore) SaveToken(_ context.Context, token *httpx.OAuth2Token) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.token = token
+	s.saves++
+	return nil
+}
+
+func TestOAuth2Middleware(t *testing.T) {
+	newAPI := func(t *testing.T, accept func(token string) bool) (*httptest.Server, *[]string) {
+		var mu sync.Mutex
+		var seen []string
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			authorization := r.Header.Get("Authorization")
+			mu.Lock()
+			seen = append(seen, authorization)
+			mu.Unlock()
+			if !accept(strings.TrimPrefix(authorization, "Bearer ")) {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			_, _ = w.Write([]byte(`{}`))
+		}))
+		t.Cleanup(server.Close)
+		return server, &seen
+	}
+	acceptAll := func(string) bool { return true }
+
+	t.Run("injects and caches the token", func(t *testing.T) {
+		tokens := newTokenServer(t, 3600)
+		api, seen := newAPI(t, acceptAll)
+		client := httpx.NewClientWithConfig(
+			httpx.WithClientDefaultBaseURL(api.URL),
+			httpx.WithClientOAuth2(httpx.OAuth2Config{
+				TokenURL:     tokens.URL,
+				ClientID:     "client",
+				ClientSecret: "s3cr%t",
+				Scopes:       []string{"read", "write"},
+			}),
+		)
+
+		for range 3 {
+			_, err := client.Execute(*httpx.NewRequest(http.MethodGet), nil)
+			require.NoError(t, err)
+		}
+
+		assert.Equal(t, []string{"Bearer token-1", "Bearer token-1", "Bearer token-1"}, *seen)
+		assert.Equal(t, int32(1), tokens.requests.Load())
+		form := tokens.lastForm.Load().(url.Values)
+		assert.Equal(t, []string{"client_credentials"}, form["grant_type"])
+		assert.Equal(t, []string{"read write"}, form["scope"])
+		assert.NotNil(t, client.OAuth2())
+	})
+
+	t.Run("concurrent requests share one token request", func(t *testing.T) {
+		tokens := newTokenServer(t, 3600)
+		tokens.delay = 50 * time.Millisecond
+		api, _ := newAPI(t, acceptAll)
+		client := httpx.NewClientWithConfig(
+			httpx.WithClientDefaultBaseURL(api.URL),
+			httpx.WithClientOAuth2(httpx.OAuth2Config{TokenURL: tokens.URL, ClientID: "client", ClientSecret: "s3cr%t"}),
+		)
+
+		var wg sync.WaitGroup
+		for range 10 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, err := client.Execute(*httpx.NewRequest(http.MethodGet), nil)
+				assert.NoError(t, err)
+			}()
+		}
+		wg.Wait()
+
+		assert.Equal(t, int32(1), tokens.requests.Load())
+	})
+
+	t.Run("refreshes before expiry", func(t *testing.T) {
+		tokens := newTokenServer(t, 60)
+		clock := httpxtesting.NewFakeClock()
+		middleware := httpx.NewOAuth2Middleware(httpx.OAuth2Config{
+			TokenURL:      tokens.URL,
+			ClientID:      "client",
+			ClientSecret:  "s3cr%t",
+			RefreshBefore: 10 * time.Second,
+			Clock:         clock,
+		})
+
+		token, err := middleware.Token(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, "token-1", token.AccessToken)
+
+		// Inside the refresh window the current token is served while a new one is fetched
+		clock.Advance(55 * time.Second)
+		token, err = middleware.Token(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, "token-1", token.AccessToken)
+		require.Eventually(t, func() bool {
+			token, err := middleware.Token(context.Background())
+			return err == nil && token.AccessToken == "token-2"
+		}, time.Second, 10*time.Millisecond)
+		assert.Equal(t, int32(2), tokens.requests.Load())
+	})
+
+	t.Run("refetches the token and retries on 401", func(t *testing.T) {
+		tokens := newTokenServer(t, 3600)
+		api, seen := newAPI(t, func(token string) bool { return token != "token-1" })
+		client := httpx.NewClientWithConfig(
+			httpx.WithClientDefaultBaseURL(api.URL),
+			httpx.WithClientOAuth2(httpx.OAuth2Config{TokenURL: tokens.URL, ClientID: "client", ClientSecret: "s3cr%t"}),
+		)
+
+		resp, err := client.Execute(*httpx.NewRequest(http.MethodPost, httpx.WithJSONBody(map[string]string{"a": "b"})), nil)
+		require.NoError(t, err)
+
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, []string{"Bearer token-1", "Bearer token-2"}, *seen)
+	})
+
+	t.Run("reuses the connection of the rejected response for the retry", func(t *testing.T) {
+		tokens := newTokenServer(t, 3600)
+		api, conns := newConnCountingServer(t, func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Authorization") == "Bearer token-1" {
+				w.WriteHeader(http.StatusUnauthorized)
+				_, _ = w.Write([]byte(strings.Repeat("x", 64<<10)))
+				return
+			}
+			_, _ = w.Write([]byte(`{}`))
+		})
+		client := httpx.NewClientWithConfig(
+			httpx.WithClientDefaultBaseURL(api.URL),
+			httpx.WithClientOAuth2(httpx.OAuth2Config{TokenURL: tokens.URL, ClientID: "client", ClientSecret: "s3cr%t"}),
+		)
+
+		resp, err := client.Execute(*httpx.NewRequest(http.MethodGet), nil)
+		require.NoError(t, err)
+
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, int32(1), conns.Load())
+	})
+
+	t.Run("uses and updates the token store", func(t *testing.T) {
+		tokens := newTokenServer(t, 3600)
+		api, seen := newAPI(t, acceptAll)
+		store := &memoryTokenStore{token: &httpx.OAuth2Token{AccessToken: "stored", Expiry: time.Now().Add(time.Hour)}}
+		client := httpx.NewClientWithConfig(
+			httpx.WithClientDefaultBaseURL(api.URL),
+			httpx.WithClientOAuth2(httpx.OAuth2Config{TokenURL: tokens.URL, ClientID: "client", ClientSecret: "s3cr%t", Store: store}),
+		)
+
+		_, err := client.Execute(*httpx.NewRequest(http.MethodGet), nil)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"Bearer stored"}, *seen)
+		assert.Equal(t, int32(0), tokens.requests.Load())
+
+		// An expired stored token is replaced by a new one from the token endpoint
+		store = &memoryTokenStore{token: &httpx.OAuth2Token{AccessToken: "stored", Expiry: time.Now().Add(-time.Minute)}}
+		middleware := httpx.NewOAuth2Middleware(httpx.OAuth2Config{TokenURL: tokens.URL, ClientID: "client", ClientSecret: "s3cr%t", Store: store})
+		token, err := middleware.Token(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, "token-1", token.AccessToken)
+		assert.Equal(t, 1, store.saves)
+		assert.Equal(t, "token-1", store.token.AccessToken)
+	})
+
+	t.Run("fails requests when the token endpoint rejects the client", func(t *testing.T) {
+		tokens := newTokenServer(t, 3600)
+		api, seen := newAPI(t, acceptAll)
+		client := httpx.NewClientWithConfig(
+			httpx.WithClientDefaultBaseURL(api.URL),
+			httpx.WithClientOAuth2(httpx.OAuth2Config{TokenURL: tokens.URL, ClientID: "client", ClientSecret: "wrong"}),
+		)
+
+		_, err := client.Execute(*httpx.NewRequest(http.MethodGet), nil)
+
+		var httpErr *httpx.HTTPError
+		require.ErrorAs(t, err, &httpErr)
+		assert.Equal(t, httpx.ErrorTypeMiddleware, httpErr.Type)
+		assert.Contains(t, httpErr.Cause.Error(), "invalid_client: bad credentials")
+		assert.Empty(t, *seen)
+	})
+
+	t.Run("fails requests without a token URL", func(t *testing.T) {
+		middleware := httpx.NewOAuth2Middleware(httpx.OAuth2Config{ClientID: "client"})
+
+		_, err := middleware.Token(context.Background())
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "token URL is not configured")
+	})
+}
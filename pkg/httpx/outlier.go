@@ -0,0 +1,208 @@
+package httpx
+
+import (
+	"cmp"
+	"context"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// defaultConsecutiveFailures is the number of failures in a row ejecting an endpoint
+	defaultConsecutiveFailures = 5
+	// defaultBaseEjectionTime is the ejection time of an endpoint's first ejection
+	defaultBaseEjectionTime = 30 * time.Second
+	// defaultMaxEjectionTime bounds the ejection time of endpoints ejected repeatedly
+	defaultMaxEjectionTime = 5 * time.Minute
+	// defaultMaxEjectionPercent is the share of a service's endpoints that can be ejected at once
+	defaultMaxEjectionPercent = 10
+)
+
+// OutlierConfig configures outlier detection, which ejects failing endpoints of a service from load balancing
+// for a cooldown, as Envoy does, so one bad instance stops failing its share of the requests
+//
+// An endpoint is ejected after ConsecutiveFailures 5xx responses or errors, timeouts included, in a row.
+// It returns after BaseEjectionTime multiplied by the number of times it was ejected in a row, up to
+// MaxEjectionTime; a success after it returned resets the multiplier.
+type OutlierConfig struct {
+	ConsecutiveFailures int           // Failures in a row ejecting an endpoint (default: 5)
+	BaseEjectionTime    time.Duration // Ejection time of a first ejection (default: 30s)
+	MaxEjectionTime     time.Duration // Upper bound of the ejection time (default: 5m)
+	MaxEjectionPercent  int           // Maximum share of endpoints ejected at once, one can always be ejected (default: 10)
+
+	// Observer is notified of ejections and returns (default: the client's with WithClientServiceDiscovery,
+	// see WithClientOutlierObserver)
+	Observer OutlierObserver
+}
+
+// OutlierObserver is notified when outlier detection ejects an endpoint or returns it to load balancing
+// Observers are called synchronously on the request path and must not block.
+type OutlierObserver interface {
+	// ObserveEjection is called when endpoint of service is ejected, ejected being the number of endpoints now ejected
+	ObserveEjection(service string, endpoint Endpoint, ejected int)
+	// ObserveReturn is called when endpoint of service returns, ejected being the number of endpoints still ejected
+	ObserveReturn(service string, endpoint Endpoint, ejected int)
+}
+
+// OutlierStats holds the state of outlier detection for a service
+type OutlierStats struct {
+	Ejections int64      // Endpoints ejected since the client was created
+	Ejected   []Endpoint // Endpoints currently ejected
+}
+
+// outlierDetector tracks the failures of a service's endpoints and ejects the outliers
+type outlierDetector struct {
+	config  OutlierConfig
+	service string
+
+	mu        sync.Mutex
+	hosts     map[string]*outlierHost // By endpoint address
+	ejections int64
+}
+
+// outlierHost is the outlier detection state of an endpoint
+type outlierHost struct {
+	endpoint     Endpoint
+	failures     int       // Failures in a row
+	ejections    int       // Ejections in a row, scaling the ejection time
+	ejectedUntil time.Time // Zero unless ejected
+}
+
+// newOutlierDetector creates an outlier detector for service, or nil when config is nil
+func newOutlierDetector(service string, config *OutlierConfig) *outlierDetector {
+	if config == nil {
+		return nil
+	}
+	detector := &outlierDetector{config: *config, service: service, hosts: make(map[string]*outlierHost)}
+	if detector.config.ConsecutiveFailures <= 0 {
+		detector.config.ConsecutiveFailures = defaultConsecutiveFailures
+	}
+	if detector.config.BaseEjectionTime <= 0 {
+		detector.config.BaseEjectionTime = defaultBaseEjectionTime
+	}
+	if detector.config.MaxEjectionTime <= 0 {
+		detector.config.MaxEjectionTime = defaultMaxEjectionTime
+	}
+	if detector.config.MaxEjectionPercent <= 0 {
+		detector.config.MaxEjectionPercent = defaultMaxEjectionPercent
+	}
+	return detector
+}
+
+// available returns the endpoints not ejected, returning those whose ejection elapsed
+// All endpoints are returned if every one of them is ejected, e.g. after the others left the service.
+func (d *outlierDetector) available(endpoints []Endpoint) []Endpoint {
+	now := time.Now()
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	result := make([]Endpoint, 0, len(endpoints))
+	for _, endpoint := range endpoints {
+		host := d.hosts[endpoint.Address()]
+		if host != nil && !host.ejectedUntil.IsZero() {
+			if now.Before(host.ejectedUntil) {
+				continue
+			}
+			host.ejectedUntil = time.Time{}
+			host.failures = 0
+			if d.config.Observer != nil {
+				d.config.Observer.ObserveReturn(d.service, host.endpoint, d.ejected())
+			}
+		}
+		result = append(result, endpoint)
+	}
+	if len(result) == 0 {
+		return endpoints
+	}
+	return result
+}
+
+// report records the outcome of a request sent to endpoint, one of total endpoints of the service
+func (d *outlierDetector) report(endpoint Endpoint, total int, failed bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	address := endpoint.Address()
+	host := d.hosts[address]
+	if host == nil {
+		if !failed {
+			return
+		}
+		host = &outlierHost{endpoint: endpoint}
+		d.hosts[address] = host
+	}
+	if !failed {
+		host.failures = 0
+		if host.ejectedUntil.IsZero() {
+			host.ejections = 0
+		}
+		return
+	}
+
+	host.failures++
+	if host.failures < d.config.ConsecutiveFailures || !host.ejectedUntil.IsZero() {
+		return
+	}
+	ejected := d.ejected()
+	if ejected > 0 && (ejected+1)*100 > total*d.config.MaxEjectionPercent {
+		return
+	}
+	host.ejections++
+	host.ejectedUntil = time.Now().Add(min(d.config.BaseEjectionTime*time.Duration(host.ejections), d.config.MaxEjectionTime))
+	d.ejections++
+	if d.config.Observer != nil {
+		d.config.Observer.ObserveEjection(d.service, endpoint, ejected+1)
+	}
+}
+
+// ejected returns the number of endpoints ejected, the caller holding the lock
+func (d *outlierDetector) ejected() int {
+	count := 0
+	for _, host := range d.hosts {
+		if !host.ejectedUntil.IsZero() {
+			count++
+		}
+	}
+	return count
+}
+
+// stats returns the state of outlier detection
+func (d *outlierDetector) stats() OutlierStats {
+	if d == nil {
+		return OutlierStats{}
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	stats := OutlierStats{Ejections: d.ejections}
+	for _, host := range d.hosts {
+		if !host.ejectedUntil.IsZero() {
+			stats.Ejected = append(stats.Ejected, host.endpoint)
+		}
+	}
+	slices.SortFunc(stats.Ejected, func(a, b Endpoint) int { return cmp.Compare(a.Address(), b.Address()) })
+	return stats
+}
+
+// prune forgets the endpoints that left the service
+func (d *outlierDetector) prune(endpoints []Endpoint) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for address := range d.hosts {
+		if !slices.ContainsFunc(endpoints, func(e Endpoint) bool { return e.Address() == address }) {
+			delete(d.hosts, address)
+		}
+	}
+}
+
+// isOutlierFailure reports whether the outcome of a request counts as a failure of the endpoint
+// Requests cancelled by the caller say nothing about the endpoint.
+func isOutlierFailure(ctx context.Context, resp *http.Response, err error) bool {
+	if err != nil {
+		return ctx.Err() == nil || !errors.Is(err, context.Canceled)
+	}
+	return resp != nil && resp.StatusCode >= http.StatusInternalServerError
+}
@@ -0,0 +1,184 @@
+package httpx_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bdpiprava/easy-http/pkg/httpx"
+)
+
+// outlierEvents records ejections and returns of endpoints
+type outlierEvents struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (o *outlierEvents) ObserveEjection(service string, endpoint httpx.Endpoint, ejected int) {
+	o.record("eject " + service + " " + endpoint.Address() + " " + strconv.Itoa(ejected))
+}
+
+func (o *outlierEvents) ObserveReturn(service string, endpoint httpx.Endpoint, ejected int) {
+	o.record("return " + service + " " + endpoint.Address() + " " + strconv.Itoa(ejected))
+}
+
+func (o *outlierEvents) record(event string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.events = append(o.events, event)
+}
+
+func (o *outlierEvents) list() []string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return append([]string(nil), o.events...)
+}
+
+// failingServer starts a server answering every request with 503
+func failingServer(t *testing.T) httpx.Endpoint {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	t.Cleanup(server.Close)
+	u, err := url.Parse(server.URL)
+	require.NoError(t, err)
+	port, err := strconv.Atoi(u.Port())
+	require.NoError(t, err)
+	return httpx.Endpoint{Host: u.Hostname(), Port: port}
+}
+
+// firstEndpoint always picks the first endpoint, so ejections are visible in which server answers
+var firstEndpoint = httpx.BalancerFunc(func(_ *http.Request, endpoints []httpx.Endpoint) (httpx.Endpoint, bool) {
+	return endpoints[0], len(endpoints) > 0
+})
+
+func TestOutlierDetection(t *testing.T) {
+	bad := failingServer(t)
+	_, good := namedServer(t, "good")
+
+	t.Run("should eject an endpoint after consecutive failures until its ejection elapses", func(t *testing.T) {
+		events := &outlierEvents{}
+		client := httpx.NewClientWithConfig(
+			httpx.WithClientOutlierObserver(events),
+			httpx.WithClientServiceDiscovery(httpx.ServiceConfig{
+				Name:     "payments",
+				Resolver: &staticResolver{endpoints: []httpx.Endpoint{bad, good}},
+				Balancer: firstEndpoint,
+				OutlierDetection: &httpx.OutlierConfig{
+					ConsecutiveFailures: 2,
+					BaseEjectionTime:    100 * time.Millisecond,
+					MaxEjectionPercent:  50,
+				},
+			}),
+		)
+		service := client.ServiceDiscovery()
+		defer service.Close()
+		get := func() *httpx.Response {
+			resp, err := client.Execute(*httpx.NewRequest(http.MethodGet), "")
+			require.NoError(t, err)
+			return resp
+		}
+
+		for range 2 {
+			assert.Equal(t, http.StatusServiceUnavailable, get().StatusCode)
+		}
+		assert.Equal(t, "good payments", get().Body)
+		assert.Equal(t, httpx.OutlierStats{Ejections: 1, Ejected: []httpx.Endpoint{bad}}, service.OutlierStats())
+
+		// Back after its ejection, the endpoint is ejected again on its next failures, for twice as long
+		time.Sleep(150 * time.Millisecond)
+		for range 2 {
+			assert.Equal(t, http.StatusServiceUnavailable, get().StatusCode)
+		}
+		assert.Equal(t, "good payments", get().Body)
+		time.Sleep(150 * time.Millisecond)
+		assert.Equal(t, "good payments", get().Body)
+
+		assert.Equal(t, int64(2), service.OutlierStats().Ejections)
+		assert.Equal(t, []string{
+			"eject payments " + bad.Address() + " 1",
+			"return payments " + bad.Address() + " 0",
+			"eject payments " + bad.Address() + " 1",
+		}, events.list())
+	})
+
+	t.Run("should report to the observer of its own config", func(t *testing.T) {
+		events := &outlierEvents{}
+		service := httpx.NewServiceMiddleware(httpx.ServiceConfig{
+			Name:             "payments",
+			Resolver:         &staticResolver{endpoints: []httpx.Endpoint{bad, good}},
+			Balancer:         firstEndpoint,
+			OutlierDetection: &httpx.OutlierConfig{ConsecutiveFailures: 1, MaxEjectionPercent: 50, Observer: events},
+		})
+		defer service.Close()
+		client := httpx.NewClientWithConfig(
+			httpx.WithClientDefaultBaseURL("http://payments"),
+			httpx.WithClientOutlierObserver(&outlierEvents{}),
+			httpx.WithClientMiddleware(service),
+		)
+
+		_, err := client.Execute(*httpx.NewRequest(http.MethodGet), "")
+		require.NoError(t, err)
+
+		assert.Equal(t, []string{"eject payments " + bad.Address() + " 1"}, events.list())
+	})
+
+	t.Run("should keep ejections within the maximum share of endpoints", func(t *testing.T) {
+		other := failingServer(t)
+		client := httpx.NewClientWithConfig(httpx.WithClientServiceDiscovery(httpx.ServiceConfig{
+			Name:             "payments",
+			Resolver:         &staticResolver{endpoints: []httpx.Endpoint{bad, other}},
+			Balancer:         firstEndpoint,
+			OutlierDetection: &httpx.OutlierConfig{ConsecutiveFailures: 1},
+		}))
+		service := client.ServiceDiscovery()
+		defer service.Close()
+
+		for range 5 {
+			resp, err := client.Execute(*httpx.NewRequest(http.MethodGet), "")
+			require.NoError(t, err)
+			assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+		}
+
+		assert.Equal(t, httpx.OutlierStats{Ejections: 1, Ejected: []httpx.Endpoint{bad}}, service.OutlierStats())
+	})
+
+	t.Run("should report ejections as Prometheus metrics", func(t *testing.T) {
+		registry := prometheus.NewRegistry()
+		config := httpx.DefaultPrometheusConfig()
+		config.Registry = registry
+		client := httpx.NewClientWithConfig(
+			httpx.WithClientPrometheusMetrics(config),
+			httpx.WithClientServiceDiscovery(httpx.ServiceConfig{
+				Name:             "payments",
+				Resolver:         &staticResolver{endpoints: []httpx.Endpoint{bad, good}},
+				Balancer:         firstEndpoint,
+				OutlierDetection: &httpx.OutlierConfig{ConsecutiveFailures: 1},
+			}),
+		)
+		defer client.ServiceDiscovery().Close()
+
+		_, err := client.Execute(*httpx.NewRequest(http.MethodGet), "")
+		require.NoError(t, err)
+
+		families, err := registry.Gather()
+		require.NoError(t, err)
+		values := map[string]float64{}
+		for _, family := range families {
+			for _, metric := range family.GetMetric() {
+				values[family.GetName()] += metric.GetGauge().GetValue() + metric.GetCounter().GetValue()
+			}
+		}
+		assert.Equal(t, float64(1), values["http_client_endpoint_ejections_total"])
+		assert.Equal(t, float64(1), values["http_client_ejected_endpoints"])
+	})
+}
@@ -0,0 +1,84 @@
+package httpx
+
+import (
+	"iter"
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Paginate iterates over the pages of a collection, following the rel="next" links of the Link header of each
+// page (RFC 8288) until a page has none. Pages are decoded like Execute decodes respType.
+//
+// Every page is a GET request of its own, so the cache middleware stores pages individually: re-listing a
+// collection that barely changed revalidates each page with a conditional request and transfers only the pages
+// that changed. CacheMiddleware.InvalidatePrefix drops the pages of a collection after it is modified.
+//
+// Iteration stops after yielding an error: a failed request, a page with an error status, or a next link
+// pointing back to a page already visited.
+//
+//	for page, err := range client.Paginate(*httpx.NewRequest(http.MethodGet, httpx.WithPath("/users")), []User{}) {
+//		if err != nil {
+//			return err
+//		}
+//		users = append(users, page.Body.([]User)...)
+//	}
+func (c Client) Paginate(request Request, respType any) iter.Seq2[*Response, error] {
+	return func(yield func(*Response, error) bool) {
+		visited := make(map[string]bool)
+		page := request
+		for {
+			resp, err := c.Execute(page, respType)
+			if err == nil && resp.StatusCode >= 400 {
+				err = ClassifyError(nil, resp.httpResponse.Request, resp.httpResponse)
+			}
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+			if !yield(resp, nil) {
+				return
+			}
+
+			pageURL := resp.httpResponse.Request.URL
+			visited[pageURL.String()] = true
+			next, ok := nextPageURL(resp, pageURL)
+			if !ok {
+				return
+			}
+			if visited[next.String()] {
+				yield(nil, errors.Errorf("pagination loop: next page %s was already visited", next.Redacted()))
+				return
+			}
+			page = *request.Clone(withPageURL(next))
+		}
+	}
+}
+
+// nextPageURL returns the target of the rel="next" link of a page, resolved against the page URL
+func nextPageURL(resp *Response, pageURL *url.URL) (*url.URL, bool) {
+	for _, link := range ParseLinkHeader(strings.Join(resp.Header().Values("Link"), ",")) {
+		if !link.HasRel("next") {
+			continue
+		}
+		target, err := url.Parse(link.URL)
+		if err != nil {
+			return nil, false
+		}
+		return pageURL.ResolveReference(target), true
+	}
+	return nil, false
+}
+
+// withPageURL points a request at the URL of a page, replacing its base URL, path and query parameters
+func withPageURL(u *url.URL) RequestOption {
+	return func(c *RequestOptions) {
+		base := *u
+		base.RawQuery, base.ForceQuery, base.Fragment, base.RawFragment = "", false, "", ""
+		c.BaseURL = base.String()
+		c.Path = ""
+		c.PathParams = nil
+		c.QueryParams = u.Query()
+	}
+}
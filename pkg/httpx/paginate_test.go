@@ -0,0 +1,150 @@
+package httpx_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bdpiprava/easy-http/pkg/httpx"
+)
+
+// collectionServer serves a collection of three pages linked with rel="next", answering conditional requests
+type collectionServer struct {
+	*httptest.Server
+
+	mu           sync.Mutex
+	full         int // Pages sent with their body
+	notModified  int // Pages revalidated with 304
+	pageVersions map[string]int
+}
+
+func newCollectionServer() *collectionServer {
+	s := &collectionServer{pageVersions: map[string]int{"1": 1, "2": 1, "3": 1}}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page := r.URL.Query().Get("page")
+		if page == "" {
+			page = "1"
+		}
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		etag := fmt.Sprintf(`"p%s-v%d"`, page, s.pageVersions[page])
+		w.Header().Set("ETag", etag)
+		if number, _ := strconv.Atoi(page); number < 3 {
+			w.Header().Set("Link", fmt.Sprintf(`</items?page=%d>; rel="next"`, number+1))
+		}
+		if r.Header.Get("If-None-Match") == etag {
+			s.notModified++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		s.full++
+		_, _ = fmt.Fprintf(w, `["item %s.%d"]`, page, s.pageVersions[page])
+	}))
+	return s
+}
+
+// counts returns the pages sent with their body and those revalidated, resetting them
+func (s *collectionServer) counts() (int, int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	full, notModified := s.full, s.notModified
+	s.full, s.notModified = 0, 0
+	return full, notModified
+}
+
+func TestClient_Paginate(t *testing.T) {
+	list := func(t *testing.T, client *httpx.Client) []any {
+		var items []any
+		for page, err := range client.Paginate(*httpx.NewRequest(http.MethodGet, httpx.WithPath("/items")), []any{}) {
+			require.NoError(t, err)
+			items = append(items, page.Body.([]any)...)
+		}
+		return items
+	}
+
+	t.Run("follows next links", func(t *testing.T) {
+		server := newCollectionServer()
+		defer server.Close()
+		client := httpx.NewClientWithConfig(httpx.WithClientDefaultBaseURL(server.URL))
+
+		assert.Equal(t, []any{"item 1.1", "item 2.1", "item 3.1"}, list(t, client))
+	})
+
+	t.Run("revalidates cached pages individually", func(t *testing.T) {
+		server := newCollectionServer()
+		defer server.Close()
+		client := httpx.NewClientWithConfig(
+			httpx.WithClientDefaultBaseURL(server.URL),
+			httpx.WithClientCache(httpx.CacheConfig{}),
+		)
+		list(t, client)
+		_, _ = server.counts()
+
+		server.mu.Lock()
+		server.pageVersions["2"]++
+		server.mu.Unlock()
+		items := list(t, client)
+
+		assert.Equal(t, []any{"item 1.1", "item 2.2", "item 3.1"}, items)
+		full, notModified := server.counts()
+		assert.Equal(t, 1, full, "only the changed page is transferred")
+		assert.Equal(t, 2, notModified)
+	})
+
+	t.Run("invalidates the pages of a collection by prefix", func(t *testing.T) {
+		server := newCollectionServer()
+		defer server.Close()
+		client := httpx.NewClientWithConfig(
+			httpx.WithClientDefaultBaseURL(server.URL),
+			httpx.WithClientCache(httpx.CacheConfig{}),
+		)
+		list(t, client)
+		_, err := client.Execute(*httpx.NewRequest(http.MethodGet, httpx.WithPath("/items-archive")), []any{})
+		require.NoError(t, err)
+
+		invalidated, err := client.Cache().InvalidatePrefix(server.URL + "/items")
+
+		require.NoError(t, err)
+		assert.Equal(t, 3, invalidated)
+		_, _ = server.counts()
+		list(t, client)
+		full, notModified := server.counts()
+		assert.Equal(t, 3, full)
+		assert.Equal(t, 0, notModified)
+	})
+
+	t.Run("stops at pagination loops", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Query().Get("page") {
+			case "":
+				w.Header().Set("Link", `<?page=2>; rel="next"`)
+				_, _ = w.Write([]byte(`[]`))
+			case "2":
+				w.Header().Set("Link", `</loop>; rel="next"`)
+				_, _ = w.Write([]byte(`[]`))
+			}
+		}))
+		defer server.Close()
+		client := httpx.NewClientWithConfig(httpx.WithClientDefaultBaseURL(server.URL))
+
+		var pages int
+		var lastErr error
+		for _, err := range client.Paginate(*httpx.NewRequest(http.MethodGet, httpx.WithPath("/loop")), []any{}) {
+			if err != nil {
+				lastErr = err
+				break
+			}
+			pages++
+		}
+
+		assert.Equal(t, 2, pages)
+		require.Error(t, lastErr)
+		assert.Contains(t, lastErr.Error(), "pagination loop")
+	})
+}
@@ -0,0 +1,79 @@
+package httpx
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"net/url"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// pathParamPattern matches the {name} placeholders of a path template
+var pathParamPattern = regexp.MustCompile(`\{([^{}/]+)\}`)
+
+// pathTemplateKey is the context key holding the path template of a request built with path parameters
+type pathTemplateKey struct{}
+
+// ContextWithPathTemplate returns a context carrying the path template of the request, e.g. "/users/{id}"
+func ContextWithPathTemplate(ctx context.Context, template string) context.Context {
+	if template == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, pathTemplateKey{}, template)
+}
+
+// PathTemplateFromContext returns the path template carried by ctx, or "" if the request has no path parameters
+// The template is the low-cardinality counterpart of the URL path, suited to metric labels and span names.
+func PathTemplateFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	template, _ := ctx.Value(pathTemplateKey{}).(string)
+	return template
+}
+
+// applyPath appends the path of the request to u, expanding the placeholders of the template with params
+// Returns the path template joined to the base URL path, or "" when there are no path parameters.
+func applyPath(u *url.URL, template string, params map[string]string) (string, error) {
+	if len(params) == 0 {
+		u.Path = path.Join(u.Path, template)
+		return "", nil
+	}
+
+	var escaped strings.Builder
+	used := make(map[string]bool, len(params))
+	last := 0
+	for _, match := range pathParamPattern.FindAllStringSubmatchIndex(template, -1) {
+		name := template[match[2]:match[3]]
+		value, ok := params[name]
+		if !ok {
+			return "", ValidationError(fmt.Sprintf("missing path parameter %q of %q", name, template), nil)
+		}
+		if value == "" || value == "." || value == ".." {
+			return "", ValidationError(fmt.Sprintf("invalid value %q of path parameter %q", value, name), nil)
+		}
+		used[name] = true
+		escaped.WriteString((&url.URL{Path: template[last:match[0]]}).EscapedPath())
+		escaped.WriteString(url.PathEscape(value))
+		last = match[1]
+	}
+	escaped.WriteString((&url.URL{Path: template[last:]}).EscapedPath())
+
+	for _, name := range slices.Sorted(maps.Keys(params)) {
+		if !used[name] {
+			return "", ValidationError(fmt.Sprintf("path parameter %q is not in %q", name, template), nil)
+		}
+	}
+
+	joined := path.Join(u.EscapedPath(), escaped.String())
+	unescaped, err := url.PathUnescape(joined)
+	if err != nil {
+		return "", ValidationError("invalid path", err)
+	}
+	base := u.Path
+	u.Path, u.RawPath = unescaped, joined
+	return path.Join(base, template), nil
+}
@@ -0,0 +1,146 @@
+package httpx_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/bdpiprava/easy-http/pkg/httpx"
+)
+
+func TestWithPathParam(t *testing.T) {
+	var paths []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.EscapedPath())
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	t.Run("expands and escapes the path template", func(t *testing.T) {
+		paths = nil
+		client := httpx.NewClientWithConfig(httpx.WithClientDefaultBaseURL(server.URL + "/v1"))
+		req := httpx.NewRequest(http.MethodGet,
+			httpx.WithPath("/users/{id}/orders/{orderId}"),
+			httpx.WithPathParam("id", "a/b c"),
+			httpx.WithPathParams(map[string]string{"orderId": "42"}),
+		)
+
+		_, err := client.Execute(*req, map[string]any{})
+		require.NoError(t, err)
+
+		assert.Equal(t, []string{"/v1/users/a%2Fb%20c/orders/42"}, paths)
+	})
+
+	t.Run("keeps braces of paths without parameters", func(t *testing.T) {
+		paths = nil
+		client := httpx.NewClientWithConfig(httpx.WithClientDefaultBaseURL(server.URL))
+
+		_, err := client.Execute(*httpx.NewRequest(http.MethodGet, httpx.WithPath("/users/{id}")), map[string]any{})
+		require.NoError(t, err)
+
+		assert.Equal(t, []string{"/users/%7Bid%7D"}, paths)
+	})
+
+	t.Run("fails on invalid parameters", func(t *testing.T) {
+		client := httpx.NewClientWithConfig(httpx.WithClientDefaultBaseURL(server.URL))
+		for name, opts := range map[string][]httpx.RequestOption{
+			"missing value":       {httpx.WithPath("/users/{id}/orders/{orderId}"), httpx.WithPathParam("id", "1")},
+			"unknown placeholder": {httpx.WithPath("/users/{id}"), httpx.WithPathParam("id", "1"), httpx.WithPathParam("userId", "1")},
+			"dot segment":         {httpx.WithPath("/users/{id}"), httpx.WithPathParam("id", "..")},
+			"empty value":         {httpx.WithPath("/users/{id}"), httpx.WithPathParam("id", "")},
+		} {
+			t.Run(name, func(t *testing.T) {
+				paths = nil
+
+				_, err := client.Execute(*httpx.NewRequest(http.MethodGet, opts...), map[string]any{})
+
+				assert.True(t, httpx.IsValidationError(err), "got %v", err)
+				assert.Empty(t, paths)
+			})
+		}
+	})
+
+	t.Run("works with the generic helpers", func(t *testing.T) {
+		paths = nil
+
+		_, err := httpx.GET[map[string]any](httpx.WithBaseURL(server.URL), httpx.WithPath("/users/{id}"), httpx.WithPathParam("id", "7"))
+		require.NoError(t, err)
+
+		assert.Equal(t, []string{"/users/7"}, paths)
+	})
+}
+
+func TestWithPathParam_Observability(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	exporter := tracetest.NewInMemoryExporter()
+	registry := prometheus.NewRegistry()
+	config := httpx.DefaultPrometheusConfig()
+	config.Registry = registry
+	config.IncludeHostLabel = false
+	config.IncludePathLabel = true
+	client := httpx.NewClientWithConfig(
+		httpx.WithClientDefaultBaseURL(server.URL),
+		httpx.WithClientTracing(httpx.TracingConfig{TracerProvider: sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))}),
+		httpx.WithClientPrometheusMetrics(config),
+	)
+
+	for _, id := range []string{"1", "2"} {
+		resp, err := client.Execute(*httpx.NewRequest(http.MethodGet, httpx.WithPath("/users/{id}"), httpx.WithPathParam("id", id)), map[string]any{})
+		require.NoError(t, err)
+		require.Equal(t, http.StatusNotFound, resp.StatusCode)
+	}
+
+	t.Run("labels metrics with the path template", func(t *testing.T) {
+		families, err := registry.Gather()
+		require.NoError(t, err)
+		var paths []string
+		for _, family := range families {
+			if family.GetName() != "http_client_requests_total" {
+				continue
+			}
+			for _, metric := range family.GetMetric() {
+				for _, label := range metric.GetLabel() {
+					if label.GetName() == "path" {
+						paths = append(paths, label.GetValue())
+						assert.Equal(t, float64(2), metric.GetCounter().GetValue())
+					}
+				}
+			}
+		}
+		assert.Equal(t, []string{"/users/{id}"}, paths)
+	})
+
+	t.Run("names spans after the path template", func(t *testing.T) {
+		spans := exporter.GetSpans()
+		require.Len(t, spans, 2)
+		for _, span := range spans {
+			assert.Equal(t, "HTTP GET /users/{id}", span.Name)
+			assert.Contains(t, span.Attributes, attribute.String("http.route", "/users/{id}"))
+		}
+	})
+
+	t.Run("reports the path template as the operation", func(t *testing.T) {
+		req := httpx.NewRequest(http.MethodGet,
+			httpx.WithPath("/users/{id}"),
+			httpx.WithPathParam("id", "3"),
+			httpx.WithMiddleware(rejectingMiddleware{}),
+		)
+
+		_, err := client.Execute(*req, map[string]any{})
+
+		var httpErr *httpx.HTTPError
+		require.ErrorAs(t, err, &httpErr)
+		assert.Equal(t, "GET /users/{id}", httpErr.Op)
+	})
+}
@@ -0,0 +1,108 @@
+package httpx
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"sync"
+)
+
+// defaultPreloadConcurrency is the number of preload requests in flight unless WithPreloadConcurrency is used
+const defaultPreloadConcurrency = 4
+
+// PreloadOption configures Client.PreloadCache
+type PreloadOption func(*preloadConfig)
+
+// preloadConfig holds the settings of a PreloadCache call
+type preloadConfig struct {
+	concurrency int
+	progress    func(PreloadProgress)
+}
+
+// WithPreloadConcurrency bounds the number of preload requests in flight (default: 4)
+func WithPreloadConcurrency(limit int) PreloadOption {
+	return func(c *preloadConfig) {
+		if limit > 0 {
+			c.concurrency = limit
+		}
+	}
+}
+
+// WithPreloadProgress calls fn after each preload request completes
+// Calls are serialized, so fn needs no locking of its own.
+func WithPreloadProgress(fn func(PreloadProgress)) PreloadOption {
+	return func(c *preloadConfig) {
+		c.progress = fn
+	}
+}
+
+// PreloadProgress reports the progress of Client.PreloadCache after a request completes
+type PreloadProgress struct {
+	Total  int   // Number of requests to preload
+	Done   int   // Requests completed so far, loaded or failed
+	Failed int   // Requests completed so far that failed
+	Index  int   // Position in the requests of the request that just completed
+	Err    error // Failure of the request that just completed, nil if it loaded
+}
+
+// PreloadCache executes GET requests purely to populate the client cache, e.g. to warm reference data before
+// a service takes traffic. Requests go through the whole middleware chain, so rate limits and retries apply.
+//
+// All requests are attempted even if some fail. It returns a *GroupError indexed by position in requests
+// listing the failures, non-2xx responses and non-GET requests included, and a validation error if the client
+// has no cache configured.
+func (c Client) PreloadCache(ctx context.Context, requests []Request, opts ...PreloadOption) error {
+	if !slices.ContainsFunc(c.config.Middlewares, func(m Middleware) bool {
+		_, ok := m.(*CacheMiddleware)
+		return ok
+	}) {
+		return ValidationError("cache preloading requires a client cache, see WithClientCache", nil)
+	}
+
+	config := preloadConfig{concurrency: defaultPreloadConcurrency}
+	for _, opt := range opts {
+		opt(&config)
+	}
+
+	var mu sync.Mutex
+	progress := PreloadProgress{Total: len(requests)}
+	report := func(index int, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		progress.Done++
+		if err != nil {
+			progress.Failed++
+		}
+		progress.Index, progress.Err = index, err
+		if config.progress != nil {
+			config.progress(progress)
+		}
+	}
+
+	group := Group(ctx, &c, WithGroupLimit(config.concurrency), WithGroupContinueOnError())
+	for i, req := range requests {
+		group.GoFunc(func(ctx context.Context) error {
+			err := c.preload(ctx, req)
+			report(i, err)
+			return err
+		})
+	}
+	return group.Wait()
+}
+
+// preload executes a single GET request, reading its body so the cache stores the response
+func (c Client) preload(ctx context.Context, req Request) error {
+	req = *req.Clone(WithContext(ctx))
+	if method := buildOptsFromConfig(c.config, &req).Method; method != http.MethodGet {
+		return ValidationError("only GET requests can be preloaded, got "+method, nil)
+	}
+
+	resp, err := c.Execute(req, "")
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode >= 400 {
+		return ClassifyError(nil, resp.httpResponse.Request, resp.httpResponse)
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package httpx_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bdpiprava/easy-http/pkg/httpx"
+)
+
+func TestClient_PreloadCache(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Cache-Control", "max-age=300")
+		_, _ = w.Write([]byte(`{"path":"` + r.URL.Path + `"}`))
+	}))
+	defer server.Close()
+
+	t.Run("populates the cache and reports progress", func(t *testing.T) {
+		backend := httpx.NewInMemoryCache(0)
+		client := httpx.NewClientWithConfig(
+			httpx.WithClientDefaultBaseURL(server.URL),
+			httpx.WithClientCache(httpx.CacheConfig{Backend: backend}),
+		)
+		requests := []httpx.Request{
+			*httpx.NewRequest(http.MethodGet, httpx.WithPath("/countries")),
+			*httpx.NewRequest(http.MethodGet, httpx.WithPath("/missing")),
+			*httpx.NewRequest(http.MethodGet, httpx.WithPath("/currencies")),
+			*httpx.NewRequest(http.MethodPost, httpx.WithPath("/countries")),
+		}
+
+		var reports []httpx.PreloadProgress
+		err := client.PreloadCache(context.Background(), requests,
+			httpx.WithPreloadConcurrency(2),
+			httpx.WithPreloadProgress(func(p httpx.PreloadProgress) {
+				reports = append(reports, p)
+			}),
+		)
+
+		var groupErr *httpx.GroupError
+		require.ErrorAs(t, err, &groupErr)
+		require.Len(t, groupErr.Errors, 2)
+		assert.Equal(t, 1, groupErr.Errors[0].Index)
+		assert.Equal(t, 3, groupErr.Errors[1].Index)
+		assert.Equal(t, int64(2), backend.Stats().Size)
+
+		require.Len(t, reports, 4)
+		last := reports[3]
+		assert.Equal(t, 4, last.Total)
+		assert.Equal(t, 4, last.Done)
+		assert.Equal(t, 2, last.Failed)
+	})
+
+	t.Run("requires a cache", func(t *testing.T) {
+		client := httpx.NewClientWithConfig(httpx.WithClientDefaultBaseURL(server.URL))
+
+		err := client.PreloadCache(context.Background(), []httpx.Request{*httpx.NewRequest(http.MethodGet)})
+
+		assert.True(t, httpx.IsValidationError(err))
+	})
+
+	t.Run("stops when the context is done", func(t *testing.T) {
+		client := httpx.NewClientWithConfig(
+			httpx.WithClientDefaultBaseURL(server.URL),
+			httpx.WithClientDefaultCache(),
+		)
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		err := client.PreloadCache(ctx, []httpx.Request{*httpx.NewRequest(http.MethodGet, httpx.WithPath("/countries"))})
+
+		assert.True(t, errors.Is(err, context.Canceled))
+	})
+}
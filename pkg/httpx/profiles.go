@@ -0,0 +1,127 @@
+package httpx
+
+import (
+	"time"
+)
+
+// Profiles are curated option bundles tuned for common workloads. Pass one first to NewClientWithConfig
+// and add client specific options after it; later options override the timeouts, retry policy and
+// circuit breaker set by the profile:
+//
+//	client := httpx.NewClientWithConfig(
+//		httpx.ProfileThirdPartyAPI(),
+//		httpx.WithClientDefaultBaseURL("https://api.example.com"),
+//	)
+//
+// The numbers are starting points, derived from the latency budget of each workload rather than
+// from the production example; revisit them against the latency percentiles of the upstream.
+
+// ProfileInteractive tunes the client for user-facing calls where a fast failure beats a slow success
+//
+// The whole call, including one retry, fits a 5s budget: connections must be established within 1s and
+// the upstream must start responding within 3s. A single quick retry absorbs transient network blips,
+// and an aggressive circuit breaker sheds load from a failing upstream instead of queueing users behind it.
+// Calls slower than 1s are logged at Warn.
+func ProfileInteractive() ClientConfigOption {
+	return withClientOptions(
+		WithClientTimeout(5*time.Second),
+		WithClientDialTimeout(1*time.Second),
+		WithClientTLSHandshakeTimeout(2*time.Second),
+		WithClientResponseHeaderTimeout(3*time.Second),
+		WithClientRetryPolicy(RetryPolicy{
+			MaxAttempts:          2,
+			BaseDelay:            50 * time.Millisecond,
+			MaxDelay:             250 * time.Millisecond,
+			Strategy:             RetryStrategyExponentialJitter,
+			Multiplier:           2.0,
+			JitterMax:            50 * time.Millisecond,
+			Condition:            AdvancedDefaultRetryCondition,
+			RetryableStatusCodes: []int{502, 503, 504},
+			RetryableErrorTypes:  []ErrorType{ErrorTypeNetwork, ErrorTypeTimeout},
+		}),
+		WithClientCircuitBreaker(withCircuitBreakerName(AggressiveCircuitBreakerConfig(), "interactive")),
+		WithClientSlowRequestThreshold(1*time.Second),
+	)
+}
+
+// ProfileBatch tunes the client for background jobs where completing the work matters more than latency
+//
+// Long timeouts tolerate slow bulk endpoints, and up to five attempts with jittered exponential backoff
+// (capped at 30s) ride out deploys and brief outages without synchronized retry storms. The conservative
+// circuit breaker only opens on sustained failure, so a job is not aborted by a handful of errors.
+// Calls slower than 30s are logged at Warn.
+func ProfileBatch() ClientConfigOption {
+	return withClientOptions(
+		WithClientTimeout(2*time.Minute),
+		WithClientDialTimeout(10*time.Second),
+		WithClientTLSHandshakeTimeout(10*time.Second),
+		WithClientResponseHeaderTimeout(60*time.Second),
+		WithClientRetryPolicy(RetryPolicy{
+			MaxAttempts:          5,
+			BaseDelay:            500 * time.Millisecond,
+			MaxDelay:             30 * time.Second,
+			Strategy:             RetryStrategyExponentialJitter,
+			Multiplier:           2.0,
+			JitterMax:            500 * time.Millisecond,
+			Condition:            AggressiveRetryCondition,
+			RetryableStatusCodes: []int{408, 429, 500, 502, 503, 504},
+			RetryableErrorTypes:  []ErrorType{ErrorTypeNetwork, ErrorTypeTimeout, ErrorTypeServer},
+		}),
+		WithClientCircuitBreaker(withCircuitBreakerName(ConservativeCircuitBreakerConfig(), "batch")),
+		WithClientSlowRequestThreshold(30*time.Second),
+	)
+}
+
+// ProfileThirdPartyAPI tunes the client for external APIs with quotas and variable latency
+//
+// Requests are limited to 10 per second per host (bursts of 20) and wait up to 5s for a token rather than
+// failing, which keeps the client within typical vendor quotas. Retries back off exponentially with jitter
+// and include 429, and the default circuit breaker stops hammering a vendor during an outage.
+// Calls slower than 5s are logged at Warn.
+//
+// The rate limiter is a middleware, so unlike the other settings it cannot be overridden by later options;
+// build the client without the profile when the vendor quota differs significantly.
+func ProfileThirdPartyAPI() ClientConfigOption {
+	return withClientOptions(
+		WithClientTimeout(30*time.Second),
+		WithClientDialTimeout(5*time.Second),
+		WithClientTLSHandshakeTimeout(5*time.Second),
+		WithClientResponseHeaderTimeout(20*time.Second),
+		WithClientRetryPolicy(RetryPolicy{
+			MaxAttempts:          4,
+			BaseDelay:            250 * time.Millisecond,
+			MaxDelay:             10 * time.Second,
+			Strategy:             RetryStrategyExponentialJitter,
+			Multiplier:           2.0,
+			JitterMax:            250 * time.Millisecond,
+			Condition:            AdvancedDefaultRetryCondition,
+			RetryableStatusCodes: []int{429, 500, 502, 503, 504},
+			RetryableErrorTypes:  []ErrorType{ErrorTypeNetwork, ErrorTypeTimeout},
+		}),
+		WithClientCircuitBreaker(withCircuitBreakerName(DefaultCircuitBreakerConfig(), "third-party-api")),
+		WithClientRateLimit(RateLimitConfig{
+			Strategy:        RateLimitTokenBucket,
+			RequestsPerSec:  10,
+			BurstSize:       20,
+			PerHost:         true,
+			WaitOnLimit:     true,
+			MaxWaitDuration: 5 * time.Second,
+		}),
+		WithClientSlowRequestThreshold(5*time.Second),
+	)
+}
+
+// withClientOptions combines several options into one
+func withClientOptions(opts ...ClientConfigOption) ClientConfigOption {
+	return func(c *ClientConfig) {
+		for _, opt := range opts {
+			opt(c)
+		}
+	}
+}
+
+// withCircuitBreakerName renames a circuit breaker configuration
+func withCircuitBreakerName(config CircuitBreakerConfig, name string) CircuitBreakerConfig {
+	config.Name = name
+	return config
+}
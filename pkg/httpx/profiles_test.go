@@ -0,0 +1,94 @@
+package httpx_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bdpiprava/easy-http/pkg/httpx"
+)
+
+func TestProfiles(t *testing.T) {
+	profiles := []struct {
+		name           string
+		profile        httpx.ClientConfigOption
+		timeout        time.Duration
+		maxAttempts    int
+		circuitBreaker string
+		rateLimited    bool
+	}{
+		{name: "interactive", profile: httpx.ProfileInteractive(), timeout: 5 * time.Second, maxAttempts: 2, circuitBreaker: "interactive"},
+		{name: "batch", profile: httpx.ProfileBatch(), timeout: 2 * time.Minute, maxAttempts: 5, circuitBreaker: "batch"},
+		{name: "third party API", profile: httpx.ProfileThirdPartyAPI(), timeout: 30 * time.Second, maxAttempts: 4, circuitBreaker: "third-party-api", rateLimited: true},
+	}
+
+	for _, tt := range profiles {
+		t.Run(tt.name, func(t *testing.T) {
+			config := httpx.ClientConfig{}
+			tt.profile(&config)
+
+			assert.Equal(t, tt.timeout, config.Timeout)
+			assert.Positive(t, config.DialTimeout)
+			assert.Less(t, config.DialTimeout, config.Timeout)
+			assert.Positive(t, config.TLSHandshakeTimeout)
+			assert.Less(t, config.ResponseHeaderTimeout, config.Timeout)
+			assert.Positive(t, config.SlowRequestThreshold)
+			require.NotNil(t, config.RetryPolicy)
+			assert.Equal(t, tt.maxAttempts, config.RetryPolicy.MaxAttempts)
+			assert.Equal(t, httpx.RetryStrategyExponentialJitter, config.RetryPolicy.Strategy)
+			assert.LessOrEqual(t, config.RetryPolicy.MaxDelay, config.Timeout)
+			require.NotNil(t, config.CircuitBreakerConfig)
+			assert.Equal(t, tt.circuitBreaker, config.CircuitBreakerConfig.Name)
+
+			_, rateLimited := findMiddleware[*httpx.RateLimitMiddleware](config.Middlewares)
+			assert.Equal(t, tt.rateLimited, rateLimited)
+		})
+	}
+
+	t.Run("later options override the profile", func(t *testing.T) {
+		config := httpx.ClientConfig{}
+		for _, opt := range []httpx.ClientConfigOption{
+			httpx.ProfileInteractive(),
+			httpx.WithClientTimeout(time.Second),
+			httpx.WithClientConservativeRetryPolicy(),
+		} {
+			opt(&config)
+		}
+
+		assert.Equal(t, time.Second, config.Timeout)
+		assert.Equal(t, httpx.ConservativeRetryPolicy().MaxAttempts, config.RetryPolicy.MaxAttempts)
+		assert.Equal(t, 1*time.Second, config.DialTimeout)
+	})
+
+	t.Run("clients built from a profile execute requests", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"ok":true}`))
+		}))
+		defer server.Close()
+
+		for _, tt := range profiles {
+			client := httpx.NewClientWithConfig(tt.profile, httpx.WithClientDefaultBaseURL(server.URL))
+
+			resp, err := client.Execute(*httpx.NewRequest(http.MethodGet), map[string]any{})
+
+			require.NoError(t, err, tt.name)
+			assert.Equal(t, map[string]any{"ok": true}, resp.Body, tt.name)
+		}
+	})
+}
+
+// findMiddleware returns the first middleware of type T
+func findMiddleware[T httpx.Middleware](middlewares []httpx.Middleware) (T, bool) {
+	for _, middleware := range middlewares {
+		if m, ok := middleware.(T); ok {
+			return m, true
+		}
+	}
+	var zero T
+	return zero, false
+}
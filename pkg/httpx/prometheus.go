@@ -18,6 +18,7 @@ type PrometheusConfig struct {
 	SizeBuckets        []float64 // Bytes
 	IncludeHostLabel   bool
 	IncludeMethodLabel bool
+	IncludePathLabel   bool // Label requests with their path template, see WithPathParam, or their path otherwise
 	ExtraLabels        []string
 }
 
@@ -43,7 +44,23 @@ type PrometheusCollector struct {
 	requestSize      *prometheus.HistogramVec
 	responseSize     *prometheus.HistogramVec
 	errorsTotal      *prometheus.CounterVec
+	bodyDrainsTotal  *prometheus.CounterVec
 	inFlightRequests prometheus.Gauge
+
+	connections          *prometheus.GaugeVec
+	dialsTotal           *prometheus.CounterVec
+	tlsHandshakeFailures *prometheus.CounterVec
+
+	endpointEjections *prometheus.CounterVec
+	ejectedEndpoints  *prometheus.GaugeVec
+
+	concurrencyLimit    prometheus.Gauge
+	concurrencyInflight prometheus.Gauge
+
+	cacheLookups  *prometheus.CounterVec
+	cacheStores   *prometheus.CounterVec
+	cacheEntryAge *prometheus.HistogramVec
+	cacheEntryTTL *prometheus.HistogramVec
 }
 
 // NewPrometheusCollector creates a new Prometheus metrics collector
@@ -60,6 +77,9 @@ func NewPrometheusCollector(config PrometheusConfig) (*PrometheusCollector, erro
 	if config.IncludeHostLabel {
 		labels = append(labels, "host")
 	}
+	if config.IncludePathLabel {
+		labels = append(labels, "path")
+	}
 	labels = append(labels, config.ExtraLabels...)
 
 	// Error labels
@@ -75,6 +95,15 @@ func NewPrometheusCollector(config PrometheusConfig) (*PrometheusCollector, erro
 		config: config,
 	}
 
+	// Body drain labels
+	drainLabels := []string{"outcome"}
+	if config.IncludeMethodLabel {
+		drainLabels = append(drainLabels, "method")
+	}
+	if config.IncludeHostLabel {
+		drainLabels = append(drainLabels, "host")
+	}
+
 	// Register metrics
 	factory := promauto.With(config.Registry)
 
@@ -131,6 +160,16 @@ func NewPrometheusCollector(config PrometheusConfig) (*PrometheusCollector, erro
 		errorLabels,
 	)
 
+	collector.bodyDrainsTotal = factory.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: config.Namespace,
+			Subsystem: config.Subsystem,
+			Name:      "response_body_drains_total",
+			Help:      "Total number of response bodies drained, discarded or leaked without being consumed by the caller",
+		},
+		drainLabels,
+	)
+
 	collector.inFlightRequests = factory.NewGauge(
 		prometheus.GaugeOpts{
 			Namespace: config.Namespace,
@@ -140,6 +179,121 @@ func NewPrometheusCollector(config PrometheusConfig) (*PrometheusCollector, erro
 		},
 	)
 
+	collector.connections = factory.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: config.Namespace,
+			Subsystem: config.Subsystem,
+			Name:      "connections",
+			Help:      "Current number of pooled connections by host and state (idle, active)",
+		},
+		[]string{"host", "state"},
+	)
+
+	collector.dialsTotal = factory.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: config.Namespace,
+			Subsystem: config.Subsystem,
+			Name:      "dials_total",
+			Help:      "Total number of connection attempts by host and outcome (success, failure)",
+		},
+		[]string{"host", "outcome"},
+	)
+
+	collector.tlsHandshakeFailures = factory.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: config.Namespace,
+			Subsystem: config.Subsystem,
+			Name:      "tls_handshake_failures_total",
+			Help:      "Total number of failed TLS handshakes by host",
+		},
+		[]string{"host"},
+	)
+
+	collector.endpointEjections = factory.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: config.Namespace,
+			Subsystem: config.Subsystem,
+			Name:      "endpoint_ejections_total",
+			Help:      "Total number of service endpoints ejected by outlier detection",
+		},
+		[]string{"service"},
+	)
+
+	collector.ejectedEndpoints = factory.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: config.Namespace,
+			Subsystem: config.Subsystem,
+			Name:      "ejected_endpoints",
+			Help:      "Current number of service endpoints ejected by outlier detection",
+		},
+		[]string{"service"},
+	)
+
+	collector.concurrencyLimit = factory.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: config.Namespace,
+			Subsystem: config.Subsystem,
+			Name:      "concurrency_limit",
+			Help:      "Current adaptive limit of requests in flight",
+		},
+	)
+
+	collector.concurrencyInflight = factory.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: config.Namespace,
+			Subsystem: config.Subsystem,
+			Name:      "concurrency_inflight",
+			Help:      "Current number of requests in flight counted by the concurrency limiter",
+		},
+	)
+
+	cacheBuckets := make([]float64, len(cacheAgeBuckets))
+	for i, bound := range cacheAgeBuckets {
+		cacheBuckets[i] = bound.Seconds()
+	}
+
+	collector.cacheLookups = factory.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: config.Namespace,
+			Subsystem: config.Subsystem,
+			Name:      "cache_lookups_total",
+			Help:      "Total number of cacheable requests by cache statistics group and result (hit, miss)",
+		},
+		[]string{"group", "result"},
+	)
+
+	collector.cacheStores = factory.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: config.Namespace,
+			Subsystem: config.Subsystem,
+			Name:      "cache_stores_total",
+			Help:      "Total number of responses stored or renewed in the cache by cache statistics group",
+		},
+		[]string{"group"},
+	)
+
+	collector.cacheEntryAge = factory.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: config.Namespace,
+			Subsystem: config.Subsystem,
+			Name:      "cache_entry_age_seconds",
+			Help:      "Age of the cache entries answering requests by cache statistics group",
+			Buckets:   cacheBuckets,
+		},
+		[]string{"group"},
+	)
+
+	collector.cacheEntryTTL = factory.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: config.Namespace,
+			Subsystem: config.Subsystem,
+			Name:      "cache_entry_ttl_seconds",
+			Help:      "Freshness lifetime of the responses stored in the cache by cache statistics group",
+			Buckets:   cacheBuckets,
+		},
+		[]string{"group"},
+	)
+
 	return collector, nil
 }
 
@@ -179,6 +333,74 @@ func (c *PrometheusCollector) IncrementErrors(method, rawURL string, statusCode
 	c.errorsTotal.With(errorLabels).Inc()
 }
 
+// ObserveBodyDrain implements BodyDrainObserver interface
+func (c *PrometheusCollector) ObserveBodyDrain(method, rawURL string, outcome BodyDrainOutcome) {
+	drainLabels := prometheus.Labels{"outcome": string(outcome)}
+	if c.config.IncludeMethodLabel {
+		drainLabels["method"] = method
+	}
+	if c.config.IncludeHostLabel {
+		drainLabels["host"] = c.extractHost(rawURL)
+	}
+
+	c.bodyDrainsTotal.With(drainLabels).Inc()
+}
+
+// ObserveConnPool implements ConnPoolObserver interface
+func (c *PrometheusCollector) ObserveConnPool(host string, idle, active int) {
+	c.connections.WithLabelValues(host, "idle").Set(float64(idle))
+	c.connections.WithLabelValues(host, "active").Set(float64(active))
+}
+
+// ObserveDial implements ConnPoolObserver interface
+func (c *PrometheusCollector) ObserveDial(host string, err error) {
+	outcome := "success"
+	if err != nil {
+		outcome = "failure"
+	}
+	c.dialsTotal.WithLabelValues(host, outcome).Inc()
+}
+
+// ObserveTLSHandshake implements ConnPoolObserver interface
+func (c *PrometheusCollector) ObserveTLSHandshake(host string, err error) {
+	if err != nil {
+		c.tlsHandshakeFailures.WithLabelValues(host).Inc()
+	}
+}
+
+// ObserveEjection implements OutlierObserver interface
+func (c *PrometheusCollector) ObserveEjection(service string, _ Endpoint, ejected int) {
+	c.endpointEjections.WithLabelValues(service).Inc()
+	c.ejectedEndpoints.WithLabelValues(service).Set(float64(ejected))
+}
+
+// ObserveReturn implements OutlierObserver interface
+func (c *PrometheusCollector) ObserveReturn(service string, _ Endpoint, ejected int) {
+	c.ejectedEndpoints.WithLabelValues(service).Set(float64(ejected))
+}
+
+// ObserveConcurrency implements ConcurrencyObserver interface
+func (c *PrometheusCollector) ObserveConcurrency(limit, inflight int) {
+	c.concurrencyLimit.Set(float64(limit))
+	c.concurrencyInflight.Set(float64(inflight))
+}
+
+// ObserveCacheLookup implements CacheObserver interface
+func (c *PrometheusCollector) ObserveCacheLookup(group string, hit bool, age time.Duration) {
+	if !hit {
+		c.cacheLookups.WithLabelValues(group, "miss").Inc()
+		return
+	}
+	c.cacheLookups.WithLabelValues(group, "hit").Inc()
+	c.cacheEntryAge.WithLabelValues(group).Observe(age.Seconds())
+}
+
+// ObserveCacheStore implements CacheObserver interface
+func (c *PrometheusCollector) ObserveCacheStore(group string, ttl time.Duration) {
+	c.cacheStores.WithLabelValues(group).Inc()
+	c.cacheEntryTTL.WithLabelValues(group).Observe(ttl.Seconds())
+}
+
 // RecordDuration implements MetricsCollector interface
 func (c *PrometheusCollector) RecordDuration(method, rawURL string, duration time.Duration) {
 	c.inFlightRequests.Dec()
@@ -217,6 +439,10 @@ func (c *PrometheusCollector) buildLabels(method, rawURL string, statusCode int)
 		labels["host"] = c.extractHost(rawURL)
 	}
 
+	if c.config.IncludePathLabel {
+		labels["path"] = c.extractPath(rawURL)
+	}
+
 	return labels
 }
 
@@ -228,3 +454,12 @@ func (c *PrometheusCollector) extractHost(rawURL string) string {
 	}
 	return u.Host
 }
+
+// extractPath extracts the path from a URL string, the path template of requests built with path parameters
+func (c *PrometheusCollector) extractPath(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "unknown"
+	}
+	return u.Path
+}
@@ -1,6 +1,7 @@
 package httpx
 
 import (
+	"context"
 	"net"
 	"net/http"
 	"net/url"
@@ -17,6 +18,8 @@ const (
 	schemeHTTPS  = "https"
 	schemeSOCKS4 = "socks4"
 	schemeSOCKS5 = "socks5"
+	// socks5h resolves host names at the proxy rather than locally, as curl does
+	schemeSOCKS5H = "socks5h"
 )
 
 // ProxyConfig holds proxy configuration settings
@@ -33,6 +36,10 @@ type ProxyConfig struct {
 
 	// ProxyAuth contains credentials for proxy authentication
 	ProxyAuth *ProxyAuth
+
+	// Via is a SOCKS proxy through which connections to an HTTP/HTTPS ProxyURL are made, chaining the HTTP
+	// proxy behind it. Credentials for Via are taken from its user info.
+	Via *url.URL
 }
 
 // ProxyAuth holds proxy authentication credentials
@@ -48,7 +55,9 @@ func GetSystemProxyFunc() func(*http.Request) (*url.URL, error) {
 }
 
 // ParseProxyURL parses and validates a proxy URL
-// Supports: http://, https://, socks4://, socks5://
+// Supports: http://, https://, socks4://, socks5://, socks5h://
+// socks5:// resolves host names locally and sends IP addresses to the proxy, socks5h:// sends host names to
+// the proxy for resolution, which is needed for names only the proxy can resolve such as Tor or internal hosts.
 func ParseProxyURL(proxyURL string) (*url.URL, error) {
 	if proxyURL == "" {
 		return nil, errors.New("proxy URL cannot be empty")
@@ -61,10 +70,10 @@ func ParseProxyURL(proxyURL string) (*url.URL, error) {
 
 	// Validate proxy scheme
 	switch u.Scheme {
-	case schemeHTTP, schemeHTTPS, schemeSOCKS4, schemeSOCKS5:
+	case schemeHTTP, schemeHTTPS, schemeSOCKS4, schemeSOCKS5, schemeSOCKS5H:
 		// Valid schemes
 	default:
-		return nil, errors.Errorf("unsupported proxy scheme '%s': must be http, https, socks4, or socks5", u.Scheme)
+		return nil, errors.Errorf("unsupported proxy scheme '%s': must be http, https, socks4, socks5, or socks5h", u.Scheme)
 	}
 
 	// Validate host
@@ -125,7 +134,7 @@ func matchesNoProxyPattern(host, pattern string) bool {
 
 	// CIDR pattern: 192.168.0.0/16
 	if strings.Contains(pattern, "/") {
-		return matchesCIDR(host, pattern)
+		return matchesCIDR(host, pattern, true)
 	}
 
 	// Domain suffix match: .example.com
@@ -136,8 +145,8 @@ func matchesNoProxyPattern(host, pattern string) bool {
 	return false
 }
 
-// matchesCIDR checks if a host IP matches a CIDR pattern
-func matchesCIDR(host, cidr string) bool {
+// matchesCIDR checks if a host IP matches a CIDR pattern, resolving host names only when resolve is set
+func matchesCIDR(host, cidr string, resolve bool) bool {
 	// Parse CIDR
 	_, ipNet, err := net.ParseCIDR(cidr)
 	if err != nil {
@@ -147,6 +156,9 @@ func matchesCIDR(host, cidr string) bool {
 	// Resolve host to IP
 	hostIP := net.ParseIP(host)
 	if hostIP == nil {
+		if !resolve {
+			return false
+		}
 		// Try resolving hostname
 		ips, err := net.LookupIP(host)
 		if err != nil || len(ips) == 0 {
@@ -213,6 +225,11 @@ func CreateSOCKSDialer(socksURL *url.URL, auth *ProxyAuth) (proxy.Dialer, error)
 		dialer, err = proxy.SOCKS5("tcp", host, nil, proxy.Direct)
 	case schemeSOCKS5:
 		dialer, err = proxy.SOCKS5("tcp", host, socksAuth, proxy.Direct)
+		if err == nil {
+			dialer = &resolvingDialer{dialer: dialer}
+		}
+	case schemeSOCKS5H:
+		dialer, err = proxy.SOCKS5("tcp", host, socksAuth, proxy.Direct)
 	default:
 		return nil, errors.Errorf("unsupported SOCKS scheme: %s", socksURL.Scheme)
 	}
@@ -224,6 +241,108 @@ func CreateSOCKSDialer(socksURL *url.URL, auth *ProxyAuth) (proxy.Dialer, error)
 	return dialer, nil
 }
 
+// resolvingDialer resolves host names locally before dialing through a SOCKS proxy, so that the proxy only
+// ever sees IP addresses
+type resolvingDialer struct {
+	dialer proxy.Dialer
+}
+
+// Dial connects to addr through the proxy
+func (d *resolvingDialer) Dial(network, addr string) (net.Conn, error) {
+	return d.DialContext(context.Background(), network, addr)
+}
+
+// DialContext resolves the host of addr and connects to the first reachable address through the proxy
+func (d *resolvingDialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	if net.ParseIP(host) != nil {
+		return dialSOCKS(ctx, d.dialer, network, addr)
+	}
+
+	ips, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	for _, ip := range ips {
+		var conn net.Conn
+		conn, err = dialSOCKS(ctx, d.dialer, network, net.JoinHostPort(ip.IP.String(), port))
+		if err == nil {
+			return conn, nil
+		}
+	}
+	return nil, err
+}
+
+// dialSOCKS dials addr through dialer, honoring ctx when the dialer supports it
+func dialSOCKS(ctx context.Context, dialer proxy.Dialer, network, addr string) (net.Conn, error) {
+	if contextDialer, ok := dialer.(proxy.ContextDialer); ok {
+		return contextDialer.DialContext(ctx, network, addr)
+	}
+	return dialer.Dial(network, addr)
+}
+
+// isSOCKSScheme reports whether scheme is a SOCKS proxy scheme
+func isSOCKSScheme(scheme string) bool {
+	return scheme == schemeSOCKS4 || scheme == schemeSOCKS5 || scheme == schemeSOCKS5H
+}
+
+// socksDialContext returns a DialContext connecting through the SOCKS proxy of config, except for hosts
+// matching NoProxy which are dialed with direct. Host names are never resolved locally to match CIDR
+// patterns for socks5h, which would leak them to the local resolver.
+func socksDialContext(config *ProxyConfig, direct func(ctx context.Context, network, addr string) (net.Conn, error)) (func(ctx context.Context, network, addr string) (net.Conn, error), error) {
+	dialer, err := CreateSOCKSDialer(config.ProxyURL, config.ProxyAuth)
+	if err != nil {
+		return nil, err
+	}
+	resolve := config.ProxyURL.Scheme != schemeSOCKS5H
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if host, _, err := net.SplitHostPort(addr); err == nil && bypassesSOCKSProxy(host, config.NoProxy, resolve) {
+			return direct(ctx, network, addr)
+		}
+		return dialSOCKS(ctx, dialer, network, addr)
+	}, nil
+}
+
+// bypassesSOCKSProxy checks if host matches the no-proxy patterns, resolving it for CIDR patterns only
+// when resolve is set
+func bypassesSOCKSProxy(host string, noProxyList []string, resolve bool) bool {
+	for _, pattern := range noProxyList {
+		if strings.Contains(pattern, "/") {
+			if matchesCIDR(strings.TrimSpace(host), strings.TrimSpace(pattern), resolve) {
+				return true
+			}
+			continue
+		}
+		if matchesNoProxyPattern(host, pattern) {
+			return true
+		}
+	}
+	return false
+}
+
+// viaDialContext returns a DialContext connecting through the SOCKS proxy via, with credentials from its
+// user info
+func viaDialContext(via *url.URL) (func(ctx context.Context, network, addr string) (net.Conn, error), error) {
+	if !isSOCKSScheme(via.Scheme) {
+		return nil, errors.Errorf("unsupported via proxy scheme '%s': must be socks4, socks5, or socks5h", via.Scheme)
+	}
+	var auth *ProxyAuth
+	if via.User != nil {
+		password, _ := via.User.Password()
+		auth = &ProxyAuth{Username: via.User.Username(), Password: password}
+	}
+	dialer, err := CreateSOCKSDialer(via, auth)
+	if err != nil {
+		return nil, err
+	}
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return dialSOCKS(ctx, dialer, network, addr)
+	}, nil
+}
+
 // GetSystemNoProxy returns the NO_PROXY environment variable as a list
 func GetSystemNoProxy() []string {
 	noProxy := os.Getenv("NO_PROXY")
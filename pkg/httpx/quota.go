@@ -0,0 +1,226 @@
+package httpx
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// defaultQuotaThresholds are the shares of a quota consumed that trigger QuotaConfig.OnThreshold
+var defaultQuotaThresholds = []float64{0.8, 0.95}
+
+// QuotaConfig configures a strict request quota, such as the daily quota of a paid API
+type QuotaConfig struct {
+	Limit       int                                             // Requests allowed per period
+	Period      time.Duration                                   // Length of a period (default: 24h)
+	Location    *time.Location                                  // Time zone whose midnight starts daily periods (default: UTC)
+	File        string                                          // File persisting the consumed count across restarts (empty keeps it in memory)
+	Thresholds  []float64                                       // Shares of the quota consumed that trigger OnThreshold (default: 0.8 and 0.95)
+	OnThreshold func(threshold float64, status RateLimitStatus) // Called once per period as each threshold is crossed
+}
+
+// QuotaMiddleware rejects requests once the quota of the current period is consumed
+//
+// Requests count against the quota when sent, whatever their outcome, so a crash never under-counts.
+// With QuotaConfig.File, the count is written to the file before each request is sent and read back on
+// creation, so restarts, including crash loops, resume from the consumed count instead of a fresh quota.
+// The file must not be shared by concurrent processes.
+type QuotaMiddleware struct {
+	config QuotaConfig
+	err    error // Error loading the persisted count, failing every request
+
+	mu          sync.Mutex
+	periodStart time.Time
+	used        int
+}
+
+// quotaState is the persisted state of a quota
+type quotaState struct {
+	PeriodStart time.Time `json:"period_start"`
+	Used        int       `json:"used"`
+}
+
+// NewQuotaMiddleware creates a quota middleware, restoring the consumed count of the current period from
+// config.File if it exists
+func NewQuotaMiddleware(config QuotaConfig) (*QuotaMiddleware, error) {
+	m := newQuotaMiddleware(config)
+	if m.err != nil {
+		return nil, m.err
+	}
+	return m, nil
+}
+
+// newQuotaMiddleware creates a quota middleware, keeping the error of loading the persisted count
+func newQuotaMiddleware(config QuotaConfig) *QuotaMiddleware {
+	if config.Period <= 0 {
+		config.Period = 24 * time.Hour
+	}
+	if config.Location == nil {
+		config.Location = time.UTC
+	}
+	if config.Thresholds == nil {
+		config.Thresholds = defaultQuotaThresholds
+	}
+	config.Thresholds = slices.Sorted(slices.Values(config.Thresholds))
+
+	m := &QuotaMiddleware{config: config, periodStart: config.periodStart(time.Now())}
+	if config.File == "" {
+		return m
+	}
+	data, err := os.ReadFile(config.File)
+	if errors.Is(err, os.ErrNotExist) {
+		return m
+	}
+	if err != nil {
+		m.err = errors.Wrap(err, "failed to read quota file")
+		return m
+	}
+	var state quotaState
+	if err := json.Unmarshal(data, &state); err != nil {
+		m.err = errors.Wrap(err, "failed to decode quota file")
+		return m
+	}
+	if state.PeriodStart.Equal(m.periodStart) {
+		m.used = state.Used
+	}
+	return m
+}
+
+// Name returns the middleware name
+func (m *QuotaMiddleware) Name() string {
+	return "quota"
+}
+
+// Execute implements the Middleware interface
+func (m *QuotaMiddleware) Execute(ctx context.Context, req *http.Request, next MiddlewareFunc) (*http.Response, error) {
+	if err := m.consume(); err != nil {
+		return nil, &HTTPError{
+			Op:      requestOperation(req),
+			Type:    ErrorTypeMiddleware,
+			Message: err.Error(),
+			Cause:   err,
+			Request: req,
+		}
+	}
+	return next(ctx, req)
+}
+
+// Remaining returns the requests left in the current period
+func (m *QuotaMiddleware) Remaining() int {
+	return m.Status().Remaining
+}
+
+// Status returns the quota, the requests left and the end of the current period
+func (m *QuotaMiddleware) Status() RateLimitStatus {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.roll(time.Now())
+	return m.status()
+}
+
+// consume counts a request against the quota, persisting the count before the request is sent
+func (m *QuotaMiddleware) consume() error {
+	if m.err != nil {
+		return m.err
+	}
+
+	m.mu.Lock()
+	m.roll(time.Now())
+	if m.used >= m.config.Limit {
+		status := m.status()
+		m.mu.Unlock()
+		return tagError(errors.Errorf("quota of %d requests exhausted until %s", status.Limit, status.ResetAt.Format(time.RFC3339)), ErrRateLimited)
+	}
+	m.used++
+	if err := m.save(); err != nil {
+		m.used--
+		m.mu.Unlock()
+		return err
+	}
+	status := m.status()
+	var crossed []float64
+	for _, threshold := range m.config.Thresholds {
+		mark := threshold * float64(m.config.Limit)
+		if float64(m.used-1) < mark && float64(m.used) >= mark {
+			crossed = append(crossed, threshold)
+		}
+	}
+	m.mu.Unlock()
+
+	if m.config.OnThreshold != nil {
+		for _, threshold := range crossed {
+			m.config.OnThreshold(threshold, status)
+		}
+	}
+	return nil
+}
+
+// roll starts a new period with a fresh quota once the current one ended, the caller holding the lock
+func (m *QuotaMiddleware) roll(now time.Time) {
+	if start := m.config.periodStart(now); start.After(m.periodStart) {
+		m.periodStart = start
+		m.used = 0
+	}
+}
+
+// status returns the quota status, the caller holding the lock
+func (m *QuotaMiddleware) status() RateLimitStatus {
+	return RateLimitStatus{
+		Limit:     m.config.Limit,
+		Remaining: max(m.config.Limit-m.used, 0),
+		ResetAt:   m.config.periodEnd(m.periodStart),
+	}
+}
+
+// save writes the count to the quota file, replacing it atomically, the caller holding the lock
+func (m *QuotaMiddleware) save() error {
+	if m.config.File == "" {
+		return nil
+	}
+	data, err := json.Marshal(quotaState{PeriodStart: m.periodStart, Used: m.used})
+	if err != nil {
+		return errors.Wrap(err, "failed to encode quota state")
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(m.config.File), filepath.Base(m.config.File)+".*")
+	if err != nil {
+		return errors.Wrap(err, "failed to write quota file")
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return errors.Wrap(err, "failed to write quota file")
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return errors.Wrap(err, "failed to write quota file")
+	}
+	if err := tmp.Close(); err != nil {
+		return errors.Wrap(err, "failed to write quota file")
+	}
+	return errors.Wrap(os.Rename(tmp.Name(), m.config.File), "failed to write quota file")
+}
+
+// periodStart returns the start of the period containing t: midnight in Location for daily periods,
+// otherwise a multiple of Period since the Unix epoch
+func (c QuotaConfig) periodStart(t time.Time) time.Time {
+	if c.Period == 24*time.Hour {
+		t = t.In(c.Location)
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, c.Location)
+	}
+	return t.Truncate(c.Period)
+}
+
+// periodEnd returns the end of the period starting at start
+func (c QuotaConfig) periodEnd(start time.Time) time.Time {
+	if c.Period == 24*time.Hour {
+		return start.AddDate(0, 0, 1)
+	}
+	return start.Add(c.Period)
+}
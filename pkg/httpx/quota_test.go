@@ -0,0 +1,105 @@
+package httpx_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bdpiprava/easy-http/pkg/httpx"
+)
+
+func TestQuota(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+	send := func(client *httpx.Client) error {
+		_, err := client.Execute(*httpx.NewRequest(http.MethodGet), nil)
+		return err
+	}
+
+	t.Run("should reject requests once the quota is consumed", func(t *testing.T) {
+		client := httpx.NewClientWithConfig(
+			httpx.WithClientDefaultBaseURL(server.URL),
+			httpx.WithClientQuota(httpx.QuotaConfig{Limit: 2}),
+		)
+
+		require.NoError(t, send(client))
+		assert.Equal(t, 1, client.Quota().Remaining())
+		require.NoError(t, send(client))
+
+		err := send(client)
+		assert.True(t, errors.Is(err, httpx.ErrRateLimited))
+		status := client.Quota().Status()
+		assert.Equal(t, 0, status.Remaining)
+		now := time.Now().UTC()
+		assert.Equal(t, time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, time.UTC), status.ResetAt)
+	})
+
+	t.Run("should resume the consumed count after a restart", func(t *testing.T) {
+		file := filepath.Join(t.TempDir(), "quota.json")
+		config := httpx.QuotaConfig{Limit: 3, File: file}
+
+		client := httpx.NewClientWithConfig(httpx.WithClientDefaultBaseURL(server.URL), httpx.WithClientQuota(config))
+		require.NoError(t, send(client))
+		require.NoError(t, send(client))
+
+		restarted := httpx.NewClientWithConfig(httpx.WithClientDefaultBaseURL(server.URL), httpx.WithClientQuota(config))
+		assert.Equal(t, 1, restarted.Quota().Remaining())
+		require.NoError(t, send(restarted))
+		assert.True(t, errors.Is(send(restarted), httpx.ErrRateLimited))
+	})
+
+	t.Run("should start a fresh quota in a new period", func(t *testing.T) {
+		file := filepath.Join(t.TempDir(), "quota.json")
+		yesterday := time.Now().UTC().AddDate(0, 0, -1).Truncate(24 * time.Hour)
+		require.NoError(t, os.WriteFile(file, []byte(`{"period_start":"`+yesterday.Format(time.RFC3339)+`","used":5}`), 0o600))
+
+		quota, err := httpx.NewQuotaMiddleware(httpx.QuotaConfig{Limit: 5, File: file})
+		require.NoError(t, err)
+		assert.Equal(t, 5, quota.Remaining())
+	})
+
+	t.Run("should fail closed when the quota file is corrupt", func(t *testing.T) {
+		file := filepath.Join(t.TempDir(), "quota.json")
+		require.NoError(t, os.WriteFile(file, []byte("{"), 0o600))
+		config := httpx.QuotaConfig{Limit: 5, File: file}
+
+		_, err := httpx.NewQuotaMiddleware(config)
+		assert.ErrorContains(t, err, "failed to decode quota file")
+
+		client := httpx.NewClientWithConfig(httpx.WithClientDefaultBaseURL(server.URL), httpx.WithClientQuota(config))
+		assert.ErrorContains(t, send(client), "failed to decode quota file")
+	})
+
+	t.Run("should call back once as each threshold is crossed", func(t *testing.T) {
+		var crossed []float64
+		var remaining []int
+		client := httpx.NewClientWithConfig(
+			httpx.WithClientDefaultBaseURL(server.URL),
+			httpx.WithClientQuota(httpx.QuotaConfig{
+				Limit:  20,
+				Period: time.Hour,
+				OnThreshold: func(threshold float64, status httpx.RateLimitStatus) {
+					crossed = append(crossed, threshold)
+					remaining = append(remaining, status.Remaining)
+				},
+			}),
+		)
+
+		for range 20 {
+			require.NoError(t, send(client))
+		}
+
+		assert.Equal(t, []float64{0.8, 0.95}, crossed)
+		assert.Equal(t, []int{4, 1}, remaining)
+		assert.Equal(t, time.Now().Truncate(time.Hour).Add(time.Hour), client.Quota().Status().ResetAt)
+	})
+}
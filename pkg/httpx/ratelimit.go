@@ -7,6 +7,7 @@ import (
 	"math"
 	"net/http"
 	"net/url"
+	"slices"
 	"strconv"
 	"sync"
 	"time"
@@ -80,38 +81,80 @@ func NewTokenBucketLimiter(requestsPerSec float64, burstSize int) *TokenBucketLi
 }
 
 // Allow implements RateLimiter interface
+// The token is reserved up front, so concurrent callers queue behind each other instead of waking together,
+// and is returned to the bucket if ctx is done before the wait ends
 func (r *TokenBucketLimiter) Allow(ctx context.Context) error {
-	r.mu.Lock()
-
-	// Refill tokens based on elapsed time
-	now := time.Now()
-	elapsed := now.Sub(r.lastRefill).Seconds()
-	r.tokens = math.Min(float64(r.capacity), r.tokens+elapsed*r.rate)
-	r.lastRefill = now
-
-	// Check if token is available
-	if r.tokens >= 1.0 {
-		r.tokens -= 1.0
-		r.mu.Unlock()
+	waitTime := r.Reserve(1)
+	if waitTime == 0 {
 		return nil
 	}
 
-	// Calculate wait time
-	waitTime := time.Duration((1.0-r.tokens)/r.rate*1000) * time.Millisecond
-	r.mu.Unlock()
-
 	// Wait for token
+	timer := time.NewTimer(waitTime)
+	defer timer.Stop()
 	select {
 	case <-ctx.Done():
-		return ctx.Err()
-	case <-time.After(waitTime):
 		r.mu.Lock()
-		r.tokens = math.Max(0, r.tokens-1.0)
+		r.tokens = math.Min(float64(r.capacity), r.tokens+1.0)
 		r.mu.Unlock()
+		return ctx.Err()
+	case <-timer.C:
 		return nil
 	}
 }
 
+// Available returns the number of tokens currently in the bucket
+// It is negative while reservations exceed the bucket
+func (r *TokenBucketLimiter) Available() float64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.refill(time.Now())
+	return r.tokens
+}
+
+// Reserve takes n tokens from the bucket and returns how long the caller must wait before they are available
+// Tokens that are not available are borrowed from future refills, delaying later requests accordingly
+func (r *TokenBucketLimiter) Reserve(n int) time.Duration {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.refill(time.Now())
+	r.tokens -= float64(n)
+	if r.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-r.tokens / r.rate * float64(time.Second))
+}
+
+// delay returns how long a request would wait for a token, without taking it
+func (r *TokenBucketLimiter) delay() time.Duration {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.refill(time.Now())
+	if r.tokens >= 1.0 {
+		return 0
+	}
+	return time.Duration((1.0 - r.tokens) / r.rate * float64(time.Second))
+}
+
+// SetRate changes the refill rate, keeping the tokens accumulated at the previous rate
+func (r *TokenBucketLimiter) SetRate(requestsPerSec float64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.refill(time.Now())
+	r.rate = requestsPerSec
+}
+
+// refill adds the tokens accumulated since the last refill, the caller holds r.mu
+func (r *TokenBucketLimiter) refill(now time.Time) {
+	elapsed := now.Sub(r.lastRefill).Seconds()
+	r.tokens = math.Min(float64(r.capacity), r.tokens+elapsed*r.rate)
+	r.lastRefill = now
+}
+
 // UpdateFromHeaders implements RateLimiter interface
 func (r *TokenBucketLimiter) UpdateFromHeaders(headers http.Header) {
 	r.mu.Lock()
@@ -201,12 +244,22 @@ func (m *RateLimitMiddleware) Execute(ctx context.Context, req *http.Request, ne
 		defer cancel()
 	}
 
+	if m.config.WaitOnLimit && eventsEnabled(ctx) {
+		if bucket, ok := limiter.(interface{ delay() time.Duration }); ok {
+			if wait := bucket.delay(); wait > 0 {
+				emitEvent(ctx, RateLimitEvent{Time: time.Now(), Op: requestOperation(req), Host: req.URL.Host, Wait: wait})
+			}
+		}
+	}
+
 	if err := limiter.Allow(waitCtx); err != nil {
 		if errors.Is(err, context.DeadlineExceeded) {
+			emitEvent(ctx, RateLimitEvent{Time: time.Now(), Op: requestOperation(req), Host: req.URL.Host, Rejected: true})
 			return nil, &HTTPError{
+				Op:      requestOperation(req),
 				Type:    ErrorTypeMiddleware,
 				Message: fmt.Sprintf("rate limit wait timeout exceeded: %v", m.config.MaxWaitDuration),
-				Cause:   err,
+				Cause:   tagError(err, ErrRateLimited),
 				Request: req,
 			}
 		}
@@ -226,9 +279,15 @@ func (m *RateLimitMiddleware) Execute(ctx context.Context, req *http.Request, ne
 	if resp.StatusCode == http.StatusTooManyRequests {
 		if retryAfter := resp.Header.Get("Retry-After"); retryAfter != "" {
 			// Parse Retry-After header (can be seconds or HTTP date)
-			if seconds, err := strconv.Atoi(retryAfter); err == nil {
-				waitDuration := time.Duration(seconds) * time.Second
+			if waitDuration, ok := ParseRetryAfter(retryAfter, time.Now()); ok {
 				if m.config.WaitOnLimit && waitDuration <= m.config.MaxWaitDuration {
+					emitEvent(ctx, RateLimitEvent{
+						Time:       time.Now(),
+						Op:         requestOperation(req),
+						Host:       req.URL.Host,
+						Wait:       waitDuration,
+						StatusCode: resp.StatusCode,
+					})
 					time.Sleep(waitDuration)
 					// Retry the request
 					return m.Execute(ctx, req, next)
@@ -242,9 +301,14 @@ func (m *RateLimitMiddleware) Execute(ctx context.Context, req *http.Request, ne
 
 // getLimiter gets or creates a rate limiter for the given URL
 func (m *RateLimitMiddleware) getLimiter(u *url.URL) RateLimiter {
+	return m.hostLimiter(u.Host)
+}
+
+// hostLimiter gets or creates the rate limiter applied to host, which is the global limiter unless PerHost is set
+func (m *RateLimitMiddleware) hostLimiter(host string) RateLimiter {
 	key := "global"
 	if m.config.PerHost {
-		key = u.Host
+		key = host
 	}
 
 	m.mu.RLock()
@@ -280,3 +344,55 @@ func (m *RateLimitMiddleware) GetStatus(u *url.URL) RateLimitStatus {
 	limiter := m.getLimiter(u)
 	return limiter.Status()
 }
+
+// tokenBucket is implemented by limiters supporting token introspection and manual management
+type tokenBucket interface {
+	Available() float64
+	Reserve(n int) time.Duration
+	SetRate(requestsPerSec float64)
+}
+
+// Hosts returns the hosts with a rate limiter, or "global" when limits are not applied per host
+func (m *RateLimitMiddleware) Hosts() []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	hosts := make([]string, 0, len(m.limiters))
+	for host := range m.limiters {
+		hosts = append(hosts, host)
+	}
+	slices.Sort(hosts)
+	return hosts
+}
+
+// Available returns the tokens remaining for host, negative while reservations exceed the bucket
+// The host is ignored unless PerHost is set
+func (m *RateLimitMiddleware) Available(host string) float64 {
+	if bucket, ok := m.hostLimiter(host).(tokenBucket); ok {
+		return bucket.Available()
+	}
+	return 0
+}
+
+// Reserve takes n tokens from the limiter of host, e.g. for work done outside the client against the same quota
+// It returns how long until the tokens are available; requests sent meanwhile wait for them as well
+func (m *RateLimitMiddleware) Reserve(host string, n int) time.Duration {
+	if bucket, ok := m.hostLimiter(host).(tokenBucket); ok {
+		return bucket.Reserve(n)
+	}
+	return 0
+}
+
+// SetRate changes the requests per second allowed to host at runtime, e.g. after a quota change
+// The host is ignored unless PerHost is set; hosts without traffic yet are created with the new rate
+func (m *RateLimitMiddleware) SetRate(host string, requestsPerSec float64) error {
+	if requestsPerSec <= 0 {
+		return fmt.Errorf("rate must be positive, got %v", requestsPerSec)
+	}
+	bucket, ok := m.hostLimiter(host).(tokenBucket)
+	if !ok {
+		return fmt.Errorf("rate limiter for %q does not support changing the rate", host)
+	}
+	bucket.SetRate(requestsPerSec)
+	return nil
+}
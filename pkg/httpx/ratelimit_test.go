@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"testing"
@@ -566,6 +567,69 @@ func TestRateLimitMiddleware_Execute_429Response(t *testing.T) {
 		assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
 		assert.Equal(t, 1, callCount, "Should not retry")
 	})
+
+	t.Run("does not retry 429 with a malformed Retry-After", func(t *testing.T) {
+		t.Parallel()
+
+		for _, retryAfter := range []string{"-1", "99999999999999999999", "soon"} {
+			callCount := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				callCount++
+				w.Header().Set("Retry-After", retryAfter)
+				w.WriteHeader(http.StatusTooManyRequests)
+				_, _ = w.Write([]byte(`{"error":"rate limited"}`))
+			}))
+
+			client := httpx.NewClientWithConfig(
+				httpx.WithClientDefaultBaseURL(server.URL),
+				httpx.WithClientRateLimit(httpx.RateLimitConfig{
+					RequestsPerSec:  10,
+					BurstSize:       5,
+					WaitOnLimit:     true,
+					MaxWaitDuration: time.Second,
+				}),
+			)
+
+			resp, err := client.Execute(*httpx.NewRequest(http.MethodGet), map[string]any{})
+			server.Close()
+
+			require.NoError(t, err)
+			assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode, retryAfter)
+			assert.Equal(t, 1, callCount, retryAfter)
+		}
+	})
+
+	t.Run("honours Retry-After given as an HTTP date", func(t *testing.T) {
+		t.Parallel()
+
+		callCount := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			callCount++
+			if callCount == 1 {
+				w.Header().Set("Retry-After", time.Now().Add(-time.Minute).UTC().Format(http.TimeFormat))
+				w.WriteHeader(http.StatusTooManyRequests)
+				return
+			}
+			_, _ = w.Write([]byte(`{"success":true}`))
+		}))
+		defer server.Close()
+
+		client := httpx.NewClientWithConfig(
+			httpx.WithClientDefaultBaseURL(server.URL),
+			httpx.WithClientRateLimit(httpx.RateLimitConfig{
+				RequestsPerSec:  10,
+				BurstSize:       5,
+				WaitOnLimit:     true,
+				MaxWaitDuration: time.Second,
+			}),
+		)
+
+		resp, err := client.Execute(*httpx.NewRequest(http.MethodGet), map[string]any{})
+
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, 2, callCount)
+	})
 }
 
 func TestRateLimitMiddleware_Execute_UpdatesFromHeaders(t *testing.T) {
@@ -844,3 +908,137 @@ func TestRateLimitMiddleware_TokenRefill(t *testing.T) {
 		assert.Equal(t, 3, callCount)
 	})
 }
+
+func TestTokenBucketLimiter_ManualTokens(t *testing.T) {
+	t.Parallel()
+
+	t.Run("reports available tokens", func(t *testing.T) {
+		t.Parallel()
+
+		subject := httpx.NewTokenBucketLimiter(1, 3)
+		require.NoError(t, subject.Allow(context.Background()))
+
+		assert.InDelta(t, 2, subject.Available(), 0.1)
+	})
+
+	t.Run("reserves tokens from future refills", func(t *testing.T) {
+		t.Parallel()
+
+		subject := httpx.NewTokenBucketLimiter(10, 2)
+
+		assert.Equal(t, time.Duration(0), subject.Reserve(2))
+		wait := subject.Reserve(1)
+
+		assert.InDelta(t, 100*time.Millisecond, wait, float64(10*time.Millisecond))
+		assert.Less(t, subject.Available(), 0.0)
+	})
+
+	t.Run("returns the token when the wait is cancelled", func(t *testing.T) {
+		t.Parallel()
+
+		subject := httpx.NewTokenBucketLimiter(0.1, 1)
+		require.NoError(t, subject.Allow(context.Background()))
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Millisecond)
+		defer cancel()
+		require.ErrorIs(t, subject.Allow(ctx), context.DeadlineExceeded)
+
+		assert.InDelta(t, 0, subject.Available(), 0.01)
+	})
+
+	t.Run("changes the refill rate", func(t *testing.T) {
+		t.Parallel()
+
+		subject := httpx.NewTokenBucketLimiter(0.1, 1)
+		subject.Reserve(1)
+		subject.SetRate(100)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+		assert.NoError(t, subject.Allow(ctx))
+	})
+}
+
+func TestClient_RateLimiter(t *testing.T) {
+	t.Parallel()
+
+	t.Run("returns nil without rate limiting", func(t *testing.T) {
+		t.Parallel()
+
+		assert.Nil(t, httpx.NewClientWithConfig().RateLimiter())
+	})
+
+	t.Run("exposes per host tokens", func(t *testing.T) {
+		t.Parallel()
+
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			_, _ = w.Write([]byte(`{}`))
+		}))
+		defer server.Close()
+		host := strings.TrimPrefix(server.URL, "http://")
+
+		client := httpx.NewClientWithConfig(
+			httpx.WithClientDefaultBaseURL(server.URL),
+			httpx.WithClientRateLimit(httpx.RateLimitConfig{RequestsPerSec: 1, BurstSize: 5, PerHost: true}),
+		)
+		limiter := client.RateLimiter()
+		require.NotNil(t, limiter)
+
+		_, err := client.Execute(*httpx.NewRequest(http.MethodGet), nil)
+		require.NoError(t, err)
+
+		assert.Equal(t, []string{host}, limiter.Hosts())
+		assert.InDelta(t, 4, limiter.Available(host), 0.1)
+		assert.InDelta(t, 5, limiter.Available("other.example.com"), 0.1)
+	})
+
+	t.Run("reserved tokens limit requests", func(t *testing.T) {
+		t.Parallel()
+
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			_, _ = w.Write([]byte(`{}`))
+		}))
+		defer server.Close()
+
+		client := httpx.NewClientWithConfig(
+			httpx.WithClientDefaultBaseURL(server.URL),
+			httpx.WithClientRateLimit(httpx.RateLimitConfig{RequestsPerSec: 0.1, BurstSize: 2}),
+		)
+		client.RateLimiter().Reserve("", 2)
+
+		_, err := client.Execute(*httpx.NewRequest(http.MethodGet), nil)
+
+		assert.ErrorIs(t, err, httpx.ErrRateLimited)
+	})
+
+	t.Run("adjusts the rate at runtime", func(t *testing.T) {
+		t.Parallel()
+
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			_, _ = w.Write([]byte(`{}`))
+		}))
+		defer server.Close()
+		host := strings.TrimPrefix(server.URL, "http://")
+
+		client := httpx.NewClientWithConfig(
+			httpx.WithClientDefaultBaseURL(server.URL),
+			httpx.WithClientRateLimit(httpx.RateLimitConfig{
+				RequestsPerSec:  0.1,
+				BurstSize:       1,
+				PerHost:         true,
+				WaitOnLimit:     true,
+				MaxWaitDuration: time.Second,
+			}),
+		)
+		limiter := client.RateLimiter()
+		require.NoError(t, limiter.SetRate(host, 100))
+		require.Error(t, limiter.SetRate(host, 0))
+
+		start := time.Now()
+		for range 3 {
+			_, err := client.Execute(*httpx.NewRequest(http.MethodGet), nil)
+			require.NoError(t, err)
+		}
+		assert.Less(t, time.Since(start), 500*time.Millisecond)
+	})
+}
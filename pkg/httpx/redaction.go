@@ -0,0 +1,514 @@
+package httpx
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	// emailPattern matches email addresses
+	emailPattern = regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`)
+	// cardNumberPattern matches candidate payment card numbers of 13 to 19 digits, optionally grouped by spaces
+	// or dashes, which are then checked with the Luhn algorithm
+	cardNumberPattern = regexp.MustCompile(`\b\d(?:[ \-]?\d){12,18}\b`)
+)
+
+// RedactionRule selects values of outgoing request bodies to mask
+//
+// Path and Field select values of JSON bodies, Field also selects the fields of form bodies. Selected values
+// are replaced by Mask, or only the matches of Pattern within them if it is set. A rule with only a Pattern
+// masks its matches in every string of JSON and form bodies, and in text bodies.
+type RedactionRule struct {
+	Name     string                  // Name the redacted values are counted under (default: the path, field or pattern)
+	Path     string                  // JSONPath selecting values, e.g. $.user.email, $..ssn or $.cards[*].number
+	Field    string                  // Field name selected at any depth, ignoring case
+	Pattern  *regexp.Regexp          // Masks the matches within selected values instead of the whole values
+	Validate func(match string) bool // Filters the matches of Pattern, e.g. by checksum (default: every match)
+	Mask     string                  // Replacement of redacted values (default: "[REDACTED]")
+}
+
+// RedactEmails returns a rule masking email addresses anywhere in request bodies
+func RedactEmails() RedactionRule {
+	return RedactionRule{Name: "email", Pattern: emailPattern}
+}
+
+// RedactCardNumbers returns a rule masking payment card numbers passing the Luhn check anywhere in request bodies
+func RedactCardNumbers() RedactionRule {
+	return RedactionRule{Name: "card_number", Pattern: cardNumberPattern, Validate: isLuhnValid}
+}
+
+// RedactionConfig configures the masking of PII in request bodies before they leave the process
+type RedactionConfig struct {
+	Rules []RedactionRule // Rules applied in order
+	Hosts []string        // Hosts whose requests are redacted, e.g. third-party analytics APIs (default: every host)
+}
+
+// RedactionStats holds audit counters of the values masked by a RedactionMiddleware
+type RedactionStats struct {
+	Requests int64            // Requests with at least one value masked
+	Redacted map[string]int64 // Values masked, by rule name
+}
+
+// RedactionMiddleware masks PII in JSON, form and text request bodies according to its rules
+//
+// The redacted body also replaces GetBody, so retries and any later middleware, such as compression,
+// encryption or message signing, never see the original values.
+// Other content types are sent unchanged.
+type RedactionMiddleware struct {
+	rules []redactionRule
+	hosts []string
+	err   error // Error parsing the rules, failing every request
+
+	requests atomic.Int64
+	mu       sync.Mutex
+	redacted map[string]int64
+}
+
+// redactionRule is a RedactionRule with its path parsed
+type redactionRule struct {
+	RedactionRule
+	path []jsonPathSegment // Nil for pattern-only rules
+}
+
+// NewRedactionMiddleware creates a redaction middleware, failing if a rule has an invalid path or selects nothing
+func NewRedactionMiddleware(config RedactionConfig) (*RedactionMiddleware, error) {
+	m := newRedactionMiddleware(config)
+	if m.err != nil {
+		return nil, m.err
+	}
+	return m, nil
+}
+
+// newRedactionMiddleware creates a redaction middleware, keeping the error of parsing the rules
+func newRedactionMiddleware(config RedactionConfig) *RedactionMiddleware {
+	m := &RedactionMiddleware{hosts: config.Hosts, redacted: make(map[string]int64)}
+	for _, rule := range config.Rules {
+		parsed := redactionRule{RedactionRule: rule}
+		switch {
+		case rule.Path != "":
+			path, err := parseJSONPath(rule.Path)
+			if err != nil {
+				m.err = err
+				return m
+			}
+			parsed.path = path
+		case rule.Field != "":
+			parsed.path = []jsonPathSegment{{key: rule.Field, index: -1, recursive: true, foldCase: true}}
+		case rule.Pattern == nil:
+			m.err = errors.New("redaction rule needs a path, a field or a pattern")
+			return m
+		}
+		if parsed.Name == "" {
+			parsed.Name = defaultRuleName(rule)
+		}
+		if parsed.Mask == "" {
+			parsed.Mask = redactedValue
+		}
+		m.rules = append(m.rules, parsed)
+	}
+	return m
+}
+
+// defaultRuleName returns the path, the field or the pattern of a rule, whichever is set first
+func defaultRuleName(rule RedactionRule) string {
+	switch {
+	case rule.Path != "":
+		return rule.Path
+	case rule.Field != "":
+		return rule.Field
+	default:
+		return rule.Pattern.String()
+	}
+}
+
+// Name returns the middleware name
+func (m *RedactionMiddleware) Name() string {
+	return "redaction"
+}
+
+// Execute implements the Middleware interface
+func (m *RedactionMiddleware) Execute(ctx context.Context, req *http.Request, next MiddlewareFunc) (*http.Response, error) {
+	if m.err != nil {
+		return nil, MiddlewareError("invalid redaction rules", m.err, req)
+	}
+	if req.Body == nil || req.Body == http.NoBody || !m.redacts(req.URL.Hostname()) {
+		return next(ctx, req)
+	}
+
+	body, err := readRequestBody(req)
+	if err != nil {
+		return nil, MiddlewareError("failed to redact request body", err, req)
+	}
+	redacted, counts := m.redact(req.Header.Get("Content-Type"), body)
+	m.record(counts)
+	setRequestBody(req, redacted)
+	return next(ctx, req)
+}
+
+// Stats returns the audit counters of the values masked so far
+func (m *RedactionMiddleware) Stats() RedactionStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stats := RedactionStats{Requests: m.requests.Load(), Redacted: make(map[string]int64, len(m.redacted))}
+	for name, count := range m.redacted {
+		stats.Redacted[name] = count
+	}
+	return stats
+}
+
+// redacts reports whether requests to host are redacted
+func (m *RedactionMiddleware) redacts(host string) bool {
+	if len(m.hosts) == 0 {
+		return true
+	}
+	for _, candidate := range m.hosts {
+		if strings.EqualFold(candidate, host) {
+			return true
+		}
+	}
+	return false
+}
+
+// record adds the values masked in a request to the audit counters
+func (m *RedactionMiddleware) record(counts map[string]int) {
+	if len(counts) == 0 {
+		return
+	}
+	m.requests.Add(1)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for name, count := range counts {
+		m.redacted[name] += int64(count)
+	}
+}
+
+// redact returns the body with the rules applied according to its content type, and the values masked by rule
+func (m *RedactionMiddleware) redact(contentType string, body []byte) ([]byte, map[string]int) {
+	counts := make(map[string]int)
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch {
+	case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"):
+		return m.redactJSON(body, counts), counts
+	case mediaType == "application/x-www-form-urlencoded":
+		return m.redactForm(body, counts), counts
+	case strings.HasPrefix(mediaType, "text/"):
+		text := string(body)
+		for _, rule := range m.rules {
+			if rule.path == nil {
+				var n int
+				if text, n = rule.maskMatches(text); n > 0 {
+					counts[rule.Name] += n
+				}
+			}
+		}
+		return []byte(text), counts
+	default:
+		return body, counts
+	}
+}
+
+// redactJSON applies the rules to a JSON body, returning bodies that are not JSON or need no redaction unchanged
+func (m *RedactionMiddleware) redactJSON(body []byte, counts map[string]int) []byte {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var value any
+	if decoder.Decode(&value) != nil || decoder.More() {
+		return body
+	}
+
+	total := 0
+	for _, rule := range m.rules {
+		visit := func(selected any, set func(any)) {
+			redacted, n := rule.redact(selected)
+			if n > 0 {
+				set(redacted)
+				counts[rule.Name] += n
+				total += n
+			}
+		}
+		if rule.path == nil {
+			visit(value, func(redacted any) { value = redacted })
+			continue
+		}
+		walkJSONPath(value, rule.path, visit)
+	}
+	if total == 0 {
+		return body
+	}
+	redacted, err := marshalCompactJSON(value)
+	if err != nil {
+		return body
+	}
+	return redacted
+}
+
+// redactForm applies the field and pattern rules to a form body
+func (m *RedactionMiddleware) redactForm(body []byte, counts map[string]int) []byte {
+	form, err := url.ParseQuery(string(body))
+	if err != nil {
+		return body
+	}
+
+	total := 0
+	for _, rule := range m.rules {
+		if rule.Path != "" {
+			continue
+		}
+		for key, values := range form {
+			if rule.Field != "" && !strings.EqualFold(rule.Field, key) {
+				continue
+			}
+			for i, value := range values {
+				redacted, n := rule.redact(value)
+				if n > 0 {
+					values[i] = redacted.(string)
+					counts[rule.Name] += n
+					total += n
+				}
+			}
+		}
+	}
+	if total == 0 {
+		return body
+	}
+	return []byte(form.Encode())
+}
+
+// redact returns the value masked, and the number of values masked
+// Without a pattern, the whole value is replaced unless it is null; with one, the matches within the strings
+// and numbers of the value are.
+func (r redactionRule) redact(value any) (any, int) {
+	if r.Pattern == nil {
+		if value == nil {
+			return nil, 0
+		}
+		return r.Mask, 1
+	}
+	switch v := value.(type) {
+	case string:
+		return r.maskMatches(v)
+	case json.Number:
+		if masked, n := r.maskMatches(v.String()); n > 0 {
+			return masked, n
+		}
+		return v, 0
+	case map[string]any:
+		total := 0
+		for key, item := range v {
+			redacted, n := r.redact(item)
+			if n > 0 {
+				v[key] = redacted
+				total += n
+			}
+		}
+		return v, total
+	case []any:
+		total := 0
+		for i, item := range v {
+			redacted, n := r.redact(item)
+			if n > 0 {
+				v[i] = redacted
+				total += n
+			}
+		}
+		return v, total
+	default:
+		return value, 0
+	}
+}
+
+// maskMatches replaces the valid matches of the rule pattern in s with the mask
+func (r redactionRule) maskMatches(s string) (string, int) {
+	n := 0
+	masked := r.Pattern.ReplaceAllStringFunc(s, func(match string) string {
+		if r.Validate != nil && !r.Validate(match) {
+			return match
+		}
+		n++
+		return r.Mask
+	})
+	return masked, n
+}
+
+// isLuhnValid reports whether the digits of s pass the Luhn checksum, ignoring spaces and dashes
+func isLuhnValid(s string) bool {
+	sum, digits := 0, 0
+	for i := len(s) - 1; i >= 0; i-- {
+		c := s[i]
+		if c == ' ' || c == '-' {
+			continue
+		}
+		if c < '0' || c > '9' {
+			return false
+		}
+		d := int(c - '0')
+		if digits%2 == 1 {
+			if d *= 2; d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		digits++
+	}
+	return digits > 0 && sum%10 == 0
+}
+
+// setRequestBody replaces the body of req, replayed by GetBody on retries
+func setRequestBody(req *http.Request, body []byte) {
+	req.ContentLength = int64(len(body))
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(body)), nil
+	}
+}
+
+// jsonPathSegment is a step of a JSONPath: a member, an array index or a wildcard, at any depth if recursive
+type jsonPathSegment struct {
+	key       string
+	index     int // Array index, or -1 for members and wildcards
+	wildcard  bool
+	recursive bool
+	foldCase  bool // Members match ignoring case
+}
+
+// parseJSONPath parses the subset of JSONPath made of $, .member, ..member, ['member'], [index] and wildcards
+func parseJSONPath(path string) ([]jsonPathSegment, error) {
+	invalid := errors.Errorf("invalid JSONPath %q", path)
+	rest, ok := strings.CutPrefix(path, "$")
+	if !ok {
+		return nil, invalid
+	}
+	var segments []jsonPathSegment
+	for rest != "" {
+		segment := jsonPathSegment{index: -1}
+		switch {
+		case strings.HasPrefix(rest, ".."):
+			segment.recursive = true
+			rest = rest[2:]
+		case strings.HasPrefix(rest, "."):
+			rest = rest[1:]
+		case strings.HasPrefix(rest, "["):
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return nil, invalid
+			}
+			selector := rest[1:end]
+			rest = rest[end+1:]
+			switch {
+			case selector == "*":
+				segment.wildcard = true
+			case len(selector) >= 2 && (selector[0] == '\'' || selector[0] == '"') && selector[len(selector)-1] == selector[0]:
+				segment.key = selector[1 : len(selector)-1]
+			default:
+				index, err := strconv.Atoi(selector)
+				if err != nil || index < 0 {
+					return nil, invalid
+				}
+				segment.index = index
+			}
+			segments = append(segments, segment)
+			continue
+		default:
+			return nil, invalid
+		}
+
+		if strings.HasPrefix(rest, "[") && segment.recursive {
+			// "..[*]" and "..[0]" apply the bracket selector at any depth
+			next, err := parseJSONPath("$" + rest)
+			if err != nil || len(next) == 0 {
+				return nil, invalid
+			}
+			next[0].recursive = true
+			return append(segments, next...), nil
+		}
+		end := strings.IndexAny(rest, ".[")
+		if end < 0 {
+			end = len(rest)
+		}
+		name := rest[:end]
+		rest = rest[end:]
+		if name == "" {
+			return nil, invalid
+		}
+		if name == "*" {
+			segment.wildcard = true
+		} else {
+			segment.key = name
+		}
+		segments = append(segments, segment)
+	}
+	if len(segments) == 0 {
+		return nil, errors.Errorf("JSONPath %q selects the whole body, use a pattern-only rule instead", path)
+	}
+	return segments, nil
+}
+
+// walkJSONPath calls visit with every value selected by path under value, and a function replacing it
+func walkJSONPath(value any, path []jsonPathSegment, visit func(selected any, set func(any))) {
+	segment := path[0]
+	if segment.recursive {
+		here := segment
+		here.recursive = false
+		walkJSONPath(value, append([]jsonPathSegment{here}, path[1:]...), visit)
+		forEachJSONChild(value, func(child any) {
+			walkJSONPath(child, path, visit)
+		})
+		return
+	}
+
+	switch v := value.(type) {
+	case map[string]any:
+		if segment.index >= 0 {
+			return
+		}
+		for key, child := range v {
+			if !segment.wildcard && key != segment.key && !(segment.foldCase && strings.EqualFold(key, segment.key)) {
+				continue
+			}
+			set := func(redacted any) { v[key] = redacted }
+			if len(path) == 1 {
+				visit(child, set)
+			} else {
+				walkJSONPath(child, path[1:], visit)
+			}
+		}
+	case []any:
+		for i, child := range v {
+			if !segment.wildcard && i != segment.index {
+				continue
+			}
+			set := func(redacted any) { v[i] = redacted }
+			if len(path) == 1 {
+				visit(child, set)
+			} else {
+				walkJSONPath(child, path[1:], visit)
+			}
+		}
+	}
+}
+
+// forEachJSONChild calls fn with every member or element of a JSON object or array
+func forEachJSONChild(value any, fn func(child any)) {
+	switch v := value.(type) {
+	case map[string]any:
+		for _, child := range v {
+			fn(child)
+		}
+	case []any:
+		for _, child := range v {
+			fn(child)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package httpx_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bdpiprava/easy-http/pkg/httpx"
+)
+
+func TestRedaction(t *testing.T) {
+	var received string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received = string(body)
+	}))
+	defer server.Close()
+
+	post := func(t *testing.T, client *httpx.Client, baseURL string, opts ...httpx.RequestOption) {
+		t.Helper()
+		opts = append([]httpx.RequestOption{httpx.WithBaseURL(baseURL)}, opts...)
+		_, err := client.Execute(*httpx.NewRequest(http.MethodPost, opts...), nil)
+		require.NoError(t, err)
+	}
+
+	t.Run("should mask JSON values selected by path, field and pattern", func(t *testing.T) {
+		client := httpx.NewClientWithConfig(httpx.WithClientRedaction(httpx.RedactionConfig{
+			Rules: []httpx.RedactionRule{
+				{Path: "$.items[*].card"},
+				{Field: "ssn", Mask: "***"},
+				httpx.RedactEmails(),
+				httpx.RedactCardNumbers(),
+			},
+		}))
+
+		post(t, client, server.URL, httpx.WithJSONBody(map[string]any{
+			"user":  map[string]any{"SSN": "123-45-6789", "note": "reach me at jane@example.com"},
+			"items": []any{map[string]any{"card": "4111", "qty": 1}},
+			"pan":   "4111 1111 1111 1111",
+			"order": "1234567890123",
+		}))
+
+		assert.JSONEq(t, `{
+			"user": {"SSN": "***", "note": "reach me at [REDACTED]"},
+			"items": [{"card": "[REDACTED]", "qty": 1}],
+			"pan": "[REDACTED]",
+			"order": "1234567890123"
+		}`, received)
+		stats := client.Redaction().Stats()
+		assert.Equal(t, int64(1), stats.Requests)
+		assert.Equal(t, map[string]int64{"$.items[*].card": 1, "ssn": 1, "email": 1, "card_number": 1}, stats.Redacted)
+	})
+
+	t.Run("should mask form fields and text bodies", func(t *testing.T) {
+		client := httpx.NewClientWithConfig(httpx.WithClientRedaction(httpx.RedactionConfig{
+			Rules: []httpx.RedactionRule{{Field: "phone"}, httpx.RedactEmails()},
+		}))
+
+		post(t, client, server.URL,
+			httpx.WithHeader("Content-Type", "application/x-www-form-urlencoded"),
+			httpx.WithBody(strings.NewReader("phone=555-0100&email=jane%40example.com&plan=pro")))
+		assert.Equal(t, "email=%5BREDACTED%5D&phone=%5BREDACTED%5D&plan=pro", received)
+
+		post(t, client, server.URL,
+			httpx.WithHeader("Content-Type", "text/plain"),
+			httpx.WithBody(strings.NewReader("signup from jane@example.com")))
+		assert.Equal(t, "signup from [REDACTED]", received)
+		assert.Equal(t, int64(2), client.Redaction().Stats().Requests)
+	})
+
+	t.Run("should only redact requests to the configured hosts", func(t *testing.T) {
+		client := httpx.NewClientWithConfig(httpx.WithClientRedaction(httpx.RedactionConfig{
+			Rules: []httpx.RedactionRule{httpx.RedactEmails()},
+			Hosts: []string{"localhost"},
+		}))
+		body := map[string]string{"email": "jane@example.com"}
+
+		post(t, client, server.URL, httpx.WithJSONBody(body))
+		assert.JSONEq(t, `{"email": "jane@example.com"}`, received)
+
+		post(t, client, strings.Replace(server.URL, "127.0.0.1", "localhost", 1), httpx.WithJSONBody(body))
+		assert.JSONEq(t, `{"email": "[REDACTED]"}`, received)
+	})
+
+	t.Run("should leave bodies without PII untouched", func(t *testing.T) {
+		client := httpx.NewClientWithConfig(httpx.WithClientRedaction(httpx.RedactionConfig{
+			Rules: []httpx.RedactionRule{httpx.RedactEmails()},
+		}))
+
+		post(t, client, server.URL,
+			httpx.WithHeader("Content-Type", "application/json"),
+			httpx.WithBody(strings.NewReader(`{ "b": 1.50, "a": "<tag>" }`)))
+
+		assert.Equal(t, `{ "b": 1.50, "a": "<tag>" }`, received)
+		assert.Zero(t, client.Redaction().Stats().Requests)
+	})
+
+	t.Run("should reject invalid rules", func(t *testing.T) {
+		_, err := httpx.NewRedactionMiddleware(httpx.RedactionConfig{Rules: []httpx.RedactionRule{{Path: "user.email"}}})
+		assert.ErrorContains(t, err, "invalid JSONPath")
+
+		_, err = httpx.NewRedactionMiddleware(httpx.RedactionConfig{Rules: []httpx.RedactionRule{{Mask: "***"}}})
+		assert.Error(t, err)
+
+		client := httpx.NewClientWithConfig(httpx.WithClientRedaction(httpx.RedactionConfig{
+			Rules: []httpx.RedactionRule{{Path: "$.items["}},
+		}))
+		_, err = client.Execute(*httpx.NewRequest(http.MethodPost, httpx.WithBaseURL(server.URL), httpx.WithJSONBody(map[string]string{})), nil)
+		assert.ErrorContains(t, err, "invalid redaction rules")
+	})
+}
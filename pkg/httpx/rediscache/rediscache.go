@@ -0,0 +1,215 @@
+package rediscache
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bdpiprava/easy-http/pkg/httpx"
+)
+
+// Defaults of Config
+const (
+	defaultPrefix  = "httpx:cache:"
+	defaultTimeout = 100 * time.Millisecond
+	scanCount      = 500
+)
+
+// Client is the subset of Redis commands the cache is stored with, so any Redis library can back it
+// An adapter of github.com/redis/go-redis/v9 takes a few lines:
+//
+//	type goRedis struct{ *redis.Client }
+//
+//	func (c goRedis) Get(ctx context.Context, key string) ([]byte, error) {
+//		value, err := c.Client.Get(ctx, key).Bytes()
+//		if errors.Is(err, redis.Nil) {
+//			return nil, nil
+//		}
+//		return value, err
+//	}
+//
+//	func (c goRedis) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+//		return c.Client.Set(ctx, key, value, ttl).Err()
+//	}
+//
+//	func (c goRedis) Del(ctx context.Context, keys ...string) error {
+//		return c.Client.Del(ctx, keys...).Err()
+//	}
+//
+//	func (c goRedis) Scan(ctx context.Context, cursor uint64, match string, count int64) ([]string, uint64, error) {
+//		return c.Client.Scan(ctx, cursor, match, count).Result()
+//	}
+type Client interface {
+	Get(ctx context.Context, key string) ([]byte, error) // Returns nil without error when the key does not exist
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	Del(ctx context.Context, keys ...string) error
+	Scan(ctx context.Context, cursor uint64, match string, count int64) (keys []string, next uint64, err error)
+}
+
+// Config configures a Cache
+type Config struct {
+	Client        Client                     // Redis client (required)
+	Prefix        string                     // Prefix of the Redis keys, so services sharing a Redis do not share entries (default: httpx:cache:)
+	Timeout       time.Duration              // Timeout of every Redis command (default: 100ms)
+	MaxEntryBytes int64                      // Maximum size of an entry, see CachedResponse.Size; larger responses are not stored (0 = unlimited)
+	OnError       func(op string, err error) // Optional callback of failed commands, which are otherwise treated as misses
+}
+
+// Cache is an httpx.CacheBackend storing responses in Redis, so the instances of a service share them
+// Entries expire in Redis once the response can no longer be served, even stale. Failing commands are treated as misses, so the
+// client keeps working against the origin when Redis is unavailable.
+//
+// Stats counts the lookups and stores of this instance; Size and Bytes are not reported since entries are shared
+// and expire on the Redis side.
+type Cache struct {
+	config Config
+
+	mu    sync.Mutex
+	stats httpx.CacheStats
+}
+
+// New creates a new Redis cache backend
+func New(config Config) *Cache {
+	if config.Prefix == "" {
+		config.Prefix = defaultPrefix
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = defaultTimeout
+	}
+	return &Cache{config: config}
+}
+
+// Get retrieves a cached response
+func (c *Cache) Get(key string) (*httpx.CachedResponse, bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.config.Timeout)
+	defer cancel()
+
+	data, err := c.config.Client.Get(ctx, c.config.Prefix+key)
+	var response httpx.CachedResponse
+	if err == nil && data != nil {
+		err = json.Unmarshal(data, &response)
+	}
+	if err != nil {
+		c.reportError("get", err)
+	}
+	// Redis expires entries with a granularity of a millisecond, they may outlive their retention by as much
+	if err != nil || data == nil || time.Now().After(response.RetainUntil()) {
+		c.count(func(stats *httpx.CacheStats) { stats.Misses++ })
+		return nil, false
+	}
+	c.count(func(stats *httpx.CacheStats) { stats.Hits++ })
+	return &response, true
+}
+
+// Set stores a response in cache until it expires
+func (c *Cache) Set(key string, response *httpx.CachedResponse) error {
+	if size := response.Size(); c.config.MaxEntryBytes > 0 && size > c.config.MaxEntryBytes {
+		c.count(func(stats *httpx.CacheStats) { stats.Rejected++ })
+		// The new response supersedes any stored one, which must not be served instead
+		_ = c.Delete(key)
+		return fmt.Errorf("cache entry of %d bytes exceeds the limit of %d bytes: %w", size, c.config.MaxEntryBytes, httpx.ErrTooLarge)
+	}
+	ttl := time.Until(response.RetainUntil())
+	if ttl <= 0 {
+		return c.Delete(key)
+	}
+	data, err := json.Marshal(response)
+	if err != nil {
+		return fmt.Errorf("failed to marshal cached response: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), c.config.Timeout)
+	defer cancel()
+	if err := c.config.Client.Set(ctx, c.config.Prefix+key, data, ttl); err != nil {
+		c.reportError("set", err)
+		return fmt.Errorf("failed to store cached response: %w", err)
+	}
+	return nil
+}
+
+// Delete removes a cache entry
+func (c *Cache) Delete(key string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), c.config.Timeout)
+	defer cancel()
+	if err := c.config.Client.Del(ctx, c.config.Prefix+key); err != nil {
+		c.reportError("del", err)
+		return fmt.Errorf("failed to delete cached response: %w", err)
+	}
+	return nil
+}
+
+// Clear removes all cache entries under the prefix, scanning the keyspace in batches
+// Each batch is given the command timeout, so clearing a large cache is not cut short.
+func (c *Cache) Clear() error {
+	var cursor uint64
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), c.config.Timeout)
+		keys, next, err := c.config.Client.Scan(ctx, cursor, c.config.Prefix+"*", scanCount)
+		if err == nil && len(keys) > 0 {
+			err = c.config.Client.Del(ctx, keys...)
+		}
+		cancel()
+		if err != nil {
+			c.reportError("clear", err)
+			return fmt.Errorf("failed to clear cache: %w", err)
+		}
+		if next == 0 {
+			return nil
+		}
+		cursor = next
+	}
+}
+
+// DeleteMatching removes the entries under the prefix whose keys match, returning how many were removed
+// The keyspace is scanned as by Clear.
+func (c *Cache) DeleteMatching(match func(key string) bool) (int, error) {
+	deleted := 0
+	var cursor uint64
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), c.config.Timeout)
+		keys, next, err := c.config.Client.Scan(ctx, cursor, c.config.Prefix+"*", scanCount)
+		var matched []string
+		for _, key := range keys {
+			if match(strings.TrimPrefix(key, c.config.Prefix)) {
+				matched = append(matched, key)
+			}
+		}
+		if err == nil && len(matched) > 0 {
+			err = c.config.Client.Del(ctx, matched...)
+		}
+		cancel()
+		if err != nil {
+			c.reportError("delete_matching", err)
+			return deleted, fmt.Errorf("failed to delete cached responses: %w", err)
+		}
+		deleted += len(matched)
+		if next == 0 {
+			return deleted, nil
+		}
+		cursor = next
+	}
+}
+
+// Stats returns the cache statistics of this instance
+func (c *Cache) Stats() httpx.CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.stats
+}
+
+// count updates the statistics under the lock
+func (c *Cache) count(update func(*httpx.CacheStats)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	update(&c.stats)
+}
+
+// reportError passes a failed command to the OnError callback
+func (c *Cache) reportError(op string, err error) {
+	if c.config.OnError != nil {
+		c.config.OnError(op, err)
+	}
+}
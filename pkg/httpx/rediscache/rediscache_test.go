@@ -0,0 +1,202 @@
+package rediscache_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bdpiprava/easy-http/pkg/httpx"
+	"github.com/bdpiprava/easy-http/pkg/httpx/rediscache"
+)
+
+// fakeRedis is an in-memory Client with key expiry
+type fakeRedis struct {
+	mu      sync.Mutex
+	values  map[string][]byte
+	expires map[string]time.Time
+	ttls    map[string]time.Duration
+	err     error
+}
+
+func newFakeRedis() *fakeRedis {
+	return &fakeRedis{values: map[string][]byte{}, expires: map[string]time.Time{}, ttls: map[string]time.Duration{}}
+}
+
+func (r *fakeRedis) Get(_ context.Context, key string) ([]byte, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		return nil, r.err
+	}
+	if time.Now().After(r.expires[key]) {
+		delete(r.values, key)
+	}
+	return r.values[key], nil
+}
+
+func (r *fakeRedis) Set(_ context.Context, key string, value []byte, ttl time.Duration) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		return r.err
+	}
+	r.values[key], r.expires[key], r.ttls[key] = value, time.Now().Add(ttl), ttl
+	return nil
+}
+
+func (r *fakeRedis) Del(_ context.Context, keys ...string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, key := range keys {
+		delete(r.values, key)
+	}
+	return r.err
+}
+
+func (r *fakeRedis) Scan(_ context.Context, _ uint64, match string, _ int64) ([]string, uint64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var keys []string
+	for key := range r.values {
+		// The cache only scans "<prefix>*" patterns, whose star matches slashes in Redis unlike path.Match
+		if strings.HasPrefix(key, strings.TrimSuffix(match, "*")) {
+			keys = append(keys, key)
+		}
+	}
+	return keys, 0, r.err
+}
+
+func TestCache(t *testing.T) {
+	response := func(ttl time.Duration) *httpx.CachedResponse {
+		return &httpx.CachedResponse{
+			StatusCode: http.StatusOK,
+			Headers:    http.Header{"Content-Type": {"application/json"}},
+			Body:       []byte(`{"id":1}`),
+			CachedAt:   time.Now(),
+			ExpiresAt:  time.Now().Add(ttl),
+			ETag:       `"v1"`,
+		}
+	}
+
+	t.Run("stores responses with their freshness as TTL", func(t *testing.T) {
+		redis := newFakeRedis()
+		cache := rediscache.New(rediscache.Config{Client: redis, Prefix: "svc:"})
+
+		require.NoError(t, cache.Set("GET /users/1", response(time.Minute)))
+		cached, ok := cache.Get("GET /users/1")
+		_, missing := cache.Get("GET /users/2")
+
+		require.True(t, ok)
+		assert.False(t, missing)
+		assert.Equal(t, http.StatusOK, cached.StatusCode)
+		assert.JSONEq(t, `{"id":1}`, string(cached.Body))
+		assert.Equal(t, "application/json", cached.Headers.Get("Content-Type"))
+		assert.Equal(t, `"v1"`, cached.ETag)
+		assert.InDelta(t, time.Minute, redis.ttls["svc:GET /users/1"], float64(time.Second))
+		stats := cache.Stats()
+		assert.Equal(t, int64(1), stats.Hits)
+		assert.Equal(t, int64(1), stats.Misses)
+	})
+
+	t.Run("does not store expired or oversized responses", func(t *testing.T) {
+		redis := newFakeRedis()
+		cache := rediscache.New(rediscache.Config{Client: redis, MaxEntryBytes: 512})
+
+		require.NoError(t, cache.Set("stale", response(-time.Second)))
+		large := response(time.Minute)
+		large.Body = make([]byte, 1024)
+		err := cache.Set("large", large)
+
+		require.ErrorIs(t, err, httpx.ErrTooLarge)
+		assert.Empty(t, redis.values)
+		assert.Equal(t, int64(1), cache.Stats().Rejected)
+	})
+
+	t.Run("clears the keys under its prefix only", func(t *testing.T) {
+		redis := newFakeRedis()
+		require.NoError(t, redis.Set(context.Background(), "other:key", []byte("x"), time.Minute))
+		cache := rediscache.New(rediscache.Config{Client: redis})
+		require.NoError(t, cache.Set("a", response(time.Minute)))
+		require.NoError(t, cache.Set("b", response(time.Minute)))
+
+		require.NoError(t, cache.Clear())
+
+		assert.Equal(t, []string{"other:key"}, keysOf(redis))
+	})
+
+	t.Run("deletes matching keys under its prefix", func(t *testing.T) {
+		redis := newFakeRedis()
+		cache := rediscache.New(rediscache.Config{Client: redis, Prefix: "svc:"})
+		for _, key := range []string{"GET:https://api/users", "GET:https://api/users?page=2", "GET:https://api/orders"} {
+			require.NoError(t, cache.Set(key, response(time.Minute)))
+		}
+
+		deleted, err := cache.DeleteMatching(func(key string) bool { return strings.HasPrefix(key, "GET:https://api/users") })
+
+		require.NoError(t, err)
+		assert.Equal(t, 2, deleted)
+		assert.Equal(t, []string{"svc:GET:https://api/orders"}, keysOf(redis))
+	})
+
+	t.Run("treats Redis failures as misses", func(t *testing.T) {
+		redis := newFakeRedis()
+		var failures []string
+		cache := rediscache.New(rediscache.Config{Client: redis, OnError: func(op string, _ error) { failures = append(failures, op) }})
+		require.NoError(t, cache.Set("a", response(time.Minute)))
+		redis.err = errors.New("connection refused")
+
+		_, ok := cache.Get("a")
+
+		assert.False(t, ok)
+		assert.Equal(t, []string{"get"}, failures)
+		assert.Error(t, cache.Set("a", response(time.Minute)))
+	})
+
+	t.Run("shares responses between clients", func(t *testing.T) {
+		var requests atomic.Int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			requests.Add(1)
+			w.Header().Set("Cache-Control", "max-age=60")
+			_, _ = w.Write([]byte(`{"id":1}`))
+		}))
+		defer server.Close()
+		redis := newFakeRedis()
+		newClient := func() *httpx.Client {
+			return httpx.NewClientWithConfig(
+				httpx.WithClientDefaultBaseURL(server.URL),
+				httpx.WithClientCache(httpx.CacheConfig{
+					Backend:      rediscache.New(rediscache.Config{Client: redis}),
+					RefreshAhead: 0.8, // Serve fresh entries without revalidation
+				}),
+			)
+		}
+
+		for _, client := range []*httpx.Client{newClient(), newClient()} {
+			resp, err := client.Execute(*httpx.NewRequest(http.MethodGet, httpx.WithPath("/users/1")), map[string]any{})
+			require.NoError(t, err)
+			assert.Equal(t, map[string]any{"id": float64(1)}, resp.Body)
+		}
+
+		assert.Equal(t, int32(1), requests.Load())
+	})
+}
+
+// keysOf returns the keys stored in redis
+func keysOf(redis *fakeRedis) []string {
+	redis.mu.Lock()
+	defer redis.mu.Unlock()
+	keys := make([]string, 0, len(redis.values))
+	for key := range redis.values {
+		keys = append(keys, key)
+	}
+	return keys
+}
@@ -1,15 +1,16 @@
 package httpx
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
 	"io"
+	"log/slog"
 	"maps"
 	"net/http"
 	"net/url"
 	"path"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/pkg/errors"
 )
@@ -29,13 +30,19 @@ var supportedMethods = map[string]bool{
 var defaultClient = &Client{client: http.DefaultClient}
 
 // Request is a request struct
+//
+// A Request is immutable once created: executing it builds a fresh *http.Request each time and never changes
+// the Request or the headers, query parameters and cookies given to its options, so one Request can be executed
+// concurrently and retried safely. Derive variants with Clone. Readers passed to WithBody are the exception,
+// as they are consumed when the request is sent; use WithJSONBody or WithFormBody for reusable bodies.
 type Request struct {
 	opts []RequestOption
 }
 
 // NewRequest is a function that returns a new request with the given options
 func NewRequest(method string, opts ...RequestOption) *Request {
-	opts = append(opts, func(c *RequestOptions) {
+	// Clip so appending never writes to the caller's backing array
+	opts = append(slices.Clip(opts), func(c *RequestOptions) {
 		if err := validateHTTPMethod(method); err != nil {
 			c.Error = errors.Wrap(err, "invalid HTTP method")
 			return
@@ -45,6 +52,12 @@ func NewRequest(method string, opts ...RequestOption) *Request {
 	return &Request{opts: opts}
 }
 
+// Clone returns a copy of the request with opts applied after the options of r, which is left unchanged
+// Options given to Clone override those of r, e.g. req.Clone(WithContext(ctx), WithHeader("X-Attempt", "2")).
+func (r *Request) Clone(opts ...RequestOption) *Request {
+	return &Request{opts: append(slices.Clone(r.opts), opts...)}
+}
+
 // WithBaseURL is a function that sets the base URL for the request
 func WithBaseURL(baseURL string) RequestOption {
 	return func(c *RequestOptions) {
@@ -63,6 +76,28 @@ func WithPath(paths ...string) RequestOption {
 	}
 }
 
+// WithPathParam sets the value of the {name} placeholder of the path, e.g. WithPath("/users/{id}") with
+// WithPathParam("id", "123"). Values are escaped as a single path segment, and the path template, not the
+// expanded path, names the request in metrics, spans and HTTPError.Op so they stay low-cardinality.
+// A placeholder without a value, or a value without a placeholder, fails the request.
+func WithPathParam(name, value string) RequestOption {
+	return func(c *RequestOptions) {
+		if c.PathParams == nil {
+			c.PathParams = make(map[string]string)
+		}
+		c.PathParams[name] = value
+	}
+}
+
+// WithPathParams sets the values of several placeholders of the path, see WithPathParam
+func WithPathParams(params map[string]string) RequestOption {
+	return func(c *RequestOptions) {
+		for name, value := range params {
+			WithPathParam(name, value)(c)
+		}
+	}
+}
+
 // WithHeaders is a function that sets the headers for the request
 func WithHeaders(headers http.Header) RequestOption {
 	return func(c *RequestOptions) {
@@ -85,7 +120,9 @@ func WithHeaders(headers http.Header) RequestOption {
 			}
 		}
 
-		maps.Copy(c.Headers, headers)
+		for key, values := range headers {
+			c.Headers[key] = slices.Clone(values)
+		}
 	}
 }
 
@@ -104,11 +141,22 @@ func WithHeader(key string, values ...string) RequestOption {
 			}
 		}
 
-		if cur, ok := c.Headers[key]; ok {
-			c.Headers[key] = append(cur, values...)
+		// Never append to a slice shared with the caller or another request
+		c.Headers[key] = append(slices.Clip(c.Headers[key]), values...)
+	}
+}
+
+// WithoutHeader removes a header from the request, including a client default header or one added by a middleware
+// such as tracing. Header names are case-insensitive and removal wins over WithHeader regardless of option order.
+// Removing User-Agent also suppresses the Go default one.
+func WithoutHeader(key string) RequestOption {
+	return func(c *RequestOptions) {
+		if err := validateHeaderName(key); err != nil {
+			c.Error = errors.Wrap(err, "invalid header name")
 			return
 		}
-		c.Headers[key] = values
+
+		c.RemovedHeaders = append(c.RemovedHeaders, key)
 	}
 }
 
@@ -119,18 +167,16 @@ func WithQueryParams(params url.Values) RequestOption {
 			return
 		}
 
-		maps.Copy(c.QueryParams, params)
+		for key, values := range params {
+			c.QueryParams[key] = slices.Clone(values)
+		}
 	}
 }
 
 // WithQueryParam is a function that sets the query parameters for the request
 func WithQueryParam(key string, values ...string) RequestOption {
 	return func(c *RequestOptions) {
-		if cur, ok := c.QueryParams[key]; ok {
-			c.QueryParams[key] = append(cur, values...)
-			return
-		}
-		c.QueryParams[key] = values
+		c.QueryParams[key] = append(slices.Clip(c.QueryParams[key]), values...)
 	}
 }
 
@@ -149,48 +195,72 @@ func WithBody(body io.Reader) RequestOption {
 }
 
 // WithJSONBody is a function that sets the JSON body for the request
+// The body is marshaled with the client's JSONCodec, see WithClientJSONCodec, when the request is sent.
 func WithJSONBody(body any) RequestOption {
 	return func(c *RequestOptions) {
-		content, err := json.Marshal(body)
-		if err != nil {
-			c.Error = errors.Wrap(err, "failed to marshal JSON body")
-			return
-		}
-
 		c.Headers.Set("Content-Type", "application/json")
-		c.Body = bytes.NewReader(content)
+		c.Body = &jsonBody{value: body}
 	}
 }
 
-// WithFormData is a function that sets form-encoded data as the request body
-// Automatically sets Content-Type to application/x-www-form-urlencoded
-func WithFormData(data url.Values) RequestOption {
+// WithEncodedBody sets v as the body of the request, with the Content-Type mediaType, marshaled by the codec
+// registered for mediaType, see RegisterCodec, when the request is sent
+func WithEncodedBody(mediaType string, v any) RequestOption {
 	return func(c *RequestOptions) {
-		if data == nil {
-			return
-		}
+		c.Headers.Set("Content-Type", mediaType)
+		c.Body = &encodedBody{mediaType: mediaType, value: v}
+	}
+}
 
-		encoded := data.Encode()
-		c.Headers.Set("Content-Type", "application/x-www-form-urlencoded")
-		c.Body = strings.NewReader(encoded)
+// WithXMLBody sets v as the XML body of the request, see WithEncodedBody
+func WithXMLBody(v any) RequestOption {
+	return WithEncodedBody("application/xml", v)
+}
+
+// WithSerializationFallback resends the payload of the request in mediaTypes, in order, when the server rejects
+// its serialization with 415 Unsupported Media Type or 406 Not Acceptable, overriding the fallbacks of the
+// client; calling it without media types disables them, see WithClientSerializationFallback
+func WithSerializationFallback(mediaTypes ...string) RequestOption {
+	return func(c *RequestOptions) {
+		c.FallbackMediaTypes = append([]string{}, mediaTypes...)
 	}
 }
 
-// WithFormFields is a convenience function for WithFormData that accepts a map
-func WithFormFields(fields map[string]string) RequestOption {
+// WithResponseDecoder decodes the response body with codec whatever its Content-Type, e.g. for servers
+// labelling XML as text/plain
+func WithResponseDecoder(codec Codec) RequestOption {
 	return func(c *RequestOptions) {
-		if fields == nil {
+		c.ResponseDecoder = codec
+	}
+}
+
+// WithFormBody sets values as an application/x-www-form-urlencoded body for the request, the form counterpart
+// of WithJSONBody, setting the Content-Type. Nil values leave the body unset.
+func WithFormBody(values url.Values) RequestOption {
+	return func(c *RequestOptions) {
+		if values == nil {
 			return
 		}
+		c.Headers.Set("Content-Type", "application/x-www-form-urlencoded")
+		c.Body = strings.NewReader(values.Encode())
+	}
+}
 
-		data := url.Values{}
-		for key, value := range fields {
-			data.Set(key, value)
-		}
+// WithFormData is a function that sets form-encoded data as the request body, see WithFormBody
+func WithFormData(data url.Values) RequestOption {
+	return WithFormBody(data)
+}
 
-		// Delegate to WithFormData
-		WithFormData(data)(c)
+// WithFormFields is a convenience function for WithFormBody that accepts a map
+func WithFormFields(fields map[string]string) RequestOption {
+	if fields == nil {
+		return WithFormBody(nil)
+	}
+	data := url.Values{}
+	for key, value := range fields {
+		data.Set(key, value)
 	}
+	return WithFormBody(data)
 }
 
 // WithMultipartForm is a function that sets a multipart/form-data body for the request
@@ -224,6 +294,25 @@ func WithFile(fieldName, filePath string) RequestOption {
 	}
 }
 
+// WithMultipartBody sends parts as a multipart/form-data body, in order, setting the Content-Type and its boundary
+// File contents are streamed from their readers while the request is sent rather than buffered, so the body
+// can be read only once: readers are consumed like those of WithBody.
+func WithMultipartBody(parts ...MultipartPart) RequestOption {
+	return func(c *RequestOptions) {
+		c.MultipartParts = append(c.MultipartParts, parts...)
+	}
+}
+
+// WithFormField adds a value field to the multipart/form-data body of the request, see WithMultipartBody
+func WithFormField(name, value string) RequestOption {
+	return WithMultipartBody(MultipartPart{FieldName: name, Value: value})
+}
+
+// WithFormFile adds a file streamed from reader to the multipart/form-data body of the request, see WithMultipartBody
+func WithFormFile(name, fileName string, reader io.Reader) RequestOption {
+	return WithMultipartBody(MultipartPart{FieldName: name, FileName: fileName, Reader: reader})
+}
+
 // WithBasicAuth is a function that sets basic authentication for the request
 func WithBasicAuth(username, password string) RequestOption {
 	return func(c *RequestOptions) {
@@ -243,6 +332,80 @@ func WithStreaming() RequestOption {
 	}
 }
 
+// WithStreamTee copies the response body to w as it is read, so a response can be processed and
+// persisted (e.g. to a file or cache) from a single download
+// Combine with WithStreaming to avoid buffering large downloads in memory; in streaming mode w only
+// receives the bytes the caller reads from Response.StreamBody, so read it to EOF for a complete copy
+func WithStreamTee(w io.Writer) RequestOption {
+	return func(c *RequestOptions) {
+		if w == nil {
+			c.Error = errors.New("stream tee writer cannot be nil")
+			return
+		}
+		c.StreamTee = w
+	}
+}
+
+// WithStreamIdleTimeout aborts the response body when no data arrives for timeout, so a silently dead
+// stream (e.g. SSE behind a proxy that dropped the connection) fails with *StreamStalledError instead of
+// hanging the consumer. The timer starts once response headers are received and restarts on every read.
+func WithStreamIdleTimeout(timeout time.Duration) RequestOption {
+	return func(c *RequestOptions) {
+		c.StreamIdleTimeout = timeout
+	}
+}
+
+// WithStreamIdleReconnect re-issues the request up to maxReconnects times when the stream stalls,
+// continuing to read from the new response; it requires WithStreamIdleTimeout
+// Streams are resumed as described by StreamReconnectPolicy and otherwise restarted from the beginning,
+// so use it for streams whose consumers tolerate a restart, such as event feeds.
+// It is ignored when WithStreamReconnect is set.
+func WithStreamIdleReconnect(maxReconnects int) RequestOption {
+	return func(c *RequestOptions) {
+		c.StreamIdleReconnects = maxReconnects
+	}
+}
+
+// WithStreamReconnect re-issues a streaming request after transient drops, and after stalls detected by
+// WithStreamIdleTimeout, resuming with Last-Event-ID or Range where the response allows it
+// Zero MaxReconnects, BaseDelay and MaxDelay take the values of DefaultStreamReconnectPolicy.
+// Reconnects are reported to policy.OnReconnect.
+func WithStreamReconnect(policy StreamReconnectPolicy) RequestOption {
+	return func(c *RequestOptions) {
+		defaults := DefaultStreamReconnectPolicy()
+		if policy.MaxReconnects <= 0 {
+			policy.MaxReconnects = defaults.MaxReconnects
+		}
+		if policy.BaseDelay <= 0 {
+			policy.BaseDelay = defaults.BaseDelay
+		}
+		if policy.MaxDelay <= 0 {
+			policy.MaxDelay = defaults.MaxDelay
+		}
+		c.StreamReconnect = &policy
+	}
+}
+
+// WithAnnotation attaches business context (e.g. order_id) to the request
+// Annotations are recorded on any resulting HTTPError, in client logs and as span attributes,
+// so failures deep inside retries can be traced back to what the call was for
+func WithAnnotation(key, value string) RequestOption {
+	return func(c *RequestOptions) {
+		if c.Annotations == nil {
+			c.Annotations = make(map[string]string)
+		}
+		c.Annotations[key] = value
+	}
+}
+
+// WithOperation names the operation reported in HTTPError.Op, e.g. "GET /users/{id}"
+// Without it the operation is the method and URL path, which varies with path parameters
+func WithOperation(op string) RequestOption {
+	return func(c *RequestOptions) {
+		c.Operation = op
+	}
+}
+
 // WithCookie adds a single cookie to the request
 func WithCookie(name, value string) RequestOption {
 	return func(c *RequestOptions) {
@@ -275,6 +438,83 @@ func WithoutCookies() RequestOption {
 	}
 }
 
+// WithRequestLogger logs this request with logger instead of the client logger, so request-scoped fields flow
+// into the client logs. It takes precedence over WithClientContextLogger and has no effect on clients without logging.
+func WithRequestLogger(logger *slog.Logger) RequestOption {
+	return func(c *RequestOptions) {
+		c.Logger = logger
+	}
+}
+
+// WithAffinityKey sends requests with the same key, e.g. a user ID, to the same endpoint of a discovered service
+// balanced by NewConsistentHashBalancer, for cache locality on sharded backends
+func WithAffinityKey(key string) RequestOption {
+	return func(c *RequestOptions) {
+		c.AffinityKey = key
+	}
+}
+
+// WithAPIVersion sets the API version of the request, sent as described by the client APIVersioning
+func WithAPIVersion(version string) RequestOption {
+	return func(c *RequestOptions) {
+		c.APIVersion = version
+	}
+}
+
+// WithNoCache sends the request to the origin without looking it up in the client cache or storing its response
+func WithNoCache() RequestOption {
+	return func(c *RequestOptions) {
+		c.Cache.NoCache = true
+	}
+}
+
+// WithForceRevalidate revalidates the cached response with the origin even when it is fresh, e.g. right after
+// modifying the resource, rather than serving it from the cache
+func WithForceRevalidate() RequestOption {
+	return func(c *RequestOptions) {
+		c.Cache.ForceRevalidate = true
+	}
+}
+
+// WithCacheTTL caches the response for ttl, overriding its caching headers and the TTL rules of the client cache
+// Responses the origin forbids to store, e.g. with no-store, are still not cached.
+func WithCacheTTL(ttl time.Duration) RequestOption {
+	return func(c *RequestOptions) {
+		c.Cache.TTL = ttl
+	}
+}
+
+// WithCacheKey identifies the response in the client cache by key rather than by the method and URL, e.g. to
+// share a response between URLs differing by a tracking parameter; the key is still scoped to the cache partition
+func WithCacheKey(key string) RequestOption {
+	return func(c *RequestOptions) {
+		c.Cache.Key = key
+	}
+}
+
+// WithInformationalHandler calls handler with each interim 1xx response of the request, e.g. to start preloading
+// the resources announced by 103 Early Hints while the server prepares the final response
+// Interim responses are otherwise skipped: the final response is decoded as usual.
+func WithInformationalHandler(handler InformationalHandler) RequestOption {
+	return func(c *RequestOptions) {
+		c.InformationalHandler = handler
+	}
+}
+
+// WithMiddleware runs middleware for this request only, e.g. to override authentication or intercept a dry run
+// Request middlewares run after the client middlewares, closest to the transport, in the order they were added,
+// so they see the request as the client middlewares left it and every retry attempt goes through them.
+func WithMiddleware(middleware Middleware) RequestOption {
+	return WithMiddlewares(middleware)
+}
+
+// WithMiddlewares runs middlewares for this request only, in order, see WithMiddleware
+func WithMiddlewares(middlewares ...Middleware) RequestOption {
+	return func(c *RequestOptions) {
+		c.Middlewares = append(c.Middlewares, middlewares...)
+	}
+}
+
 // WithProxy sets the proxy URL for this specific request (supports HTTP/HTTPS/SOCKS4/SOCKS5)
 // Overrides the client's proxy configuration for this request only
 func WithProxy(proxyURL string) RequestOption {
@@ -349,6 +589,13 @@ func buildRequest(opts RequestOptions) (*http.Request, error) {
 	if opts.Error != nil {
 		return nil, opts.Error
 	}
+	if err := marshalJSONBody(&opts, nil); err != nil {
+		return nil, err
+	}
+	if err := marshalEncodedBody(&opts, nil); err != nil {
+		return nil, err
+	}
+	encodeMultipartBody(&opts)
 
 	if _, ok := supportedMethods[strings.ToUpper(opts.Method)]; !ok {
 		return nil, errors.Errorf("unsupported method: %s", opts.Method)
@@ -358,13 +605,26 @@ func buildRequest(opts RequestOptions) (*http.Request, error) {
 	if ctx == nil {
 		ctx = context.Background()
 	}
+	ctx = ContextWithAnnotations(ctx, opts.Annotations)
+	ctx = ContextWithOperation(ctx, opts.Operation)
+	ctx = contextWithRemovedHeaders(ctx, opts.RemovedHeaders)
+	ctx = ContextWithAffinityKey(ctx, opts.AffinityKey)
+	ctx = contextWithRequestCacheOptions(ctx, opts.Cache)
+	ctx = contextWithInformationalHandler(ctx, opts.InformationalHandler)
 	req, err := http.NewRequestWithContext(ctx, opts.Method, opts.BaseURL, opts.Body)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to create request")
 	}
 
-	req.URL.Path = path.Join(req.URL.Path, opts.Path)
+	template, err := applyPath(req.URL, opts.Path, opts.PathParams)
+	if err != nil {
+		return nil, err
+	}
+	if template != "" {
+		req = req.WithContext(ContextWithPathTemplate(req.Context(), template))
+	}
 	req.Header = opts.Headers
+	removeHeaders(req.Header, opts.RemovedHeaders)
 	req.URL.RawQuery = opts.QueryParams.Encode()
 
 	// Apply basic auth if specified
@@ -386,7 +646,7 @@ func buildOpts(clientOpts ClientOptions, request *Request) RequestOptions {
 	}
 
 	if clientOpts.Headers != nil {
-		maps.Copy(opts.Headers, clientOpts.Headers)
+		opts.Headers = clientOpts.Headers.Clone()
 	}
 
 	for _, opt := range request.opts {
@@ -424,6 +684,12 @@ func buildOptsFromConfig(clientConfig ClientConfig, request *Request) RequestOpt
 		if tempOpts.Path != "" {
 			requestConfig.Path = tempOpts.Path
 		}
+		if len(tempOpts.PathParams) > 0 {
+			if requestConfig.PathParams == nil {
+				requestConfig.PathParams = make(map[string]string)
+			}
+			maps.Copy(requestConfig.PathParams, tempOpts.PathParams)
+		}
 		if len(tempOpts.Headers) > 0 {
 			for key, values := range tempOpts.Headers {
 				requestConfig.Headers[key] = values
@@ -449,7 +715,30 @@ func buildOptsFromConfig(clientConfig ClientConfig, request *Request) RequestOpt
 		if tempOpts.Error != nil {
 			requestConfig.Error = tempOpts.Error
 		}
-		requestConfig.Streaming = tempOpts.Streaming
+		if tempOpts.Streaming {
+			requestConfig.Streaming = true
+		}
+		if tempOpts.StreamTee != nil {
+			requestConfig.StreamTee = tempOpts.StreamTee
+		}
+		if tempOpts.Operation != "" {
+			requestConfig.Operation = tempOpts.Operation
+		}
+		if tempOpts.StreamIdleTimeout != 0 {
+			requestConfig.StreamIdleTimeout = tempOpts.StreamIdleTimeout
+		}
+		if tempOpts.StreamIdleReconnects != 0 {
+			requestConfig.StreamIdleReconnects = tempOpts.StreamIdleReconnects
+		}
+		if tempOpts.StreamReconnect != nil {
+			requestConfig.StreamReconnect = tempOpts.StreamReconnect
+		}
+		if len(tempOpts.Annotations) > 0 {
+			if requestConfig.Annotations == nil {
+				requestConfig.Annotations = make(map[string]string)
+			}
+			maps.Copy(requestConfig.Annotations, tempOpts.Annotations)
+		}
 		if len(tempOpts.Cookies) > 0 {
 			if requestConfig.Cookies == nil {
 				requestConfig.Cookies = make([]*http.Cookie, 0)
@@ -459,6 +748,34 @@ func buildOptsFromConfig(clientConfig ClientConfig, request *Request) RequestOpt
 		if tempOpts.DisableCookies {
 			requestConfig.DisableCookies = true
 		}
+		if tempOpts.Logger != nil {
+			requestConfig.Logger = tempOpts.Logger
+		}
+		if tempOpts.AffinityKey != "" {
+			requestConfig.AffinityKey = tempOpts.AffinityKey
+		}
+		if tempOpts.APIVersion != "" {
+			requestConfig.APIVersion = tempOpts.APIVersion
+		}
+		if tempOpts.ResponseDecoder != nil {
+			requestConfig.ResponseDecoder = tempOpts.ResponseDecoder
+		}
+		requestConfig.Cache = requestConfig.Cache.merge(tempOpts.Cache)
+		if tempOpts.InformationalHandler != nil {
+			requestConfig.InformationalHandler = tempOpts.InformationalHandler
+		}
+		if tempOpts.FallbackMediaTypes != nil {
+			requestConfig.FallbackMediaTypes = tempOpts.FallbackMediaTypes
+		}
+		if len(tempOpts.MultipartParts) > 0 {
+			requestConfig.MultipartParts = append(requestConfig.MultipartParts, tempOpts.MultipartParts...)
+		}
+		if len(tempOpts.Middlewares) > 0 {
+			requestConfig.Middlewares = append(requestConfig.Middlewares, tempOpts.Middlewares...)
+		}
+		if len(tempOpts.RemovedHeaders) > 0 {
+			requestConfig.RemovedHeaders = append(requestConfig.RemovedHeaders, tempOpts.RemovedHeaders...)
+		}
 		if tempOpts.ProxyURL != "" {
 			requestConfig.ProxyURL = tempOpts.ProxyURL
 		}
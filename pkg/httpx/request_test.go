@@ -1,14 +1,24 @@
 package httpx_test
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"log/slog"
 	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
 	"strings"
+	"sync"
 	"testing"
 
 	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
 
 	"github.com/bdpiprava/easy-http/pkg/httpx"
@@ -472,6 +482,81 @@ func (s *RequestTestSuite) TestStreamingModeWithHTTPError() {
 	s.JSONEq(testErrorBody, string(content))
 }
 
+func (s *RequestTestSuite) TestWithStreamTee() {
+	mockServer := NewMockServer()
+	defer mockServer.Close()
+
+	mockServer.SetupMock("GET", "/test", 200, testResponseBody)
+
+	s.Run("streaming mode copies bytes as they are read", func() {
+		archive := filepath.Join(s.T().TempDir(), "download.json")
+		file, err := os.Create(archive)
+		s.Require().NoError(err)
+		defer file.Close()
+
+		resp, err := httpx.GET[string](
+			httpx.WithBaseURL(mockServer.GetURL()),
+			httpx.WithPath("/test"),
+			httpx.WithStreaming(),
+			httpx.WithStreamTee(file),
+		)
+
+		s.Require().NoError(err)
+		s.True(resp.IsStreaming)
+
+		content, err := io.ReadAll(resp.StreamBody)
+		s.Require().NoError(err)
+		s.Require().NoError(resp.StreamBody.Close())
+		s.JSONEq(testResponseBody, string(content))
+
+		persisted, err := os.ReadFile(archive)
+		s.Require().NoError(err)
+		s.Equal(content, persisted)
+	})
+
+	s.Run("buffered mode copies the full body", func() {
+		var archive bytes.Buffer
+
+		resp, err := httpx.GET[map[string]any](
+			httpx.WithBaseURL(mockServer.GetURL()),
+			httpx.WithPath("/test"),
+			httpx.WithStreamTee(&archive),
+		)
+
+		s.Require().NoError(err)
+		s.Equal("test response", resp.Body.(map[string]any)["message"])
+		s.JSONEq(testResponseBody, archive.String())
+	})
+
+	s.Run("streaming is kept when followed by other options", func() {
+		var archive bytes.Buffer
+
+		resp, err := httpx.NewClientWithConfig(httpx.WithClientDefaultBaseURL(mockServer.GetURL())).
+			Execute(*httpx.NewRequest(http.MethodGet,
+				httpx.WithStreaming(),
+				httpx.WithStreamTee(&archive),
+				httpx.WithPath("/test"),
+			), "")
+
+		s.Require().NoError(err)
+		s.True(resp.IsStreaming)
+		_, err = io.Copy(io.Discard, resp.StreamBody)
+		s.Require().NoError(err)
+		s.Require().NoError(resp.StreamBody.Close())
+		s.JSONEq(testResponseBody, archive.String())
+	})
+
+	s.Run("nil writer is rejected", func() {
+		_, err := httpx.GET[string](
+			httpx.WithBaseURL(mockServer.GetURL()),
+			httpx.WithStreamTee(nil),
+		)
+
+		s.Require().Error(err)
+		s.Contains(err.Error(), "stream tee writer cannot be nil")
+	})
+}
+
 func (s *RequestTestSuite) TestValidationErrors() {
 	tests := []struct {
 		name    string
@@ -623,7 +708,7 @@ func (s *RequestTestSuite) TestStructuredLogging() {
 	s.Contains(logs, "HTTP request")
 	s.Contains(logs, "HTTP response")
 	s.Contains(logs, "method=GET")
-	s.Contains(logs, "status_code=200")
+	s.Contains(logs, "http.status=200")
 }
 
 func (s *RequestTestSuite) TestLoggingLevels() {
@@ -743,3 +828,156 @@ func (s *RequestTestSuite) TestFormDataWithMultipleValues() {
 	s.Equal(200, resp.StatusCode)
 	s.Equal("xyz789", resp.Body.(map[string]any)["access_token"])
 }
+
+func (s *RequestTestSuite) TestFormBody() {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.Equal("application/x-www-form-urlencoded", r.Header.Get("Content-Type"))
+		s.Require().NoError(r.ParseForm())
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(r.PostForm)
+	}))
+	defer server.Close()
+
+	s.Run("with client Execute", func() {
+		client := httpx.NewClientWithConfig(httpx.WithClientDefaultBaseURL(server.URL))
+		req := httpx.NewRequest(http.MethodPost, httpx.WithFormBody(url.Values{"scope": {"read", "write"}}))
+
+		resp, err := client.Execute(*req, map[string][]string{})
+
+		s.Require().NoError(err)
+		s.Equal(map[string][]string{"scope": {"read", "write"}}, resp.Body)
+	})
+
+	s.Run("with generic helpers", func() {
+		resp, err := httpx.POST[map[string][]string](
+			httpx.WithBaseURL(server.URL),
+			httpx.WithFormFields(map[string]string{"grant_type": "client_credentials"}),
+		)
+
+		s.Require().NoError(err)
+		s.Equal(map[string][]string{"grant_type": {"client_credentials"}}, resp.Body)
+	})
+}
+
+func FuzzRequestURL(f *testing.F) {
+	f.Add("https://api.example.com", "/users/1", "q", "a b")
+	f.Add("https://api.example.com/v1/", "../admin", "", "")
+	f.Add("http://[::1]:8080/base?x=1", "a//b/./c", "k&v", "=;#")
+	f.Add("https://api.example.com", "%2F%00", "\x00", "\xff")
+	f.Add("://missing-scheme", "/path", "q", "v")
+
+	f.Fuzz(func(t *testing.T, baseURL, path, key, value string) {
+		req := httpx.NewRequest(http.MethodGet, httpx.WithPath(path), httpx.WithQueryParam(key, value))
+		httpReq, err := req.ToHTTPReq(httpx.ClientOptions{BaseURL: baseURL})
+		if err != nil {
+			return
+		}
+		if httpReq.URL.RawQuery != "" && httpReq.URL.Query().Get(key) != value {
+			t.Fatalf("query %q=%q not preserved in %q", key, value, httpReq.URL.RawQuery)
+		}
+		_ = httpReq.URL.String()
+	})
+}
+
+func TestRequestImmutability(t *testing.T) {
+	var mu sync.Mutex
+	var received []*http.Request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		received = append(received, r)
+		mu.Unlock()
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+	lastReceived := func() *http.Request {
+		mu.Lock()
+		defer mu.Unlock()
+		return received[len(received)-1]
+	}
+
+	t.Run("Clone should derive a request without changing the original", func(t *testing.T) {
+		client := httpx.NewClientWithConfig(httpx.WithClientDefaultBaseURL(server.URL))
+		original := httpx.NewRequest(http.MethodGet, httpx.WithPath("/items"), httpx.WithHeader("X-Version", "1"))
+
+		clone := original.Clone(httpx.WithHeader("X-Attempt", "2"), httpx.WithQueryParam("page", "2"))
+		_, err := client.Execute(*clone, "")
+		require.NoError(t, err)
+		assert.Equal(t, "1", lastReceived().Header.Get("X-Version"))
+		assert.Equal(t, "2", lastReceived().Header.Get("X-Attempt"))
+		assert.Equal(t, "page=2", lastReceived().URL.RawQuery)
+
+		_, err = client.Execute(*original, "")
+		require.NoError(t, err)
+		assert.Equal(t, "1", lastReceived().Header.Get("X-Version"))
+		assert.Empty(t, lastReceived().Header.Get("X-Attempt"))
+		assert.Empty(t, lastReceived().URL.RawQuery)
+	})
+
+	t.Run("should not write to the slices given to options", func(t *testing.T) {
+		client := httpx.NewClientWithConfig(
+			httpx.WithClientDefaultBaseURL(server.URL),
+			httpx.WithClientMiddleware(&addHeaderMiddleware{key: "X-Tag", value: "b"}),
+		)
+		headerValues := append(make([]string, 0, 4), "a")
+		opts := append(make([]httpx.RequestOption, 0, 4), httpx.WithHeader("X-Tag", headerValues...))
+
+		first := httpx.NewRequest(http.MethodGet, opts...)
+		second := httpx.NewRequest(http.MethodGet, append(opts, httpx.WithQueryParam("page", "2"))...)
+		_, err := client.Execute(*first, "")
+		require.NoError(t, err)
+
+		assert.Equal(t, []string{"a", "b"}, lastReceived().Header.Values("X-Tag"))
+		assert.Equal(t, []string{"a", ""}, headerValues[:2], "header values must not be appended to in place")
+		assert.Empty(t, lastReceived().URL.RawQuery, "requests must not share their options")
+		_, err = client.Execute(*second, "")
+		require.NoError(t, err)
+		assert.Equal(t, "page=2", lastReceived().URL.RawQuery)
+	})
+
+	t.Run("should not share client default headers between requests", func(t *testing.T) {
+		defaults := append(make([]string, 0, 4), "json")
+		client := httpx.NewClientWithConfig(
+			httpx.WithClientDefaultBaseURL(server.URL),
+			httpx.WithClientDefaultHeader("Accept", defaults...),
+			httpx.WithClientMiddleware(&addHeaderMiddleware{key: "Accept", value: "xml"}),
+		)
+
+		for range 2 {
+			_, err := client.Execute(*httpx.NewRequest(http.MethodGet), "")
+			require.NoError(t, err)
+			assert.Equal(t, []string{"json", "xml"}, lastReceived().Header.Values("Accept"))
+		}
+		assert.Equal(t, []string{"json", ""}, defaults[:2])
+	})
+
+	t.Run("should execute one request concurrently", func(t *testing.T) {
+		client := httpx.NewClientWithConfig(httpx.WithClientDefaultBaseURL(server.URL))
+		req := httpx.NewRequest(http.MethodPost, httpx.WithJSONBody(map[string]string{"name": "gear"}), httpx.WithHeader("X-Tag", "a"))
+
+		var wg sync.WaitGroup
+		for range 10 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				resp, err := client.Execute(*req, "")
+				assert.NoError(t, err)
+				assert.Equal(t, http.StatusOK, resp.StatusCode)
+			}()
+		}
+		wg.Wait()
+	})
+}
+
+// addHeaderMiddleware adds a value to a request header
+type addHeaderMiddleware struct {
+	key, value string
+}
+
+func (m *addHeaderMiddleware) Name() string {
+	return "add-header"
+}
+
+func (m *addHeaderMiddleware) Execute(ctx context.Context, req *http.Request, next httpx.MiddlewareFunc) (*http.Response, error) {
+	req.Header.Add(m.key, m.value)
+	return next(ctx, req)
+}
@@ -0,0 +1,138 @@
+package httpx_test
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bdpiprava/easy-http/pkg/httpx"
+)
+
+func TestRequestSigning(t *testing.T) {
+	t.Run("HMAC signer signs method, target, time, body and headers", func(t *testing.T) {
+		secret := []byte("shared-secret")
+		var verified bool
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+			bodyHash := sha256.Sum256(body)
+			stringToSign := strings.Join([]string{
+				r.Method,
+				r.URL.RequestURI(),
+				r.Header.Get("X-Timestamp"),
+				hex.EncodeToString(bodyHash[:]),
+				"x-tenant:acme",
+			}, "\n")
+			mac := hmac.New(sha256.New, secret)
+			mac.Write([]byte(stringToSign))
+			verified = hmac.Equal([]byte(hex.EncodeToString(mac.Sum(nil))), []byte(r.Header.Get("X-Signature")))
+			assert.Equal(t, "key-1", r.Header.Get("X-Key-Id"))
+			_, _ = w.Write([]byte(`{}`))
+		}))
+		defer server.Close()
+
+		client := httpx.NewClientWithConfig(
+			httpx.WithClientDefaultBaseURL(server.URL),
+			httpx.WithClientRequestSigner(httpx.NewHMACSigner(httpx.HMACSignerConfig{
+				KeyID:         "key-1",
+				Secret:        secret,
+				SignedHeaders: []string{"X-Tenant"},
+			})),
+		)
+
+		_, err := client.Execute(*httpx.NewRequest(http.MethodPost,
+			httpx.WithPath("/orders"),
+			httpx.WithQueryParam("dry_run", "true"),
+			httpx.WithHeader("X-Tenant", "acme"),
+			httpx.WithJSONBody(map[string]string{"item": "book"}),
+		), nil)
+
+		require.NoError(t, err)
+		assert.True(t, verified)
+	})
+
+	t.Run("HMAC signer requires the signed headers", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			_, _ = w.Write([]byte(`{}`))
+		}))
+		defer server.Close()
+		client := httpx.NewClientWithConfig(
+			httpx.WithClientDefaultBaseURL(server.URL),
+			httpx.WithClientRequestSigner(httpx.NewHMACSigner(httpx.HMACSignerConfig{Secret: []byte("s"), SignedHeaders: []string{"X-Tenant"}})),
+		)
+
+		_, err := client.Execute(*httpx.NewRequest(http.MethodGet), nil)
+
+		require.Error(t, err)
+		assert.True(t, httpx.IsMiddlewareError(err))
+	})
+
+	t.Run("SigV4 signer matches the AWS reference signature", func(t *testing.T) {
+		// Example of the AWS Signature Version 4 documentation
+		signer := httpx.NewSigV4Signer(httpx.SigV4Config{
+			Credentials: httpx.StaticAWSCredentials{
+				AccessKeyID:     "AKIDEXAMPLE",
+				SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+			},
+			Region:  "us-east-1",
+			Service: "iam",
+		})
+		target, err := url.Parse("https://iam.amazonaws.com/?Action=ListUsers&Version=2010-05-08")
+		require.NoError(t, err)
+
+		headers, err := signer.SignRequest(context.Background(), httpx.SignableRequest{
+			Method:   http.MethodGet,
+			URL:      target,
+			Host:     "iam.amazonaws.com",
+			Header:   http.Header{"Content-Type": {"application/x-www-form-urlencoded; charset=utf-8"}},
+			BodyHash: "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855",
+			Time:     time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC),
+		})
+
+		require.NoError(t, err)
+		assert.Equal(t, "20150830T123600Z", headers.Get("X-Amz-Date"))
+		assert.Equal(t, "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/iam/aws4_request, "+
+			"SignedHeaders=content-type;host;x-amz-date, "+
+			"Signature=5d672d79c15b13162d9279b0855cfba6789a8edb4c82c400e06b5924a6f2b5d7", headers.Get("Authorization"))
+	})
+
+	t.Run("SigV4 signer adds the S3 payload hash and session token", func(t *testing.T) {
+		var received http.Header
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			received = r.Header.Clone()
+			_, _ = w.Write([]byte(`{}`))
+		}))
+		defer server.Close()
+
+		client := httpx.NewClientWithConfig(
+			httpx.WithClientDefaultBaseURL(server.URL),
+			httpx.WithClientRequestSigner(httpx.NewSigV4Signer(httpx.SigV4Config{
+				Credentials: httpx.StaticAWSCredentials{AccessKeyID: "AKID", SecretAccessKey: "secret", SessionToken: "session"},
+				Region:      "eu-west-1",
+				Service:     "s3",
+			})),
+		)
+
+		_, err := client.Execute(*httpx.NewRequest(http.MethodPut,
+			httpx.WithPath("/bucket/key.txt"),
+			httpx.WithBody(strings.NewReader("hello")),
+		), nil)
+
+		require.NoError(t, err)
+		bodyHash := sha256.Sum256([]byte("hello"))
+		assert.Equal(t, hex.EncodeToString(bodyHash[:]), received.Get("X-Amz-Content-Sha256"))
+		assert.Equal(t, "session", received.Get("X-Amz-Security-Token"))
+		assert.Contains(t, received.Get("Authorization"), "/eu-west-1/s3/aws4_request, ")
+		assert.Contains(t, received.Get("Authorization"), "SignedHeaders=host;x-amz-content-sha256;x-amz-date;x-amz-security-token, ")
+	})
+}
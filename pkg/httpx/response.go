@@ -19,18 +19,24 @@ type Response struct {
 	RawBody      []byte
 	StreamBody   io.ReadCloser  // Only set when streaming is enabled
 	IsStreaming  bool           // Indicates if this response is in streaming mode
+	APIVersion   string         // API version reported by the server, see APIVersioning
 	httpResponse *http.Response // Original HTTP response for cookie access
+	decoder      Codec          // Codec forced with WithResponseDecoder
+	cacheStatus  CacheStatus    // How the cache middleware answered the request
 }
 
-// newResponse is a function that creates a new response
-func newResponse(httpResp *http.Response, bType any, streaming bool) (*Response, error) {
+// newResponse is a function that creates a new response, decoding and transforming the body as configured
+func newResponse(httpResp *http.Response, bType any, opts RequestOptions, config *ClientConfig) (*Response, error) {
+	streaming := opts.Streaming
 	response := &Response{
 		header:       httpResp.Header,
 		Status:       httpResp.Status,
 		StatusCode:   httpResp.StatusCode,
 		IsStreaming:  streaming,
 		httpResponse: httpResp,
+		decoder:      opts.ResponseDecoder,
 	}
+	response.APIVersion = reportedAPIVersion(httpResp, config.APIVersioning)
 
 	// In streaming mode, don't read the body into memory
 	if streaming {
@@ -47,6 +53,10 @@ func newResponse(httpResp *http.Response, bType any, streaming bool) (*Response,
 		return nil, errors.Wrap(err, "failed to read response body")
 	}
 
+	bodyBytes, err = transformRaw(config.ResponseTransformers, httpResp, bodyBytes)
+	if err != nil {
+		return nil, err
+	}
 	response.RawBody = bodyBytes
 
 	if httpResp.StatusCode > 299 {
@@ -54,11 +64,24 @@ func newResponse(httpResp *http.Response, bType any, streaming bool) (*Response,
 		return response, nil
 	}
 
+	return response, decodeResponseBody(response, bodyBytes, bType, config)
+}
+
+// decodeResponseBody sets the body of a success response to bodyBytes decoded as bType, then transformed
+// Bodies are decoded with the codec of the response Content-Type, see RegisterCodec, or as JSON.
+func decodeResponseBody(response *Response, bodyBytes []byte, bType any, config *ClientConfig) error {
+	httpResp := response.httpResponse
+	var err error
+	unmarshal := config.unmarshalJSON
+	if codec := responseCodec(response); codec != nil {
+		unmarshal = codec.Unmarshal
+	}
+
 	// Handle empty response bodies (e.g., 204 No Content, HEAD requests)
 	if len(bodyBytes) == 0 {
 		// For empty bodies, bType can be nil (e.g., HEAD[any]) - just set it as-is
 		response.Body = bType
-		return response, nil
+		return nil
 	}
 
 	// Check the reflected type to handle different cases
@@ -68,32 +91,41 @@ func newResponse(httpResp *http.Response, bType any, streaming bool) (*Response,
 	// Auto-detect JSON structure: objects → map[string]any, arrays → []any
 	if bTypeReflected == nil {
 		var target any
-		err = json.Unmarshal(bodyBytes, &target)
+		err = unmarshal(bodyBytes, &target)
 		if err != nil {
-			return response, errors.Wrap(err, "failed to unmarshal response as type map[string]interface {}")
+			return errors.Wrap(tagError(err, ErrDecode), "failed to unmarshal response as type map[string]interface {}")
 		}
-		response.Body = target
-		return response, nil
+		response.Body, err = transformDecoded(config.ResponseTransformers, httpResp, target)
+		return err
 	}
 
 	// Handle string type specially - return raw body as string
 	if bTypeReflected.Kind() == reflect.String {
-		response.Body = string(bodyBytes)
-		return response, nil
+		response.Body, err = transformDecoded(config.ResponseTransformers, httpResp, string(bodyBytes))
+		return err
 	}
 
 	// Create a new instance of the underlying type for proper JSON unmarshaling
 	targetType := reflect.TypeOf(bType)
 	targetValue := reflect.New(targetType).Interface()
 
-	err = json.Unmarshal(bodyBytes, targetValue)
+	err = unmarshal(bodyBytes, targetValue)
 	if err != nil {
-		return response, errors.Wrapf(err, "failed to unmarshal response as type %T", bType)
+		return errors.Wrapf(tagError(err, ErrDecode), "failed to unmarshal response as type %T", bType)
 	}
 
 	// Dereference the pointer to get the actual value
-	response.Body = reflect.ValueOf(targetValue).Elem().Interface()
-	return response, nil
+	response.Body, err = transformDecoded(config.ResponseTransformers, httpResp, reflect.ValueOf(targetValue).Elem().Interface())
+	return err
+}
+
+// Close releases the streaming body, draining it so the connection can be reused
+// It is a no-op for non-streaming responses, whose body is already consumed
+func (r *Response) Close() error {
+	if r.StreamBody == nil {
+		return nil
+	}
+	return r.StreamBody.Close()
 }
 
 // Header returns the response headers
@@ -101,6 +133,23 @@ func (r *Response) Header() http.Header {
 	return r.header
 }
 
+// CacheStatus returns how the cache middleware answered the request, empty without caching
+func (r *Response) CacheStatus() CacheStatus {
+	return r.cacheStatus
+}
+
+// FromCache reports whether the response was served by the cache middleware rather than the origin, including
+// entries revalidated with a 304 Not Modified response
+func (r *Response) FromCache() bool {
+	return r.cacheStatus.FromCache()
+}
+
+// Stale reports whether the response is an expired cache entry, served while it is revalidated in the background
+// or because the origin failed, see CacheConfig.RevalidateAsync and CacheConfig.MaxStaleOnError
+func (r *Response) Stale() bool {
+	return r.cacheStatus == CacheStale
+}
+
 // tryParsingErrorResponse is a function that tries to parse the error response as JSON object or returns the raw body
 func tryParsingErrorResponse(contentBytes []byte) any {
 	parsedBody := make(map[string]any)
@@ -273,3 +322,19 @@ func (r *Response) GetCookies() []*http.Cookie {
 func (r *Response) HasCookie(name string) bool {
 	return r.GetCookie(name) != nil
 }
+
+// teeBody copies everything read from the response body to a writer
+type teeBody struct {
+	io.Reader
+	body io.ReadCloser
+}
+
+// newTeeBody wraps the body so reads are also written to w
+func newTeeBody(body io.ReadCloser, w io.Writer) io.ReadCloser {
+	return &teeBody{Reader: io.TeeReader(body, w), body: body}
+}
+
+// Close closes the underlying response body
+func (t *teeBody) Close() error {
+	return t.body.Close()
+}
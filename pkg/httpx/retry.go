@@ -2,11 +2,10 @@ package httpx
 
 import (
 	"context"
-	"crypto/rand"
 	"errors"
 	"fmt"
 	"math"
-	"math/big"
+	"math/rand/v2"
 	"net/http"
 	"time"
 )
@@ -23,6 +22,10 @@ const (
 	RetryStrategyExponential RetryStrategy = "exponential"
 	// RetryStrategyExponentialJitter uses exponential backoff with jitter
 	RetryStrategyExponentialJitter RetryStrategy = "exponential_jitter"
+	// RetryStrategyDecorrelatedJitter waits a random delay between BaseDelay and three times the previous delay,
+	// capped at MaxDelay, as AWS recommends: clients failing together quickly spread their retries apart instead of
+	// retrying in lockstep after a shared outage
+	RetryStrategyDecorrelatedJitter RetryStrategy = "decorrelated_jitter"
 )
 
 // RetryCondition determines whether a request should be retried based on error and response
@@ -48,6 +51,19 @@ type RetryPolicy struct {
 	// JitterMax adds random jitter up to this duration (only for jitter strategy)
 	JitterMax time.Duration
 
+	// RandSource is the random source for jitter (default: a per-middleware source seeded randomly)
+	// Set a seeded source, e.g. rand.NewPCG(1, 2), for deterministic delays in tests and simulations;
+	// it is guarded by a lock, so it need not be safe for concurrent use
+	RandSource rand.Source
+
+	// JitterSeed seeds the jitter source when RandSource is nil with a value identifying the replica, e.g. its
+	// hostname or pod name, so each replica of a deployment draws different delays from run to run while its
+	// own delays stay reproducible
+	JitterSeed string
+
+	// Clock is the clock backoffs wait on (default: the system clock)
+	Clock Clock
+
 	// Condition determines if a request should be retried
 	Condition RetryCondition
 
@@ -56,6 +72,18 @@ type RetryPolicy struct {
 
 	// RetryableErrorTypes defines which error types should trigger retries
 	RetryableErrorTypes []ErrorType
+
+	// RespectRetryAfter waits for the delay requested by the Retry-After header of 429 and 503 responses, given
+	// in seconds or as an HTTP-date and capped at MaxDelay, instead of the delay of the strategy
+	RespectRetryAfter bool
+
+	// OnRetry is called before waiting for each retry, with the number of the attempt about to be made, the delay
+	// waited and the response or error of the failed attempt, e.g. to log or count retry decisions
+	// The body of the response is already drained.
+	OnRetry func(attempt int, delay time.Duration, resp *http.Response, err error)
+
+	// Budget caps the retries of all the requests sharing the policy, see RetryBudget (default: unlimited)
+	Budget *RetryBudget
 }
 
 // DefaultRetryPolicy returns a sensible default retry policy
@@ -185,6 +213,8 @@ func ConservativeRetryCondition(attempt int, err error, resp *http.Response) boo
 // AdvancedRetryMiddleware implements sophisticated retry logic
 type AdvancedRetryMiddleware struct {
 	policy RetryPolicy
+	rand   *lockedSource
+	budget *retryBudget // Nil without a budget
 }
 
 // NewAdvancedRetryMiddleware creates a new advanced retry middleware
@@ -208,10 +238,19 @@ func NewAdvancedRetryMiddleware(policy RetryPolicy) *AdvancedRetryMiddleware {
 	if policy.Condition == nil {
 		policy.Condition = AdvancedDefaultRetryCondition
 	}
+	policy.Clock = clockOrSystem(policy.Clock)
+	if policy.RandSource == nil && policy.JitterSeed != "" {
+		policy.RandSource = seededSource(policy.JitterSeed)
+	}
 
-	return &AdvancedRetryMiddleware{
+	middleware := &AdvancedRetryMiddleware{
 		policy: policy,
+		rand:   newLockedSource(policy.RandSource),
+	}
+	if policy.Budget != nil {
+		middleware.budget = newRetryBudget(*policy.Budget, policy.Clock)
 	}
+	return middleware
 }
 
 // Name returns the middleware name
@@ -223,12 +262,17 @@ func (m *AdvancedRetryMiddleware) Name() string {
 func (m *AdvancedRetryMiddleware) Execute(ctx context.Context, req *http.Request, next MiddlewareFunc) (*http.Response, error) {
 	var lastErr error
 	var lastResp *http.Response
+	var delay time.Duration // Delay waited before the attempt, decorrelated jitter derives the next one from it
 
+	if m.budget != nil {
+		m.budget.deposit()
+	}
 	for attempt := range m.policy.MaxAttempts {
-		// Clone the request for retry attempts
-		reqClone := req.Clone(ctx)
+		// Clone the request for retry attempts, recording the attempt number for logs
+		attemptCtx := context.WithValue(ctx, retryAttemptKey{}, attempt+1)
+		reqClone := req.Clone(attemptCtx)
 
-		resp, err := next(ctx, reqClone)
+		resp, err := next(attemptCtx, reqClone)
 
 		// Check if this was successful or if we shouldn't retry
 		if !m.shouldRetry(attempt, err, resp) {
@@ -244,8 +288,37 @@ func (m *AdvancedRetryMiddleware) Execute(ctx context.Context, req *http.Request
 			break
 		}
 
-		// Calculate and apply delay
-		delay := m.calculateDelay(attempt)
+		// Calculate the delay, giving up at once if the deadline would pass while waiting
+		delay = m.calculateDelay(attempt, delay)
+		if retryAfter, ok := m.retryAfter(resp); ok {
+			delay = retryAfter
+		}
+		if deadlineErr := retryDeadlineError(ctx, req, m.policy.Clock, attempt+1, delay, err, resp); deadlineErr != nil {
+			return nil, deadlineErr
+		}
+
+		// Give up when the retries of the client exhausted its budget, only spent by retries actually made
+		if m.budget != nil && !m.budget.withdraw() {
+			return resp, err
+		}
+
+		// Drain the discarded response so its connection can be reused by the next attempt
+		if resp != nil && resp.Body != nil {
+			drainAndClose(resp.Body, defaultDrainLimit)
+		}
+		countRetry(ctx)
+		emitEvent(ctx, RetryEvent{
+			Time:        time.Now(),
+			Op:          requestOperation(req),
+			Attempt:     attempt + 2,
+			MaxAttempts: m.policy.MaxAttempts,
+			Delay:       delay,
+			StatusCode:  statusCodeOf(resp),
+			Err:         err,
+		})
+		if m.policy.OnRetry != nil {
+			m.policy.OnRetry(attempt+2, delay, resp, err)
+		}
 		if err := m.waitWithContext(ctx, delay); err != nil {
 			return nil, err // Context cancelled or deadline exceeded
 		}
@@ -253,6 +326,9 @@ func (m *AdvancedRetryMiddleware) Execute(ctx context.Context, req *http.Request
 
 	// Return the last error or response
 	if lastErr != nil {
+		if lastResp != nil && lastResp.Body != nil {
+			drainAndClose(lastResp.Body, defaultDrainLimit)
+		}
 		return nil, lastErr
 	}
 	return lastResp, nil
@@ -290,8 +366,9 @@ func (m *AdvancedRetryMiddleware) shouldRetry(attempt int, err error, resp *http
 	return false
 }
 
-// calculateDelay calculates the delay for the given attempt using the configured strategy
-func (m *AdvancedRetryMiddleware) calculateDelay(attempt int) time.Duration {
+// calculateDelay calculates the delay for the given attempt using the configured strategy, given the delay waited
+// before it
+func (m *AdvancedRetryMiddleware) calculateDelay(attempt int, previous time.Duration) time.Duration {
 	var delay time.Duration
 
 	switch m.policy.Strategy {
@@ -318,6 +395,10 @@ func (m *AdvancedRetryMiddleware) calculateDelay(attempt int) time.Duration {
 			delay = baseDelay
 		}
 
+	case RetryStrategyDecorrelatedJitter:
+		upper := 3 * max(previous, m.policy.BaseDelay)
+		delay = m.policy.BaseDelay + m.randomJitter(upper-m.policy.BaseDelay)
+
 	default:
 		// Default to exponential
 		multiplier := math.Pow(2.0, float64(attempt))
@@ -332,21 +413,25 @@ func (m *AdvancedRetryMiddleware) calculateDelay(attempt int) time.Duration {
 	return delay
 }
 
-// randomJitter generates random jitter up to the specified maximum
-func (m *AdvancedRetryMiddleware) randomJitter(maxJitter time.Duration) time.Duration {
-	if maxJitter <= 0 {
-		return 0
+// retryAfter returns the delay requested by the Retry-After header of a 429 or 503 response, capped at MaxDelay,
+// when the policy respects it
+func (m *AdvancedRetryMiddleware) retryAfter(resp *http.Response) (time.Duration, bool) {
+	if !m.policy.RespectRetryAfter || resp == nil {
+		return 0, false
 	}
-
-	// Generate cryptographically secure random number
-	maxNanos := maxJitter.Nanoseconds()
-	randomNanos, err := rand.Int(rand.Reader, big.NewInt(maxNanos))
-	if err != nil {
-		// Fallback to no jitter if random generation fails
-		return 0
+	if resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode != http.StatusServiceUnavailable {
+		return 0, false
+	}
+	delay, ok := ParseRetryAfter(resp.Header.Get("Retry-After"), m.policy.Clock.Now())
+	if !ok {
+		return 0, false
 	}
+	return min(delay, m.policy.MaxDelay), true
+}
 
-	return time.Duration(randomNanos.Int64())
+// randomJitter generates random jitter up to the specified maximum
+func (m *AdvancedRetryMiddleware) randomJitter(maxJitter time.Duration) time.Duration {
+	return m.rand.jitter(maxJitter)
 }
 
 // waitWithContext waits for the specified duration while respecting context cancellation
@@ -355,17 +440,65 @@ func (m *AdvancedRetryMiddleware) waitWithContext(ctx context.Context, delay tim
 		return nil
 	}
 
-	timer := time.NewTimer(delay)
+	timer := m.policy.Clock.NewTimer(delay)
 	defer timer.Stop()
 
 	select {
 	case <-ctx.Done():
 		return ctx.Err()
-	case <-timer.C:
+	case <-timer.C():
 		return nil
 	}
 }
 
+// RetryDeadlineError reports a retry skipped because its backoff would outlast the context deadline
+// It is the cause of a timeout HTTPError and matches ErrDeadlineWouldExceed and the error of the last attempt.
+type RetryDeadlineError struct {
+	Attempts   int           // Attempts made before giving up
+	Delay      time.Duration // Backoff the next attempt would have waited
+	Remaining  time.Duration // Time left before the deadline
+	StatusCode int           // Status code of the last attempt, 0 if it failed without a response
+	Err        error         // Error of the last attempt, nil if it failed with a retryable status code
+}
+
+// Error implements the error interface
+func (e *RetryDeadlineError) Error() string {
+	msg := fmt.Sprintf("gave up after %d attempts: backoff of %v exceeds the %v left before the deadline", e.Attempts, e.Delay, e.Remaining)
+	switch {
+	case e.Err != nil:
+		msg += fmt.Sprintf(", last error: %v", e.Err)
+	case e.StatusCode != 0:
+		msg += fmt.Sprintf(", last status: %d", e.StatusCode)
+	}
+	return msg
+}
+
+// Unwrap exposes the error of the last attempt and ErrDeadlineWouldExceed to errors.Is and errors.As
+func (e *RetryDeadlineError) Unwrap() []error {
+	if e.Err == nil {
+		return []error{ErrDeadlineWouldExceed}
+	}
+	return []error{e.Err, ErrDeadlineWouldExceed}
+}
+
+// retryDeadlineError returns a timeout error if ctx has a deadline closer than delay on clock, nil if the retry
+// can wait. The response of the last attempt, if any, is drained since it is not returned.
+func retryDeadlineError(ctx context.Context, req *http.Request, clock Clock, attempts int, delay time.Duration, err error, resp *http.Response) error {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return nil
+	}
+	remaining := deadline.Sub(clock.Now())
+	if delay < remaining {
+		return nil
+	}
+	if resp != nil && resp.Body != nil {
+		drainAndClose(resp.Body, defaultDrainLimit)
+	}
+	cause := &RetryDeadlineError{Attempts: attempts, Delay: delay, Remaining: max(remaining, 0), StatusCode: statusCodeOf(resp), Err: err}
+	return TimeoutError("retry backoff would exceed the deadline", cause, req)
+}
+
 // RetryableError wraps an error to indicate it should be retried
 type RetryableError struct {
 	Err   error
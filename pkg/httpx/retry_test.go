@@ -3,8 +3,10 @@ package httpx_test
 import (
 	"context"
 	"errors"
+	"math/rand/v2"
 	"net/http"
 	"net/http/httptest"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -12,8 +14,19 @@ import (
 	"github.com/stretchr/testify/require"
 
 	"github.com/bdpiprava/easy-http/pkg/httpx"
+	httpxtesting "github.com/bdpiprava/easy-http/pkg/httpx/testing"
 )
 
+// fakeDeadlineContext reports a deadline on a fake clock, which the context itself never reaches
+type fakeDeadlineContext struct {
+	context.Context
+	deadline time.Time
+}
+
+func (c fakeDeadlineContext) Deadline() (time.Time, bool) {
+	return c.deadline, true
+}
+
 func TestRetryPolicies(t *testing.T) {
 	t.Run("default retry policy", func(t *testing.T) {
 		policy := httpx.DefaultRetryPolicy()
@@ -228,8 +241,10 @@ func TestAdvancedRetryMiddleware(t *testing.T) {
 			return nil, errors.New("error")
 		}
 
-		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		// Cancel rather than set a deadline, which would skip the retry without waiting
+		ctx, cancel := context.WithCancel(context.Background())
 		defer cancel()
+		time.AfterFunc(50*time.Millisecond, cancel)
 
 		req, _ := http.NewRequest("GET", "http://example.com", nil)
 
@@ -240,7 +255,7 @@ func TestAdvancedRetryMiddleware(t *testing.T) {
 
 		require.Error(t, err)
 		assert.Nil(t, resp)
-		require.ErrorIs(t, err, context.DeadlineExceeded)
+		require.ErrorIs(t, err, context.Canceled)
 		assert.Less(t, duration, 200*time.Millisecond) // Should be cancelled before second retry
 		assert.Equal(t, 1, attemptCount)               // Should only attempt once before context cancellation
 	})
@@ -389,6 +404,64 @@ func TestRetryWithJitter(t *testing.T) {
 		// We should have at least 2 different delays out of 5 runs
 		assert.GreaterOrEqual(t, len(uniqueDelays), 2, "Jitter should create different delays")
 	})
+
+	t.Run("draws jitter from the policy random source", func(t *testing.T) {
+		source := &countingSource{Source: rand.NewPCG(1, 2)}
+		middleware := httpx.NewAdvancedRetryMiddleware(httpx.RetryPolicy{
+			MaxAttempts: 3,
+			BaseDelay:   time.Millisecond,
+			Strategy:    httpx.RetryStrategyExponentialJitter,
+			JitterMax:   time.Millisecond,
+			RandSource:  source,
+		})
+
+		next := func(_ context.Context, _ *http.Request) (*http.Response, error) {
+			return nil, httpx.NetworkError("connection refused", nil, nil)
+		}
+		req, _ := http.NewRequest(http.MethodGet, "http://example.com", nil)
+		_, err := middleware.Execute(context.Background(), req, next)
+
+		require.Error(t, err)
+		assert.Equal(t, int32(2), source.calls.Load())
+	})
+
+	t.Run("draws jitter from the client random source", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}))
+		defer server.Close()
+
+		source := &countingSource{Source: rand.NewPCG(1, 2)}
+		client := httpx.NewClientWithConfig(
+			httpx.WithClientDefaultBaseURL(server.URL),
+			httpx.WithClientRandSource(source),
+			httpx.WithClientRetryPolicy(httpx.RetryPolicy{
+				MaxAttempts: 3,
+				BaseDelay:   time.Millisecond,
+				Strategy:    httpx.RetryStrategyExponentialJitter,
+				JitterMax:   time.Millisecond,
+				Condition: func(_ int, _ error, resp *http.Response) bool {
+					return resp != nil && resp.StatusCode == http.StatusServiceUnavailable
+				},
+			}),
+		)
+
+		_, err := client.Execute(*httpx.NewRequest(http.MethodGet), map[string]any{})
+
+		require.NoError(t, err)
+		assert.Equal(t, int32(2), source.calls.Load())
+	})
+}
+
+// countingSource counts the values drawn from the wrapped source
+type countingSource struct {
+	rand.Source
+	calls atomic.Int32
+}
+
+func (s *countingSource) Uint64() uint64 {
+	s.calls.Add(1)
+	return s.Source.Uint64()
 }
 
 func TestRetryableError(t *testing.T) {
@@ -413,6 +486,102 @@ func TestRetryableError(t *testing.T) {
 	})
 }
 
+func TestRetryDeadline(t *testing.T) {
+	var attempts atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		attempts.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	t.Run("gives up when the backoff would exceed the deadline", func(t *testing.T) {
+		attempts.Store(0)
+		client := httpx.NewClientWithConfig(
+			httpx.WithClientDefaultBaseURL(server.URL),
+			httpx.WithClientRetryPolicy(httpx.RetryPolicy{
+				MaxAttempts: 5,
+				BaseDelay:   50 * time.Millisecond,
+				MaxDelay:    time.Second,
+				Strategy:    httpx.RetryStrategyExponential,
+			}),
+		)
+		ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+		defer cancel()
+
+		start := time.Now()
+		_, err := client.Execute(*httpx.NewRequest(http.MethodGet, httpx.WithContext(ctx)), nil)
+
+		// Backoffs of 50ms, 100ms and 200ms: the third retry would wait past the deadline
+		require.Error(t, err)
+		assert.Less(t, time.Since(start), 250*time.Millisecond)
+		assert.ErrorIs(t, err, httpx.ErrDeadlineWouldExceed)
+		assert.ErrorIs(t, err, httpx.ErrTimeout)
+		assert.True(t, httpx.IsTimeoutError(err))
+		var deadlineErr *httpx.RetryDeadlineError
+		require.ErrorAs(t, err, &deadlineErr)
+		assert.Equal(t, 3, deadlineErr.Attempts)
+		assert.Equal(t, 200*time.Millisecond, deadlineErr.Delay)
+		assert.Equal(t, http.StatusServiceUnavailable, deadlineErr.StatusCode)
+		assert.Equal(t, int32(3), attempts.Load())
+		require.NoError(t, ctx.Err())
+	})
+
+	t.Run("measures the time left with the clock of the policy", func(t *testing.T) {
+		attempts.Store(0)
+		clock := httpxtesting.NewFakeClock()
+		client := httpx.NewClientWithConfig(
+			httpx.WithClientDefaultBaseURL(server.URL),
+			httpx.WithClientRetryPolicy(httpx.RetryPolicy{
+				MaxAttempts: 5,
+				BaseDelay:   50 * time.Millisecond,
+				MaxDelay:    time.Second,
+				Strategy:    httpx.RetryStrategyExponential,
+				Clock:       clock,
+			}),
+		)
+		ctx := fakeDeadlineContext{Context: context.Background(), deadline: clock.Now().Add(120 * time.Millisecond)}
+		go clock.AdvanceToNext()
+
+		_, err := client.Execute(*httpx.NewRequest(http.MethodGet, httpx.WithContext(ctx)), nil)
+
+		// The first backoff of 50ms fits in the 120ms left, the second of 100ms does not fit in the 70ms left
+		var deadlineErr *httpx.RetryDeadlineError
+		require.ErrorAs(t, err, &deadlineErr)
+		assert.Equal(t, 2, deadlineErr.Attempts)
+		assert.Equal(t, 100*time.Millisecond, deadlineErr.Delay)
+		assert.Equal(t, 70*time.Millisecond, deadlineErr.Remaining)
+	})
+
+	t.Run("gives up with the legacy retry middleware", func(t *testing.T) {
+		attempts.Store(0)
+		client := httpx.NewClientWithConfig(
+			httpx.WithClientDefaultBaseURL(server.URL),
+			httpx.WithClientMiddleware(httpx.NewRetryMiddleware(httpx.RetryConfig{MaxRetries: 3, BaseDelay: time.Second})),
+		)
+		ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+		defer cancel()
+
+		_, err := client.Execute(*httpx.NewRequest(http.MethodGet, httpx.WithContext(ctx)), nil)
+
+		require.ErrorIs(t, err, httpx.ErrDeadlineWouldExceed)
+		assert.Equal(t, int32(1), attempts.Load())
+	})
+
+	t.Run("retries without a deadline", func(t *testing.T) {
+		attempts.Store(0)
+		client := httpx.NewClientWithConfig(
+			httpx.WithClientDefaultBaseURL(server.URL),
+			httpx.WithClientRetryPolicy(httpx.RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, Strategy: httpx.RetryStrategyFixed}),
+		)
+
+		resp, err := client.Execute(*httpx.NewRequest(http.MethodGet), nil)
+
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+		assert.Equal(t, int32(3), attempts.Load())
+	})
+}
+
 func TestClientWithRetryPolicy(t *testing.T) {
 	t.Run("client with default retry policy", func(t *testing.T) {
 		// Create a server that fails twice then succeeds
@@ -531,3 +700,106 @@ func (m *testMiddleware) Name() string {
 func (m *testMiddleware) Execute(ctx context.Context, req *http.Request, next httpx.MiddlewareFunc) (*http.Response, error) {
 	return m.execute(ctx, req, next)
 }
+
+func TestRetryAfter(t *testing.T) {
+	type retry struct {
+		attempt int
+		delay   time.Duration
+		status  int
+	}
+	run := func(t *testing.T, respect bool, responses ...*http.Response) []retry {
+		var retries []retry
+		middleware := httpx.NewAdvancedRetryMiddleware(httpx.RetryPolicy{
+			MaxAttempts:          len(responses) + 1,
+			BaseDelay:            20 * time.Millisecond,
+			MaxDelay:             30 * time.Millisecond,
+			Strategy:             httpx.RetryStrategyFixed,
+			Condition:            func(_ int, _ error, resp *http.Response) bool { return resp.StatusCode >= 429 },
+			RespectRetryAfter:    respect,
+			RetryableStatusCodes: []int{429, 500, 503},
+			OnRetry: func(attempt int, delay time.Duration, resp *http.Response, _ error) {
+				retries = append(retries, retry{attempt: attempt, delay: delay, status: resp.StatusCode})
+			},
+		})
+		responses = append(responses, &http.Response{StatusCode: http.StatusOK, Body: http.NoBody})
+		next := func(_ context.Context, _ *http.Request) (*http.Response, error) {
+			resp := responses[0]
+			responses = responses[1:]
+			return resp, nil
+		}
+		req, _ := http.NewRequest(http.MethodGet, "http://example.com", nil)
+
+		resp, err := middleware.Execute(context.Background(), req, next)
+		defer closeSafely(resp)
+
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		return retries
+	}
+	response := func(status int, retryAfter string) *http.Response {
+		return &http.Response{StatusCode: status, Header: http.Header{"Retry-After": {retryAfter}}, Body: http.NoBody}
+	}
+	past := time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat)
+
+	t.Run("waits for the delay requested by 429 and 503 responses", func(t *testing.T) {
+		retries := run(t, true,
+			response(http.StatusTooManyRequests, past),
+			response(http.StatusServiceUnavailable, "5"),
+			response(http.StatusInternalServerError, "0"),
+			response(http.StatusServiceUnavailable, "soon"),
+		)
+
+		assert.Equal(t, []retry{
+			{attempt: 2, delay: 0, status: http.StatusTooManyRequests},
+			{attempt: 3, delay: 30 * time.Millisecond, status: http.StatusServiceUnavailable}, // Capped at MaxDelay
+			{attempt: 4, delay: 20 * time.Millisecond, status: http.StatusInternalServerError},
+			{attempt: 5, delay: 20 * time.Millisecond, status: http.StatusServiceUnavailable},
+		}, retries)
+	})
+
+	t.Run("ignores Retry-After unless enabled", func(t *testing.T) {
+		retries := run(t, false, response(http.StatusTooManyRequests, past))
+
+		assert.Equal(t, []retry{{attempt: 2, delay: 20 * time.Millisecond, status: http.StatusTooManyRequests}}, retries)
+	})
+}
+
+func TestDecorrelatedJitter(t *testing.T) {
+	delays := func(seed string) []time.Duration {
+		var waited []time.Duration
+		middleware := httpx.NewAdvancedRetryMiddleware(httpx.RetryPolicy{
+			MaxAttempts: 6,
+			BaseDelay:   time.Microsecond,
+			MaxDelay:    40 * time.Microsecond,
+			Strategy:    httpx.RetryStrategyDecorrelatedJitter,
+			JitterSeed:  seed,
+			Condition:   func(_ int, err error, _ *http.Response) bool { return err != nil },
+			OnRetry: func(_ int, delay time.Duration, _ *http.Response, _ error) {
+				waited = append(waited, delay)
+			},
+		})
+		next := func(_ context.Context, _ *http.Request) (*http.Response, error) {
+			return nil, errors.New("connection reset")
+		}
+		req, _ := http.NewRequest(http.MethodGet, "http://example.com", nil)
+		_, _ = middleware.Execute(context.Background(), req, next)
+		return waited
+	}
+
+	t.Run("draws delays between the base delay and three times the previous one", func(t *testing.T) {
+		waited := delays("")
+
+		require.Len(t, waited, 5)
+		previous := time.Microsecond
+		for _, delay := range waited {
+			assert.GreaterOrEqual(t, delay, time.Microsecond)
+			assert.LessOrEqual(t, delay, min(3*previous, 40*time.Microsecond))
+			previous = delay
+		}
+	})
+
+	t.Run("seeds the jitter per replica", func(t *testing.T) {
+		assert.Equal(t, delays("replica-a"), delays("replica-a"))
+		assert.NotEqual(t, delays("replica-a"), delays("replica-b"))
+	})
+}
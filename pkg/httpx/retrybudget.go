@@ -0,0 +1,88 @@
+package httpx
+
+import (
+	"sync"
+	"time"
+)
+
+// RetryBudget caps the retries of a client in aggregate, so failing dependencies are not hit by a retry storm
+// when many requests fail and retry at once
+//
+// The budget is a token bucket shared by every request of the client: each request deposits Ratio tokens, the
+// bucket refills at MinPerSecond tokens per second, and each retry takes a token. A request whose retry finds
+// the bucket empty is not retried, its last response or error is returned as is. The bucket starts full and
+// holds up to Burst tokens.
+type RetryBudget struct {
+	// Ratio is the number of retries each request earns, e.g. 0.1 to retry at most one request in ten
+	// (default: 0.1, negative: none)
+	Ratio float64
+
+	// MinPerSecond is the number of retries allowed per second regardless of the number of requests, so
+	// clients sending few requests can still retry (default: 10, negative: none)
+	MinPerSecond float64
+
+	// Burst is the number of retries that can be saved up for a burst of failures (default: 10)
+	Burst int
+}
+
+// retryBudget is the state of a RetryBudget
+type retryBudget struct {
+	mu         sync.Mutex
+	ratio      float64
+	perSecond  float64
+	capacity   float64
+	tokens     float64
+	lastRefill time.Time
+	clock      Clock
+}
+
+// newRetryBudget creates the state of budget, full, with the defaults of its unset fields
+func newRetryBudget(budget RetryBudget, clock Clock) *retryBudget {
+	if budget.Ratio == 0 {
+		budget.Ratio = 0.1
+	}
+	if budget.MinPerSecond == 0 {
+		budget.MinPerSecond = 10
+	}
+	if budget.Burst <= 0 {
+		budget.Burst = 10
+	}
+	return &retryBudget{
+		ratio:      max(budget.Ratio, 0),
+		perSecond:  max(budget.MinPerSecond, 0),
+		capacity:   float64(budget.Burst),
+		tokens:     float64(budget.Burst),
+		lastRefill: clock.Now(),
+		clock:      clock,
+	}
+}
+
+// deposit earns the retries of a request
+func (b *retryBudget) deposit() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.refill()
+	b.tokens = min(b.tokens+b.ratio, b.capacity)
+}
+
+// withdraw takes the token of a retry, reporting whether the budget allows it
+func (b *retryBudget) withdraw() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.refill()
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// refill adds the tokens earned per second since the last refill
+func (b *retryBudget) refill() {
+	now := b.clock.Now()
+	elapsed := now.Sub(b.lastRefill).Seconds()
+	b.lastRefill = now
+	if elapsed > 0 {
+		b.tokens = min(b.tokens+elapsed*b.perSecond, b.capacity)
+	}
+}
@@ -0,0 +1,88 @@
+package httpx_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bdpiprava/easy-http/pkg/httpx"
+)
+
+func TestRetryBudget(t *testing.T) {
+	t.Parallel()
+
+	var attempts atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts.Add(1)
+		if r.URL.Path == "/ok" {
+			return
+		}
+		if r.URL.Path == "/later" {
+			w.Header().Set("Retry-After", "1")
+		}
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+	newClient := func(budget httpx.RetryBudget) *httpx.Client {
+		return httpx.NewClientWithConfig(
+			httpx.WithClientDefaultBaseURL(server.URL),
+			httpx.WithClientRetryPolicy(httpx.RetryPolicy{
+				MaxAttempts:          3,
+				BaseDelay:            time.Millisecond,
+				MaxDelay:             time.Second,
+				RespectRetryAfter:    true,
+				Strategy:             httpx.RetryStrategyFixed,
+				Condition:            func(_ int, _ error, resp *http.Response) bool { return resp != nil && resp.StatusCode >= 500 },
+				RetryableStatusCodes: []int{http.StatusServiceUnavailable},
+			}),
+			httpx.WithClientRetryBudget(budget),
+		)
+	}
+	attemptsOf := func(t *testing.T, client *httpx.Client, path string) int32 {
+		attempts.Store(0)
+		_, _ = client.Execute(*httpx.NewRequest(http.MethodGet, httpx.WithPath(path)), "")
+		return attempts.Load()
+	}
+
+	t.Run("stops retrying once the budget is spent", func(t *testing.T) {
+		client := newClient(httpx.RetryBudget{Ratio: -1, MinPerSecond: -1, Burst: 2})
+
+		assert.Equal(t, int32(3), attemptsOf(t, client, "/fail"))
+		assert.Equal(t, int32(1), attemptsOf(t, client, "/fail"))
+	})
+
+	t.Run("earns retries from requests", func(t *testing.T) {
+		client := newClient(httpx.RetryBudget{Ratio: 0.5, MinPerSecond: -1, Burst: 1})
+
+		assert.Equal(t, int32(2), attemptsOf(t, client, "/fail"))
+		assert.Equal(t, int32(1), attemptsOf(t, client, "/fail"), "half a retry earned")
+		assert.Equal(t, int32(1), attemptsOf(t, client, "/ok"))
+		assert.Equal(t, int32(2), attemptsOf(t, client, "/fail"))
+	})
+
+	t.Run("earns retries over time", func(t *testing.T) {
+		client := newClient(httpx.RetryBudget{Ratio: -1, MinPerSecond: 100, Burst: 1})
+		require.Equal(t, int32(2), attemptsOf(t, client, "/fail"))
+
+		time.Sleep(20 * time.Millisecond)
+
+		assert.Equal(t, int32(2), attemptsOf(t, client, "/fail"))
+	})
+	t.Run("retries abandoned at the deadline do not spend the budget", func(t *testing.T) {
+		client := newClient(httpx.RetryBudget{Ratio: -1, MinPerSecond: -1, Burst: 1})
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+
+		_, err := client.Execute(*httpx.NewRequest(http.MethodGet, httpx.WithPath("/later"), httpx.WithContext(ctx)), "")
+		require.ErrorIs(t, err, httpx.ErrDeadlineWouldExceed)
+
+		assert.Equal(t, int32(2), attemptsOf(t, client, "/fail"))
+		assert.Equal(t, int32(1), attemptsOf(t, client, "/fail"))
+	})
+}
@@ -0,0 +1,239 @@
+package httpx
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"time"
+
+	"golang.org/x/crypto/ocsp"
+)
+
+// RevocationCheckMode defines how certificate revocation status is enforced for outbound TLS
+type RevocationCheckMode string
+
+const (
+	// RevocationCheckDisabled skips revocation checking entirely (default)
+	RevocationCheckDisabled RevocationCheckMode = "disabled"
+	// RevocationCheckSoftFail rejects revoked certificates but allows connections when the
+	// revocation status cannot be determined (missing, unknown or invalid OCSP staple)
+	RevocationCheckSoftFail RevocationCheckMode = "soft_fail"
+	// RevocationCheckHardFail rejects every connection that does not present a valid "good" OCSP staple
+	RevocationCheckHardFail RevocationCheckMode = "hard_fail"
+)
+
+// RevocationStatus represents the revocation status reported for a server certificate
+type RevocationStatus string
+
+const (
+	// RevocationStatusGood indicates the stapled OCSP response reports the certificate as good
+	RevocationStatusGood RevocationStatus = "good"
+	// RevocationStatusRevoked indicates the stapled OCSP response reports the certificate as revoked
+	RevocationStatusRevoked RevocationStatus = "revoked"
+	// RevocationStatusUnknown indicates the OCSP responder does not know about the certificate
+	RevocationStatusUnknown RevocationStatus = "unknown"
+	// RevocationStatusMissing indicates the server did not staple an OCSP response
+	RevocationStatusMissing RevocationStatus = "missing"
+	// RevocationStatusInvalid indicates the stapled OCSP response could not be parsed or verified
+	RevocationStatusInvalid RevocationStatus = "invalid"
+)
+
+// RevocationResult describes the outcome of a revocation check for a single TLS handshake
+type RevocationResult struct {
+	ServerName  string            // SNI server name of the connection
+	Status      RevocationStatus  // Revocation status determined from the OCSP staple
+	Certificate *x509.Certificate // Leaf certificate presented by the server
+	RevokedAt   time.Time         // Revocation time (only set when Status is revoked)
+	NextUpdate  time.Time         // When the responder will publish newer information (may be zero)
+	Err         error             // Parsing or verification error (only set when Status is invalid)
+}
+
+// RevocationSoftFailHandler is called when a revocation check soft-fails
+type RevocationSoftFailHandler func(result RevocationResult)
+
+// RevocationError is returned when a server certificate fails revocation checking
+type RevocationError struct {
+	Result RevocationResult
+}
+
+// Error implements the error interface
+func (e *RevocationError) Error() string {
+	if e.Result.Err != nil {
+		return fmt.Sprintf("certificate revocation check failed for %s: %s: %v", e.Result.ServerName, e.Result.Status, e.Result.Err)
+	}
+	return fmt.Sprintf("certificate revocation check failed for %s: %s", e.Result.ServerName, e.Result.Status)
+}
+
+// Unwrap implements the unwrapper interface for error chains
+func (e *RevocationError) Unwrap() error {
+	return e.Result.Err
+}
+
+// IsRevocationError checks if an error was caused by a failed certificate revocation check
+func IsRevocationError(err error) bool {
+	revocationErr := &RevocationError{}
+	return errors.As(err, &revocationErr)
+}
+
+// transportFor returns the *http.Transport used by the client, installing a clone of
+// http.DefaultTransport when the client does not have one yet
+func transportFor(httpClient *http.Client) *http.Transport {
+	if transport, ok := httpClient.Transport.(*http.Transport); ok && transport != nil {
+		return transport
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	httpClient.Transport = transport
+	return transport
+}
+
+// configureTLS applies TLS settings, the client certificate provider and revocation checking to the transport
+func configureTLS(transport *http.Transport, config *ClientConfig) {
+	if config.TLSConfig != nil {
+		transport.TLSClientConfig = config.TLSConfig.Clone()
+	}
+	if config.CertificateProvider != nil {
+		configureClientCertificate(transport, config.CertificateProvider)
+	}
+
+	if config.RevocationCheck == "" || config.RevocationCheck == RevocationCheckDisabled {
+		return
+	}
+
+	if transport.TLSClientConfig == nil {
+		transport.TLSClientConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+	}
+
+	checker := &revocationChecker{
+		mode:       config.RevocationCheck,
+		onSoftFail: config.RevocationSoftFailHandler,
+		logger:     config.Logger,
+	}
+
+	// Chain with any existing VerifyConnection callback so user verification still runs
+	previous := transport.TLSClientConfig.VerifyConnection
+	transport.TLSClientConfig.VerifyConnection = func(cs tls.ConnectionState) error {
+		if previous != nil {
+			if err := previous(cs); err != nil {
+				return err
+			}
+		}
+		return checker.verify(cs)
+	}
+}
+
+// revocationChecker validates stapled OCSP responses during the TLS handshake
+type revocationChecker struct {
+	mode       RevocationCheckMode
+	onSoftFail RevocationSoftFailHandler
+	logger     *slog.Logger
+}
+
+// verify checks the OCSP staple of the connection and enforces the configured mode
+func (c *revocationChecker) verify(cs tls.ConnectionState) error {
+	if len(cs.PeerCertificates) == 0 {
+		return nil
+	}
+
+	result := checkStapledOCSP(cs)
+
+	switch result.Status {
+	case RevocationStatusGood:
+		return nil
+	case RevocationStatusRevoked:
+		return &RevocationError{Result: result}
+	}
+
+	if c.mode == RevocationCheckHardFail {
+		return &RevocationError{Result: result}
+	}
+
+	if c.logger != nil {
+		c.logger.LogAttrs(context.Background(), slog.LevelWarn, "Certificate revocation status could not be confirmed",
+			slog.String(LogFieldServerName, result.ServerName),
+			slog.String(LogFieldRevocation, string(result.Status)),
+		)
+	}
+	if c.onSoftFail != nil {
+		c.onSoftFail(result)
+	}
+	return nil
+}
+
+// checkStapledOCSP determines the revocation status of the leaf certificate from the stapled OCSP response
+func checkStapledOCSP(cs tls.ConnectionState) RevocationResult {
+	leaf := cs.PeerCertificates[0]
+	result := RevocationResult{
+		ServerName:  cs.ServerName,
+		Certificate: leaf,
+	}
+
+	if len(cs.OCSPResponse) == 0 {
+		result.Status = RevocationStatusMissing
+		return result
+	}
+
+	issuer := findIssuer(cs)
+	resp, err := parseOCSPResponse(cs.OCSPResponse, leaf, issuer)
+	if err != nil {
+		result.Status = RevocationStatusInvalid
+		result.Err = err
+		return result
+	}
+
+	result.NextUpdate = resp.NextUpdate
+	switch resp.Status {
+	case ocsp.Good:
+		result.Status = RevocationStatusGood
+	case ocsp.Revoked:
+		result.Status = RevocationStatusRevoked
+		result.RevokedAt = resp.RevokedAt
+	default:
+		result.Status = RevocationStatusUnknown
+	}
+	return result
+}
+
+// findIssuer returns the issuer of the leaf certificate from the verified chain
+func findIssuer(cs tls.ConnectionState) *x509.Certificate {
+	for _, chain := range cs.VerifiedChains {
+		if len(chain) > 1 {
+			return chain[1]
+		}
+	}
+	if len(cs.PeerCertificates) > 1 {
+		return cs.PeerCertificates[1]
+	}
+	// Self-signed leaf acts as its own issuer
+	return cs.PeerCertificates[0]
+}
+
+// ocspClockSkew is how far in the future the ThisUpdate of an OCSP response may be, as clocks are not in sync
+const ocspClockSkew = 5 * time.Minute
+
+// parseOCSPResponse parses a DER encoded OCSP response, verifies it was signed by the issuer or a responder it
+// authorized, and returns the status of the leaf certificate if the response is currently valid
+func parseOCSPResponse(der []byte, leaf, issuer *x509.Certificate) (*ocsp.Response, error) {
+	resp, err := ocsp.ParseResponseForCert(der, leaf, issuer)
+	if err != nil {
+		return nil, fmt.Errorf("invalid OCSP response: %w", err)
+	}
+	// The parser checks a delegated responder was issued by the issuer, but not that it may sign OCSP responses
+	if resp.Certificate != nil && !resp.Certificate.Equal(issuer) &&
+		!slices.Contains(resp.Certificate.ExtKeyUsage, x509.ExtKeyUsageOCSPSigning) {
+		return nil, errors.New("OCSP responder certificate is not authorized for OCSP signing")
+	}
+
+	now := time.Now()
+	if resp.ThisUpdate.After(now.Add(ocspClockSkew)) {
+		return nil, errors.New("OCSP response is not yet valid")
+	}
+	if !resp.NextUpdate.IsZero() && now.After(resp.NextUpdate) {
+		return nil, errors.New("OCSP response has expired")
+	}
+	return resp, nil
+}
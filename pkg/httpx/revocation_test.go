@@ -0,0 +1,343 @@
+package httpx_test
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ocsp"
+
+	"github.com/bdpiprava/easy-http/pkg/httpx"
+)
+
+type testPKI struct {
+	caCert   *x509.Certificate
+	caKey    *ecdsa.PrivateKey
+	leafDER  []byte
+	leafKey  *ecdsa.PrivateKey
+	leafCert *x509.Certificate
+}
+
+func newTestPKI(t *testing.T) *testPKI {
+	t.Helper()
+
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test-ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	require.NoError(t, err)
+	caCert, err := x509.ParseCertificate(caDER)
+	require.NoError(t, err)
+
+	leafKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	leafTemplate := &x509.Certificate{
+		SerialNumber: big.NewInt(42),
+		Subject:      pkix.Name{CommonName: "127.0.0.1"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+	leafDER, err := x509.CreateCertificate(rand.Reader, leafTemplate, caCert, &leafKey.PublicKey, caKey)
+	require.NoError(t, err)
+	leafCert, err := x509.ParseCertificate(leafDER)
+	require.NoError(t, err)
+
+	return &testPKI{caCert: caCert, caKey: caKey, leafDER: leafDER, leafKey: leafKey, leafCert: leafCert}
+}
+
+// staple builds an OCSP response for the leaf certificate signed by the CA, changed by the modifiers
+func (p *testPKI) staple(t *testing.T, status httpx.RevocationStatus, modifiers ...func(*ocsp.Response)) []byte {
+	t.Helper()
+
+	template := ocsp.Response{
+		Status:       ocsp.Good,
+		SerialNumber: p.leafCert.SerialNumber,
+		IssuerHash:   crypto.SHA256,
+		ThisUpdate:   time.Now().Add(-time.Minute),
+		NextUpdate:   time.Now().Add(time.Hour),
+	}
+	switch status {
+	case httpx.RevocationStatusUnknown:
+		template.Status = ocsp.Unknown
+	case httpx.RevocationStatusRevoked:
+		template.Status = ocsp.Revoked
+		template.RevokedAt = time.Now().Add(-time.Minute)
+	}
+	for _, modify := range modifiers {
+		modify(&template)
+	}
+
+	der, err := ocsp.CreateResponse(p.caCert, p.caCert, template, p.caKey)
+	require.NoError(t, err)
+	return der
+}
+
+// delegatedStaple builds a good OCSP response for the leaf certificate signed by a responder the CA issued with
+// the given extended key usages
+func (p *testPKI) delegatedStaple(t *testing.T, usages ...x509.ExtKeyUsage) []byte {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	der, err := x509.CreateCertificate(rand.Reader, &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "test-ocsp-responder"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  usages,
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}, p.caCert, &key.PublicKey, p.caKey)
+	require.NoError(t, err)
+	responder, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	staple, err := ocsp.CreateResponse(p.caCert, responder, ocsp.Response{
+		Status:       ocsp.Good,
+		SerialNumber: p.leafCert.SerialNumber,
+		ThisUpdate:   time.Now().Add(-time.Minute),
+		NextUpdate:   time.Now().Add(time.Hour),
+		Certificate:  responder,
+	}, key)
+	require.NoError(t, err)
+	return staple
+}
+
+// server starts a TLS server presenting the leaf certificate with the given OCSP staple
+func (p *testPKI) server(t *testing.T, staple []byte) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte(`{"ok":true}`))
+	}))
+	server.TLS = &tls.Config{
+		Certificates: []tls.Certificate{{
+			Certificate: [][]byte{p.leafDER},
+			PrivateKey:  p.leafKey,
+			OCSPStaple:  staple,
+		}},
+		MinVersion: tls.VersionTLS12,
+	}
+	server.StartTLS()
+	t.Cleanup(server.Close)
+	return server
+}
+
+func (p *testPKI) tlsConfig() *tls.Config {
+	pool := x509.NewCertPool()
+	pool.AddCert(p.caCert)
+	return &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}
+}
+
+func TestRevocationCheck(t *testing.T) {
+	pki := newTestPKI(t)
+
+	t.Run("soft fail allows missing staple and invokes handler", func(t *testing.T) {
+		server := pki.server(t, nil)
+
+		var results []httpx.RevocationResult
+		client := httpx.NewClientWithConfig(
+			httpx.WithClientDefaultBaseURL(server.URL),
+			httpx.WithClientTLSConfig(pki.tlsConfig()),
+			httpx.WithClientRevocationCheck(httpx.RevocationCheckSoftFail),
+			httpx.WithClientRevocationSoftFailHandler(func(result httpx.RevocationResult) {
+				results = append(results, result)
+			}),
+		)
+
+		resp, err := client.Execute(*httpx.NewRequest(http.MethodGet), map[string]any{})
+
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		require.Len(t, results, 1)
+		assert.Equal(t, httpx.RevocationStatusMissing, results[0].Status)
+		assert.Equal(t, pki.leafCert.SerialNumber, results[0].Certificate.SerialNumber)
+	})
+
+	t.Run("hard fail rejects missing staple", func(t *testing.T) {
+		server := pki.server(t, nil)
+
+		client := httpx.NewClientWithConfig(
+			httpx.WithClientDefaultBaseURL(server.URL),
+			httpx.WithClientTLSConfig(pki.tlsConfig()),
+			httpx.WithClientRevocationCheck(httpx.RevocationCheckHardFail),
+		)
+
+		_, err := client.Execute(*httpx.NewRequest(http.MethodGet), map[string]any{})
+
+		require.Error(t, err)
+		assert.True(t, httpx.IsRevocationError(err))
+	})
+
+	t.Run("hard fail accepts good staple", func(t *testing.T) {
+		server := pki.server(t, pki.staple(t, httpx.RevocationStatusGood))
+
+		client := httpx.NewClientWithConfig(
+			httpx.WithClientDefaultBaseURL(server.URL),
+			httpx.WithClientTLSConfig(pki.tlsConfig()),
+			httpx.WithClientRevocationCheck(httpx.RevocationCheckHardFail),
+		)
+
+		resp, err := client.Execute(*httpx.NewRequest(http.MethodGet), map[string]any{})
+
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	})
+
+	t.Run("soft fail rejects revoked certificate", func(t *testing.T) {
+		server := pki.server(t, pki.staple(t, httpx.RevocationStatusRevoked))
+
+		called := false
+		client := httpx.NewClientWithConfig(
+			httpx.WithClientDefaultBaseURL(server.URL),
+			httpx.WithClientTLSConfig(pki.tlsConfig()),
+			httpx.WithClientRevocationCheck(httpx.RevocationCheckSoftFail),
+			httpx.WithClientRevocationSoftFailHandler(func(httpx.RevocationResult) { called = true }),
+		)
+
+		_, err := client.Execute(*httpx.NewRequest(http.MethodGet), map[string]any{})
+
+		require.Error(t, err)
+		assert.True(t, httpx.IsRevocationError(err))
+		assert.False(t, called)
+	})
+
+	t.Run("soft fail reports unknown status", func(t *testing.T) {
+		server := pki.server(t, pki.staple(t, httpx.RevocationStatusUnknown))
+
+		var status httpx.RevocationStatus
+		client := httpx.NewClientWithConfig(
+			httpx.WithClientDefaultBaseURL(server.URL),
+			httpx.WithClientTLSConfig(pki.tlsConfig()),
+			httpx.WithClientRevocationCheck(httpx.RevocationCheckSoftFail),
+			httpx.WithClientRevocationSoftFailHandler(func(result httpx.RevocationResult) { status = result.Status }),
+		)
+
+		_, err := client.Execute(*httpx.NewRequest(http.MethodGet), map[string]any{})
+
+		require.NoError(t, err)
+		assert.Equal(t, httpx.RevocationStatusUnknown, status)
+	})
+
+	t.Run("soft fail reports invalid staple", func(t *testing.T) {
+		server := pki.server(t, []byte{0x30, 0x03, 0x0a, 0x01, 0x00})
+
+		var result httpx.RevocationResult
+		client := httpx.NewClientWithConfig(
+			httpx.WithClientDefaultBaseURL(server.URL),
+			httpx.WithClientTLSConfig(pki.tlsConfig()),
+			httpx.WithClientRevocationCheck(httpx.RevocationCheckSoftFail),
+			httpx.WithClientRevocationSoftFailHandler(func(r httpx.RevocationResult) { result = r }),
+		)
+
+		_, err := client.Execute(*httpx.NewRequest(http.MethodGet), map[string]any{})
+
+		require.NoError(t, err)
+		assert.Equal(t, httpx.RevocationStatusInvalid, result.Status)
+		assert.Error(t, result.Err)
+	})
+
+	t.Run("soft fail reports staples not matching the certificate or not currently valid", func(t *testing.T) {
+		testCases := map[string][]byte{
+			"other issuer": newTestPKI(t).staple(t, httpx.RevocationStatusGood),
+			"other serial number": pki.staple(t, httpx.RevocationStatusGood, func(resp *ocsp.Response) {
+				resp.SerialNumber = big.NewInt(43)
+			}),
+			"future this update": pki.staple(t, httpx.RevocationStatusGood, func(resp *ocsp.Response) {
+				resp.ThisUpdate = time.Now().Add(time.Hour)
+				resp.NextUpdate = time.Now().Add(2 * time.Hour)
+			}),
+			"past next update": pki.staple(t, httpx.RevocationStatusGood, func(resp *ocsp.Response) {
+				resp.ThisUpdate = time.Now().Add(-2 * time.Hour)
+				resp.NextUpdate = time.Now().Add(-time.Hour)
+			}),
+			"responder not authorized for OCSP signing": pki.delegatedStaple(t, x509.ExtKeyUsageServerAuth),
+		}
+		for name, staple := range testCases {
+			t.Run(name, func(t *testing.T) {
+				server := pki.server(t, staple)
+
+				var result httpx.RevocationResult
+				client := httpx.NewClientWithConfig(
+					httpx.WithClientDefaultBaseURL(server.URL),
+					httpx.WithClientTLSConfig(pki.tlsConfig()),
+					httpx.WithClientRevocationCheck(httpx.RevocationCheckSoftFail),
+					httpx.WithClientRevocationSoftFailHandler(func(r httpx.RevocationResult) { result = r }),
+				)
+
+				_, err := client.Execute(*httpx.NewRequest(http.MethodGet), map[string]any{})
+
+				require.NoError(t, err)
+				assert.Equal(t, httpx.RevocationStatusInvalid, result.Status)
+				assert.Error(t, result.Err)
+			})
+		}
+	})
+
+	t.Run("accepts a this update within the clock skew", func(t *testing.T) {
+		server := pki.server(t, pki.staple(t, httpx.RevocationStatusGood, func(resp *ocsp.Response) {
+			resp.ThisUpdate = time.Now().Add(time.Minute)
+		}))
+
+		client := httpx.NewClientWithConfig(
+			httpx.WithClientDefaultBaseURL(server.URL),
+			httpx.WithClientTLSConfig(pki.tlsConfig()),
+			httpx.WithClientRevocationCheck(httpx.RevocationCheckHardFail),
+		)
+
+		_, err := client.Execute(*httpx.NewRequest(http.MethodGet), map[string]any{})
+
+		require.NoError(t, err)
+	})
+
+	t.Run("accepts a staple of a delegated responder", func(t *testing.T) {
+		server := pki.server(t, pki.delegatedStaple(t, x509.ExtKeyUsageOCSPSigning))
+
+		client := httpx.NewClientWithConfig(
+			httpx.WithClientDefaultBaseURL(server.URL),
+			httpx.WithClientTLSConfig(pki.tlsConfig()),
+			httpx.WithClientRevocationCheck(httpx.RevocationCheckHardFail),
+		)
+
+		_, err := client.Execute(*httpx.NewRequest(http.MethodGet), map[string]any{})
+
+		require.NoError(t, err)
+	})
+
+	t.Run("disabled skips checking", func(t *testing.T) {
+		server := pki.server(t, pki.staple(t, httpx.RevocationStatusRevoked))
+
+		client := httpx.NewClientWithConfig(
+			httpx.WithClientDefaultBaseURL(server.URL),
+			httpx.WithClientTLSConfig(pki.tlsConfig()),
+		)
+
+		resp, err := client.Execute(*httpx.NewRequest(http.MethodGet), map[string]any{})
+
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	})
+}
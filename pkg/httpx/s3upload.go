@@ -0,0 +1,245 @@
+package httpx
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// defaultPresignedUploadConcurrency is the number of parts uploaded in parallel by default
+const defaultPresignedUploadConcurrency = 4
+
+// PresignedPart is a part of an S3 multipart upload with its presigned UploadPart URL
+type PresignedPart struct {
+	Number int    // Part number, from 1
+	URL    string // Presigned URL of the UploadPart request
+}
+
+// CompletedPart is a part uploaded by PresignedMultipartUpload, as listed in the CompleteMultipartUpload request
+type CompletedPart struct {
+	PartNumber int    `xml:"PartNumber"`
+	ETag       string `xml:"ETag"`
+}
+
+// PresignedUploadResult is the outcome of PresignedMultipartUpload
+type PresignedUploadResult struct {
+	Parts    []CompletedPart // Uploaded parts ordered by number, to complete the upload when there is no CompleteURL
+	Response *Response       // Response to the CompleteMultipartUpload request, nil without a CompleteURL
+}
+
+// PresignedUploadConfig holds the configuration of PresignedMultipartUpload
+type PresignedUploadConfig struct {
+	PartSize       int64               // Size of every part but the last, at least 5MiB on AWS (required)
+	Concurrency    int                 // Parts uploaded in parallel (default: 4)
+	RateLimiter    RateLimiter         // Limiter every part request waits on, e.g. NewTokenBucketLimiter (default: none)
+	CompleteURL    string              // Presigned CompleteMultipartUpload URL, called once every part is uploaded
+	AbortURL       string              // Presigned AbortMultipartUpload URL, called when the upload fails
+	OnPartUploaded func(CompletedPart) // Optional callback invoked after every uploaded part, from any goroutine
+	Options        []RequestOption     // Options of every request, e.g. annotations
+}
+
+// PresignedUploadOption configures PresignedMultipartUpload
+type PresignedUploadOption func(*PresignedUploadConfig)
+
+// WithPresignedPartSize sets the size of every part but the last
+func WithPresignedPartSize(size int64) PresignedUploadOption {
+	return func(c *PresignedUploadConfig) {
+		c.PartSize = size
+	}
+}
+
+// WithPresignedConcurrency sets the number of parts uploaded in parallel
+func WithPresignedConcurrency(concurrency int) PresignedUploadOption {
+	return func(c *PresignedUploadConfig) {
+		c.Concurrency = concurrency
+	}
+}
+
+// WithPresignedRateLimiter makes every part request wait on limiter, e.g. to leave bandwidth to other traffic
+func WithPresignedRateLimiter(limiter RateLimiter) PresignedUploadOption {
+	return func(c *PresignedUploadConfig) {
+		c.RateLimiter = limiter
+	}
+}
+
+// WithPresignedCompletion sets the presigned CompleteMultipartUpload and AbortMultipartUpload URLs
+// An empty abortURL leaves failed uploads to the bucket lifecycle rules.
+func WithPresignedCompletion(completeURL, abortURL string) PresignedUploadOption {
+	return func(c *PresignedUploadConfig) {
+		c.CompleteURL = completeURL
+		c.AbortURL = abortURL
+	}
+}
+
+// WithPresignedPartUploaded sets a callback invoked after every uploaded part
+func WithPresignedPartUploaded(callback func(CompletedPart)) PresignedUploadOption {
+	return func(c *PresignedUploadConfig) {
+		c.OnPartUploaded = callback
+	}
+}
+
+// WithPresignedOptions adds request options to every part, completion and abort request
+func WithPresignedOptions(opts ...RequestOption) PresignedUploadOption {
+	return func(c *PresignedUploadConfig) {
+		c.Options = append(c.Options, opts...)
+	}
+}
+
+// PresignedMultipartUpload uploads size bytes of content to an S3-compatible endpoint through the presigned
+// UploadPart URLs of parts, one per PartSize bytes, in parallel. The ETag of every part is collected and, with
+// a CompleteURL, the upload is completed, which S3 may report as failed in a 200 response: such responses
+// fail with an error. Part requests go through the client middlewares, so they are retried, traced and
+// measured like any other request; a part failing for good cancels the others and aborts the upload.
+// The parts are buffered in memory, Concurrency times PartSize bytes at most, so retries can resend them.
+func PresignedMultipartUpload(ctx context.Context, client *Client, content io.ReaderAt, size int64, parts []PresignedPart, opts ...PresignedUploadOption) (*PresignedUploadResult, error) {
+	config := PresignedUploadConfig{Concurrency: defaultPresignedUploadConcurrency}
+	for _, opt := range opts {
+		opt(&config)
+	}
+	if config.PartSize <= 0 {
+		return nil, ValidationError("presigned multipart upload requires a part size", nil)
+	}
+	if expected := max((size+config.PartSize-1)/config.PartSize, 1); int64(len(parts)) != expected {
+		return nil, ValidationError("presigned multipart upload of this size requires one URL per part", errors.Errorf("%d parts of %d bytes, %d URLs", expected, config.PartSize, len(parts)))
+	}
+	parts = slices.SortedFunc(slices.Values(parts), func(a, b PresignedPart) int { return a.Number - b.Number })
+
+	upload := &presignedUpload{client: client, config: config, content: content, size: size}
+	group := Group(ctx, client, WithGroupLimit(config.Concurrency))
+	for i, part := range parts {
+		group.GoFunc(func(ctx context.Context) error {
+			return upload.uploadPart(ctx, part, int64(i)*config.PartSize)
+		})
+	}
+	if err := group.Wait(); err != nil {
+		upload.abort(ctx)
+		return nil, err
+	}
+
+	result := &PresignedUploadResult{Parts: upload.completed()}
+	if config.CompleteURL == "" {
+		return result, nil
+	}
+	var err error
+	if result.Response, err = upload.complete(ctx, result.Parts); err != nil {
+		upload.abort(ctx)
+	}
+	return result, err
+}
+
+// presignedUpload is the state of an upload run by PresignedMultipartUpload
+type presignedUpload struct {
+	client  *Client
+	config  PresignedUploadConfig
+	content io.ReaderAt
+	size    int64
+
+	mu    sync.Mutex
+	parts []CompletedPart
+}
+
+// uploadPart uploads the part starting at offset and records its ETag
+func (u *presignedUpload) uploadPart(ctx context.Context, part PresignedPart, offset int64) error {
+	chunk := make([]byte, min(u.config.PartSize, u.size-offset))
+	if _, err := u.content.ReadAt(chunk, offset); err != nil && !errors.Is(err, io.EOF) {
+		return errors.Wrapf(err, "failed to read part %d", part.Number)
+	}
+	if u.config.RateLimiter != nil {
+		if err := u.config.RateLimiter.Allow(ctx); err != nil {
+			return err
+		}
+	}
+
+	resp, err := u.send(ctx, http.MethodPut, part.URL, "s3.UploadPart", WithBody(bytes.NewReader(chunk)))
+	if err != nil {
+		return err
+	}
+	etag := resp.Header().Get("ETag")
+	if etag == "" {
+		return errors.Errorf("part %d uploaded without an ETag header, is it exposed by the bucket CORS rules?", part.Number)
+	}
+
+	completed := CompletedPart{PartNumber: part.Number, ETag: etag}
+	u.mu.Lock()
+	u.parts = append(u.parts, completed)
+	u.mu.Unlock()
+	if u.config.OnPartUploaded != nil {
+		u.config.OnPartUploaded(completed)
+	}
+	return nil
+}
+
+// completed returns the uploaded parts ordered by number
+func (u *presignedUpload) completed() []CompletedPart {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return slices.SortedFunc(slices.Values(u.parts), func(a, b CompletedPart) int { return a.PartNumber - b.PartNumber })
+}
+
+// completeMultipartUpload is the body of a CompleteMultipartUpload request
+type completeMultipartUpload struct {
+	XMLName xml.Name        `xml:"CompleteMultipartUpload"`
+	Parts   []CompletedPart `xml:"Part"`
+}
+
+// s3Error is the error body of S3 responses
+type s3Error struct {
+	XMLName xml.Name `xml:"Error"`
+	Code    string   `xml:"Code"`
+	Message string   `xml:"Message"`
+}
+
+// complete sends the CompleteMultipartUpload request listing parts
+func (u *presignedUpload) complete(ctx context.Context, parts []CompletedPart) (*Response, error) {
+	body, err := xml.Marshal(completeMultipartUpload{Parts: parts})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to marshal CompleteMultipartUpload body")
+	}
+	resp, err := u.send(ctx, http.MethodPost, u.config.CompleteURL, "s3.CompleteMultipartUpload",
+		WithBody(bytes.NewReader(body)), WithHeader("Content-Type", "application/xml"))
+	if err != nil {
+		return resp, err
+	}
+	// S3 reports failures detected after it started answering as an error body in a 200 response
+	var failure s3Error
+	if xml.Unmarshal(resp.RawBody, &failure) == nil {
+		return resp, errors.Errorf("failed to complete multipart upload: %s: %s", failure.Code, failure.Message)
+	}
+	return resp, nil
+}
+
+// abort sends the AbortMultipartUpload request so the parts uploaded do not linger, ignoring failures
+func (u *presignedUpload) abort(ctx context.Context) {
+	if u.config.AbortURL == "" {
+		return
+	}
+	_, _ = u.send(context.WithoutCancel(ctx), http.MethodDelete, u.config.AbortURL, "s3.AbortMultipartUpload")
+}
+
+// send sends a request to a presigned URL, failing on error responses
+func (u *presignedUpload) send(ctx context.Context, method, presignedURL, operation string, opts ...RequestOption) (*Response, error) {
+	target, err := url.Parse(presignedURL)
+	if err != nil {
+		return nil, ValidationError("invalid presigned URL", err)
+	}
+	query := target.Query()
+	target.RawQuery, target.Fragment = "", ""
+
+	options := []RequestOption{WithBaseURL(target.String()), WithQueryParams(query), WithContext(ctx), WithOperation(operation)}
+	options = append(append(options, opts...), u.config.Options...)
+	resp, err := u.client.Execute(*NewRequest(method, options...), "")
+	if err != nil {
+		return resp, err
+	}
+	if resp.StatusCode >= 400 {
+		return resp, ClassifyError(nil, resp.httpResponse.Request, resp.httpResponse)
+	}
+	return resp, nil
+}
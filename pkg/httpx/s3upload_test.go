@@ -0,0 +1,195 @@
+package httpx_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bdpiprava/easy-http/pkg/httpx"
+)
+
+// fakeS3 is an in-memory S3 endpoint serving the presigned requests of a multipart upload
+type fakeS3 struct {
+	*httptest.Server
+	mu           sync.Mutex
+	parts        map[int][]byte
+	object       []byte
+	aborted      bool
+	failPart     int  // Part rejected with 403 Forbidden
+	failComplete bool // Whether completion fails in a 200 response
+	inFlight     atomic.Int32
+	maxInFlight  atomic.Int32
+}
+
+func newFakeS3(t *testing.T) *fakeS3 {
+	s := &fakeS3{parts: make(map[int][]byte)}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/bucket/video.mp4", r.URL.Path)
+		assert.Equal(t, "upload-1", r.URL.Query().Get("uploadId"))
+		assert.NotEmpty(t, r.URL.Query().Get("X-Amz-Signature"))
+		switch r.Method {
+		case http.MethodPut:
+			s.uploadPart(w, r)
+		case http.MethodPost:
+			s.complete(t, w, r)
+		case http.MethodDelete:
+			s.mu.Lock()
+			s.aborted = true
+			s.mu.Unlock()
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *fakeS3) uploadPart(w http.ResponseWriter, r *http.Request) {
+	if n := s.inFlight.Add(1); n > s.maxInFlight.Load() {
+		s.maxInFlight.Store(n)
+	}
+	defer s.inFlight.Add(-1)
+	number, _ := strconv.Atoi(r.URL.Query().Get("partNumber"))
+	if number == s.failPart {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	body, _ := io.ReadAll(r.Body)
+	s.mu.Lock()
+	s.parts[number] = body
+	s.mu.Unlock()
+	w.Header().Set("ETag", etag(body))
+}
+
+func (s *fakeS3) complete(t *testing.T, w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Parts []httpx.CompletedPart `xml:"Part"`
+	}
+	require.NoError(t, xml.NewDecoder(r.Body).Decode(&request))
+	if s.failComplete {
+		_, _ = w.Write([]byte(`<Error><Code>InternalError</Code><Message>We encountered an internal error.</Message></Error>`))
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, part := range request.Parts {
+		assert.Equal(t, i+1, part.PartNumber)
+		assert.Equal(t, etag(s.parts[part.PartNumber]), part.ETag)
+		s.object = append(s.object, s.parts[part.PartNumber]...)
+	}
+	_, _ = w.Write([]byte(`<CompleteMultipartUploadResult><Key>video.mp4</Key></CompleteMultipartUploadResult>`))
+}
+
+func (s *fakeS3) presign(query string) string {
+	return s.URL + "/bucket/video.mp4?uploadId=upload-1&X-Amz-Signature=abc" + query
+}
+
+func (s *fakeS3) presignParts(count int) []httpx.PresignedPart {
+	parts := make([]httpx.PresignedPart, count)
+	for i := range parts {
+		parts[i] = httpx.PresignedPart{Number: count - i, URL: s.presign(fmt.Sprintf("&partNumber=%d", count-i))}
+	}
+	return parts
+}
+
+func etag(body []byte) string {
+	return fmt.Sprintf(`"%x"`, md5.Sum(body))
+}
+
+// countingLimiter counts the requests it allows
+type countingLimiter struct {
+	httpx.RateLimiter
+	allowed atomic.Int32
+}
+
+func (l *countingLimiter) Allow(context.Context) error {
+	l.allowed.Add(1)
+	return nil
+}
+
+func TestPresignedMultipartUpload(t *testing.T) {
+	content := bytes.Repeat([]byte("abcdefghij"), 1000) // 10000 bytes
+	client := httpx.NewClientWithConfig()
+
+	t.Run("should upload parts in parallel and complete the upload", func(t *testing.T) {
+		s3 := newFakeS3(t)
+		limiter := &countingLimiter{}
+		var uploaded atomic.Int32
+
+		result, err := httpx.PresignedMultipartUpload(context.Background(), client, bytes.NewReader(content), int64(len(content)), s3.presignParts(4),
+			httpx.WithPresignedPartSize(3000),
+			httpx.WithPresignedConcurrency(2),
+			httpx.WithPresignedRateLimiter(limiter),
+			httpx.WithPresignedCompletion(s3.presign(""), s3.presign("")),
+			httpx.WithPresignedPartUploaded(func(httpx.CompletedPart) { uploaded.Add(1) }),
+		)
+
+		require.NoError(t, err)
+		assert.Equal(t, content, s3.object)
+		require.Len(t, result.Parts, 4)
+		assert.Equal(t, httpx.CompletedPart{PartNumber: 4, ETag: etag(content[9000:])}, result.Parts[3])
+		assert.Contains(t, string(result.Response.RawBody), "<Key>video.mp4</Key>")
+		assert.LessOrEqual(t, s3.maxInFlight.Load(), int32(2))
+		assert.Equal(t, int32(4), limiter.allowed.Load())
+		assert.Equal(t, int32(4), uploaded.Load())
+		assert.False(t, s3.aborted)
+	})
+
+	t.Run("should return the parts without a completion URL", func(t *testing.T) {
+		s3 := newFakeS3(t)
+
+		result, err := httpx.PresignedMultipartUpload(context.Background(), client, bytes.NewReader(content), int64(len(content)), s3.presignParts(2),
+			httpx.WithPresignedPartSize(5000))
+
+		require.NoError(t, err)
+		assert.Len(t, result.Parts, 2)
+		assert.Nil(t, result.Response)
+		assert.Empty(t, s3.object)
+	})
+
+	t.Run("should abort the upload when a part fails", func(t *testing.T) {
+		s3 := newFakeS3(t)
+		s3.failPart = 2
+
+		_, err := httpx.PresignedMultipartUpload(context.Background(), client, bytes.NewReader(content), int64(len(content)), s3.presignParts(4),
+			httpx.WithPresignedPartSize(3000),
+			httpx.WithPresignedCompletion(s3.presign(""), s3.presign("")))
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "403")
+		assert.True(t, s3.aborted)
+		assert.Empty(t, s3.object)
+	})
+
+	t.Run("should fail when completion reports an error in a 200 response", func(t *testing.T) {
+		s3 := newFakeS3(t)
+		s3.failComplete = true
+
+		_, err := httpx.PresignedMultipartUpload(context.Background(), client, bytes.NewReader(content), int64(len(content)), s3.presignParts(1),
+			httpx.WithPresignedPartSize(10000),
+			httpx.WithPresignedCompletion(s3.presign(""), s3.presign("")))
+
+		assert.ErrorContains(t, err, "InternalError: We encountered an internal error.")
+		assert.True(t, s3.aborted)
+	})
+
+	t.Run("should require one URL per part", func(t *testing.T) {
+		s3 := newFakeS3(t)
+
+		_, err := httpx.PresignedMultipartUpload(context.Background(), client, bytes.NewReader(content), int64(len(content)), s3.presignParts(3),
+			httpx.WithPresignedPartSize(3000))
+
+		assert.ErrorContains(t, err, "requires one URL per part")
+	})
+}
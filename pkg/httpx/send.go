@@ -0,0 +1,210 @@
+package httpx
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// defaultDeliveryHistory is the number of completed deliveries whose status is kept unless
+// WithClientDeliveryHistory is used
+const defaultDeliveryHistory = 1000
+
+// MessageID identifies a request sent with Client.Send
+type MessageID string
+
+// DeliveryState is the state of a request sent with Client.Send
+type DeliveryState string
+
+const (
+	DeliveryPending   DeliveryState = "pending"   // The request is in flight or waiting to be retried
+	DeliveryDelivered DeliveryState = "delivered" // The request received a response other than a 4xx or 5xx one
+	DeliveryFailed    DeliveryState = "failed"    // The request failed or received a 4xx or 5xx response once retries were exhausted
+)
+
+// DeliveryStatus is the status of a request sent with Client.Send
+type DeliveryStatus struct {
+	ID          MessageID
+	State       DeliveryState
+	StatusCode  int       // Status code of the final response, zero if none was received
+	Err         error     // Failure of the request, a *HTTPError for 4xx and 5xx responses
+	SentAt      time.Time // When Send was called
+	CompletedAt time.Time // When the request was delivered or failed, zero while pending
+}
+
+// SendOption configures Client.Send
+type SendOption func(*sendConfig)
+
+// sendConfig holds the settings of a Send call
+type sendConfig struct {
+	callback func(DeliveryStatus)
+}
+
+// WithSendCallback calls fn with the final status of the request once it is delivered or failed
+// It runs on the goroutine that sent the request, so it should not block.
+func WithSendCallback(fn func(DeliveryStatus)) SendOption {
+	return func(c *sendConfig) {
+		c.callback = fn
+	}
+}
+
+// Send sends the request in the background, e.g. a notification whose response the caller does not wait for,
+// and returns the ID its delivery can be queried with, see Client.DeliveryStatus
+//
+// The request goes through the whole middleware chain, so it is retried by the retry policy of the client. It
+// keeps the values of the context given with WithContext but not its cancellation, as the caller typically
+// returns before the request completes; it is cancelled once the base context of the client is done instead,
+// see WithClientBaseContext. Invalid requests fail at once with a validation error.
+func (c Client) Send(req Request, opts ...SendOption) (MessageID, error) {
+	var config sendConfig
+	for _, opt := range opts {
+		opt(&config)
+	}
+	requestOpts := c.requestOptions(&req)
+	if requestOpts.Error != nil {
+		return "", requestOpts.Error
+	}
+	if base := c.config.BaseContext; base != nil && base.Err() != nil {
+		return "", ValidationError("client base context is done", base.Err())
+	}
+
+	ctx := requestOpts.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	ctx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	if base := c.config.BaseContext; base != nil {
+		stop, cancelRequest := context.AfterFunc(base, cancel), cancel
+		cancel = func() {
+			stop()
+			cancelRequest()
+		}
+	}
+	requestOpts.Context = ctx
+
+	status := c.deliveries.add()
+	go func() {
+		defer cancel()
+		exchange, err := sendWithMiddleware(&c, requestOpts)
+		if err == nil {
+			status.StatusCode = exchange.resp.StatusCode
+			drainAndClose(exchange.resp.Body, defaultDrainLimit)
+			c.slowRequests.report(exchange.ctx, exchange.req, exchange.stopwatch, status.StatusCode, nil)
+			if httpErr := ClassifyError(nil, exchange.req, exchange.resp); httpErr != nil {
+				err = httpErr
+			}
+		}
+		c.metrics.completed(err)
+
+		status.State, status.Err, status.CompletedAt = DeliveryDelivered, err, time.Now()
+		if err != nil {
+			status.State = DeliveryFailed
+		}
+		c.deliveries.complete(status)
+		if config.callback != nil {
+			config.callback(status)
+		}
+		c.deliveries.done()
+	}()
+	return status.ID, nil
+}
+
+// DeliveryStatus returns the status of a request sent with Send, false if its ID is unknown, e.g. because it
+// completed before the most recent deliveries kept, see WithClientDeliveryHistory
+func (c Client) DeliveryStatus(id MessageID) (DeliveryStatus, bool) {
+	return c.deliveries.status(id)
+}
+
+// WaitDeliveries waits until the requests sent with Send so far are delivered or failed and their callbacks
+// returned, e.g. before the process exits, returning the error of ctx if it is done first
+func (c Client) WaitDeliveries(ctx context.Context) error {
+	return c.deliveries.wait(ctx)
+}
+
+// deliveryTracker holds the status of pending deliveries and of the most recently completed ones
+type deliveryTracker struct {
+	mu        sync.Mutex
+	statuses  map[MessageID]*list.Element
+	completed *list.List // Completed deliveries, the most recent at the front
+	history   int
+	pending   int
+	idle      chan struct{} // Closed once no delivery is pending, nil while none is
+}
+
+// newDeliveryTracker creates the tracker of the deliveries of a client
+func newDeliveryTracker(config ClientConfig) *deliveryTracker {
+	history := config.DeliveryHistory
+	if history <= 0 {
+		history = defaultDeliveryHistory
+	}
+	return &deliveryTracker{
+		statuses:  make(map[MessageID]*list.Element),
+		completed: list.New(),
+		history:   history,
+	}
+}
+
+// add records a new pending delivery
+func (t *deliveryTracker) add() DeliveryStatus {
+	status := DeliveryStatus{ID: MessageID(uuid.NewString()), State: DeliveryPending, SentAt: time.Now()}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.statuses[status.ID] = &list.Element{Value: status}
+	if t.pending == 0 {
+		t.idle = make(chan struct{})
+	}
+	t.pending++
+	return status
+}
+
+// complete records the final status of a delivery, forgetting the oldest completed ones past the history
+func (t *deliveryTracker) complete(status DeliveryStatus) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.statuses[status.ID] = t.completed.PushFront(status)
+	for t.completed.Len() > t.history {
+		oldest := t.completed.Remove(t.completed.Back()).(DeliveryStatus)
+		delete(t.statuses, oldest.ID)
+	}
+}
+
+// done records that a delivery and its callback completed
+func (t *deliveryTracker) done() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.pending--
+	if t.pending == 0 {
+		close(t.idle)
+		t.idle = nil
+	}
+}
+
+// status returns the status of the delivery of id
+func (t *deliveryTracker) status(id MessageID) (DeliveryStatus, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	element, ok := t.statuses[id]
+	if !ok {
+		return DeliveryStatus{}, false
+	}
+	return element.Value.(DeliveryStatus), true
+}
+
+// wait waits until no delivery is pending
+func (t *deliveryTracker) wait(ctx context.Context) error {
+	t.mu.Lock()
+	idle := t.idle
+	t.mu.Unlock()
+	if idle == nil {
+		return nil
+	}
+	select {
+	case <-idle:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
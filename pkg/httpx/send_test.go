@@ -0,0 +1,128 @@
+package httpx_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bdpiprava/easy-http/pkg/httpx"
+)
+
+func TestSend(t *testing.T) {
+	t.Parallel()
+
+	var attempts atomic.Int32
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/flaky":
+			if attempts.Add(1) == 1 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+			}
+		case "/rejected":
+			w.WriteHeader(http.StatusBadRequest)
+		case "/slow":
+			<-release
+		}
+	}))
+	defer server.Close()
+	newClient := func(opts ...httpx.ClientConfigOption) *httpx.Client {
+		return httpx.NewClientWithConfig(append([]httpx.ClientConfigOption{
+			httpx.WithClientDefaultBaseURL(server.URL),
+			httpx.WithClientRetryPolicy(httpx.RetryPolicy{MaxAttempts: 2, BaseDelay: time.Millisecond}),
+		}, opts...)...)
+	}
+	wait := func(t *testing.T, client *httpx.Client) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		require.NoError(t, client.WaitDeliveries(ctx))
+	}
+
+	t.Run("delivers in the background with retries", func(t *testing.T) {
+		client := newClient()
+		completed := make(chan httpx.DeliveryStatus, 1)
+		ctx, cancel := context.WithCancel(context.Background())
+
+		id, err := client.Send(*httpx.NewRequest(http.MethodPost, httpx.WithPath("/flaky"), httpx.WithContext(ctx)),
+			httpx.WithSendCallback(func(status httpx.DeliveryStatus) { completed <- status }))
+		cancel() // The caller returning does not cancel the delivery
+		require.NoError(t, err)
+
+		status := <-completed
+		assert.Equal(t, id, status.ID)
+		assert.Equal(t, httpx.DeliveryDelivered, status.State)
+		assert.Equal(t, http.StatusOK, status.StatusCode)
+		assert.NoError(t, status.Err)
+		assert.Equal(t, int32(2), attempts.Load())
+		wait(t, client)
+		queried, ok := client.DeliveryStatus(id)
+		require.True(t, ok)
+		assert.Equal(t, status, queried)
+	})
+
+	t.Run("reports failed deliveries", func(t *testing.T) {
+		client := newClient()
+
+		id, err := client.Send(*httpx.NewRequest(http.MethodPost, httpx.WithPath("/rejected")))
+		require.NoError(t, err)
+		wait(t, client)
+
+		status, ok := client.DeliveryStatus(id)
+		require.True(t, ok)
+		assert.Equal(t, httpx.DeliveryFailed, status.State)
+		assert.Equal(t, http.StatusBadRequest, status.StatusCode)
+		assert.True(t, httpx.IsClientError(status.Err))
+	})
+
+	t.Run("reports pending deliveries", func(t *testing.T) {
+		client := newClient()
+
+		id, err := client.Send(*httpx.NewRequest(http.MethodGet, httpx.WithPath("/slow")))
+		require.NoError(t, err)
+
+		status, ok := client.DeliveryStatus(id)
+		require.True(t, ok)
+		assert.Equal(t, httpx.DeliveryPending, status.State)
+		assert.True(t, status.CompletedAt.IsZero())
+		close(release)
+		wait(t, client)
+	})
+
+	t.Run("forgets the oldest deliveries past the history", func(t *testing.T) {
+		client := newClient(httpx.WithClientDeliveryHistory(1))
+
+		first, err := client.Send(*httpx.NewRequest(http.MethodGet, httpx.WithPath("/ok")))
+		require.NoError(t, err)
+		wait(t, client)
+		second, err := client.Send(*httpx.NewRequest(http.MethodGet, httpx.WithPath("/ok")))
+		require.NoError(t, err)
+		wait(t, client)
+
+		_, ok := client.DeliveryStatus(first)
+		assert.False(t, ok)
+		_, ok = client.DeliveryStatus(second)
+		assert.True(t, ok)
+	})
+
+	t.Run("rejects invalid requests at once", func(t *testing.T) {
+		id, err := newClient().Send(*httpx.NewRequest("BAD METHOD"))
+
+		require.Error(t, err)
+		assert.Empty(t, id)
+	})
+
+	t.Run("rejects requests once the client is shut down", func(t *testing.T) {
+		base, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		_, err := newClient(httpx.WithClientBaseContext(base)).Send(*httpx.NewRequest(http.MethodGet))
+
+		require.Error(t, err)
+	})
+}
@@ -0,0 +1,120 @@
+package httpx
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/asn1"
+	"math/big"
+
+	"github.com/pkg/errors"
+)
+
+// SigningKey is a private key used to sign outbound requests
+type SigningKey struct {
+	KeyID  string        // Key identifier advertised to the server (e.g. the "kid" published in the JWKS)
+	Signer crypto.Signer // Private key (*rsa.PrivateKey, *ecdsa.PrivateKey, ed25519.PrivateKey or an HSM/KMS backed signer)
+}
+
+// SigningKeySource supplies the current signing key, allowing keys to be rotated or loaded from a KMS
+type SigningKeySource interface {
+	SigningKey(ctx context.Context) (SigningKey, error)
+}
+
+// SigningKeySourceFunc adapts a function to the SigningKeySource interface
+type SigningKeySourceFunc func(ctx context.Context) (SigningKey, error)
+
+// SigningKey implements SigningKeySource
+func (f SigningKeySourceFunc) SigningKey(ctx context.Context) (SigningKey, error) {
+	return f(ctx)
+}
+
+// StaticSigningKey is a SigningKeySource that always returns the same key
+type StaticSigningKey SigningKey
+
+// SigningKey implements SigningKeySource
+func (k StaticSigningKey) SigningKey(_ context.Context) (SigningKey, error) {
+	if k.Signer == nil {
+		return SigningKey{}, errors.New("signing key cannot be nil")
+	}
+	return SigningKey(k), nil
+}
+
+// jwsAlgorithmFor returns the default JWS algorithm for a signing key
+func jwsAlgorithmFor(signer crypto.Signer) (string, error) {
+	switch key := signer.Public().(type) {
+	case *rsa.PublicKey:
+		return "RS256", nil
+	case *ecdsa.PublicKey:
+		switch key.Curve.Params().BitSize {
+		case 256:
+			return "ES256", nil
+		case 384:
+			return "ES384", nil
+		case 521:
+			return "ES512", nil
+		}
+		return "", errors.Errorf("unsupported EC curve: %s", key.Curve.Params().Name)
+	case ed25519.PublicKey:
+		return "EdDSA", nil
+	default:
+		return "", errors.Errorf("unsupported signing key type: %T", key)
+	}
+}
+
+// signJWS signs the input with the given JWS algorithm, returning the JWS encoded signature
+func signJWS(alg string, signer crypto.Signer, signingInput []byte) ([]byte, error) {
+	if alg == "EdDSA" {
+		return signer.Sign(rand.Reader, signingInput, crypto.Hash(0))
+	}
+	if len(alg) != 5 {
+		return nil, errors.Errorf("unsupported algorithm: %s", alg)
+	}
+
+	hash, err := jwsHash(alg)
+	if err != nil {
+		return nil, err
+	}
+	hasher := hash.New()
+	hasher.Write(signingInput)
+	digest := hasher.Sum(nil)
+
+	switch alg[:2] {
+	case "RS":
+		return signer.Sign(rand.Reader, digest, hash)
+	case "PS":
+		return signer.Sign(rand.Reader, digest, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash, Hash: hash})
+	case "ES":
+		publicKey, ok := signer.Public().(*ecdsa.PublicKey)
+		if !ok {
+			return nil, errors.New("key type does not match algorithm")
+		}
+		if publicKey.Curve != jwsCurve(alg) {
+			return nil, errors.New("key curve does not match algorithm")
+		}
+		der, err := signer.Sign(rand.Reader, digest, hash)
+		if err != nil {
+			return nil, err
+		}
+		return ecdsaRawSignature(der, (publicKey.Curve.Params().BitSize+7)/8)
+	default:
+		return nil, errors.Errorf("unsupported algorithm: %s", alg)
+	}
+}
+
+// ecdsaRawSignature converts an ASN.1 DER ECDSA signature into the fixed-size R || S form
+func ecdsaRawSignature(der []byte, size int) ([]byte, error) {
+	var sig struct {
+		R, S *big.Int
+	}
+	if _, err := asn1.Unmarshal(der, &sig); err != nil {
+		return nil, errors.Wrap(err, "invalid ECDSA signature")
+	}
+	raw := make([]byte, 2*size)
+	sig.R.FillBytes(raw[:size])
+	sig.S.FillBytes(raw[size:])
+	return raw, nil
+}
@@ -0,0 +1,205 @@
+package httpx
+
+import (
+	"context"
+	"crypto/tls"
+	"log/slog"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// RequestTiming is the timing breakdown of a single client call
+//
+// Phase durations are summed across attempts when net/http or a retry middleware sends the request
+// more than once; phases that did not happen (e.g. DNS on a reused connection) are zero.
+type RequestTiming struct {
+	DNS             time.Duration // Time spent resolving the host
+	Connect         time.Duration // Time spent establishing TCP connections
+	TLSHandshake    time.Duration // Time spent in TLS handshakes
+	TimeToFirstByte time.Duration // Time from the request being written to the first response byte of the last attempt
+	BodyRead        time.Duration // Time from the response headers to the body being read (zero for streaming responses)
+	Total           time.Duration // Wall time of the whole call, including middlewares and retries
+	Attempts        int           // Number of connections requested, i.e. attempts that reached the transport
+	ConnReused      bool          // Whether the last attempt used a pooled keep-alive connection
+}
+
+// slowRequestReporter logs and counts calls exceeding the configured threshold
+type slowRequestReporter struct {
+	threshold time.Duration
+	logger    *slog.Logger
+	count     atomic.Uint64
+}
+
+// newSlowRequestReporter creates a reporter, returning nil when slow request logging is disabled
+func newSlowRequestReporter(config ClientConfig) *slowRequestReporter {
+	if config.SlowRequestThreshold <= 0 {
+		return nil
+	}
+	logger := config.Logger
+	if logger == nil {
+		logger = mapLogFields(slog.Default(), config.LogFieldMapper)
+	}
+	return &slowRequestReporter{threshold: config.SlowRequestThreshold, logger: logger}
+}
+
+// start begins timing the request, returning the request carrying the timing trace
+// A nil reporter returns the request unchanged and a nil stopwatch
+func (r *slowRequestReporter) start(req *http.Request) (*http.Request, *requestStopwatch) {
+	if r == nil {
+		return req, nil
+	}
+	stopwatch := &requestStopwatch{start: time.Now()}
+	return req.WithContext(httptrace.WithClientTrace(req.Context(), stopwatch.trace())), stopwatch
+}
+
+// report logs the call at Warn if it exceeded the threshold
+// Logging is independent of the client log level so slow calls surface without enabling debug logs
+func (r *slowRequestReporter) report(ctx context.Context, req *http.Request, stopwatch *requestStopwatch, statusCode int, err error) {
+	if r == nil || stopwatch == nil {
+		return
+	}
+	timing := stopwatch.stop()
+	if timing.Total < r.threshold {
+		return
+	}
+	r.count.Add(1)
+
+	attrs := []slog.Attr{
+		slog.String(LogFieldMethod, req.Method),
+		slog.String(LogFieldURL, req.URL.Redacted()),
+		durationAttr(LogFieldSlowThreshold, r.threshold),
+		durationAttr(LogFieldDuration, timing.Total),
+		durationAttr(LogFieldDNS, timing.DNS),
+		durationAttr(LogFieldConnect, timing.Connect),
+		durationAttr(LogFieldTLSHandshake, timing.TLSHandshake),
+		durationAttr(LogFieldFirstByte, timing.TimeToFirstByte),
+		durationAttr(LogFieldBodyRead, timing.BodyRead),
+		slog.Int(LogFieldRetryAttempts, timing.Attempts),
+		slog.Bool(LogFieldConnReused, timing.ConnReused),
+	}
+	if statusCode != 0 {
+		attrs = append(attrs, slog.Int(LogFieldStatus, statusCode))
+	}
+	if err != nil {
+		attrs = append(attrs, slog.String(LogFieldError, err.Error()))
+	}
+	if attr, ok := annotationLogAttr(AnnotationsFromContext(ctx)); ok {
+		attrs = append(attrs, attr)
+	}
+	loggerFromContext(ctx, r.logger).LogAttrs(ctx, slog.LevelWarn, "Slow HTTP request", attrs...)
+}
+
+// slowRequests returns the number of calls that exceeded the threshold
+func (r *slowRequestReporter) slowRequests() uint64 {
+	if r == nil {
+		return 0
+	}
+	return r.count.Load()
+}
+
+// requestStopwatch records the timing of a call from httptrace events
+type requestStopwatch struct {
+	start time.Time
+
+	mu           sync.Mutex
+	dnsStart     time.Time
+	connectStart map[string]time.Time
+	tlsStart     time.Time
+	wroteRequest time.Time
+	headers      time.Time
+	timing       RequestTiming
+}
+
+// trace returns the client trace feeding the stopwatch
+func (s *requestStopwatch) trace() *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		GetConn: func(string) {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			s.timing.Attempts++
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			s.timing.ConnReused = info.Reused
+		},
+		DNSStart: func(httptrace.DNSStartInfo) {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			s.dnsStart = time.Now()
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			if !s.dnsStart.IsZero() {
+				s.timing.DNS += time.Since(s.dnsStart)
+			}
+		},
+		ConnectStart: func(network, addr string) {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			// Dial attempts may race (e.g. IPv4 and IPv6), so track them per address
+			if s.connectStart == nil {
+				s.connectStart = make(map[string]time.Time)
+			}
+			s.connectStart[network+addr] = time.Now()
+		},
+		ConnectDone: func(network, addr string, _ error) {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			if started, ok := s.connectStart[network+addr]; ok {
+				s.timing.Connect += time.Since(started)
+				delete(s.connectStart, network+addr)
+			}
+		},
+		TLSHandshakeStart: func() {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			s.tlsStart = time.Now()
+		},
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			if !s.tlsStart.IsZero() {
+				s.timing.TLSHandshake += time.Since(s.tlsStart)
+			}
+		},
+		WroteRequest: func(httptrace.WroteRequestInfo) {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			s.wroteRequest = time.Now()
+		},
+		GotFirstResponseByte: func() {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			if !s.wroteRequest.IsZero() {
+				s.timing.TimeToFirstByte = time.Since(s.wroteRequest)
+			}
+		},
+	}
+}
+
+// headersReceived marks the point the middleware chain returned the response headers
+func (s *requestStopwatch) headersReceived() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.headers = time.Now()
+}
+
+// stop finalises the timing of the call
+func (s *requestStopwatch) stop() RequestTiming {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	s.timing.Total = now.Sub(s.start)
+	if !s.headers.IsZero() {
+		s.timing.BodyRead = now.Sub(s.headers)
+	}
+	return s.timing
+}
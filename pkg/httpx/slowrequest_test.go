@@ -0,0 +1,119 @@
+package httpx_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bdpiprava/easy-http/pkg/httpx"
+)
+
+func TestSlowRequestThreshold(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			time.Sleep(50 * time.Millisecond)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"ok":true}`))
+	}))
+	defer server.Close()
+
+	newClient := func(buf *bytes.Buffer, level slog.Level) *httpx.Client {
+		logger := slog.New(slog.NewJSONHandler(buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+		return httpx.NewClientWithConfig(
+			httpx.WithClientDefaultBaseURL(server.URL),
+			httpx.WithClientLogger(logger),
+			httpx.WithClientLogLevel(level),
+			httpx.WithClientSlowRequestThreshold(20*time.Millisecond),
+		)
+	}
+
+	t.Run("logs slow calls at warn with timing breakdown", func(t *testing.T) {
+		var buf bytes.Buffer
+		client := newClient(&buf, slog.LevelError)
+
+		_, err := client.Execute(*httpx.NewRequest(http.MethodGet, httpx.WithPath("/slow")), map[string]any{})
+		require.NoError(t, err)
+
+		record := findLogRecord(t, &buf, "Slow HTTP request")
+		require.NotNil(t, record)
+		assert.Equal(t, "WARN", record["level"])
+		assert.Equal(t, http.MethodGet, record[httpx.LogFieldMethod])
+		assert.Equal(t, server.URL+"/slow", record[httpx.LogFieldURL])
+		assert.InDelta(t, 200, record[httpx.LogFieldStatus], 0)
+		assert.InDelta(t, 1, record[httpx.LogFieldRetryAttempts], 0)
+		assert.GreaterOrEqual(t, record[httpx.LogFieldDuration], float64(50))
+		assert.GreaterOrEqual(t, record[httpx.LogFieldFirstByte], float64(50))
+		for _, key := range []string{
+			httpx.LogFieldSlowThreshold, httpx.LogFieldDNS, httpx.LogFieldConnect, httpx.LogFieldTLSHandshake,
+			httpx.LogFieldBodyRead, httpx.LogFieldConnReused,
+		} {
+			assert.Contains(t, record, key)
+		}
+		assert.Equal(t, uint64(1), client.SlowRequests())
+	})
+
+	t.Run("does not log fast calls", func(t *testing.T) {
+		var buf bytes.Buffer
+		client := newClient(&buf, slog.LevelError)
+
+		_, err := client.Execute(*httpx.NewRequest(http.MethodGet, httpx.WithPath("/fast")), map[string]any{})
+		require.NoError(t, err)
+
+		assert.Nil(t, findLogRecord(t, &buf, "Slow HTTP request"))
+		assert.Equal(t, uint64(0), client.SlowRequests())
+	})
+
+	t.Run("logs slow failures", func(t *testing.T) {
+		var buf bytes.Buffer
+		client := newClient(&buf, slog.LevelError)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+		defer cancel()
+
+		_, err := client.Execute(*httpx.NewRequest(http.MethodGet,
+			httpx.WithPath("/slow"),
+			httpx.WithContext(ctx),
+		), map[string]any{})
+		require.Error(t, err)
+
+		record := findLogRecord(t, &buf, "Slow HTTP request")
+		require.NotNil(t, record)
+		assert.Contains(t, record, httpx.LogFieldError)
+		assert.NotContains(t, record, "status_code")
+		assert.Equal(t, uint64(1), client.SlowRequests())
+	})
+
+	t.Run("disabled by default", func(t *testing.T) {
+		client := httpx.NewClientWithConfig(httpx.WithClientDefaultBaseURL(server.URL))
+
+		_, err := client.Execute(*httpx.NewRequest(http.MethodGet, httpx.WithPath("/slow")), map[string]any{})
+		require.NoError(t, err)
+		assert.Equal(t, uint64(0), client.SlowRequests())
+	})
+}
+
+// findLogRecord returns the first JSON log record with the given message, or nil
+func findLogRecord(t *testing.T, buf *bytes.Buffer, msg string) map[string]any {
+	t.Helper()
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		record := map[string]any{}
+		require.NoError(t, json.Unmarshal([]byte(line), &record))
+		if record["msg"] == msg {
+			return record
+		}
+	}
+	return nil
+}
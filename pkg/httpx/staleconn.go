@@ -0,0 +1,93 @@
+package httpx
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"strings"
+	"sync/atomic"
+	"syscall"
+)
+
+// doWithStaleConnectionRetry sends the request, retrying exactly once when it failed because a reused
+// keep-alive connection had already been closed by the server (idle timeout race, ECONNRESET, HTTP/2 GOAWAY)
+//
+// net/http only replays requests it knows were not written or whose method is safe, so a PUT racing an idle
+// connection close surfaces as a spurious error. Idempotent requests are replayed on a fresh connection, others
+// only if none of the request was written, as the server may have processed it before closing the connection.
+// Requests whose body cannot be rewound are never replayed.
+func doWithStaleConnectionRetry(do func(*http.Request) (*http.Response, error), req *http.Request) (*http.Response, error) {
+	var reused, wrote atomic.Bool
+	trace := &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			reused.Store(info.Reused)
+		},
+		WroteHeaders: func() {
+			wrote.Store(true)
+		},
+	}
+
+	resp, err := do(req.WithContext(httptrace.WithClientTrace(req.Context(), trace)))
+	if err == nil || !reused.Load() || req.Context().Err() != nil || !isStaleConnectionError(err) {
+		return resp, err
+	}
+	if wrote.Load() && !isIdempotentMethod(req.Method) {
+		return resp, err
+	}
+
+	retry, ok := rewindRequest(req)
+	if !ok {
+		return resp, err
+	}
+	return do(retry)
+}
+
+// isIdempotentMethod reports whether sending a request of the method twice has the effect of sending it once
+func isIdempotentMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// rewindRequest clones the request with a fresh body, reporting false if the body cannot be replayed
+func rewindRequest(req *http.Request) (*http.Request, bool) {
+	retry := req.Clone(req.Context())
+	if req.Body == nil || req.Body == http.NoBody {
+		return retry, true
+	}
+	if req.GetBody == nil {
+		return nil, false
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, false
+	}
+	retry.Body = body
+	return retry, true
+}
+
+// staleConnectionMessages are the messages of the errors of net/http reporting a connection closed by the
+// server, which it does not export: an HTTP/2 GOAWAY closing the connection before the stream completed, and an
+// HTTP/1 connection closed while idle
+var staleConnectionMessages = []string{
+	"http2: server sent GOAWAY and closed the connection",
+	"http: server closed idle connection",
+}
+
+// isStaleConnectionError checks if an error was caused by the server closing a connection before responding
+func isStaleConnectionError(err error) bool {
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.EPIPE) {
+		return true
+	}
+	message := err.Error()
+	for _, stale := range staleConnectionMessages {
+		if strings.Contains(message, stale) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,290 @@
+package httpx_test
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/http2/hpack"
+
+	"github.com/bdpiprava/easy-http/pkg/httpx"
+)
+
+// staleConnServer serves one request per connection with keep-alive, then resets the connection
+// after reading the next request, simulating a server closing an idle connection
+type staleConnServer struct {
+	listener net.Listener
+	conns    atomic.Int32
+	requests atomic.Int32
+	// resetFirst resets the very first request on each connection instead of serving it
+	resetFirst bool
+}
+
+func newStaleConnServer(t *testing.T, resetFirst bool) *staleConnServer {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	server := &staleConnServer{listener: listener, resetFirst: resetFirst}
+	go server.serve()
+	t.Cleanup(func() { _ = listener.Close() })
+	return server
+}
+
+func (s *staleConnServer) URL() string {
+	return "http://" + s.listener.Addr().String()
+}
+
+func (s *staleConnServer) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.conns.Add(1)
+		go s.handle(conn.(*net.TCPConn))
+	}
+}
+
+func (s *staleConnServer) handle(conn *net.TCPConn) {
+	reader := bufio.NewReader(conn)
+	for served := 0; ; served++ {
+		req, err := http.ReadRequest(reader)
+		if err != nil {
+			_ = conn.Close()
+			return
+		}
+		_, _ = io.Copy(io.Discard, req.Body)
+		s.requests.Add(1)
+
+		if served > 0 || s.resetFirst {
+			// Reset the connection without responding
+			_ = conn.SetLinger(0)
+			_ = conn.Close()
+			return
+		}
+		_, _ = conn.Write([]byte("HTTP/1.1 200 OK\r\nContent-Type: application/json\r\nContent-Length: 11\r\n\r\n{\"ok\":true}"))
+	}
+}
+
+// goAwayServer is an HTTP/2 server serving one request per connection, then answering the next one with a
+// GOAWAY covering its stream and closing the connection, as a server shutting down does
+type goAwayServer struct {
+	listener net.Listener
+	conns    atomic.Int32
+	requests atomic.Int32
+}
+
+func newGoAwayServer(t *testing.T, pki *testPKI) *goAwayServer {
+	t.Helper()
+
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{{Certificate: [][]byte{pki.leafDER}, PrivateKey: pki.leafKey}},
+		NextProtos:   []string{"h2"},
+		MinVersion:   tls.VersionTLS12,
+	})
+	require.NoError(t, err)
+
+	server := &goAwayServer{listener: listener}
+	go server.serve()
+	t.Cleanup(func() { _ = listener.Close() })
+	return server
+}
+
+func (s *goAwayServer) URL() string {
+	return "https://" + s.listener.Addr().String()
+}
+
+func (s *goAwayServer) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.conns.Add(1)
+		go s.handle(conn)
+	}
+}
+
+// HTTP/2 frame types and flags used by the server, see RFC 9113
+const (
+	h2FrameData     = 0x0
+	h2FrameHeaders  = 0x1
+	h2FrameSettings = 0x4
+	h2FrameGoAway   = 0x7
+	h2FlagEndStream = 0x1
+	h2FlagAck       = 0x1
+	h2FlagEndHeader = 0x4
+)
+
+func (s *goAwayServer) handle(conn net.Conn) {
+	defer func() { _ = conn.Close() }()
+	preface := make([]byte, len(http2Preface))
+	if _, err := io.ReadFull(conn, preface); err != nil {
+		return
+	}
+	writeFrame := func(frameType, flags byte, stream uint32, payload []byte) {
+		header := []byte{byte(len(payload) >> 16), byte(len(payload) >> 8), byte(len(payload)), frameType, flags, 0, 0, 0, 0}
+		binary.BigEndian.PutUint32(header[5:], stream)
+		_, _ = conn.Write(append(header, payload...))
+	}
+	writeFrame(h2FrameSettings, 0, 0, nil)
+
+	for served := 0; ; {
+		header := make([]byte, 9)
+		if _, err := io.ReadFull(conn, header); err != nil {
+			return
+		}
+		payload := make([]byte, int(header[0])<<16|int(header[1])<<8|int(header[2]))
+		if _, err := io.ReadFull(conn, payload); err != nil {
+			return
+		}
+		frameType, flags, stream := header[3], header[4], binary.BigEndian.Uint32(header[5:])&0x7fffffff
+		switch {
+		case frameType == h2FrameSettings && flags&h2FlagAck == 0:
+			writeFrame(h2FrameSettings, h2FlagAck, 0, nil)
+			continue
+		case (frameType == h2FrameHeaders || frameType == h2FrameData) && flags&h2FlagEndStream != 0:
+		default:
+			continue
+		}
+
+		s.requests.Add(1)
+		if served > 0 {
+			goAway := make([]byte, 8) // Last stream ID covering the request, NO_ERROR
+			binary.BigEndian.PutUint32(goAway, stream)
+			writeFrame(h2FrameGoAway, 0, 0, goAway)
+			return
+		}
+		served++
+		var block bytes.Buffer
+		encoder := hpack.NewEncoder(&block)
+		_ = encoder.WriteField(hpack.HeaderField{Name: ":status", Value: "200"})
+		_ = encoder.WriteField(hpack.HeaderField{Name: "content-type", Value: "application/json"})
+		writeFrame(h2FrameHeaders, h2FlagEndHeader, stream, block.Bytes())
+		writeFrame(h2FrameData, h2FlagEndStream, stream, []byte(`{"ok":true}`))
+	}
+}
+
+const http2Preface = "PRI * HTTP/2.0\r\n\r\nSM\r\n\r\n"
+
+func TestStaleConnectionRetry(t *testing.T) {
+	t.Run("replays PUT once on a fresh connection", func(t *testing.T) {
+		server := newStaleConnServer(t, false)
+		client := httpx.NewClientWithConfig(httpx.WithClientDefaultBaseURL(server.URL()))
+
+		for range 2 {
+			resp, err := client.Execute(*httpx.NewRequest(http.MethodPut,
+				httpx.WithJSONBody(map[string]string{"name": "test"}),
+			), map[string]any{})
+
+			require.NoError(t, err)
+			assert.Equal(t, map[string]any{"ok": true}, resp.Body)
+		}
+
+		assert.Equal(t, int32(2), server.conns.Load())
+		assert.Equal(t, int32(3), server.requests.Load())
+	})
+
+	t.Run("does not replay a POST the server read before resetting the connection", func(t *testing.T) {
+		server := newStaleConnServer(t, false)
+		client := httpx.NewClientWithConfig(httpx.WithClientDefaultBaseURL(server.URL()))
+
+		_, err := client.Execute(*httpx.NewRequest(http.MethodPost, httpx.WithJSONBody(map[string]string{"n": "1"})), map[string]any{})
+		require.NoError(t, err)
+		_, err = client.Execute(*httpx.NewRequest(http.MethodPost, httpx.WithJSONBody(map[string]string{"n": "2"})), map[string]any{})
+
+		require.Error(t, err)
+		assert.Equal(t, int32(1), server.conns.Load())
+		assert.Equal(t, int32(2), server.requests.Load())
+	})
+
+	t.Run("replays PUT once when the server sends GOAWAY", func(t *testing.T) {
+		pki := newTestPKI(t)
+		server := newGoAwayServer(t, pki)
+		client := httpx.NewClientWithConfig(
+			httpx.WithClientDefaultBaseURL(server.URL()),
+			httpx.WithClientTLSConfig(pki.tlsConfig()),
+		)
+
+		for range 2 {
+			resp, err := client.Execute(*httpx.NewRequest(http.MethodPut,
+				httpx.WithJSONBody(map[string]string{"name": "test"}),
+			), map[string]any{})
+
+			require.NoError(t, err)
+			assert.Equal(t, map[string]any{"ok": true}, resp.Body)
+		}
+
+		assert.Equal(t, int32(2), server.conns.Load())
+		assert.Equal(t, int32(3), server.requests.Load())
+	})
+
+	t.Run("does not replay a POST the server may have processed before sending GOAWAY", func(t *testing.T) {
+		pki := newTestPKI(t)
+		server := newGoAwayServer(t, pki)
+		client := httpx.NewClientWithConfig(
+			httpx.WithClientDefaultBaseURL(server.URL()),
+			httpx.WithClientTLSConfig(pki.tlsConfig()),
+		)
+
+		_, err := client.Execute(*httpx.NewRequest(http.MethodPost, httpx.WithJSONBody(map[string]string{"n": "1"})), map[string]any{})
+		require.NoError(t, err)
+		_, err = client.Execute(*httpx.NewRequest(http.MethodPost, httpx.WithJSONBody(map[string]string{"n": "2"})), map[string]any{})
+
+		require.Error(t, err)
+		assert.Equal(t, int32(2), server.requests.Load())
+	})
+
+	t.Run("does not retry when disabled", func(t *testing.T) {
+		server := newStaleConnServer(t, false)
+		client := httpx.NewClientWithConfig(
+			httpx.WithClientDefaultBaseURL(server.URL()),
+			httpx.WithoutStaleConnectionRetry(),
+		)
+
+		_, err := client.Execute(*httpx.NewRequest(http.MethodPut, httpx.WithJSONBody(map[string]string{"n": "1"})), map[string]any{})
+		require.NoError(t, err)
+		_, err = client.Execute(*httpx.NewRequest(http.MethodPut, httpx.WithJSONBody(map[string]string{"n": "2"})), map[string]any{})
+
+		require.Error(t, err)
+		assert.Equal(t, int32(2), server.requests.Load())
+	})
+
+	t.Run("does not retry failures on a new connection", func(t *testing.T) {
+		server := newStaleConnServer(t, true)
+		client := httpx.NewClientWithConfig(httpx.WithClientDefaultBaseURL(server.URL()))
+
+		_, err := client.Execute(*httpx.NewRequest(http.MethodPut, httpx.WithJSONBody(map[string]string{"n": "1"})), map[string]any{})
+
+		require.Error(t, err)
+		assert.Equal(t, int32(1), server.requests.Load())
+	})
+
+	t.Run("does not replay bodies that cannot be rewound", func(t *testing.T) {
+		server := newStaleConnServer(t, false)
+		client := httpx.NewClientWithConfig(httpx.WithClientDefaultBaseURL(server.URL()))
+
+		_, err := client.Execute(*httpx.NewRequest(http.MethodPut, httpx.WithJSONBody(map[string]string{"n": "1"})), map[string]any{})
+		require.NoError(t, err)
+
+		reader, writer := io.Pipe()
+		go func() {
+			_, _ = writer.Write([]byte(`{"n":"2"}`))
+			_ = writer.Close()
+		}()
+		_, err = client.Execute(*httpx.NewRequest(http.MethodPut, httpx.WithBody(reader)), map[string]any{})
+
+		require.Error(t, err)
+		assert.Equal(t, int32(2), server.requests.Load())
+	})
+}
@@ -0,0 +1,70 @@
+package httpx
+
+import (
+	"net/http"
+)
+
+// StandardClient returns an *http.Client whose requests run through the client middleware chain
+// (retries, circuit breaker, rate limiting, metrics, tracing, logging), so SDKs that accept an
+// *http.Client inherit the same resilience and observability as requests made with Execute
+//
+// The returned client shares the connection pool, timeout and cookie jar of this client. Requests are
+// sent as built by the caller: default base URL, headers and basic auth are not applied, so credentials
+// meant for one API do not leak into third-party calls. Errors are returned as *url.Error wrapping the
+// *HTTPError, so helpers like IsTimeoutError and the sentinel errors work with errors.Is and errors.As.
+func (c Client) StandardClient() *http.Client {
+	return &http.Client{
+		Transport:     c.Transport(),
+		CheckRedirect: c.client.CheckRedirect,
+		Jar:           c.client.Jar,
+		Timeout:       c.client.Timeout,
+	}
+}
+
+// Transport returns an http.RoundTripper sending requests through the client middleware chain and
+// connection pool, to compose with other transports, e.g. &oauth2.Transport{Base: client.Transport()}
+//
+// A RoundTripper sends a single request, so the client timeout, cookie jar and redirect policy are not
+// applied; they belong to the *http.Client using it. StandardClient returns such a client preconfigured.
+func (c Client) Transport() http.RoundTripper {
+	return &clientTransport{client: &c}
+}
+
+// clientTransport is an http.RoundTripper that sends requests through the client middleware chain
+type clientTransport struct {
+	client *Client
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *clientTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// Middlewares may modify the request, which a RoundTripper must not do to the caller's request
+	req = req.Clone(req.Context())
+
+	req, stopwatch := t.client.slowRequests.start(req)
+	ctx := req.Context()
+	resp, err := t.client.send(req, t.transport().RoundTrip)
+	if err != nil {
+		httpErr := ClassifyError(err, req, resp)
+		// The response is not handed to the caller, so return its connection to the pool
+		t.client.drainer.release(resp)
+		t.client.slowRequests.report(ctx, req, stopwatch, 0, httpErr)
+		t.client.metrics.completed(httpErr)
+		return nil, httpErr
+	}
+	t.client.slowRequests.report(ctx, req, stopwatch, resp.StatusCode, nil)
+	t.client.metrics.completed(nil)
+	return resp, nil
+}
+
+// CloseIdleConnections closes idle connections of the underlying transport
+func (t *clientTransport) CloseIdleConnections() {
+	t.client.CloseIdleConnections()
+}
+
+// transport returns the round tripper performing the actual HTTP call
+func (t *clientTransport) transport() http.RoundTripper {
+	if t.client.client.Transport != nil {
+		return t.client.client.Transport
+	}
+	return http.DefaultTransport
+}
@@ -0,0 +1,218 @@
+package httpx_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bdpiprava/easy-http/pkg/httpx"
+)
+
+func TestStandardClient(t *testing.T) {
+	t.Run("retries through the middleware chain", func(t *testing.T) {
+		var calls atomic.Int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			if calls.Add(1) == 1 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			_, _ = w.Write([]byte("ok"))
+		}))
+		defer server.Close()
+
+		policy := httpx.DefaultRetryPolicy()
+		policy.BaseDelay = time.Millisecond
+		client := httpx.NewClientWithConfig(httpx.WithClientRetryPolicy(policy))
+
+		resp, err := client.StandardClient().Get(server.URL)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "ok", string(body))
+		assert.Equal(t, int32(2), calls.Load())
+	})
+
+	t.Run("does not modify the caller's request", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte(r.Header.Get("X-Injected")))
+		}))
+		defer server.Close()
+
+		client := httpx.NewClientWithConfig(httpx.WithClientMiddleware(headerMiddleware{key: "X-Injected", value: "yes"}))
+		req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, server.URL, nil)
+		require.NoError(t, err)
+
+		resp, err := client.StandardClient().Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		assert.Equal(t, "yes", string(body))
+		assert.Empty(t, req.Header.Get("X-Injected"))
+	})
+
+	t.Run("returns classified errors", func(t *testing.T) {
+		client := httpx.NewClientWithConfig(httpx.WithClientMiddleware(rejectingMiddleware{}))
+
+		_, err := client.StandardClient().Get("http://example.com/users/42")
+
+		require.Error(t, err)
+		urlErr := &url.Error{}
+		require.ErrorAs(t, err, &urlErr)
+		assert.True(t, httpx.IsMiddlewareError(err))
+		assert.Equal(t, "GET /users/42", httpx.GetOperation(err))
+	})
+
+	t.Run("shares the client cookie jar and timeout", func(t *testing.T) {
+		client := httpx.NewClientWithConfig(httpx.WithClientCookieJar(), httpx.WithClientTimeout(3*time.Second))
+
+		standard := client.StandardClient()
+
+		assert.NotNil(t, standard.Jar)
+		assert.Equal(t, 3*time.Second, standard.Timeout)
+	})
+
+	t.Run("reports timeouts", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, _ *http.Request) {
+			time.Sleep(100 * time.Millisecond)
+		}))
+		defer server.Close()
+
+		client := httpx.NewClientWithConfig(httpx.WithClientResponseHeaderTimeout(20 * time.Millisecond))
+
+		_, err := client.StandardClient().Get(server.URL)
+
+		require.Error(t, err)
+		assert.True(t, errors.Is(err, httpx.ErrTimeout))
+		assert.Equal(t, httpx.TimeoutPhaseResponseHeader, httpx.GetTimeoutPhase(err))
+	})
+}
+
+func TestClientTransport(t *testing.T) {
+	t.Run("composes with other transports", func(t *testing.T) {
+		var seen atomic.Value
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			seen.Store(r.Header.Get("Authorization") + "|" + r.Header.Get("X-Injected"))
+			w.WriteHeader(http.StatusNoContent)
+		}))
+		defer server.Close()
+
+		client := httpx.NewClientWithConfig(httpx.WithClientMiddleware(headerMiddleware{key: "X-Injected", value: "yes"}))
+		standard := &http.Client{Transport: &authTransport{token: "secret", base: client.Transport()}}
+
+		resp, err := standard.Get(server.URL)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+		assert.Equal(t, "Bearer secret|yes", seen.Load())
+	})
+
+	t.Run("returns classified errors", func(t *testing.T) {
+		client := httpx.NewClientWithConfig(httpx.WithClientMiddleware(rejectingMiddleware{}))
+		req := httptest.NewRequest(http.MethodGet, "http://example.com/", nil)
+
+		resp, err := client.Transport().RoundTrip(req)
+
+		assert.Nil(t, resp)
+		assert.True(t, httpx.IsMiddlewareError(err))
+	})
+}
+
+// authTransport adds a bearer token, standing in for transports such as oauth2.Transport
+type authTransport struct {
+	token string
+	base  http.RoundTripper
+}
+
+func (t *authTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", "Bearer "+t.token)
+	return t.base.RoundTrip(req)
+}
+
+// headerMiddleware sets a header on every request
+type headerMiddleware struct {
+	key, value string
+}
+
+func (m headerMiddleware) Name() string { return "header" }
+
+func (m headerMiddleware) Execute(ctx context.Context, req *http.Request, next httpx.MiddlewareFunc) (*http.Response, error) {
+	req.Header.Set(m.key, m.value)
+	return next(ctx, req)
+}
+
+func TestClientDo(t *testing.T) {
+	t.Run("returns the unread response after the middleware chain", func(t *testing.T) {
+		var calls atomic.Int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if calls.Add(1) == 1 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			w.Header().Set("Content-Type", "image/png")
+			_, _ = w.Write([]byte("png:" + r.Header.Get("X-Client") + r.URL.Path))
+		}))
+		defer server.Close()
+
+		policy := httpx.DefaultRetryPolicy()
+		policy.BaseDelay = time.Millisecond
+		client := httpx.NewClientWithConfig(
+			httpx.WithClientDefaultBaseURL(server.URL),
+			httpx.WithClientDefaultHeader("X-Client", "gallery"),
+			httpx.WithClientRetryPolicy(policy),
+		)
+
+		resp, err := client.Do(*httpx.NewRequest(http.MethodGet, httpx.WithPath("/cat.png")))
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		assert.Equal(t, "png:gallery/cat.png", string(body))
+		assert.Equal(t, "image/png", resp.Header.Get("Content-Type"))
+		assert.Equal(t, int32(2), calls.Load())
+		assert.Equal(t, int64(1), client.Metrics().Requests)
+	})
+
+	t.Run("returns error responses without an error", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			http.Error(w, "missing", http.StatusNotFound)
+		}))
+		defer server.Close()
+		client := httpx.NewClientWithConfig(httpx.WithClientDefaultBaseURL(server.URL))
+
+		resp, err := client.Do(*httpx.NewRequest(http.MethodGet))
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	})
+
+	t.Run("returns HTTPError on failure", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+		server.Close()
+		client := httpx.NewClientWithConfig(httpx.WithClientDefaultBaseURL(server.URL))
+
+		resp, err := client.Do(*httpx.NewRequest(http.MethodGet))
+
+		assert.Nil(t, resp)
+		var httpErr *httpx.HTTPError
+		require.ErrorAs(t, err, &httpErr)
+		assert.Equal(t, int64(1), client.Metrics().Errors)
+	})
+}
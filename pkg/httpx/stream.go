@@ -0,0 +1,479 @@
+package httpx
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// errStreamStalled is the cancellation cause of a request whose response body stalled
+var errStreamStalled = errors.New("stream stalled")
+
+// StreamStalledError is returned when reading a response body receives no data for the idle timeout
+// It matches ErrTimeout with errors.Is
+type StreamStalledError struct {
+	IdleTimeout time.Duration // Configured idle timeout
+	BytesRead   int64         // Bytes read from the stream before it stalled, across reconnects
+	Reconnects  int           // Number of reconnects made before giving up
+	Cause       error         // Reconnect failure, if the stream could not be re-established
+}
+
+// Error implements the error interface
+func (e *StreamStalledError) Error() string {
+	msg := fmt.Sprintf("stream stalled: no data received for %s after %d bytes", e.IdleTimeout, e.BytesRead)
+	if e.Reconnects > 0 {
+		msg += fmt.Sprintf(" and %d reconnects", e.Reconnects)
+	}
+	if e.Cause != nil {
+		msg += fmt.Sprintf(": %v", e.Cause)
+	}
+	return msg
+}
+
+// Unwrap implements the unwrapper interface for error chains
+func (e *StreamStalledError) Unwrap() error {
+	return e.Cause
+}
+
+// Is reports whether target is ErrTimeout
+func (e *StreamStalledError) Is(target error) bool {
+	return target == ErrTimeout
+}
+
+// Timeout implements net.Error so the error is recognized as a timeout
+func (e *StreamStalledError) Timeout() bool {
+	return true
+}
+
+// Temporary implements net.Error
+func (e *StreamStalledError) Temporary() bool {
+	return true
+}
+
+// StreamResumeMode describes how a reconnect resumes a stream
+type StreamResumeMode string
+
+const (
+	// StreamResumeLastEventID resumes a server-sent event stream by sending the Last-Event-ID header
+	StreamResumeLastEventID StreamResumeMode = "last_event_id"
+	// StreamResumeRange resumes a byte stream with a Range request from the last byte read
+	StreamResumeRange StreamResumeMode = "range"
+	// StreamResumeRestart re-issues the request from the start, so the consumer receives data again
+	StreamResumeRestart StreamResumeMode = "restart"
+)
+
+// StreamReconnectEvent describes a reconnect of a dropped or stalled stream
+type StreamReconnectEvent struct {
+	Attempt     int              // Reconnect attempt, starting at 1
+	Cause       error            // Error that interrupted the stream
+	BytesRead   int64            // Bytes read from the stream so far, across reconnects
+	Mode        StreamResumeMode // How the stream is resumed
+	LastEventID string           // Last-Event-ID sent to resume a server-sent event stream
+	Delay       time.Duration    // Delay before the request is re-issued
+}
+
+// StreamReconnectPolicy configures how streaming responses are re-established after transient drops
+//
+// Server-sent event streams (Content-Type text/event-stream) are resumed with the Last-Event-ID header;
+// only complete events are handed to the reader, so an event cut by the drop is discarded rather than
+// merged with the resumed stream. Other GET responses advertising Accept-Ranges: bytes are resumed with
+// a Range request, guarded by If-Range. Streams that cannot be resumed fail unless AllowRestart is set.
+type StreamReconnectPolicy struct {
+	// MaxReconnects is the maximum number of reconnects over the lifetime of the response
+	MaxReconnects int
+
+	// BaseDelay is the delay before the first reconnect, doubled for each subsequent one
+	BaseDelay time.Duration
+
+	// MaxDelay caps the delay between reconnects
+	MaxDelay time.Duration
+
+	// AllowRestart re-issues streams that cannot be resumed from the start
+	// Only enable it when the consumer tolerates receiving data again
+	AllowRestart bool
+
+	// OnReconnect is called before each reconnect
+	OnReconnect func(event StreamReconnectEvent)
+}
+
+// DefaultStreamReconnectPolicy returns a sensible default stream reconnect policy
+func DefaultStreamReconnectPolicy() StreamReconnectPolicy {
+	return StreamReconnectPolicy{
+		MaxReconnects: 5,
+		BaseDelay:     250 * time.Millisecond,
+		MaxDelay:      10 * time.Second,
+	}
+}
+
+// delay returns the backoff before the given reconnect attempt
+func (p *StreamReconnectPolicy) delay(attempt int) time.Duration {
+	delay := p.BaseDelay << min(attempt-1, 30)
+	if delay > p.MaxDelay || delay < 0 {
+		delay = p.MaxDelay
+	}
+	return delay
+}
+
+// maxSSEEventSize bounds the partial event held back from the reader of a server-sent event stream
+// Larger events are released as they arrive, giving up on discarding them if the stream drops
+const maxSSEEventSize = 1 << 20
+
+// streamDialer re-issues the request with extra headers, returning the response and the function cancelling it
+type streamDialer func(header http.Header) (*http.Response, context.CancelCauseFunc, error)
+
+// resumableStream is a response body that aborts when no data arrives for the idle timeout and
+// reconnects after stalls or transient drops according to its reconnect policy
+type resumableStream struct {
+	ctx            context.Context
+	idleTimeout    time.Duration
+	policy         *StreamReconnectPolicy // nil disables reconnects
+	reconnectDrops bool                   // Whether transient read errors reconnect, not only stalls
+	dial           streamDialer
+
+	mode      StreamResumeMode
+	validator string     // ETag or Last-Modified used as If-Range when resuming with Range
+	events    *sseEvents // Event tracker of server-sent event streams
+
+	conn       *streamConn
+	cancel     context.CancelCauseFunc // Cancels the initial request until its response is watched
+	ready      []byte                  // Complete events not yet handed to the reader
+	err        error                   // Terminal error returned once ready is consumed
+	bytesRead  int64
+	reconnects int
+	closed     atomic.Bool
+	closeOnce  sync.Once
+}
+
+// streamConn is the body of one connection of a stream
+type streamConn struct {
+	body    io.ReadCloser
+	cancel  context.CancelCauseFunc
+	timer   *time.Timer
+	stalled atomic.Bool
+}
+
+// newResumableStream prepares the request for idle watching and reconnects, returning nil when neither is configured
+// The returned request carries a cancellable context used to abort the stream
+func (c *Client) newResumableStream(req *http.Request, do func(*http.Request) (*http.Response, error), opts RequestOptions) (*http.Request, *resumableStream) {
+	policy := opts.StreamReconnect
+	reconnectDrops := policy != nil
+	if policy == nil && opts.StreamIdleReconnects > 0 {
+		policy = &StreamReconnectPolicy{MaxReconnects: opts.StreamIdleReconnects, AllowRestart: true}
+	}
+	if opts.StreamIdleTimeout <= 0 && policy == nil {
+		return req, nil
+	}
+
+	parent := req
+	ctx, cancel := context.WithCancelCause(req.Context())
+	stream := &resumableStream{
+		ctx:            req.Context(),
+		idleTimeout:    opts.StreamIdleTimeout,
+		policy:         policy,
+		reconnectDrops: reconnectDrops,
+		cancel:         cancel,
+	}
+	stream.dial = func(header http.Header) (*http.Response, context.CancelCauseFunc, error) {
+		retry, ok := rewindRequest(parent)
+		if !ok {
+			return nil, nil, errors.New("request body cannot be replayed")
+		}
+		for key, values := range header {
+			retry.Header[key] = values
+		}
+		ctx, cancel := context.WithCancelCause(retry.Context())
+		retry = retry.WithContext(ctx)
+		resp, err := c.send(retry, do)
+		if err == nil && resp.StatusCode >= 300 {
+			err = ClassifyError(nil, retry, resp)
+		}
+		if err != nil {
+			c.drainer.release(resp)
+			cancel(err)
+			return nil, nil, err
+		}
+		return resp, cancel, nil
+	}
+	return req.WithContext(ctx), stream
+}
+
+// watch starts reading the response body through the stream
+func (s *resumableStream) watch(resp *http.Response) {
+	if s == nil {
+		return
+	}
+	s.mode = resumeModeFor(resp)
+	if s.mode == StreamResumeLastEventID {
+		s.events = &sseEvents{}
+	}
+	if etag := resp.Header.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+		s.validator = etag
+	} else {
+		s.validator = resp.Header.Get("Last-Modified")
+	}
+	s.conn = s.newConn(resp.Body, s.cancel)
+	resp.Body = s
+}
+
+// abort cancels the request when no response is watched
+func (s *resumableStream) abort(cause error) {
+	if s == nil {
+		return
+	}
+	s.cancel(cause)
+}
+
+// resumeModeFor determines how the stream of a response can be resumed
+func resumeModeFor(resp *http.Response) StreamResumeMode {
+	if mediaType, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type")); err == nil && mediaType == "text/event-stream" {
+		return StreamResumeLastEventID
+	}
+	if resp.Request != nil && resp.Request.Method == http.MethodGet && resp.StatusCode == http.StatusOK &&
+		resp.Request.Header.Get("Range") == "" && strings.EqualFold(resp.Header.Get("Accept-Ranges"), "bytes") {
+		return StreamResumeRange
+	}
+	return StreamResumeRestart
+}
+
+// newConn starts the idle timer of a connection
+func (s *resumableStream) newConn(body io.ReadCloser, cancel context.CancelCauseFunc) *streamConn {
+	conn := &streamConn{body: body, cancel: cancel}
+	if s.idleTimeout > 0 {
+		conn.timer = time.AfterFunc(s.idleTimeout, func() {
+			conn.stalled.Store(true)
+			conn.cancel(errStreamStalled)
+		})
+	}
+	return conn
+}
+
+// stop stops the idle timer of the connection
+func (c *streamConn) stop() {
+	if c.timer != nil {
+		c.timer.Stop()
+	}
+}
+
+// Read implements io.Reader, reconnecting the stream after stalls and transient drops
+func (s *resumableStream) Read(p []byte) (int, error) {
+	for {
+		if len(s.ready) > 0 {
+			n := copy(p, s.ready)
+			s.ready = s.ready[n:]
+			return n, nil
+		}
+		if s.err != nil {
+			return 0, s.err
+		}
+
+		conn := s.conn
+		n, err := conn.body.Read(p)
+		if n > 0 {
+			s.bytesRead += int64(n)
+			if conn.timer != nil {
+				conn.timer.Reset(s.idleTimeout)
+			}
+		}
+		if s.events != nil {
+			// Hand complete events to the reader, holding back the one in progress
+			s.ready = s.events.feed(p[:n])
+			if err == io.EOF {
+				s.ready = append(s.ready, s.events.flush()...)
+			}
+			n = 0
+		}
+		if err == nil || n > 0 {
+			if n == 0 {
+				continue
+			}
+			return n, nil
+		}
+		if errors.Is(err, io.EOF) {
+			s.err = err
+			continue
+		}
+
+		stalled := conn.stalled.Load()
+		if !s.canReconnect(err, stalled) {
+			if stalled {
+				return 0, s.stalledError(nil)
+			}
+			return 0, err
+		}
+		cause := err
+		if stalled {
+			cause = s.stalledError(nil)
+		}
+		if rerr := s.reconnect(cause); rerr != nil {
+			if stalled {
+				return 0, s.stalledError(rerr)
+			}
+			return 0, fmt.Errorf("stream dropped after %d bytes: %w (reconnect failed: %w)", s.bytesRead, err, rerr)
+		}
+	}
+}
+
+// canReconnect reports whether the stream interrupted by err may be reconnected
+func (s *resumableStream) canReconnect(err error, stalled bool) bool {
+	if s.policy == nil || s.reconnects >= s.policy.MaxReconnects || s.closed.Load() || s.ctx.Err() != nil {
+		return false
+	}
+	if !stalled {
+		// The body read timeout bounds the whole call, so it is not a transient drop
+		phaseErr := &TimeoutPhaseError{}
+		if !s.reconnectDrops || errors.As(err, &phaseErr) {
+			return false
+		}
+	}
+	return s.mode != StreamResumeRestart || s.policy.AllowRestart || s.bytesRead == 0
+}
+
+// reconnect replaces the interrupted connection with a request resuming the stream
+func (s *resumableStream) reconnect(cause error) error {
+	s.conn.stop()
+	_ = s.conn.body.Close()
+	s.conn.cancel(cause)
+
+	s.reconnects++
+	event := StreamReconnectEvent{
+		Attempt:   s.reconnects,
+		Cause:     cause,
+		BytesRead: s.bytesRead,
+		Mode:      s.mode,
+		Delay:     s.policy.delay(s.reconnects),
+	}
+	header := http.Header{}
+	switch s.mode {
+	case StreamResumeLastEventID:
+		if s.events.lastID != "" {
+			event.LastEventID = s.events.lastID
+			header.Set("Last-Event-ID", s.events.lastID)
+		}
+	case StreamResumeRange:
+		header.Set("Range", "bytes="+strconv.FormatInt(s.bytesRead, 10)+"-")
+		if s.validator != "" {
+			header.Set("If-Range", s.validator)
+		}
+	}
+	if s.policy.OnReconnect != nil {
+		s.policy.OnReconnect(event)
+	}
+
+	if event.Delay > 0 {
+		timer := time.NewTimer(event.Delay)
+		select {
+		case <-s.ctx.Done():
+			timer.Stop()
+			return s.ctx.Err()
+		case <-timer.C:
+		}
+	}
+
+	resp, cancel, err := s.dial(header)
+	if err != nil {
+		return err
+	}
+	if s.mode == StreamResumeRange && resp.StatusCode != http.StatusPartialContent {
+		// The representation changed or the server ignored the range, so the stream cannot be continued
+		drainAndClose(resp.Body, defaultDrainLimit)
+		cancel(context.Canceled)
+		return fmt.Errorf("server did not resume the stream: status %d", resp.StatusCode)
+	}
+	if s.events != nil {
+		s.events.discardPending()
+	}
+	s.conn = s.newConn(resp.Body, cancel)
+	return nil
+}
+
+// stalledError describes the stalled stream
+func (s *resumableStream) stalledError(cause error) *StreamStalledError {
+	return &StreamStalledError{IdleTimeout: s.idleTimeout, BytesRead: s.bytesRead, Reconnects: s.reconnects, Cause: cause}
+}
+
+// Close stops the stream and closes the current connection
+func (s *resumableStream) Close() error {
+	s.closed.Store(true)
+	err := s.conn.body.Close()
+	s.closeOnce.Do(func() {
+		s.conn.stop()
+		s.conn.cancel(context.Canceled)
+	})
+	return err
+}
+
+// sseEvents tracks the event boundaries and last event ID of a server-sent event stream
+type sseEvents struct {
+	pending   []byte // Bytes of the event in progress
+	scanned   int    // Bytes of pending already scanned for line ends
+	lineStart int    // Start of the current line in pending
+	eventID   string // ID field of the event in progress
+	hasID     bool
+	lastID    string // ID of the last complete event
+}
+
+// feed adds data read from the stream, returning the complete events ready for the reader
+func (e *sseEvents) feed(data []byte) []byte {
+	e.pending = append(e.pending, data...)
+
+	released := 0
+	for i := e.scanned; i < len(e.pending); i++ {
+		if e.pending[i] != '\n' {
+			continue
+		}
+		line := bytes.TrimSuffix(e.pending[e.lineStart:i], []byte("\r"))
+		e.lineStart = i + 1
+		switch {
+		case len(line) == 0:
+			// A blank line dispatches the event
+			if e.hasID {
+				e.lastID = e.eventID
+				e.hasID = false
+			}
+			released = i + 1
+		case bytes.HasPrefix(line, []byte("id:")):
+			e.eventID = strings.TrimPrefix(string(line[3:]), " ")
+			e.hasID = true
+		}
+	}
+	e.scanned = len(e.pending)
+
+	if released == 0 && len(e.pending) > maxSSEEventSize {
+		released = e.lineStart
+	}
+	return e.release(released)
+}
+
+// flush returns the remaining bytes once the stream ended
+func (e *sseEvents) flush() []byte {
+	return e.release(len(e.pending))
+}
+
+// release removes the first n pending bytes and returns a copy of them
+func (e *sseEvents) release(n int) []byte {
+	if n == 0 {
+		return nil
+	}
+	out := bytes.Clone(e.pending[:n])
+	e.pending = append(e.pending[:0], e.pending[n:]...)
+	e.scanned -= n
+	e.lineStart -= n
+	return out
+}
+
+// discardPending drops the event interrupted by a reconnect
+func (e *sseEvents) discardPending() {
+	e.pending = e.pending[:0]
+	e.scanned = 0
+	e.lineStart = 0
+	e.hasID = false
+}
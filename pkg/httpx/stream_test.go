@@ -0,0 +1,301 @@
+package httpx_test
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bdpiprava/easy-http/pkg/httpx"
+)
+
+// newStallingServer writes chunks[i] on the i-th connection, then stalls until the client goes away
+// The last chunk completes the response instead of stalling
+func newStallingServer(t *testing.T, chunks ...string) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		index := int(requests.Add(1)) - 1
+		w.Header().Set("Content-Type", "text/event-stream")
+		if index < len(chunks) {
+			_, _ = w.Write([]byte(chunks[index]))
+		}
+		w.(http.Flusher).Flush()
+		if index == len(chunks)-1 {
+			return
+		}
+		<-r.Context().Done()
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+func TestWithStreamIdleTimeout(t *testing.T) {
+	t.Run("fails a stalled stream with StreamStalledError", func(t *testing.T) {
+		server, _ := newStallingServer(t, "data: 1\n\n", "unused")
+		client := httpx.NewClientWithConfig(httpx.WithClientDefaultBaseURL(server.URL))
+
+		resp, err := client.Execute(*httpx.NewRequest(http.MethodGet,
+			httpx.WithStreaming(),
+			httpx.WithStreamIdleTimeout(50*time.Millisecond),
+		), nil)
+		require.NoError(t, err)
+		defer resp.Close()
+
+		start := time.Now()
+		body, err := io.ReadAll(resp.StreamBody)
+
+		assert.Equal(t, "data: 1\n\n", string(body))
+		stalledErr := &httpx.StreamStalledError{}
+		require.ErrorAs(t, err, &stalledErr)
+		assert.Equal(t, int64(len(body)), stalledErr.BytesRead)
+		assert.Equal(t, 50*time.Millisecond, stalledErr.IdleTimeout)
+		assert.ErrorIs(t, err, httpx.ErrTimeout)
+		assert.Less(t, time.Since(start), 2*time.Second)
+	})
+
+	t.Run("reconnects a stalled stream", func(t *testing.T) {
+		server, requests := newStallingServer(t, "data: 1\n\n", "data: 2\n\n")
+		client := httpx.NewClientWithConfig(httpx.WithClientDefaultBaseURL(server.URL))
+
+		resp, err := client.Execute(*httpx.NewRequest(http.MethodGet,
+			httpx.WithStreaming(),
+			httpx.WithStreamIdleTimeout(50*time.Millisecond),
+			httpx.WithStreamIdleReconnect(1),
+		), nil)
+		require.NoError(t, err)
+		defer resp.Close()
+
+		body, err := io.ReadAll(resp.StreamBody)
+
+		require.NoError(t, err)
+		assert.Equal(t, "data: 1\n\ndata: 2\n\n", string(body))
+		assert.Equal(t, int32(2), requests.Load())
+	})
+
+	t.Run("gives up after the reconnect limit", func(t *testing.T) {
+		server, requests := newStallingServer(t, "data: a\n\n", "data: b\n\n", "data: c\n\n", "data: d\n\n")
+		client := httpx.NewClientWithConfig(httpx.WithClientDefaultBaseURL(server.URL))
+
+		resp, err := client.Execute(*httpx.NewRequest(http.MethodGet,
+			httpx.WithStreaming(),
+			httpx.WithStreamIdleTimeout(30*time.Millisecond),
+			httpx.WithStreamIdleReconnect(2),
+		), nil)
+		require.NoError(t, err)
+		defer resp.Close()
+
+		body, err := io.ReadAll(resp.StreamBody)
+
+		assert.Equal(t, "data: a\n\ndata: b\n\ndata: c\n\n", string(body))
+		stalledErr := &httpx.StreamStalledError{}
+		require.ErrorAs(t, err, &stalledErr)
+		assert.Equal(t, 2, stalledErr.Reconnects)
+		assert.Equal(t, int32(3), requests.Load())
+	})
+
+	t.Run("does not interrupt active streams", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			for range 5 {
+				_, _ = w.Write([]byte("x"))
+				w.(http.Flusher).Flush()
+				time.Sleep(20 * time.Millisecond)
+			}
+		}))
+		defer server.Close()
+		client := httpx.NewClientWithConfig(httpx.WithClientDefaultBaseURL(server.URL))
+
+		resp, err := client.Execute(*httpx.NewRequest(http.MethodGet,
+			httpx.WithStreaming(),
+			httpx.WithStreamIdleTimeout(60*time.Millisecond),
+		), nil)
+		require.NoError(t, err)
+		defer resp.Close()
+
+		body, err := io.ReadAll(resp.StreamBody)
+
+		require.NoError(t, err)
+		assert.Equal(t, "xxxxx", string(body))
+	})
+
+	t.Run("applies to buffered responses", func(t *testing.T) {
+		server, _ := newStallingServer(t, `{"partial":`, "unused")
+		client := httpx.NewClientWithConfig(httpx.WithClientDefaultBaseURL(server.URL))
+
+		_, err := client.Execute(*httpx.NewRequest(http.MethodGet, httpx.WithStreamIdleTimeout(50*time.Millisecond)), map[string]any{})
+
+		stalledErr := &httpx.StreamStalledError{}
+		assert.True(t, errors.As(err, &stalledErr))
+	})
+}
+
+// abortStream sends the partial body and drops the connection, so the client sees a transient read error
+func abortStream(w http.ResponseWriter, partial string) {
+	_, _ = w.Write([]byte(partial))
+	w.(http.Flusher).Flush()
+	panic(http.ErrAbortHandler)
+}
+
+func TestWithStreamReconnect(t *testing.T) {
+	t.Run("resumes event streams with Last-Event-ID", func(t *testing.T) {
+		var requests atomic.Int32
+		var lastEventID atomic.Value
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/event-stream")
+			if requests.Add(1) == 1 {
+				abortStream(w, "id: 1\ndata: a\n\nid: 2\ndata: cut")
+			}
+			lastEventID.Store(r.Header.Get("Last-Event-ID"))
+			_, _ = w.Write([]byte("id: 2\ndata: b\n\n"))
+		}))
+		defer server.Close()
+		client := httpx.NewClientWithConfig(httpx.WithClientDefaultBaseURL(server.URL))
+
+		var events []httpx.StreamReconnectEvent
+		resp, err := client.Execute(*httpx.NewRequest(http.MethodGet,
+			httpx.WithStreaming(),
+			httpx.WithStreamReconnect(httpx.StreamReconnectPolicy{
+				BaseDelay:   time.Millisecond,
+				OnReconnect: func(event httpx.StreamReconnectEvent) { events = append(events, event) },
+			}),
+		), nil)
+		require.NoError(t, err)
+		defer resp.Close()
+
+		body, err := io.ReadAll(resp.StreamBody)
+
+		require.NoError(t, err)
+		assert.Equal(t, "id: 1\ndata: a\n\nid: 2\ndata: b\n\n", string(body))
+		assert.Equal(t, "1", lastEventID.Load())
+		require.Len(t, events, 1)
+		assert.Equal(t, 1, events[0].Attempt)
+		assert.Equal(t, httpx.StreamResumeLastEventID, events[0].Mode)
+		assert.Equal(t, "1", events[0].LastEventID)
+		assert.Equal(t, time.Millisecond, events[0].Delay)
+		assert.Error(t, events[0].Cause)
+	})
+
+	t.Run("resumes byte streams with Range", func(t *testing.T) {
+		const content = "0123456789"
+		var requests atomic.Int32
+		var rangeHeader, ifRange atomic.Value
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Accept-Ranges", "bytes")
+			w.Header().Set("ETag", `"v1"`)
+			if requests.Add(1) == 1 {
+				w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+				abortStream(w, content[:4])
+			}
+			rangeHeader.Store(r.Header.Get("Range"))
+			ifRange.Store(r.Header.Get("If-Range"))
+			w.Header().Set("Content-Range", "bytes 4-9/10")
+			w.WriteHeader(http.StatusPartialContent)
+			_, _ = w.Write([]byte(content[4:]))
+		}))
+		defer server.Close()
+		client := httpx.NewClientWithConfig(httpx.WithClientDefaultBaseURL(server.URL))
+
+		resp, err := client.Execute(*httpx.NewRequest(http.MethodGet,
+			httpx.WithStreaming(),
+			httpx.WithStreamReconnect(httpx.StreamReconnectPolicy{BaseDelay: time.Millisecond}),
+		), nil)
+		require.NoError(t, err)
+		defer resp.Close()
+
+		body, err := io.ReadAll(resp.StreamBody)
+
+		require.NoError(t, err)
+		assert.Equal(t, content, string(body))
+		assert.Equal(t, "bytes=4-", rangeHeader.Load())
+		assert.Equal(t, `"v1"`, ifRange.Load())
+	})
+
+	t.Run("fails when the server does not honour the range", func(t *testing.T) {
+		var requests atomic.Int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.Header().Set("Accept-Ranges", "bytes")
+			w.Header().Set("Content-Length", "10")
+			if requests.Add(1) == 1 {
+				abortStream(w, "0123")
+			}
+			_, _ = w.Write([]byte("abcdefghij"))
+		}))
+		defer server.Close()
+		client := httpx.NewClientWithConfig(httpx.WithClientDefaultBaseURL(server.URL))
+
+		resp, err := client.Execute(*httpx.NewRequest(http.MethodGet,
+			httpx.WithStreaming(),
+			httpx.WithStreamReconnect(httpx.StreamReconnectPolicy{BaseDelay: time.Millisecond}),
+		), nil)
+		require.NoError(t, err)
+		defer resp.Close()
+
+		body, err := io.ReadAll(resp.StreamBody)
+
+		assert.Equal(t, "0123", string(body))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "server did not resume the stream")
+	})
+
+	t.Run("does not restart streams that cannot be resumed", func(t *testing.T) {
+		var requests atomic.Int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			requests.Add(1)
+			w.Header().Set("Content-Length", "10")
+			abortStream(w, "0123")
+		}))
+		defer server.Close()
+		client := httpx.NewClientWithConfig(httpx.WithClientDefaultBaseURL(server.URL))
+
+		resp, err := client.Execute(*httpx.NewRequest(http.MethodGet,
+			httpx.WithStreaming(),
+			httpx.WithStreamReconnect(httpx.StreamReconnectPolicy{BaseDelay: time.Millisecond}),
+		), nil)
+		require.NoError(t, err)
+		defer resp.Close()
+
+		body, err := io.ReadAll(resp.StreamBody)
+
+		assert.Equal(t, "0123", string(body))
+		assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+		assert.Equal(t, int32(1), requests.Load())
+	})
+
+	t.Run("gives up after the reconnect limit", func(t *testing.T) {
+		var requests atomic.Int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			requests.Add(1)
+			w.Header().Set("Content-Type", "text/event-stream")
+			abortStream(w, "data: x\n\n")
+		}))
+		defer server.Close()
+		client := httpx.NewClientWithConfig(httpx.WithClientDefaultBaseURL(server.URL))
+
+		var attempts []int
+		resp, err := client.Execute(*httpx.NewRequest(http.MethodGet,
+			httpx.WithStreaming(),
+			httpx.WithStreamReconnect(httpx.StreamReconnectPolicy{
+				MaxReconnects: 2,
+				BaseDelay:     time.Millisecond,
+				OnReconnect:   func(event httpx.StreamReconnectEvent) { attempts = append(attempts, event.Attempt) },
+			}),
+		), nil)
+		require.NoError(t, err)
+		defer resp.Close()
+
+		body, err := io.ReadAll(resp.StreamBody)
+
+		assert.Equal(t, "data: x\n\ndata: x\n\ndata: x\n\n", string(body))
+		assert.Error(t, err)
+		assert.Equal(t, []int{1, 2}, attempts)
+		assert.Equal(t, int32(3), requests.Load())
+	})
+}
@@ -0,0 +1,409 @@
+package httpx
+
+import (
+	"bufio"
+	"cmp"
+	"context"
+	"io"
+	"maps"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// defaultSubscriptionBuffer is the number of events buffered per subscription when SubscriptionConfig.Buffer is not set
+const defaultSubscriptionBuffer = 64
+
+// errSubscriptionsClosed is returned by SubscriptionManager.Subscribe once the manager is closed
+var errSubscriptionsClosed = errors.New("subscription manager is closed")
+
+// SSEEvent is a server-sent event
+type SSEEvent struct {
+	ID    string        // Last event ID, carried over from previous events when the event has none
+	Event string        // Event type, "message" when the event has none
+	Data  string        // Data lines joined with newlines
+	Retry time.Duration // Reconnection time requested by the server with the event, 0 if none
+}
+
+// SubscriptionHandler handles the events of a subscription, one at a time
+// An error is reported to SubscriptionConfig.OnError and the subscription goes on.
+type SubscriptionHandler func(ctx context.Context, event SSEEvent) error
+
+// SubscriptionConfig configures a SubscriptionManager, shared by all its subscriptions
+type SubscriptionConfig struct {
+	// Reconnect is the policy of re-subscribing after a stream ends or fails, resuming with Last-Event-ID
+	// MaxReconnects bounds consecutive failed reconnects, it is reset by every event received; the subscription
+	// is dropped after that. Delays are jittered so dropped streams do not reconnect all at once.
+	// Zero MaxReconnects, BaseDelay and MaxDelay take the values of DefaultStreamReconnectPolicy.
+	Reconnect StreamReconnectPolicy
+
+	// IdleTimeout reconnects streams receiving no data, not even a comment, for this long (0 disables)
+	IdleTimeout time.Duration
+
+	// Buffer is the number of events read ahead of a slow handler (default: 64); once it is full the stream is
+	// not read until the handler catches up, which slows the server down through TCP flow control
+	Buffer int
+
+	// OnError is called with handler errors and stream failures, from the goroutine of the subscription
+	OnError func(name string, err error)
+}
+
+// SubscriptionStats is the health of a subscription
+type SubscriptionStats struct {
+	Connected     bool      // Whether the stream is currently open
+	Events        int64     // Events received
+	Reconnects    int64     // Reconnects after the stream ended or failed
+	HandlerErrors int64     // Events the handler failed on
+	LastEventAt   time.Time // When the last event was received, zero if none
+	LastEventID   string    // ID sent in Last-Event-ID on reconnect
+	LastError     error     // Last stream failure or handler error, nil if none
+}
+
+// SubscriptionHealth is the aggregate health of the subscriptions of a SubscriptionManager
+type SubscriptionHealth struct {
+	Subscriptions int   // Active subscriptions
+	Connected     int   // Subscriptions with an open stream
+	Events        int64 // Events received by all subscriptions
+	Reconnects    int64 // Reconnects of all subscriptions
+	HandlerErrors int64 // Handler errors of all subscriptions
+	Failed        int64 // Subscriptions dropped after exhausting their reconnects
+
+	BySubscription map[string]SubscriptionStats
+}
+
+// SubscriptionManager maintains many server-sent event streams through one client, each with its own handler
+// Every subscription runs a goroutine reading its stream and one running its handler; the manager reconnects
+// dropped streams with a shared policy and reports their health:
+//
+//	subscriptions := httpx.NewSubscriptionManager(client, httpx.SubscriptionConfig{IdleTimeout: time.Minute})
+//	defer subscriptions.Close()
+//	err := subscriptions.Subscribe("orders", *httpx.NewRequest(http.MethodGet, httpx.WithPath("/orders/events")),
+//		func(ctx context.Context, event httpx.SSEEvent) error {
+//			return process(ctx, event.Data)
+//		})
+type SubscriptionManager struct {
+	client *Client
+	config SubscriptionConfig
+	rand   *lockedSource
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mu            sync.Mutex
+	closed        bool
+	subscriptions map[string]*subscription
+	failed        int64
+}
+
+// subscription is a stream maintained by a SubscriptionManager
+type subscription struct {
+	name    string
+	request Request
+	handler SubscriptionHandler
+	cancel  context.CancelFunc
+
+	mu    sync.Mutex
+	stats SubscriptionStats
+}
+
+// NewSubscriptionManager creates a subscription manager sending its requests with client
+// Subscriptions are stopped by Close, or once the client base context is done.
+func NewSubscriptionManager(client *Client, config SubscriptionConfig) *SubscriptionManager {
+	defaults := DefaultStreamReconnectPolicy()
+	if config.Reconnect.MaxReconnects <= 0 {
+		config.Reconnect.MaxReconnects = defaults.MaxReconnects
+	}
+	if config.Reconnect.BaseDelay <= 0 {
+		config.Reconnect.BaseDelay = defaults.BaseDelay
+	}
+	if config.Reconnect.MaxDelay <= 0 {
+		config.Reconnect.MaxDelay = defaults.MaxDelay
+	}
+	if config.Buffer <= 0 {
+		config.Buffer = defaultSubscriptionBuffer
+	}
+
+	base := client.config.BaseContext
+	if base == nil {
+		base = context.Background()
+	}
+	ctx, cancel := context.WithCancel(base)
+	return &SubscriptionManager{
+		client:        client,
+		config:        config,
+		rand:          newLockedSource(client.config.RandSource),
+		ctx:           ctx,
+		cancel:        cancel,
+		subscriptions: make(map[string]*subscription),
+	}
+}
+
+// Subscribe opens the stream of request and passes its events to handler until Unsubscribe or Close
+// The request is sent with WithStreaming and an Accept: text/event-stream header.
+func (m *SubscriptionManager) Subscribe(name string, request Request, handler SubscriptionHandler) error {
+	if handler == nil {
+		return ValidationError("subscription handler cannot be nil", nil)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return errSubscriptionsClosed
+	}
+	if _, exists := m.subscriptions[name]; exists {
+		return ValidationError("subscription already exists: "+name, nil)
+	}
+
+	ctx, cancel := context.WithCancel(m.ctx)
+	sub := &subscription{name: name, request: request, handler: handler, cancel: cancel}
+	m.subscriptions[name] = sub
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		m.run(ctx, sub)
+	}()
+	return nil
+}
+
+// Unsubscribe closes the stream of a subscription, reporting whether it existed
+// Events already buffered are still handled.
+func (m *SubscriptionManager) Unsubscribe(name string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	sub, exists := m.subscriptions[name]
+	if exists {
+		sub.cancel()
+		delete(m.subscriptions, name)
+	}
+	return exists
+}
+
+// Close closes every stream and waits for the handlers to return
+func (m *SubscriptionManager) Close() {
+	m.mu.Lock()
+	m.closed = true
+	m.mu.Unlock()
+	m.cancel()
+	m.wg.Wait()
+}
+
+// Health returns the health of the subscriptions
+func (m *SubscriptionManager) Health() SubscriptionHealth {
+	m.mu.Lock()
+	subscriptions := maps.Clone(m.subscriptions)
+	health := SubscriptionHealth{
+		Subscriptions:  len(subscriptions),
+		Failed:         m.failed,
+		BySubscription: make(map[string]SubscriptionStats, len(subscriptions)),
+	}
+	m.mu.Unlock()
+
+	for name, sub := range subscriptions {
+		sub.mu.Lock()
+		stats := sub.stats
+		sub.mu.Unlock()
+		health.BySubscription[name] = stats
+		if stats.Connected {
+			health.Connected++
+		}
+		health.Events += stats.Events
+		health.Reconnects += stats.Reconnects
+		health.HandlerErrors += stats.HandlerErrors
+	}
+	return health
+}
+
+// run maintains the stream of a subscription, handing its events to the handler goroutine
+func (m *SubscriptionManager) run(ctx context.Context, sub *subscription) {
+	events := make(chan SSEEvent, m.config.Buffer)
+	handled := make(chan struct{})
+	go func() {
+		defer close(handled)
+		for event := range events {
+			if err := sub.handler(ctx, event); err != nil {
+				sub.update(func(stats *SubscriptionStats) {
+					stats.HandlerErrors++
+					stats.LastError = err
+				})
+				m.reportError(sub.name, err)
+			}
+		}
+	}()
+	defer func() {
+		close(events)
+		<-handled
+	}()
+
+	var retry time.Duration // Reconnection time requested by the server
+	failures := 0
+	for {
+		received, err := m.stream(ctx, sub, events, &retry)
+		if ctx.Err() != nil {
+			return
+		}
+		if received {
+			failures = 0
+		}
+		failures++
+		if err != nil {
+			sub.update(func(stats *SubscriptionStats) { stats.LastError = err })
+			m.reportError(sub.name, err)
+		}
+		if failures > m.config.Reconnect.MaxReconnects {
+			m.drop(sub, errors.Wrapf(cmp.Or(err, io.EOF), "subscription %s dropped after %d reconnects", sub.name, failures-1))
+			return
+		}
+
+		delay := retry
+		if delay == 0 {
+			delay = m.config.Reconnect.delay(failures)
+		}
+		// Half the delay is jittered, spreading the reconnects of streams dropped together
+		delay = delay/2 + m.rand.jitter(delay/2+1)
+		if m.config.Reconnect.OnReconnect != nil {
+			m.config.Reconnect.OnReconnect(StreamReconnectEvent{
+				Attempt:     failures,
+				Cause:       err,
+				Mode:        StreamResumeLastEventID,
+				LastEventID: sub.lastEventID(),
+				Delay:       delay,
+			})
+		}
+		timer := clockOrSystem(m.client.config.Clock).NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C():
+		}
+		sub.update(func(stats *SubscriptionStats) { stats.Reconnects++ })
+	}
+}
+
+// stream opens the stream of a subscription and reads it until it ends, reporting whether events were received
+func (m *SubscriptionManager) stream(ctx context.Context, sub *subscription, events chan<- SSEEvent, retry *time.Duration) (bool, error) {
+	opts := []RequestOption{WithContext(ctx), WithStreaming(), WithHeader("Accept", "text/event-stream")}
+	if id := sub.lastEventID(); id != "" {
+		opts = append(opts, WithHeader("Last-Event-ID", id))
+	}
+	if m.config.IdleTimeout > 0 {
+		opts = append(opts, WithStreamIdleTimeout(m.config.IdleTimeout))
+	}
+	request := sub.request
+	request.opts = append(append([]RequestOption{}, request.opts...), opts...)
+
+	resp, err := m.client.Execute(request, nil)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Close()
+	if resp.StatusCode != http.StatusOK {
+		return false, ClassifyError(nil, resp.httpResponse.Request, resp.httpResponse)
+	}
+
+	sub.update(func(stats *SubscriptionStats) { stats.Connected = true })
+	defer sub.update(func(stats *SubscriptionStats) { stats.Connected = false })
+
+	received := false
+	err = readSSE(resp.StreamBody, sub.lastEventID(), func(event SSEEvent) bool {
+		received = true
+		if event.Retry > 0 {
+			*retry = event.Retry
+		}
+		sub.update(func(stats *SubscriptionStats) {
+			stats.Events++
+			stats.LastEventAt = time.Now()
+			stats.LastEventID = event.ID
+		})
+		select {
+		case events <- event:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	})
+	return received, err
+}
+
+// drop removes a subscription which exhausted its reconnects
+func (m *SubscriptionManager) drop(sub *subscription, err error) {
+	m.mu.Lock()
+	if m.subscriptions[sub.name] == sub {
+		delete(m.subscriptions, sub.name)
+		m.failed++
+	}
+	m.mu.Unlock()
+	m.reportError(sub.name, err)
+}
+
+// reportError passes an error to the OnError callback
+func (m *SubscriptionManager) reportError(name string, err error) {
+	if m.config.OnError != nil {
+		m.config.OnError(name, err)
+	}
+}
+
+// update changes the stats of the subscription under its lock
+func (s *subscription) update(change func(*SubscriptionStats)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	change(&s.stats)
+}
+
+// lastEventID returns the ID of the last event received
+func (s *subscription) lastEventID() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.stats.LastEventID
+}
+
+// readSSE parses the server-sent events of body, passing each to emit until it returns false or body ends
+// lastID is the event ID carried over to events without one, as after a reconnect.
+func readSSE(body io.Reader, lastID string, emit func(SSEEvent) bool) error {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 4096), maxSSEEventSize)
+
+	event := SSEEvent{ID: lastID}
+	var data []string
+	for scanner.Scan() {
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+		if line == "" {
+			// A blank line dispatches the event, unless it has no data
+			if data != nil {
+				event.Data = strings.Join(data, "\n")
+				if event.Event == "" {
+					event.Event = "message"
+				}
+				if !emit(event) {
+					return nil
+				}
+			}
+			event, data = SSEEvent{ID: event.ID}, nil
+			continue
+		}
+		if strings.HasPrefix(line, ":") {
+			continue // Comment, e.g. a keep-alive
+		}
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+		switch field {
+		case "data":
+			data = append(data, value)
+		case "event":
+			event.Event = value
+		case "id":
+			if !strings.ContainsRune(value, 0) {
+				event.ID = value
+			}
+		case "retry":
+			if ms, err := strconv.Atoi(value); err == nil && ms >= 0 {
+				event.Retry = time.Duration(ms) * time.Millisecond
+			}
+		}
+	}
+	return scanner.Err()
+}
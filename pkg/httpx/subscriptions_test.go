@@ -0,0 +1,197 @@
+package httpx_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bdpiprava/easy-http/pkg/httpx"
+)
+
+func TestSubscriptionManager(t *testing.T) {
+	fastReconnect := httpx.StreamReconnectPolicy{MaxReconnects: 3, BaseDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond}
+
+	t.Run("dispatches the events of each stream to its handler", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "text/event-stream", r.Header.Get("Accept"))
+			w.Header().Set("Content-Type", "text/event-stream")
+			topic := r.URL.Query().Get("topic")
+			_, _ = fmt.Fprintf(w, ": keep-alive\n\nid: 1\nevent: update\ndata: %s\ndata: line 2\n\n", topic)
+			w.(http.Flusher).Flush()
+			<-r.Context().Done()
+		}))
+		defer server.Close()
+		subscriptions := httpx.NewSubscriptionManager(httpx.NewClientWithConfig(httpx.WithClientDefaultBaseURL(server.URL)), httpx.SubscriptionConfig{})
+		defer subscriptions.Close()
+
+		received := make(chan httpx.SSEEvent, 10)
+		for _, topic := range []string{"a", "b", "c"} {
+			err := subscriptions.Subscribe(topic, *httpx.NewRequest(http.MethodGet, httpx.WithQueryParam("topic", topic)),
+				func(_ context.Context, event httpx.SSEEvent) error {
+					received <- event
+					return nil
+				})
+			require.NoError(t, err)
+		}
+
+		data := map[string]bool{}
+		for range 3 {
+			event := <-received
+			assert.Equal(t, "1", event.ID)
+			assert.Equal(t, "update", event.Event)
+			data[event.Data] = true
+		}
+		assert.Equal(t, map[string]bool{"a\nline 2": true, "b\nline 2": true, "c\nline 2": true}, data)
+		assert.Eventually(t, func() bool { return subscriptions.Health().Connected == 3 }, time.Second, 5*time.Millisecond)
+		health := subscriptions.Health()
+		assert.Equal(t, 3, health.Subscriptions)
+		assert.Equal(t, int64(3), health.Events)
+		assert.Equal(t, "1", health.BySubscription["a"].LastEventID)
+	})
+
+	t.Run("rejects duplicate names and subscriptions after Close", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+			<-r.Context().Done()
+		}))
+		defer server.Close()
+		subscriptions := httpx.NewSubscriptionManager(httpx.NewClientWithConfig(httpx.WithClientDefaultBaseURL(server.URL)), httpx.SubscriptionConfig{})
+		handler := func(context.Context, httpx.SSEEvent) error { return nil }
+
+		require.NoError(t, subscriptions.Subscribe("a", *httpx.NewRequest(http.MethodGet), handler))
+		assert.Error(t, subscriptions.Subscribe("a", *httpx.NewRequest(http.MethodGet), handler))
+		assert.True(t, subscriptions.Unsubscribe("a"))
+		assert.False(t, subscriptions.Unsubscribe("a"))
+		subscriptions.Close()
+		assert.Error(t, subscriptions.Subscribe("b", *httpx.NewRequest(http.MethodGet), handler))
+	})
+
+	t.Run("reconnects with the last event ID", func(t *testing.T) {
+		var (
+			mu           sync.Mutex
+			lastEventIDs []string
+		)
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			lastEventIDs = append(lastEventIDs, r.Header.Get("Last-Event-ID"))
+			connection := len(lastEventIDs)
+			mu.Unlock()
+			_, _ = fmt.Fprintf(w, "id: %d\ndata: event %d\n\n", connection, connection)
+			if connection >= 3 {
+				w.(http.Flusher).Flush()
+				<-r.Context().Done()
+			}
+		}))
+		defer server.Close()
+		subscriptions := httpx.NewSubscriptionManager(httpx.NewClientWithConfig(httpx.WithClientDefaultBaseURL(server.URL)),
+			httpx.SubscriptionConfig{Reconnect: fastReconnect})
+		defer subscriptions.Close()
+
+		var events atomic.Int32
+		require.NoError(t, subscriptions.Subscribe("a", *httpx.NewRequest(http.MethodGet), func(context.Context, httpx.SSEEvent) error {
+			events.Add(1)
+			return nil
+		}))
+
+		assert.Eventually(t, func() bool { return events.Load() == 3 }, time.Second, 5*time.Millisecond)
+		mu.Lock()
+		assert.Equal(t, []string{"", "1", "2"}, lastEventIDs)
+		mu.Unlock()
+		assert.Equal(t, int64(2), subscriptions.Health().Reconnects)
+	})
+
+	t.Run("drops subscriptions exhausting their reconnects", func(t *testing.T) {
+		var requests atomic.Int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			requests.Add(1)
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}))
+		defer server.Close()
+		var (
+			mu     sync.Mutex
+			errs   []error
+			failed = make(chan struct{})
+		)
+		subscriptions := httpx.NewSubscriptionManager(httpx.NewClientWithConfig(httpx.WithClientDefaultBaseURL(server.URL)),
+			httpx.SubscriptionConfig{Reconnect: fastReconnect, OnError: func(name string, err error) {
+				assert.Equal(t, "a", name)
+				mu.Lock()
+				defer mu.Unlock()
+				errs = append(errs, err)
+				if len(errs) == 5 {
+					close(failed)
+				}
+			}})
+		defer subscriptions.Close()
+
+		require.NoError(t, subscriptions.Subscribe("a", *httpx.NewRequest(http.MethodGet), func(context.Context, httpx.SSEEvent) error { return nil }))
+
+		<-failed
+		assert.Equal(t, int32(4), requests.Load())
+		health := subscriptions.Health()
+		assert.Equal(t, 0, health.Subscriptions)
+		assert.Equal(t, int64(1), health.Failed)
+		mu.Lock()
+		assert.Contains(t, errs[4].Error(), "dropped after 3 reconnects")
+		mu.Unlock()
+	})
+
+	t.Run("counts handler errors without stopping the subscription", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = fmt.Fprint(w, "data: 1\n\ndata: 2\n\n")
+			w.(http.Flusher).Flush()
+			<-r.Context().Done()
+		}))
+		defer server.Close()
+		var reported atomic.Int32
+		subscriptions := httpx.NewSubscriptionManager(httpx.NewClientWithConfig(httpx.WithClientDefaultBaseURL(server.URL)),
+			httpx.SubscriptionConfig{OnError: func(string, error) { reported.Add(1) }})
+		defer subscriptions.Close()
+
+		require.NoError(t, subscriptions.Subscribe("a", *httpx.NewRequest(http.MethodGet), func(context.Context, httpx.SSEEvent) error {
+			return errors.New("boom")
+		}))
+
+		assert.Eventually(t, func() bool { return subscriptions.Health().HandlerErrors == 2 }, time.Second, 5*time.Millisecond)
+		assert.Equal(t, int32(2), reported.Load())
+		assert.True(t, subscriptions.Health().BySubscription["a"].Connected)
+	})
+
+	t.Run("stops reading streams while the handler is behind", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			for i := range 10 {
+				_, _ = fmt.Fprintf(w, "data: %d\n\n", i)
+			}
+			w.(http.Flusher).Flush()
+			<-r.Context().Done()
+		}))
+		defer server.Close()
+		subscriptions := httpx.NewSubscriptionManager(httpx.NewClientWithConfig(httpx.WithClientDefaultBaseURL(server.URL)),
+			httpx.SubscriptionConfig{Buffer: 2})
+		defer subscriptions.Close()
+
+		release := make(chan struct{})
+		var handled atomic.Int32
+		require.NoError(t, subscriptions.Subscribe("a", *httpx.NewRequest(http.MethodGet), func(context.Context, httpx.SSEEvent) error {
+			<-release
+			handled.Add(1)
+			return nil
+		}))
+
+		// One event in the handler, two buffered and one waiting to be buffered
+		assert.Eventually(t, func() bool { return subscriptions.Health().Events == 4 }, time.Second, 5*time.Millisecond)
+		time.Sleep(20 * time.Millisecond)
+		assert.Equal(t, int64(4), subscriptions.Health().Events)
+
+		close(release)
+		assert.Eventually(t, func() bool { return handled.Load() == 10 }, time.Second, 5*time.Millisecond)
+	})
+}
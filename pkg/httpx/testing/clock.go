@@ -0,0 +1,134 @@
+package testing
+
+import (
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/bdpiprava/easy-http/pkg/httpx"
+)
+
+// FakeClock is an httpx.Clock whose time only moves when advanced, making retry backoffs and circuit breaker
+// timeouts instantaneous in tests:
+//
+//	clock := httpxtesting.NewFakeClock()
+//	client := httpx.NewClientWithConfig(httpx.WithClientClock(clock), httpx.WithClientRetryPolicy(policy))
+//	go client.Execute(req, "")
+//	assert.Equal(t, 100*time.Millisecond, clock.AdvanceToNext()) // the first retry was scheduled after 100ms
+type FakeClock struct {
+	mu      sync.Mutex
+	changed *sync.Cond // Signaled when a timer is created
+	now     time.Time
+	timers  []*fakeTimer
+}
+
+// fakeTimer is a timer of a FakeClock
+type fakeTimer struct {
+	clock    *FakeClock
+	deadline time.Time
+	ch       chan time.Time
+}
+
+// NewFakeClock returns a fake clock set to 2024-01-01 00:00:00 UTC
+func NewFakeClock() *FakeClock {
+	clock := &FakeClock{now: time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)}
+	clock.changed = sync.NewCond(&clock.mu)
+	return clock
+}
+
+// Now implements httpx.Clock
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// NewTimer implements httpx.Clock, the timer fires once the clock is advanced by d
+func (c *FakeClock) NewTimer(d time.Duration) httpx.Timer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	timer := &fakeTimer{clock: c, deadline: c.now.Add(d), ch: make(chan time.Time, 1)}
+	if d <= 0 {
+		timer.ch <- c.now
+		return timer
+	}
+	c.timers = append(c.timers, timer)
+	c.changed.Broadcast()
+	return timer
+}
+
+// Advance moves the clock forward by d, firing the timers due meanwhile in deadline order
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.advanceTo(c.now.Add(d))
+}
+
+// AdvanceToNext waits until a timer is pending, then moves the clock to its deadline and fires it
+// It returns how far the clock moved, i.e. the remaining delay of the timer, such as a retry backoff.
+func (c *FakeClock) AdvanceToNext() time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for len(c.timers) == 0 {
+		c.changed.Wait()
+	}
+	next := slices.MinFunc(c.timers, func(a, b *fakeTimer) int { return a.deadline.Compare(b.deadline) }).deadline
+	delay := next.Sub(c.now)
+	c.advanceTo(next)
+	return delay
+}
+
+// BlockUntil waits until at least n timers are pending, e.g. until a request is waiting for its retry
+func (c *FakeClock) BlockUntil(n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for len(c.timers) < n {
+		c.changed.Wait()
+	}
+}
+
+// Pending returns the remaining delays of the pending timers, shortest first
+func (c *FakeClock) Pending() []time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delays := make([]time.Duration, 0, len(c.timers))
+	for _, timer := range c.timers {
+		delays = append(delays, timer.deadline.Sub(c.now))
+	}
+	slices.Sort(delays)
+	return delays
+}
+
+// advanceTo sets the clock to now, firing the due timers, c.mu must be held
+func (c *FakeClock) advanceTo(now time.Time) {
+	if now.After(c.now) {
+		c.now = now
+	}
+	slices.SortStableFunc(c.timers, func(a, b *fakeTimer) int { return a.deadline.Compare(b.deadline) })
+	fired := 0
+	for _, timer := range c.timers {
+		if timer.deadline.After(c.now) {
+			break
+		}
+		timer.ch <- c.now
+		fired++
+	}
+	c.timers = slices.Delete(c.timers, 0, fired)
+}
+
+// C implements httpx.Timer
+func (t *fakeTimer) C() <-chan time.Time {
+	return t.ch
+}
+
+// Stop implements httpx.Timer
+func (t *fakeTimer) Stop() bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	index := slices.Index(t.clock.timers, t)
+	if index < 0 {
+		return false
+	}
+	t.clock.timers = slices.Delete(t.clock.timers, index, index+1)
+	return true
+}
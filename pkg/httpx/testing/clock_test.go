@@ -0,0 +1,99 @@
+package testing_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bdpiprava/easy-http/pkg/httpx"
+	httpxtesting "github.com/bdpiprava/easy-http/pkg/httpx/testing"
+)
+
+func TestFakeClock(t *testing.T) {
+	t.Parallel()
+
+	t.Run("fires timers in deadline order when advanced", func(t *testing.T) {
+		t.Parallel()
+		clock := httpxtesting.NewFakeClock()
+		start := clock.Now()
+		late, early, stopped := clock.NewTimer(2*time.Second), clock.NewTimer(time.Second), clock.NewTimer(time.Second)
+
+		assert.True(t, stopped.Stop())
+		assert.Equal(t, []time.Duration{time.Second, 2 * time.Second}, clock.Pending())
+		clock.Advance(1500 * time.Millisecond)
+
+		assert.Equal(t, start.Add(1500*time.Millisecond), <-early.C())
+		assert.Empty(t, late.C())
+		assert.Equal(t, 500*time.Millisecond, clock.AdvanceToNext())
+		assert.Equal(t, start.Add(2*time.Second), <-late.C())
+		assert.False(t, late.Stop())
+		assert.Empty(t, clock.Pending())
+	})
+
+	t.Run("schedules retry backoffs on the clock", func(t *testing.T) {
+		t.Parallel()
+		var calls atomic.Int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			if calls.Add(1) < 3 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+			}
+		}))
+		defer server.Close()
+		policy := httpx.DefaultRetryPolicy()
+		policy.BaseDelay, policy.Strategy = time.Hour, httpx.RetryStrategyExponential
+		policy.MaxDelay = 10 * time.Hour
+		clock := httpxtesting.NewFakeClock()
+		client := httpx.NewClientWithConfig(
+			httpx.WithClientDefaultBaseURL(server.URL),
+			httpx.WithClientRetryPolicy(policy),
+			httpx.WithClientClock(clock),
+		)
+		done := make(chan *httpx.Response)
+		go func() {
+			resp, _ := client.Execute(*httpx.NewRequest(http.MethodGet), "")
+			done <- resp
+		}()
+
+		assert.Equal(t, time.Hour, clock.AdvanceToNext())
+		assert.Equal(t, 2*time.Hour, clock.AdvanceToNext())
+		resp := <-done
+
+		require.NotNil(t, resp)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, int32(3), calls.Load())
+	})
+
+	t.Run("moves open circuit breakers to half-open after the timeout", func(t *testing.T) {
+		t.Parallel()
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusInternalServerError)
+		}))
+		defer server.Close()
+		config := httpx.DefaultCircuitBreakerConfig()
+		config.Timeout = time.Minute
+		config.ReadyToTrip = func(counts httpx.Counts) bool { return counts.ConsecutiveFailures >= 1 }
+		clock := httpxtesting.NewFakeClock()
+		client := httpx.NewClientWithConfig(
+			httpx.WithClientDefaultBaseURL(server.URL),
+			httpx.WithClientCircuitBreaker(config),
+			httpx.WithClientClock(clock),
+		)
+		execute := func() error {
+			_, err := client.Execute(*httpx.NewRequest(http.MethodGet), "")
+			return err
+		}
+
+		require.NoError(t, execute())
+		require.ErrorIs(t, execute(), httpx.ErrCircuitOpen)
+		clock.Advance(59 * time.Second)
+		require.ErrorIs(t, execute(), httpx.ErrCircuitOpen)
+		clock.Advance(2 * time.Second)
+
+		assert.NoError(t, execute())
+	})
+}
@@ -0,0 +1,183 @@
+package testing
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+// GraphQL Matchers
+
+// graphQLOperationPattern finds the name of the first named operation of a GraphQL document
+var graphQLOperationPattern = regexp.MustCompile(`\b(?:query|mutation|subscription)\s+([_A-Za-z][_0-9A-Za-z]*)`)
+
+// graphQLRequest is a GraphQL request, sent as a JSON body or as GET query parameters
+type graphQLRequest struct {
+	Query         string         `json:"query"`
+	OperationName string         `json:"operationName"`
+	Variables     map[string]any `json:"variables"`
+}
+
+// parseGraphQLRequest decodes the GraphQL request of req, leaving its body readable by other matchers
+// The operation name falls back to the name of the first operation of the document when the request has none.
+func parseGraphQLRequest(req *http.Request) (*graphQLRequest, bool) {
+	var gql graphQLRequest
+	if req.Method == http.MethodGet {
+		query := req.URL.Query()
+		gql.Query = query.Get("query")
+		gql.OperationName = query.Get("operationName")
+		if variables := query.Get("variables"); variables != "" {
+			if err := json.Unmarshal([]byte(variables), &gql.Variables); err != nil {
+				return nil, false
+			}
+		}
+	} else {
+		if req.Body == nil {
+			return nil, false
+		}
+		body, err := io.ReadAll(req.Body)
+		req.Body.Close()
+		req.Body = io.NopCloser(bytes.NewReader(body))
+		if err != nil || json.Unmarshal(body, &gql) != nil {
+			return nil, false
+		}
+	}
+	if gql.Query == "" {
+		return nil, false
+	}
+	if gql.OperationName == "" {
+		if match := graphQLOperationPattern.FindStringSubmatch(gql.Query); match != nil {
+			gql.OperationName = match[1]
+		}
+	}
+	return &gql, true
+}
+
+// operationNameMatcher matches GraphQL requests by operation name
+type operationNameMatcher struct {
+	name string
+}
+
+// OperationName creates a matcher for GraphQL requests of the named operation
+// The operationName of the request is used, or the name of the operation in the query when it is not sent.
+func OperationName(name string) RequestMatcher {
+	return &operationNameMatcher{name: name}
+}
+
+func (m *operationNameMatcher) Matches(req *http.Request) bool {
+	gql, ok := parseGraphQLRequest(req)
+	return ok && gql.OperationName == m.name
+}
+
+func (m *operationNameMatcher) String() string {
+	return fmt.Sprintf("graphql.operationName=%s", m.name)
+}
+
+// queryContainsMatcher matches GraphQL requests whose query contains a substring
+type queryContainsMatcher struct {
+	substring string
+}
+
+// QueryContains creates a matcher for GraphQL requests whose query contains substring, e.g. a field name
+func QueryContains(substring string) RequestMatcher {
+	return &queryContainsMatcher{substring: substring}
+}
+
+func (m *queryContainsMatcher) Matches(req *http.Request) bool {
+	gql, ok := parseGraphQLRequest(req)
+	return ok && strings.Contains(gql.Query, m.substring)
+}
+
+func (m *queryContainsMatcher) String() string {
+	return fmt.Sprintf("graphql.query~%q", m.substring)
+}
+
+// variableMatcher matches GraphQL requests with a specific variable value
+type variableMatcher struct {
+	name  string
+	value any
+}
+
+// HasVariable creates a matcher for GraphQL requests with a variable equal to value
+// Values are compared in their JSON form, so HasVariable("id", 42) matches {"id": 42} and structs match the
+// objects they marshal to.
+func HasVariable(name string, value any) RequestMatcher {
+	normalized, err := normalizeJSON(value)
+	if err != nil {
+		return &alwaysFalseMatcher{reason: fmt.Sprintf("invalid variable %s: %v", name, err)}
+	}
+	return &variableMatcher{name: name, value: normalized}
+}
+
+func (m *variableMatcher) Matches(req *http.Request) bool {
+	gql, ok := parseGraphQLRequest(req)
+	if !ok {
+		return false
+	}
+	value, exists := gql.Variables[m.name]
+	return exists && reflect.DeepEqual(value, m.value)
+}
+
+func (m *variableMatcher) String() string {
+	return fmt.Sprintf("graphql.variables[%s]=%v", m.name, m.value)
+}
+
+// normalizeJSON returns value as decoded from its JSON form
+func normalizeJSON(value any) (any, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	var normalized any
+	return normalized, json.Unmarshal(data, &normalized)
+}
+
+// OnGraphQL registers a mock response for the named GraphQL operation sent to the specified path
+func (m *MockServer) OnGraphQL(path, operationName string) *ResponseBuilder {
+	return m.On(ExactPath(path), OperationName(operationName))
+}
+
+// GraphQL Responses
+
+// GraphQLError is an error of a GraphQL response
+type GraphQLError struct {
+	Message    string         `json:"message"`
+	Path       []any          `json:"path,omitempty"`
+	Extensions map[string]any `json:"extensions,omitempty"`
+}
+
+// graphQLResponse is the body of a GraphQL response
+type graphQLResponse struct {
+	Data   any            `json:"data,omitempty"`
+	Errors []GraphQLError `json:"errors,omitempty"`
+}
+
+// WithGraphQLData sets the response body to a GraphQL response with data, keeping errors set by WithGraphQLErrors
+func (rb *ResponseBuilder) WithGraphQLData(data any) *ResponseBuilder {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+	if rb.graphQL == nil {
+		rb.graphQL = &graphQLResponse{}
+	}
+	rb.graphQL.Data = data
+	rb.setJSON(rb.graphQL)
+	return rb
+}
+
+// WithGraphQLErrors adds errors to the GraphQL response, keeping data set by WithGraphQLData for partial results
+// The status stays 200 OK as GraphQL servers answer with errors in the body.
+func (rb *ResponseBuilder) WithGraphQLErrors(errors ...GraphQLError) *ResponseBuilder {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+	if rb.graphQL == nil {
+		rb.graphQL = &graphQLResponse{}
+	}
+	rb.graphQL.Errors = append(rb.graphQL.Errors, errors...)
+	rb.setJSON(rb.graphQL)
+	return rb
+}
@@ -0,0 +1,113 @@
+package testing_test
+
+import (
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	httpxtesting "github.com/bdpiprava/easy-http/pkg/httpx/testing"
+)
+
+func TestGraphQLMatchers(t *testing.T) {
+	t.Parallel()
+
+	post := func(t *testing.T, server *httpxtesting.MockServer, body string) (int, string) {
+		resp, err := http.Post(server.URL()+"/graphql", "application/json", strings.NewReader(body))
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		data, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return resp.StatusCode, string(data)
+	}
+
+	t.Run("matches operations by name, query and variables", func(t *testing.T) {
+		t.Parallel()
+
+		server := httpxtesting.NewMockServer()
+		defer server.Close()
+		server.On(httpxtesting.OperationName("GetUser"), httpxtesting.HasVariable("id", 2)).
+			WithGraphQLData(map[string]any{"user": map[string]any{"name": "Bob"}})
+		server.OnGraphQL("/graphql", "GetUser").
+			WithGraphQLData(map[string]any{"user": map[string]any{"name": "Alice"}})
+		server.On(httpxtesting.QueryContains("orders")).
+			WithGraphQLData(map[string]any{"orders": []any{}})
+
+		testCases := []struct {
+			name     string
+			body     string
+			expected string
+		}{
+			{
+				name:     "operation name and variable",
+				body:     `{"query":"query GetUser($id: ID!) { user(id: $id) { name } }","operationName":"GetUser","variables":{"id":2}}`,
+				expected: `{"data":{"user":{"name":"Bob"}}}`,
+			},
+			{
+				name:     "operation name from the query",
+				body:     `{"query":"query GetUser($id: ID!) { user(id: $id) { name } }","variables":{"id":1}}`,
+				expected: `{"data":{"user":{"name":"Alice"}}}`,
+			},
+			{
+				name:     "query substring",
+				body:     `{"query":"{ orders { id } }"}`,
+				expected: `{"data":{"orders":[]}}`,
+			},
+		}
+		for _, tc := range testCases {
+			t.Run(tc.name, func(t *testing.T) {
+				status, body := post(t, server, tc.body)
+
+				assert.Equal(t, http.StatusOK, status)
+				assert.JSONEq(t, tc.expected, body)
+			})
+		}
+
+		status, _ := post(t, server, `{"query":"mutation DeleteUser { deleteUser }"}`)
+		assert.Equal(t, http.StatusNotFound, status)
+		recorded := server.Requests()
+		assert.Contains(t, string(recorded[len(recorded)-1].Body), "DeleteUser")
+	})
+
+	t.Run("matches GET requests with query parameters", func(t *testing.T) {
+		t.Parallel()
+
+		server := httpxtesting.NewMockServer()
+		defer server.Close()
+		server.On(httpxtesting.OperationName("GetUser"), httpxtesting.HasVariable("filter", map[string]any{"active": true})).
+			WithGraphQLData(map[string]any{"user": nil})
+
+		query := url.Values{
+			"query":     {"query GetUser($filter: Filter) { user(filter: $filter) { id } }"},
+			"variables": {`{"filter":{"active":true}}`},
+		}
+		resp, err := http.Get(server.URL() + "/graphql?" + query.Encode())
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	})
+
+	t.Run("builds responses with partial data and errors", func(t *testing.T) {
+		t.Parallel()
+
+		server := httpxtesting.NewMockServer()
+		defer server.Close()
+		server.OnGraphQL("/graphql", "GetUser").
+			WithGraphQLData(map[string]any{"user": nil}).
+			WithGraphQLErrors(httpxtesting.GraphQLError{
+				Message:    "user not found",
+				Path:       []any{"user"},
+				Extensions: map[string]any{"code": "NOT_FOUND"},
+			})
+
+		status, body := post(t, server, `{"query":"query GetUser { user { id } }"}`)
+
+		assert.Equal(t, http.StatusOK, status)
+		assert.JSONEq(t, `{"data":{"user":null},"errors":[{"message":"user not found","path":["user"],"extensions":{"code":"NOT_FOUND"}}]}`, body)
+	})
+}
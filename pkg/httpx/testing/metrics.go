@@ -0,0 +1,14 @@
+package testing
+
+import (
+	"github.com/bdpiprava/easy-http/pkg/httpx"
+)
+
+// CaptureMetrics runs fn and returns the requests, retries, cache hits and other activity the client counted
+// meanwhile, e.g. to assert that exactly one retry happened. Requests sent concurrently by other goroutines
+// with the same client are counted too.
+func CaptureMetrics(client *httpx.Client, fn func()) httpx.ClientMetrics {
+	before := client.Metrics()
+	fn()
+	return client.Metrics().Sub(before)
+}
@@ -0,0 +1,74 @@
+package testing_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bdpiprava/easy-http/pkg/httpx"
+	httpxtesting "github.com/bdpiprava/easy-http/pkg/httpx/testing"
+)
+
+func TestCaptureMetrics(t *testing.T) {
+	t.Parallel()
+
+	t.Run("counts requests, attempts and retries during the closure only", func(t *testing.T) {
+		t.Parallel()
+		var calls atomic.Int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/flaky" && calls.Add(1) == 1 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+			}
+		}))
+		defer server.Close()
+		policy := httpx.DefaultRetryPolicy()
+		policy.BaseDelay, policy.Strategy = time.Millisecond, httpx.RetryStrategyFixed
+		client := httpx.NewClientWithConfig(
+			httpx.WithClientDefaultBaseURL(server.URL),
+			httpx.WithClientRetryPolicy(policy),
+		)
+		_, err := client.Execute(*httpx.NewRequest(http.MethodGet, httpx.WithPath("/ok")), "")
+		require.NoError(t, err)
+
+		metrics := httpxtesting.CaptureMetrics(client, func() {
+			_, err := client.Execute(*httpx.NewRequest(http.MethodGet, httpx.WithPath("/flaky")), "")
+			require.NoError(t, err)
+		})
+
+		assert.Equal(t, httpx.ClientMetrics{Requests: 1, Attempts: 2, Retries: 1}, metrics)
+	})
+
+	t.Run("counts cache hits and errors of concurrent requests", func(t *testing.T) {
+		t.Parallel()
+		mock := httpxtesting.NewMockServer()
+		defer mock.Close()
+		mock.OnGet("/cached").WithStatus(http.StatusOK).WithHeader("Cache-Control", "max-age=60").WithBodyString("cached")
+		client := httpx.NewClientWithConfig(
+			httpx.WithClientDefaultBaseURL(mock.URL()),
+			httpx.WithClientCache(httpx.CacheConfig{Backend: httpx.NewInMemoryCache(10), DefaultTTL: time.Minute, RefreshAhead: 0.8}),
+		)
+		_, err := client.Execute(*httpx.NewRequest(http.MethodGet, httpx.WithPath("/cached")), "")
+		require.NoError(t, err)
+
+		metrics := httpxtesting.CaptureMetrics(client, func() {
+			var wg sync.WaitGroup
+			for range 5 {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					_, _ = client.Execute(*httpx.NewRequest(http.MethodGet, httpx.WithPath("/cached")), "")
+				}()
+			}
+			_, _ = client.Execute(*httpx.NewRequest("INVALID METHOD"), "")
+			wg.Wait()
+		})
+
+		assert.Equal(t, httpx.ClientMetrics{Requests: 6, Errors: 1, CacheHits: 5}, metrics)
+	})
+}
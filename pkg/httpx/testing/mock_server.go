@@ -1,12 +1,18 @@
 package testing
 
 import (
+	"bytes"
+	"compress/gzip"
 	"encoding/json"
 	"fmt"
 	"io"
+	"mime"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"regexp"
+	"strconv"
 	"strings"
 	"sync"
 )
@@ -17,6 +23,8 @@ type MockServer struct {
 	routes   []*Route
 	requests []*RecordedRequest
 	mu       sync.RWMutex
+
+	closeOnce sync.Once
 }
 
 // Route represents a single mock route configuration
@@ -50,6 +58,7 @@ func NewMockServer() *MockServer {
 	}
 
 	mock.server = httptest.NewServer(http.HandlerFunc(mock.handleRequest))
+	mock.register()
 	return mock
 }
 
@@ -61,6 +70,7 @@ func NewMockTLSServer() *MockServer {
 	}
 
 	mock.server = httptest.NewTLSServer(http.HandlerFunc(mock.handleRequest))
+	mock.register()
 	return mock
 }
 
@@ -71,9 +81,21 @@ func (m *MockServer) URL() string {
 
 // Close shuts down the mock server
 func (m *MockServer) Close() {
+	m.closeOnce.Do(func() {
+		networkGuard.mu.Lock()
+		denyHosts(serverHost(m.server.URL))
+		networkGuard.mu.Unlock()
+	})
 	m.server.Close()
 }
 
+// register makes the mock server reachable by clients guarded by DenyExternalNetwork
+func (m *MockServer) register() {
+	networkGuard.mu.Lock()
+	defer networkGuard.mu.Unlock()
+	allowHosts(serverHost(m.server.URL))
+}
+
 // OnGet registers a mock response for GET requests to the specified path
 func (m *MockServer) OnGet(path string) *ResponseBuilder {
 	return m.On(MethodIs("GET"), ExactPath(path))
@@ -212,6 +234,8 @@ func (m *MockServer) handleRequest(w http.ResponseWriter, r *http.Request) {
 func (m *MockServer) recordRequest(r *http.Request) *RecordedRequest {
 	body, _ := io.ReadAll(r.Body)
 	r.Body.Close()
+	// Matchers inspecting the body, such as the GraphQL ones, read it again
+	r.Body = io.NopCloser(bytes.NewReader(body))
 
 	// Create a copy of query params
 	queryParams := make(map[string][]string)
@@ -235,10 +259,19 @@ type ResponseBuilder struct {
 	statusCode int
 	headers    http.Header
 	body       []byte
+	bodyFile   string // File streamed as the body, set by WithBodyFromFile
 	delay      func()
+	graphQL    *graphQLResponse  // Response built by WithGraphQLData and WithGraphQLErrors
+	interim    []interimResponse // Informational responses sent before the response, see WithInformational
 	mu         sync.RWMutex
 }
 
+// interimResponse is a 1xx response sent before the final response
+type interimResponse struct {
+	statusCode int
+	headers    http.Header
+}
+
 // NewResponseBuilder creates a new response builder with defaults
 func NewResponseBuilder() *ResponseBuilder {
 	return &ResponseBuilder{
@@ -279,7 +312,7 @@ func (rb *ResponseBuilder) WithHeaders(headers map[string]string) *ResponseBuild
 func (rb *ResponseBuilder) WithBody(body []byte) *ResponseBuilder {
 	rb.mu.Lock()
 	defer rb.mu.Unlock()
-	rb.body = body
+	rb.body, rb.bodyFile = body, ""
 	return rb
 }
 
@@ -287,7 +320,7 @@ func (rb *ResponseBuilder) WithBody(body []byte) *ResponseBuilder {
 func (rb *ResponseBuilder) WithBodyString(body string) *ResponseBuilder {
 	rb.mu.Lock()
 	defer rb.mu.Unlock()
-	rb.body = []byte(body)
+	rb.body, rb.bodyFile = []byte(body), ""
 	return rb
 }
 
@@ -295,17 +328,67 @@ func (rb *ResponseBuilder) WithBodyString(body string) *ResponseBuilder {
 func (rb *ResponseBuilder) WithJSON(data interface{}) *ResponseBuilder {
 	rb.mu.Lock()
 	defer rb.mu.Unlock()
+	rb.setJSON(data)
+	return rb
+}
 
+// setJSON sets the response body to data marshaled as JSON, the caller holding the lock
+func (rb *ResponseBuilder) setJSON(data interface{}) {
 	jsonBytes, err := json.Marshal(data)
 	if err != nil {
 		// Fallback to error response
-		rb.body = []byte(fmt.Sprintf(`{"error":"failed to marshal JSON: %v"}`, err))
+		rb.body, rb.bodyFile = []byte(fmt.Sprintf(`{"error":"failed to marshal JSON: %v"}`, err)), ""
 		rb.headers.Set("Content-Type", "application/json")
-		return rb
+		return
 	}
 
-	rb.body = jsonBytes
+	rb.body, rb.bodyFile = jsonBytes, ""
 	rb.headers.Set("Content-Type", "application/json")
+}
+
+// WithBodyFromFile streams the file at path as the response body, without loading it into memory
+// The file is opened on every request, Content-Length is set from its size and Content-Type from its
+// extension unless set with WithHeader. A file that cannot be opened is answered with 500 Internal Server Error.
+func (rb *ResponseBuilder) WithBodyFromFile(path string) *ResponseBuilder {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+	rb.body, rb.bodyFile = nil, path
+	return rb
+}
+
+// WithGzippedBody sets the response body to data compressed with gzip, with a Content-Encoding: gzip header
+func (rb *ResponseBuilder) WithGzippedBody(data []byte) *ResponseBuilder {
+	var compressed bytes.Buffer
+	writer := gzip.NewWriter(&compressed)
+	_, _ = writer.Write(data) // Writes to a bytes.Buffer do not fail
+	_ = writer.Close()
+
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+	rb.body, rb.bodyFile = compressed.Bytes(), ""
+	rb.headers.Set("Content-Encoding", "gzip")
+	return rb
+}
+
+// WithInformational sends an interim 1xx response with headers before the response, e.g. 102 Processing
+// Interim responses are sent in the order they were added, before the delay of WithDelay.
+func (rb *ResponseBuilder) WithInformational(statusCode int, headers map[string]string) *ResponseBuilder {
+	header := make(http.Header, len(headers))
+	for k, v := range headers {
+		header.Set(k, v)
+	}
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+	rb.interim = append(rb.interim, interimResponse{statusCode: statusCode, headers: header})
+	return rb
+}
+
+// WithEarlyHints sends a 103 Early Hints response announcing links before the response, e.g.
+// `</style.css>; rel=preload; as=style`
+func (rb *ResponseBuilder) WithEarlyHints(links ...string) *ResponseBuilder {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+	rb.interim = append(rb.interim, interimResponse{statusCode: http.StatusEarlyHints, headers: http.Header{"Link": links}})
 	return rb
 }
 
@@ -322,6 +405,17 @@ func (rb *ResponseBuilder) Write(w http.ResponseWriter) {
 	rb.mu.RLock()
 	defer rb.mu.RUnlock()
 
+	// Send interim responses, whose headers must not leak into the final response
+	for _, interim := range rb.interim {
+		for key, values := range interim.headers {
+			w.Header()[key] = values
+		}
+		w.WriteHeader(interim.statusCode)
+		for key := range interim.headers {
+			w.Header().Del(key)
+		}
+	}
+
 	// Apply delay if configured
 	if rb.delay != nil {
 		rb.delay()
@@ -334,6 +428,11 @@ func (rb *ResponseBuilder) Write(w http.ResponseWriter) {
 		}
 	}
 
+	if rb.bodyFile != "" {
+		rb.writeFile(w)
+		return
+	}
+
 	// Write status code
 	w.WriteHeader(rb.statusCode)
 
@@ -343,6 +442,30 @@ func (rb *ResponseBuilder) Write(w http.ResponseWriter) {
 	}
 }
 
+// writeFile writes the configured status code and streams the body file, the caller holding the read lock
+func (rb *ResponseBuilder) writeFile(w http.ResponseWriter) {
+	file, err := os.Open(rb.bodyFile)
+	var info os.FileInfo
+	if err == nil {
+		defer file.Close()
+		info, err = file.Stat()
+	}
+	if err != nil {
+		w.Header().Del("Content-Encoding")
+		http.Error(w, fmt.Sprintf("failed to open response body file: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Length", strconv.FormatInt(info.Size(), 10))
+	if w.Header().Get("Content-Type") == "" {
+		if contentType := mime.TypeByExtension(filepath.Ext(rb.bodyFile)); contentType != "" {
+			w.Header().Set("Content-Type", contentType)
+		}
+	}
+	w.WriteHeader(rb.statusCode)
+	_, _ = io.Copy(w, file)
+}
+
 // Request Matchers
 
 // exactPathMatcher matches requests with exact path
@@ -1,9 +1,13 @@
 package testing_test
 
 import (
+	"bytes"
+	"compress/gzip"
 	"encoding/json"
 	"io"
 	"net/http"
+	"os"
+	"path/filepath"
 	"strings"
 	"sync"
 	"testing"
@@ -585,6 +589,74 @@ func TestResponseBuilder_WithBody(t *testing.T) {
 	})
 }
 
+func TestResponseBuilder_WithBodyFromFile(t *testing.T) {
+	t.Parallel()
+
+	t.Run("streams the file with its length and content type", func(t *testing.T) {
+		t.Parallel()
+
+		content := bytes.Repeat([]byte("fixture "), 64*1024)
+		path := filepath.Join(t.TempDir(), "payload.json")
+		require.NoError(t, os.WriteFile(path, content, 0o600))
+
+		subject := httpxtesting.NewMockServer()
+		defer subject.Close()
+		subject.OnGet("/download").WithBodyFromFile(path)
+
+		resp, err := http.Get(subject.URL() + "/download")
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+
+		assert.Equal(t, content, body)
+		assert.Equal(t, int64(len(content)), resp.ContentLength)
+		assert.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+	})
+
+	t.Run("answers 500 when the file cannot be opened", func(t *testing.T) {
+		t.Parallel()
+
+		subject := httpxtesting.NewMockServer()
+		defer subject.Close()
+		subject.OnGet("/download").WithBodyFromFile(filepath.Join(t.TempDir(), "missing.bin"))
+
+		resp, err := http.Get(subject.URL() + "/download")
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+	})
+}
+
+func TestResponseBuilder_WithGzippedBody(t *testing.T) {
+	t.Parallel()
+
+	t.Run("compresses the body with a Content-Encoding header", func(t *testing.T) {
+		t.Parallel()
+
+		subject := httpxtesting.NewMockServer()
+		defer subject.Close()
+		subject.OnGet("/test").WithGzippedBody([]byte(`{"compressed":true}`))
+
+		req, err := http.NewRequest(http.MethodGet, subject.URL()+"/test", nil)
+		require.NoError(t, err)
+		// An explicit Accept-Encoding stops the transport from decompressing transparently
+		req.Header.Set("Accept-Encoding", "gzip")
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		assert.Equal(t, "gzip", resp.Header.Get("Content-Encoding"))
+		reader, err := gzip.NewReader(resp.Body)
+		require.NoError(t, err)
+		body, err := io.ReadAll(reader)
+		require.NoError(t, err)
+		assert.JSONEq(t, `{"compressed":true}`, string(body))
+	})
+}
+
 func TestResponseBuilder_WithBodyString(t *testing.T) {
 	t.Parallel()
 
@@ -0,0 +1,79 @@
+package testing
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"testing"
+
+	"github.com/bdpiprava/easy-http/pkg/httpx"
+)
+
+// networkGuard tracks the hosts reachable while DenyExternalNetwork is in effect
+var networkGuard = struct {
+	mu      sync.Mutex
+	hosts   map[string]int // Reference count of the reachable hosts
+	users   int            // Number of tests denying external network
+	restore func()
+}{hosts: make(map[string]int)}
+
+// DenyExternalNetwork fails every request sent by an httpx client during the test unless it is directed at a
+// running mock server or one of allowedHosts ("host:port", e.g. of an httptest server), catching tests that
+// forgot to set a base URL and would reach real endpoints. The guard applies to all clients of the process and
+// is removed once every test that installed it completed.
+func DenyExternalNetwork(t testing.TB, allowedHosts ...string) {
+	t.Helper()
+	networkGuard.mu.Lock()
+	defer networkGuard.mu.Unlock()
+	if networkGuard.users == 0 {
+		networkGuard.restore = httpx.SetNetworkGuard(guardRequest)
+	}
+	networkGuard.users++
+	allowHosts(allowedHosts...)
+
+	t.Cleanup(func() {
+		networkGuard.mu.Lock()
+		defer networkGuard.mu.Unlock()
+		denyHosts(allowedHosts...)
+		networkGuard.users--
+		if networkGuard.users == 0 {
+			networkGuard.restore()
+		}
+	})
+}
+
+// guardRequest fails requests to hosts other than mock servers and allowed hosts
+func guardRequest(req *http.Request) error {
+	networkGuard.mu.Lock()
+	defer networkGuard.mu.Unlock()
+	if networkGuard.hosts[req.URL.Host] > 0 {
+		return nil
+	}
+	return fmt.Errorf("httpxtesting: request to %s blocked, only mock servers are reachable (is the base URL set?)", req.URL.Redacted())
+}
+
+// allowHosts makes hosts reachable, networkGuard.mu must be held
+func allowHosts(hosts ...string) {
+	for _, host := range hosts {
+		networkGuard.hosts[host]++
+	}
+}
+
+// denyHosts reverts allowHosts, networkGuard.mu must be held
+func denyHosts(hosts ...string) {
+	for _, host := range hosts {
+		if networkGuard.hosts[host]--; networkGuard.hosts[host] <= 0 {
+			delete(networkGuard.hosts, host)
+		}
+	}
+}
+
+// serverHost returns the host:port of a server URL
+func serverHost(serverURL string) string {
+	parsed, err := url.Parse(serverURL)
+	if err != nil {
+		return ""
+	}
+	return parsed.Host
+}
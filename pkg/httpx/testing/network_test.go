@@ -0,0 +1,63 @@
+package testing_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bdpiprava/easy-http/pkg/httpx"
+	httpxtesting "github.com/bdpiprava/easy-http/pkg/httpx/testing"
+)
+
+// DenyExternalNetwork guards all clients of the process, so these tests must not run in parallel
+func TestDenyExternalNetwork(t *testing.T) {
+	var external atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		external.Add(1)
+	}))
+	defer server.Close()
+	get := func(baseURL string) error {
+		client := httpx.NewClientWithConfig(httpx.WithClientDefaultBaseURL(baseURL))
+		_, err := client.Execute(*httpx.NewRequest(http.MethodGet, httpx.WithPath("/test")), "")
+		return err
+	}
+
+	t.Run("allows requests to mock servers and blocks others", func(t *testing.T) {
+		httpxtesting.DenyExternalNetwork(t)
+		mock := httpxtesting.NewMockServer()
+		defer mock.Close()
+		mock.OnGet("/test").WithStatus(http.StatusOK)
+
+		require.NoError(t, get(mock.URL()))
+		err := get(server.URL)
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "only mock servers are reachable")
+		assert.Equal(t, int32(0), external.Load())
+	})
+
+	t.Run("allows the given hosts", func(t *testing.T) {
+		httpxtesting.DenyExternalNetwork(t, strings.TrimPrefix(server.URL, "http://"))
+
+		require.NoError(t, get(server.URL))
+		assert.Equal(t, int32(1), external.Load())
+	})
+
+	t.Run("blocks mock servers once closed", func(t *testing.T) {
+		httpxtesting.DenyExternalNetwork(t)
+		mock := httpxtesting.NewMockServer()
+		mock.Close()
+
+		assert.ErrorContains(t, get(mock.URL()), "blocked")
+	})
+
+	t.Run("removes the guard after the test", func(t *testing.T) {
+		require.NoError(t, get(server.URL))
+		assert.Equal(t, int32(2), external.Load())
+	})
+}
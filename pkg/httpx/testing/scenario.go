@@ -0,0 +1,194 @@
+package testing
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+
+	"github.com/bdpiprava/easy-http/pkg/httpx"
+)
+
+// Step is a scripted server behavior answering a number of consecutive requests of a Scenario
+type Step struct {
+	Times   int               // Requests answered by the step, the last step answers all remaining requests
+	Status  int               // Status code of the responses (default: 200)
+	Delay   time.Duration     // Delay before responding
+	Body    string            // Body of the responses
+	Headers map[string]string // Headers of the responses
+}
+
+// Fail returns a step answering n requests with status
+func Fail(n, status int) Step {
+	return Step{Times: n, Status: status}
+}
+
+// Slow returns a step answering n requests successfully after delay
+func Slow(n int, delay time.Duration) Step {
+	return Step{Times: n, Status: http.StatusOK, Delay: delay}
+}
+
+// Recover returns a step answering requests successfully, ending a scenario
+func Recover() Step {
+	return Step{Times: 1, Status: http.StatusOK}
+}
+
+// ScenarioAttempt is a request received by the scenario server
+type ScenarioAttempt struct {
+	Offset time.Duration // Time since the run started
+	Step   int           // Index of the step that answered
+	Status int           // Status code answered
+}
+
+// ScenarioOutcome is the result of a request executed by Scenario.Run
+type ScenarioOutcome struct {
+	StatusCode int   // Status code of the response, 0 if the request failed without one
+	Err        error // Error returned by the client
+}
+
+// ScenarioResult is the full observable outcome of a scenario run
+type ScenarioResult struct {
+	Outcomes    []ScenarioOutcome           // Outcome of each request, in order
+	Attempts    []ScenarioAttempt           // Requests received by the server, retries included
+	Transitions []httpx.CircuitBreakerEvent // Circuit breaker state changes, rejections excluded
+	Events      []httpx.Event               // All events of the client, empty unless WithClientEvents is set
+	Metrics     httpx.ClientMetrics         // Client activity during the run
+}
+
+// AttemptStatuses returns the status codes answered by the server, in order
+func (r ScenarioResult) AttemptStatuses() []int {
+	statuses := make([]int, len(r.Attempts))
+	for i, attempt := range r.Attempts {
+		statuses[i] = attempt.Status
+	}
+	return statuses
+}
+
+// StatusCodes returns the status code of each request outcome, 0 for requests that failed without a response
+func (r ScenarioResult) StatusCodes() []int {
+	statuses := make([]int, len(r.Outcomes))
+	for i, outcome := range r.Outcomes {
+		statuses[i] = outcome.StatusCode
+	}
+	return statuses
+}
+
+// BreakerStates returns the states the circuit breakers moved to, in order
+func (r ScenarioResult) BreakerStates() []httpx.CircuitBreakerState {
+	states := make([]httpx.CircuitBreakerState, len(r.Transitions))
+	for i, transition := range r.Transitions {
+		states[i] = transition.To
+	}
+	return states
+}
+
+// Scenario drives a client against a server scripted as a sequence of steps, e.g. fail twice, answer slowly,
+// then recover, to test resilience configurations end to end:
+//
+//	scenario := httpxtesting.NewScenario(httpxtesting.Fail(2, 503), httpxtesting.Recover())
+//	defer scenario.Close()
+//	client := httpx.NewClientWithConfig(httpx.WithClientDefaultBaseURL(scenario.URL()), httpx.WithClientEvents(0), ...)
+//	result := scenario.Run(client, *httpx.NewRequest(http.MethodGet), 1)
+//	assert.Equal(t, []int{503, 503, 200}, result.AttemptStatuses())
+//
+// Enable events with httpx.WithClientEvents to observe circuit breaker transitions.
+type Scenario struct {
+	server *httptest.Server
+	steps  []Step
+
+	mu       sync.Mutex
+	step     int // Index of the current step
+	served   int // Requests answered by the current step
+	started  time.Time
+	attempts []ScenarioAttempt // Requests received during the current run
+}
+
+// NewScenario starts a server answering requests with the given steps, in order
+func NewScenario(steps ...Step) *Scenario {
+	if len(steps) == 0 {
+		steps = []Step{Recover()}
+	}
+	scenario := &Scenario{steps: steps}
+	scenario.server = httptest.NewServer(http.HandlerFunc(scenario.handle))
+	return scenario
+}
+
+// URL returns the base URL of the scenario server
+func (s *Scenario) URL() string {
+	return s.server.URL
+}
+
+// Close shuts down the scenario server
+func (s *Scenario) Close() {
+	s.server.Close()
+}
+
+// Run executes req n times in sequence with client, which must send its requests to the scenario server, and
+// returns what happened meanwhile. The script continues where the previous run stopped.
+func (s *Scenario) Run(client *httpx.Client, req httpx.Request, n int) ScenarioResult {
+	s.mu.Lock()
+	s.started, s.attempts = time.Now(), nil
+	s.mu.Unlock()
+
+	var result ScenarioResult
+	result.Metrics = CaptureMetrics(client, func() {
+		for range n {
+			resp, err := client.Execute(req, "")
+			outcome := ScenarioOutcome{Err: err}
+			if resp != nil {
+				outcome.StatusCode = resp.StatusCode
+			}
+			result.Outcomes = append(result.Outcomes, outcome)
+			// Drain events after each request so the buffer never drops any
+			result.Events = append(result.Events, drainEvents(client)...)
+		}
+	})
+	for _, event := range result.Events {
+		if transition, ok := event.(httpx.CircuitBreakerEvent); ok && !transition.Rejected {
+			result.Transitions = append(result.Transitions, transition)
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	result.Attempts = append([]ScenarioAttempt(nil), s.attempts...)
+	return result
+}
+
+// handle answers a request with the current step
+func (s *Scenario) handle(w http.ResponseWriter, _ *http.Request) {
+	s.mu.Lock()
+	index := s.step
+	step := s.steps[index]
+	if s.served++; s.served >= max(step.Times, 1) && s.step < len(s.steps)-1 {
+		s.step, s.served = s.step+1, 0
+	}
+	status := step.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	s.attempts = append(s.attempts, ScenarioAttempt{Offset: time.Since(s.started), Step: index, Status: status})
+	s.mu.Unlock()
+
+	if step.Delay > 0 {
+		time.Sleep(step.Delay)
+	}
+	for key, value := range step.Headers {
+		w.Header().Set(key, value)
+	}
+	w.WriteHeader(status)
+	_, _ = w.Write([]byte(step.Body))
+}
+
+// drainEvents returns the events buffered by the client
+func drainEvents(client *httpx.Client) []httpx.Event {
+	var events []httpx.Event
+	for {
+		select {
+		case event := <-client.Events():
+			events = append(events, event)
+		default:
+			return events
+		}
+	}
+}
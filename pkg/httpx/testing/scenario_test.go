@@ -0,0 +1,73 @@
+package testing_test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bdpiprava/easy-http/pkg/httpx"
+	httpxtesting "github.com/bdpiprava/easy-http/pkg/httpx/testing"
+)
+
+func TestScenario(t *testing.T) {
+	t.Parallel()
+
+	t.Run("records retried attempts until the server recovers", func(t *testing.T) {
+		t.Parallel()
+		scenario := httpxtesting.NewScenario(httpxtesting.Fail(2, http.StatusServiceUnavailable), httpxtesting.Recover())
+		defer scenario.Close()
+		policy := httpx.DefaultRetryPolicy()
+		policy.BaseDelay, policy.Strategy = time.Millisecond, httpx.RetryStrategyFixed
+		client := httpx.NewClientWithConfig(httpx.WithClientDefaultBaseURL(scenario.URL()), httpx.WithClientRetryPolicy(policy))
+
+		result := scenario.Run(client, *httpx.NewRequest(http.MethodGet), 2)
+
+		assert.Equal(t, []int{503, 503, 200, 200}, result.AttemptStatuses())
+		assert.Equal(t, 1, result.Attempts[2].Step)
+		assert.Equal(t, []int{200, 200}, result.StatusCodes())
+		assert.Equal(t, httpx.ClientMetrics{Requests: 2, Attempts: 4, Retries: 2}, result.Metrics)
+	})
+
+	t.Run("records circuit breaker transitions across runs", func(t *testing.T) {
+		t.Parallel()
+		scenario := httpxtesting.NewScenario(httpxtesting.Fail(3, http.StatusInternalServerError), httpxtesting.Recover())
+		defer scenario.Close()
+		config := httpx.DefaultCircuitBreakerConfig()
+		config.ReadyToTrip = func(counts httpx.Counts) bool { return counts.ConsecutiveFailures >= 3 }
+		clock := httpxtesting.NewFakeClock()
+		client := httpx.NewClientWithConfig(
+			httpx.WithClientDefaultBaseURL(scenario.URL()),
+			httpx.WithClientCircuitBreaker(config),
+			httpx.WithClientClock(clock),
+			httpx.WithClientEvents(0),
+		)
+
+		tripped := scenario.Run(client, *httpx.NewRequest(http.MethodGet), 4)
+		clock.Advance(config.Timeout + time.Second)
+		recovered := scenario.Run(client, *httpx.NewRequest(http.MethodGet), 1)
+
+		assert.Equal(t, []int{500, 500, 500, 0}, tripped.StatusCodes())
+		require.ErrorIs(t, tripped.Outcomes[3].Err, httpx.ErrCircuitOpen)
+		assert.Len(t, tripped.Attempts, 3)
+		assert.Equal(t, []httpx.CircuitBreakerState{httpx.StateOpen}, tripped.BreakerStates())
+		assert.Equal(t, []int{200}, recovered.AttemptStatuses())
+		assert.Equal(t, []httpx.CircuitBreakerState{httpx.StateHalfOpen, httpx.StateClosed}, recovered.BreakerStates())
+	})
+
+	t.Run("records timeouts of slow responses", func(t *testing.T) {
+		t.Parallel()
+		scenario := httpxtesting.NewScenario(httpxtesting.Slow(1, 200*time.Millisecond), httpxtesting.Recover())
+		defer scenario.Close()
+		client := httpx.NewClientWithConfig(httpx.WithClientDefaultBaseURL(scenario.URL()), httpx.WithClientTimeout(50*time.Millisecond))
+
+		result := scenario.Run(client, *httpx.NewRequest(http.MethodGet), 2)
+
+		require.ErrorIs(t, result.Outcomes[0].Err, httpx.ErrTimeout)
+		assert.NoError(t, result.Outcomes[1].Err)
+		assert.Equal(t, httpx.ClientMetrics{Requests: 2, Errors: 1, Attempts: 2}, result.Metrics)
+		assert.Greater(t, result.Attempts[1].Offset, result.Attempts[0].Offset)
+	})
+}
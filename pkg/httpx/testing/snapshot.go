@@ -0,0 +1,236 @@
+package testing
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/bdpiprava/easy-http/pkg/httpx"
+)
+
+// updateSnapshots rewrites golden files instead of comparing against them, also enabled by HTTPX_UPDATE_SNAPSHOTS=1
+var updateSnapshots = flag.Bool("update-snapshots", false, "rewrite httpxtesting snapshot golden files")
+
+// snapshotRedacted replaces redacted header and field values in snapshots
+const snapshotRedacted = "[REDACTED]"
+
+var (
+	// defaultIgnoredHeaders change on every response and are left out of snapshots
+	defaultIgnoredHeaders = []string{"Date", "Content-Length"}
+	// defaultRedactedHeaders carry credentials and are redacted in snapshots
+	defaultRedactedHeaders = []string{"Authorization", "Cookie", "Set-Cookie", "Proxy-Authorization"}
+)
+
+// snapshotCalls counts the snapshots taken by each test, naming the files of tests taking several
+var snapshotCalls = struct {
+	mu    sync.Mutex
+	names map[string]int
+}{names: make(map[string]int)}
+
+// SnapshotOption configures how Snapshot normalizes a response
+type SnapshotOption func(*snapshotConfig)
+
+// snapshotConfig holds the normalization applied by Snapshot
+type snapshotConfig struct {
+	name            string
+	dir             string
+	ignoredHeaders  []string
+	redactedHeaders []string
+	redactedFields  []string
+	replacements    []snapshotReplacement
+}
+
+// snapshotReplacement replaces the matches of a pattern in a snapshot
+type snapshotReplacement struct {
+	pattern     *regexp.Regexp
+	replacement string
+}
+
+// WithSnapshotName names the golden file instead of deriving it from the test name
+func WithSnapshotName(name string) SnapshotOption {
+	return func(c *snapshotConfig) {
+		c.name = name
+	}
+}
+
+// WithSnapshotDir sets the directory of golden files (default: testdata/snapshots)
+func WithSnapshotDir(dir string) SnapshotOption {
+	return func(c *snapshotConfig) {
+		c.dir = dir
+	}
+}
+
+// WithIgnoredHeaders leaves headers out of the snapshot, in addition to Date and Content-Length
+func WithIgnoredHeaders(names ...string) SnapshotOption {
+	return func(c *snapshotConfig) {
+		c.ignoredHeaders = append(c.ignoredHeaders, names...)
+	}
+}
+
+// WithRedactedHeaders redacts the values of headers, in addition to Authorization and cookies
+func WithRedactedHeaders(names ...string) SnapshotOption {
+	return func(c *snapshotConfig) {
+		c.redactedHeaders = append(c.redactedHeaders, names...)
+	}
+}
+
+// WithRedactedFields redacts the values of JSON body fields, at any depth and in any case, e.g. generated IDs
+func WithRedactedFields(fields ...string) SnapshotOption {
+	return func(c *snapshotConfig) {
+		c.redactedFields = append(c.redactedFields, fields...)
+	}
+}
+
+// WithReplacement replaces the matches of pattern in the serialized snapshot, e.g. timestamps embedded in text
+func WithReplacement(pattern *regexp.Regexp, replacement string) SnapshotOption {
+	return func(c *snapshotConfig) {
+		c.replacements = append(c.replacements, snapshotReplacement{pattern: pattern, replacement: replacement})
+	}
+}
+
+// Snapshot compares the status, headers and body of resp against a golden file, failing the test on any
+// difference. Bodies are normalized before comparison: JSON is indented with sorted keys, volatile headers
+// are left out and credentials are redacted. Run the tests with -update-snapshots, or HTTPX_UPDATE_SNAPSHOTS=1,
+// to write the golden files, which live under testdata/snapshots named after the test:
+//
+//	resp, err := client.Execute(*httpx.NewRequest(http.MethodGet, httpx.WithPath("/users/1")), nil)
+//	require.NoError(t, err)
+//	httpxtesting.Snapshot(t, resp, httpxtesting.WithRedactedFields("created_at"))
+func Snapshot(t testing.TB, resp *httpx.Response, opts ...SnapshotOption) {
+	t.Helper()
+	config := snapshotConfig{
+		dir:             filepath.Join("testdata", "snapshots"),
+		ignoredHeaders:  slices.Clone(defaultIgnoredHeaders),
+		redactedHeaders: slices.Clone(defaultRedactedHeaders),
+	}
+	for _, opt := range opts {
+		opt(&config)
+	}
+	if config.name == "" {
+		config.name = snapshotName(t.Name())
+	}
+
+	actual, err := serializeSnapshot(resp, config)
+	if err != nil {
+		t.Fatalf("httpxtesting: failed to serialize snapshot: %v", err)
+	}
+	file := filepath.Join(config.dir, config.name+".snap")
+
+	if *updateSnapshots || os.Getenv("HTTPX_UPDATE_SNAPSHOTS") == "1" {
+		if err := os.MkdirAll(config.dir, 0o755); err != nil {
+			t.Fatalf("httpxtesting: failed to create snapshot directory: %v", err)
+		}
+		if err := os.WriteFile(file, []byte(actual), 0o644); err != nil {
+			t.Fatalf("httpxtesting: failed to write snapshot: %v", err)
+		}
+		return
+	}
+
+	expected, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatalf("httpxtesting: failed to read snapshot %s (run with -update-snapshots to create it): %v", file, err)
+	}
+	if string(expected) != actual {
+		t.Errorf("httpxtesting: response does not match snapshot %s (run with -update-snapshots to accept it)\n--- expected\n%s\n--- actual\n%s",
+			file, expected, actual)
+	}
+}
+
+// snapshotName returns a file name for the next snapshot of a test, suffixed from the second snapshot on
+func snapshotName(testName string) string {
+	name := strings.Map(func(r rune) rune {
+		switch r {
+		case '/', '\\', ':', '*', '?', '"', '<', '>', '|', ' ':
+			return '_'
+		}
+		return r
+	}, testName)
+
+	snapshotCalls.mu.Lock()
+	defer snapshotCalls.mu.Unlock()
+	snapshotCalls.names[testName]++
+	if n := snapshotCalls.names[testName]; n > 1 {
+		return fmt.Sprintf("%s_%d", name, n)
+	}
+	return name
+}
+
+// serializeSnapshot writes the normalized status line, headers and body of resp
+func serializeSnapshot(resp *httpx.Response, config snapshotConfig) (string, error) {
+	var buf strings.Builder
+	fmt.Fprintf(&buf, "HTTP %s\n", resp.Status)
+
+	header := resp.Header()
+	names := make([]string, 0, len(header))
+	for name := range header {
+		if !containsHeader(config.ignoredHeaders, name) {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		for _, value := range header[name] {
+			if containsHeader(config.redactedHeaders, name) {
+				value = snapshotRedacted
+			}
+			fmt.Fprintf(&buf, "%s: %s\n", name, value)
+		}
+	}
+
+	body, err := normalizeSnapshotBody(resp.RawBody, config.redactedFields)
+	if err != nil {
+		return "", err
+	}
+	if len(body) > 0 {
+		buf.WriteString("\n")
+		buf.Write(body)
+		buf.WriteString("\n")
+	}
+
+	snapshot := buf.String()
+	for _, r := range config.replacements {
+		snapshot = r.pattern.ReplaceAllString(snapshot, r.replacement)
+	}
+	return snapshot, nil
+}
+
+// normalizeSnapshotBody redacts fields of JSON bodies and indents them with sorted keys; other bodies are kept
+func normalizeSnapshotBody(body []byte, redactedFields []string) ([]byte, error) {
+	if len(redactedFields) > 0 {
+		var err error
+		if body, err = httpx.RedactJSONFields(redactedFields...).Raw(nil, body); err != nil {
+			return nil, err
+		}
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var value any
+	if decoder.Decode(&value) != nil || decoder.More() {
+		return bytes.TrimRight(body, "\n"), nil
+	}
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(value); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+// containsHeader reports whether names contains name, ignoring case
+func containsHeader(names []string, name string) bool {
+	return slices.ContainsFunc(names, func(candidate string) bool {
+		return http.CanonicalHeaderKey(candidate) == http.CanonicalHeaderKey(name)
+	})
+}
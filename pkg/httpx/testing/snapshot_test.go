@@ -0,0 +1,92 @@
+package testing_test
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bdpiprava/easy-http/pkg/httpx"
+	httpxtesting "github.com/bdpiprava/easy-http/pkg/httpx/testing"
+)
+
+// recordingT records the failures of a test helper instead of failing the test
+type recordingT struct {
+	testing.TB
+	failures []string
+}
+
+func (r *recordingT) Helper() {}
+
+func (r *recordingT) Errorf(format string, args ...any) {
+	r.failures = append(r.failures, fmt.Sprintf(format, args...))
+}
+
+func (r *recordingT) Fatalf(format string, args ...any) {
+	r.failures = append(r.failures, fmt.Sprintf(format, args...))
+}
+
+func TestSnapshot(t *testing.T) {
+	mock := httpxtesting.NewMockServer()
+	defer mock.Close()
+	mock.OnGet("/users/1").
+		WithHeader("Set-Cookie", "session=secret").
+		WithHeader("X-Request-Id", "req-8f2c").
+		WithJSON(map[string]any{"name": "Jane", "id": "a1b2", "tags": []string{"<admin>"}})
+	client := httpx.NewClientWithConfig(httpx.WithClientDefaultBaseURL(mock.URL()))
+	resp, err := client.Execute(*httpx.NewRequest(http.MethodGet, httpx.WithPath("/users/1")), nil)
+	require.NoError(t, err)
+	opts := []httpxtesting.SnapshotOption{
+		httpxtesting.WithRedactedFields("id"),
+		httpxtesting.WithReplacement(regexp.MustCompile(`req-[0-9a-f]+`), "req-ID"),
+	}
+
+	t.Run("writes normalized golden files when updating", func(t *testing.T) {
+		dir := t.TempDir()
+		t.Setenv("HTTPX_UPDATE_SNAPSHOTS", "1")
+
+		httpxtesting.Snapshot(t, resp, append(opts, httpxtesting.WithSnapshotDir(dir))...)
+
+		golden, err := os.ReadFile(filepath.Join(dir, "TestSnapshot_writes_normalized_golden_files_when_updating.snap"))
+		require.NoError(t, err)
+		assert.Equal(t, `HTTP 200 OK
+Content-Type: application/json
+Set-Cookie: [REDACTED]
+X-Request-Id: req-ID
+
+{
+  "id": "[REDACTED]",
+  "name": "Jane",
+  "tags": [
+    "<admin>"
+  ]
+}
+`, string(golden))
+	})
+
+	t.Run("compares against golden files", func(t *testing.T) {
+		dir := t.TempDir()
+		t.Setenv("HTTPX_UPDATE_SNAPSHOTS", "1")
+		httpxtesting.Snapshot(t, resp, append(opts, httpxtesting.WithSnapshotDir(dir), httpxtesting.WithSnapshotName("user"))...)
+		t.Setenv("HTTPX_UPDATE_SNAPSHOTS", "")
+
+		matching := &recordingT{TB: t}
+		httpxtesting.Snapshot(matching, resp, append(opts, httpxtesting.WithSnapshotDir(dir), httpxtesting.WithSnapshotName("user"))...)
+		assert.Empty(t, matching.failures)
+
+		unredacted := &recordingT{TB: t}
+		httpxtesting.Snapshot(unredacted, resp, httpxtesting.WithSnapshotDir(dir), httpxtesting.WithSnapshotName("user"))
+		require.Len(t, unredacted.failures, 1)
+		assert.Contains(t, unredacted.failures[0], `"id": "a1b2"`)
+
+		missing := &recordingT{TB: t}
+		httpxtesting.Snapshot(missing, resp, httpxtesting.WithSnapshotDir(dir))
+		require.NotEmpty(t, missing.failures)
+		assert.Contains(t, missing.failures[0], "run with -update-snapshots")
+	})
+}
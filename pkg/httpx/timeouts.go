@@ -0,0 +1,194 @@
+package httpx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// TimeoutPhase identifies which phase of a request exceeded its timeout
+type TimeoutPhase string
+
+const (
+	// TimeoutPhaseDial indicates the TCP connection could not be established in time
+	TimeoutPhaseDial TimeoutPhase = "dial"
+	// TimeoutPhaseTLSHandshake indicates the TLS handshake did not complete in time
+	TimeoutPhaseTLSHandshake TimeoutPhase = "tls_handshake"
+	// TimeoutPhaseResponseHeader indicates the server did not send response headers in time
+	TimeoutPhaseResponseHeader TimeoutPhase = "response_header"
+	// TimeoutPhaseBodyRead indicates the response body was not read in time
+	TimeoutPhaseBodyRead TimeoutPhase = "body_read"
+	// TimeoutPhaseOverall indicates the overall client timeout or context deadline was exceeded
+	TimeoutPhaseOverall TimeoutPhase = "overall"
+)
+
+// TimeoutPhaseError is the cause of timeout HTTPErrors, recording which phase timed out
+type TimeoutPhaseError struct {
+	Phase TimeoutPhase  // Phase that exceeded its timeout
+	Limit time.Duration // Configured timeout for the phase (0 if unknown)
+	Cause error         // Underlying error
+}
+
+// Error implements the error interface
+func (e *TimeoutPhaseError) Error() string {
+	if e.Cause == nil {
+		return fmt.Sprintf("%s timeout", e.Phase)
+	}
+	return fmt.Sprintf("%s timeout: %v", e.Phase, e.Cause)
+}
+
+// Unwrap implements the unwrapper interface for error chains
+func (e *TimeoutPhaseError) Unwrap() error {
+	return e.Cause
+}
+
+// Is reports whether target is ErrTimeout
+func (e *TimeoutPhaseError) Is(target error) bool {
+	return target == ErrTimeout
+}
+
+// Timeout implements net.Error so the error is recognized as a timeout
+func (e *TimeoutPhaseError) Timeout() bool {
+	return true
+}
+
+// Temporary implements net.Error
+func (e *TimeoutPhaseError) Temporary() bool {
+	return true
+}
+
+// GetTimeoutPhase returns the phase that timed out, or an empty phase if the error is not a timeout
+func GetTimeoutPhase(err error) TimeoutPhase {
+	if err == nil {
+		return ""
+	}
+	phaseErr := &TimeoutPhaseError{}
+	if errors.As(err, &phaseErr) {
+		return phaseErr.Phase
+	}
+	if !isTimeoutError(err) {
+		return ""
+	}
+	return classifyTimeoutPhase(err)
+}
+
+// classifyTimeoutPhase determines the phase of a timeout error returned by net/http
+func classifyTimeoutPhase(err error) TimeoutPhase {
+	phaseErr := &TimeoutPhaseError{}
+	if errors.As(err, &phaseErr) {
+		return phaseErr.Phase
+	}
+
+	errStr := strings.ToLower(err.Error())
+	switch {
+	case strings.Contains(errStr, "tls handshake timeout"):
+		return TimeoutPhaseTLSHandshake
+	case strings.Contains(errStr, "timeout awaiting response headers"):
+		return TimeoutPhaseResponseHeader
+	case strings.Contains(errStr, "client.timeout"):
+		return TimeoutPhaseOverall
+	}
+
+	opErr := &net.OpError{}
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return TimeoutPhaseDial
+	}
+	return TimeoutPhaseOverall
+}
+
+// timeoutMessage returns the HTTPError message for a timeout phase
+func timeoutMessage(phase TimeoutPhase) string {
+	switch phase {
+	case TimeoutPhaseDial:
+		return "dial timeout"
+	case TimeoutPhaseTLSHandshake:
+		return "TLS handshake timeout"
+	case TimeoutPhaseResponseHeader:
+		return "response header timeout"
+	case TimeoutPhaseBodyRead:
+		return "body read timeout"
+	default:
+		return "request timeout"
+	}
+}
+
+// configureTimeouts applies the granular timeouts to the transport
+func configureTimeouts(transport *http.Transport, config *ClientConfig) {
+	if config.DialTimeout > 0 {
+		dial := transport.DialContext
+		if dial == nil {
+			dial = (&net.Dialer{}).DialContext
+		}
+		timeout := config.DialTimeout
+		transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+			dialCtx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+
+			conn, err := dial(dialCtx, network, addr)
+			if err != nil && ctx.Err() == nil && dialCtx.Err() != nil {
+				return nil, &TimeoutPhaseError{Phase: TimeoutPhaseDial, Limit: timeout, Cause: err}
+			}
+			return conn, err
+		}
+	}
+	if config.TLSHandshakeTimeout > 0 {
+		transport.TLSHandshakeTimeout = config.TLSHandshakeTimeout
+	}
+	if config.ResponseHeaderTimeout > 0 {
+		transport.ResponseHeaderTimeout = config.ResponseHeaderTimeout
+	}
+}
+
+// doWithBodyReadTimeout sends the request and cancels it if the response body is not fully read within timeout
+func doWithBodyReadTimeout(do func(*http.Request) (*http.Response, error), req *http.Request, timeout time.Duration) (*http.Response, error) {
+	ctx, cancel := context.WithCancel(req.Context())
+	resp, err := do(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return resp, err
+	}
+
+	body := &timeoutBody{ReadCloser: resp.Body, cancel: cancel, timeout: timeout}
+	body.timer = time.AfterFunc(timeout, func() {
+		body.timedOut.Store(true)
+		cancel()
+	})
+	resp.Body = body
+	return resp, nil
+}
+
+// timeoutBody reports reads failing after the body read timeout as TimeoutPhaseError
+type timeoutBody struct {
+	io.ReadCloser
+	cancel    context.CancelFunc
+	timer     *time.Timer
+	timeout   time.Duration
+	timedOut  atomic.Bool
+	closeOnce sync.Once
+}
+
+// Read implements io.Reader
+func (b *timeoutBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err != nil && err != io.EOF && b.timedOut.Load() {
+		return n, &TimeoutPhaseError{Phase: TimeoutPhaseBodyRead, Limit: b.timeout, Cause: err}
+	}
+	return n, err
+}
+
+// Close stops the timer and closes the underlying body
+func (b *timeoutBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.closeOnce.Do(func() {
+		b.timer.Stop()
+		b.cancel()
+	})
+	return err
+}
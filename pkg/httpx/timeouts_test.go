@@ -0,0 +1,137 @@
+package httpx_test
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bdpiprava/easy-http/pkg/httpx"
+)
+
+func TestTimeoutTaxonomy(t *testing.T) {
+	slowHeaders := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(time.Second):
+		case <-r.Context().Done():
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer slowHeaders.Close()
+
+	slowBody := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte(`{"partial":`))
+		w.(http.Flusher).Flush()
+		select {
+		case <-time.After(time.Second):
+		case <-r.Context().Done():
+		}
+	}))
+	defer slowBody.Close()
+
+	// Accepts TCP connections but never completes the TLS handshake
+	silent, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer silent.Close()
+	go func() {
+		for {
+			conn, err := silent.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	tests := []struct {
+		name        string
+		baseURL     string
+		opts        []httpx.ClientConfigOption
+		reqOpts     []httpx.RequestOption
+		wantPhase   httpx.TimeoutPhase
+		wantMessage string
+	}{
+		{
+			name:        "response header timeout",
+			baseURL:     slowHeaders.URL,
+			opts:        []httpx.ClientConfigOption{httpx.WithClientResponseHeaderTimeout(50 * time.Millisecond)},
+			wantPhase:   httpx.TimeoutPhaseResponseHeader,
+			wantMessage: "response header timeout",
+		},
+		{
+			name:        "body read timeout",
+			baseURL:     slowBody.URL,
+			opts:        []httpx.ClientConfigOption{httpx.WithClientBodyReadTimeout(50 * time.Millisecond)},
+			wantPhase:   httpx.TimeoutPhaseBodyRead,
+			wantMessage: "body read timeout",
+		},
+		{
+			name:        "TLS handshake timeout",
+			baseURL:     "https://" + silent.Addr().String(),
+			opts:        []httpx.ClientConfigOption{httpx.WithClientTLSHandshakeTimeout(50 * time.Millisecond)},
+			wantPhase:   httpx.TimeoutPhaseTLSHandshake,
+			wantMessage: "TLS handshake timeout",
+		},
+		{
+			name:        "overall client timeout",
+			baseURL:     slowHeaders.URL,
+			opts:        []httpx.ClientConfigOption{httpx.WithClientTimeout(50 * time.Millisecond)},
+			wantPhase:   httpx.TimeoutPhaseOverall,
+			wantMessage: "request timeout",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := httpx.NewClientWithConfig(append([]httpx.ClientConfigOption{httpx.WithClientDefaultBaseURL(tt.baseURL)}, tt.opts...)...)
+
+			_, err := client.Execute(*httpx.NewRequest(http.MethodGet, tt.reqOpts...), map[string]any{})
+
+			require.Error(t, err)
+			assert.True(t, httpx.IsTimeoutError(err))
+			assert.Equal(t, tt.wantPhase, httpx.GetTimeoutPhase(err))
+			assert.Contains(t, err.Error(), tt.wantMessage)
+
+			phaseErr := &httpx.TimeoutPhaseError{}
+			require.ErrorAs(t, err, &phaseErr)
+			assert.Equal(t, tt.wantPhase, phaseErr.Phase)
+		})
+	}
+
+	t.Run("body read timeout surfaces while streaming", func(t *testing.T) {
+		client := httpx.NewClientWithConfig(
+			httpx.WithClientDefaultBaseURL(slowBody.URL),
+			httpx.WithClientBodyReadTimeout(50*time.Millisecond),
+		)
+
+		resp, err := client.Execute(*httpx.NewRequest(http.MethodGet, httpx.WithStreaming()), "")
+		require.NoError(t, err)
+		defer resp.Close()
+
+		_, err = io.ReadAll(resp.StreamBody)
+		assert.Equal(t, httpx.TimeoutPhaseBodyRead, httpx.GetTimeoutPhase(err))
+	})
+
+	t.Run("classifies dial timeouts", func(t *testing.T) {
+		dialErr := &net.OpError{Op: "dial", Net: "tcp", Err: context.DeadlineExceeded}
+
+		err := httpx.ClassifyError(dialErr, nil, nil)
+
+		assert.Equal(t, httpx.ErrorTypeTimeout, err.Type)
+		assert.Equal(t, "dial timeout", err.Message)
+		assert.Equal(t, httpx.TimeoutPhaseDial, httpx.GetTimeoutPhase(err))
+	})
+
+	t.Run("non timeout errors have no phase", func(t *testing.T) {
+		assert.Empty(t, httpx.GetTimeoutPhase(httpx.NetworkError("connection refused", nil, nil)))
+		assert.Empty(t, httpx.GetTimeoutPhase(nil))
+	})
+}
@@ -3,7 +3,9 @@ package httpx
 import (
 	"context"
 	"fmt"
+	"math/rand/v2"
 	"net/http"
+	"path"
 	"strings"
 
 	"go.opentelemetry.io/otel"
@@ -20,6 +22,21 @@ type TracingConfig struct {
 	SpanNameFunc     func(*http.Request) string
 	CaptureHeaders   bool
 	SensitiveHeaders []string // Headers to exclude from capture
+
+	// SamplerFunc reports whether a request is traced, e.g. to never trace health checks and always trace
+	// payments; it takes precedence over SamplingRules
+	SamplerFunc func(*http.Request) bool
+
+	// SamplingRules set the fraction of the requests to the paths matching their pattern that are traced, the
+	// first matching rule applying; requests matching no rule are traced
+	SamplingRules []TracingSamplingRule
+}
+
+// TracingSamplingRule traces a fraction of the requests to the paths matching PathPattern
+// Requests that are not sampled get no span of the client, but still propagate the trace context of the caller.
+type TracingSamplingRule struct {
+	PathPattern string  // Pattern of the request path in the path.Match syntax, e.g. "/health*"
+	Rate        float64 // Fraction of the requests traced, from 0 for none to 1 for all
 }
 
 // TracingMiddleware implements distributed tracing using OpenTelemetry
@@ -67,6 +84,11 @@ func (m *TracingMiddleware) Name() string {
 
 // Execute implements the Middleware interface
 func (m *TracingMiddleware) Execute(ctx context.Context, req *http.Request, next MiddlewareFunc) (*http.Response, error) {
+	if !m.sampled(req) {
+		m.config.Propagator.Inject(ctx, propagation.HeaderCarrier(req.Header))
+		return next(ctx, req)
+	}
+
 	// Create span
 	spanName := m.config.SpanNameFunc(req)
 	ctx, span := m.tracer.Start(ctx, spanName,
@@ -74,6 +96,9 @@ func (m *TracingMiddleware) Execute(ctx context.Context, req *http.Request, next
 		trace.WithAttributes(
 			m.httpAttributes(req)...,
 		),
+		trace.WithAttributes(
+			annotationSpanAttributes(ctx)...,
+		),
 	)
 	defer span.End()
 
@@ -114,6 +139,19 @@ func (m *TracingMiddleware) Execute(ctx context.Context, req *http.Request, next
 	return resp, nil
 }
 
+// sampled reports whether the request is traced, by the SamplerFunc or the first matching sampling rule
+func (m *TracingMiddleware) sampled(req *http.Request) bool {
+	if m.config.SamplerFunc != nil {
+		return m.config.SamplerFunc(req)
+	}
+	for _, rule := range m.config.SamplingRules {
+		if matched, _ := path.Match(rule.PathPattern, req.URL.Path); matched {
+			return rule.Rate >= 1 || (rule.Rate > 0 && rand.Float64() < rule.Rate)
+		}
+	}
+	return true
+}
+
 // httpAttributes generates OpenTelemetry semantic convention attributes for HTTP
 func (m *TracingMiddleware) httpAttributes(req *http.Request) []attribute.KeyValue {
 	attrs := []attribute.KeyValue{
@@ -124,6 +162,10 @@ func (m *TracingMiddleware) httpAttributes(req *http.Request) []attribute.KeyVal
 		attribute.String("http.target", req.URL.Path),
 	}
 
+	if template := PathTemplateFromContext(req.Context()); template != "" {
+		attrs = append(attrs, attribute.String("http.route", path.Join("/", template)))
+	}
+
 	if req.URL.RawQuery != "" {
 		attrs = append(attrs, attribute.String("http.query", req.URL.RawQuery))
 	}
@@ -165,7 +207,11 @@ func (m *TracingMiddleware) isSensitiveHeader(name string) bool {
 	return false
 }
 
-// defaultSpanName generates default span name from request
+// defaultSpanName generates default span name from request, with the path template of requests built with
+// path parameters
 func defaultSpanName(req *http.Request) string {
+	if template := PathTemplateFromContext(req.Context()); template != "" {
+		return fmt.Sprintf("HTTP %s %s", req.Method, path.Join("/", template))
+	}
 	return fmt.Sprintf("HTTP %s", req.Method)
 }
@@ -1087,3 +1087,78 @@ func TestTracingMiddleware_Execute_NetworkErrors(t *testing.T) {
 		assert.Equal(t, "Error", spans[0].Status.Code.String())
 	})
 }
+
+func TestTracingMiddleware_Sampling(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+	// tracedPaths requests paths and returns those traced
+	tracedPaths := func(t *testing.T, config httpx.TracingConfig, paths ...string) []string {
+		exporter := tracetest.NewInMemoryExporter()
+		config.TracerProvider = sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+		config.SpanNameFunc = func(req *http.Request) string { return req.URL.Path }
+		client := httpx.NewClientWithConfig(
+			httpx.WithClientDefaultBaseURL(server.URL),
+			httpx.WithClientMiddleware(httpx.NewTracingMiddleware(config)),
+		)
+		for _, path := range paths {
+			_, err := client.Execute(*httpx.NewRequest(http.MethodGet, httpx.WithPath(path)), nil)
+			require.NoError(t, err)
+		}
+
+		var traced []string
+		for _, span := range exporter.GetSpans() {
+			traced = append(traced, span.Name)
+		}
+		return traced
+	}
+
+	t.Run("traces the requests the sampler selects", func(t *testing.T) {
+		traced := tracedPaths(t, httpx.TracingConfig{
+			SamplerFunc: func(req *http.Request) bool { return req.URL.Path != "/health" },
+			// The sampler takes precedence over the rules
+			SamplingRules: []httpx.TracingSamplingRule{{PathPattern: "/payments", Rate: 0}},
+		}, "/health", "/payments")
+
+		assert.Equal(t, []string{"/payments"}, traced)
+	})
+
+	t.Run("traces the requests matching rules at their rate", func(t *testing.T) {
+		traced := tracedPaths(t, httpx.TracingConfig{
+			SamplingRules: []httpx.TracingSamplingRule{
+				{PathPattern: "/health*", Rate: 0},
+				{PathPattern: "/payments/*", Rate: 1},
+				{PathPattern: "/payments/*", Rate: 0}, // Shadowed by the first matching rule
+			},
+		}, "/health", "/healthz", "/payments/1", "/orders")
+
+		assert.Equal(t, []string{"/payments/1", "/orders"}, traced)
+	})
+
+	t.Run("propagates the trace context of unsampled requests", func(t *testing.T) {
+		var traceparent string
+		server := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+			traceparent = r.Header.Get("Traceparent")
+		}))
+		defer server.Close()
+		provider := sdktrace.NewTracerProvider()
+		ctx, parent := provider.Tracer("test").Start(context.Background(), "handler")
+		defer parent.End()
+		client := httpx.NewClientWithConfig(
+			httpx.WithClientDefaultBaseURL(server.URL),
+			httpx.WithClientMiddleware(httpx.NewTracingMiddleware(httpx.TracingConfig{
+				TracerProvider: provider,
+				Propagator:     propagation.TraceContext{},
+				SamplerFunc:    func(*http.Request) bool { return false },
+			})),
+		)
+
+		_, err := client.Execute(*httpx.NewRequest(http.MethodGet, httpx.WithContext(ctx)), nil)
+
+		require.NoError(t, err)
+		assert.Contains(t, traceparent, parent.SpanContext().SpanID().String())
+	})
+}
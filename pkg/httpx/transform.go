@@ -0,0 +1,224 @@
+package httpx
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// redactedValue replaces the values of fields redacted by RedactJSONFields
+const redactedValue = "[REDACTED]"
+
+// ResponseTransformer rewrites response bodies before they reach the caller, for concerns shared by every
+// service such as redaction, unit conversion or renaming legacy fields. Either function may be nil.
+//
+// Raw runs on every buffered body, errors included, before it is decoded; its result is also the RawBody of
+// the response. Decoded runs on successfully decoded bodies and must return a value of the response type.
+// Streaming responses are not transformed.
+type ResponseTransformer struct {
+	Raw     func(resp *http.Response, body []byte) ([]byte, error) // Rewrites the raw body
+	Decoded func(resp *http.Response, body any) (any, error)       // Rewrites the decoded body
+}
+
+// TransformBody returns a transformer modifying decoded bodies of type T in place, e.g. converting units;
+// bodies of other types are left unchanged
+func TransformBody[T any](transform func(resp *http.Response, body *T) error) ResponseTransformer {
+	return ResponseTransformer{
+		Decoded: func(resp *http.Response, body any) (any, error) {
+			typed, ok := body.(T)
+			if !ok {
+				return body, nil
+			}
+			if err := transform(resp, &typed); err != nil {
+				return nil, err
+			}
+			return typed, nil
+		},
+	}
+}
+
+// RedactJSONFields returns a transformer replacing the values of the named fields of JSON bodies, at any depth
+// and in any case, with "[REDACTED]" before they are decoded or exposed as RawBody. Other bodies are unchanged.
+func RedactJSONFields(fields ...string) ResponseTransformer {
+	return ResponseTransformer{
+		Raw: func(_ *http.Response, body []byte) ([]byte, error) {
+			return rewriteJSONKeys(body, func(object map[string]any) {
+				for key := range object {
+					if isJSONKey(fields, key) {
+						object[key] = redactedValue
+					}
+				}
+			})
+		},
+	}
+}
+
+// RenameJSONFields returns a transformer renaming fields of JSON bodies at any depth, from the keys of renames
+// to their values, before they are decoded, e.g. to map a legacy field onto the current struct field
+func RenameJSONFields(renames map[string]string) ResponseTransformer {
+	return ResponseTransformer{
+		Raw: func(_ *http.Response, body []byte) ([]byte, error) {
+			return rewriteJSONKeys(body, func(object map[string]any) {
+				for from, to := range renames {
+					if value, ok := object[from]; ok {
+						delete(object, from)
+						object[to] = value
+					}
+				}
+			})
+		},
+	}
+}
+
+// rewriteJSONKeys applies rewrite to every object of a JSON body, returning bodies that are not JSON unchanged
+func rewriteJSONKeys(body []byte, rewrite func(object map[string]any)) ([]byte, error) {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var value any
+	if decoder.Decode(&value) != nil || decoder.More() {
+		return body, nil
+	}
+	var walk func(value any)
+	walk = func(value any) {
+		switch v := value.(type) {
+		case map[string]any:
+			rewrite(v)
+			for _, item := range v {
+				walk(item)
+			}
+		case []any:
+			for _, item := range v {
+				walk(item)
+			}
+		}
+	}
+	walk(value)
+	return marshalCompactJSON(value)
+}
+
+// marshalCompactJSON marshals value without escaping HTML characters, which would alter strings needlessly
+func marshalCompactJSON(value any) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(value); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+// RequestTransformer rewrites the body of a request after it was marshaled and before any middleware runs,
+// so compression, signing and digests always see the final body. It may also set headers of req, whose body
+// must not be read; nil or empty bodies are not transformed.
+type RequestTransformer func(req *http.Request, body []byte) ([]byte, error)
+
+// StripJSONNulls returns a request transformer removing the null fields of JSON objects at any depth
+func StripJSONNulls() RequestTransformer {
+	return func(_ *http.Request, body []byte) ([]byte, error) {
+		return rewriteJSONKeys(body, func(object map[string]any) {
+			for key, value := range object {
+				if value == nil {
+					delete(object, key)
+				}
+			}
+		})
+	}
+}
+
+// SortJSONKeys returns a request transformer writing JSON bodies compactly with the keys of every object
+// sorted, the canonical form expected by many request signing schemes
+func SortJSONKeys() RequestTransformer {
+	return func(_ *http.Request, body []byte) ([]byte, error) {
+		return rewriteJSONKeys(body, func(map[string]any) {})
+	}
+}
+
+// InjectJSONFields returns a request transformer adding fields to JSON object bodies, e.g. envelope fields
+// such as a client ID; fields already in the body are kept
+func InjectJSONFields(fields map[string]any) RequestTransformer {
+	return func(_ *http.Request, body []byte) ([]byte, error) {
+		var object map[string]any
+		decoder := json.NewDecoder(bytes.NewReader(body))
+		decoder.UseNumber()
+		if decoder.Decode(&object) != nil || object == nil || decoder.More() {
+			return body, nil
+		}
+		for key, value := range fields {
+			if _, ok := object[key]; !ok {
+				object[key] = value
+			}
+		}
+		return marshalCompactJSON(object)
+	}
+}
+
+// transformRequest applies the transformers to the body of req, replacing it with the result
+func transformRequest(transformers []RequestTransformer, req *http.Request) error {
+	if len(transformers) == 0 || req.Body == nil || req.Body == http.NoBody {
+		return nil
+	}
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		return errors.Wrap(err, "failed to read request body")
+	}
+	_ = req.Body.Close()
+	for _, transformer := range transformers {
+		if len(body) == 0 {
+			break
+		}
+		if body, err = transformer(req, body); err != nil {
+			return errors.Wrap(err, "failed to transform request body")
+		}
+	}
+	req.ContentLength = int64(len(body))
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(body)), nil
+	}
+	if len(body) == 0 {
+		req.Body = http.NoBody
+	}
+	return nil
+}
+
+// transformRaw applies the Raw functions of the transformers to a body
+func transformRaw(transformers []ResponseTransformer, resp *http.Response, body []byte) ([]byte, error) {
+	for _, transformer := range transformers {
+		if transformer.Raw == nil {
+			continue
+		}
+		var err error
+		if body, err = transformer.Raw(resp, body); err != nil {
+			return nil, errors.Wrap(err, "failed to transform response body")
+		}
+	}
+	return body, nil
+}
+
+// transformDecoded applies the Decoded functions of the transformers to a decoded body
+func transformDecoded(transformers []ResponseTransformer, resp *http.Response, body any) (any, error) {
+	for _, transformer := range transformers {
+		if transformer.Decoded == nil {
+			continue
+		}
+		var err error
+		if body, err = transformer.Decoded(resp, body); err != nil {
+			return nil, errors.Wrap(err, "failed to transform decoded response")
+		}
+	}
+	return body, nil
+}
+
+// isJSONKey reports whether key names one of fields, ignoring case
+func isJSONKey(fields []string, key string) bool {
+	for _, field := range fields {
+		if strings.EqualFold(field, key) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,182 @@
+package httpx_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bdpiprava/easy-http/pkg/httpx"
+)
+
+func TestResponseTransformers(t *testing.T) {
+	var status int
+	var body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(body))
+	}))
+	defer server.Close()
+	type account struct {
+		Name     string  `json:"name"`
+		Email    string  `json:"email"`
+		BalanceC int     `json:"balance_cents"`
+		Balance  float64 `json:"-"`
+	}
+	execute := func(t *testing.T, respType any, transformers ...httpx.ResponseTransformer) (*httpx.Response, error) {
+		t.Helper()
+		client := httpx.NewClientWithConfig(
+			httpx.WithClientDefaultBaseURL(server.URL),
+			httpx.WithClientResponseTransformers(transformers...),
+		)
+		return client.Execute(*httpx.NewRequest(http.MethodGet), respType)
+	}
+
+	t.Run("should redact fields at any depth in the raw and decoded body", func(t *testing.T) {
+		status, body = http.StatusOK, `{"user":{"Email":"a@b.c","name":"ada"},"items":[{"token":"t","id":1}]}`
+
+		resp, err := execute(t, nil, httpx.RedactJSONFields("email", "token"))
+
+		require.NoError(t, err)
+		assert.Equal(t, map[string]any{
+			"user":  map[string]any{"Email": "[REDACTED]", "name": "ada"},
+			"items": []any{map[string]any{"token": "[REDACTED]", "id": float64(1)}},
+		}, resp.Body)
+		assert.NotContains(t, string(resp.RawBody), "a@b.c")
+	})
+
+	t.Run("should redact error bodies", func(t *testing.T) {
+		status, body = http.StatusBadRequest, `{"error":"bad","email":"a@b.c"}`
+
+		resp, err := execute(t, nil, httpx.RedactJSONFields("email"))
+
+		require.NoError(t, err)
+		assert.Equal(t, map[string]any{"error": "bad", "email": "[REDACTED]"}, resp.Body)
+		assert.NotContains(t, string(resp.RawBody), "a@b.c")
+	})
+
+	t.Run("should rename legacy fields and convert units in order", func(t *testing.T) {
+		status, body = http.StatusOK, `{"name":"ada","mail":"a@b.c","balance_cents":1250}`
+
+		resp, err := execute(t, account{},
+			httpx.RenameJSONFields(map[string]string{"mail": "email"}),
+			httpx.TransformBody(func(_ *http.Response, a *account) error {
+				a.Balance = float64(a.BalanceC) / 100
+				return nil
+			}),
+		)
+
+		require.NoError(t, err)
+		assert.Equal(t, account{Name: "ada", Email: "a@b.c", BalanceC: 1250, Balance: 12.5}, resp.Body)
+	})
+
+	t.Run("should leave bodies of other types and non-JSON bodies unchanged", func(t *testing.T) {
+		status, body = http.StatusOK, `not json, email`
+
+		resp, err := execute(t, "",
+			httpx.RedactJSONFields("email"),
+			httpx.TransformBody(func(*http.Response, *account) error { return errors.New("not called") }),
+		)
+
+		require.NoError(t, err)
+		assert.Equal(t, "not json, email", resp.Body)
+	})
+
+	t.Run("should fail with the error of a transformer", func(t *testing.T) {
+		status, body = http.StatusOK, `{"name":"ada"}`
+
+		_, err := execute(t, account{}, httpx.TransformBody(func(*http.Response, *account) error {
+			return errors.New("invalid account")
+		}))
+
+		assert.ErrorContains(t, err, "invalid account")
+	})
+}
+
+// digestMiddleware records the request body seen by the middleware chain, as a signing middleware would
+type digestMiddleware struct{ body *string }
+
+func (digestMiddleware) Name() string { return "digest" }
+
+func (m digestMiddleware) Execute(ctx context.Context, req *http.Request, next httpx.MiddlewareFunc) (*http.Response, error) {
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, err
+	}
+	data, _ := io.ReadAll(body)
+	*m.body = string(data)
+	return next(ctx, req)
+}
+
+func TestRequestTransformers(t *testing.T) {
+	var received string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		received = string(data)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+	execute := func(t *testing.T, opts []httpx.ClientConfigOption, reqOpts ...httpx.RequestOption) error {
+		t.Helper()
+		client := httpx.NewClientWithConfig(append([]httpx.ClientConfigOption{httpx.WithClientDefaultBaseURL(server.URL)}, opts...)...)
+		_, err := client.Execute(*httpx.NewRequest(http.MethodPost, reqOpts...), "")
+		return err
+	}
+
+	t.Run("should strip nulls and sort keys before middlewares see the body", func(t *testing.T) {
+		var signed string
+
+		err := execute(t, []httpx.ClientConfigOption{
+			httpx.WithClientMiddleware(digestMiddleware{body: &signed}),
+			httpx.WithClientRequestTransformers(httpx.StripJSONNulls(), httpx.SortJSONKeys()),
+		}, httpx.WithJSONBody(map[string]any{"z": 1, "a": nil, "m": map[string]any{"y": nil, "b": "<x>"}, "l": []any{nil}}))
+
+		require.NoError(t, err)
+		assert.Equal(t, `{"l":[null],"m":{"b":"<x>"},"z":1}`, received)
+		assert.Equal(t, received, signed)
+	})
+
+	t.Run("should inject envelope fields without overriding body fields", func(t *testing.T) {
+		err := execute(t, []httpx.ClientConfigOption{
+			httpx.WithClientRequestTransformers(httpx.InjectJSONFields(map[string]any{"client": "svc", "id": "x"})),
+		}, httpx.WithBody(strings.NewReader(`{"id":12345678901234567890}`)))
+
+		require.NoError(t, err)
+		assert.Equal(t, `{"client":"svc","id":12345678901234567890}`, received)
+	})
+
+	t.Run("should pass the request to transformers and leave non-JSON bodies unchanged", func(t *testing.T) {
+		var contentType string
+
+		err := execute(t, []httpx.ClientConfigOption{
+			httpx.WithClientRequestTransformers(httpx.SortJSONKeys(), func(req *http.Request, body []byte) ([]byte, error) {
+				contentType = req.Header.Get("Content-Type")
+				return append(body, '!'), nil
+			}),
+		}, httpx.WithBody(strings.NewReader("a=1")), httpx.WithHeader("Content-Type", "text/plain"))
+
+		require.NoError(t, err)
+		assert.Equal(t, "text/plain", contentType)
+		assert.Equal(t, "a=1!", received)
+	})
+
+	t.Run("should fail the request when a transformer fails", func(t *testing.T) {
+		received = ""
+
+		err := execute(t, []httpx.ClientConfigOption{
+			httpx.WithClientRequestTransformers(func(*http.Request, []byte) ([]byte, error) {
+				return nil, errors.New("boom")
+			}),
+		}, httpx.WithBody(strings.NewReader("a")))
+
+		require.ErrorContains(t, err, "boom")
+		assert.Empty(t, received)
+	})
+}
@@ -0,0 +1,400 @@
+package httpx
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	defaultUploadChunkSize  = 8 << 20 // 8MiB
+	defaultUploadRetries    = 3
+	defaultUploadRetryDelay = time.Second
+	maxUploadRetryDelay     = 30 * time.Second
+
+	// googleUploadGranularity is the multiple of which every chunk of a Google resumable upload but the last must be
+	googleUploadGranularity = 256 << 10
+	// tusVersion is the version of the tus protocol implemented by ResumableUpload
+	tusVersion = "1.0.0"
+	// statusResumeIncomplete is the status of Google resumable upload responses for unfinished uploads
+	statusResumeIncomplete = http.StatusPermanentRedirect
+)
+
+// UploadProtocol is a resumable upload session protocol
+type UploadProtocol string
+
+const (
+	// UploadProtocolTus is the tus.io protocol: the session is created with a POST carrying Upload-Length, chunks
+	// are sent with PATCH at the Upload-Offset and HEAD reports the offset stored by the server
+	UploadProtocolTus UploadProtocol = "tus"
+	// UploadProtocolGoogle is the Google resumable upload protocol: the session is created with the initial
+	// request, chunks are sent with PUT and a Content-Range, and 308 responses report the stored range
+	UploadProtocolGoogle UploadProtocol = "google"
+)
+
+// UploadProgress describes the progress of a resumable upload after a chunk was acknowledged
+type UploadProgress struct {
+	URL     string // URL of the upload session, which WithUploadURL resumes from another process
+	Offset  int64  // Bytes stored by the server
+	Size    int64  // Total size of the upload
+	Chunks  int    // Chunks acknowledged so far
+	Retries int    // Chunks retried so far
+}
+
+// UploadConfig holds the configuration of ResumableUpload
+type UploadConfig struct {
+	Protocol    UploadProtocol       // Session protocol (default: tus)
+	ChunkSize   int64                // Bytes sent per request, rounded up to 256KiB for Google (default: 8MiB)
+	MaxRetries  int                  // Attempts to resend a chunk after a transient failure (default: 3)
+	RetryDelay  time.Duration        // Delay before the first resend, doubled for each following one (default: 1s)
+	ContentType string               // Media type of the content, sent as X-Upload-Content-Type to Google
+	Metadata    map[string]string    // tus Upload-Metadata sent when the session is created, e.g. filename
+	URL         string               // URL of an existing session to resume instead of creating one
+	OnProgress  func(UploadProgress) // Optional callback invoked after every acknowledged chunk
+	Options     []RequestOption      // Options of chunk and offset requests, e.g. per-request auth headers
+}
+
+// UploadOption configures ResumableUpload
+type UploadOption func(*UploadConfig)
+
+// WithUploadProtocol sets the session protocol of the upload
+func WithUploadProtocol(protocol UploadProtocol) UploadOption {
+	return func(c *UploadConfig) {
+		c.Protocol = protocol
+	}
+}
+
+// WithUploadChunkSize sets the number of bytes sent per request
+func WithUploadChunkSize(size int64) UploadOption {
+	return func(c *UploadConfig) {
+		c.ChunkSize = size
+	}
+}
+
+// WithUploadRetries sets the attempts to resend a chunk and the delay before the first one
+func WithUploadRetries(maxRetries int, delay time.Duration) UploadOption {
+	return func(c *UploadConfig) {
+		c.MaxRetries = maxRetries
+		c.RetryDelay = delay
+	}
+}
+
+// WithUploadContentType sets the media type of the uploaded content
+func WithUploadContentType(contentType string) UploadOption {
+	return func(c *UploadConfig) {
+		c.ContentType = contentType
+	}
+}
+
+// WithUploadMetadata sets the tus Upload-Metadata sent when the session is created
+func WithUploadMetadata(metadata map[string]string) UploadOption {
+	return func(c *UploadConfig) {
+		c.Metadata = metadata
+	}
+}
+
+// WithUploadURL resumes the session at sessionURL, e.g. reported by a previous process through WithUploadProgress,
+// from the offset stored by the server
+func WithUploadURL(sessionURL string) UploadOption {
+	return func(c *UploadConfig) {
+		c.URL = sessionURL
+	}
+}
+
+// WithUploadProgress sets a callback invoked after every acknowledged chunk
+func WithUploadProgress(callback func(UploadProgress)) UploadOption {
+	return func(c *UploadConfig) {
+		c.OnProgress = callback
+	}
+}
+
+// WithUploadOptions adds request options to chunk and offset requests, which otherwise only inherit the client
+// configuration and the context of the initial request
+func WithUploadOptions(opts ...RequestOption) UploadOption {
+	return func(c *UploadConfig) {
+		c.Options = append(c.Options, opts...)
+	}
+}
+
+// ResumableUpload uploads size bytes of content in chunks through a resumable upload session created by req,
+// e.g. a POST to the tus creation endpoint or the Google uploadType=resumable URL with the metadata as body.
+// Failed chunks are resent after asking the server for the offset it stored, so no byte is sent twice nor
+// skipped; offsets acknowledged by the server are verified against the bytes sent. The response completing
+// the upload is returned; error responses are returned like Execute does, without an error.
+func ResumableUpload(client *Client, req Request, content io.ReaderAt, size int64, opts ...UploadOption) (*Response, error) {
+	config := UploadConfig{
+		Protocol:   UploadProtocolTus,
+		ChunkSize:  defaultUploadChunkSize,
+		MaxRetries: defaultUploadRetries,
+		RetryDelay: defaultUploadRetryDelay,
+	}
+	for _, opt := range opts {
+		opt(&config)
+	}
+	if config.ChunkSize <= 0 {
+		config.ChunkSize = defaultUploadChunkSize
+	}
+	if config.Protocol == UploadProtocolGoogle {
+		config.ChunkSize = (config.ChunkSize + googleUploadGranularity - 1) / googleUploadGranularity * googleUploadGranularity
+	}
+	if config.Protocol != UploadProtocolTus && config.Protocol != UploadProtocolGoogle {
+		return nil, errors.Errorf("unsupported upload protocol: %s", config.Protocol)
+	}
+
+	ctx := buildOptsFromConfig(client.config, &req).Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	config.Options = append(slices.Clip(config.Options), WithContext(ctx))
+	upload := &resumableUpload{client: client, config: config, content: content, size: size}
+
+	var resp *Response
+	var err error
+	if config.URL == "" {
+		resp, err = upload.create(req)
+	} else if upload.session, err = url.Parse(config.URL); err != nil {
+		return nil, errors.Wrap(err, "invalid upload URL")
+	} else {
+		resp, err = upload.queryOffset()
+	}
+	if err != nil || !upload.acknowledged(resp) || upload.complete {
+		return resp, err
+	}
+	upload.report()
+	return upload.send(ctx)
+}
+
+// resumableUpload is the state of an upload session started by ResumableUpload
+type resumableUpload struct {
+	client   *Client
+	config   UploadConfig
+	content  io.ReaderAt
+	size     int64
+	session  *url.URL // URL of the upload session
+	offset   int64    // Bytes stored by the server
+	complete bool     // Whether the server reported the upload complete
+	chunks   int
+	retries  int
+}
+
+// create starts the upload session with req, recording its URL from the Location header of the response
+func (u *resumableUpload) create(req Request) (*Response, error) {
+	var opts []RequestOption
+	switch u.config.Protocol {
+	case UploadProtocolTus:
+		opts = append(opts, WithHeader("Tus-Resumable", tusVersion), WithHeader("Upload-Length", strconv.FormatInt(u.size, 10)))
+		if len(u.config.Metadata) > 0 {
+			opts = append(opts, WithHeader("Upload-Metadata", tusMetadata(u.config.Metadata)))
+		}
+	case UploadProtocolGoogle:
+		opts = append(opts, WithHeader("X-Upload-Content-Length", strconv.FormatInt(u.size, 10)))
+		if u.config.ContentType != "" {
+			opts = append(opts, WithHeader("X-Upload-Content-Type", u.config.ContentType))
+		}
+	}
+
+	resp, err := u.client.Execute(*req.Clone(opts...), "")
+	if err != nil || resp.StatusCode > 299 {
+		return resp, err
+	}
+	location := resp.Header().Get("Location")
+	if location == "" {
+		return resp, errors.New("upload session created without a Location header")
+	}
+	if u.session, err = resp.httpResponse.Request.URL.Parse(location); err != nil {
+		return resp, errors.Wrap(err, "invalid upload session Location header")
+	}
+	u.complete = u.config.Protocol == UploadProtocolTus && u.size == 0
+	return resp, nil
+}
+
+// send uploads the content from the stored offset, resending chunks after transient failures
+func (u *resumableUpload) send(ctx context.Context) (*Response, error) {
+	attempts := 0
+	for {
+		before := u.offset
+		resp, err := u.sendChunk()
+		if err == nil && u.complete {
+			return resp, nil
+		}
+		// Acknowledgements not moving the offset are retried too, or a misbehaving server would loop forever
+		if err == nil && u.acknowledged(resp) && u.offset > before {
+			attempts = 0
+			u.chunks++
+			u.report()
+			continue
+		}
+		if ctx.Err() != nil || !u.transient(resp, err) || attempts >= u.config.MaxRetries {
+			return resp, err
+		}
+
+		// Ask the server what it stored before resending, the chunk may have been stored partially or entirely
+		delay := min(u.config.RetryDelay<<attempts, maxUploadRetryDelay)
+		attempts++
+		u.retries++
+		if err := u.wait(ctx, delay); err != nil {
+			return resp, err
+		}
+		if resp, err = u.queryOffset(); err == nil && u.complete {
+			return resp, nil
+		}
+		if err != nil && !u.transient(resp, err) {
+			return resp, err
+		}
+	}
+}
+
+// wait sleeps for delay on the client clock
+func (u *resumableUpload) wait(ctx context.Context, delay time.Duration) error {
+	timer := clockOrSystem(u.client.config.Clock).NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C():
+		return nil
+	case <-ctx.Done():
+		return errors.Wrap(ctx.Err(), "resumable upload canceled")
+	}
+}
+
+// sendChunk sends the chunk at the stored offset, updating the offset from the acknowledgement of the server
+func (u *resumableUpload) sendChunk() (*Response, error) {
+	chunk := make([]byte, min(u.config.ChunkSize, u.size-u.offset))
+	if _, err := u.content.ReadAt(chunk, u.offset); err != nil && !errors.Is(err, io.EOF) {
+		return nil, errors.Wrap(err, "failed to read upload content")
+	}
+
+	var opts []RequestOption
+	method := http.MethodPatch
+	switch u.config.Protocol {
+	case UploadProtocolTus:
+		opts = append(opts,
+			WithHeader("Tus-Resumable", tusVersion),
+			WithHeader("Upload-Offset", strconv.FormatInt(u.offset, 10)),
+			WithHeader("Content-Type", "application/offset+octet-stream"))
+	case UploadProtocolGoogle:
+		method = http.MethodPut
+		contentRange := fmt.Sprintf("bytes */%d", u.size)
+		if len(chunk) > 0 {
+			contentRange = fmt.Sprintf("bytes %d-%d/%d", u.offset, u.offset+int64(len(chunk))-1, u.size)
+		}
+		opts = append(opts, WithHeader("Content-Range", contentRange))
+	}
+	opts = append(opts, WithBody(bytes.NewReader(chunk)))
+
+	resp, err := u.client.Execute(*NewRequest(method, u.requestOptions(opts...)...), "")
+	if err != nil || !u.acknowledged(resp) {
+		return resp, err
+	}
+	sent := u.offset + int64(len(chunk))
+	if err := u.readOffset(resp); err != nil {
+		return resp, err
+	}
+	if u.offset > sent {
+		return resp, errors.Errorf("upload server acknowledged offset %d beyond the %d bytes sent", u.offset, sent)
+	}
+	return resp, nil
+}
+
+// queryOffset asks the server for the offset it stored
+func (u *resumableUpload) queryOffset() (*Response, error) {
+	var resp *Response
+	var err error
+	switch u.config.Protocol {
+	case UploadProtocolTus:
+		resp, err = u.client.Execute(*NewRequest(http.MethodHead, u.requestOptions(WithHeader("Tus-Resumable", tusVersion))...), "")
+	case UploadProtocolGoogle:
+		contentRange := WithHeader("Content-Range", fmt.Sprintf("bytes */%d", u.size))
+		resp, err = u.client.Execute(*NewRequest(http.MethodPut, u.requestOptions(contentRange)...), "")
+	}
+	if err != nil || !u.acknowledged(resp) {
+		return resp, err
+	}
+	return resp, u.readOffset(resp)
+}
+
+// readOffset sets the stored offset from an acknowledgement of the server
+func (u *resumableUpload) readOffset(resp *Response) error {
+	offset := u.size
+	switch {
+	case u.config.Protocol == UploadProtocolTus:
+		var err error
+		if offset, err = strconv.ParseInt(resp.Header().Get("Upload-Offset"), 10, 64); err != nil || offset < 0 {
+			return errors.Errorf("invalid Upload-Offset header %q", resp.Header().Get("Upload-Offset"))
+		}
+	case resp.StatusCode == statusResumeIncomplete:
+		// Range is absent until the server stored a byte, and then holds the inclusive range stored, e.g. bytes=0-42
+		offset = 0
+		if stored := resp.Header().Get("Range"); stored != "" {
+			_, last, ok := strings.Cut(strings.TrimPrefix(stored, "bytes="), "-")
+			end, err := strconv.ParseInt(last, 10, 64)
+			if !ok || err != nil {
+				return errors.Errorf("invalid Range header %q", stored)
+			}
+			offset = end + 1
+		}
+	}
+	if offset > u.size {
+		return errors.Errorf("upload server acknowledged offset %d beyond the size %d", offset, u.size)
+	}
+	u.offset = offset
+	u.complete = (u.config.Protocol == UploadProtocolTus && offset == u.size) || (u.config.Protocol == UploadProtocolGoogle && resp.StatusCode <= 299)
+	return nil
+}
+
+// acknowledged reports whether resp acknowledges the session, a chunk or an offset query
+func (u *resumableUpload) acknowledged(resp *Response) bool {
+	return resp.StatusCode <= 299 || (u.config.Protocol == UploadProtocolGoogle && resp.StatusCode == statusResumeIncomplete)
+}
+
+// transient reports whether a failed chunk or offset query may succeed if sent again
+func (u *resumableUpload) transient(resp *Response, err error) bool {
+	if err != nil {
+		return resp == nil
+	}
+	switch resp.StatusCode {
+	case http.StatusRequestTimeout, http.StatusConflict, http.StatusTooManyRequests:
+		// tus servers answer 409 Conflict when the offset sent differs from the stored one
+		return true
+	}
+	return resp.StatusCode >= 500
+}
+
+// report calls the progress callback with the state of the upload
+func (u *resumableUpload) report() {
+	if u.config.OnProgress != nil {
+		u.config.OnProgress(UploadProgress{URL: u.session.String(), Offset: u.offset, Size: u.size, Chunks: u.chunks, Retries: u.retries})
+	}
+}
+
+// requestOptions returns the options of a request to the upload session
+func (u *resumableUpload) requestOptions(opts ...RequestOption) []RequestOption {
+	base := *u.session
+	base.RawQuery, base.Fragment = "", ""
+	options := []RequestOption{WithBaseURL(base.String())}
+	if u.session.RawQuery != "" {
+		query, _ := url.ParseQuery(u.session.RawQuery)
+		options = append(options, WithQueryParams(query))
+	}
+	options = append(options, opts...)
+	return append(options, u.config.Options...)
+}
+
+// tusMetadata encodes metadata as a tus Upload-Metadata header, keys followed by their base64 encoded values
+func tusMetadata(metadata map[string]string) string {
+	pairs := make([]string, 0, len(metadata))
+	for key, value := range metadata {
+		pairs = append(pairs, key+" "+base64.StdEncoding.EncodeToString([]byte(value)))
+	}
+	slices.Sort(pairs)
+	return strings.Join(pairs, ",")
+}
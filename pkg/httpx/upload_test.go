@@ -0,0 +1,205 @@
+package httpx_test
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bdpiprava/easy-http/pkg/httpx"
+)
+
+// uploadServer is an in-memory upload server speaking the tus and Google resumable upload protocols
+type uploadServer struct {
+	*httptest.Server
+	mu       sync.Mutex
+	size     int64
+	stored   []byte
+	headers  http.Header // Headers of the creation request
+	failures map[int]int // Chunk requests (1-based) failing with 503 after storing the given number of bytes
+	chunks   int         // Chunk requests received
+	overAck  int64       // Bytes acknowledged beyond those stored
+	methods  []string    // Methods of the requests to the session
+	progress []httpx.UploadProgress
+}
+
+func newUploadServer(t *testing.T, google bool) *uploadServer {
+	s := &uploadServer{failures: make(map[int]int)}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if r.URL.Path == "/uploads" {
+			s.headers = r.Header.Clone()
+			length := r.Header.Get("Upload-Length")
+			if google {
+				length = r.Header.Get("X-Upload-Content-Length")
+			}
+			s.size, _ = strconv.ParseInt(length, 10, 64)
+			w.Header().Set("Location", "/uploads/1?upload_id=abc")
+			w.WriteHeader(http.StatusCreated)
+			return
+		}
+		assert.Equal(t, "abc", r.URL.Query().Get("upload_id"))
+		s.methods = append(s.methods, r.Method)
+		if google {
+			s.serveGoogle(w, r)
+		} else {
+			s.serveTus(w, r)
+		}
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *uploadServer) serveTus(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodPatch {
+		offset, _ := strconv.ParseInt(r.Header.Get("Upload-Offset"), 10, 64)
+		if offset != int64(len(s.stored)) {
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+		if !s.store(r) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+	}
+	w.Header().Set("Upload-Offset", strconv.Itoa(len(s.stored)+int(s.overAck)))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *uploadServer) serveGoogle(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.Header.Get("Content-Range"), "bytes */") {
+		if !strings.HasPrefix(r.Header.Get("Content-Range"), fmt.Sprintf("bytes %d-", len(s.stored))) {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if !s.store(r) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+	}
+	if int64(len(s.stored)) == s.size {
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(`{"id":"file-1"}`))
+		return
+	}
+	if len(s.stored) > 0 {
+		w.Header().Set("Range", fmt.Sprintf("bytes=0-%d", len(s.stored)-1))
+	}
+	w.WriteHeader(http.StatusPermanentRedirect)
+}
+
+// store appends the chunk of r, or a part of it and reports false if the chunk is configured to fail
+func (s *uploadServer) store(r *http.Request) bool {
+	s.chunks++
+	body, _ := io.ReadAll(r.Body)
+	stored, failing := s.failures[s.chunks]
+	if !failing {
+		stored = len(body)
+	}
+	s.stored = append(s.stored, body[:stored]...)
+	return !failing
+}
+
+func (s *uploadServer) record(progress httpx.UploadProgress) {
+	s.progress = append(s.progress, progress)
+}
+
+func TestResumableUpload(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789"), 60<<10) // 600KiB
+	size := int64(len(content))
+	upload := func(s *uploadServer, opts ...httpx.UploadOption) (*httpx.Response, error) {
+		client := httpx.NewClientWithConfig(httpx.WithClientDefaultBaseURL(s.URL))
+		opts = append([]httpx.UploadOption{httpx.WithUploadRetries(3, time.Millisecond), httpx.WithUploadProgress(s.record)}, opts...)
+		return httpx.ResumableUpload(client, *httpx.NewRequest(http.MethodPost, httpx.WithPath("/uploads")), bytes.NewReader(content), size, opts...)
+	}
+
+	t.Run("should upload in chunks with the tus protocol", func(t *testing.T) {
+		server := newUploadServer(t, false)
+
+		resp, err := upload(server, httpx.WithUploadChunkSize(256<<10), httpx.WithUploadMetadata(map[string]string{"filename": "video.mp4"}))
+
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+		assert.Equal(t, content, server.stored)
+		assert.Equal(t, "1.0.0", server.headers.Get("Tus-Resumable"))
+		assert.Equal(t, "filename dmlkZW8ubXA0", server.headers.Get("Upload-Metadata"))
+		assert.Equal(t, []string{"PATCH", "PATCH", "PATCH"}, server.methods)
+		require.Len(t, server.progress, 3, "progress is reported on creation and after every chunk but the last")
+		assert.Equal(t, httpx.UploadProgress{URL: server.URL + "/uploads/1?upload_id=abc", Offset: 512 << 10, Size: size, Chunks: 2}, server.progress[2])
+	})
+
+	t.Run("should resume from the offset stored by the tus server after a failure", func(t *testing.T) {
+		server := newUploadServer(t, false)
+		server.failures[2] = 1000
+
+		_, err := upload(server, httpx.WithUploadChunkSize(256<<10))
+
+		require.NoError(t, err)
+		assert.Equal(t, content, server.stored)
+		assert.Equal(t, []string{"PATCH", "PATCH", "HEAD", "PATCH", "PATCH"}, server.methods)
+		assert.Equal(t, 1, server.progress[len(server.progress)-1].Retries)
+	})
+
+	t.Run("should upload with the Google protocol", func(t *testing.T) {
+		server := newUploadServer(t, true)
+		server.failures[1] = 0
+
+		resp, err := upload(server, httpx.WithUploadProtocol(httpx.UploadProtocolGoogle),
+			httpx.WithUploadChunkSize(100<<10), httpx.WithUploadContentType("video/mp4"))
+
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusCreated, resp.StatusCode)
+		assert.Equal(t, `{"id":"file-1"}`, string(resp.RawBody))
+		assert.Equal(t, content, server.stored)
+		assert.Equal(t, "video/mp4", server.headers.Get("X-Upload-Content-Type"))
+		assert.Equal(t, 4, server.chunks, "chunks are rounded up to 256KiB and the failed one is resent")
+		assert.Equal(t, []string{"PUT", "PUT", "PUT", "PUT", "PUT"}, server.methods)
+	})
+
+	t.Run("should resume an existing session", func(t *testing.T) {
+		server := newUploadServer(t, true)
+		server.size = size
+		server.stored = append([]byte(nil), content[:300<<10]...)
+
+		resp, err := upload(server, httpx.WithUploadProtocol(httpx.UploadProtocolGoogle),
+			httpx.WithUploadURL(server.URL+"/uploads/1?upload_id=abc"))
+
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusCreated, resp.StatusCode)
+		assert.Equal(t, content, server.stored)
+		assert.Nil(t, server.headers, "no session is created")
+		assert.Equal(t, int64(300<<10), server.progress[0].Offset)
+	})
+
+	t.Run("should fail when the server acknowledges bytes it was not sent", func(t *testing.T) {
+		server := newUploadServer(t, false)
+		server.overAck = 10
+
+		_, err := upload(server, httpx.WithUploadChunkSize(256<<10))
+
+		assert.ErrorContains(t, err, "beyond the 262144 bytes sent")
+	})
+
+	t.Run("should give up after the configured retries", func(t *testing.T) {
+		server := newUploadServer(t, false)
+		for chunk := range 5 {
+			server.failures[chunk+1] = 0
+		}
+
+		resp, err := upload(server, httpx.WithUploadRetries(2, time.Millisecond))
+
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+		assert.Equal(t, 3, server.chunks)
+	})
+}
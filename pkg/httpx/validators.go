@@ -0,0 +1,149 @@
+package httpx
+
+import (
+	"container/list"
+	"net/url"
+	"sync"
+)
+
+// Validators are the ETag and Last-Modified validators of the representation of a URL
+type Validators struct {
+	ETag         string
+	LastModified string
+}
+
+// ValidatorStore holds the validators of the responses received by the cache middleware by URL, and those seeded
+// by the caller to send a request for a URL whose response is not cached conditionally
+//
+// Jobs persisting state outside the process, e.g. a nightly sync storing ETags in its database, read the
+// validators of the responses they processed with Get and seed them with Set on their next run; a 304 Not
+// Modified response is then returned as is when no response is cached to answer it. Validators recorded from
+// responses are not sent on their own, so callers only receive such responses for the URLs they seeded. URLs are
+// compared in their CanonicalURL form. The least recently used validators are dropped past the capacity of the
+// store.
+//
+// Validators are partitioned like cache entries, see CacheConfig.PartitionFunc: a store reads and writes those of
+// a single partition, the anonymous one for the store returned by Client.Validators.
+type ValidatorStore struct {
+	validators *validatorMap
+	partition  string
+}
+
+// Partition returns the store of the validators of another partition, e.g. the tenant of a custom PartitionFunc
+func (s *ValidatorStore) Partition(partition string) *ValidatorStore {
+	return &ValidatorStore{validators: s.validators, partition: partition}
+}
+
+// Get returns the ETag and Last-Modified validators recorded for rawURL, empty if there are none
+func (s *ValidatorStore) Get(rawURL string) (etag, lastModified string) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", ""
+	}
+	entry, _ := s.validators.lookup(s.key(u))
+	return entry.validators.ETag, entry.validators.LastModified
+}
+
+// Set records the validators of rawURL, sent with the next request for it that the cache cannot answer
+// Setting both validators empty removes those of rawURL.
+func (s *ValidatorStore) Set(rawURL, etag, lastModified string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ValidationError("invalid validator URL", err)
+	}
+	s.validators.store(s.key(u), Validators{ETag: etag, LastModified: lastModified}, true)
+	return nil
+}
+
+// Delete removes the validators of rawURL
+func (s *ValidatorStore) Delete(rawURL string) {
+	if u, err := url.Parse(rawURL); err == nil {
+		s.validators.store(s.key(u), Validators{}, false)
+	}
+}
+
+// key returns the key of the validators of u in the partition of the store
+func (s *ValidatorStore) key(u *url.URL) validatorKey {
+	return validatorKey{partition: s.partition, url: CanonicalURL(u).String()}
+}
+
+// validatorKey identifies the validators of a URL in a partition
+type validatorKey struct {
+	partition string
+	url       string
+}
+
+// validatorMap holds validators by partition and URL, evicting the least recently used ones past its capacity
+type validatorMap struct {
+	mu       sync.Mutex
+	entries  map[validatorKey]*list.Element
+	lru      *list.List // Most recently used validators at the front
+	capacity int
+}
+
+// validatorEntry is an element of the LRU list
+type validatorEntry struct {
+	key        validatorKey
+	validators Validators
+	seeded     bool // Set by the caller rather than recorded from a response
+}
+
+// newValidatorMap creates a map holding the validators of up to capacity URLs, 1000 when zero or negative
+func newValidatorMap(capacity int) *validatorMap {
+	if capacity <= 0 {
+		capacity = 1000
+	}
+	return &validatorMap{
+		entries:  make(map[validatorKey]*list.Element),
+		lru:      list.New(),
+		capacity: capacity,
+	}
+}
+
+// lookup returns the entry of the validators stored under key
+func (m *validatorMap) lookup(key validatorKey) (validatorEntry, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	element, ok := m.entries[key]
+	if !ok {
+		return validatorEntry{}, false
+	}
+	m.lru.MoveToFront(element)
+	return *element.Value.(*validatorEntry), true
+}
+
+// store stores validators under key, removing the entry when they are empty
+func (m *validatorMap) store(key validatorKey, validators Validators, seeded bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	element, exists := m.entries[key]
+	switch {
+	case validators == (Validators{}):
+		if exists {
+			m.lru.Remove(element)
+			delete(m.entries, key)
+		}
+	case exists:
+		entry := element.Value.(*validatorEntry)
+		entry.validators, entry.seeded = validators, seeded
+		m.lru.MoveToFront(element)
+	default:
+		m.entries[key] = m.lru.PushFront(&validatorEntry{key: key, validators: validators, seeded: seeded})
+		for len(m.entries) > m.capacity {
+			oldest := m.lru.Remove(m.lru.Back()).(*validatorEntry)
+			delete(m.entries, oldest.key)
+		}
+	}
+}
+
+// deleteMatching removes the validators of the URLs that match, in every partition
+func (m *validatorMap) deleteMatching(match func(url string) bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for key, element := range m.entries {
+		if match(key.url) {
+			m.lru.Remove(element)
+			delete(m.entries, key)
+		}
+	}
+}
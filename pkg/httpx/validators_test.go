@@ -0,0 +1,101 @@
+package httpx_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bdpiprava/easy-http/pkg/httpx"
+)
+
+func TestValidatorStore(t *testing.T) {
+	t.Parallel()
+
+	const lastModified = "Mon, 05 Oct 2026 10:00:00 GMT"
+	var conditional []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conditional = append(conditional, r.Header.Get("If-None-Match"))
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Last-Modified", lastModified)
+		w.Header().Set("Cache-Control", "no-store")
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		_, _ = fmt.Fprint(w, "products")
+	}))
+	defer server.Close()
+	newClient := func() *httpx.Client {
+		return httpx.NewClientWithConfig(
+			httpx.WithClientDefaultBaseURL(server.URL),
+			httpx.WithClientCache(httpx.CacheConfig{}),
+		)
+	}
+	request := *httpx.NewRequest(http.MethodGet, httpx.WithPath("/products"))
+
+	t.Run("records the validators of responses", func(t *testing.T) {
+		client := newClient()
+
+		_, err := client.Execute(request, "")
+		require.NoError(t, err)
+
+		etag, modified := client.Validators().Get(server.URL + "/products")
+		assert.Equal(t, `"v1"`, etag)
+		assert.Equal(t, lastModified, modified)
+		etag, _ = client.Validators().Get(server.URL + "/other")
+		assert.Empty(t, etag)
+
+		// Recorded validators are not sent without a cached response to answer a 304 Not Modified response
+		resp, err := client.Execute(request, "")
+		require.NoError(t, err)
+		assert.Equal(t, "products", resp.Body)
+	})
+
+	t.Run("sends seeded validators and returns Not Modified responses", func(t *testing.T) {
+		client := newClient()
+		require.NoError(t, client.Validators().Set(server.URL+"/products", `"v1"`, ""))
+		conditional = nil
+
+		resp, err := client.Execute(request, "")
+
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusNotModified, resp.StatusCode)
+		assert.Equal(t, []string{`"v1"`}, conditional)
+	})
+
+	t.Run("keeps the validators of each partition", func(t *testing.T) {
+		client := newClient()
+		authorized := httpx.NewRequest(http.MethodGet, httpx.WithPath("/products"), httpx.WithHeader("Authorization", "Bearer alice"))
+
+		_, err := client.Execute(*authorized, "")
+		require.NoError(t, err)
+
+		etag, _ := client.Validators().Get(server.URL + "/products")
+		assert.Empty(t, etag)
+		partition := httpx.AuthorizationPartition(&http.Request{Header: http.Header{"Authorization": {"Bearer alice"}}})
+		etag, _ = client.Validators().Partition(partition).Get(server.URL + "/products")
+		assert.Equal(t, `"v1"`, etag)
+	})
+
+	t.Run("removes validators", func(t *testing.T) {
+		client := newClient()
+		require.NoError(t, client.Validators().Set(server.URL+"/products", `"v1"`, ""))
+		require.NoError(t, client.Validators().Set(server.URL+"/orders", `"v3"`, ""))
+
+		client.Validators().Delete(server.URL + "/products")
+		require.NoError(t, client.Validators().Set(server.URL+"/orders", "", ""))
+
+		for _, path := range []string{"/products", "/orders"} {
+			etag, modified := client.Validators().Get(server.URL + path)
+			assert.Empty(t, etag+modified, path)
+		}
+	})
+
+	t.Run("is nil without a cache", func(t *testing.T) {
+		assert.Nil(t, httpx.NewClientWithConfig().Validators())
+	})
+}
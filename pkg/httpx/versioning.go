@@ -0,0 +1,103 @@
+package httpx
+
+import (
+	"cmp"
+	"mime"
+	"net/http"
+)
+
+// APIVersionScheme is how the API version of a request is sent
+type APIVersionScheme string
+
+const (
+	// APIVersionHeader sends the version in a request header, e.g. "API-Version: 2024-06-01"
+	APIVersionHeader APIVersionScheme = "header"
+	// APIVersionQuery sends the version as a query parameter, e.g. "?api-version=2024-06-01"
+	APIVersionQuery APIVersionScheme = "query"
+	// APIVersionAccept sends the version as a media type parameter, e.g. "Accept: application/json; version=2"
+	APIVersionAccept APIVersionScheme = "accept"
+)
+
+// Default names of the version header, query parameter and media type of each scheme
+const (
+	defaultAPIVersionHeader    = "API-Version"
+	defaultAPIVersionQuery     = "api-version"
+	defaultAPIVersionMediaType = "application/json"
+	apiVersionMediaTypeParam   = "version"
+)
+
+// APIVersioning describes how a client negotiates API versions with its upstream
+// Requests carry the version set with WithAPIVersion, else Default, in the way Scheme describes. The version the
+// server reports is read from ResponseHeader, or else from where Scheme sent it: the Name header for
+// APIVersionHeader, the version parameter of Content-Type for APIVersionAccept and the API-Version header for
+// APIVersionQuery; see Response.APIVersion.
+type APIVersioning struct {
+	Default        string           // Version of requests without WithAPIVersion, none when empty
+	Scheme         APIVersionScheme // How the version is sent (default: APIVersionHeader)
+	Name           string           // Header, query parameter or media type carrying the version (default: "API-Version", "api-version" or "application/json")
+	ResponseHeader string           // Header reporting the version served (default: see above)
+}
+
+// apply sends version with req as the scheme describes
+func (v APIVersioning) apply(req *http.Request, version string) {
+	switch v.Scheme {
+	case APIVersionQuery:
+		query := req.URL.Query()
+		query.Set(v.name(), version)
+		req.URL.RawQuery = query.Encode()
+	case APIVersionAccept:
+		req.Header.Set("Accept", mime.FormatMediaType(v.name(), map[string]string{apiVersionMediaTypeParam: version}))
+	default:
+		req.Header.Set(v.name(), version)
+	}
+}
+
+// Reported returns the API version the server reports in resp, or "" if it reports none
+func (v APIVersioning) Reported(resp *http.Response) string {
+	if v.ResponseHeader != "" {
+		return resp.Header.Get(v.ResponseHeader)
+	}
+	switch v.Scheme {
+	case APIVersionQuery:
+		return resp.Header.Get(defaultAPIVersionHeader)
+	case APIVersionAccept:
+		_, params, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+		if err != nil {
+			return ""
+		}
+		return params[apiVersionMediaTypeParam]
+	default:
+		return resp.Header.Get(v.name())
+	}
+}
+
+// name returns the header, query parameter or media type carrying the version
+func (v APIVersioning) name() string {
+	if v.Name != "" {
+		return v.Name
+	}
+	switch v.Scheme {
+	case APIVersionQuery:
+		return defaultAPIVersionQuery
+	case APIVersionAccept:
+		return defaultAPIVersionMediaType
+	default:
+		return defaultAPIVersionHeader
+	}
+}
+
+// applyAPIVersion sends the version of the request, or the default one of the client, with req
+func applyAPIVersion(req *http.Request, version string, versioning *APIVersioning) {
+	versioning = cmp.Or(versioning, &APIVersioning{})
+	if version == "" {
+		version = versioning.Default
+	}
+	if version != "" {
+		versioning.apply(req, version)
+	}
+}
+
+// reportedAPIVersion returns the API version the server reports in resp
+func reportedAPIVersion(resp *http.Response, versioning *APIVersioning) string {
+	return cmp.Or(versioning, &APIVersioning{}).Reported(resp)
+}
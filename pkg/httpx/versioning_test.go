@@ -0,0 +1,90 @@
+package httpx_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bdpiprava/easy-http/pkg/httpx"
+)
+
+func TestAPIVersioning(t *testing.T) {
+	var received *http.Request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r
+		w.Header().Set("API-Version", "2024-06-01")
+		w.Header().Set("X-Served-Version", "7")
+		w.Header().Set("Content-Type", "application/vnd.example+json; version=2")
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	testCases := []struct {
+		name        string
+		versioning  *httpx.APIVersioning
+		opts        []httpx.RequestOption
+		wantHeader  map[string]string
+		wantQuery   string
+		wantVersion string
+	}{
+		{
+			name:        "sends the request version in the API-Version header by default",
+			opts:        []httpx.RequestOption{httpx.WithAPIVersion("2024-06-01")},
+			wantHeader:  map[string]string{"API-Version": "2024-06-01"},
+			wantVersion: "2024-06-01",
+		},
+		{
+			name:        "sends no version when none is set",
+			wantHeader:  map[string]string{"API-Version": ""},
+			wantVersion: "2024-06-01",
+		},
+		{
+			name:        "sends the default version in a custom header",
+			versioning:  &httpx.APIVersioning{Default: "3", Name: "X-Api-Version", ResponseHeader: "X-Served-Version"},
+			wantHeader:  map[string]string{"X-Api-Version": "3"},
+			wantVersion: "7",
+		},
+		{
+			name:        "sends the version as a query parameter",
+			versioning:  &httpx.APIVersioning{Default: "2023-01-01", Scheme: httpx.APIVersionQuery},
+			opts:        []httpx.RequestOption{httpx.WithQueryParam("page", "2")},
+			wantQuery:   "api-version=2023-01-01&page=2",
+			wantVersion: "2024-06-01",
+		},
+		{
+			name:        "overrides the default version per request",
+			versioning:  &httpx.APIVersioning{Default: "2023-01-01", Scheme: httpx.APIVersionQuery, Name: "v"},
+			opts:        []httpx.RequestOption{httpx.WithAPIVersion("2024-06-01")},
+			wantQuery:   "v=2024-06-01",
+			wantVersion: "2024-06-01",
+		},
+		{
+			name:        "sends the version as a media type parameter",
+			versioning:  &httpx.APIVersioning{Default: "2", Scheme: httpx.APIVersionAccept, Name: "application/vnd.example+json"},
+			wantHeader:  map[string]string{"Accept": "application/vnd.example+json; version=2"},
+			wantVersion: "2",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			opts := []httpx.ClientConfigOption{httpx.WithClientDefaultBaseURL(server.URL)}
+			if tc.versioning != nil {
+				opts = append(opts, httpx.WithClientAPIVersioning(*tc.versioning))
+			}
+			client := httpx.NewClientWithConfig(opts...)
+
+			resp, err := client.Execute(*httpx.NewRequest(http.MethodGet, tc.opts...), map[string]any{})
+
+			require.NoError(t, err)
+			for name, value := range tc.wantHeader {
+				assert.Equal(t, value, received.Header.Get(name), name)
+			}
+			assert.Equal(t, tc.wantQuery, received.URL.RawQuery)
+			assert.Equal(t, tc.wantVersion, resp.APIVersion)
+		})
+	}
+}